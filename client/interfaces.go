@@ -3,6 +3,7 @@ package lxd
 import (
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/websocket"
 
@@ -145,16 +146,20 @@ type InstanceServer interface {
 	GetInstancesFull(instanceType api.InstanceType) (instances []api.InstanceFull, err error)
 	GetInstance(name string) (instance *api.Instance, ETag string, err error)
 	CreateInstance(instance api.InstancesPost) (op Operation, err error)
+	UpdateInstances(state api.InstancesPut, ETag string) (op Operation, err error)
 	CreateInstanceFromImage(source ImageServer, image api.Image, req api.InstancesPost) (op RemoteOperation, err error)
 	CopyInstance(source InstanceServer, instance api.Instance, args *InstanceCopyArgs) (op RemoteOperation, err error)
 	UpdateInstance(name string, instance api.InstancePut, ETag string) (op Operation, err error)
 	RenameInstance(name string, instance api.InstancePost) (op Operation, err error)
 	MigrateInstance(name string, instance api.InstancePost) (op Operation, err error)
 	DeleteInstance(name string) (op Operation, err error)
+	CreateInstanceClones(name string, clones api.InstanceClonesPost) (op Operation, err error)
+	ClaimInstance(name string, claim api.InstanceWarmPoolClaimPost) (instance *api.Instance, err error)
 
 	ExecInstance(instanceName string, exec api.InstanceExecPost, args *InstanceExecArgs) (op Operation, err error)
 	ConsoleInstance(instanceName string, console api.InstanceConsolePost, args *InstanceConsoleArgs) (op Operation, err error)
 	GetInstanceConsoleLog(instanceName string, args *InstanceConsoleLogArgs) (content io.ReadCloser, err error)
+	GetInstanceConsoleScreenshot(instanceName string) (content io.ReadCloser, err error)
 	DeleteInstanceConsoleLog(instanceName string, args *InstanceConsoleLogArgs) (err error)
 
 	GetInstanceFile(instanceName string, path string) (content io.ReadCloser, resp *InstanceFileResponse, err error)
@@ -164,6 +169,11 @@ type InstanceServer interface {
 	GetInstanceSnapshotNames(instanceName string) (names []string, err error)
 	GetInstanceSnapshots(instanceName string) (snapshots []api.InstanceSnapshot, err error)
 	GetInstanceSnapshot(instanceName string, name string) (snapshot *api.InstanceSnapshot, ETag string, err error)
+	GetInstanceSnapshotDiff(instanceName string, name string, target string) (op Operation, err error)
+	GetInstanceSnapshotFile(instanceName string, name string, filePath string) (content io.ReadCloser, resp *InstanceFileResponse, err error)
+	RestoreInstanceSnapshotFiles(instanceName string, name string, files api.InstanceSnapshotFilesPost) (op Operation, err error)
+	MountInstanceSnapshot(instanceName string, name string, mount api.InstanceSnapshotMountPost) (op Operation, err error)
+	UnmountInstanceSnapshot(instanceName string, name string) (op Operation, err error)
 	CreateInstanceSnapshot(instanceName string, snapshot api.InstanceSnapshotsPost) (op Operation, err error)
 	CopyInstanceSnapshot(source InstanceServer, instanceName string, snapshot api.InstanceSnapshot, args *InstanceSnapshotCopyArgs) (op RemoteOperation, err error)
 	RenameInstanceSnapshot(instanceName string, name string, instance api.InstanceSnapshotPost) (op Operation, err error)
@@ -178,10 +188,25 @@ type InstanceServer interface {
 	RenameInstanceBackup(instanceName string, name string, backup api.InstanceBackupPost) (op Operation, err error)
 	DeleteInstanceBackup(instanceName string, name string) (op Operation, err error)
 	GetInstanceBackupFile(instanceName string, name string, req *BackupFileRequest) (resp *BackupFileResponse, err error)
+	ExportInstance(name string, req *InstanceExportRequest) (resp *BackupFileResponse, err error)
 	CreateInstanceFromBackup(args InstanceBackupArgs) (op Operation, err error)
 
 	GetInstanceState(name string) (state *api.InstanceState, ETag string, err error)
 	UpdateInstanceState(name string, state api.InstanceStatePut, ETag string) (op Operation, err error)
+	GetInstanceHistory(name string) (history []api.InstanceHistoryEntry, err error)
+
+	GetInstanceSchedules(instanceName string) (schedules []api.InstanceSchedule, err error)
+	GetInstanceSchedule(instanceName string, name string) (schedule *api.InstanceSchedule, ETag string, err error)
+	CreateInstanceSchedule(instanceName string, schedule api.InstanceSchedulesPost) (err error)
+	UpdateInstanceSchedule(instanceName string, name string, schedule api.InstanceSchedulePut, ETag string) (err error)
+	DeleteInstanceSchedule(instanceName string, name string) (err error)
+
+	GetInstanceReplications(instanceName string) (replications []api.InstanceReplication, err error)
+	GetInstanceReplication(instanceName string, name string) (replication *api.InstanceReplication, ETag string, err error)
+	CreateInstanceReplication(instanceName string, replication api.InstanceReplicationsPost) (err error)
+	UpdateInstanceReplication(instanceName string, name string, replication api.InstanceReplicationPut, ETag string) (err error)
+	DeleteInstanceReplication(instanceName string, name string) (err error)
+	PromoteInstance(name string) (err error)
 
 	GetInstanceLogfiles(name string) (logfiles []string, err error)
 	GetInstanceLogfile(name string, filename string) (content io.ReadCloser, err error)
@@ -196,6 +221,14 @@ type InstanceServer interface {
 	UpdateInstanceTemplateFile(instanceName string, templateName string, content io.ReadSeeker) (err error)
 	DeleteInstanceTemplateFile(name string, templateName string) (err error)
 
+	// Instance group functions ("instance_groups" API extension)
+	GetInstanceGroupNames() (names []string, err error)
+	GetInstanceGroups() (groups []api.InstanceGroup, err error)
+	GetInstanceGroup(name string) (group *api.InstanceGroup, ETag string, err error)
+	CreateInstanceGroup(group api.InstanceGroupsPost) (err error)
+	UpdateInstanceGroup(name string, group api.InstanceGroupPut, ETag string) (err error)
+	DeleteInstanceGroup(name string) (err error)
+
 	// Event handling functions
 	GetEvents() (listener *EventListener, err error)
 
@@ -222,6 +255,22 @@ type InstanceServer interface {
 	RenameNetwork(name string, network api.NetworkPost) (err error)
 	DeleteNetwork(name string) (err error)
 
+	// Network reservation functions ("network_address_reservations" API extension)
+	GetNetworkReservationNames(networkName string) (names []string, err error)
+	GetNetworkReservations(networkName string) (reservations []api.NetworkReservation, err error)
+	GetNetworkReservation(networkName string, name string) (reservation *api.NetworkReservation, ETag string, err error)
+	CreateNetworkReservation(networkName string, reservation api.NetworkReservationsPost) (err error)
+	UpdateNetworkReservation(networkName string, name string, reservation api.NetworkReservationPut, ETag string) (err error)
+	DeleteNetworkReservation(networkName string, name string) (err error)
+
+	// Network peer functions ("network_peers" API extension)
+	GetNetworkPeerNames(networkName string) (names []string, err error)
+	GetNetworkPeers(networkName string) (peers []api.NetworkPeer, err error)
+	GetNetworkPeer(networkName string, name string) (peer *api.NetworkPeer, ETag string, err error)
+	CreateNetworkPeer(networkName string, peer api.NetworkPeersPost) (err error)
+	UpdateNetworkPeer(networkName string, name string, peer api.NetworkPeerPut, ETag string) (err error)
+	DeleteNetworkPeer(networkName string, name string) (err error)
+
 	// Operation functions
 	GetOperationUUIDs() (uuids []string, err error)
 	GetOperations() (operations []api.Operation, err error)
@@ -256,6 +305,7 @@ type InstanceServer interface {
 	CreateStoragePool(pool api.StoragePoolsPost) (err error)
 	UpdateStoragePool(name string, pool api.StoragePoolPut, ETag string) (err error)
 	DeleteStoragePool(name string) (err error)
+	TrimStoragePool(name string) (op Operation, err error)
 
 	// Storage volume functions ("storage" API extension)
 	GetStoragePoolVolumeNames(pool string) (names []string, err error)
@@ -287,6 +337,19 @@ type InstanceServer interface {
 	GetClusterMember(name string) (member *api.ClusterMember, ETag string, err error)
 	UpdateClusterMember(name string, member api.ClusterMemberPut, ETag string) (err error)
 	RenameClusterMember(name string, member api.ClusterMemberPost) (err error)
+	GetClusterGroupNames() (names []string, err error)
+	GetClusterGroups() (groups []api.ClusterGroup, err error)
+	GetClusterGroup(name string) (group *api.ClusterGroup, ETag string, err error)
+	CreateClusterGroup(group api.ClusterGroupsPost) (err error)
+	UpdateClusterGroup(name string, group api.ClusterGroupPut, ETag string) (err error)
+	DeleteClusterGroup(name string) (err error)
+
+	// Warning functions ("warnings" API extension)
+	GetWarningUUIDs() (uuids []string, err error)
+	GetWarnings() (warnings []api.Warning, err error)
+	GetWarning(uuid string) (warning *api.Warning, ETag string, err error)
+	UpdateWarning(uuid string, warning api.WarningPut, ETag string) (err error)
+	DeleteWarning(uuid string) (err error)
 
 	// Internal functions (for internal use)
 	RawQuery(method string, path string, data interface{}, queryETag string) (resp *api.Response, ETag string, err error)
@@ -322,6 +385,29 @@ type BackupFileResponse struct {
 	Size int64
 }
 
+// The InstanceExportRequest struct is used for streamed instance exports.
+//
+// API extension: instance_export_streaming
+type InstanceExportRequest struct {
+	// Writer for the backup tarball
+	BackupFile io.Writer
+
+	// Whether to leave the snapshots out
+	InstanceOnly bool
+
+	// Whether to use the optimized format of the storage driver
+	OptimizedStorage bool
+
+	// Compression algorithm, the default one of the server if empty
+	CompressionAlgorithm string
+
+	// Progress handler (called whenever some progress is made)
+	ProgressHandler func(progress ioprogress.ProgressData)
+
+	// A canceler that can be used to interrupt the export
+	Canceler *cancel.Canceler
+}
+
 // The ImageCreateArgs struct is used for direct image upload.
 type ImageCreateArgs struct {
 	// Reader for the meta file
@@ -440,6 +526,16 @@ type InstanceCopyArgs struct {
 	// API extension: container_incremental_copy
 	// Perform an incremental copy
 	Refresh bool
+
+	// API extension: migration_max_downtime
+	// Maximum downtime of a live migration in milliseconds, the migration being rolled back if exceeded
+	MaxDowntime int64
+
+	// API extension: operation_not_before
+	// Don't start the copy before the given time, and only during the off-peak windows of the server
+	// if OffPeak is set (only for copies within the same server)
+	NotBefore time.Time
+	OffPeak   bool
 }
 
 // The InstanceSnapshotCopyArgs struct is used to pass additional options during instance copy.
@@ -2,6 +2,8 @@ package lxd
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
 
 	"github.com/lxc/lxd/shared/api"
 )
@@ -136,3 +138,97 @@ func (r *ProtocolLXD) RenameClusterMember(name string, member api.ClusterMemberP
 
 	return nil
 }
+
+// GetClusterGroupNames returns the names of the cluster groups
+func (r *ProtocolLXD) GetClusterGroupNames() ([]string, error) {
+	if !r.HasExtension("cluster_groups") {
+		return nil, fmt.Errorf("The server is missing the required \"cluster_groups\" API extension")
+	}
+
+	urls := []string{}
+	_, err := r.queryStruct("GET", "/cluster/groups", nil, "", &urls)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse it
+	names := []string{}
+	for _, uri := range urls {
+		fields := strings.Split(uri, "/cluster/groups/")
+		names = append(names, fields[len(fields)-1])
+	}
+
+	return names, nil
+}
+
+// GetClusterGroups returns the cluster groups
+func (r *ProtocolLXD) GetClusterGroups() ([]api.ClusterGroup, error) {
+	if !r.HasExtension("cluster_groups") {
+		return nil, fmt.Errorf("The server is missing the required \"cluster_groups\" API extension")
+	}
+
+	groups := []api.ClusterGroup{}
+	_, err := r.queryStruct("GET", "/cluster/groups?recursion=1", nil, "", &groups)
+	if err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// GetClusterGroup returns information about the given cluster group
+func (r *ProtocolLXD) GetClusterGroup(name string) (*api.ClusterGroup, string, error) {
+	if !r.HasExtension("cluster_groups") {
+		return nil, "", fmt.Errorf("The server is missing the required \"cluster_groups\" API extension")
+	}
+
+	group := api.ClusterGroup{}
+	etag, err := r.queryStruct("GET", fmt.Sprintf("/cluster/groups/%s", url.PathEscape(name)), nil, "", &group)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &group, etag, nil
+}
+
+// CreateClusterGroup defines a new cluster group
+func (r *ProtocolLXD) CreateClusterGroup(group api.ClusterGroupsPost) error {
+	if !r.HasExtension("cluster_groups") {
+		return fmt.Errorf("The server is missing the required \"cluster_groups\" API extension")
+	}
+
+	_, _, err := r.query("POST", "/cluster/groups", group, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateClusterGroup updates the description and the members of the given cluster group
+func (r *ProtocolLXD) UpdateClusterGroup(name string, group api.ClusterGroupPut, ETag string) error {
+	if !r.HasExtension("cluster_groups") {
+		return fmt.Errorf("The server is missing the required \"cluster_groups\" API extension")
+	}
+
+	_, _, err := r.query("PUT", fmt.Sprintf("/cluster/groups/%s", url.PathEscape(name)), group, ETag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteClusterGroup removes the given cluster group
+func (r *ProtocolLXD) DeleteClusterGroup(name string) error {
+	if !r.HasExtension("cluster_groups") {
+		return fmt.Errorf("The server is missing the required \"cluster_groups\" API extension")
+	}
+
+	_, _, err := r.query("DELETE", fmt.Sprintf("/cluster/groups/%s", url.PathEscape(name)), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
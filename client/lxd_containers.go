@@ -1908,6 +1908,11 @@ func (r *ProtocolLXD) GetContainerBackupFile(containerName string, name string,
 		return nil, err
 	}
 
+	// Errors happening while merging incremental backups are reported in a trailer.
+	if response.Trailer.Get("X-LXD-Error") != "" {
+		return nil, fmt.Errorf("Failed to fetch the backup: %s", response.Trailer.Get("X-LXD-Error"))
+	}
+
 	resp := BackupFileResponse{}
 	resp.Size = size
 
@@ -0,0 +1,114 @@
+package lxd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Instance group handling functions
+
+// GetInstanceGroupNames returns a list of instance group names
+func (r *ProtocolLXD) GetInstanceGroupNames() ([]string, error) {
+	if !r.HasExtension("instance_groups") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_groups\" API extension")
+	}
+
+	urls := []string{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", "/instance-groups", nil, "", &urls)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse it
+	names := []string{}
+	for _, uri := range urls {
+		fields := strings.Split(uri, "/instance-groups/")
+		names = append(names, fields[len(fields)-1])
+	}
+
+	return names, nil
+}
+
+// GetInstanceGroups returns a list of instance groups
+func (r *ProtocolLXD) GetInstanceGroups() ([]api.InstanceGroup, error) {
+	if !r.HasExtension("instance_groups") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_groups\" API extension")
+	}
+
+	groups := []api.InstanceGroup{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", "/instance-groups?recursion=1", nil, "", &groups)
+	if err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// GetInstanceGroup returns the instance group with the given name
+func (r *ProtocolLXD) GetInstanceGroup(name string) (*api.InstanceGroup, string, error) {
+	if !r.HasExtension("instance_groups") {
+		return nil, "", fmt.Errorf("The server is missing the required \"instance_groups\" API extension")
+	}
+
+	group := api.InstanceGroup{}
+
+	// Fetch the raw value
+	etag, err := r.queryStruct("GET", fmt.Sprintf("/instance-groups/%s", url.PathEscape(name)), nil, "", &group)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &group, etag, nil
+}
+
+// CreateInstanceGroup defines a new instance group
+func (r *ProtocolLXD) CreateInstanceGroup(group api.InstanceGroupsPost) error {
+	if !r.HasExtension("instance_groups") {
+		return fmt.Errorf("The server is missing the required \"instance_groups\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("POST", "/instance-groups", group, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateInstanceGroup updates the instance group to match the provided struct
+func (r *ProtocolLXD) UpdateInstanceGroup(name string, group api.InstanceGroupPut, ETag string) error {
+	if !r.HasExtension("instance_groups") {
+		return fmt.Errorf("The server is missing the required \"instance_groups\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("PUT", fmt.Sprintf("/instance-groups/%s", url.PathEscape(name)), group, ETag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteInstanceGroup removes the instance group with the given name
+func (r *ProtocolLXD) DeleteInstanceGroup(name string) error {
+	if !r.HasExtension("instance_groups") {
+		return fmt.Errorf("The server is missing the required \"instance_groups\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("DELETE", fmt.Sprintf("/instance-groups/%s", url.PathEscape(name)), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -402,6 +402,15 @@ func (r *ProtocolLXD) CopyInstance(source InstanceServer, instance api.Instance,
 		req.Source.InstanceOnly = args.InstanceOnly
 		req.Source.ContainerOnly = args.InstanceOnly // For legacy servers.
 		req.Source.Refresh = args.Refresh
+
+		if !args.NotBefore.IsZero() || args.OffPeak {
+			if !r.HasExtension("operation_not_before") {
+				return nil, fmt.Errorf("The target server is missing the required \"operation_not_before\" API extension")
+			}
+
+			req.Source.NotBefore = args.NotBefore
+			req.Source.OffPeak = args.OffPeak
+		}
 	}
 
 	if req.Source.Live {
@@ -461,6 +470,15 @@ func (r *ProtocolLXD) CopyInstance(source InstanceServer, instance api.Instance,
 		InstanceOnly:  req.Source.InstanceOnly,
 	}
 
+	// The maximum downtime only applies to live migrations, stopped instances not going down.
+	if args != nil && args.MaxDowntime > 0 && req.Source.Live {
+		if !source.HasExtension("migration_max_downtime") {
+			return nil, fmt.Errorf("The source server is missing the required \"migration_max_downtime\" API extension")
+		}
+
+		sourceReq.MaxDowntime = args.MaxDowntime
+	}
+
 	// Push mode migration
 	if args != nil && args.Mode == "push" {
 		// Get target server connection information
@@ -570,6 +588,10 @@ func (r *ProtocolLXD) UpdateInstance(name string, instance api.InstancePut, ETag
 		return nil, err
 	}
 
+	if len(instance.RestorePaths) > 0 && !r.HasExtension("snapshot_restore_paths") {
+		return nil, fmt.Errorf("The server is missing the required \"snapshot_restore_paths\" API extension")
+	}
+
 	// Send the request
 	op, _, err := r.queryOperation("PUT", fmt.Sprintf("%s/%s", path, url.PathEscape(name)), instance, ETag)
 	if err != nil {
@@ -591,6 +613,10 @@ func (r *ProtocolLXD) RenameInstance(name string, instance api.InstancePost) (Op
 		return nil, fmt.Errorf("Can't ask for a migration through RenameInstance")
 	}
 
+	if instance.Project != "" && !r.HasExtension("instance_project_move") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_project_move\" API extension")
+	}
+
 	// Send the request
 	op, _, err := r.queryOperation("POST", fmt.Sprintf("%s/%s", path, url.PathEscape(name)), instance, "")
 	if err != nil {
@@ -875,7 +901,12 @@ func (r *ProtocolLXD) GetInstanceFile(instanceName string, filePath string) (io.
 		return nil, nil, err
 	}
 
-	requestURL, err = r.setQueryAttributes(requestURL)
+	return r.getInstanceFile(requestURL)
+}
+
+// getInstanceFile retrieves the file or directory listing served at the provided URL.
+func (r *ProtocolLXD) getInstanceFile(requestURL string) (io.ReadCloser, *InstanceFileResponse, error) {
+	requestURL, err := r.setQueryAttributes(requestURL)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -1118,6 +1149,115 @@ func (r *ProtocolLXD) GetInstanceSnapshot(instanceName string, name string) (*ap
 	return &snapshot, etag, nil
 }
 
+// GetInstanceSnapshotDiff requests the paths which differ between the snapshot and either another
+// snapshot of the instance, or the instance itself if target is empty. The changes are returned in the
+// "changes" field of the operation metadata.
+func (r *ProtocolLXD) GetInstanceSnapshotDiff(instanceName string, name string, target string) (Operation, error) {
+	if !r.HasExtension("instance_snapshot_diff") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_snapshot_diff\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	uri := fmt.Sprintf("%s/%s/snapshots/%s/diff", path, url.PathEscape(instanceName), url.PathEscape(name))
+	if target != "" {
+		uri += fmt.Sprintf("?target=%s", url.QueryEscape(target))
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("GET", uri, nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// GetInstanceSnapshotFile retrieves the provided path from the instance snapshot.
+func (r *ProtocolLXD) GetInstanceSnapshotFile(instanceName string, name string, filePath string) (io.ReadCloser, *InstanceFileResponse, error) {
+	if !r.HasExtension("instance_snapshot_files") {
+		return nil, nil, fmt.Errorf("The server is missing the required \"instance_snapshot_files\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Prepare the HTTP request
+	requestURL, err := shared.URLEncode(
+		fmt.Sprintf("%s/1.0%s/%s/snapshots/%s/files", r.httpHost, path, url.PathEscape(instanceName), url.PathEscape(name)),
+		map[string]string{"path": filePath})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r.getInstanceFile(requestURL)
+}
+
+// RestoreInstanceSnapshotFiles copies the provided paths from the snapshot back into the instance.
+func (r *ProtocolLXD) RestoreInstanceSnapshotFiles(instanceName string, name string, files api.InstanceSnapshotFilesPost) (Operation, error) {
+	if !r.HasExtension("instance_snapshot_files") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_snapshot_files\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", fmt.Sprintf("%s/%s/snapshots/%s/files", path, url.PathEscape(instanceName), url.PathEscape(name)), files, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// MountInstanceSnapshot exposes the snapshot read-only inside the instance.
+func (r *ProtocolLXD) MountInstanceSnapshot(instanceName string, name string, mount api.InstanceSnapshotMountPost) (Operation, error) {
+	if !r.HasExtension("instance_snapshot_mount") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_snapshot_mount\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", fmt.Sprintf("%s/%s/snapshots/%s/mount", path, url.PathEscape(instanceName), url.PathEscape(name)), mount, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// UnmountInstanceSnapshot stops exposing the snapshot inside the instance.
+func (r *ProtocolLXD) UnmountInstanceSnapshot(instanceName string, name string) (Operation, error) {
+	if !r.HasExtension("instance_snapshot_mount") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_snapshot_mount\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("DELETE", fmt.Sprintf("%s/%s/snapshots/%s/mount", path, url.PathEscape(instanceName), url.PathEscape(name)), nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
 // CreateInstanceSnapshot requests that LXD creates a new snapshot for the instance.
 func (r *ProtocolLXD) CreateInstanceSnapshot(instanceName string, snapshot api.InstanceSnapshotsPost) (Operation, error) {
 	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
@@ -1505,6 +1645,10 @@ func (r *ProtocolLXD) GetInstanceState(name string) (*api.InstanceState, string,
 
 // UpdateInstanceState updates the instance to match the requested state.
 func (r *ProtocolLXD) UpdateInstanceState(name string, state api.InstanceStatePut, ETag string) (Operation, error) {
+	if state.Signal != "" && !r.HasExtension("instance_stop_signal") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_stop_signal\" API extension")
+	}
+
 	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
 	if err != nil {
 		return nil, err
@@ -1519,6 +1663,322 @@ func (r *ProtocolLXD) UpdateInstanceState(name string, state api.InstanceStatePu
 	return op, nil
 }
 
+// UpdateInstances updates the state of several instances at once.
+func (r *ProtocolLXD) UpdateInstances(state api.InstancesPut, ETag string) (Operation, error) {
+	if !r.HasExtension("instance_bulk_state_change") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_bulk_state_change\" API extension")
+	}
+
+	if state.Group != "" && !r.HasExtension("instance_groups") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_groups\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("PUT", path, state, ETag)
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// CreateInstanceClones creates several copies of the instance in a single operation.
+func (r *ProtocolLXD) CreateInstanceClones(name string, clones api.InstanceClonesPost) (Operation, error) {
+	if !r.HasExtension("instance_clones") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_clones\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", fmt.Sprintf("%s/%s/clones", path, url.PathEscape(name)), clones, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// ClaimInstance takes an instance out of the warm pool of the given instance.
+func (r *ProtocolLXD) ClaimInstance(name string, claim api.InstanceWarmPoolClaimPost) (*api.Instance, error) {
+	if !r.HasExtension("instance_warm_pool") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_warm_pool\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the request
+	instance := api.Instance{}
+	_, err = r.queryStruct("POST", fmt.Sprintf("%s/%s/claim", path, url.PathEscape(name)), claim, "", &instance)
+	if err != nil {
+		return nil, err
+	}
+
+	return &instance, nil
+}
+
+// GetInstanceHistory returns the recorded state changes of the instance.
+func (r *ProtocolLXD) GetInstanceHistory(name string) ([]api.InstanceHistoryEntry, error) {
+	if !r.HasExtension("instance_history") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_history\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	history := []api.InstanceHistoryEntry{}
+
+	// Fetch the raw value
+	_, err = r.queryStruct("GET", fmt.Sprintf("%s/%s/history", path, url.PathEscape(name)), nil, "", &history)
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// GetInstanceSchedules returns the schedules of the instance.
+func (r *ProtocolLXD) GetInstanceSchedules(instanceName string) ([]api.InstanceSchedule, error) {
+	if !r.HasExtension("instance_schedules") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_schedules\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules := []api.InstanceSchedule{}
+
+	// Fetch the raw value
+	_, err = r.queryStruct("GET", fmt.Sprintf("%s/%s/schedules?recursion=1", path, url.PathEscape(instanceName)), nil, "", &schedules)
+	if err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+// GetInstanceSchedule returns the schedule of the instance with the given name.
+func (r *ProtocolLXD) GetInstanceSchedule(instanceName string, name string) (*api.InstanceSchedule, string, error) {
+	if !r.HasExtension("instance_schedules") {
+		return nil, "", fmt.Errorf("The server is missing the required \"instance_schedules\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, "", err
+	}
+
+	schedule := api.InstanceSchedule{}
+
+	// Fetch the raw value
+	etag, err := r.queryStruct("GET", fmt.Sprintf("%s/%s/schedules/%s", path, url.PathEscape(instanceName), url.PathEscape(name)), nil, "", &schedule)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &schedule, etag, nil
+}
+
+// CreateInstanceSchedule adds a schedule to the instance.
+func (r *ProtocolLXD) CreateInstanceSchedule(instanceName string, schedule api.InstanceSchedulesPost) error {
+	if !r.HasExtension("instance_schedules") {
+		return fmt.Errorf("The server is missing the required \"instance_schedules\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return err
+	}
+
+	// Send the request
+	_, _, err = r.query("POST", fmt.Sprintf("%s/%s/schedules", path, url.PathEscape(instanceName)), schedule, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateInstanceSchedule updates the schedule of the instance with the given name.
+func (r *ProtocolLXD) UpdateInstanceSchedule(instanceName string, name string, schedule api.InstanceSchedulePut, ETag string) error {
+	if !r.HasExtension("instance_schedules") {
+		return fmt.Errorf("The server is missing the required \"instance_schedules\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return err
+	}
+
+	// Send the request
+	_, _, err = r.query("PUT", fmt.Sprintf("%s/%s/schedules/%s", path, url.PathEscape(instanceName), url.PathEscape(name)), schedule, ETag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteInstanceSchedule removes the schedule of the instance with the given name.
+func (r *ProtocolLXD) DeleteInstanceSchedule(instanceName string, name string) error {
+	if !r.HasExtension("instance_schedules") {
+		return fmt.Errorf("The server is missing the required \"instance_schedules\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return err
+	}
+
+	// Send the request
+	_, _, err = r.query("DELETE", fmt.Sprintf("%s/%s/schedules/%s", path, url.PathEscape(instanceName), url.PathEscape(name)), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetInstanceReplications returns the replications of the instance.
+func (r *ProtocolLXD) GetInstanceReplications(instanceName string) ([]api.InstanceReplication, error) {
+	if !r.HasExtension("instance_replications") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_replications\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	replications := []api.InstanceReplication{}
+
+	// Fetch the raw value
+	_, err = r.queryStruct("GET", fmt.Sprintf("%s/%s/replications?recursion=1", path, url.PathEscape(instanceName)), nil, "", &replications)
+	if err != nil {
+		return nil, err
+	}
+
+	return replications, nil
+}
+
+// GetInstanceReplication returns the replication of the instance with the given name.
+func (r *ProtocolLXD) GetInstanceReplication(instanceName string, name string) (*api.InstanceReplication, string, error) {
+	if !r.HasExtension("instance_replications") {
+		return nil, "", fmt.Errorf("The server is missing the required \"instance_replications\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, "", err
+	}
+
+	replication := api.InstanceReplication{}
+
+	// Fetch the raw value
+	etag, err := r.queryStruct("GET", fmt.Sprintf("%s/%s/replications/%s", path, url.PathEscape(instanceName), url.PathEscape(name)), nil, "", &replication)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &replication, etag, nil
+}
+
+// CreateInstanceReplication adds a replication to the instance.
+func (r *ProtocolLXD) CreateInstanceReplication(instanceName string, replication api.InstanceReplicationsPost) error {
+	if !r.HasExtension("instance_replications") {
+		return fmt.Errorf("The server is missing the required \"instance_replications\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return err
+	}
+
+	// Send the request
+	_, _, err = r.query("POST", fmt.Sprintf("%s/%s/replications", path, url.PathEscape(instanceName)), replication, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateInstanceReplication updates the replication of the instance with the given name.
+func (r *ProtocolLXD) UpdateInstanceReplication(instanceName string, name string, replication api.InstanceReplicationPut, ETag string) error {
+	if !r.HasExtension("instance_replications") {
+		return fmt.Errorf("The server is missing the required \"instance_replications\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return err
+	}
+
+	// Send the request
+	_, _, err = r.query("PUT", fmt.Sprintf("%s/%s/replications/%s", path, url.PathEscape(instanceName), url.PathEscape(name)), replication, ETag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteInstanceReplication removes the replication of the instance with the given name.
+func (r *ProtocolLXD) DeleteInstanceReplication(instanceName string, name string) error {
+	if !r.HasExtension("instance_replications") {
+		return fmt.Errorf("The server is missing the required \"instance_replications\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return err
+	}
+
+	// Send the request
+	_, _, err = r.query("DELETE", fmt.Sprintf("%s/%s/replications/%s", path, url.PathEscape(instanceName), url.PathEscape(name)), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// PromoteInstance turns a replica into a regular instance, which can be started and doesn't get
+// synced anymore.
+func (r *ProtocolLXD) PromoteInstance(name string) error {
+	if !r.HasExtension("instance_replications") {
+		return fmt.Errorf("The server is missing the required \"instance_replications\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return err
+	}
+
+	// Send the request
+	_, _, err = r.query("POST", fmt.Sprintf("%s/%s/promote", path, url.PathEscape(name)), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // GetInstanceLogfiles returns a list of logfiles for the instance.
 func (r *ProtocolLXD) GetInstanceLogfiles(name string) ([]string, error) {
 	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
@@ -1900,6 +2360,52 @@ func (r *ProtocolLXD) GetInstanceConsoleLog(instanceName string, args *InstanceC
 	return resp.Body, err
 }
 
+// GetInstanceConsoleScreenshot returns a PNG screenshot of the display of a virtual machine.
+func (r *ProtocolLXD) GetInstanceConsoleScreenshot(instanceName string) (io.ReadCloser, error) {
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	if !r.HasExtension("instance_console_screenshot") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_console_screenshot\" API extension")
+	}
+
+	// Prepare the HTTP request
+	url := fmt.Sprintf("%s/1.0%s/%s/console/screenshot", r.httpHost, path, url.PathEscape(instanceName))
+
+	url, err = r.setQueryAttributes(url)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set the user agent
+	if r.httpUserAgent != "" {
+		req.Header.Set("User-Agent", r.httpUserAgent)
+	}
+
+	// Send the request
+	resp, err := r.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the return value for a cleaner error
+	if resp.StatusCode != http.StatusOK {
+		_, _, err := lxdParseResponse(resp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return resp.Body, nil
+}
+
 // DeleteInstanceConsoleLog deletes the requested instance's console log.
 func (r *ProtocolLXD) DeleteInstanceConsoleLog(instanceName string, args *InstanceConsoleLogArgs) error {
 	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
@@ -2002,6 +2508,10 @@ func (r *ProtocolLXD) CreateInstanceBackup(instanceName string, backup api.Insta
 		return nil, fmt.Errorf("The server is missing the required \"container_backup\" API extension")
 	}
 
+	if (!backup.NotBefore.IsZero() || backup.OffPeak) && !r.HasExtension("operation_not_before") {
+		return nil, fmt.Errorf("The server is missing the required \"operation_not_before\" API extension")
+	}
+
 	// Send the request
 	op, _, err := r.queryOperation("POST", fmt.Sprintf("%s/%s/backups", path, url.PathEscape(instanceName)), backup, "")
 	if err != nil {
@@ -2112,6 +2622,99 @@ func (r *ProtocolLXD) GetInstanceBackupFile(instanceName string, name string, re
 		return nil, err
 	}
 
+	// Errors happening while merging incremental backups are reported in a trailer.
+	if response.Trailer.Get("X-LXD-Error") != "" {
+		return nil, fmt.Errorf("Failed to fetch the backup: %s", response.Trailer.Get("X-LXD-Error"))
+	}
+
+	resp := BackupFileResponse{}
+	resp.Size = size
+
+	return &resp, nil
+}
+
+// ExportInstance streams a backup tarball of the instance, without it being stored on the server.
+func (r *ProtocolLXD) ExportInstance(name string, req *InstanceExportRequest) (*BackupFileResponse, error) {
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	if !r.HasExtension("instance_export_streaming") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_export_streaming\" API extension")
+	}
+
+	// Build the URL
+	values := url.Values{}
+	if r.project != "" {
+		values.Set("project", r.project)
+	}
+
+	if req.InstanceOnly {
+		values.Set("instance-only", "true")
+	}
+
+	if req.OptimizedStorage {
+		values.Set("optimized-storage", "true")
+	}
+
+	if req.CompressionAlgorithm != "" {
+		values.Set("compression", req.CompressionAlgorithm)
+	}
+
+	uri := fmt.Sprintf("%s/1.0%s/%s/export", r.httpHost, path, url.PathEscape(name))
+	if len(values) > 0 {
+		uri += fmt.Sprintf("?%s", values.Encode())
+	}
+
+	// Prepare the download request
+	request, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.httpUserAgent != "" {
+		request.Header.Set("User-Agent", r.httpUserAgent)
+	}
+
+	// Start the request
+	response, doneCh, err := cancel.CancelableDownload(req.Canceler, r.http, request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	defer close(doneCh)
+
+	if response.StatusCode != http.StatusOK {
+		_, _, err := lxdParseResponse(response)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Handle the data, whose size isn't known in advance
+	body := response.Body
+	if req.ProgressHandler != nil {
+		body = &ioprogress.ProgressReader{
+			ReadCloser: response.Body,
+			Tracker: &ioprogress.ProgressTracker{
+				Handler: func(received int64, speed int64) {
+					req.ProgressHandler(ioprogress.ProgressData{Text: fmt.Sprintf("%s (%s/s)", units.GetByteSizeString(received, 2), units.GetByteSizeString(speed, 2))})
+				},
+			},
+		}
+	}
+
+	size, err := io.Copy(req.BackupFile, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Errors happening while streaming are reported in a trailer.
+	if response.Trailer.Get("X-LXD-Error") != "" {
+		return nil, fmt.Errorf("Failed to stream the backup: %s", response.Trailer.Get("X-LXD-Error"))
+	}
+
 	resp := BackupFileResponse{}
 	resp.Size = size
 
@@ -0,0 +1,114 @@
+package lxd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Network peer handling functions
+
+// GetNetworkPeerNames returns a list of peer names of the network
+func (r *ProtocolLXD) GetNetworkPeerNames(networkName string) ([]string, error) {
+	if !r.HasExtension("network_peers") {
+		return nil, fmt.Errorf("The server is missing the required \"network_peers\" API extension")
+	}
+
+	urls := []string{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/networks/%s/peers", url.PathEscape(networkName)), nil, "", &urls)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse it
+	names := []string{}
+	for _, uri := range urls {
+		fields := strings.Split(uri, "/peers/")
+		names = append(names, fields[len(fields)-1])
+	}
+
+	return names, nil
+}
+
+// GetNetworkPeers returns a list of peers of the network
+func (r *ProtocolLXD) GetNetworkPeers(networkName string) ([]api.NetworkPeer, error) {
+	if !r.HasExtension("network_peers") {
+		return nil, fmt.Errorf("The server is missing the required \"network_peers\" API extension")
+	}
+
+	peers := []api.NetworkPeer{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/networks/%s/peers?recursion=1", url.PathEscape(networkName)), nil, "", &peers)
+	if err != nil {
+		return nil, err
+	}
+
+	return peers, nil
+}
+
+// GetNetworkPeer returns the network peer with the given name
+func (r *ProtocolLXD) GetNetworkPeer(networkName string, name string) (*api.NetworkPeer, string, error) {
+	if !r.HasExtension("network_peers") {
+		return nil, "", fmt.Errorf("The server is missing the required \"network_peers\" API extension")
+	}
+
+	peer := api.NetworkPeer{}
+
+	// Fetch the raw value
+	etag, err := r.queryStruct("GET", fmt.Sprintf("/networks/%s/peers/%s", url.PathEscape(networkName), url.PathEscape(name)), nil, "", &peer)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &peer, etag, nil
+}
+
+// CreateNetworkPeer peers the network with another one
+func (r *ProtocolLXD) CreateNetworkPeer(networkName string, peer api.NetworkPeersPost) error {
+	if !r.HasExtension("network_peers") {
+		return fmt.Errorf("The server is missing the required \"network_peers\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("POST", fmt.Sprintf("/networks/%s/peers", url.PathEscape(networkName)), peer, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateNetworkPeer updates the network peer to match the provided struct
+func (r *ProtocolLXD) UpdateNetworkPeer(networkName string, name string, peer api.NetworkPeerPut, ETag string) error {
+	if !r.HasExtension("network_peers") {
+		return fmt.Errorf("The server is missing the required \"network_peers\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("PUT", fmt.Sprintf("/networks/%s/peers/%s", url.PathEscape(networkName), url.PathEscape(name)), peer, ETag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteNetworkPeer removes the network peer with the given name
+func (r *ProtocolLXD) DeleteNetworkPeer(networkName string, name string) error {
+	if !r.HasExtension("network_peers") {
+		return fmt.Errorf("The server is missing the required \"network_peers\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("DELETE", fmt.Sprintf("/networks/%s/peers/%s", url.PathEscape(networkName), url.PathEscape(name)), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
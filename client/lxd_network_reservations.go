@@ -0,0 +1,114 @@
+package lxd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Network reservation handling functions
+
+// GetNetworkReservationNames returns a list of address reservation names on the network
+func (r *ProtocolLXD) GetNetworkReservationNames(networkName string) ([]string, error) {
+	if !r.HasExtension("network_address_reservations") {
+		return nil, fmt.Errorf("The server is missing the required \"network_address_reservations\" API extension")
+	}
+
+	urls := []string{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/networks/%s/reservations", url.PathEscape(networkName)), nil, "", &urls)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse it
+	names := []string{}
+	for _, uri := range urls {
+		fields := strings.Split(uri, "/reservations/")
+		names = append(names, fields[len(fields)-1])
+	}
+
+	return names, nil
+}
+
+// GetNetworkReservations returns a list of address reservations on the network
+func (r *ProtocolLXD) GetNetworkReservations(networkName string) ([]api.NetworkReservation, error) {
+	if !r.HasExtension("network_address_reservations") {
+		return nil, fmt.Errorf("The server is missing the required \"network_address_reservations\" API extension")
+	}
+
+	reservations := []api.NetworkReservation{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/networks/%s/reservations?recursion=1", url.PathEscape(networkName)), nil, "", &reservations)
+	if err != nil {
+		return nil, err
+	}
+
+	return reservations, nil
+}
+
+// GetNetworkReservation returns the address reservation with the given name
+func (r *ProtocolLXD) GetNetworkReservation(networkName string, name string) (*api.NetworkReservation, string, error) {
+	if !r.HasExtension("network_address_reservations") {
+		return nil, "", fmt.Errorf("The server is missing the required \"network_address_reservations\" API extension")
+	}
+
+	reservation := api.NetworkReservation{}
+
+	// Fetch the raw value
+	etag, err := r.queryStruct("GET", fmt.Sprintf("/networks/%s/reservations/%s", url.PathEscape(networkName), url.PathEscape(name)), nil, "", &reservation)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &reservation, etag, nil
+}
+
+// CreateNetworkReservation reserves addresses on the network
+func (r *ProtocolLXD) CreateNetworkReservation(networkName string, reservation api.NetworkReservationsPost) error {
+	if !r.HasExtension("network_address_reservations") {
+		return fmt.Errorf("The server is missing the required \"network_address_reservations\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("POST", fmt.Sprintf("/networks/%s/reservations", url.PathEscape(networkName)), reservation, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateNetworkReservation updates the address reservation to match the provided struct
+func (r *ProtocolLXD) UpdateNetworkReservation(networkName string, name string, reservation api.NetworkReservationPut, ETag string) error {
+	if !r.HasExtension("network_address_reservations") {
+		return fmt.Errorf("The server is missing the required \"network_address_reservations\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("PUT", fmt.Sprintf("/networks/%s/reservations/%s", url.PathEscape(networkName), url.PathEscape(name)), reservation, ETag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteNetworkReservation releases the address reservation with the given name
+func (r *ProtocolLXD) DeleteNetworkReservation(networkName string, name string) error {
+	if !r.HasExtension("network_address_reservations") {
+		return fmt.Errorf("The server is missing the required \"network_address_reservations\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("DELETE", fmt.Sprintf("/networks/%s/reservations/%s", url.PathEscape(networkName), url.PathEscape(name)), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
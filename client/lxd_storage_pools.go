@@ -133,3 +133,18 @@ func (r *ProtocolLXD) GetStoragePoolResources(name string) (*api.ResourcesStorag
 
 	return &res, nil
 }
+
+// TrimStoragePool discards the unused blocks of a storage pool
+func (r *ProtocolLXD) TrimStoragePool(name string) (Operation, error) {
+	if !r.HasExtension("storage_pool_trim") {
+		return nil, fmt.Errorf("The server is missing the required \"storage_pool_trim\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", fmt.Sprintf("/storage-pools/%s/trim", url.PathEscape(name)), nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
@@ -99,6 +99,7 @@ type cmdAction struct {
 
 	flagAll       bool
 	flagForce     bool
+	flagSignal    string
 	flagStateful  bool
 	flagStateless bool
 	flagTimeout   int
@@ -112,6 +113,7 @@ func (c *cmdAction) Command(action string) *cobra.Command {
 
 	if action == "stop" {
 		cmd.Flags().BoolVar(&c.flagStateful, "stateful", false, i18n.G("Store the instance state"))
+		cmd.Flags().StringVar(&c.flagSignal, "signal", "", i18n.G("Signal to send to the container's init process, --force then kills it after the timeout")+"``")
 	} else if action == "start" {
 		cmd.Flags().BoolVar(&c.flagStateless, "stateless", false, i18n.G("Ignore the instance state"))
 	}
@@ -173,6 +175,7 @@ func (c *cmdAction) doAction(action string, conf *config.Config, nameArg string)
 		Timeout:  c.flagTimeout,
 		Force:    c.flagForce,
 		Stateful: state,
+		Signal:   c.flagSignal,
 	}
 
 	op, err := d.UpdateInstanceState(name, req, "")
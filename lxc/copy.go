@@ -30,6 +30,7 @@ type cmdCopy struct {
 	flagTarget        string
 	flagTargetProject string
 	flagRefresh       bool
+	flagMaxDowntime   int64
 }
 
 func (c *cmdCopy) Command() *cobra.Command {
@@ -53,6 +54,7 @@ func (c *cmdCopy) Command() *cobra.Command {
 	cmd.Flags().StringVar(&c.flagTargetProject, "target-project", "", i18n.G("Copy to a project different from the source")+"``")
 	cmd.Flags().BoolVar(&c.flagNoProfiles, "no-profiles", false, i18n.G("Create the instance with no profiles applied"))
 	cmd.Flags().BoolVar(&c.flagRefresh, "refresh", false, i18n.G("Perform an incremental copy"))
+	cmd.Flags().Int64Var(&c.flagMaxDowntime, "max-downtime", 0, i18n.G("Maximum downtime of a live migration in milliseconds")+"``")
 
 	return cmd
 }
@@ -162,6 +164,10 @@ func (c *cmdCopy) copyInstance(conf *config.Config, sourceResource string, destR
 			return fmt.Errorf(i18n.G("--refresh can only be used with instances"))
 		}
 
+		if c.flagMaxDowntime != 0 {
+			return fmt.Errorf(i18n.G("--max-downtime can only be used with instances"))
+		}
+
 		// Copy of a snapshot into a new instance
 		srcFields := strings.SplitN(sourceName, shared.SnapshotDelimiter, 2)
 		entry, _, err := source.GetInstanceSnapshot(srcFields[0], srcFields[1])
@@ -254,6 +260,7 @@ func (c *cmdCopy) copyInstance(conf *config.Config, sourceResource string, destR
 			InstanceOnly: instanceOnly,
 			Mode:         mode,
 			Refresh:      c.flagRefresh,
+			MaxDowntime:  c.flagMaxDowntime,
 		}
 
 		// Copy of an instance into a new instance
@@ -467,6 +474,10 @@ func (c *cmdCopy) Run(cmd *cobra.Command, args []string) error {
 	}
 
 	stateful := !c.flagStateless && !c.flagRefresh
+	if c.flagMaxDowntime != 0 && !stateful {
+		return fmt.Errorf(i18n.G("--max-downtime can't be used with --stateless or --refresh"))
+	}
+
 	keepVolatile := c.flagRefresh
 	instanceOnly := c.flagInstanceOnly
 
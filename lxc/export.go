@@ -23,6 +23,7 @@ type cmdExport struct {
 	flagInstanceOnly         bool
 	flagOptimizedStorage     bool
 	flagCompressionAlgorithm string
+	flagStream               bool
 }
 
 func (c *cmdExport) Command() *cobra.Command {
@@ -41,6 +42,7 @@ func (c *cmdExport) Command() *cobra.Command {
 	cmd.Flags().BoolVar(&c.flagOptimizedStorage, "optimized-storage", false,
 		i18n.G("Use storage driver optimized format (can only be restored on a similar pool)"))
 	cmd.Flags().StringVar(&c.flagCompressionAlgorithm, "compression", "", i18n.G("Define a compression algorithm: for backup or none")+"``")
+	cmd.Flags().BoolVar(&c.flagStream, "stream", false, i18n.G("Stream the tarball without storing it on the server first"))
 
 	return cmd
 }
@@ -67,6 +69,10 @@ func (c *cmdExport) Run(cmd *cobra.Command, args []string) error {
 
 	instanceOnly := c.flagInstanceOnly
 
+	if c.flagStream {
+		return c.stream(d, name, args)
+	}
+
 	req := api.InstanceBackupsPost{
 		Name:                 "",
 		ExpiresAt:            time.Now().Add(24 * time.Hour),
@@ -99,13 +105,7 @@ func (c *cmdExport) Run(cmd *cobra.Command, args []string) error {
 		}
 	}()
 
-	var targetName string
-	if len(args) > 1 {
-		targetName = args[1]
-	} else {
-		targetName = "backup.tar.gz"
-	}
-
+	targetName := c.targetName(args)
 	target, err := os.Create(shared.HostPath(targetName))
 	if err != nil {
 		return err
@@ -133,3 +133,44 @@ func (c *cmdExport) Run(cmd *cobra.Command, args []string) error {
 	progress.Done(i18n.G("Backup exported successfully!"))
 	return nil
 }
+
+// Stream the backup tarball straight from the server.
+func (c *cmdExport) stream(d lxd.InstanceServer, name string, args []string) error {
+	targetName := c.targetName(args)
+	target, err := os.Create(shared.HostPath(targetName))
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	progress := utils.ProgressRenderer{
+		Format: i18n.G("Exporting the backup: %s"),
+		Quiet:  c.global.flagQuiet,
+	}
+
+	req := lxd.InstanceExportRequest{
+		BackupFile:           target,
+		InstanceOnly:         c.flagInstanceOnly,
+		OptimizedStorage:     c.flagOptimizedStorage,
+		CompressionAlgorithm: c.flagCompressionAlgorithm,
+		ProgressHandler:      progress.UpdateProgress,
+	}
+
+	_, err = d.ExportInstance(name, &req)
+	if err != nil {
+		os.Remove(targetName)
+		progress.Done("")
+		return errors.Wrap(err, "Stream instance backup")
+	}
+
+	progress.Done(i18n.G("Backup exported successfully!"))
+	return nil
+}
+
+func (c *cmdExport) targetName(args []string) string {
+	if len(args) > 1 {
+		return args[1]
+	}
+
+	return "backup.tar.gz"
+}
@@ -546,6 +546,14 @@ func (c *cmdInfo) instanceInfo(d lxd.InstanceServer, remote config.Remote, name
 				networkInfo += fmt.Sprintf("      %s: %s\n", i18n.G("Bytes sent"), units.GetByteSizeString(net.Counters.BytesSent, 2))
 				networkInfo += fmt.Sprintf("      %s: %d\n", i18n.G("Packets received"), net.Counters.PacketsReceived)
 				networkInfo += fmt.Sprintf("      %s: %d\n", i18n.G("Packets sent"), net.Counters.PacketsSent)
+
+				if net.LinkState != "" {
+					networkInfo += fmt.Sprintf("      %s: %s\n", i18n.G("Link state"), net.LinkState)
+				}
+
+				if net.Speed > 0 {
+					networkInfo += fmt.Sprintf("      %s: %d Mbit/s\n", i18n.G("Link speed"), net.Speed)
+				}
 			}
 		}
 
@@ -26,6 +26,7 @@ type cmdMove struct {
 	flagStorage       string
 	flagTarget        string
 	flagTargetProject string
+	flagMaxDowntime   int64
 }
 
 func (c *cmdMove) Command() *cobra.Command {
@@ -56,6 +57,7 @@ lxc move <instance>/<old snapshot name> <instance>/<new snapshot name>
 	cmd.Flags().StringVarP(&c.flagStorage, "storage", "s", "", i18n.G("Storage pool name")+"``")
 	cmd.Flags().StringVar(&c.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
 	cmd.Flags().StringVar(&c.flagTargetProject, "target-project", "", i18n.G("Copy to a project different from the source")+"``")
+	cmd.Flags().Int64Var(&c.flagMaxDowntime, "max-downtime", 0, i18n.G("Maximum downtime of a live migration in milliseconds")+"``")
 
 	return cmd
 }
@@ -102,7 +104,15 @@ func (c *cmdMove) Run(cmd *cobra.Command, args []string) error {
 	// running, instances that are running should be live migrated (of
 	// course, this changing of hostname isn't supported right now, so this
 	// simply won't work).
+	if c.flagMaxDowntime != 0 && c.flagStateless {
+		return fmt.Errorf(i18n.G("The --max-downtime flag can't be used with --stateless"))
+	}
+
 	if sourceRemote == destRemote && c.flagTarget == "" && c.flagStorage == "" && c.flagTargetProject == "" {
+		if c.flagMaxDowntime != 0 {
+			return fmt.Errorf(i18n.G("The --max-downtime flag can't be used for a local rename"))
+		}
+
 		if c.flagConfig != nil || c.flagDevice != nil || c.flagProfile != nil || c.flagNoProfiles {
 			return fmt.Errorf(i18n.G("Can't override configuration or profiles in local rename"))
 		}
@@ -163,6 +173,10 @@ func (c *cmdMove) Run(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf(i18n.G("The --mode flag can't be used with --target"))
 		}
 
+		if c.flagMaxDowntime != 0 {
+			return fmt.Errorf(i18n.G("The --max-downtime flag can't be used with --target"))
+		}
+
 		return moveClusterInstance(conf, sourceResource, destResource, c.flagTarget)
 	}
 
@@ -174,6 +188,7 @@ func (c *cmdMove) Run(cmd *cobra.Command, args []string) error {
 	cpy.flagDevice = c.flagDevice
 	cpy.flagProfile = c.flagProfile
 	cpy.flagNoProfiles = c.flagNoProfiles
+	cpy.flagMaxDowntime = c.flagMaxDowntime
 
 	stateful := !c.flagStateless
 	instanceOnly := c.flagInstanceOnly
@@ -44,6 +44,9 @@ func restServer(d *Daemon) *http.Server {
 		}
 	}
 
+	d.createCmd(mux, "1.0", openapiCmd)
+	d.createCmd(mux, "ui", dashboardCmd)
+
 	for _, c := range apiInternal {
 		d.createCmd(mux, "internal", c)
 	}
@@ -66,7 +69,7 @@ func (s *lxdHttpServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	// Set CORS headers, unless this is an internal or gRPC request.
 	if !strings.HasPrefix(req.URL.Path, "/internal") && !strings.HasPrefix(req.URL.Path, "/protocol.SQL") {
 		<-s.d.setupChan
-		err := s.d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		err := s.d.cluster.TransactionContext(req.Context(), func(tx *db.ClusterTx) error {
 			config, err := cluster.ConfigLoad(tx)
 			if err != nil {
 				return err
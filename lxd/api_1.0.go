@@ -15,6 +15,7 @@ import (
 	"github.com/lxc/lxd/lxd/node"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/tools"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -36,21 +37,41 @@ var api10 = []APIEndpoint{
 	certificateCmd,
 	certificatesCmd,
 	clusterCmd,
+	clusterGroupCmd,
+	clusterGroupsCmd,
 	clusterNodeCmd,
 	clusterNodesCmd,
+	debugMetricsCmd,
+	debugPprofCmd,
 	instanceBackupCmd,
 	instanceBackupExportCmd,
 	instanceBackupsCmd,
+	instanceClonesCmd,
+	instanceWarmPoolClaimCmd,
 	instanceCmd,
 	instanceConsoleCmd,
+	instanceConsoleScreenshotCmd,
 	instanceExecCmd,
+	instanceExportCmd,
 	instanceFileCmd,
+	instanceGroupCmd,
+	instanceGroupsCmd,
+	instanceHistoryCmd,
 	instanceLogCmd,
 	instanceLogsCmd,
 	instanceMetadataCmd,
 	instanceMetadataTemplatesCmd,
+	instancePromoteCmd,
+	instanceRebuildCmd,
+	instanceReplicationCmd,
+	instanceReplicationsCmd,
+	instanceScheduleCmd,
+	instanceSchedulesCmd,
 	instancesCmd,
 	instanceSnapshotCmd,
+	instanceSnapshotDiffCmd,
+	instanceSnapshotFilesCmd,
+	instanceSnapshotMountCmd,
 	instanceSnapshotsCmd,
 	instanceStateCmd,
 	eventsCmd,
@@ -63,6 +84,10 @@ var api10 = []APIEndpoint{
 	imageSecretCmd,
 	networkCmd,
 	networkLeasesCmd,
+	networkPeerCmd,
+	networkPeersCmd,
+	networkReservationCmd,
+	networkReservationsCmd,
 	networksCmd,
 	networkStateCmd,
 	operationCmd,
@@ -75,6 +100,7 @@ var api10 = []APIEndpoint{
 	projectsCmd,
 	storagePoolCmd,
 	storagePoolResourcesCmd,
+	storagePoolTrimCmd,
 	storagePoolsCmd,
 	storagePoolVolumesCmd,
 	storagePoolVolumeSnapshotsTypeCmd,
@@ -84,6 +110,8 @@ var api10 = []APIEndpoint{
 	storagePoolVolumeTypeCustomCmd,
 	storagePoolVolumeTypeImageCmd,
 	storagePoolVolumeTypeVMCmd,
+	warningCmd,
+	warningsCmd,
 }
 
 func api10Get(d *Daemon, r *http.Request) response.Response {
@@ -230,6 +258,8 @@ func api10Get(d *Daemon, r *http.Request) response.Response {
 		}
 	}
 
+	env.Tools = tools.Versions()
+
 	drivers := readStoragePoolDriversCache()
 	for driver, version := range drivers {
 		if env.Storage != "" {
@@ -481,6 +511,7 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 	maasChanged := false
 	candidChanged := false
 	rbacChanged := false
+	bgpChanged := false
 
 	for key := range clusterChanged {
 		switch key {
@@ -490,6 +521,16 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 			fallthrough
 		case "core.proxy_ignore_hosts":
 			daemonConfigSetProxy(d, clusterConfig)
+		case "core.bgp_asn":
+			bgpChanged = true
+		case "core.events_retention":
+			d.events.SetRetention(clusterConfig.EventsRetention())
+		case "core.trace_endpoint":
+			d.setupTracing(clusterConfig.TraceEndpoint())
+		case "authorization.webhook.url":
+			fallthrough
+		case "authorization.webhook.timeout":
+			d.setupAuthzWebhook(clusterConfig.AuthorizationWebhook())
 		case "maas.api.url":
 			fallthrough
 		case "maas.api.key":
@@ -537,6 +578,16 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 		maasChanged = true
 	}
 
+	_, ok = nodeChanged["core.bgp_address"]
+	if ok {
+		bgpChanged = true
+	}
+
+	_, ok = nodeChanged["core.bgp_routerid"]
+	if ok {
+		bgpChanged = true
+	}
+
 	value, ok := nodeChanged["core.https_address"]
 	if ok {
 		err := d.endpoints.NetworkUpdateAddress(value)
@@ -561,6 +612,14 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 		}
 	}
 
+	_, ok = nodeChanged["core.core_dumps"]
+	if ok {
+		err := coreDumpsSetup(d.os.ExecPath, nodeConfig.CoreDumps())
+		if err != nil {
+			return err
+		}
+	}
+
 	value, ok = nodeChanged["storage.backups_volume"]
 	if ok {
 		err := daemonStorageMove(s, "backups", value)
@@ -586,6 +645,13 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 		}
 	}
 
+	if bgpChanged {
+		err := d.setupBGP(nodeConfig.BGPAddress(), clusterConfig.BGPASN(), nodeConfig.BGPRouterID())
+		if err != nil {
+			return err
+		}
+	}
+
 	if candidChanged {
 		apiURL, apiKey, expiry, domains := clusterConfig.CandidServer()
 		err := d.setupExternalAuthentication(apiURL, apiKey, expiry, domains)
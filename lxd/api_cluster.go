@@ -917,8 +917,21 @@ func clusterNodePut(d *Daemon, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
-	// Validate the request is fine
-	err = util.EtagCheck(r, current.Roles)
+	// Validate the request is fine, the ETag being computed from the
+	// roles reported by clusterNodeGet.
+	members, err := cluster.List(d.State())
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	currentRoles := current.Roles
+	for _, member := range members {
+		if member.ServerName == name {
+			currentRoles = member.Roles
+		}
+	}
+
+	err = util.EtagCheck(r, currentRoles)
 	if err != nil {
 		return response.PreconditionFailed(err)
 	}
@@ -932,19 +945,33 @@ func clusterNodePut(d *Daemon, r *http.Request) response.Response {
 	}
 
 	// Validate the request
-	if shared.StringInSlice(string(db.ClusterRoleDatabase), current.Roles) && !shared.StringInSlice(string(db.ClusterRoleDatabase), req.Roles) {
-		return response.BadRequest(fmt.Errorf("The '%s' role cannot be dropped at this time", db.ClusterRoleDatabase))
+	for _, role := range req.Roles {
+		switch db.ClusterRole(role) {
+		case db.ClusterRoleDatabase, db.ClusterRoleDatabaseStandBy, db.ClusterRoleEventHub:
+		default:
+			return response.BadRequest(fmt.Errorf("Invalid cluster role '%s'", role))
+		}
 	}
 
-	if !shared.StringInSlice(string(db.ClusterRoleDatabase), current.Roles) && shared.StringInSlice(string(db.ClusterRoleDatabase), req.Roles) {
-		return response.BadRequest(fmt.Errorf("The '%s' role cannot be added at this time", db.ClusterRoleDatabase))
+	wasDatabase := shared.StringInSlice(string(db.ClusterRoleDatabase), current.Roles)
+	isDatabase := shared.StringInSlice(string(db.ClusterRoleDatabase), req.Roles)
+	wasStandBy := shared.StringInSlice(string(db.ClusterRoleDatabaseStandBy), currentRoles)
+	isStandBy := shared.StringInSlice(string(db.ClusterRoleDatabaseStandBy), req.Roles)
+
+	if isDatabase && isStandBy {
+		return response.BadRequest(fmt.Errorf("The '%s' and '%s' roles are exclusive", db.ClusterRoleDatabase, db.ClusterRoleDatabaseStandBy))
 	}
 
-	// Update the database
+	// Update the database, the database role itself being changed by the
+	// raft leader.
 	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
 		dbRoles := []db.ClusterRole{}
-		for _, role := range req.Roles {
-			dbRoles = append(dbRoles, db.ClusterRole(role))
+		if wasDatabase {
+			dbRoles = append(dbRoles, db.ClusterRoleDatabase)
+		}
+
+		if shared.StringInSlice(string(db.ClusterRoleEventHub), req.Roles) {
+			dbRoles = append(dbRoles, db.ClusterRoleEventHub)
 		}
 
 		err := tx.NodeUpdateRoles(current.ID, dbRoles)
@@ -958,6 +985,51 @@ func clusterNodePut(d *Daemon, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
+	if wasDatabase == isDatabase && (isDatabase || wasStandBy == isStandBy) {
+		return response.EmptySyncResponse
+	}
+
+	// Promote or demote the member, or change its stand-by role.
+	post := &internalClusterPostHandoverRequest{}
+	if wasDatabase == isDatabase {
+		post.Target = current.Address
+		post.StandBy = &isStandBy
+	} else if isDatabase {
+		post.Target = current.Address
+	} else {
+		post.Address = current.Address
+	}
+
+	leader, err := d.gateway.LeaderAddress()
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	client, err := cluster.Connect(leader, d.endpoints.NetworkCert(), true)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	_, _, err = client.RawQuery("POST", "/internal/cluster/handover", post, "")
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// A handover is a no-op if no other member can take over the role.
+	if !isDatabase {
+		err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			current, err = tx.NodeByName(name)
+			return err
+		})
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		if shared.StringInSlice(string(db.ClusterRoleDatabase), current.Roles) {
+			return response.BadRequest(fmt.Errorf("No other member can take over the '%s' role", db.ClusterRoleDatabase))
+		}
+	}
+
 	return response.EmptySyncResponse
 }
 
@@ -1359,7 +1431,7 @@ func internalClusterPostHandover(d *Daemon, r *http.Request) response.Response {
 	}
 
 	// Sanity checks
-	if req.Address == "" {
+	if req.Address == "" && req.Target == "" {
 		return response.BadRequest(fmt.Errorf("No id provided"))
 	}
 
@@ -1383,7 +1455,51 @@ func internalClusterPostHandover(d *Daemon, r *http.Request) response.Response {
 		return response.SyncResponseRedirect(url.String())
 	}
 
-	target, nodes, err := cluster.Handover(d.State(), d.gateway, req.Address)
+	// Change the stand-by role of the target.
+	if req.Address == "" && req.StandBy != nil {
+		nodes, err := cluster.SetStandBy(d.State(), d.gateway, req.Target, *req.StandBy)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		err = changeMemberRole(d, req.Target, nodes)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		return response.SyncResponse(true, nil)
+	}
+
+	// Promote the target, demoting another member if there are already
+	// enough voters.
+	if req.Address == "" {
+		demoted, nodes, err := cluster.Promote(d.State(), d.gateway, req.Target)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		err = changeMemberRole(d, req.Target, nodes)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		if demoted != "" {
+			for i, node := range nodes {
+				if node.Address == demoted {
+					nodes[i].Role = db.RaftSpare
+				}
+			}
+
+			err = changeMemberRole(d, demoted, nodes)
+			if err != nil {
+				return response.SmartError(err)
+			}
+		}
+
+		return response.SyncResponse(true, nil)
+	}
+
+	target, nodes, err := cluster.Handover(d.State(), d.gateway, req.Address, req.Target)
 	if err != nil {
 		return response.SmartError(err)
 	}
@@ -1418,6 +1534,14 @@ out:
 type internalClusterPostHandoverRequest struct {
 	// Address of the server whose role should be transferred.
 	Address string `json:"address" yaml:"address"`
+
+	// Address of the server which should get the role, if any. If
+	// Address is empty, the target is promoted.
+	Target string `json:"target" yaml:"target"`
+
+	// Whether the target should become a stand-by or a spare database
+	// member, rather than being promoted. Only used if Address is empty.
+	StandBy *bool `json:"standby,omitempty" yaml:"standby,omitempty"`
 }
 
 func clusterCheckStoragePoolsMatch(cluster *db.Cluster, reqPools []api.StoragePool) error {
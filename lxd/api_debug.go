@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	runtimePprof "runtime/pprof"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var debugMetricsCmd = APIEndpoint{
+	Path: "debug/metrics",
+
+	Get: APIEndpointAction{Handler: debugMetricsGet},
+}
+
+var debugPprofCmd = APIEndpoint{
+	Path: "debug/pprof/{name}",
+
+	Get: APIEndpointAction{Handler: debugPprofGet},
+}
+
+func debugMetricsGet(d *Daemon, r *http.Request) response.Response {
+	metrics := api.DebugMetrics{
+		Goroutines: runtime.NumGoroutine(),
+		Operations: len(operations.Operations()),
+	}
+
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	metrics.FileDescriptors = len(fds)
+
+	var limit unix.Rlimit
+	err = unix.Getrlimit(unix.RLIMIT_NOFILE, &limit)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	metrics.FileDescriptorsLimit = limit.Cur
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	metrics.Memory = api.DebugMetricsMemory{
+		HeapAllocated: m.HeapAlloc,
+		HeapIdle:      m.HeapIdle,
+		HeapReleased:  m.HeapReleased,
+		StackInUse:    m.StackInuse,
+		System:        m.Sys,
+	}
+
+	metrics.GarbageCollector = api.DebugMetricsGarbageCollector{
+		Runs:       m.NumGC,
+		TotalPause: time.Duration(m.PauseTotalNs),
+		NextTarget: m.NextGC,
+	}
+
+	if m.NumGC > 0 {
+		metrics.GarbageCollector.LastRun = time.Unix(0, int64(m.LastGC)).UTC()
+		metrics.GarbageCollector.LastPause = time.Duration(m.PauseNs[(m.NumGC+255)%256])
+	}
+
+	return response.SyncResponse(true, metrics)
+}
+
+// Serve the pprof profiles of the daemon, so that they can be fetched with "go tool pprof" using
+// a trusted client certificate.
+func debugPprofGet(d *Daemon, r *http.Request) response.Response {
+	name := mux.Vars(r)["name"]
+
+	var handler http.HandlerFunc
+	switch name {
+	case "cmdline":
+		handler = pprof.Cmdline
+	case "profile":
+		handler = pprof.Profile
+	case "symbol":
+		handler = pprof.Symbol
+	case "trace":
+		handler = pprof.Trace
+	default:
+		if runtimePprof.Lookup(name) == nil {
+			return response.NotFound(fmt.Errorf("Unknown profile %q", name))
+		}
+
+		handler = pprof.Handler(name).ServeHTTP
+	}
+
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		// Let the handler pick its own content type.
+		w.Header().Del("Content-Type")
+		handler(w, r)
+		return nil
+	})
+}
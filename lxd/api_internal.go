@@ -16,6 +16,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/lxc/lxd/lxd/backup"
+	"github.com/lxc/lxd/lxd/crashlog"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/db/cluster"
 	"github.com/lxc/lxd/lxd/db/node"
@@ -50,6 +51,11 @@ var apiInternal = []APIEndpoint{
 	internalRAFTSnapshotCmd,
 	internalClusterHandoverCmd,
 	internalClusterRaftNodeCmd,
+	internalCrashesCmd,
+	internalDatabaseBackupCmd,
+	internalDatabaseRestoreCmd,
+	internalOrphansCmd,
+	internalNetworkPeersCmd,
 }
 
 var internalShutdownCmd = APIEndpoint{
@@ -101,6 +107,19 @@ var internalGarbageCollectorCmd = APIEndpoint{
 	Get: APIEndpointAction{Handler: internalGC},
 }
 
+var internalOrphansCmd = APIEndpoint{
+	Path: "orphans",
+
+	Get:    APIEndpointAction{Handler: internalOrphansGet},
+	Delete: APIEndpointAction{Handler: internalOrphansDelete},
+}
+
+var internalCrashesCmd = APIEndpoint{
+	Path: "crashes",
+
+	Get: APIEndpointAction{Handler: internalCrashes},
+}
+
 var internalRAFTSnapshotCmd = APIEndpoint{
 	Path: "raft-snapshot",
 
@@ -780,6 +799,31 @@ func internalGC(d *Daemon, r *http.Request) response.Response {
 	return response.EmptySyncResponse
 }
 
+// internalOrphansGet lists the orphaned artifacts found on this node, without removing them.
+func internalOrphansGet(d *Daemon, r *http.Request) response.Response {
+	orphans, err := orphansPrune(d.State(), true)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, orphans)
+}
+
+// internalOrphansDelete removes the orphaned artifacts found on this node and returns them.
+func internalOrphansDelete(d *Daemon, r *http.Request) response.Response {
+	orphans, err := orphansPrune(d.State(), false)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, orphans)
+}
+
+// internalCrashes returns the panics recovered from API handlers and operations since the daemon started.
+func internalCrashes(d *Daemon, r *http.Request) response.Response {
+	return response.SyncResponse(true, crashlog.Entries())
+}
+
 func internalRAFTSnapshot(d *Daemon, r *http.Request) response.Response {
 	logger.Infof("Started forced RAFT snapshot")
 	err := d.gateway.Snapshot()
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db/cluster"
+	"github.com/lxc/lxd/lxd/db/node"
+	"github.com/lxc/lxd/lxd/db/query"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+var internalDatabaseBackupCmd = APIEndpoint{
+	Path: "database/backup",
+
+	Get: APIEndpointAction{Handler: internalDatabaseBackupGet},
+}
+
+var internalDatabaseRestoreCmd = APIEndpoint{
+	Path: "database/restore",
+
+	Post: APIEndpointAction{Handler: internalDatabaseRestorePost},
+}
+
+// Server certificate files that are included in database backups, if present.
+var internalDatabaseBackupCertificates = []string{
+	"server.crt",
+	"server.key",
+	"server.ca",
+	"cluster.crt",
+	"cluster.key",
+	"cluster.ca",
+}
+
+// A point-in-time backup of the local and global databases.
+type internalDatabaseBackup struct {
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+
+	GlobalSchema int    `json:"global_schema" yaml:"global_schema"`
+	Global       string `json:"global" yaml:"global"`
+
+	LocalSchema int    `json:"local_schema" yaml:"local_schema"`
+	Local       string `json:"local" yaml:"local"`
+
+	// Content of the server certificate files, keyed by file name.
+	Certificates map[string]string `json:"certificates" yaml:"certificates"`
+}
+
+// Produce a consistent dump of each database, along with the server
+// certificates.
+func internalDatabaseBackupGet(d *Daemon, r *http.Request) response.Response {
+	backup := internalDatabaseBackup{
+		CreatedAt:    time.Now().UTC(),
+		Certificates: map[string]string{},
+	}
+
+	var err error
+	backup.GlobalSchema, backup.Global, err = internalDatabaseDump(d.cluster.DB(), cluster.FreshSchema())
+	if err != nil {
+		return response.SmartError(errors.Wrap(err, "Failed to dump global database"))
+	}
+
+	backup.LocalSchema, backup.Local, err = internalDatabaseDump(d.db.DB(), node.FreshSchema())
+	if err != nil {
+		return response.SmartError(errors.Wrap(err, "Failed to dump local database"))
+	}
+
+	for _, name := range internalDatabaseBackupCertificates {
+		path := filepath.Join(d.os.VarDir, name)
+		if !shared.PathExists(path) {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		backup.Certificates[name] = string(content)
+	}
+
+	return response.SyncResponse(true, backup)
+}
+
+// Restore a backup produced by internalDatabaseBackupGet. The backup is only
+// accepted if it was taken with the same schema versions as the running
+// databases. The daemon must be restarted for the restored state to be fully
+// taken into account.
+func internalDatabaseRestorePost(d *Daemon, r *http.Request) response.Response {
+	backup := internalDatabaseBackup{}
+	err := json.NewDecoder(r.Body).Decode(&backup)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if backup.Global == "" || backup.Local == "" {
+		return response.BadRequest(fmt.Errorf("Backup is missing database content"))
+	}
+
+	for name := range backup.Certificates {
+		if !shared.StringInSlice(name, internalDatabaseBackupCertificates) {
+			return response.BadRequest(fmt.Errorf("Invalid certificate file %q", name))
+		}
+	}
+
+	err = internalDatabaseRestore(d.cluster.DB(), cluster.FreshSchema(), backup.GlobalSchema, backup.Global)
+	if err != nil {
+		return response.SmartError(errors.Wrap(err, "Failed to restore global database"))
+	}
+
+	err = internalDatabaseRestore(d.db.DB(), node.FreshSchema(), backup.LocalSchema, backup.Local)
+	if err != nil {
+		return response.SmartError(errors.Wrap(err, "Failed to restore local database"))
+	}
+
+	for name, content := range backup.Certificates {
+		mode := os.FileMode(0644)
+		if filepath.Ext(name) == ".key" {
+			mode = 0600
+		}
+
+		err := ioutil.WriteFile(filepath.Join(d.os.VarDir, name), []byte(content), mode)
+		if err != nil {
+			return response.SmartError(err)
+		}
+	}
+
+	logger.Warnf("Database restored from backup taken at %s, LXD must be restarted", backup.CreatedAt)
+
+	return response.EmptySyncResponse
+}
+
+// Return the schema version and a SQL text dump of the given database, both
+// read within the same transaction.
+func internalDatabaseDump(db *sql.DB, schema string) (int, string, error) {
+	var version int
+	var dump string
+
+	err := query.Transaction(db, func(tx *sql.Tx) error {
+		var err error
+		version, err = internalDatabaseSchemaVersion(tx)
+		if err != nil {
+			return err
+		}
+
+		dump, err = query.Dump(tx, schema, false)
+		return err
+	})
+	if err != nil {
+		return -1, "", err
+	}
+
+	return version, dump, nil
+}
+
+// Replace the content of the given database with the one of the dump, after
+// checking that the dump matches the running schema version.
+func internalDatabaseRestore(db *sql.DB, schema string, version int, dump string) error {
+	return query.Transaction(db, func(tx *sql.Tx) error {
+		current, err := internalDatabaseSchemaVersion(tx)
+		if err != nil {
+			return err
+		}
+
+		if current != version {
+			return fmt.Errorf("Backup schema version %d doesn't match running schema version %d", version, current)
+		}
+
+		return query.Restore(tx, schema, dump)
+	})
+}
+
+func internalDatabaseSchemaVersion(tx *sql.Tx) (int, error) {
+	versions, err := query.SelectIntegers(tx, "SELECT MAX(version) FROM schema")
+	if err != nil {
+		return -1, errors.Wrap(err, "Failed to fetch schema version")
+	}
+
+	if len(versions) != 1 {
+		return -1, fmt.Errorf("Schema version not found")
+	}
+
+	return versions[0], nil
+}
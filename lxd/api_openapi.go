@@ -0,0 +1,263 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/openapi"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
+)
+
+// The API description is generated from the api10 endpoints, so it's registered on its own rather
+// than being part of them.
+var openapiCmd = APIEndpoint{
+	Path: "openapi",
+
+	Get: APIEndpointAction{Handler: openapiGet, AllowUntrusted: true},
+}
+
+// openapiType holds the Go types exchanged by an API call.
+type openapiType struct {
+	request  interface{} // Type of the request body, if any.
+	response interface{} // Type of the metadata of synchronous responses, if any.
+	async    bool        // Whether the call returns a background operation.
+}
+
+//go:generate lxd-generate openapi types -t api_openapi_types.go
+
+// openapiTypeOverrides lists the calls whose types can't be derived from their path, see
+// openapiCallTypes.
+var openapiTypeOverrides = map[string]openapiType{
+	"GET ":   {response: api.Server{}},
+	"PUT ":   {request: api.ServerPut{}},
+	"PATCH ": {request: api.ServerPut{}},
+
+	"GET instances/{name}/history":  {response: []api.InstanceHistoryEntry{}},
+	"GET instances/{name}/metadata": {response: api.ImageMetadata{}},
+	"PUT instances/{name}/metadata": {request: api.ImageMetadata{}},
+
+	"GET networks/{name}/leases": {response: []api.NetworkLease{}},
+
+	"GET operations":           {response: map[string][]string{}},
+	"GET operations/{id}/wait": {response: api.Operation{}},
+
+	"GET storage-pools/{name}/resources": {response: api.ResourcesStoragePool{}},
+}
+
+// openapiAsyncCalls lists the calls returning a background operation.
+var openapiAsyncCalls = []string{
+	"PUT cluster",
+	"POST instances",
+	"PUT instances",
+	"PUT instances/{name}",
+	"POST instances/{name}",
+	"DELETE instances/{name}",
+	"PUT instances/{name}/state",
+	"POST instances/{name}/clones",
+	"POST instances/{name}/exec",
+	"POST instances/{name}/console",
+	"POST instances/{name}/rebuild",
+	"POST instances/{name}/backups",
+	"POST instances/{name}/backups/{backupName}",
+	"DELETE instances/{name}/backups/{backupName}",
+	"POST instances/{name}/snapshots",
+	"PUT instances/{name}/snapshots/{snapshotName}",
+	"POST instances/{name}/snapshots/{snapshotName}",
+	"DELETE instances/{name}/snapshots/{snapshotName}",
+	"GET instances/{name}/snapshots/{snapshotName}/diff",
+	"POST instances/{name}/snapshots/{snapshotName}/files",
+	"POST instances/{name}/snapshots/{snapshotName}/mount",
+	"DELETE instances/{name}/snapshots/{snapshotName}/mount",
+	"POST images",
+	"DELETE images/{fingerprint}",
+	"POST images/{fingerprint}/secret",
+	"POST images/{fingerprint}/refresh",
+	"POST projects/{name}",
+	"POST storage-pools/{name}/trim",
+	"POST storage-pools/{name}/volumes",
+	"POST storage-pools/{name}/volumes/{type}",
+	"POST storage-pools/{pool}/volumes/container/{name:.*}",
+	"POST storage-pools/{pool}/volumes/virtual-machine/{name:.*}",
+	"POST storage-pools/{pool}/volumes/custom/{name}",
+	"POST storage-pools/{pool}/volumes/image/{name}",
+	"POST storage-pools/{pool}/volumes/{type}/{name}/snapshots",
+	"PUT storage-pools/{pool}/volumes/{type}/{name}/snapshots/{snapshotName}",
+	"POST storage-pools/{pool}/volumes/{type}/{name}/snapshots/{snapshotName}",
+	"DELETE storage-pools/{pool}/volumes/{type}/{name}/snapshots/{snapshotName}",
+}
+
+// openapiPathAliases rewrites the paths whose api structs are named after another path.
+var openapiPathAliases = []struct {
+	regexp *regexp.Regexp
+	path   string
+}{
+	{regexp.MustCompile(`^storage-pools/{[^}]+}/volumes(/custom|/{type})?`), "storage-volumes"},
+	{regexp.MustCompile(`^images/aliases/`), "image-aliases-entries/"},
+}
+
+// openapiCallTypes returns the types exchanged by a call, derived from the names of the api
+// structs: the path "instances/{name}/snapshots/{snapshotName}" gets "InstanceSnapshot" for GET
+// and "InstanceSnapshotPut" and "InstanceSnapshotPost" as requests, while "instances" lists
+// names and gets "InstancesPost" as request. Calls using other types are listed in
+// openapiTypeOverrides, and the ones returning background operations in openapiAsyncCalls.
+func openapiCallTypes(method string, path string, collection bool, apiTypes map[string]interface{}) openapiType {
+	key := method + " " + path
+	types, ok := openapiTypeOverrides[key]
+	if !ok {
+		for _, alias := range openapiPathAliases {
+			path = alias.regexp.ReplaceAllString(path, alias.path)
+		}
+
+		name := ""
+		item := strings.HasSuffix(path, "}")
+		segments := strings.Split(path, "/")
+		for i, segment := range segments {
+			if strings.HasPrefix(segment, "{") {
+				continue
+			}
+
+			word := ""
+			for _, part := range strings.Split(segment, "-") {
+				word += strings.Title(part)
+			}
+
+			// Only a trailing collection keeps its plural.
+			if item || i < len(segments)-1 {
+				if strings.HasSuffix(word, "ies") {
+					word = strings.TrimSuffix(word, "ies") + "y"
+				} else {
+					word = strings.TrimSuffix(word, "s")
+				}
+			}
+
+			name += word
+		}
+
+		switch method {
+		case "GET":
+			types.response = apiTypes[name]
+			if types.response == nil && collection {
+				types.response = []string{}
+			}
+
+		case "PUT", "PATCH":
+			types.request = apiTypes[name+"Put"]
+		case "POST":
+			types.request = apiTypes[name+"Post"]
+		}
+	}
+
+	types.async = shared.StringInSlice(key, openapiAsyncCalls)
+
+	return types
+}
+
+func openapiGet(d *Daemon, r *http.Request) response.Response {
+	doc := openapiDocument(api10)
+
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		return util.WriteJSON(w, doc, false)
+	})
+}
+
+// openapiDocument returns the OpenAPI description of the given endpoints.
+func openapiDocument(endpoints []APIEndpoint) *openapi.Document {
+	g := openapi.NewGenerator("LXD", version.APIVersion)
+
+	// Paths having items below them are collections, whatever their placeholders are named.
+	placeholders := regexp.MustCompile(`{[^}]*}`)
+	collections := map[string]bool{}
+	for _, c := range endpoints {
+		i := strings.LastIndex(c.Path, "/{")
+		if i > 0 {
+			collections[placeholders.ReplaceAllString(c.Path[:i], "{}")] = true
+		}
+	}
+
+	usedIDs := map[string]bool{}
+	for _, c := range endpoints {
+		path := "/" + version.APIVersion
+		if c.Path != "" {
+			path += "/" + c.Path
+		}
+
+		actions := []struct {
+			method string
+			action APIEndpointAction
+		}{
+			{"GET", c.Get},
+			{"PUT", c.Put},
+			{"POST", c.Post},
+			{"DELETE", c.Delete},
+			{"PATCH", c.Patch},
+		}
+
+		for _, a := range actions {
+			if a.action.Handler == nil {
+				continue
+			}
+
+			// Name the operations after their handlers, which are shared by some methods.
+			id := runtime.FuncForPC(reflect.ValueOf(a.action.Handler).Pointer()).Name()
+			id = strings.TrimPrefix(id, "main.")
+			if usedIDs[id] {
+				id += strings.Title(strings.ToLower(a.method))
+			}
+
+			usedIDs[id] = true
+
+			types := openapiCallTypes(a.method, c.Path, collections[placeholders.ReplaceAllString(c.Path, "{}")], openapiAPITypes)
+			var resp *openapi.Response
+			if types.async {
+				resp = g.JSONResponse("Background operation", openapiEnvelope(g, "async", api.Operation{}))
+			} else {
+				resp = g.JSONResponse("Success", openapiEnvelope(g, "sync", types.response))
+			}
+
+			g.AddOperation(a.method, path, id, types.request, resp)
+		}
+	}
+
+	g.AddResponse("default", g.JSONResponse("Error", &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"type":       {Type: "string"},
+			"error":      {Type: "string"},
+			"error_code": {Type: "integer", Format: "int32"},
+		},
+	}))
+
+	return g.Document()
+}
+
+// openapiEnvelope returns the schema of a standard response of the given type, carrying metadata of
+// the type of the given value.
+func openapiEnvelope(g *openapi.Generator, responseType string, metadata interface{}) *openapi.Schema {
+	schema := &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"type":        {Type: "string"},
+			"status":      {Type: "string"},
+			"status_code": {Type: "integer", Format: "int32"},
+		},
+	}
+
+	if responseType == "async" {
+		schema.Properties["operation"] = &openapi.Schema{Type: "string"}
+	}
+
+	if metadata != nil {
+		schema.Properties["metadata"] = g.Schema(metadata)
+	} else {
+		schema.Properties["metadata"] = &openapi.Schema{}
+	}
+
+	return schema
+}
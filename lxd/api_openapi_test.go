@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The calls listed as returning background operations all exist.
+func TestOpenapiAsyncCalls(t *testing.T) {
+	calls := map[string]bool{}
+	for _, c := range api10 {
+		actions := map[string]APIEndpointAction{
+			"GET":    c.Get,
+			"PUT":    c.Put,
+			"POST":   c.Post,
+			"DELETE": c.Delete,
+			"PATCH":  c.Patch,
+		}
+
+		for method, action := range actions {
+			if action.Handler != nil {
+				calls[method+" "+c.Path] = true
+			}
+		}
+	}
+
+	for _, call := range openapiAsyncCalls {
+		assert.True(t, calls[call], "Unknown call %q", call)
+	}
+}
+
+func TestOpenapiCallTypes_Async(t *testing.T) {
+	types := openapiCallTypes("POST", "storage-pools/{name}/trim", false, openapiAPITypes)
+	assert.True(t, types.async)
+
+	types = openapiCallTypes("GET", "storage-pools/{name}", false, openapiAPITypes)
+	assert.False(t, types.async)
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/units"
 	"github.com/lxc/lxd/shared/version"
 )
 
@@ -134,8 +135,18 @@ func projectsPost(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
+	// Check user permissions on the parent project
+	if !projectParentAllowed(d, r, project.Config["parent"]) {
+		return response.Forbidden(nil)
+	}
+
 	var id int64
 	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		err := projectValidateParent(tx, project.Name, project.Config["parent"])
+		if err != nil {
+			return err
+		}
+
 		id, err = tx.ProjectCreate(project)
 		if err != nil {
 			return errors.Wrap(err, "Add project to database")
@@ -155,6 +166,11 @@ func projectsPost(d *Daemon, r *http.Request) response.Response {
 			}
 		}
 
+		err = projecthelpers.ValidateDefaultProfiles(tx, project.Name, project.Config)
+		if err != nil {
+			return err
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -250,7 +266,7 @@ func projectPut(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
-	return projectChange(d, project, req)
+	return projectChange(d, r, project, req)
 }
 
 func projectPatch(d *Daemon, r *http.Request) response.Response {
@@ -318,11 +334,11 @@ func projectPatch(d *Daemon, r *http.Request) response.Response {
 		}
 	}
 
-	return projectChange(d, project, req)
+	return projectChange(d, r, project, req)
 }
 
 // Common logic between PUT and PATCH.
-func projectChange(d *Daemon, project *api.Project, req api.ProjectPut) response.Response {
+func projectChange(d *Daemon, r *http.Request, project *api.Project, req api.ProjectPut) response.Response {
 	// Make a list of config keys that have changed.
 	configChanged := []string{}
 	for key := range project.Config {
@@ -338,9 +354,10 @@ func projectChange(d *Daemon, project *api.Project, req api.ProjectPut) response
 		}
 	}
 
-	// Flag indicating if any feature has changed.
+	// Flag indicating if any feature has changed. Changing the parent
+	// changes where the disabled features are inherited from.
 	featuresChanged := false
-	for _, featureKey := range projectFeatures {
+	for _, featureKey := range append(projectFeatures, "parent") {
 		if shared.StringInSlice(featureKey, configChanged) {
 			featuresChanged = true
 			break
@@ -362,6 +379,11 @@ func projectChange(d *Daemon, project *api.Project, req api.ProjectPut) response
 		return response.BadRequest(err)
 	}
 
+	// Check user permissions on the new parent project.
+	if shared.StringInSlice("parent", configChanged) && !projectParentAllowed(d, r, req.Config["parent"]) {
+		return response.Forbidden(nil)
+	}
+
 	// Update the database entry.
 	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
 		err := projecthelpers.AllowProjectUpdate(tx, project.Name, req.Config, configChanged)
@@ -369,6 +391,13 @@ func projectChange(d *Daemon, project *api.Project, req api.ProjectPut) response
 			return err
 		}
 
+		if shared.StringInSlice("parent", configChanged) {
+			err = projectValidateParent(tx, project.Name, req.Config["parent"])
+			if err != nil {
+				return err
+			}
+		}
+
 		err = tx.ProjectUpdate(project.Name, req)
 		if err != nil {
 			return errors.Wrap(err, "Persist profile changes")
@@ -389,6 +418,13 @@ func projectChange(d *Daemon, project *api.Project, req api.ProjectPut) response
 			}
 		}
 
+		if shared.StringInSlice("defaults.profiles", configChanged) || shared.StringInSlice("features.profiles", configChanged) || shared.StringInSlice("parent", configChanged) {
+			err = projecthelpers.ValidateDefaultProfiles(tx, project.Name, req.Config)
+			if err != nil {
+				return err
+			}
+		}
+
 		return nil
 	})
 
@@ -437,6 +473,15 @@ func projectPost(d *Daemon, r *http.Request) response.Response {
 				return fmt.Errorf("Only empty projects can be renamed")
 			}
 
+			children, err := projectChildren(tx, name)
+			if err != nil {
+				return err
+			}
+
+			if len(children) > 0 {
+				return fmt.Errorf("Projects used as parent by other projects can't be renamed")
+			}
+
 			id, err = tx.ProjectID(name)
 			if err != nil {
 				return errors.Wrapf(err, "Fetch project id %q", name)
@@ -484,6 +529,15 @@ func projectDelete(d *Daemon, r *http.Request) response.Response {
 			return fmt.Errorf("Only empty projects can be removed")
 		}
 
+		children, err := projectChildren(tx, name)
+		if err != nil {
+			return err
+		}
+
+		if len(children) > 0 {
+			return fmt.Errorf("Projects used as parent by other projects can't be removed")
+		}
+
 		id, err = tx.ProjectID(name)
 		if err != nil {
 			return errors.Wrapf(err, "Fetch project id %q", name)
@@ -526,17 +580,37 @@ func isEitherAllowOrBlockOrManaged(value string) error {
 	return shared.IsOneOf(value, []string{"block", "allow", "managed"})
 }
 
+func isNetworkQuotaAction(value string) error {
+	return shared.IsOneOf(value, []string{"none", "throttle"})
+}
+
+func isNetworkQuotaThrottle(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	_, err := units.ParseBitSizeString(value)
+	return err
+}
+
 // Validate the project configuration
 var projectConfigKeys = map[string]func(value string) error{
+	"defaults.profiles":              shared.IsAny,
 	"features.profiles":              shared.IsBool,
 	"features.images":                shared.IsBool,
 	"features.storage.volumes":       shared.IsBool,
 	"limits.containers":              shared.IsUint32,
 	"limits.virtual-machines":        shared.IsUint32,
 	"limits.memory":                  shared.IsSize,
+	"limits.network.quota":           shared.IsSize,
+	"limits.network.quota.action":    isNetworkQuotaAction,
+	"limits.network.quota.throttle":  isNetworkQuotaThrottle,
 	"limits.processes":               shared.IsUint32,
 	"limits.cpu":                     shared.IsUint32,
+	"parent":                         shared.IsAny,
+	"profiles.conflicts":             isEitherAllowOrBlock,
 	"restricted":                     shared.IsBool,
+	"restricted.cluster.groups":      shared.IsClusterGroupList,
 	"restricted.containers.nesting":  isEitherAllowOrBlock,
 	"restricted.containers.lowlevel": isEitherAllowOrBlock,
 	"restricted.containers.privilege": func(value string) error {
@@ -549,8 +623,69 @@ var projectConfigKeys = map[string]func(value string) error{
 	"restricted.devices.infiniband":        isEitherAllowOrBlock,
 	"restricted.devices.gpu":               isEitherAllowOrBlock,
 	"restricted.devices.usb":               isEitherAllowOrBlock,
+	"restricted.devices.plugin":            isEitherAllowOrBlock,
 	"restricted.devices.nic":               isEitherAllowOrBlockOrManaged,
 	"restricted.devices.disk":              isEitherAllowOrBlockOrManaged,
+	"restricted.networks":                  shared.IsAny,
+	"restricted.pools":                     shared.IsAny,
+}
+
+// Check that the user is allowed to make a project inherit from the given parent
+// project, which exposes the images and profiles of the parent to the project.
+func projectParentAllowed(d *Daemon, r *http.Request, parent string) bool {
+	if parent == "" {
+		return true
+	}
+
+	return d.userHasPermission(r, parent, "manage-projects")
+}
+
+// Check that the given project can inherit from the given parent project.
+func projectValidateParent(tx *db.ClusterTx, name string, parent string) error {
+	if parent == "" {
+		return nil
+	}
+
+	if name == projecthelpers.Default {
+		return fmt.Errorf("The 'default' project can't have a parent")
+	}
+
+	// Walk up the chain of parents to detect cycles.
+	for current := parent; current != "" && current != projecthelpers.Default; {
+		if current == name {
+			return fmt.Errorf("Project %q can't be its own ancestor", name)
+		}
+
+		project, err := tx.ProjectGet(current)
+		if err != nil {
+			if err == db.ErrNoSuchObject {
+				return fmt.Errorf("Parent project %q doesn't exist", current)
+			}
+
+			return errors.Wrapf(err, "Fetch project %q", current)
+		}
+
+		current = project.Config["parent"]
+	}
+
+	return nil
+}
+
+// Return the names of the projects which have the given project as parent.
+func projectChildren(tx *db.ClusterTx, name string) ([]string, error) {
+	projects, err := tx.ProjectList(db.ProjectFilter{})
+	if err != nil {
+		return nil, errors.Wrap(err, "Fetch projects")
+	}
+
+	children := []string{}
+	for _, project := range projects {
+		if project.Config["parent"] == name {
+			children = append(children, project.Name)
+		}
+	}
+
+	return children, nil
 }
 
 func projectValidateConfig(config map[string]string) error {
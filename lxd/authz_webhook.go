@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Request sent to the authorization webhook for every API call.
+type authzWebhookRequest struct {
+	Username string `json:"username"`
+	Protocol string `json:"protocol"`
+	Address  string `json:"address"`
+	Method   string `json:"method"`
+	URL      string `json:"url"`
+	Project  string `json:"project"`
+}
+
+// Answer of the authorization webhook.
+type authzWebhookResponse struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason"`
+}
+
+// authzWebhook delegates the authorization of API calls to an external service.
+type authzWebhook struct {
+	url    string
+	client *http.Client
+}
+
+func newAuthzWebhook(endpoint string, timeout int64, proxy func(req *http.Request) (*url.URL, error)) *authzWebhook {
+	return &authzWebhook{
+		url: endpoint,
+		client: &http.Client{
+			Timeout:   time.Duration(timeout) * time.Second,
+			Transport: &http.Transport{Proxy: proxy},
+		},
+	}
+}
+
+// Check asks the webhook whether the request is allowed. Any failure to get an answer denies the
+// request.
+func (w *authzWebhook) Check(r *http.Request, username string, protocol string) (bool, string, error) {
+	req := authzWebhookRequest{
+		Username: username,
+		Protocol: protocol,
+		Address:  r.RemoteAddr,
+		Method:   r.Method,
+		URL:      r.URL.RequestURI(),
+		Project:  projectParam(r),
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return false, "", err
+	}
+
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return false, "", errors.Wrap(err, "Failed to query authorization webhook")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, "", fmt.Errorf("Authorization webhook returned status %d", resp.StatusCode)
+	}
+
+	answer := authzWebhookResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&answer)
+	if err != nil {
+		return false, "", errors.Wrap(err, "Invalid answer from authorization webhook")
+	}
+
+	return answer.Allow, answer.Reason, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthzWebhookCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := authzWebhookRequest{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		require.NoError(t, err)
+
+		resp := authzWebhookResponse{Allow: req.Username == "alice" && req.Project == "web"}
+		if !resp.Allow {
+			resp.Reason = "Not allowed"
+		}
+
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	webhook := newAuthzWebhook(server.URL, 5, nil)
+	r := httptest.NewRequest("GET", "/1.0/instances?project=web", nil)
+
+	allowed, _, err := webhook.Check(r, "alice", "candid")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, reason, err := webhook.Check(r, "bob", "candid")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, "Not allowed", reason)
+}
+
+func TestAuthzWebhookCheck_Failure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	webhook := newAuthzWebhook(server.URL, 5, nil)
+	r := httptest.NewRequest("GET", "/1.0", nil)
+
+	allowed, _, err := webhook.Check(r, "alice", "tls")
+	assert.Error(t, err)
+	assert.False(t, allowed)
+}
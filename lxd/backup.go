@@ -1,10 +1,14 @@
 package main
 
 import (
+	"archive/tar"
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"strings"
+	"syscall"
 	"time"
 
 	"context"
@@ -52,6 +56,19 @@ func backupCreate(s *state.State, args db.InstanceBackupArgs, sourceInst instanc
 		args.OptimizedStorage = false
 	}
 
+	// Load what an incremental backup needs to know about the backup it's based upon.
+	var parent *backupParent
+	if args.Parent != "" {
+		if args.OptimizedStorage {
+			return fmt.Errorf("Incremental backups can't use optimized storage")
+		}
+
+		parent, err = backupParentLoad(s, sourceInst, args.Parent)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Create the database entry.
 	err = s.Cluster.InstanceBackupCreate(args)
 	if err != nil {
@@ -104,6 +121,73 @@ func backupCreate(s *state.State, args db.InstanceBackupArgs, sourceInst instanc
 	defer tarFileWriter.Close()
 	revert.Add(func() { os.Remove(target) })
 
+	err = backupWriteTarball(sourceInst, pool, b.OptimizedStorage(), !b.InstanceOnly(), parent, compress, tarFileWriter)
+	if err != nil {
+		return err
+	}
+
+	revert.Success()
+	return nil
+}
+
+// backupParent holds what an incremental backup needs to know about the backup it's based upon.
+type backupParent struct {
+	name         string    // Full name of the parent backup
+	creationDate time.Time // Files not modified since then are left out of the incremental backup
+	snapshots    []string  // Snapshots already in the parent backup, left out of the incremental backup
+}
+
+// backupParentLoad loads the backup of an instance with the given name, for an incremental backup
+// to be based upon it.
+func backupParentLoad(s *state.State, inst instance.Instance, name string) (*backupParent, error) {
+	b, err := instance.BackupLoadByName(s, inst.Project(), name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Load parent backup %q", name)
+	}
+
+	if b.OptimizedStorage() {
+		return nil, fmt.Errorf("Incremental backups can't be based upon optimized backups")
+	}
+
+	f, err := os.Open(shared.VarPath("backups", project.Instance(inst.Project(), b.Name())))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := backup.GetInfo(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Read parent backup %q", name)
+	}
+
+	parent := &backupParent{
+		name:         b.Name(),
+		creationDate: b.CreationDate(),
+		snapshots:    []string{},
+	}
+
+	// Snapshots re-created with the same name since the parent backup must be backed up again.
+	snaps, err := inst.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, snap := range snaps {
+		_, snapName, _ := shared.InstanceGetParentAndSnapshotName(snap.Name())
+		if shared.StringInSlice(snapName, info.Snapshots) && snap.CreationDate().Before(parent.creationDate) {
+			parent.snapshots = append(parent.snapshots, snapName)
+		}
+	}
+
+	return parent, nil
+}
+
+// backupWriteTarball writes the backup tarball of an instance to the given writer, compressing it
+// with the given algorithm unless it's "none". If a parent backup is given, only the files and the
+// snapshots changed since then are included.
+func backupWriteTarball(sourceInst instance.Instance, pool storagePools.Pool, optimized bool, snapshots bool, parent *backupParent, compress string, w io.Writer) error {
+	var err error
+
 	// Get IDMap to unshift container as the tarball is created.
 	var idmap *idmap.IdmapSet
 	if sourceInst.Type() == instancetype.Container {
@@ -125,25 +209,54 @@ func backupCreate(s *state.State, args db.InstanceBackupArgs, sourceInst instanc
 		logger.Debug("Started backup tarball writer")
 		defer logger.Debug("Finished backup tarball writer")
 		if compress != "none" {
-			err = compressFile(compress, tarPipeReader, tarFileWriter)
+			err = compressFile(compress, tarPipeReader, w)
 		} else {
-			_, err = io.Copy(tarFileWriter, tarPipeReader)
+			_, err = io.Copy(w, tarPipeReader)
 		}
 		resCh <- err
 	}(tarWriterRes)
 
 	// Write index file.
 	logger.Debug("Adding backup index file")
-	err = backupWriteIndex(sourceInst, pool, b.OptimizedStorage(), !b.InstanceOnly(), tarWriter)
+	err = backupWriteIndex(sourceInst, pool, optimized, snapshots, parent, tarWriter)
 	if err != nil {
 		return errors.Wrapf(err, "Error writing backup index file")
 	}
 
-	err = pool.BackupInstance(sourceInst, tarWriter, b.OptimizedStorage(), !b.InstanceOnly(), nil)
+	// Leave out of incremental backups the snapshots and the files which didn't change, keeping
+	// track of the unchanged files so that they can be taken from the parent on restore.
+	unchanged := []string{}
+	if parent != nil {
+		tarWriter.SetFilter(func(name string, fi os.FileInfo) bool {
+			snapName := backupSnapshotName(name)
+			if snapName != "" {
+				return !shared.StringInSlice(snapName, parent.snapshots)
+			}
+
+			if !fi.Mode().IsRegular() || backupFileChangedSince(fi, parent.creationDate) {
+				return true
+			}
+
+			unchanged = append(unchanged, name)
+			return false
+		})
+	}
+
+	err = pool.BackupInstance(sourceInst, tarWriter, optimized, snapshots, nil)
 	if err != nil {
 		return errors.Wrap(err, "Backup create")
 	}
 
+	if parent != nil {
+		tarWriter.SetFilter(nil)
+
+		logger.Debug("Adding backup unchanged files list", log.Ctx{"count": len(unchanged)})
+		err = backupWriteUnchanged(unchanged, tarWriter)
+		if err != nil {
+			return errors.Wrapf(err, "Error writing backup unchanged files list")
+		}
+	}
+
 	// Close off the tarball file.
 	err = tarWriter.Close()
 	if err != nil {
@@ -161,12 +274,11 @@ func backupCreate(s *state.State, args db.InstanceBackupArgs, sourceInst instanc
 		return errors.Wrap(err, "Error writing tarball")
 	}
 
-	revert.Success()
 	return nil
 }
 
 // backupWriteIndex generates an index.yaml file and then writes it to the root of the backup tarball.
-func backupWriteIndex(sourceInst instance.Instance, pool storagePools.Pool, optimized bool, snapshots bool, tarWriter *instancewriter.InstanceTarWriter) error {
+func backupWriteIndex(sourceInst instance.Instance, pool storagePools.Pool, optimized bool, snapshots bool, parent *backupParent, tarWriter *instancewriter.InstanceTarWriter) error {
 	indexInfo := backup.Info{
 		Name:             sourceInst.Name(),
 		Pool:             pool.Name(),
@@ -176,6 +288,10 @@ func backupWriteIndex(sourceInst instance.Instance, pool storagePools.Pool, opti
 		OptimizedStorage: &optimized,
 	}
 
+	if parent != nil {
+		indexInfo.Parent = strings.SplitN(parent.name, "/", 2)[1]
+	}
+
 	if snapshots {
 		snaps, err := sourceInst.Snapshots()
 		if err != nil {
@@ -211,6 +327,286 @@ func backupWriteIndex(sourceInst instance.Instance, pool storagePools.Pool, opti
 	return nil
 }
 
+// backupWriteUnchanged writes the list of the files left out of an incremental backup because they
+// didn't change since its parent, separated by NUL characters.
+func backupWriteUnchanged(unchanged []string, tarWriter *instancewriter.InstanceTarWriter) error {
+	data := []byte(strings.Join(unchanged, "\x00"))
+
+	fileInfo := instancewriter.FileInfo{
+		FileName:    "backup/unchanged",
+		FileSize:    int64(len(data)),
+		FileMode:    0644,
+		FileModTime: time.Now(),
+	}
+
+	return tarWriter.WriteFileFromReader(bytes.NewReader(data), &fileInfo)
+}
+
+// backupFileChangedSince returns whether the content or the metadata of a file changed since the
+// given time.
+func backupFileChangedSince(fi os.FileInfo, since time.Time) bool {
+	if fi.ModTime().After(since) {
+		return true
+	}
+
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return true
+	}
+
+	return time.Unix(int64(stat.Ctim.Sec), int64(stat.Ctim.Nsec)).After(since)
+}
+
+// backupSnapshotName returns the name of the snapshot a file of a backup tarball belongs to, or an
+// empty string if it doesn't belong to a snapshot.
+func backupSnapshotName(name string) string {
+	for _, prefix := range []string{"backup/snapshots/", "backup/virtual-machine-snapshots/"} {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(name, prefix), "/", 2)
+		if len(parts) == 1 && prefix == "backup/virtual-machine-snapshots/" {
+			// Block volume of a virtual machine snapshot.
+			return strings.TrimSuffix(parts[0], ".img")
+		}
+
+		return parts[0]
+	}
+
+	return ""
+}
+
+// backupWriteMerged writes the full tarball of an incremental backup to the given writer, by merging
+// it with the backups it's based upon, and compressing it with the given algorithm unless it's "none".
+func backupWriteMerged(s *state.State, projectName string, b *backup.Backup, compress string, w io.Writer) error {
+	// Find the backups down to the full one.
+	chain := []string{b.Name()}
+	for parent := b.Parent(); parent != ""; {
+		chain = append([]string{parent}, chain...)
+
+		pb, err := instance.BackupLoadByName(s, projectName, parent)
+		if err != nil {
+			return errors.Wrapf(err, "Load parent backup %q", parent)
+		}
+
+		parent = pb.Parent()
+	}
+
+	// Merge the incremental backups one after the other into temporary uncompressed tarballs,
+	// except for the last one which is written out.
+	basePath := shared.VarPath("backups", project.Instance(projectName, chain[0]))
+	for _, name := range chain[1 : len(chain)-1] {
+		tmpFile, err := ioutil.TempFile(shared.VarPath("backups"), "lxd_backup_")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmpFile.Name())
+
+		err = backupMergeTarballs(basePath, shared.VarPath("backups", project.Instance(projectName, name)), tmpFile)
+		tmpFile.Close()
+		if err != nil {
+			return errors.Wrapf(err, "Error merging backup %q", name)
+		}
+
+		basePath = tmpFile.Name()
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+	defer pipeWriter.Close() // Ensure that go routine below always ends.
+
+	compressRes := make(chan error, 1)
+	go func(resCh chan<- error) {
+		var err error
+		if compress != "none" {
+			err = compressFile(compress, pipeReader, w)
+		} else {
+			_, err = io.Copy(w, pipeReader)
+		}
+
+		// Unblock the merge if the compression failed.
+		pipeReader.CloseWithError(err)
+		resCh <- err
+	}(compressRes)
+
+	err := backupMergeTarballs(basePath, shared.VarPath("backups", project.Instance(projectName, b.Name())), pipeWriter)
+	if err != nil {
+		return errors.Wrapf(err, "Error merging backup %q", b.Name())
+	}
+
+	err = pipeWriter.Close()
+	if err != nil {
+		return errors.Wrap(err, "Error closing tarball pipe writer")
+	}
+
+	err = <-compressRes
+	if err != nil {
+		return errors.Wrap(err, "Error writing tarball")
+	}
+
+	return nil
+}
+
+// backupMergeTarballs writes to the given writer the uncompressed tarball of a full backup, made of
+// an incremental backup and the full backup it's based upon. Files come from the incremental backup
+// when it includes them, and otherwise from the parent if they are listed as unchanged. Snapshots
+// come from the parent when the incremental backup refers to them without including them.
+func backupMergeTarballs(parentPath string, childPath string, w io.Writer) error {
+	child, err := os.Open(childPath)
+	if err != nil {
+		return err
+	}
+	defer child.Close()
+
+	parent, err := os.Open(parentPath)
+	if err != nil {
+		return err
+	}
+	defer parent.Close()
+
+	// Read the index and the unchanged files of the incremental backup, and find its snapshots.
+	index := backup.Info{}
+	unchanged := map[string]bool{}
+	childSnapshots := map[string]bool{}
+	err = backupReadTarball(child, func(hdr *tar.Header, r io.Reader) error {
+		switch hdr.Name {
+		case "backup/index.yaml":
+			return yaml.NewDecoder(r).Decode(&index)
+		case "backup/unchanged":
+			data, err := ioutil.ReadAll(r)
+			if err != nil {
+				return err
+			}
+
+			for _, name := range strings.Split(string(data), "\x00") {
+				if name != "" {
+					unchanged[name] = true
+				}
+			}
+		default:
+			snapName := backupSnapshotName(hdr.Name)
+			if snapName != "" {
+				childSnapshots[snapName] = true
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+
+	// The merged backup is a full one.
+	index.Parent = ""
+	indexData, err := yaml.Marshal(&index)
+	if err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     "backup/index.yaml",
+		Size:     int64(len(indexData)),
+		Mode:     0644,
+		ModTime:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tw.Write(indexData)
+	if err != nil {
+		return err
+	}
+
+	// Copy the unchanged files and the snapshots left out of the incremental backup from the
+	// parent first, so that the files of the incremental backup take precedence on extraction.
+	found := 0
+	err = backupReadTarball(parent, func(hdr *tar.Header, r io.Reader) error {
+		snapName := backupSnapshotName(hdr.Name)
+		if snapName != "" {
+			if childSnapshots[snapName] || !shared.StringInSlice(snapName, index.Snapshots) {
+				return nil
+			}
+		} else if unchanged[hdr.Name] {
+			found++
+		} else {
+			return nil
+		}
+
+		return backupCopyTarEntry(tw, hdr, r)
+	})
+	if err != nil {
+		return err
+	}
+
+	if found != len(unchanged) {
+		return fmt.Errorf("Parent backup is missing %d unchanged files", len(unchanged)-found)
+	}
+
+	err = backupReadTarball(child, func(hdr *tar.Header, r io.Reader) error {
+		if hdr.Name == "backup/index.yaml" || hdr.Name == "backup/unchanged" {
+			return nil
+		}
+
+		return backupCopyTarEntry(tw, hdr, r)
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// backupReadTarball calls the given function with the header and the content of each file of the
+// given (optionally compressed) tarball.
+func backupReadTarball(f *os.File, fn func(hdr *tar.Header, r io.Reader) error) error {
+	_, _, unpacker, err := shared.DetectCompressionFile(f)
+	if err != nil {
+		return err
+	}
+
+	tr, cancelFunc, err := shared.CompressedTarReader(context.Background(), f, unpacker)
+	if err != nil {
+		return err
+	}
+	defer cancelFunc()
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrapf(err, "Error reading backup tarball %q", f.Name())
+		}
+
+		err = fn(hdr, tr)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// backupCopyTarEntry copies a file read from a tarball into another.
+func backupCopyTarEntry(tw *tar.Writer, hdr *tar.Header, r io.Reader) error {
+	err := tw.WriteHeader(hdr)
+	if err != nil {
+		return errors.Wrapf(err, "Error writing tar header of %q", hdr.Name)
+	}
+
+	_, err = io.Copy(tw, r)
+	if err != nil {
+		return errors.Wrapf(err, "Error copying %q", hdr.Name)
+	}
+
+	return nil
+}
+
 func pruneExpiredContainerBackupsTask(d *Daemon) (task.Func, task.Schedule) {
 	f := func(ctx context.Context) {
 		opRun := func(op *operations.Operation) error {
@@ -256,6 +652,16 @@ func pruneExpiredContainerBackups(ctx context.Context, d *Daemon) error {
 	}
 
 	for _, b := range backups {
+		// Backups are kept as long as incremental backups are based upon them.
+		children, err := d.cluster.ContainerBackupChildren(b.Name)
+		if err != nil {
+			return errors.Wrapf(err, "Error deleting instance backup %s", b.Name)
+		}
+
+		if len(children) > 0 {
+			continue
+		}
+
 		inst, err := instance.LoadByID(d.State(), b.InstanceID)
 		if err != nil {
 			return errors.Wrapf(err, "Error deleting instance backup %s", b.Name)
@@ -33,6 +33,7 @@ type Info struct {
 	Snapshots        []string         `json:"snapshots,omitempty" yaml:"snapshots,omitempty"`
 	OptimizedStorage *bool            `json:"optimized,omitempty" yaml:"optimized,omitempty"` // Optional field to handle older optimized backups that don't have this field.
 	Type             api.InstanceType `json:"type" yaml:"type"`
+	Parent           string           `json:"parent,omitempty" yaml:"parent,omitempty"` // Name of the backup an incremental backup is based upon.
 }
 
 // GetInfo extracts backup information from a given ReadSeeker.
@@ -127,10 +128,11 @@ type Backup struct {
 	instanceOnly         bool
 	optimizedStorage     bool
 	compressionAlgorithm string
+	parent               string
 }
 
 // New instantiates a new Backup struct.
-func New(state *state.State, inst Instance, ID int, name string, creationDate, expiryDate time.Time, instanceOnly, optimizedStorage bool) *Backup {
+func New(state *state.State, inst Instance, ID int, name string, creationDate, expiryDate time.Time, instanceOnly, optimizedStorage bool, parent string) *Backup {
 	return &Backup{
 		state:            state,
 		instance:         inst,
@@ -140,6 +142,7 @@ func New(state *state.State, inst Instance, ID int, name string, creationDate, e
 		expiryDate:       expiryDate,
 		instanceOnly:     instanceOnly,
 		optimizedStorage: optimizedStorage,
+		parent:           parent,
 	}
 }
 
@@ -169,6 +172,17 @@ func (b *Backup) OptimizedStorage() bool {
 	return b.optimizedStorage
 }
 
+// CreationDate returns the date the backup was created.
+func (b *Backup) CreationDate() time.Time {
+	return b.creationDate
+}
+
+// Parent returns the name of the backup an incremental backup only includes the changes since,
+// or an empty string if it's a full backup.
+func (b *Backup) Parent() string {
+	return b.parent
+}
+
 // Rename renames a container backup
 func (b *Backup) Rename(newName string) error {
 	oldBackupPath := shared.VarPath("backups", project.Instance(b.instance.Project(), b.name))
@@ -214,6 +228,11 @@ func (b *Backup) Delete() error {
 
 // Render returns an InstanceBackup struct of the backup.
 func (b *Backup) Render() *api.InstanceBackup {
+	parent := ""
+	if b.parent != "" {
+		parent = strings.SplitN(b.parent, "/", 2)[1]
+	}
+
 	return &api.InstanceBackup{
 		Name:             strings.SplitN(b.name, "/", 2)[1],
 		CreatedAt:        b.creationDate,
@@ -221,11 +240,22 @@ func (b *Backup) Render() *api.InstanceBackup {
 		InstanceOnly:     b.instanceOnly,
 		ContainerOnly:    b.instanceOnly,
 		OptimizedStorage: b.optimizedStorage,
+		Parent:           parent,
 	}
 }
 
 // DoBackupDelete deletes a backup.
 func DoBackupDelete(s *state.State, projectName, backupName, containerName string) error {
+	// Incremental backups can't be restored without the backups they're based upon.
+	children, err := s.Cluster.ContainerBackupChildren(backupName)
+	if err != nil {
+		return err
+	}
+
+	if len(children) > 0 {
+		return fmt.Errorf("Backup %q is the parent of incremental backup %q", strings.SplitN(backupName, "/", 2)[1], strings.SplitN(children[0], "/", 2)[1])
+	}
+
 	backupPath := shared.VarPath("backups", project.Instance(projectName, backupName))
 
 	// Delete the on-disk data
@@ -247,7 +277,7 @@ func DoBackupDelete(s *state.State, projectName, backupName, containerName strin
 	}
 
 	// Remove the database record
-	err := s.Cluster.InstanceBackupRemove(backupName)
+	err = s.Cluster.InstanceBackupRemove(backupName)
 	if err != nil {
 		return err
 	}
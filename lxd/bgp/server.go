@@ -0,0 +1,663 @@
+package bgp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	bgpAPI "github.com/osrg/gobgp/api"
+	bgpServer "github.com/osrg/gobgp/pkg/server"
+)
+
+// DefaultPort is the port the BGP speaker listens on when none is specified.
+const DefaultPort = 179
+
+var familyIPv4 = &bgpAPI.Family{Afi: bgpAPI.Family_AFI_IP, Safi: bgpAPI.Family_SAFI_UNICAST}
+var familyIPv6 = &bgpAPI.Family{Afi: bgpAPI.Family_AFI_IP6, Safi: bgpAPI.Family_SAFI_UNICAST}
+
+// Server represents the BGP speaker advertising LXD's prefixes to upstream routers.
+//
+// Prefixes and peers are tracked by owner (typically a network) and kept while the speaker is
+// disabled, so that they get advertised as soon as it's configured. Each peer is only sent the
+// prefixes of its own owners.
+type Server struct {
+	bgp *bgpServer.BgpServer
+	mu  sync.Mutex
+
+	address  string
+	asn      uint32
+	routerID net.IP
+
+	paths map[string][]*path
+	peers map[string]*peer
+}
+
+// Prefix is a prefix to advertise, along with its next-hop. The speaker's own address is used when
+// no next-hop is given.
+type Prefix struct {
+	Prefix  net.IPNet
+	Nexthop net.IP
+}
+
+// Peer is an upstream router to advertise prefixes to.
+type Peer struct {
+	Address  net.IP
+	ASN      uint32
+	Password string
+}
+
+type path struct {
+	prefix  net.IPNet
+	nexthop net.IP
+	uuid    []byte
+}
+
+type peer struct {
+	address  net.IP
+	asn      uint32
+	password string
+	owners   map[string]bool
+
+	// Prefixes currently allowed by the export policy of the peer.
+	exported map[string]bool
+}
+
+// NewServer returns a new, unconfigured, BGP speaker.
+func NewServer() *Server {
+	return &Server{
+		paths: map[string][]*path{},
+		peers: map[string]*peer{},
+	}
+}
+
+// Configure (re)starts the BGP speaker with the given listen address, ASN and router ID. The
+// speaker is stopped if either the address or the ASN is empty, and left alone if none of them
+// changed.
+func (s *Server) Configure(address string, asn uint32, routerID net.IP) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	enabled := address != "" && asn != 0
+	if s.address == address && s.asn == asn && s.routerID.Equal(routerID) && enabled == (s.bgp != nil) {
+		return nil
+	}
+
+	err := s.stop()
+	if err != nil {
+		return err
+	}
+
+	s.address = address
+	s.asn = asn
+	s.routerID = routerID
+
+	if !enabled {
+		return nil
+	}
+
+	return s.start()
+}
+
+// Address returns the listen address of the BGP speaker, or an empty string if it's disabled.
+func (s *Server) Address() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.bgp == nil {
+		return ""
+	}
+
+	return s.address
+}
+
+// UpdatePrefixes sets the prefixes advertised on behalf of the given owner. Only the prefixes which
+// weren't already advertised are added, and only the ones which are gone are withdrawn.
+func (s *Server) UpdatePrefixes(owner string, prefixes []Prefix) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keep := []*path{}
+	for _, p := range s.paths[owner] {
+		if prefixesHave(prefixes, p) {
+			keep = append(keep, p)
+			continue
+		}
+
+		if s.bgp != nil {
+			err := s.deletePath(p)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	s.paths[owner] = keep
+
+	for _, prefix := range prefixes {
+		if pathsHave(s.paths[owner], prefix) {
+			continue
+		}
+
+		p := &path{prefix: prefix.Prefix, nexthop: prefix.Nexthop}
+		if s.bgp != nil {
+			err := s.addPath(p)
+			if err != nil {
+				return err
+			}
+		}
+
+		s.paths[owner] = append(s.paths[owner], p)
+	}
+
+	if len(s.paths[owner]) == 0 {
+		delete(s.paths, owner)
+	}
+
+	// Let the peers of the owner know about the change.
+	for _, p := range s.peers {
+		if !p.owners[owner] {
+			continue
+		}
+
+		err := s.refreshPeer(p)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemovePrefixes withdraws all the prefixes advertised on behalf of the given owner.
+func (s *Server) RemovePrefixes(owner string) error {
+	return s.UpdatePrefixes(owner, nil)
+}
+
+// UpdatePeers sets the routers the prefixes of the given owner are advertised to. Several owners
+// can share a peer as long as they agree on its settings. Sessions with peers which are kept are
+// left alone.
+func (s *Server) UpdatePeers(owner string, peers []Peer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wanted := map[string]Peer{}
+	for _, p := range peers {
+		key := p.Address.String()
+		existing, ok := s.peers[key]
+		if ok && (existing.asn != p.ASN || existing.password != p.Password) && (len(existing.owners) > 1 || !existing.owners[owner]) {
+			return fmt.Errorf("A BGP peer with address %q is already configured with different settings", key)
+		}
+
+		wanted[key] = p
+	}
+
+	// Drop the owner from the peers it no longer uses, or whose settings it changed.
+	for key, existing := range s.peers {
+		if !existing.owners[owner] {
+			continue
+		}
+
+		p, ok := wanted[key]
+		if ok && p.ASN == existing.asn && p.Password == existing.password {
+			continue
+		}
+
+		delete(existing.owners, owner)
+		if len(existing.owners) > 0 {
+			err := s.refreshPeer(existing)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		delete(s.peers, key)
+
+		if s.bgp != nil {
+			err := s.removePeer(existing)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for key, p := range wanted {
+		existing, ok := s.peers[key]
+		if ok {
+			if existing.owners[owner] {
+				continue
+			}
+
+			existing.owners[owner] = true
+			err := s.refreshPeer(existing)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		newPeer := &peer{
+			address:  p.Address,
+			asn:      p.ASN,
+			password: p.Password,
+			owners:   map[string]bool{owner: true},
+		}
+
+		if s.bgp != nil {
+			err := s.addPeer(newPeer)
+			if err != nil {
+				return err
+			}
+		}
+
+		s.peers[key] = newPeer
+	}
+
+	return nil
+}
+
+// RemovePeers drops the given owner from all the peers, removing those no longer used by anyone.
+func (s *Server) RemovePeers(owner string) error {
+	return s.UpdatePeers(owner, nil)
+}
+
+// Start the speaker and load the known peers and prefixes into it. Must be called with the lock held.
+func (s *Server) start() error {
+	host, port, err := parseAddress(s.address)
+	if err != nil {
+		return err
+	}
+
+	routerID := s.routerID
+	if routerID == nil {
+		// Default to the listen address when it's a specific IPv4 address.
+		listenIP := net.ParseIP(host)
+		if listenIP == nil || listenIP.To4() == nil || listenIP.IsUnspecified() {
+			return fmt.Errorf("A router ID must be set when the BGP speaker doesn't listen on a specific IPv4 address")
+		}
+
+		routerID = listenIP
+	}
+
+	global := &bgpAPI.Global{
+		As:         s.asn,
+		RouterId:   routerID.String(),
+		ListenPort: int32(port),
+	}
+
+	if host != "" {
+		global.ListenAddresses = []string{host}
+	}
+
+	server := bgpServer.NewBgpServer()
+	go server.Serve()
+
+	err = server.StartBgp(context.Background(), &bgpAPI.StartBgpRequest{Global: global})
+	if err != nil {
+		server.Stop()
+		return fmt.Errorf("Failed to start BGP speaker: %v", err)
+	}
+
+	s.bgp = server
+
+	for _, p := range s.peers {
+		err := s.addPeer(p)
+		if err != nil {
+			s.stop()
+			return err
+		}
+	}
+
+	for _, paths := range s.paths {
+		for _, p := range paths {
+			err := s.addPath(p)
+			if err != nil {
+				s.stop()
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Stop the speaker if it's running. Must be called with the lock held.
+func (s *Server) stop() error {
+	if s.bgp == nil {
+		return nil
+	}
+
+	err := s.bgp.StopBgp(context.Background(), &bgpAPI.StopBgpRequest{})
+	s.bgp.Stop()
+	s.bgp = nil
+	if err != nil {
+		return fmt.Errorf("Failed to stop BGP speaker: %v", err)
+	}
+
+	return nil
+}
+
+// peerPolicyName returns the name of the export policy, and of its prefix set, of the given peer.
+func peerPolicyName(p *peer) string {
+	return fmt.Sprintf("lxd-peer-%s", p.address.String())
+}
+
+// peerPrefixes returns the prefixes the given peer should be sent, those of its owners.
+func (s *Server) peerPrefixes(p *peer) map[string]bool {
+	prefixes := map[string]bool{}
+	for owner := range p.owners {
+		for _, path := range s.paths[owner] {
+			prefixes[path.prefix.String()] = true
+		}
+	}
+
+	return prefixes
+}
+
+// prefixSet returns the prefix set of the export policy of the given peer, holding the given
+// prefixes.
+func prefixSet(p *peer, prefixes []string) (*bgpAPI.DefinedSet, error) {
+	set := &bgpAPI.DefinedSet{
+		DefinedType: bgpAPI.DefinedType_PREFIX,
+		Name:        peerPolicyName(p),
+	}
+
+	for _, prefix := range prefixes {
+		_, subnet, err := net.ParseCIDR(prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		ones, _ := subnet.Mask.Size()
+		set.Prefixes = append(set.Prefixes, &bgpAPI.Prefix{
+			IpPrefix:      prefix,
+			MaskLengthMin: uint32(ones),
+			MaskLengthMax: uint32(ones),
+		})
+	}
+
+	return set, nil
+}
+
+// addPeer sets up the session with the given peer, along with an export policy rejecting anything
+// but the prefixes of its owners. Must be called with the lock held and the speaker running.
+func (s *Server) addPeer(p *peer) error {
+	name := peerPolicyName(p)
+
+	p.exported = s.peerPrefixes(p)
+	prefixes := []string{}
+	for prefix := range p.exported {
+		prefixes = append(prefixes, prefix)
+	}
+
+	set, err := prefixSet(p, prefixes)
+	if err != nil {
+		return err
+	}
+
+	err = s.bgp.AddDefinedSet(context.Background(), &bgpAPI.AddDefinedSetRequest{DefinedSet: set})
+	if err != nil {
+		return fmt.Errorf("Failed to add the prefix set of BGP peer %q: %v", p.address.String(), err)
+	}
+
+	err = s.bgp.AddPolicy(context.Background(), &bgpAPI.AddPolicyRequest{
+		Policy: &bgpAPI.Policy{
+			Name: name,
+			Statements: []*bgpAPI.Statement{{
+				Name: name,
+				Conditions: &bgpAPI.Conditions{
+					PrefixSet: &bgpAPI.MatchSet{MatchType: bgpAPI.MatchType_ANY, Name: name},
+				},
+				Actions: &bgpAPI.Actions{RouteAction: bgpAPI.RouteAction_ACCEPT},
+			}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to add the export policy of BGP peer %q: %v", p.address.String(), err)
+	}
+
+	err = s.bgp.AddPeer(context.Background(), &bgpAPI.AddPeerRequest{
+		Peer: &bgpAPI.Peer{
+			Conf: &bgpAPI.PeerConf{
+				NeighborAddress: p.address.String(),
+				PeerAs:          p.asn,
+				AuthPassword:    p.password,
+			},
+			ApplyPolicy: &bgpAPI.ApplyPolicy{
+				ExportPolicy: &bgpAPI.PolicyAssignment{
+					Name:          p.address.String(),
+					Direction:     bgpAPI.PolicyDirection_EXPORT,
+					Policies:      []*bgpAPI.Policy{{Name: name}},
+					DefaultAction: bgpAPI.RouteAction_REJECT,
+				},
+			},
+			AfiSafis: []*bgpAPI.AfiSafi{
+				{Config: &bgpAPI.AfiSafiConfig{Family: familyIPv4, Enabled: true}},
+				{Config: &bgpAPI.AfiSafiConfig{Family: familyIPv6, Enabled: true}},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to add BGP peer %q: %v", p.address.String(), err)
+	}
+
+	return nil
+}
+
+// removePeer ends the session with the given peer and removes its export policy. Must be called
+// with the lock held and the speaker running.
+func (s *Server) removePeer(p *peer) error {
+	err := s.bgp.DeletePeer(context.Background(), &bgpAPI.DeletePeerRequest{Address: p.address.String()})
+	if err != nil {
+		return fmt.Errorf("Failed to remove BGP peer %q: %v", p.address.String(), err)
+	}
+
+	name := peerPolicyName(p)
+	err = s.bgp.DeletePolicy(context.Background(), &bgpAPI.DeletePolicyRequest{Policy: &bgpAPI.Policy{Name: name}, All: true})
+	if err != nil {
+		return fmt.Errorf("Failed to remove the export policy of BGP peer %q: %v", p.address.String(), err)
+	}
+
+	err = s.bgp.DeleteDefinedSet(context.Background(), &bgpAPI.DeleteDefinedSetRequest{
+		DefinedSet: &bgpAPI.DefinedSet{DefinedType: bgpAPI.DefinedType_PREFIX, Name: name},
+		All:        true,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to remove the prefix set of BGP peer %q: %v", p.address.String(), err)
+	}
+
+	return nil
+}
+
+// refreshPeer updates the export policy of the given peer to the current prefixes of its owners,
+// then resends its routes without resetting the session. Must be called with the lock held.
+func (s *Server) refreshPeer(p *peer) error {
+	if s.bgp == nil {
+		return nil
+	}
+
+	prefixes := s.peerPrefixes(p)
+	added := []string{}
+	removed := []string{}
+	for prefix := range prefixes {
+		if !p.exported[prefix] {
+			added = append(added, prefix)
+		}
+	}
+
+	for prefix := range p.exported {
+		if !prefixes[prefix] {
+			removed = append(removed, prefix)
+		}
+	}
+
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+
+	if len(added) > 0 {
+		set, err := prefixSet(p, added)
+		if err != nil {
+			return err
+		}
+
+		err = s.bgp.AddDefinedSet(context.Background(), &bgpAPI.AddDefinedSetRequest{DefinedSet: set})
+		if err != nil {
+			return fmt.Errorf("Failed to update the prefix set of BGP peer %q: %v", p.address.String(), err)
+		}
+	}
+
+	if len(removed) > 0 {
+		set, err := prefixSet(p, removed)
+		if err != nil {
+			return err
+		}
+
+		err = s.bgp.DeleteDefinedSet(context.Background(), &bgpAPI.DeleteDefinedSetRequest{DefinedSet: set})
+		if err != nil {
+			return fmt.Errorf("Failed to update the prefix set of BGP peer %q: %v", p.address.String(), err)
+		}
+	}
+
+	p.exported = prefixes
+
+	err := s.bgp.ResetPeer(context.Background(), &bgpAPI.ResetPeerRequest{
+		Address:   p.address.String(),
+		Soft:      true,
+		Direction: bgpAPI.ResetPeerRequest_OUT,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to refresh the routes of BGP peer %q: %v", p.address.String(), err)
+	}
+
+	return nil
+}
+
+func (s *Server) addPath(p *path) error {
+	ones, _ := p.prefix.Mask.Size()
+
+	nlri, err := ptypes.MarshalAny(&bgpAPI.IPAddressPrefix{
+		Prefix:    p.prefix.IP.String(),
+		PrefixLen: uint32(ones),
+	})
+	if err != nil {
+		return err
+	}
+
+	origin, err := ptypes.MarshalAny(&bgpAPI.OriginAttribute{Origin: 0})
+	if err != nil {
+		return err
+	}
+
+	family := familyIPv4
+	nexthop := "0.0.0.0"
+	if p.prefix.IP.To4() == nil {
+		family = familyIPv6
+		nexthop = "::"
+	}
+
+	if p.nexthop != nil {
+		nexthop = p.nexthop.String()
+	}
+
+	var nexthopAttr *any.Any
+	if family == familyIPv4 {
+		nexthopAttr, err = ptypes.MarshalAny(&bgpAPI.NextHopAttribute{NextHop: nexthop})
+	} else {
+		nexthopAttr, err = ptypes.MarshalAny(&bgpAPI.MpReachNLRIAttribute{
+			Family:   family,
+			NextHops: []string{nexthop},
+			Nlris:    []*any.Any{nlri},
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.bgp.AddPath(context.Background(), &bgpAPI.AddPathRequest{
+		TableType: bgpAPI.TableType_GLOBAL,
+		Path: &bgpAPI.Path{
+			Family: family,
+			Nlri:   nlri,
+			Pattrs: []*any.Any{origin, nexthopAttr},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to advertise prefix %q: %v", p.prefix.String(), err)
+	}
+
+	p.uuid = resp.Uuid
+	return nil
+}
+
+func (s *Server) deletePath(p *path) error {
+	err := s.bgp.DeletePath(context.Background(), &bgpAPI.DeletePathRequest{
+		TableType: bgpAPI.TableType_GLOBAL,
+		Uuid:      p.uuid,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to withdraw prefix %q: %v", p.prefix.String(), err)
+	}
+
+	return nil
+}
+
+// prefixesHave returns whether the given prefixes include the one of the given path, with the same
+// next-hop.
+func prefixesHave(prefixes []Prefix, p *path) bool {
+	for _, prefix := range prefixes {
+		if prefix.Prefix.String() == p.prefix.String() && prefix.Nexthop.Equal(p.nexthop) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pathsHave returns whether the given paths include the given prefix, with the same next-hop.
+func pathsHave(paths []*path, prefix Prefix) bool {
+	for _, p := range paths {
+		if prefix.Prefix.String() == p.prefix.String() && prefix.Nexthop.Equal(p.nexthop) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseAddress splits a listen address into its host and port, defaulting to the standard BGP port.
+func parseAddress(address string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		// No port specified.
+		host = address
+		portStr = strconv.Itoa(DefaultPort)
+	}
+
+	if host != "" && net.ParseIP(host) == nil {
+		return "", -1, fmt.Errorf("Invalid BGP listen address %q", address)
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", -1, fmt.Errorf("Invalid BGP listen port in %q", address)
+	}
+
+	return host, port, nil
+}
+
+// ValidateAddress checks that the given value is a valid BGP listen address.
+func ValidateAddress(address string) error {
+	if address == "" {
+		return nil
+	}
+
+	_, _, err := parseAddress(address)
+	return err
+}
@@ -0,0 +1,63 @@
+package bgp
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAddress(t *testing.T) {
+	cases := map[string]struct {
+		host string
+		port int
+	}{
+		"10.0.0.1":       {"10.0.0.1", 179},
+		"10.0.0.1:1179":  {"10.0.0.1", 1179},
+		":179":           {"", 179},
+		"[fd42::1]:1179": {"fd42::1", 1179},
+		"fd42::1":        {"fd42::1", 179},
+	}
+
+	for address, expected := range cases {
+		host, port, err := parseAddress(address)
+		assert.NoError(t, err, address)
+		assert.Equal(t, expected.host, host, address)
+		assert.Equal(t, expected.port, port, address)
+	}
+
+	for _, address := range []string{"foo", "10.0.0.1:0", "10.0.0.1:bar"} {
+		assert.Error(t, ValidateAddress(address), address)
+	}
+}
+
+func TestUpdatePeers(t *testing.T) {
+	s := NewServer()
+
+	_, subnet1, _ := net.ParseCIDR("10.0.1.0/24")
+	_, subnet2, _ := net.ParseCIDR("10.0.2.0/24")
+	router := Peer{Address: net.ParseIP("10.0.0.1"), ASN: 65000}
+
+	assert.NoError(t, s.UpdatePeers("net1", []Peer{router}))
+	assert.NoError(t, s.UpdatePeers("net2", []Peer{router}))
+	assert.NoError(t, s.UpdatePrefixes("net1", []Prefix{{Prefix: *subnet1}}))
+	assert.NoError(t, s.UpdatePrefixes("net2", []Prefix{{Prefix: *subnet2}}))
+
+	// A shared peer can't be reconfigured by one of its owners.
+	assert.Error(t, s.UpdatePeers("net1", []Peer{{Address: router.Address, ASN: 65001}}))
+
+	// A peer is only sent the prefixes of its owners.
+	other := Peer{Address: net.ParseIP("10.0.0.2"), ASN: 65002}
+	assert.NoError(t, s.UpdatePeers("net2", []Peer{other}))
+	assert.Equal(t, map[string]bool{"10.0.1.0/24": true}, s.peerPrefixes(s.peers["10.0.0.1"]))
+	assert.Equal(t, map[string]bool{"10.0.2.0/24": true}, s.peerPrefixes(s.peers["10.0.0.2"]))
+
+	// The last owner can reconfigure it, and unused peers are dropped.
+	assert.NoError(t, s.UpdatePeers("net1", []Peer{{Address: router.Address, ASN: 65001}}))
+	assert.Equal(t, uint32(65001), s.peers["10.0.0.1"].asn)
+	assert.NoError(t, s.RemovePeers("net2"))
+	assert.Len(t, s.peers, 1)
+
+	assert.NoError(t, s.RemovePrefixes("net1"))
+	assert.Len(t, s.paths, 1)
+}
@@ -2,6 +2,8 @@ package cgroup
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 // CGroup represents the main cgroup abstraction.
@@ -216,36 +218,215 @@ func (cg *CGroup) GetMemorySwapUsage() (string, error) {
 	return "", ErrUnknownVersion
 }
 
-// GetBlkioWeight returns the currently allowed range of weights
-func (cg *CGroup) GetBlkioWeight() (string, error) {
+// GetMemoryOOMKills returns the number of processes killed by the OOM killer because of the limit
+func (cg *CGroup) GetMemoryOOMKills() (int64, error) {
+	version := cgControllers["memory"]
+	var content string
+	var err error
+	switch version {
+	case Unavailable:
+		return -1, ErrControllerMissing
+	case V1:
+		content, err = cg.rw.Get(version, "memory", "memory.oom_control")
+	case V2:
+		content, err = cg.rw.Get(version, "memory", "memory.events")
+	default:
+		return -1, ErrUnknownVersion
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	// Both files are made of "key value" lines, older kernels don't have the oom_kill key.
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			return strconv.ParseInt(fields[1], 10, 64)
+		}
+	}
+
+	return -1, ErrControllerMissing
+}
+
+// GetMemoryWorkingSet returns the current use of memory, less the page cache which can be reclaimed
+func (cg *CGroup) GetMemoryWorkingSet() (int64, error) {
+	version := cgControllers["memory"]
+	var usage string
+	var content string
+	var err error
+	key := "inactive_file"
+	switch version {
+	case Unavailable:
+		return -1, ErrControllerMissing
+	case V1:
+		usage, err = cg.rw.Get(version, "memory", "memory.usage_in_bytes")
+		if err == nil {
+			content, err = cg.rw.Get(version, "memory", "memory.stat")
+		}
+
+		// The V1 file holds both the local and hierarchical counters.
+		key = "total_inactive_file"
+	case V2:
+		usage, err = cg.rw.Get(version, "memory", "memory.current")
+		if err == nil {
+			content, err = cg.rw.Get(version, "memory", "memory.stat")
+		}
+	default:
+		return -1, ErrUnknownVersion
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	value, err := strconv.ParseInt(strings.TrimSpace(usage), 10, 64)
+	if err != nil {
+		return -1, err
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != key {
+			continue
+		}
+
+		inactive, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return -1, err
+		}
+
+		if inactive > value {
+			return 0, nil
+		}
+
+		return value - inactive, nil
+	}
+
+	return value, nil
+}
+
+// GetBlkioWeight returns the I/O weight of the cgroup, as a legacy blkio weight. On the unified
+// hierarchy, bfq selects the weight used by the BFQ scheduler rather than io.weight.
+func (cg *CGroup) GetBlkioWeight(bfq bool) (string, error) {
 	// Confirm we have the controller
-	version := cgControllers["blkio"]
+	version := cgControllers["blkio.weight"]
 	switch version {
 	case Unavailable:
 		return "", ErrControllerMissing
 	case V1:
 		return cg.rw.Get(version, "blkio", "blkio.weight")
 	case V2:
-		return "", ErrControllerMissing
+		key, max := blkioWeightKeyV2(bfq)
+		if key == "" {
+			return "", ErrControllerMissing
+		}
+
+		value, err := cg.rw.Get(version, "io", key)
+		if err != nil {
+			return "", err
+		}
+
+		// Only report the default weight, ignoring any per-device override.
+		fields := strings.Fields(value)
+		if len(fields) == 2 && fields[0] == "default" {
+			value = fields[1]
+		}
+
+		weight, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("%d", blkioWeightFromV2(weight, max)), nil
 	}
 	return "", ErrUnknownVersion
 }
 
-// SetBlkioWeight set the currently allowed range of weights
-func (cg *CGroup) SetBlkioWeight(value string) error {
-	version := cgControllers["blkio"]
+// SetBlkioWeight sets the I/O weight of the cgroup from a legacy blkio weight. On the unified
+// hierarchy, bfq selects the weight used by the BFQ scheduler rather than io.weight.
+func (cg *CGroup) SetBlkioWeight(value string, bfq bool) error {
+	version := cgControllers["blkio.weight"]
 	switch version {
 	case Unavailable:
 		return ErrControllerMissing
 	case V1:
 		return cg.rw.Set(version, "blkio", "blkio.weight", value)
 	case V2:
-		return ErrControllerMissing
+		key, max := blkioWeightKeyV2(bfq)
+		if key == "" {
+			return ErrControllerMissing
+		}
+
+		weight, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		return cg.rw.Set(version, "io", key, fmt.Sprintf("%d", blkioWeightToV2(weight, max)))
 	}
 	return ErrUnknownVersion
 
 }
 
+// blkioWeightKeyV2 returns the unified hierarchy file controlling the I/O weight, along with the
+// maximum weight it accepts. The BFQ scheduler only honours io.bfq.weight while the other ones
+// rely on io.weight, falling back to whichever is available.
+func blkioWeightKeyV2(bfq bool) (string, int64) {
+	keys := []string{"io.weight", "io.bfq.weight"}
+	if bfq {
+		keys = []string{"io.bfq.weight", "io.weight"}
+	}
+
+	for _, key := range keys {
+		if cgControllers[key] != V2 {
+			continue
+		}
+
+		if key == "io.bfq.weight" {
+			return key, 1000
+		}
+
+		return key, 10000
+	}
+
+	return "", -1
+}
+
+// blkioWeightToV2 converts a legacy blkio weight (10 to 1000, defaulting to 500) to a unified
+// hierarchy I/O weight (1 to max, defaulting to 100), keeping the legacy default on the new one.
+func blkioWeightToV2(weight int64, max int64) int64 {
+	if weight < 10 {
+		weight = 10
+	} else if weight > 1000 {
+		weight = 1000
+	}
+
+	if weight <= 500 {
+		return 1 + divRound((weight-10)*99, 490)
+	}
+
+	return 100 + divRound((weight-500)*(max-100), 500)
+}
+
+// blkioWeightFromV2 converts a unified hierarchy I/O weight back to a legacy blkio weight.
+func blkioWeightFromV2(weight int64, max int64) int64 {
+	if weight < 1 {
+		weight = 1
+	} else if weight > max {
+		weight = max
+	}
+
+	if weight <= 100 {
+		return 10 + divRound((weight-1)*490, 99)
+	}
+
+	return 500 + divRound((weight-100)*500, max-100)
+}
+
+// divRound divides the given non-negative integers, rounding to the nearest integer.
+func divRound(a int64, b int64) int64 {
+	return (a + b/2) / b
+}
+
 // SetCPUShare sets the weight of each group in the same hierarchy
 func (cg *CGroup) SetCPUShare(value string) error {
 	//Confirm we have the controller
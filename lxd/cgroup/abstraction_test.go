@@ -0,0 +1,84 @@
+package cgroup
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlkioWeightToV2(t *testing.T) {
+	cases := []struct {
+		weight   int64
+		max      int64
+		expected int64
+	}{
+		{10, 10000, 1},
+		{500, 10000, 100},
+		{1000, 10000, 10000},
+		{750, 10000, 5050},
+		{10, 1000, 1},
+		{500, 1000, 100},
+		{1000, 1000, 1000},
+		{255, 1000, 51},
+		{0, 10000, 1},
+		{5000, 10000, 10000},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, blkioWeightToV2(c.weight, c.max), fmt.Sprintf("%d (max %d)", c.weight, c.max))
+	}
+}
+
+func TestBlkioWeightFromV2(t *testing.T) {
+	cases := []struct {
+		weight   int64
+		max      int64
+		expected int64
+	}{
+		{1, 10000, 10},
+		{100, 10000, 500},
+		{10000, 10000, 1000},
+		{5050, 10000, 750},
+		{1000, 1000, 1000},
+		{50, 1000, 253},
+		{0, 1000, 10},
+		{20000, 10000, 1000},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, blkioWeightFromV2(c.weight, c.max), fmt.Sprintf("%d (max %d)", c.weight, c.max))
+	}
+}
+
+// Converting back and forth keeps the weight, up to the resolution of the coarser scale.
+func TestBlkioWeightRoundTrip(t *testing.T) {
+	for _, max := range []int64{1000, 10000} {
+		// The unified scale is finer above the default, and coarser below it.
+		for weight := int64(10); weight <= 1000; weight++ {
+			result := blkioWeightFromV2(blkioWeightToV2(weight, max), max)
+			if weight >= 500 {
+				assert.Equal(t, weight, result, fmt.Sprintf("%d (max %d)", weight, max))
+			} else {
+				assert.InDelta(t, weight, result, 3, fmt.Sprintf("%d (max %d)", weight, max))
+			}
+		}
+
+		for weight := int64(1); weight <= 100; weight++ {
+			assert.Equal(t, weight, blkioWeightToV2(blkioWeightFromV2(weight, max), max), fmt.Sprintf("%d (max %d)", weight, max))
+		}
+	}
+
+	// The LXD priorities map to distinct weights.
+	seen := map[int64]bool{}
+	for priority := int64(0); priority <= 10; priority++ {
+		weight := priority * 100
+		if weight == 0 {
+			weight = 10
+		}
+
+		converted := blkioWeightToV2(weight, 10000)
+		assert.False(t, seen[converted], fmt.Sprintf("priority %d", priority))
+		seen[converted] = true
+	}
+}
@@ -2,6 +2,7 @@ package cgroup
 
 import (
 	"bufio"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -130,11 +131,7 @@ func (info *Info) SupportsVersion(resource Resource) (Backend, bool) {
 		return Unavailable, false
 	case BlkioWeight:
 		val, ok := cgControllers["blkio.weight"]
-		if ok && val == V1 {
-			return V1, ok
-		}
-
-		return Unavailable, false
+		return val, ok
 	case CPU:
 		val, ok := cgControllers["cpu"]
 		if ok && val == V1 {
@@ -291,6 +288,24 @@ func (info *Info) Log() {
 	}
 }
 
+// parseUnifiedControllers returns the controllers listed in a cgroup.controllers file of the unified
+// hierarchy, along with the "unified" pseudo-controller recording that it's present at all.
+func parseUnifiedControllers(r io.Reader) map[string]Backend {
+	unifiedControllers := map[string]Backend{}
+
+	// Record the fact that V2 is present at all.
+	unifiedControllers["unified"] = V2
+
+	scanControllers := bufio.NewScanner(r)
+	for scanControllers.Scan() {
+		for _, controller := range strings.Fields(scanControllers.Text()) {
+			unifiedControllers[controller] = V2
+		}
+	}
+
+	return unifiedControllers
+}
+
 func init() {
 	_, err := os.Stat("/proc/self/ns/cgroup")
 	if err == nil {
@@ -313,6 +328,7 @@ func init() {
 
 	hasV1 := false
 	hasV2 := false
+	unifiedPath := ""
 	// Go through the file line by line.
 	scanSelfCg := bufio.NewScanner(selfCg)
 	for scanSelfCg.Scan() {
@@ -351,22 +367,15 @@ func init() {
 		}
 
 		if err == nil {
-			unifiedControllers := map[string]Backend{}
-
-			// Record the fact that V2 is present at all.
-			unifiedControllers["unified"] = V2
-
-			scanControllers := bufio.NewScanner(controllers)
-			for scanControllers.Scan() {
-				line := strings.TrimSpace(scanSelfCg.Text())
-				unifiedControllers[line] = V2
-			}
+			unifiedControllers := parseUnifiedControllers(controllers)
 			hasV2 = true
 
 			if dedicatedPath != "" {
+				unifiedPath = filepath.Dir(dedicatedPath)
 				cgControllers = unifiedControllers
 				break
 			} else {
+				unifiedPath = filepath.Dir(hybridPath)
 				for k, v := range unifiedControllers {
 					cgControllers[k] = v
 				}
@@ -386,6 +395,19 @@ func init() {
 		}
 	}
 
+	val, ok = cgControllers["io"]
+	if ok && val == V2 && unifiedPath != "" {
+		if shared.PathExists(filepath.Join(unifiedPath, "io.bfq.weight")) {
+			cgControllers["io.bfq.weight"] = V2
+			cgControllers["blkio.weight"] = V2
+		}
+
+		if shared.PathExists(filepath.Join(unifiedPath, "io.weight")) {
+			cgControllers["io.weight"] = V2
+			cgControllers["blkio.weight"] = V2
+		}
+	}
+
 	val, ok = cgControllers["memory"]
 	if ok && val == V1 && shared.PathExists("/sys/fs/cgroup/memory/memory.memsw.limit_in_bytes") {
 		cgControllers["memory.memsw.limit_in_bytes"] = V2
@@ -0,0 +1,27 @@
+package cgroup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUnifiedControllers(t *testing.T) {
+	cases := map[string]map[string]Backend{
+		"": {"unified": V2},
+		"cpuset cpu io memory pids\n": {
+			"unified": V2,
+			"cpuset":  V2,
+			"cpu":     V2,
+			"io":      V2,
+			"memory":  V2,
+			"pids":    V2,
+		},
+		"memory  pids": {"unified": V2, "memory": V2, "pids": V2},
+	}
+
+	for content, expected := range cases {
+		assert.Equal(t, expected, parseUnifiedControllers(strings.NewReader(content)), content)
+	}
+}
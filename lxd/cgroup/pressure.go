@@ -0,0 +1,81 @@
+package cgroup
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PressureStall holds the pressure stall information of a resource for one class of tasks: the
+// share of time tasks were stalled on it, averaged over 10s, 60s and 300s windows, along with the
+// total stall time in microseconds.
+type PressureStall struct {
+	Avg10  float64
+	Avg60  float64
+	Avg300 float64
+	Total  int64
+}
+
+// GetPressure returns the pressure stall information of the given controller (cpu, memory or io),
+// keyed by class ("some" when at least one task stalled, "full" when all of them did). It's only
+// available on the unified hierarchy.
+func (cg *CGroup) GetPressure(controller string) (map[string]PressureStall, error) {
+	version := cgControllers[controller]
+	switch version {
+	case Unavailable, V1:
+		return nil, ErrControllerMissing
+	case V2:
+		content, err := cg.rw.Get(version, controller, fmt.Sprintf("%s.pressure", controller))
+		if err != nil {
+			return nil, err
+		}
+
+		return parsePressure(content)
+	}
+
+	return nil, ErrUnknownVersion
+}
+
+// parsePressure parses the content of a *.pressure file, made of lines like
+// "some avg10=0.00 avg60=0.00 avg300=0.00 total=0".
+func parsePressure(content string) (map[string]PressureStall, error) {
+	stalls := map[string]PressureStall{}
+
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		stall := PressureStall{}
+		for _, field := range fields[1:] {
+			parts := strings.SplitN(field, "=", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("Invalid pressure field %q", field)
+			}
+
+			var err error
+			switch parts[0] {
+			case "avg10":
+				stall.Avg10, err = strconv.ParseFloat(parts[1], 64)
+			case "avg60":
+				stall.Avg60, err = strconv.ParseFloat(parts[1], 64)
+			case "avg300":
+				stall.Avg300, err = strconv.ParseFloat(parts[1], 64)
+			case "total":
+				stall.Total, err = strconv.ParseInt(parts[1], 10, 64)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("Invalid pressure field %q: %v", field, err)
+			}
+		}
+
+		stalls[fields[0]] = stall
+	}
+
+	if len(stalls) == 0 {
+		return nil, ErrControllerMissing
+	}
+
+	return stalls, nil
+}
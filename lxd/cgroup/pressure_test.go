@@ -0,0 +1,23 @@
+package cgroup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePressure(t *testing.T) {
+	content := "some avg10=1.50 avg60=0.25 avg300=0.00 total=123456\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=42\n"
+
+	stalls, err := parsePressure(content)
+	require.NoError(t, err)
+	assert.Equal(t, PressureStall{Avg10: 1.5, Avg60: 0.25, Total: 123456}, stalls["some"])
+	assert.Equal(t, PressureStall{Total: 42}, stalls["full"])
+
+	_, err = parsePressure("")
+	assert.Equal(t, ErrControllerMissing, err)
+
+	_, err = parsePressure("some avg10=x")
+	assert.Error(t, err)
+}
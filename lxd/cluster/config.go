@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"net/url"
 	"os/exec"
 	"strconv"
 	"time"
@@ -13,6 +14,8 @@ import (
 
 	"github.com/lxc/lxd/lxd/config"
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/scriptlet"
+	"github.com/lxc/lxd/shared"
 	"github.com/pkg/errors"
 )
 
@@ -78,6 +81,12 @@ func (c *Config) CandidServer() (string, string, int64, string) {
 		c.m.GetString("candid.domains")
 }
 
+// AuthorizationWebhook returns the URL of the authorization webhook and its timeout in seconds.
+func (c *Config) AuthorizationWebhook() (string, int64) {
+	return c.m.GetString("authorization.webhook.url"),
+		c.m.GetInt64("authorization.webhook.timeout")
+}
+
 // RBACServer returns all the Candid settings needed to connect to a server.
 func (c *Config) RBACServer() (string, string, int64, string, string, string, string) {
 	return c.m.GetString("rbac.api.url"),
@@ -131,6 +140,13 @@ func (c *Config) OfflineThreshold() time.Duration {
 	return time.Duration(n) * time.Second
 }
 
+// HeartbeatInterval returns the configured interval between two rounds of
+// heartbeats sent by the leader.
+func (c *Config) HeartbeatInterval() time.Duration {
+	n := c.m.GetInt64("cluster.heartbeat_interval")
+	return time.Duration(n) * time.Second
+}
+
 // ImagesMinimalReplica returns the numbers of nodes for cluster images replication
 func (c *Config) ImagesMinimalReplica() int64 {
 	return c.m.GetInt64("cluster.images_minimal_replica")
@@ -148,6 +164,43 @@ func (c *Config) MaxStandBy() int64 {
 	return c.m.GetInt64("cluster.max_standby")
 }
 
+// BGPASN returns the autonomous system number of the BGP speaker.
+func (c *Config) BGPASN() int64 {
+	return c.m.GetInt64("core.bgp_asn")
+}
+
+// Dashboard returns whether the built-in web dashboard is served.
+func (c *Config) Dashboard() bool {
+	return c.m.GetBool("core.dashboard")
+}
+
+// DashboardExec returns whether the built-in web dashboard offers a shell in the instances.
+func (c *Config) DashboardExec() bool {
+	return c.m.GetBool("core.dashboard_exec")
+}
+
+// OperationsRetention returns for how long completed operations are kept in the history.
+func (c *Config) OperationsRetention() time.Duration {
+	n := c.m.GetInt64("core.operations_retention")
+	return time.Duration(n) * time.Hour
+}
+
+// EventsRetention returns for how long emitted events are kept in memory.
+func (c *Config) EventsRetention() time.Duration {
+	n := c.m.GetInt64("core.events_retention")
+	return time.Duration(n) * time.Minute
+}
+
+// OffPeakWindows returns the weekly windows during which deferred copies and backups may run.
+func (c *Config) OffPeakWindows() string {
+	return c.m.GetString("core.offpeak_windows")
+}
+
+// TraceEndpoint returns the URL of the OpenTelemetry collector the trace spans are sent to.
+func (c *Config) TraceEndpoint() string {
+	return c.m.GetString("core.trace_endpoint")
+}
+
 // Dump current configuration keys and their values. Keys with values matching
 // their defaults are omitted.
 func (c *Config) Dump() map[string]interface{} {
@@ -178,6 +231,12 @@ func (c *Config) update(values map[string]interface{}) (map[string]string, error
 		return nil, err
 	}
 
+	// Members must get a chance to reply to at least one heartbeat before
+	// being considered offline.
+	if c.OfflineThreshold() <= c.HeartbeatInterval() {
+		return nil, fmt.Errorf("The offline threshold must be greater than the heartbeat interval")
+	}
+
 	err = c.tx.UpdateConfig(changed)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot persist configuration changes: %v")
@@ -237,18 +296,28 @@ func configGet(cluster *db.Cluster) (*Config, error) {
 
 // ConfigSchema defines available server configuration keys.
 var ConfigSchema = config.Schema{
+	"authorization.webhook.timeout":  {Type: config.Int64, Default: "5"},
+	"authorization.webhook.url":      {},
 	"backups.compression_algorithm":  {Default: "gzip", Validator: validateCompression},
+	"cluster.heartbeat_interval":     {Type: config.Int64, Default: heartbeatIntervalDefault(), Validator: heartbeatIntervalValidator},
 	"cluster.offline_threshold":      {Type: config.Int64, Default: offlineThresholdDefault(), Validator: offlineThresholdValidator},
 	"cluster.images_minimal_replica": {Type: config.Int64, Default: "3", Validator: imageMinimalReplicaValidator},
 	"cluster.max_voters":             {Type: config.Int64, Default: "3", Validator: maxVotersValidator},
 	"cluster.max_standby":            {Type: config.Int64, Default: "2", Validator: maxStandByValidator},
+	"core.bgp_asn":                   {Type: config.Int64, Default: "0", Validator: bgpASNValidator},
+	"core.dashboard":                 {Type: config.Bool},
+	"core.dashboard_exec":            {Type: config.Bool},
+	"core.events_retention":          {Type: config.Int64, Default: "60", Validator: shared.IsUint32},
 	"core.https_allowed_headers":     {},
 	"core.https_allowed_methods":     {},
 	"core.https_allowed_origin":      {},
 	"core.https_allowed_credentials": {Type: config.Bool},
+	"core.offpeak_windows":           {Validator: offPeakWindowsValidator},
+	"core.operations_retention":      {Type: config.Int64, Default: "24", Validator: shared.IsUint32},
 	"core.proxy_http":                {},
 	"core.proxy_https":               {},
 	"core.proxy_ignore_hosts":        {},
+	"core.trace_endpoint":            {Validator: traceEndpointValidator},
 	"core.trust_password":            {Hidden: true, Setter: passwordSetter},
 	"core.trust_ca_certificates":     {Type: config.Bool},
 	"candid.api.key":                 {},
@@ -259,6 +328,7 @@ var ConfigSchema = config.Schema{
 	"images.auto_update_interval":    {Type: config.Int64, Default: "6"},
 	"images.compression_algorithm":   {Default: "gzip", Validator: validateCompression},
 	"images.remote_cache_expiry":     {Type: config.Int64, Default: "10"},
+	"instances.admission_scriptlet":  {Validator: scriptlet.Validate},
 	"maas.api.key":                   {},
 	"maas.api.url":                   {},
 	"rbac.agent.url":                 {},
@@ -281,20 +351,69 @@ var ConfigSchema = config.Schema{
 	"storage.zfs_use_refquota":     {Setter: deprecatedStorage, Type: config.Bool},
 }
 
+func bgpASNValidator(value string) error {
+	asn, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return fmt.Errorf("Invalid ASN %q", value)
+	}
+
+	if asn == 4294967295 {
+		return fmt.Errorf("ASN 4294967295 is reserved")
+	}
+
+	return nil
+}
+
+func traceEndpointValidator(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Invalid OTLP/HTTP endpoint %q", value)
+	}
+
+	return nil
+}
+
+func offPeakWindowsValidator(value string) error {
+	_, err := shared.InstanceMaintenanceWindowsParse(value)
+	return err
+}
+
 func offlineThresholdDefault() string {
 	return strconv.Itoa(db.DefaultOfflineThreshold)
 }
 
 func offlineThresholdValidator(value string) error {
-	// Ensure that the given value is greater than the heartbeat interval,
-	// which is the lower bound granularity of the offline check.
+	// Ensure that the given value is greater than the lowest heartbeat
+	// interval, which is the lower bound granularity of the offline check.
+	// The actual heartbeat interval is checked when updating the config.
 	threshold, err := strconv.Atoi(value)
 	if err != nil {
 		return fmt.Errorf("Offline threshold is not a number")
 	}
 
-	if threshold <= heartbeatInterval {
-		return fmt.Errorf("Value must be greater than '%d'", heartbeatInterval)
+	if threshold <= heartbeatIntervalMin {
+		return fmt.Errorf("Value must be greater than '%d'", heartbeatIntervalMin)
+	}
+
+	return nil
+}
+
+func heartbeatIntervalDefault() string {
+	return strconv.Itoa(db.DefaultHeartbeatInterval)
+}
+
+func heartbeatIntervalValidator(value string) error {
+	interval, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("Heartbeat interval is not a number")
+	}
+
+	if interval < heartbeatIntervalMin {
+		return fmt.Errorf("Value must be at least '%d'", heartbeatIntervalMin)
 	}
 
 	return nil
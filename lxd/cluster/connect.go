@@ -31,6 +31,10 @@ func Connect(address string, cert *shared.CertInfo, notify bool) (lxd.InstanceSe
 		for i := 0; i < 20; i++ {
 			listenersLock.Lock()
 			_, ok := listeners[address]
+			if !ok && listenersRelay != "" {
+				// All events are relayed by an event hub.
+				_, ok = listeners[listenersRelay]
+			}
 			listenersLock.Unlock()
 
 			if ok {
@@ -46,15 +50,21 @@ func Connect(address string, cert *shared.CertInfo, notify bool) (lxd.InstanceSe
 		}
 	}
 
+	userAgent := version.UserAgent
+	if notify {
+		userAgent = "lxd-cluster-notifier"
+	}
+
+	return connect(address, cert, userAgent)
+}
+
+func connect(address string, cert *shared.CertInfo, userAgent string) (lxd.InstanceServer, error) {
 	args := &lxd.ConnectionArgs{
 		TLSServerCert: string(cert.PublicKey()),
 		TLSClientCert: string(cert.PublicKey()),
 		TLSClientKey:  string(cert.PrivateKey()),
 		SkipGetServer: true,
-		UserAgent:     version.UserAgent,
-	}
-	if notify {
-		args.UserAgent = "lxd-cluster-notifier"
+		UserAgent:     userAgent,
 	}
 
 	url := fmt.Sprintf("https://%s", address)
@@ -12,11 +12,15 @@ import (
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
 )
 
 var listeners = map[string]*lxd.EventListener{}
 var listenersLock sync.Mutex
 
+// Address of the event hub relaying the events of all members, if any.
+var listenersRelay string
+
 // Events starts a task that continuously monitors the list of cluster nodes and
 // maintains a pool of websocket connections against all of them, in order to
 // get notified about events.
@@ -47,6 +51,7 @@ func eventsUpdateListeners(endpoints *endpoints.Endpoints, cluster *db.Cluster,
 	// Get the current cluster nodes.
 	var nodes []db.NodeInfo
 	var offlineThreshold time.Duration
+	var localName string
 
 	err := cluster.Transaction(func(tx *db.ClusterTx) error {
 		var err error
@@ -61,6 +66,11 @@ func eventsUpdateListeners(endpoints *endpoints.Endpoints, cluster *db.Cluster,
 			return err
 		}
 
+		localName, err = tx.NodeName()
+		if err != nil {
+			return err
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -73,9 +83,51 @@ func eventsUpdateListeners(endpoints *endpoints.Endpoints, cluster *db.Cluster,
 
 	address := endpoints.NetworkAddress()
 
-	addresses := make([]string, len(nodes))
-	for i, node := range nodes {
-		addresses[i] = node.Address
+	// If some online members are event hubs, the other members only get
+	// the events relayed by one of them, instead of connecting to every
+	// member. The event hubs themselves still connect to every member.
+	listenersLock.Lock()
+	relayAddress := ""
+	for _, node := range nodes {
+		if !shared.StringInSlice(string(db.ClusterRoleEventHub), node.Roles) {
+			continue
+		}
+
+		if node.Address == address {
+			relayAddress = ""
+			break
+		}
+
+		if node.IsOffline(offlineThreshold) {
+			continue
+		}
+
+		if relayAddress == "" || node.Address == listenersRelay {
+			relayAddress = node.Address
+		}
+	}
+
+	// Listeners to the former or new relay get different events.
+	if relayAddress != listenersRelay {
+		for _, hubAddress := range []string{listenersRelay, relayAddress} {
+			listener, ok := listeners[hubAddress]
+			if ok {
+				listener.Disconnect()
+				delete(listeners, hubAddress)
+			}
+		}
+
+		listenersRelay = relayAddress
+	}
+	listenersLock.Unlock()
+
+	addresses := []string{}
+	for _, node := range nodes {
+		if relayAddress != "" && node.Address != relayAddress {
+			continue
+		}
+
+		addresses = append(addresses, node.Address)
 
 		// Don't bother trying to connect to offline nodes, or to ourselves.
 		if node.IsOffline(offlineThreshold) || node.Address == address {
@@ -99,13 +151,25 @@ func eventsUpdateListeners(endpoints *endpoints.Endpoints, cluster *db.Cluster,
 		}
 		listenersLock.Unlock()
 
-		listener, err := eventsConnect(node.Address, endpoints.NetworkCert())
+		listener, err := eventsConnect(node.Address, endpoints.NetworkCert(), relayAddress != "")
 		if err != nil {
 			logger.Warnf("Failed to get events from node %s: %v", node.Address, err)
 			continue
 		}
 		logger.Debugf("Listening for events on node %s", node.Address)
-		listener.AddHandler(nil, func(event api.Event) { f(node.ID, event) })
+		nodeID := node.ID
+		if relayAddress != "" {
+			listener.AddHandler(nil, func(event api.Event) {
+				// Our own events are relayed back too.
+				if event.Location == localName {
+					return
+				}
+
+				f(nodeID, event)
+			})
+		} else {
+			listener.AddHandler(nil, func(event api.Event) { f(nodeID, event) })
+		}
 
 		listenersLock.Lock()
 		listeners[node.Address] = listener
@@ -122,9 +186,16 @@ func eventsUpdateListeners(endpoints *endpoints.Endpoints, cluster *db.Cluster,
 	listenersLock.Unlock()
 }
 
-// Establish a client connection to get events from the given node.
-func eventsConnect(address string, cert *shared.CertInfo) (*lxd.EventListener, error) {
-	client, err := Connect(address, cert, true)
+// Establish a client connection to get events from the given node. If relay is
+// true, the node is an event hub which is asked for the events of all members,
+// and not only its own.
+func eventsConnect(address string, cert *shared.CertInfo, relay bool) (*lxd.EventListener, error) {
+	userAgent := "lxd-cluster-notifier"
+	if relay {
+		userAgent = version.UserAgent
+	}
+
+	client, err := connect(address, cert, userAgent)
 	if err != nil {
 		return nil, err
 	}
@@ -110,14 +110,14 @@ func (hbState *APIHeartbeat) Update(fullStateList bool, raftNodes []db.RaftNode,
 }
 
 // Send sends heartbeat requests to the nodes supplied and updates heartbeat state.
-func (hbState *APIHeartbeat) Send(ctx context.Context, cert *shared.CertInfo, localAddress string, nodes []db.NodeInfo, delay bool) {
+func (hbState *APIHeartbeat) Send(ctx context.Context, cert *shared.CertInfo, localAddress string, nodes []db.NodeInfo, spread time.Duration) {
 	heartbeatsWg := sync.WaitGroup{}
-	sendHeartbeat := func(nodeID int64, address string, delay bool, heartbeatData *APIHeartbeat) {
+	sendHeartbeat := func(nodeID int64, address string, spread time.Duration, heartbeatData *APIHeartbeat) {
 		defer heartbeatsWg.Done()
 
-		if delay {
+		if spread > 0 {
 			// Spread in time by waiting up to 3s less than the interval.
-			time.Sleep(time.Duration(rand.Intn((heartbeatInterval*1000)-3000)) * time.Millisecond)
+			time.Sleep(time.Duration(rand.Int63n(int64(spread - 3*time.Second))))
 		}
 		logger.Debugf("Sending heartbeat to %s", address)
 
@@ -157,7 +157,7 @@ func (hbState *APIHeartbeat) Send(ctx context.Context, cert *shared.CertInfo, lo
 
 		// Parallelize the rest.
 		heartbeatsWg.Add(1)
-		go sendHeartbeat(node.ID, node.Address, delay, hbState)
+		go sendHeartbeat(node.ID, node.Address, spread, hbState)
 	}
 	heartbeatsWg.Wait()
 }
@@ -182,11 +182,35 @@ func HeartbeatTask(gateway *Gateway) (task.Func, task.Schedule) {
 		}
 	}
 
-	schedule := task.Every(time.Duration(heartbeatInterval) * time.Second)
+	// Follow changes of the configured interval at every round.
+	schedule := func() (time.Duration, error) {
+		return gateway.heartbeatInterval(), nil
+	}
 
 	return heartbeatWrapper, schedule
 }
 
+// Return the configured interval between two heartbeat rounds, or the default
+// one if it can't be loaded.
+func (g *Gateway) heartbeatInterval() time.Duration {
+	interval := time.Duration(db.DefaultHeartbeatInterval) * time.Second
+	if g.Cluster == nil {
+		return interval
+	}
+
+	err := g.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		interval, err = tx.NodeHeartbeatInterval()
+		return err
+	})
+	if err != nil {
+		logger.Warnf("Failed to get heartbeat interval: %v", err)
+		return time.Duration(db.DefaultHeartbeatInterval) * time.Second
+	}
+
+	return interval
+}
+
 func (g *Gateway) heartbeat(ctx context.Context, initialHeartbeat bool) {
 	if g.Cluster == nil || g.server == nil || g.memoryDial != nil {
 		// We're not a raft node or we're not clustered
@@ -261,14 +285,14 @@ func (g *Gateway) heartbeat(ctx context.Context, initialHeartbeat bool) {
 	// Send stale set to all nodes in database to get a fresh set of active nodes.
 	if initialHeartbeat {
 		hbState.Update(false, raftNodes, allNodes, offlineThreshold)
-		hbState.Send(ctx, g.cert, localAddress, allNodes, false)
+		hbState.Send(ctx, g.cert, localAddress, allNodes, 0)
 
 		// We have the latest set of node states now, lets send that state set to all nodes.
 		hbState.Update(true, raftNodes, allNodes, offlineThreshold)
-		hbState.Send(ctx, g.cert, localAddress, allNodes, false)
+		hbState.Send(ctx, g.cert, localAddress, allNodes, 0)
 	} else {
 		hbState.Update(true, raftNodes, allNodes, offlineThreshold)
-		hbState.Send(ctx, g.cert, localAddress, allNodes, true)
+		hbState.Send(ctx, g.cert, localAddress, allNodes, g.heartbeatInterval())
 	}
 
 	// Look for any new node which appeared since sending last heartbeat.
@@ -307,7 +331,7 @@ func (g *Gateway) heartbeat(ctx context.Context, initialHeartbeat bool) {
 	// If any new nodes found, send heartbeat to just them (with full node state).
 	if len(newNodes) > 0 {
 		hbState.Update(true, raftNodes, allNodes, offlineThreshold)
-		hbState.Send(ctx, g.cert, localAddress, newNodes, false)
+		hbState.Send(ctx, g.cert, localAddress, newNodes, 0)
 	}
 
 	// If the context has been cancelled, return immediately.
@@ -342,8 +366,9 @@ func (g *Gateway) heartbeat(ctx context.Context, initialHeartbeat bool) {
 	logger.Debugf("Completed heartbeat round")
 }
 
-// heartbeatInterval Number of seconds to wait between to heartbeat rounds.
-const heartbeatInterval = 10
+// heartbeatIntervalMin Lowest number of seconds to wait between two heartbeat
+// rounds, heartbeats being spread over the interval minus 3 seconds.
+const heartbeatIntervalMin = 4
 
 // HeartbeatNode performs a single heartbeat request against the node with the given address.
 func HeartbeatNode(taskCtx context.Context, address string, cert *shared.CertInfo, heartbeatData *APIHeartbeat) error {
@@ -829,8 +829,10 @@ func Leave(state *state.State, gateway *Gateway, name string, force bool) (strin
 // address of such member along with an updated list of nodes, with the ne role
 // set.
 //
+// If target is not empty, only the member with that address is considered.
+//
 // It should be called only by the current leader.
-func Handover(state *state.State, gateway *Gateway, address string) (string, []db.RaftNode, error) {
+func Handover(state *state.State, gateway *Gateway, address string, target string) (string, []db.RaftNode, error) {
 	nodes, err := gateway.currentRaftNodes()
 	if err != nil {
 		return "", nil, errors.Wrap(err, "Failed to get current raft nodes")
@@ -857,6 +859,9 @@ func Handover(state *state.State, gateway *Gateway, address string) (string, []d
 		if node.Role == db.RaftVoter || node.Address == address {
 			continue
 		}
+		if target != "" && node.Address != target {
+			continue
+		}
 		online, err := isMemberOnline(state, gateway.cert, node.Address)
 		if err != nil {
 			return "", nil, errors.Wrapf(err, "Failed to check if %s is online", node.Address)
@@ -871,6 +876,145 @@ func Handover(state *state.State, gateway *Gateway, address string) (string, []d
 	return "", nil, nil
 }
 
+// Promote returns an updated list of nodes in which the member with the given
+// address is a voter. If that would make the number of voters exceed
+// cluster.max_voters, it also returns the address of a voter other than the
+// leader, which must then be demoted.
+//
+// It should be called only by the current leader.
+func Promote(state *state.State, gateway *Gateway, address string) (string, []db.RaftNode, error) {
+	nodes, err := gateway.currentRaftNodes()
+	if err != nil {
+		return "", nil, errors.Wrap(err, "Failed to get current raft nodes")
+	}
+
+	var maxVoters int64
+	err = state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		config, err := ConfigLoad(tx)
+		if err != nil {
+			return errors.Wrap(err, "Failed to load cluster configuration")
+		}
+		maxVoters = config.MaxVoters()
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	leader, err := gateway.LeaderAddress()
+	if err != nil {
+		return "", nil, err
+	}
+
+	found := false
+	voters := []string{}
+	for i, node := range nodes {
+		if node.Address != address {
+			if node.Role == db.RaftVoter {
+				voters = append(voters, node.Address)
+			}
+			continue
+		}
+		if node.Role == db.RaftVoter {
+			return "", nil, fmt.Errorf("Member %s is already a database member", address)
+		}
+		nodes[i].Role = db.RaftVoter
+		found = true
+	}
+	if !found {
+		return "", nil, fmt.Errorf("No dqlite node has address %s", address)
+	}
+
+	online, err := isMemberOnline(state, gateway.cert, address)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "Failed to check if %s is online", address)
+	}
+	if !online {
+		return "", nil, fmt.Errorf("Member %s is offline", address)
+	}
+
+	if len(voters) < int(maxVoters) {
+		return "", nodes, nil
+	}
+
+	for _, voter := range voters {
+		if voter != leader {
+			return voter, nodes, nil
+		}
+	}
+
+	return "", nil, fmt.Errorf("No database member can be demoted in favor of %s", address)
+}
+
+// SetStandBy turns the spare database member with the given address into a
+// stand-by one, if there are fewer than cluster.max_standby of them, or the
+// stand-by member with the given address into a spare one.
+//
+// Return the updated raft configuration, to be applied with the assign
+// endpoint.
+func SetStandBy(state *state.State, gateway *Gateway, address string, standBy bool) ([]db.RaftNode, error) {
+	nodes, err := gateway.currentRaftNodes()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get current raft nodes")
+	}
+
+	var maxStandBy int64
+	err = state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		config, err := ConfigLoad(tx)
+		if err != nil {
+			return errors.Wrap(err, "Failed to load cluster configuration")
+		}
+		maxStandBy = config.MaxStandBy()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	found := false
+	standBys := 0
+	for i, node := range nodes {
+		if node.Address != address {
+			if node.Role == db.RaftStandBy {
+				standBys++
+			}
+			continue
+		}
+
+		if node.Role == db.RaftVoter {
+			return nil, fmt.Errorf("Member %s is a database member", address)
+		}
+
+		if standBy {
+			nodes[i].Role = db.RaftStandBy
+		} else {
+			nodes[i].Role = db.RaftSpare
+		}
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("No dqlite node has address %s", address)
+	}
+
+	if !standBy {
+		return nodes, nil
+	}
+
+	if standBys >= int(maxStandBy) {
+		return nil, fmt.Errorf("There are already %d stand-by database members", standBys)
+	}
+
+	online, err := isMemberOnline(state, gateway.cert, address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to check if %s is online", address)
+	}
+	if !online {
+		return nil, fmt.Errorf("Member %s is offline", address)
+	}
+
+	return nodes, nil
+}
+
 // Check if the member with the given address is one.
 func isMemberOnline(state *state.State, cert *shared.CertInfo, address string) (bool, error) {
 	online := true
@@ -948,6 +1092,24 @@ func List(state *state.State) ([]api.ClusterMember, error) {
 		return nil, err
 	}
 
+	// The stand-by database members are only known from the raft
+	// configuration.
+	var raftNodes []db.RaftNode
+	err = state.Node.Transaction(func(tx *db.NodeTx) error {
+		raftNodes, err = tx.RaftNodes()
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to load raft nodes")
+	}
+
+	standBys := []string{}
+	for _, raftNode := range raftNodes {
+		if raftNode.Role == db.RaftStandBy {
+			standBys = append(standBys, raftNode.Address)
+		}
+	}
+
 	result := make([]api.ClusterMember, len(nodes))
 	now := time.Now()
 	version := nodes[0].Version()
@@ -956,6 +1118,9 @@ func List(state *state.State) ([]api.ClusterMember, error) {
 		result[i].URL = fmt.Sprintf("https://%s", node.Address)
 		result[i].Database = shared.StringInSlice(string(db.ClusterRoleDatabase), node.Roles)
 		result[i].Roles = node.Roles
+		if shared.StringInSlice(node.Address, standBys) {
+			result[i].Roles = append(result[i].Roles, string(db.ClusterRoleDatabaseStandBy))
+		}
 		result[i].Architecture, err = osarch.ArchitectureName(node.Architecture)
 		if err != nil {
 			return nil, err
@@ -334,6 +334,7 @@ func TestJoin(t *testing.T) {
 	assert.Equal(t, "Online", nodes[1].Status)
 	assert.True(t, nodes[0].Database)
 	assert.False(t, nodes[1].Database)
+	assert.Contains(t, nodes[1].Roles, "database-standby")
 
 	// The Count function returns the number of nodes.
 	count, err := cluster.Count(state)
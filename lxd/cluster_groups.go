@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	projecthelpers "github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
+)
+
+var clusterGroupsCmd = APIEndpoint{
+	Path: "cluster/groups",
+
+	Get:  APIEndpointAction{Handler: clusterGroupsGet, AccessHandler: allowAuthenticated},
+	Post: APIEndpointAction{Handler: clusterGroupsPost},
+}
+
+var clusterGroupCmd = APIEndpoint{
+	Path: "cluster/groups/{name}",
+
+	Delete: APIEndpointAction{Handler: clusterGroupDelete},
+	Get:    APIEndpointAction{Handler: clusterGroupGet, AccessHandler: allowAuthenticated},
+	Put:    APIEndpointAction{Handler: clusterGroupPut},
+}
+
+func clusterGroupsGet(d *Daemon, r *http.Request) response.Response {
+	recursion := util.IsRecursionRequest(r)
+
+	var groups []db.ClusterGroup
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		groups, err = tx.ClusterGroups()
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	resultString := []string{}
+	resultMap := []api.ClusterGroup{}
+	for _, group := range groups {
+		if !recursion {
+			resultString = append(resultString, fmt.Sprintf("/%s/cluster/groups/%s", version.APIVersion, group.Name))
+		} else {
+			resultMap = append(resultMap, clusterGroupToAPI(group))
+		}
+	}
+
+	if !recursion {
+		return response.SyncResponse(true, resultString)
+	}
+
+	return response.SyncResponse(true, resultMap)
+}
+
+func clusterGroupsPost(d *Daemon, r *http.Request) response.Response {
+	req := api.ClusterGroupsPost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = clusterGroupValidName(req.Name)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	exists := false
+	missing := ""
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		_, err := tx.ClusterGroupGet(req.Name)
+		if err == nil {
+			exists = true
+			return nil
+		}
+
+		if err != db.ErrNoSuchObject {
+			return err
+		}
+
+		missing, err = clusterGroupMissingMember(tx, req.Members)
+		if err != nil || missing != "" {
+			return err
+		}
+
+		_, err = tx.ClusterGroupCreate(req.Name, req.Description, req.Members)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if exists {
+		return response.Conflict(fmt.Errorf("Cluster group '%s' already exists", req.Name))
+	}
+
+	if missing != "" {
+		return response.BadRequest(fmt.Errorf("Cluster member '%s' not found", missing))
+	}
+
+	return response.SyncResponseLocation(true, nil, fmt.Sprintf("/%s/cluster/groups/%s", version.APIVersion, req.Name))
+}
+
+func clusterGroupGet(d *Daemon, r *http.Request) response.Response {
+	name := mux.Vars(r)["name"]
+
+	var group db.ClusterGroup
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		group, err = tx.ClusterGroupGet(name)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	render := clusterGroupToAPI(group)
+	return response.SyncResponseETag(true, render, render.Writable())
+}
+
+func clusterGroupPut(d *Daemon, r *http.Request) response.Response {
+	name := mux.Vars(r)["name"]
+
+	req := api.ClusterGroupPut{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	var group db.ClusterGroup
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		group, err = tx.ClusterGroupGet(name)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Validate the ETag
+	current := clusterGroupToAPI(group)
+	err = util.EtagCheck(r, current.Writable())
+	if err != nil {
+		return response.PreconditionFailed(err)
+	}
+
+	missing := ""
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		missing, err = clusterGroupMissingMember(tx, req.Members)
+		if err != nil || missing != "" {
+			return err
+		}
+
+		return tx.ClusterGroupUpdate(group.ID, req.Description, req.Members)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if missing != "" {
+		return response.BadRequest(fmt.Errorf("Cluster member '%s' not found", missing))
+	}
+
+	return response.EmptySyncResponse
+}
+
+func clusterGroupDelete(d *Daemon, r *http.Request) response.Response {
+	name := mux.Vars(r)["name"]
+
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		group, err := tx.ClusterGroupGet(name)
+		if err != nil {
+			return err
+		}
+
+		return tx.ClusterGroupDelete(group.ID)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func clusterGroupToAPI(group db.ClusterGroup) api.ClusterGroup {
+	return api.ClusterGroup{
+		ClusterGroupPut: api.ClusterGroupPut{
+			Description: group.Description,
+			Members:     group.Members,
+		},
+		Name: group.Name,
+	}
+}
+
+// clusterGroupValidName checks the name of a cluster group, which can't contain characters used
+// when listing or targeting groups.
+func clusterGroupValidName(name string) error {
+	if name == "" {
+		return fmt.Errorf("Cluster group name can't be empty")
+	}
+
+	if strings.ContainsAny(name, "/,@ \t\n") {
+		return fmt.Errorf("Cluster group name can't contain slashes, commas, '@' or whitespace")
+	}
+
+	return nil
+}
+
+// clusterGroupMissingMember returns the first of the given members which isn't a cluster member,
+// if any.
+func clusterGroupMissingMember(tx *db.ClusterTx, members []string) (string, error) {
+	for _, member := range members {
+		_, err := tx.NodeByName(member)
+		if err == db.ErrNoSuchObject {
+			return member, nil
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return "", nil
+}
+
+// clusterGroupsPlacement returns the cluster groups a new instance can be placed on, nil meaning
+// any cluster member, checking the given target against them. A target of the form @<group>
+// selects the members of that group.
+func clusterGroupsPlacement(tx *db.ClusterTx, project string, req api.InstancesPost, target string) ([]string, error) {
+	allowed, err := projecthelpers.AllowedClusterGroups(tx, project, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(target, "@") {
+		name := strings.TrimPrefix(target, "@")
+		_, err := tx.ClusterGroupGet(name)
+		if err == db.ErrNoSuchObject {
+			return nil, fmt.Errorf("Cluster group '%s' not found", name)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if allowed != nil && !shared.StringInSlice(name, allowed) {
+			return nil, fmt.Errorf("Cluster group '%s' isn't allowed for this instance", name)
+		}
+
+		return []string{name}, nil
+	}
+
+	if target == "" || allowed == nil {
+		return allowed, nil
+	}
+
+	for _, name := range allowed {
+		group, err := tx.ClusterGroupGet(name)
+		if err == db.ErrNoSuchObject {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if shared.StringInSlice(target, group.Members) {
+			return allowed, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Cluster member '%s' isn't in any of the cluster groups allowed for this instance", target)
+}
@@ -0,0 +1,59 @@
+package crashlog
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// maxEntries is the number of crash reports kept in memory, older ones are discarded.
+const maxEntries = 50
+
+// Entry represents a panic that was recovered from an API handler or an operation.
+type Entry struct {
+	Timestamp time.Time `json:"timestamp" yaml:"timestamp"`
+	Source    string    `json:"source" yaml:"source"`
+	Message   string    `json:"message" yaml:"message"`
+	Stack     string    `json:"stack" yaml:"stack"`
+}
+
+var entriesLock sync.Mutex
+var entries []Entry
+
+// Record stores a crash report for the given recovered panic value and logs it.
+// It must be called from the deferred function that recovered the panic so that the captured stack trace points
+// to the code that panicked.
+func Record(source string, value interface{}) Entry {
+	entry := Entry{
+		Timestamp: time.Now().UTC(),
+		Source:    source,
+		Message:   fmt.Sprintf("%v", value),
+		Stack:     string(debug.Stack()),
+	}
+
+	logger.Errorf("Recovered from panic in %s: %s\n%s", entry.Source, entry.Message, entry.Stack)
+
+	entriesLock.Lock()
+	defer entriesLock.Unlock()
+
+	entries = append(entries, entry)
+	if len(entries) > maxEntries {
+		entries = entries[len(entries)-maxEntries:]
+	}
+
+	return entry
+}
+
+// Entries returns a copy of the recorded crash reports, oldest first.
+func Entries() []Entry {
+	entriesLock.Lock()
+	defer entriesLock.Unlock()
+
+	result := make([]Entry, len(entries))
+	copy(result, entries)
+
+	return result
+}
@@ -0,0 +1,44 @@
+package crashlog_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/lxd/crashlog"
+)
+
+// A recovered panic is recorded along with its stack trace.
+func TestRecord(t *testing.T) {
+	func() {
+		defer func() {
+			p := recover()
+			if p != nil {
+				crashlog.Record("test", p)
+			}
+		}()
+
+		panic("boom")
+	}()
+
+	entries := crashlog.Entries()
+	assert.NotEmpty(t, entries)
+
+	entry := entries[len(entries)-1]
+	assert.Equal(t, "test", entry.Source)
+	assert.Equal(t, "boom", entry.Message)
+	assert.Contains(t, entry.Stack, "crashlog_test.TestRecord")
+}
+
+// Only the most recent entries are kept.
+func TestRecord_Bounded(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		crashlog.Record("test", fmt.Sprintf("crash %d", i))
+	}
+
+	entries := crashlog.Entries()
+	assert.Len(t, entries, 50)
+	assert.Equal(t, "crash 99", entries[len(entries)-1].Message)
+	assert.Equal(t, "crash 50", entries[0].Message)
+}
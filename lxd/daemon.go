@@ -8,6 +8,7 @@ import (
 	sqldriver "database/sql/driver"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -28,7 +29,9 @@ import (
 	"gopkg.in/macaroon-bakery.v2/bakery/identchecker"
 	"gopkg.in/macaroon-bakery.v2/httpbakery"
 
+	"github.com/lxc/lxd/lxd/bgp"
 	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/crashlog"
 	"github.com/lxc/lxd/lxd/daemon"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/device"
@@ -36,18 +39,22 @@ import (
 	"github.com/lxc/lxd/lxd/events"
 	"github.com/lxc/lxd/lxd/firewall"
 	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/tools"
 
 	// Import instance/drivers without name so init() runs.
 	_ "github.com/lxc/lxd/lxd/instance/drivers"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/maas"
 	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/procevents"
 	"github.com/lxc/lxd/lxd/rbac"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/lxd/seccomp"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/lxd/sys"
 	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/lxd/tracing"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/idmap"
@@ -63,6 +70,7 @@ type Daemon struct {
 	os           *sys.OS
 	db           *db.Node
 	firewall     firewall.Firewall
+	bgp          *bgp.Server
 	maas         *maas.Controller
 	rbac         *rbac.Server
 	cluster      *db.Cluster
@@ -98,6 +106,11 @@ type Daemon struct {
 	// Serialize changes to cluster membership (joins, leaves, role
 	// changes).
 	clusterMembershipMutex sync.Mutex
+
+	// Authorization webhook, replaced on configuration changes while
+	// requests are being served.
+	authzWebhook     *authzWebhook
+	authzWebhookLock sync.Mutex
 }
 
 type externalAuth struct {
@@ -152,6 +165,7 @@ func newDaemon(config *DaemonConfig, os *sys.OS) *Daemon {
 	devlxdEvents := events.NewServer(daemon.Debug, daemon.Verbose)
 
 	return &Daemon{
+		bgp:          bgp.NewServer(),
 		config:       config,
 		devlxdEvents: devlxdEvents,
 		events:       lxdEvents,
@@ -210,6 +224,16 @@ func allowAuthenticated(d *Daemon, r *http.Request) response.Response {
 	return response.EmptySyncResponse
 }
 
+// allowAdmin is an AccessHandler which only allows requests from admins, the
+// same as not setting any AccessHandler.
+func allowAdmin(d *Daemon, r *http.Request) response.Response {
+	if !d.userIsAdmin(r) {
+		return response.Forbidden(nil)
+	}
+
+	return response.EmptySyncResponse
+}
+
 // allowProjectPermission is a wrapper to check access against the project, its features and RBAC permission
 func allowProjectPermission(feature string, permission string) func(d *Daemon, r *http.Request) response.Response {
 	return func(d *Daemon, r *http.Request) response.Response {
@@ -353,7 +377,7 @@ func writeMacaroonsRequiredResponse(b *identchecker.Bakery, r *http.Request, w h
 
 // State creates a new State instance linked to our internal db and os.
 func (d *Daemon) State() *state.State {
-	return state.NewState(d.db, d.cluster, d.maas, d.os, d.endpoints, d.events, d.devlxdEvents, d.firewall, d.proxy)
+	return state.NewState(d.db, d.cluster, d.maas, d.bgp, d.os, d.endpoints, d.events, d.devlxdEvents, d.firewall, d.proxy, d.readyChan)
 }
 
 // UnixSocket returns the full path to the unix.socket file that this daemon is
@@ -378,6 +402,27 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c APIEndpoint) {
 	route := restAPI.HandleFunc(uri, func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
+		// Trace the request, as part of the caller's trace if it sent a traceparent header.
+		ctx, span := tracing.Start(tracing.Extract(r.Context(), r.Header.Get("traceparent")), fmt.Sprintf("%s %s", r.Method, uri))
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.target", r.URL.RequestURI())
+		defer span.End(nil)
+		r = r.WithContext(ctx)
+
+		// Isolate panics to the request that triggered them rather than taking the whole daemon down.
+		defer func() {
+			p := recover()
+			if p == http.ErrAbortHandler {
+				// Let net/http abort the response, as requested by the handler.
+				panic(p)
+			}
+
+			if p != nil {
+				crashlog.Record(fmt.Sprintf("%s %s", r.Method, r.URL.Path), p)
+				response.InternalError(fmt.Errorf("Internal error while handling request")).Render(w)
+			}
+		}()
+
 		if !(r.RemoteAddr == "@" && version == "internal") {
 			// Block public API requests until we're done with basic
 			// initialization tasks, such setting up the cluster database.
@@ -415,6 +460,7 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c APIEndpoint) {
 		if trusted {
 			logger.Debug("Handling", log.Ctx{"method": r.Method, "url": r.URL.RequestURI(), "ip": r.RemoteAddr, "user": username})
 			r = r.WithContext(context.WithValue(r.Context(), "username", username))
+			r = r.WithContext(context.WithValue(r.Context(), "protocol", protocol))
 		} else if untrustedOk && r.Header.Get("X-LXD-authenticated") == "" {
 			logger.Debug(fmt.Sprintf("Allowing untrusted %s", r.Method), log.Ctx{"url": r.URL.RequestURI(), "ip": r.RemoteAddr})
 		} else if derr, ok := err.(*bakery.DischargeRequiredError); ok {
@@ -440,6 +486,29 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c APIEndpoint) {
 			shared.DebugJson(captured)
 		}
 
+		// Let the authorization webhook decide, except for local and intra-cluster requests.
+		d.authzWebhookLock.Lock()
+		webhook := d.authzWebhook
+		d.authzWebhookLock.Unlock()
+
+		if webhook != nil && trusted && !shared.StringInSlice(protocol, []string{"unix", "cluster"}) {
+			allowed, reason, err := webhook.Check(r, username, protocol)
+			if err != nil {
+				logger.Error("Failed to check request authorization", log.Ctx{"url": r.URL.RequestURI(), "user": username, "err": err})
+			}
+
+			if !allowed {
+				logger.Warn("Rejecting request denied by authorization webhook", log.Ctx{"method": r.Method, "url": r.URL.RequestURI(), "ip": r.RemoteAddr, "user": username})
+				if reason != "" {
+					response.Forbidden(fmt.Errorf("%s", reason)).Render(w)
+				} else {
+					response.Forbidden(nil).Render(w)
+				}
+
+				return
+			}
+		}
+
 		// Actually process the request
 		var resp response.Response
 		resp = response.NotImplemented(nil)
@@ -480,6 +549,13 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c APIEndpoint) {
 			resp = response.NotFound(fmt.Errorf("Method '%s' not found", r.Method))
 		}
 
+		// Record who requested the operation, for the operations history.
+		operations.SetResponseRequestor(resp, instanceHistoryRequestor(r))
+
+		// Trace the operation as part of the request.
+		operations.SetResponseTraceContext(resp, r.Context())
+		span.SetAttribute("lxd.response", resp.String())
+
 		// Handle errors
 		if err := resp.Render(w); err != nil {
 			err := response.InternalError(err).Render(w)
@@ -550,6 +626,9 @@ func (d *Daemon) init() error {
 	// Setup logger
 	events.LoggingServer = d.events
 
+	// Trace the subprocesses run on behalf of traced requests and operations.
+	shared.RunCommandHook = tracing.CommandHook
+
 	// Lets check if there's an existing LXD running
 	err := endpoints.CheckAlreadyRunning(d.UnixSocket())
 	if err != nil {
@@ -580,6 +659,9 @@ func (d *Daemon) init() error {
 		return err
 	}
 
+	// Detect the versions of the external tools in the background, as that may take a while
+	go tools.RefreshVersions()
+
 	// Bump some kernel limits to avoid issues
 	for _, limit := range []int{unix.RLIMIT_NOFILE} {
 		rLimit := unix.Rlimit{}
@@ -884,6 +966,12 @@ func (d *Daemon) init() error {
 	maasAPIKey := ""
 	maasMachine := ""
 
+	bgpAddress := ""
+	bgpRouterID := ""
+	bgpASN := int64(0)
+
+	coreDumps := false
+
 	err = d.db.Transaction(func(tx *db.NodeTx) error {
 		config, err := node.ConfigLoad(tx)
 		if err != nil {
@@ -891,6 +979,9 @@ func (d *Daemon) init() error {
 		}
 
 		maasMachine = config.MAASMachine()
+		bgpAddress = config.BGPAddress()
+		bgpRouterID = config.BGPRouterID()
+		coreDumps = config.CoreDumps()
 		return nil
 	})
 	if err != nil {
@@ -908,8 +999,13 @@ func (d *Daemon) init() error {
 			config.ProxyHTTPS(), config.ProxyHTTP(), config.ProxyIgnoreHosts(),
 		)
 
+		d.setupAuthzWebhook(config.AuthorizationWebhook())
+		d.events.SetRetention(config.EventsRetention())
+		d.setupTracing(config.TraceEndpoint())
+
 		candidAPIURL, candidAPIKey, candidExpiry, candidDomains = config.CandidServer()
 		maasAPIURL, maasAPIKey = config.MAASController()
+		bgpASN = config.BGPASN()
 		rbacAPIURL, rbacAPIKey, rbacExpiry, rbacAgentURL, rbacAgentUsername, rbacAgentPrivateKey, rbacAgentPublicKey = config.RBACServer()
 		return nil
 	})
@@ -917,6 +1013,14 @@ func (d *Daemon) init() error {
 		return err
 	}
 
+	// Start the BGP speaker, the networks have already registered their prefixes with it.
+	if bgpAddress != "" && bgpASN != 0 {
+		err = d.setupBGP(bgpAddress, bgpASN, bgpRouterID)
+		if err != nil {
+			logger.Warn("Failed to start the BGP speaker", log.Ctx{"address": bgpAddress, "err": err})
+		}
+	}
+
 	if rbacAPIURL != "" {
 		err = d.setupRBACServer(rbacAPIURL, rbacAPIKey, rbacExpiry, rbacAgentURL, rbacAgentUsername, rbacAgentPrivateKey, rbacAgentPublicKey)
 		if err != nil {
@@ -947,6 +1051,19 @@ func (d *Daemon) init() error {
 		// This should come after the event handler go routines have been started.
 		devicesRegister(d.State())
 
+		// Track the exit of the init process of the running containers to detect crashes.
+		err = procevents.Start()
+		if err != nil {
+			logger.Warn("Failed to listen to process events, container crashes won't be detected", log.Ctx{"err": err})
+		} else {
+			containersWatchInit(d.State())
+		}
+
+		err = coreDumpsSetup(d.os.ExecPath, coreDumps)
+		if err != nil {
+			logger.Warn("Failed to set up the capture of core dumps", log.Ctx{"err": err})
+		}
+
 		// Setup seccomp handler
 		if d.os.SeccompListener {
 			seccompServer, err := seccomp.NewSeccompServer(d.State(), shared.VarPath("seccomp.socket"), func(pid int32, state *state.State) (seccomp.Instance, error) {
@@ -1053,6 +1170,42 @@ func (d *Daemon) Ready() error {
 
 		// Take snapshot of custom volumes (minutely check of configurable cron expression)
 		d.tasks.Add(autoCreateCustomVolumeSnapshotsTask(d))
+
+		// Remove orphaned artifacts (daily)
+		d.tasks.Add(pruneOrphansTask(d))
+
+		// Check storage pools usage (hourly)
+		d.tasks.Add(checkStoragePoolsUsageTask(d))
+
+		// Suspend idle containers (every 10s)
+		d.tasks.Add(idleCheckTask(d))
+
+		// Report OOM kills and memory pressure in containers (minutely)
+		d.tasks.Add(oomCheckTask(d))
+
+		// Autoscale container limits (every 30s)
+		d.tasks.Add(autoscaleCheckTask(d))
+
+		// Account network usage against container quotas (minutely)
+		d.tasks.Add(networkQuotaCheckTask(d))
+
+		// Remove expired operations from the history (hourly)
+		d.tasks.Add(operationsHistoryPruneTask(d))
+
+		// Propagate host timezone changes to containers (minutely)
+		d.tasks.Add(hostTimezoneSyncTask(d))
+
+		// Refill the warm pools of instances (minutely)
+		d.tasks.Add(warmPoolRefillTask(d))
+
+		// Perform the scheduled actions on instances (minutely)
+		d.tasks.Add(instanceSchedulesTask(d))
+
+		// Sync the replicas of instances on other servers (minutely)
+		d.tasks.Add(instanceReplicationsTask(d))
+
+		// Trim storage pools (minutely check of configurable cron expression)
+		d.tasks.Add(storagePoolsTrimTask(d))
 	}
 
 	// Start all background tasks
@@ -1061,9 +1214,20 @@ func (d *Daemon) Ready() error {
 	// Get daemon state struct
 	s := d.State()
 
+	// Let the drivers snapshot instances before destructive changes
+	instance.SnapshotBeforeChange = func(s *state.State, inst instance.Instance, action string) error {
+		_, err := instanceSnapshotBeforeChange(s, inst, action, nil)
+		return err
+	}
+
 	// Restore containers
 	containersRestart(s)
 
+	// Hold the ports of stopped containers with proxy activation
+	if !d.os.MockMode {
+		proxyActivationSetup(s)
+	}
+
 	// Start monitoring VMs again
 	vmMonitor(s)
 
@@ -1119,6 +1283,14 @@ func (d *Daemon) Stop() error {
 		trackError(d.endpoints.Down())
 	}
 
+	// Hand the core dumps back to the pattern we replaced, as nothing would handle them anymore.
+	if !d.os.MockMode {
+		err := coreDumpsSetup(d.os.ExecPath, false)
+		if err != nil {
+			logger.Warn("Failed to restore the core dump pattern", log.Ctx{"err": err})
+		}
+	}
+
 	trackError(d.tasks.Stop(3 * time.Second))        // Give tasks a bit of time to cleanup.
 	trackError(d.clusterTasks.Stop(3 * time.Second)) // Give tasks a bit of time to cleanup.
 
@@ -1279,6 +1451,34 @@ func (d *Daemon) setupExternalAuthentication(authEndpoint string, authPubkey str
 	return nil
 }
 
+// Setup the authorization webhook, disabling it if the URL is empty.
+func (d *Daemon) setupAuthzWebhook(webhookURL string, timeout int64) {
+	d.authzWebhookLock.Lock()
+	defer d.authzWebhookLock.Unlock()
+
+	if webhookURL == "" {
+		d.authzWebhook = nil
+		return
+	}
+
+	// Look up the proxy on each call so that proxy changes apply to the webhook.
+	proxy := func(req *http.Request) (*url.URL, error) {
+		return d.proxy(req)
+	}
+
+	d.authzWebhook = newAuthzWebhook(webhookURL, timeout, proxy)
+}
+
+// Setup the export of trace spans, disabling it if the endpoint is empty.
+func (d *Daemon) setupTracing(endpoint string) {
+	// Look up the proxy on each call so that proxy changes apply to the exporter.
+	proxy := func(req *http.Request) (*url.URL, error) {
+		return d.proxy(req)
+	}
+
+	tracing.Configure(endpoint, proxy)
+}
+
 // Setup RBAC
 func (d *Daemon) setupRBACServer(rbacURL string, rbacKey string, rbacExpiry int64, rbacAgentURL string, rbacAgentUsername string, rbacAgentPrivateKey string, rbacAgentPublicKey string) error {
 	if d.rbac != nil || rbacURL == "" || rbacAgentURL == "" || rbacAgentUsername == "" || rbacAgentPrivateKey == "" || rbacAgentPublicKey == "" {
@@ -1345,6 +1545,20 @@ func (d *Daemon) userHasPermission(r *http.Request, project string, permission s
 	return d.rbac.HasPermission(r.Context().Value("username").(string), project, permission)
 }
 
+// Setup the BGP speaker, stopping it if either the address or the ASN is unset.
+func (d *Daemon) setupBGP(address string, asn int64, routerID string) error {
+	var routerIP net.IP
+	if routerID != "" {
+		routerIP = net.ParseIP(routerID)
+	}
+
+	if address != "" && asn != 0 {
+		logger.Info("Starting BGP speaker", log.Ctx{"address": address, "asn": asn})
+	}
+
+	return d.bgp.Configure(address, uint32(asn), routerIP)
+}
+
 // Setup MAAS
 func (d *Daemon) setupMAASController(server string, key string, machine string) error {
 	var err error
@@ -14,6 +14,7 @@ import (
 	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/tracing"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -31,6 +32,17 @@ var imagesDownloadingLock sync.Mutex
 
 // ImageDownload resolves the image fingerprint and if not in the database, downloads it
 func (d *Daemon) ImageDownload(op *operations.Operation, server string, protocol string, certificate string, secret string, alias string, imageType string, forContainer bool, autoUpdate bool, storagePool string, preferCached bool, project string) (*api.Image, error) {
+	_, span := tracing.StartChild(op.Context(), "image download")
+	span.SetAttribute("lxd.image.server", server)
+	span.SetAttribute("lxd.image.alias", alias)
+
+	info, err := d.imageDownload(op, server, protocol, certificate, secret, alias, imageType, forContainer, autoUpdate, storagePool, preferCached, project)
+	span.End(err)
+
+	return info, err
+}
+
+func (d *Daemon) imageDownload(op *operations.Operation, server string, protocol string, certificate string, secret string, alias string, imageType string, forContainer bool, autoUpdate bool, storagePool string, preferCached bool, project string) (*api.Image, error) {
 	var err error
 	var ctxMap log.Ctx
 
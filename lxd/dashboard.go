@@ -0,0 +1,319 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/response"
+)
+
+// The dashboard lives outside of the 1.0 API, it's registered on its own under /ui.
+var dashboardCmd = APIEndpoint{
+	Get: APIEndpointAction{Handler: dashboardGet, AccessHandler: allowAuthenticated},
+}
+
+// dashboardGet serves the built-in web dashboard if it's enabled. The page itself only uses the
+// REST API from the browser, so it's subject to the same authentication as any other client.
+func dashboardGet(d *Daemon, r *http.Request) response.Response {
+	enabled := false
+	exec := false
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		config, err := cluster.ConfigLoad(tx)
+		if err != nil {
+			return err
+		}
+
+		enabled = config.Dashboard()
+		exec = config.DashboardExec()
+		return nil
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if !enabled {
+		return response.NotFound(fmt.Errorf("The dashboard is disabled"))
+	}
+
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", dashboardContentSecurityPolicy(r.Host, exec))
+		w.Header().Set("X-Frame-Options", "DENY")
+
+		_, err := w.Write([]byte(dashboardRender(exec)))
+		return err
+	})
+}
+
+// dashboardContentSecurityPolicy returns the policy of the dashboard served on the given host. The
+// page only connects to the server it was served from, over a websocket for the shell.
+func dashboardContentSecurityPolicy(host string, exec bool) string {
+	connect := "'self'"
+	if exec {
+		connect += fmt.Sprintf(" wss://%s", host)
+	}
+
+	return fmt.Sprintf("default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src %s", connect)
+}
+
+// dashboardRender returns the dashboard page, offering a shell in the running instances if exec
+// is true.
+func dashboardRender(exec bool) string {
+	if !exec {
+		return dashboardPage
+	}
+
+	return strings.Replace(dashboardPage, `<body data-exec="false">`, `<body data-exec="true">`, 1)
+}
+
+// dashboardPage is the single page dashboard. It lists the instances of a project with their state
+// and usage, refreshed periodically, and gives access to their console log. With core.dashboard_exec
+// set, it also offers a shell through the terminal framing of the exec API. That's the only action
+// it offers, it doesn't otherwise change the instances.
+const dashboardPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>LXD</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.3em 0.6em; border-bottom: 1px solid #ddd; }
+th { background: #f4f4f4; }
+.Running { color: #0a0; }
+.Stopped { color: #a00; }
+#panel { display: none; margin-top: 1em; }
+#output { background: #111; color: #eee; padding: 0.5em; height: 25em; overflow: auto; white-space: pre-wrap; outline: none; }
+</style>
+</head>
+<body data-exec="false">
+<h1>LXD <small id="server"></small></h1>
+<p>Project: <select id="project"></select> <span id="error"></span></p>
+<table>
+<thead><tr><th>Name</th><th>Type</th><th>Status</th><th>Location</th><th>Addresses</th><th>Processes</th><th>CPU</th><th>Memory</th><th>Network (rx/tx)</th><th></th></tr></thead>
+<tbody id="instances"></tbody>
+</table>
+<div id="panel">
+<h2 id="title"></h2>
+<button id="close">Close</button>
+<div id="output" tabindex="0"></div>
+</div>
+<script>
+"use strict";
+
+var project = new URLSearchParams(window.location.search).get("project") || "default";
+var socket = null;
+
+function api(method, path, body) {
+	var sep = path.indexOf("?") < 0 ? "?" : "&";
+	var opts = {method: method, credentials: "same-origin"};
+	if (body) {
+		opts.body = JSON.stringify(body);
+	}
+
+	return fetch("/1.0" + path + sep + "project=" + encodeURIComponent(project), opts).then(function(resp) {
+		return resp.json();
+	}).then(function(data) {
+		if (data.type === "error") {
+			throw new Error(data.error);
+		}
+
+		return data;
+	});
+}
+
+function human(value, unit) {
+	var units = ["", "k", "M", "G", "T"];
+	var i = 0;
+	while (value >= 1024 && i < units.length - 1) {
+		value /= 1024;
+		i++;
+	}
+
+	return value.toFixed(i ? 1 : 0) + units[i] + unit;
+}
+
+function cell(row, text) {
+	var td = document.createElement("td");
+	td.textContent = text;
+	row.appendChild(td);
+	return td;
+}
+
+function button(td, label, action) {
+	var b = document.createElement("button");
+	b.textContent = label;
+	b.onclick = action;
+	td.appendChild(b);
+}
+
+function showError(err) {
+	document.getElementById("error").textContent = err ? err.message : "";
+}
+
+function refresh() {
+	api("GET", "/instances?recursion=2").then(function(data) {
+		var tbody = document.getElementById("instances");
+		tbody.textContent = "";
+
+		data.metadata.forEach(function(inst) {
+			var state = inst.state || {};
+			var addresses = [];
+			var rx = 0;
+			var tx = 0;
+			Object.keys(state.network || {}).forEach(function(nic) {
+				if (nic === "lo") {
+					return;
+				}
+
+				state.network[nic].addresses.forEach(function(addr) {
+					if (addr.scope === "global") {
+						addresses.push(addr.address);
+					}
+				});
+
+				rx += state.network[nic].counters.bytes_received;
+				tx += state.network[nic].counters.bytes_sent;
+			});
+
+			var row = document.createElement("tr");
+			cell(row, inst.name);
+			cell(row, inst.type);
+			cell(row, inst.status).className = inst.status;
+			cell(row, inst.location);
+			cell(row, addresses.join(", "));
+			cell(row, state.processes > 0 ? state.processes : "");
+			cell(row, state.cpu ? (state.cpu.usage / 1e9).toFixed(1) + "s" : "");
+			cell(row, state.memory ? human(state.memory.usage, "B") : "");
+			cell(row, human(rx, "B") + " / " + human(tx, "B"));
+
+			var actions = cell(row, "");
+			button(actions, "Console log", function() { consoleLog(inst.name); });
+			if (document.body.dataset.exec === "true" && inst.status === "Running") {
+				button(actions, "Shell", function() { shell(inst.name); });
+			}
+
+			tbody.appendChild(row);
+		});
+
+		showError(null);
+	}).catch(showError);
+}
+
+function openPanel(title) {
+	closePanel();
+	document.getElementById("title").textContent = title;
+	document.getElementById("output").textContent = "";
+	document.getElementById("panel").style.display = "block";
+}
+
+function closePanel() {
+	if (socket) {
+		socket.close();
+		socket = null;
+	}
+
+	document.getElementById("panel").style.display = "none";
+}
+
+function write(text) {
+	var output = document.getElementById("output");
+	// Escape sequences aren't interpreted, drop them.
+	output.textContent += text.replace(/\x1b\[[0-9;?]*[A-Za-z]|\x1b\][^\x07]*\x07|\r/g, "");
+	output.scrollTop = output.scrollHeight;
+}
+
+function consoleLog(name) {
+	openPanel(name + " console log");
+	fetch("/1.0/instances/" + encodeURIComponent(name) + "/console?project=" + encodeURIComponent(project), {credentials: "same-origin"}).then(function(resp) {
+		return resp.text();
+	}).then(write).catch(showError);
+}
+
+var keys = {Enter: "\r", Backspace: "\x7f", Tab: "\t", Escape: "\x1b", ArrowUp: "\x1b[A", ArrowDown: "\x1b[B", ArrowRight: "\x1b[C", ArrowLeft: "\x1b[D"};
+
+function shell(name) {
+	openPanel(name + " shell");
+
+	var req = {
+		"command": ["/bin/sh", "-c", "if command -v bash >/dev/null; then exec bash -l; fi; exec sh -l"],
+		"environment": {"TERM": "dumb"},
+		"interactive": true,
+		"wait-for-websocket": true,
+		"framing": "terminal",
+		"width": 120,
+		"height": 40
+	};
+
+	api("POST", "/instances/" + encodeURIComponent(name) + "/exec", req).then(function(data) {
+		var scheme = window.location.protocol === "https:" ? "wss://" : "ws://";
+		var url = scheme + window.location.host + data.operation + "/websocket?secret=" + data.metadata.metadata.fds["0"];
+		var decoder = new TextDecoder();
+		var encoder = new TextEncoder();
+
+		socket = new WebSocket(url);
+		socket.binaryType = "arraybuffer";
+		socket.onmessage = function(ev) {
+			write(decoder.decode(ev.data, {stream: true}));
+		};
+
+		socket.onclose = function() {
+			write("\n[session closed]\n");
+		};
+
+		var output = document.getElementById("output");
+		output.onkeydown = function(ev) {
+			if (!socket || socket.readyState !== WebSocket.OPEN) {
+				return;
+			}
+
+			var data = keys[ev.key];
+			if (ev.ctrlKey && /^[a-z]$/i.test(ev.key)) {
+				data = String.fromCharCode(ev.key.toUpperCase().charCodeAt(0) - 64);
+			} else if (!data && ev.key.length === 1) {
+				data = ev.key;
+			}
+
+			if (data) {
+				socket.send(encoder.encode(data));
+				ev.preventDefault();
+			}
+		};
+
+		output.focus();
+	}).catch(showError);
+}
+
+document.getElementById("close").onclick = closePanel;
+
+document.getElementById("project").onchange = function(ev) {
+	window.location.search = "?project=" + encodeURIComponent(ev.target.value);
+};
+
+fetch("/1.0", {credentials: "same-origin"}).then(function(resp) {
+	return resp.json();
+}).then(function(data) {
+	document.getElementById("server").textContent = data.metadata.environment.server_name;
+});
+
+fetch("/1.0/projects?recursion=1", {credentials: "same-origin"}).then(function(resp) {
+	return resp.json();
+}).then(function(data) {
+	var select = document.getElementById("project");
+	(data.metadata || [{name: "default"}]).forEach(function(p) {
+		var option = document.createElement("option");
+		option.textContent = p.name;
+		option.selected = p.name === project;
+		select.appendChild(option);
+	});
+});
+
+refresh();
+setInterval(refresh, 5000);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The page only connects back to the server, and only over a websocket if it offers a shell.
+func TestDashboardContentSecurityPolicy(t *testing.T) {
+	policy := dashboardContentSecurityPolicy("lxd.example.com:8443", false)
+	assert.Equal(t, "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src 'self'", policy)
+
+	policy = dashboardContentSecurityPolicy("lxd.example.com:8443", true)
+	assert.Equal(t, "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src 'self' wss://lxd.example.com:8443", policy)
+}
+
+func TestDashboardRender(t *testing.T) {
+	assert.Contains(t, dashboardRender(false), `<body data-exec="false">`)
+	assert.NotContains(t, dashboardRender(false), `data-exec="true"`)
+
+	assert.Contains(t, dashboardRender(true), `<body data-exec="true">`)
+}
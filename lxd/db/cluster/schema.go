@@ -14,6 +14,12 @@ CREATE TABLE certificates (
     certificate TEXT NOT NULL,
     UNIQUE (fingerprint)
 );
+CREATE TABLE cluster_groups (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    UNIQUE (name)
+);
 CREATE TABLE config (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     key TEXT NOT NULL,
@@ -48,6 +54,15 @@ CREATE TABLE "images_aliases" (
     FOREIGN KEY (image_id) REFERENCES images (id) ON DELETE CASCADE,
     FOREIGN KEY (project_id) REFERENCES projects (id) ON DELETE CASCADE
 );
+CREATE TABLE images_aliases_channels (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    image_alias_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    image_id INTEGER NOT NULL,
+    UNIQUE (image_alias_id, name),
+    FOREIGN KEY (image_alias_id) REFERENCES images_aliases (id) ON DELETE CASCADE,
+    FOREIGN KEY (image_id) REFERENCES images (id) ON DELETE CASCADE
+);
 CREATE INDEX images_aliases_project_id_idx ON images_aliases (project_id);
 CREATE TABLE images_nodes (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
@@ -107,6 +122,7 @@ CREATE TABLE "instances_backups" (
     expiry_date DATETIME,
     container_only INTEGER NOT NULL default 0,
     optimized_storage INTEGER NOT NULL default 0,
+    parent_id INTEGER REFERENCES instances_backups (id),
     FOREIGN KEY (instance_id) REFERENCES "instances" (id) ON DELETE CASCADE,
     UNIQUE (instance_id, name)
 );
@@ -169,6 +185,41 @@ CREATE VIEW instances_devices_ref (project,
      JOIN instances ON instances.id=instances_devices.instance_id
      JOIN projects ON projects.id=instances.project_id
      JOIN nodes ON nodes.id=instances.node_id;
+CREATE TABLE instances_groups (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    project_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    UNIQUE (project_id, name),
+    FOREIGN KEY (project_id) REFERENCES projects (id) ON DELETE CASCADE
+);
+CREATE TABLE instances_groups_config (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    group_id INTEGER NOT NULL,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    UNIQUE (group_id, key),
+    FOREIGN KEY (group_id) REFERENCES instances_groups (id) ON DELETE CASCADE
+);
+CREATE TABLE instances_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    instance_id INTEGER NOT NULL,
+    date DATETIME NOT NULL,
+    action TEXT NOT NULL,
+    operation TEXT NOT NULL DEFAULT '',
+    requestor TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (instance_id) REFERENCES instances (id) ON DELETE CASCADE
+);
+CREATE INDEX instances_history_instance_id_idx ON instances_history (instance_id);
+CREATE TABLE instances_network_usage (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    instance_id INTEGER NOT NULL,
+    period TEXT NOT NULL,
+    usage INTEGER NOT NULL,
+    counter INTEGER NOT NULL,
+    UNIQUE (instance_id),
+    FOREIGN KEY (instance_id) REFERENCES instances (id) ON DELETE CASCADE
+);
 CREATE INDEX instances_node_id_idx ON instances (node_id);
 CREATE TABLE "instances_profiles" (
     id INTEGER primary key AUTOINCREMENT NOT NULL,
@@ -201,6 +252,31 @@ CREATE INDEX instances_project_id_and_node_id_and_name_idx ON instances (project
 CREATE INDEX instances_project_id_and_node_id_idx ON instances (project_id,
     node_id);
 CREATE INDEX instances_project_id_idx ON instances (project_id);
+CREATE TABLE instances_replications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    instance_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    target TEXT NOT NULL,
+    target_certificate TEXT NOT NULL DEFAULT '',
+    target_project TEXT NOT NULL DEFAULT 'default',
+    interval INTEGER NOT NULL,
+    snapshots TEXT NOT NULL DEFAULT '',
+    last_sync_at DATETIME NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    UNIQUE (instance_id, name),
+    FOREIGN KEY (instance_id) REFERENCES instances (id) ON DELETE CASCADE
+);
+CREATE TABLE instances_schedules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    instance_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    action TEXT NOT NULL,
+    schedule TEXT NOT NULL,
+    UNIQUE (instance_id, name),
+    FOREIGN KEY (instance_id) REFERENCES instances (id) ON DELETE CASCADE
+);
 CREATE TABLE instances_snapshots (
     id INTEGER primary key AUTOINCREMENT NOT NULL,
     instance_id INTEGER NOT NULL,
@@ -209,6 +285,7 @@ CREATE TABLE instances_snapshots (
     stateful INTEGER NOT NULL DEFAULT 0,
     description TEXT,
     expiry_date DATETIME,
+    scheduled INTEGER NOT NULL DEFAULT 0,
     UNIQUE (instance_id, name),
     FOREIGN KEY (instance_id) REFERENCES instances (id) ON DELETE CASCADE
 );
@@ -301,6 +378,28 @@ CREATE TABLE networks_nodes (
     FOREIGN KEY (network_id) REFERENCES networks (id) ON DELETE CASCADE,
     FOREIGN KEY (node_id) REFERENCES nodes (id) ON DELETE CASCADE
 );
+CREATE TABLE networks_peers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    network_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    target_network_id INTEGER NOT NULL,
+    UNIQUE (network_id, name),
+    UNIQUE (network_id, target_network_id),
+    FOREIGN KEY (network_id) REFERENCES networks (id) ON DELETE CASCADE,
+    FOREIGN KEY (target_network_id) REFERENCES networks (id) ON DELETE CASCADE
+);
+CREATE TABLE networks_reservations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    network_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    ipv4_address TEXT NOT NULL DEFAULT '',
+    ipv6_address TEXT NOT NULL DEFAULT '',
+    instance_id INTEGER REFERENCES instances (id) ON DELETE SET NULL,
+    UNIQUE (network_id, name),
+    FOREIGN KEY (network_id) REFERENCES networks (id) ON DELETE CASCADE
+);
 CREATE TABLE nodes (
     id INTEGER PRIMARY KEY,
     name TEXT NOT NULL,
@@ -314,6 +413,14 @@ CREATE TABLE nodes (
     UNIQUE (name),
     UNIQUE (address)
 );
+CREATE TABLE nodes_cluster_groups (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    node_id INTEGER NOT NULL,
+    group_id INTEGER NOT NULL,
+    UNIQUE (node_id, group_id),
+    FOREIGN KEY (node_id) REFERENCES nodes (id) ON DELETE CASCADE,
+    FOREIGN KEY (group_id) REFERENCES cluster_groups (id) ON DELETE CASCADE
+);
 CREATE TABLE nodes_roles (
     node_id INTEGER NOT NULL,
     role INTEGER NOT NULL,
@@ -330,6 +437,17 @@ CREATE TABLE "operations" (
     FOREIGN KEY (node_id) REFERENCES nodes (id) ON DELETE CASCADE,
     FOREIGN KEY (project_id) REFERENCES projects (id) ON DELETE CASCADE
 );
+CREATE TABLE operations_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    uuid TEXT NOT NULL,
+    project TEXT NOT NULL DEFAULT '',
+    type TEXT NOT NULL,
+    requestor TEXT NOT NULL DEFAULT '',
+    finished_at DATETIME NOT NULL,
+    operation TEXT NOT NULL,
+    UNIQUE (uuid)
+);
+CREATE INDEX operations_history_finished_at_idx ON operations_history (finished_at);
 CREATE TABLE "profiles" (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     name TEXT NOT NULL,
@@ -430,6 +548,14 @@ CREATE VIEW projects_config_ref (name,
     projects_config.value
      FROM projects_config
      JOIN projects ON projects.id=projects_config.project_id;
+CREATE TABLE projects_network_usage (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    project_id INTEGER NOT NULL,
+    period TEXT NOT NULL,
+    usage INTEGER NOT NULL,
+    UNIQUE (project_id, period),
+    FOREIGN KEY (project_id) REFERENCES projects (id) ON DELETE CASCADE
+);
 CREATE VIEW projects_used_by_ref (name,
     value) AS
   SELECT projects.name,
@@ -552,6 +678,23 @@ CREATE TABLE storage_volumes_snapshots_config (
     FOREIGN KEY (storage_volume_snapshot_id) REFERENCES storage_volumes_snapshots (id) ON DELETE CASCADE,
     UNIQUE (storage_volume_snapshot_id, key)
 );
+CREATE TABLE warnings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    uuid TEXT NOT NULL,
+    node_id INTEGER NOT NULL,
+    project_id INTEGER,
+    entity TEXT NOT NULL DEFAULT '',
+    type TEXT NOT NULL,
+    severity TEXT NOT NULL,
+    status TEXT NOT NULL,
+    message TEXT NOT NULL,
+    count INTEGER NOT NULL,
+    first_seen_date DATETIME NOT NULL,
+    last_seen_date DATETIME NOT NULL,
+    UNIQUE (uuid),
+    FOREIGN KEY (node_id) REFERENCES nodes (id) ON DELETE CASCADE,
+    FOREIGN KEY (project_id) REFERENCES projects (id) ON DELETE CASCADE
+);
 
-INSERT INTO schema (version, updated_at) VALUES (28, strftime("%s"))
+INSERT INTO schema (version, updated_at) VALUES (42, strftime("%s"))
 `
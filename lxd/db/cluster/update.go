@@ -65,6 +65,269 @@ var updates = map[int]schema.Update{
 	26: updateFromV25,
 	27: updateFromV26,
 	28: updateFromV27,
+	29: updateFromV28,
+	30: updateFromV29,
+	31: updateFromV30,
+	32: updateFromV31,
+	33: updateFromV32,
+	34: updateFromV33,
+	35: updateFromV34,
+	36: updateFromV35,
+	37: updateFromV36,
+	38: updateFromV37,
+	39: updateFromV38,
+	40: updateFromV39,
+	41: updateFromV40,
+	42: updateFromV41,
+}
+
+// Add a column flagging the instance snapshots taken on schedule, which are the only ones
+// snapshots.retention applies to.
+func updateFromV41(tx *sql.Tx) error {
+	_, err := tx.Exec("ALTER TABLE instances_snapshots ADD COLUMN scheduled INTEGER NOT NULL DEFAULT 0")
+	return err
+}
+
+// Add tables recording the network usage of instances and projects, which used to be kept in
+// volatile keys of the instances.
+func updateFromV40(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE instances_network_usage (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    instance_id INTEGER NOT NULL,
+    period TEXT NOT NULL,
+    usage INTEGER NOT NULL,
+    counter INTEGER NOT NULL,
+    UNIQUE (instance_id),
+    FOREIGN KEY (instance_id) REFERENCES instances (id) ON DELETE CASCADE
+);
+CREATE TABLE projects_network_usage (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    project_id INTEGER NOT NULL,
+    period TEXT NOT NULL,
+    usage INTEGER NOT NULL,
+    UNIQUE (project_id, period),
+    FOREIGN KEY (project_id) REFERENCES projects (id) ON DELETE CASCADE
+);
+DELETE FROM instances_config WHERE key IN ('volatile.network.counter', 'volatile.network.period', 'volatile.network.usage');
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+// Add a column recording the instance using a network address reservation.
+func updateFromV39(tx *sql.Tx) error {
+	_, err := tx.Exec("ALTER TABLE networks_reservations ADD COLUMN instance_id INTEGER REFERENCES instances (id) ON DELETE SET NULL")
+	return err
+}
+
+// Add a column referencing the backup an incremental backup is based upon.
+func updateFromV38(tx *sql.Tx) error {
+	_, err := tx.Exec("ALTER TABLE instances_backups ADD COLUMN parent_id INTEGER REFERENCES instances_backups (id)")
+	return err
+}
+
+// Add a table holding the replications of instances to other servers.
+func updateFromV37(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE instances_replications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    instance_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    target TEXT NOT NULL,
+    target_certificate TEXT NOT NULL DEFAULT '',
+    target_project TEXT NOT NULL DEFAULT 'default',
+    interval INTEGER NOT NULL,
+    snapshots TEXT NOT NULL DEFAULT '',
+    last_sync_at DATETIME NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    UNIQUE (instance_id, name),
+    FOREIGN KEY (instance_id) REFERENCES instances (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+// Add tables holding named groups of cluster members.
+func updateFromV36(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE cluster_groups (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    UNIQUE (name)
+);
+CREATE TABLE nodes_cluster_groups (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    node_id INTEGER NOT NULL,
+    group_id INTEGER NOT NULL,
+    UNIQUE (node_id, group_id),
+    FOREIGN KEY (node_id) REFERENCES nodes (id) ON DELETE CASCADE,
+    FOREIGN KEY (group_id) REFERENCES cluster_groups (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+// Add tables holding named groups of instances and their config.
+func updateFromV35(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE instances_groups (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    project_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    UNIQUE (project_id, name),
+    FOREIGN KEY (project_id) REFERENCES projects (id) ON DELETE CASCADE
+);
+CREATE TABLE instances_groups_config (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    group_id INTEGER NOT NULL,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    UNIQUE (group_id, key),
+    FOREIGN KEY (group_id) REFERENCES instances_groups (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+// Add a table holding the schedules of start, stop and snapshot actions on instances.
+func updateFromV34(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE instances_schedules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    instance_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    action TEXT NOT NULL,
+    schedule TEXT NOT NULL,
+    UNIQUE (instance_id, name),
+    FOREIGN KEY (instance_id) REFERENCES instances (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+// Add a table keeping completed operations around after they're gone from memory.
+func updateFromV33(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE operations_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    uuid TEXT NOT NULL,
+    project TEXT NOT NULL DEFAULT '',
+    type TEXT NOT NULL,
+    requestor TEXT NOT NULL DEFAULT '',
+    finished_at DATETIME NOT NULL,
+    operation TEXT NOT NULL,
+    UNIQUE (uuid)
+);
+CREATE INDEX operations_history_finished_at_idx ON operations_history (finished_at);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+// Add a table holding the channels of image aliases.
+func updateFromV32(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE images_aliases_channels (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    image_alias_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    image_id INTEGER NOT NULL,
+    UNIQUE (image_alias_id, name),
+    FOREIGN KEY (image_alias_id) REFERENCES images_aliases (id) ON DELETE CASCADE,
+    FOREIGN KEY (image_id) REFERENCES images (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+// Add a table holding the peerings between networks.
+func updateFromV31(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE networks_peers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    network_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    target_network_id INTEGER NOT NULL,
+    UNIQUE (network_id, name),
+    UNIQUE (network_id, target_network_id),
+    FOREIGN KEY (network_id) REFERENCES networks (id) ON DELETE CASCADE,
+    FOREIGN KEY (target_network_id) REFERENCES networks (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+// Add a table holding the addresses reserved on networks.
+func updateFromV30(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE networks_reservations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    network_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    ipv4_address TEXT NOT NULL DEFAULT '',
+    ipv6_address TEXT NOT NULL DEFAULT '',
+    UNIQUE (network_id, name),
+    FOREIGN KEY (network_id) REFERENCES networks (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+// Add a table holding warnings about degraded conditions.
+func updateFromV29(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE warnings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    uuid TEXT NOT NULL,
+    node_id INTEGER NOT NULL,
+    project_id INTEGER,
+    entity TEXT NOT NULL DEFAULT '',
+    type TEXT NOT NULL,
+    severity TEXT NOT NULL,
+    status TEXT NOT NULL,
+    message TEXT NOT NULL,
+    count INTEGER NOT NULL,
+    first_seen_date DATETIME NOT NULL,
+    last_seen_date DATETIME NOT NULL,
+    UNIQUE (uuid),
+    FOREIGN KEY (node_id) REFERENCES nodes (id) ON DELETE CASCADE,
+    FOREIGN KEY (project_id) REFERENCES projects (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+// Add a table recording the state changes of instances.
+func updateFromV28(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE instances_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    instance_id INTEGER NOT NULL,
+    date DATETIME NOT NULL,
+    action TEXT NOT NULL,
+    operation TEXT NOT NULL DEFAULT '',
+    requestor TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (instance_id) REFERENCES instances (id) ON DELETE CASCADE
+);
+CREATE INDEX instances_history_instance_id_idx ON instances_history (instance_id);
+`
+	_, err := tx.Exec(stmt)
+	return err
 }
 
 // Add expiry date to storage volume snapshots
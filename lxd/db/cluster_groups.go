@@ -0,0 +1,154 @@
+// +build linux,cgo,!agent
+
+package db
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db/query"
+)
+
+// ClusterGroup holds a named group of cluster members, such as the members in the same rack or
+// availability zone.
+type ClusterGroup struct {
+	ID          int64
+	Name        string
+	Description string
+	Members     []string // Names of the cluster members in the group
+}
+
+// ClusterGroups returns all the cluster groups.
+func (c *ClusterTx) ClusterGroups() ([]ClusterGroup, error) {
+	return c.clusterGroups("")
+}
+
+// ClusterGroupGet returns the cluster group with the given name.
+func (c *ClusterTx) ClusterGroupGet(name string) (ClusterGroup, error) {
+	null := ClusterGroup{}
+	groups, err := c.clusterGroups("WHERE cluster_groups.name = ?", name)
+	if err != nil {
+		return null, err
+	}
+
+	switch len(groups) {
+	case 0:
+		return null, ErrNoSuchObject
+	case 1:
+		return groups[0], nil
+	default:
+		return null, fmt.Errorf("More than one cluster group matches")
+	}
+}
+
+// ClusterGroupCreate adds a new cluster group with the given members.
+func (c *ClusterTx) ClusterGroupCreate(name string, description string, members []string) (int64, error) {
+	columns := []string{"name", "description"}
+	values := []interface{}{name, description}
+	id, err := query.UpsertObject(c.tx, "cluster_groups", columns, values)
+	if err != nil {
+		return -1, err
+	}
+
+	err = c.clusterGroupMembersAdd(id, members)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// ClusterGroupUpdate replaces the description and the members of the cluster group with the given
+// ID.
+func (c *ClusterTx) ClusterGroupUpdate(id int64, description string, members []string) error {
+	_, err := c.tx.Exec("UPDATE cluster_groups SET description = ? WHERE id = ?", description, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.tx.Exec("DELETE FROM nodes_cluster_groups WHERE group_id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	return c.clusterGroupMembersAdd(id, members)
+}
+
+// ClusterGroupDelete removes the cluster group with the given ID.
+func (c *ClusterTx) ClusterGroupDelete(id int64) error {
+	deleted, err := query.DeleteObject(c.tx, "cluster_groups", id)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return ErrNoSuchObject
+	}
+
+	return nil
+}
+
+func (c *ClusterTx) clusterGroupMembersAdd(id int64, members []string) error {
+	for _, member := range members {
+		result, err := c.tx.Exec(`
+INSERT INTO nodes_cluster_groups (node_id, group_id)
+  SELECT nodes.id, ? FROM nodes WHERE nodes.name = ?
+`, id, member)
+		if err != nil {
+			return err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if n != 1 {
+			return fmt.Errorf("Cluster member %q not found", member)
+		}
+	}
+
+	return nil
+}
+
+// Return the cluster groups, filtered by the given clause.
+func (c *ClusterTx) clusterGroups(where string, args ...interface{}) ([]ClusterGroup, error) {
+	groups := []ClusterGroup{}
+	dest := func(i int) []interface{} {
+		groups = append(groups, ClusterGroup{})
+		return []interface{}{
+			&groups[i].ID,
+			&groups[i].Name,
+			&groups[i].Description,
+		}
+	}
+
+	stmt, err := c.tx.Prepare(fmt.Sprintf(`
+SELECT cluster_groups.id, cluster_groups.name, cluster_groups.description
+  FROM cluster_groups
+ %s
+ ORDER BY cluster_groups.name`, where))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	err = query.SelectObjects(stmt, dest, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch cluster groups")
+	}
+
+	for i := range groups {
+		groups[i].Members, err = query.SelectStrings(c.tx, `
+SELECT nodes.name FROM nodes
+  JOIN nodes_cluster_groups ON nodes_cluster_groups.node_id = nodes.id
+ WHERE nodes_cluster_groups.group_id = ?
+ ORDER BY nodes.name`, groups[i].ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to fetch cluster group members")
+		}
+	}
+
+	return groups, nil
+}
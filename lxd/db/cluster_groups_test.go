@@ -0,0 +1,45 @@
+// +build linux,cgo,!agent
+
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Add, update and delete cluster groups.
+func TestClusterGroups(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	_, err := tx.NodeAdd("node2", "1.2.3.4:666")
+	require.NoError(t, err)
+
+	id, err := tx.ClusterGroupCreate("rack1", "First rack", []string{"none", "node2"})
+	require.NoError(t, err)
+
+	group, err := tx.ClusterGroupGet("rack1")
+	require.NoError(t, err)
+	assert.Equal(t, "First rack", group.Description)
+	assert.Equal(t, []string{"node2", "none"}, group.Members)
+
+	err = tx.ClusterGroupUpdate(id, "", []string{"node2"})
+	require.NoError(t, err)
+
+	groups, err := tx.ClusterGroups()
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	assert.Equal(t, []string{"node2"}, groups[0].Members)
+
+	_, err = tx.ClusterGroupCreate("rack2", "", []string{"missing"})
+	assert.EqualError(t, err, `Cluster member "missing" not found`)
+
+	err = tx.ClusterGroupDelete(id)
+	require.NoError(t, err)
+
+	_, err = tx.ClusterGroupGet("rack1")
+	assert.Equal(t, db.ErrNoSuchObject, err)
+}
@@ -167,6 +167,7 @@ type InstanceBackupArgs struct {
 	InstanceOnly         bool
 	OptimizedStorage     bool
 	CompressionAlgorithm string
+	Parent               string // Name of the backup an incremental backup is based upon
 }
 
 // InstanceNames returns the names of all containers the given project.
@@ -179,6 +180,69 @@ SELECT instances.name FROM instances
 	return query.SelectStrings(c.tx, stmt, project, instancetype.Any)
 }
 
+// InstanceNamesWithConfig returns the names of the instances of the given
+// project whose config, or one of whose profiles, sets the given key to the
+// given value. The caller must check the expanded config of the instances,
+// since their own config may override the profiles.
+func (c *ClusterTx) InstanceNamesWithConfig(project string, key string, value string) ([]string, error) {
+	stmt := `
+SELECT instances.name FROM instances
+  JOIN projects ON projects.id = instances.project_id
+  WHERE projects.name = ? AND instances.id IN (
+    SELECT instance_id FROM instances_config WHERE key = ? AND value = ?
+    UNION
+    SELECT instances_profiles.instance_id FROM instances_profiles
+      JOIN profiles_config ON profiles_config.profile_id = instances_profiles.profile_id
+      WHERE profiles_config.key = ? AND profiles_config.value = ?)
+`
+	return query.SelectStrings(c.tx, stmt, project, key, value, key, value)
+}
+
+// LocalInstanceIDsWithConfigKeys returns the IDs of the instances of this node
+// whose own config sets any of the given keys.
+func (c *ClusterTx) LocalInstanceIDsWithConfigKeys(keys ...string) ([]int, error) {
+	stmt := fmt.Sprintf(`
+SELECT DISTINCT instances.id FROM instances
+  JOIN instances_config ON instances_config.instance_id = instances.id
+  WHERE instances.node_id = ? AND instances_config.key IN %s
+`, query.Params(len(keys)))
+
+	args := []interface{}{c.nodeID}
+	for _, key := range keys {
+		args = append(args, key)
+	}
+
+	return query.SelectIntegers(c.tx, stmt, args...)
+}
+
+// LocalInstanceIDsWithExpandedConfigKeys returns the IDs of the instances of
+// this node which set at least one of the given config keys, either directly
+// or through one of their profiles.
+func (c *ClusterTx) LocalInstanceIDsWithExpandedConfigKeys(keys ...string) ([]int, error) {
+	stmt := fmt.Sprintf(`
+SELECT instances.id FROM instances
+  JOIN instances_config ON instances_config.instance_id = instances.id
+  WHERE instances.node_id = ? AND instances_config.key IN %s
+UNION
+SELECT instances.id FROM instances
+  JOIN instances_profiles ON instances_profiles.instance_id = instances.id
+  JOIN profiles_config ON profiles_config.profile_id = instances_profiles.profile_id
+  WHERE instances.node_id = ? AND profiles_config.key IN %s
+`, query.Params(len(keys)), query.Params(len(keys)))
+
+	args := []interface{}{c.nodeID}
+	for _, key := range keys {
+		args = append(args, key)
+	}
+
+	args = append(args, c.nodeID)
+	for _, key := range keys {
+		args = append(args, key)
+	}
+
+	return query.SelectIntegers(c.tx, stmt, args...)
+}
+
 // ContainerNodeAddress returns the address of the node hosting the container
 // with the given name in the given project.
 //
@@ -345,10 +409,20 @@ func (c *ClusterTx) instanceListExpanded() ([]Instance, error) {
 		return nil, errors.Wrap(err, "Load projects")
 	}
 
-	// Map to check which projects have the profiles features on.
-	projectHasProfiles := map[string]bool{}
+	// Map of the project providing the profiles of each project.
+	projectConfigs := map[string]map[string]string{}
 	for _, project := range projects {
-		projectHasProfiles[project.Name] = shared.IsTrue(project.Config["features.profiles"])
+		projectConfigs[project.Name] = project.Config
+	}
+
+	profilesProjects := map[string]string{}
+	for _, project := range projects {
+		profilesProjects[project.Name], err = projectFeatureResolve(project.Name, "features.profiles", func(name string) (map[string]string, error) {
+			return projectConfigs[name], nil
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	profiles, err := c.ProfileList(ProfileFilter{})
@@ -370,11 +444,10 @@ func (c *ClusterTx) instanceListExpanded() ([]Instance, error) {
 	for i, instance := range instances {
 		profiles := make([]api.Profile, len(instance.Profiles))
 
-		profilesProject := instance.Project
-
 		// If the instance's project does not have the profiles feature
-		// enable, we fall back to the default project.
-		if !projectHasProfiles[profilesProject] {
+		// enabled, we fall back to its parent or the default project.
+		profilesProject, ok := profilesProjects[instance.Project]
+		if !ok {
 			profilesProject = "default"
 		}
 
@@ -384,7 +457,7 @@ func (c *ClusterTx) instanceListExpanded() ([]Instance, error) {
 		}
 
 		instances[i].Config = ProfilesExpandConfig(instance.Config, profiles)
-		instances[i].Devices = ProfilesExpandDevices(deviceConfig.NewDevices(instance.Devices), profiles).CloneNative()
+		instances[i].Devices = ProfilesExpandDevices(deviceConfig.NewDevices(instance.Devices), profiles, instance.Config).CloneNative()
 	}
 
 	return instances, nil
@@ -727,13 +800,11 @@ func (c *Cluster) ContainerSetStateful(id int, stateful bool) error {
 // ContainerProfilesInsert associates the container with the given ID with the
 // profiles with the given names in the given project.
 func ContainerProfilesInsert(tx *sql.Tx, id int, project string, profiles []string) error {
-	enabled, err := projectHasProfiles(tx, project)
+	profilesProject, err := projectFeatureProject(tx, project, "features.profiles")
 	if err != nil {
 		return errors.Wrap(err, "Check if project has profiles")
 	}
-	if !enabled {
-		project = "default"
-	}
+	project = profilesProject
 
 	applyOrder := 1
 	str := `
@@ -1160,15 +1231,17 @@ func (c *Cluster) ContainerGetBackup(project, name string) (InstanceBackupArgs,
 	q := `
 SELECT instances_backups.id, instances_backups.instance_id,
        instances_backups.creation_date, instances_backups.expiry_date,
-       instances_backups.container_only, instances_backups.optimized_storage
+       instances_backups.container_only, instances_backups.optimized_storage,
+       COALESCE(parents.name, '')
     FROM instances_backups
     JOIN instances ON instances.id=instances_backups.instance_id
     JOIN projects ON projects.id=instances.project_id
+    LEFT JOIN instances_backups AS parents ON parents.id=instances_backups.parent_id
     WHERE projects.name=? AND instances_backups.name=?
 `
 	arg1 := []interface{}{project, name}
 	arg2 := []interface{}{&args.ID, &args.InstanceID, &args.CreationDate,
-		&args.ExpiryDate, &instanceOnlyInt, &optimizedStorageInt}
+		&args.ExpiryDate, &instanceOnlyInt, &optimizedStorageInt, &args.Parent}
 	err := dbQueryRowScan(c.db, q, arg1, arg2)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -1190,14 +1263,15 @@ SELECT instances_backups.id, instances_backups.instance_id,
 }
 
 // ContainerGetBackups returns the names of all backups of the container
-// with the given name.
+// with the given name, from the oldest to the newest.
 func (c *Cluster) ContainerGetBackups(project, name string) ([]string, error) {
 	var result []string
 
 	q := `SELECT instances_backups.name FROM instances_backups
 JOIN instances ON instances_backups.instance_id=instances.id
 JOIN projects ON projects.id=instances.project_id
-WHERE projects.name=? AND instances.name=?`
+WHERE projects.name=? AND instances.name=?
+ORDER BY instances_backups.id`
 	inargs := []interface{}{project, name}
 	outfmt := []interface{}{name}
 	dbResults, err := queryScan(c.db, q, inargs, outfmt)
@@ -1219,6 +1293,14 @@ func (c *Cluster) InstanceBackupCreate(args InstanceBackupArgs) error {
 		return ErrAlreadyDefined
 	}
 
+	var parentID interface{}
+	if args.Parent != "" {
+		parentID, err = c.ContainerBackupID(args.Parent)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = c.Transaction(func(tx *ClusterTx) error {
 		instanceOnlyInt := 0
 		if args.InstanceOnly {
@@ -1230,7 +1312,7 @@ func (c *Cluster) InstanceBackupCreate(args InstanceBackupArgs) error {
 			optimizedStorageInt = 1
 		}
 
-		str := fmt.Sprintf("INSERT INTO instances_backups (instance_id, name, creation_date, expiry_date, container_only, optimized_storage) VALUES (?, ?, ?, ?, ?, ?)")
+		str := fmt.Sprintf("INSERT INTO instances_backups (instance_id, name, creation_date, expiry_date, container_only, optimized_storage, parent_id) VALUES (?, ?, ?, ?, ?, ?, ?)")
 		stmt, err := tx.tx.Prepare(str)
 		if err != nil {
 			return err
@@ -1238,7 +1320,7 @@ func (c *Cluster) InstanceBackupCreate(args InstanceBackupArgs) error {
 		defer stmt.Close()
 		result, err := stmt.Exec(args.InstanceID, args.Name,
 			args.CreationDate.Unix(), args.ExpiryDate.Unix(), instanceOnlyInt,
-			optimizedStorageInt)
+			optimizedStorageInt, parentID)
 		if err != nil {
 			return err
 		}
@@ -1254,6 +1336,28 @@ func (c *Cluster) InstanceBackupCreate(args InstanceBackupArgs) error {
 	return err
 }
 
+// ContainerBackupChildren returns the names of the incremental backups based upon the backup with
+// the given name.
+func (c *Cluster) ContainerBackupChildren(name string) ([]string, error) {
+	var result []string
+
+	q := `SELECT instances_backups.name FROM instances_backups
+JOIN instances_backups AS parents ON parents.id=instances_backups.parent_id
+WHERE parents.name=?`
+	inargs := []interface{}{name}
+	outfmt := []interface{}{name}
+	dbResults, err := queryScan(c.db, q, inargs, outfmt)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range dbResults {
+		result = append(result, r[0].(string))
+	}
+
+	return result, nil
+}
+
 // InstanceBackupRemove removes the container backup with the given name from the database.
 func (c *Cluster) InstanceBackupRemove(name string) error {
 	id, err := c.ContainerBackupID(name)
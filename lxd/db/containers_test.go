@@ -349,6 +349,54 @@ func TestContainersByNodeName(t *testing.T) {
 		}, result)
 }
 
+// Only the local instances setting one of the keys are returned.
+func TestLocalInstanceIDsWithConfigKeys(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	nodeID2, err := tx.NodeAdd("node2", "1.2.3.4:666")
+	require.NoError(t, err)
+
+	addContainer(t, tx, 1, "c1")
+	addContainer(t, tx, 1, "c2")
+	addContainer(t, tx, 1, "c3")
+	addContainer(t, tx, nodeID2, "c4")
+	addContainerConfig(t, tx, "c1", "warm_pool.size", "2")
+	addContainerConfig(t, tx, "c2", "volatile.warm_pool.template", "c1")
+	addContainerConfig(t, tx, "c3", "user.foo", "bar")
+	addContainerConfig(t, tx, "c4", "warm_pool.size", "1")
+
+	ids, err := tx.LocalInstanceIDsWithConfigKeys("warm_pool.size", "volatile.warm_pool.template")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int{int(getContainerID(t, tx, "c1")), int(getContainerID(t, tx, "c2"))}, ids)
+}
+
+// Instances setting one of the keys through a profile are returned as well.
+func TestLocalInstanceIDsWithExpandedConfigKeys(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	profileID, err := tx.ProfileCreate(db.Profile{
+		Project: "default",
+		Name:    "idle",
+		Config:  map[string]string{"idle.action": "freeze"},
+	})
+	require.NoError(t, err)
+
+	addContainer(t, tx, 1, "c1")
+	addContainer(t, tx, 1, "c2")
+	addContainer(t, tx, 1, "c3")
+	addContainerConfig(t, tx, "c1", "idle.action", "stop")
+	addContainerConfig(t, tx, "c3", "user.foo", "bar")
+
+	_, err = tx.Tx().Exec("INSERT INTO instances_profiles(instance_id, profile_id) VALUES (?, ?)", getContainerID(t, tx, "c2"), profileID)
+	require.NoError(t, err)
+
+	ids, err := tx.LocalInstanceIDsWithExpandedConfigKeys("idle.action")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int{int(getContainerID(t, tx, "c1")), int(getContainerID(t, tx, "c2"))}, ids)
+}
+
 func TestInstancePool(t *testing.T) {
 	cluster, cleanup := db.NewTestCluster(t)
 	defer cleanup()
@@ -381,6 +429,58 @@ func TestInstancePool(t *testing.T) {
 	assert.Equal(t, "default", poolName)
 }
 
+// Incremental backups reference the backup they're based upon, even after it was renamed.
+func TestInstanceBackupCreate_Parent(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	var instanceID int
+	err := cluster.Transaction(func(tx *db.ClusterTx) error {
+		addContainer(t, tx, 1, "c1")
+		instanceID = int(getContainerID(t, tx, "c1"))
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = cluster.InstanceBackupCreate(db.InstanceBackupArgs{
+		InstanceID:   instanceID,
+		Name:         "c1/full",
+		CreationDate: time.Now(),
+	})
+	require.NoError(t, err)
+
+	err = cluster.InstanceBackupCreate(db.InstanceBackupArgs{
+		InstanceID:   instanceID,
+		Name:         "c1/incremental",
+		CreationDate: time.Now(),
+		Parent:       "c1/full",
+	})
+	require.NoError(t, err)
+
+	err = cluster.InstanceBackupCreate(db.InstanceBackupArgs{
+		InstanceID:   instanceID,
+		Name:         "c1/orphan",
+		CreationDate: time.Now(),
+		Parent:       "c1/missing",
+	})
+	assert.Equal(t, db.ErrNoSuchObject, err)
+
+	err = cluster.ContainerBackupRename("c1/full", "c1/base")
+	require.NoError(t, err)
+
+	backup, err := cluster.ContainerGetBackup("default", "c1/incremental")
+	require.NoError(t, err)
+	assert.Equal(t, "c1/base", backup.Parent)
+
+	backup, err = cluster.ContainerGetBackup("default", "c1/base")
+	require.NoError(t, err)
+	assert.Equal(t, "", backup.Parent)
+
+	children, err := cluster.ContainerBackupChildren("c1/base")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c1/incremental"}, children)
+}
+
 // Only containers running on the local node are returned.
 func TestContainersNodeList(t *testing.T) {
 	cluster, cleanup := db.NewTestCluster(t)
@@ -3,6 +3,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
@@ -16,6 +17,7 @@ import (
 	"github.com/lxc/lxd/lxd/db/cluster"
 	"github.com/lxc/lxd/lxd/db/node"
 	"github.com/lxc/lxd/lxd/db/query"
+	"github.com/lxc/lxd/lxd/tracing"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
 )
@@ -342,9 +344,16 @@ func (c *Cluster) GetNodeID() int64 {
 // If EnterExclusive has been called before, calling Transaction will block
 // until ExitExclusive has been called as well to release the lock.
 func (c *Cluster) Transaction(f func(*ClusterTx) error) error {
+	return c.TransactionContext(context.Background(), f)
+}
+
+// TransactionContext is like Transaction, but the transaction is bound to the
+// given context, so it gets aborted (and not retried) if the context is done
+// before it completes.
+func (c *Cluster) TransactionContext(ctx context.Context, f func(*ClusterTx) error) error {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return c.transaction(f)
+	return c.transaction(ctx, f)
 }
 
 // EnterExclusive acquires a lock on the cluster db, so any successive call to
@@ -372,21 +381,25 @@ func (c *Cluster) EnterExclusive() error {
 func (c *Cluster) ExitExclusive(f func(*ClusterTx) error) error {
 	logger.Debug("Releasing exclusive lock on cluster db")
 	defer c.mu.Unlock()
-	return c.transaction(f)
+	return c.transaction(context.Background(), f)
 }
 
-func (c *Cluster) transaction(f func(*ClusterTx) error) error {
+func (c *Cluster) transaction(ctx context.Context, f func(*ClusterTx) error) error {
 	clusterTx := &ClusterTx{
 		nodeID: c.nodeID,
 		stmts:  c.stmts,
 	}
 
-	return query.Retry(func() error {
-		return query.Transaction(c.db, func(tx *sql.Tx) error {
+	ctx, span := tracing.StartChild(ctx, "db transaction")
+	err := query.RetryContext(ctx, func() error {
+		return query.TransactionContext(ctx, c.db, func(tx *sql.Tx) error {
 			clusterTx.tx = tx
 			return f(clusterTx)
 		})
 	})
+	span.End(err)
+
+	return err
 }
 
 // NodeID sets the the node NodeID associated with this cluster instance. It's used for
@@ -31,6 +31,8 @@ func dbDeviceTypeToString(t int) (string, error) {
 		return "proxy", nil
 	case 9:
 		return "unix-hotplug", nil
+	case 10:
+		return "plugin", nil
 	default:
 		return "", fmt.Errorf("Invalid device type %d", t)
 	}
@@ -58,6 +60,8 @@ func dbDeviceTypeToInt(t string) (int, error) {
 		return 8, nil
 	case "unix-hotplug":
 		return 9, nil
+	case "plugin":
+		return 10, nil
 	default:
 		return -1, fmt.Errorf("Invalid device type %s", t)
 	}
@@ -146,13 +150,11 @@ func dbDeviceConfig(db *sql.DB, id int, isprofile bool) (deviceConfig.Device, er
 // Devices returns the devices matching the given filters.
 func (c *Cluster) Devices(project, qName string, isprofile bool) (deviceConfig.Devices, error) {
 	err := c.Transaction(func(tx *ClusterTx) error {
-		enabled, err := tx.ProjectHasProfiles(project)
+		profilesProject, err := tx.ProjectProfilesProject(project)
 		if err != nil {
 			return err
 		}
-		if !enabled {
-			project = "default"
-		}
+		project = profilesProject
 		return nil
 	})
 	if err != nil {
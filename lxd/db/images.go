@@ -5,6 +5,7 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -49,13 +50,11 @@ SELECT images.fingerprint
 // ImagesGet returns the names of all images (optionally only the public ones).
 func (c *Cluster) ImagesGet(project string, public bool) ([]string, error) {
 	err := c.Transaction(func(tx *ClusterTx) error {
-		enabled, err := tx.ProjectHasImages(project)
+		imagesProject, err := tx.ProjectImagesProject(project)
 		if err != nil {
 			return errors.Wrap(err, "Check if project has images")
 		}
-		if !enabled {
-			project = "default"
-		}
+		project = imagesProject
 		return nil
 	})
 	if err != nil {
@@ -255,13 +254,11 @@ func (c *Cluster) ImageSourceGetCachedFingerprint(server string, protocol string
 // ImageExists returns whether an image with the given fingerprint exists.
 func (c *Cluster) ImageExists(project string, fingerprint string) (bool, error) {
 	err := c.Transaction(func(tx *ClusterTx) error {
-		enabled, err := tx.ProjectHasImages(project)
+		imagesProject, err := tx.ProjectImagesProject(project)
 		if err != nil {
 			return errors.Wrap(err, "Check if project has images")
 		}
-		if !enabled {
-			project = "default"
-		}
+		project = imagesProject
 		return nil
 	})
 	if err != nil {
@@ -289,13 +286,11 @@ SELECT COUNT(*) > 0
 // fingerprint is referenced by projects other than the given one.
 func (c *Cluster) ImageIsReferencedByOtherProjects(project string, fingerprint string) (bool, error) {
 	err := c.Transaction(func(tx *ClusterTx) error {
-		enabled, err := tx.ProjectHasImages(project)
+		imagesProject, err := tx.ProjectImagesProject(project)
 		if err != nil {
 			return errors.Wrap(err, "Check if project has images")
 		}
-		if !enabled {
-			project = "default"
-		}
+		project = imagesProject
 		return nil
 	})
 	if err != nil {
@@ -327,13 +322,11 @@ SELECT COUNT(*) > 0
 func (c *Cluster) ImageGet(project, fingerprint string, public bool, strictMatching bool) (int, *api.Image, error) {
 	profileProject := project
 	err := c.Transaction(func(tx *ClusterTx) error {
-		enabled, err := tx.ProjectHasImages(project)
+		imagesProject, err := tx.ProjectImagesProject(project)
 		if err != nil {
 			return errors.Wrap(err, "Check if project has images")
 		}
-		if !enabled {
-			project = "default"
-		}
+		project = imagesProject
 		return nil
 	})
 	if err != nil {
@@ -536,13 +529,11 @@ func (c *Cluster) imageFill(id int, image *api.Image, create, expire, used, uplo
 func (c *Cluster) imageFillProfiles(id int, image *api.Image, project string) error {
 	// Check which project name to use
 	err := c.Transaction(func(tx *ClusterTx) error {
-		enabled, err := tx.ProjectHasProfiles(project)
+		profilesProject, err := tx.ProjectProfilesProject(project)
 		if err != nil {
 			return errors.Wrap(err, "Check if project has profiles")
 		}
-		if !enabled {
-			project = "default"
-		}
+		project = profilesProject
 		return nil
 	})
 	if err != nil {
@@ -659,13 +650,11 @@ func (c *Cluster) ImageDelete(id int) error {
 // ImageAliasesGet returns the names of the aliases of all images.
 func (c *Cluster) ImageAliasesGet(project string) ([]string, error) {
 	err := c.Transaction(func(tx *ClusterTx) error {
-		enabled, err := tx.ProjectHasImages(project)
+		imagesProject, err := tx.ProjectImagesProject(project)
 		if err != nil {
 			return errors.Wrap(err, "Check if project has images")
 		}
-		if !enabled {
-			project = "default"
-		}
+		project = imagesProject
 		return nil
 	})
 	if err != nil {
@@ -698,13 +687,11 @@ func (c *Cluster) ImageAliasGet(project, name string, isTrustedClient bool) (int
 	entry := api.ImageAliasesEntry{}
 
 	err := c.Transaction(func(tx *ClusterTx) error {
-		enabled, err := tx.ProjectHasImages(project)
+		imagesProject, err := tx.ProjectImagesProject(project)
 		if err != nil {
 			return errors.Wrap(err, "Check if project has images")
 		}
-		if !enabled {
-			project = "default"
-		}
+		project = imagesProject
 		return nil
 	})
 	if err != nil {
@@ -741,9 +728,61 @@ func (c *Cluster) ImageAliasGet(project, name string, isTrustedClient bool) (int
 	entry.Description = description
 	entry.Type = instancetype.Type(imageType).String()
 
+	q = `SELECT images_aliases_channels.name, images.fingerprint
+			 FROM images_aliases_channels
+			 INNER JOIN images
+			 ON images_aliases_channels.image_id=images.id
+			 WHERE images_aliases_channels.image_alias_id=?`
+	if !isTrustedClient {
+		q = q + ` AND images.public=1`
+	}
+
+	inargs := []interface{}{id}
+	outfmt := []interface{}{"", ""}
+	results, err := queryScan(c.db, q, inargs, outfmt)
+	if err != nil {
+		return -1, entry, err
+	}
+
+	entry.Channels = map[string]string{}
+	for _, res := range results {
+		entry.Channels[res[0].(string)] = res[1].(string)
+	}
+
 	return id, entry, nil
 }
 
+// ImageAliasResolve returns the alias with the given name in the given project. The name may be
+// suffixed with ":<channel>" to select one of the channels of the alias, in which case the
+// returned alias targets the image of that channel.
+func (c *Cluster) ImageAliasResolve(project, name string, isTrustedClient bool) (api.ImageAliasesEntry, error) {
+	_, entry, err := c.ImageAliasGet(project, name, isTrustedClient)
+	if err != ErrNoSuchObject {
+		return entry, err
+	}
+
+	i := strings.LastIndex(name, ":")
+	if i < 0 {
+		return entry, err
+	}
+
+	_, entry, err = c.ImageAliasGet(project, name[:i], isTrustedClient)
+	if err != nil {
+		return entry, err
+	}
+
+	target, ok := entry.Channels[name[i+1:]]
+	if !ok {
+		return entry, ErrNoSuchObject
+	}
+
+	entry.Name = name
+	entry.Target = target
+	entry.Channels = nil
+
+	return entry, nil
+}
+
 // ImageAliasRename renames the alias with the given ID.
 func (c *Cluster) ImageAliasRename(id int, name string) error {
 	err := exec(c.db, "UPDATE images_aliases SET name=? WHERE id=?", name, id)
@@ -753,13 +792,11 @@ func (c *Cluster) ImageAliasRename(id int, name string) error {
 // ImageAliasDelete deletes the alias with the given name.
 func (c *Cluster) ImageAliasDelete(project, name string) error {
 	err := c.Transaction(func(tx *ClusterTx) error {
-		enabled, err := tx.ProjectHasImages(project)
+		imagesProject, err := tx.ProjectImagesProject(project)
 		if err != nil {
 			return errors.Wrap(err, "Check if project has images")
 		}
-		if !enabled {
-			project = "default"
-		}
+		project = imagesProject
 		return nil
 	})
 	if err != nil {
@@ -777,19 +814,22 @@ DELETE
 // ImageAliasesMove changes the image ID associated with an alias.
 func (c *Cluster) ImageAliasesMove(source int, destination int) error {
 	err := exec(c.db, "UPDATE images_aliases SET image_id=? WHERE image_id=?", destination, source)
+	if err != nil {
+		return err
+	}
+
+	err = exec(c.db, "UPDATE images_aliases_channels SET image_id=? WHERE image_id=?", destination, source)
 	return err
 }
 
 // ImageAliasAdd inserts an alias ento the database.
 func (c *Cluster) ImageAliasAdd(project, name string, imageID int, desc string) error {
 	err := c.Transaction(func(tx *ClusterTx) error {
-		enabled, err := tx.ProjectHasImages(project)
+		imagesProject, err := tx.ProjectImagesProject(project)
 		if err != nil {
 			return errors.Wrap(err, "Check if project has images")
 		}
-		if !enabled {
-			project = "default"
-		}
+		project = imagesProject
 		return nil
 	})
 	if err != nil {
@@ -811,6 +851,26 @@ func (c *Cluster) ImageAliasUpdate(id int, imageID int, desc string) error {
 	return err
 }
 
+// ImageAliasChannelsUpdate replaces the channels of the alias with the given ID, given as a map of
+// channel names to image IDs.
+func (c *Cluster) ImageAliasChannelsUpdate(id int, channels map[string]int) error {
+	return c.Transaction(func(tx *ClusterTx) error {
+		_, err := tx.tx.Exec("DELETE FROM images_aliases_channels WHERE image_alias_id=?", id)
+		if err != nil {
+			return err
+		}
+
+		for name, imageID := range channels {
+			_, err := tx.tx.Exec("INSERT INTO images_aliases_channels (image_alias_id, name, image_id) VALUES (?, ?, ?)", id, name, imageID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
 // ImageCopyDefaultProfiles copies default profiles from id to new_id.
 func (c *Cluster) ImageCopyDefaultProfiles(id int, newID int) error {
 	err := c.Transaction(func(tx *ClusterTx) error {
@@ -898,13 +958,11 @@ func (c *Cluster) ImageUpdate(id int, fname string, sz int64, public bool, autoU
 		}
 
 		if project != "" && profileIds != nil {
-			enabled, err := tx.ProjectHasProfiles(project)
+			profilesProject, err := tx.ProjectProfilesProject(project)
 			if err != nil {
 				return err
 			}
-			if !enabled {
-				project = "default"
-			}
+			project = profilesProject
 			q := `DELETE FROM images_profiles
 				WHERE image_id = ? AND profile_id IN (
 					SELECT profiles.id FROM profiles
@@ -939,13 +997,11 @@ func (c *Cluster) ImageUpdate(id int, fname string, sz int64, public bool, autoU
 func (c *Cluster) ImageInsert(project, fp string, fname string, sz int64, public bool, autoUpdate bool, architecture string, createdAt time.Time, expiresAt time.Time, properties map[string]string, typeName string) error {
 	profileProject := project
 	err := c.Transaction(func(tx *ClusterTx) error {
-		enabled, err := tx.ProjectHasImages(project)
+		imagesProject, err := tx.ProjectImagesProject(project)
 		if err != nil {
 			return errors.Wrap(err, "Check if project has images")
 		}
-		if !enabled {
-			project = "default"
-		}
+		project = imagesProject
 		return nil
 	})
 	if err != nil {
@@ -39,3 +39,49 @@ func TestImageLocate(t *testing.T) {
 	require.Equal(t, "", address)
 	require.EqualError(t, err, "image not available on any online node")
 }
+
+func TestImageAliasResolve(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	for _, fingerprint := range []string{"abc", "def"} {
+		err := cluster.ImageInsert(
+			"default", fingerprint, "x.gz", 16, false, false, "amd64", time.Now(), time.Now(), map[string]string{}, "container")
+		require.NoError(t, err)
+	}
+
+	stableID, _, err := cluster.ImageGet("default", "abc", false, true)
+	require.NoError(t, err)
+
+	candidateID, _, err := cluster.ImageGet("default", "def", false, true)
+	require.NoError(t, err)
+
+	err = cluster.ImageAliasAdd("default", "ubuntu/20.04", stableID, "")
+	require.NoError(t, err)
+
+	aliasID, _, err := cluster.ImageAliasGet("default", "ubuntu/20.04", true)
+	require.NoError(t, err)
+
+	err = cluster.ImageAliasChannelsUpdate(aliasID, map[string]int{"stable": stableID, "candidate": candidateID})
+	require.NoError(t, err)
+
+	_, alias, err := cluster.ImageAliasGet("default", "ubuntu/20.04", true)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"stable": "abc", "candidate": "def"}, alias.Channels)
+
+	alias, err = cluster.ImageAliasResolve("default", "ubuntu/20.04:candidate", true)
+	require.NoError(t, err)
+	assert.Equal(t, "ubuntu/20.04:candidate", alias.Name)
+	assert.Equal(t, "def", alias.Target)
+
+	alias, err = cluster.ImageAliasResolve("default", "ubuntu/20.04", true)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", alias.Target)
+
+	_, err = cluster.ImageAliasResolve("default", "ubuntu/20.04:edge", true)
+	assert.Equal(t, db.ErrNoSuchObject, err)
+
+	// Channels only pointing to private images aren't visible to untrusted clients.
+	_, err = cluster.ImageAliasResolve("default", "ubuntu/20.04:candidate", false)
+	assert.Equal(t, db.ErrNoSuchObject, err)
+}
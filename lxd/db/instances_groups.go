@@ -0,0 +1,197 @@
+// +build linux,cgo,!agent
+
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db/query"
+)
+
+// InstanceGroup holds a named group of instances, sharing the group config.
+type InstanceGroup struct {
+	ID          int64
+	Project     string
+	Name        string
+	Description string
+	Config      map[string]string
+}
+
+// InstanceGroups returns the instance groups of the given project.
+func (c *ClusterTx) InstanceGroups(project string) ([]InstanceGroup, error) {
+	return c.instanceGroups("projects.name = ?", project)
+}
+
+// InstanceGroupGet returns the instance group with the given name.
+func (c *ClusterTx) InstanceGroupGet(project string, name string) (InstanceGroup, error) {
+	null := InstanceGroup{}
+	groups, err := c.instanceGroups("projects.name = ? AND instances_groups.name = ?", project, name)
+	if err != nil {
+		return null, err
+	}
+
+	switch len(groups) {
+	case 0:
+		return null, ErrNoSuchObject
+	case 1:
+		return groups[0], nil
+	default:
+		return null, fmt.Errorf("More than one instance group matches")
+	}
+}
+
+// InstanceGroupCreate adds a new instance group to the given project.
+func (c *ClusterTx) InstanceGroupCreate(project string, name string, description string, config map[string]string) (int64, error) {
+	projectID, err := c.ProjectID(project)
+	if err != nil {
+		return -1, err
+	}
+
+	columns := []string{"project_id", "name", "description"}
+	values := []interface{}{projectID, name, description}
+	id, err := query.UpsertObject(c.tx, "instances_groups", columns, values)
+	if err != nil {
+		return -1, err
+	}
+
+	err = c.instanceGroupConfigAdd(id, config)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// InstanceGroupUpdate replaces the description and the config of the instance group with the
+// given ID.
+func (c *ClusterTx) InstanceGroupUpdate(id int64, description string, config map[string]string) error {
+	_, err := c.tx.Exec("UPDATE instances_groups SET description = ? WHERE id = ?", description, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.tx.Exec("DELETE FROM instances_groups_config WHERE group_id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	return c.instanceGroupConfigAdd(id, config)
+}
+
+// InstanceGroupDelete removes the instance group with the given ID.
+func (c *ClusterTx) InstanceGroupDelete(id int64) error {
+	deleted, err := query.DeleteObject(c.tx, "instances_groups", id)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return ErrNoSuchObject
+	}
+
+	return nil
+}
+
+// InstanceGroupMembers returns the instances of the given project belonging to the group with the
+// given name, according to their groups config key, along with the name of the node they're on.
+// The group itself doesn't need to exist.
+func (c *ClusterTx) InstanceGroupMembers(project string, group string) (map[string]string, error) {
+	type member struct {
+		name   string
+		node   string
+		groups string
+	}
+
+	members := []member{}
+	dest := func(i int) []interface{} {
+		members = append(members, member{})
+		return []interface{}{&members[i].name, &members[i].node, &members[i].groups}
+	}
+
+	stmt, err := c.tx.Prepare(`
+SELECT instances.name, nodes.name, instances_config.value
+  FROM instances
+  JOIN instances_config ON instances_config.instance_id = instances.id
+  JOIN nodes ON nodes.id = instances.node_id
+  JOIN projects ON projects.id = instances.project_id
+ WHERE projects.name = ? AND instances_config.key = 'groups'
+`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	err = query.SelectObjects(stmt, dest, project)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch instance group members")
+	}
+
+	result := map[string]string{}
+	for _, member := range members {
+		for _, name := range strings.Split(member.groups, ",") {
+			if strings.TrimSpace(name) == group {
+				result[member.name] = member.node
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func (c *ClusterTx) instanceGroupConfigAdd(id int64, config map[string]string) error {
+	for key, value := range config {
+		if value == "" {
+			continue
+		}
+
+		_, err := c.tx.Exec("INSERT INTO instances_groups_config (group_id, key, value) VALUES (?, ?, ?)", id, key, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Return the instance groups, filtered by the given clause.
+func (c *ClusterTx) instanceGroups(where string, args ...interface{}) ([]InstanceGroup, error) {
+	groups := []InstanceGroup{}
+	dest := func(i int) []interface{} {
+		groups = append(groups, InstanceGroup{})
+		return []interface{}{
+			&groups[i].ID,
+			&groups[i].Project,
+			&groups[i].Name,
+			&groups[i].Description,
+		}
+	}
+
+	stmt, err := c.tx.Prepare(fmt.Sprintf(`
+SELECT instances_groups.id, projects.name, instances_groups.name, instances_groups.description
+  FROM instances_groups
+  JOIN projects ON projects.id = instances_groups.project_id
+ WHERE %s
+ ORDER BY instances_groups.name`, where))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	err = query.SelectObjects(stmt, dest, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch instance groups")
+	}
+
+	for i := range groups {
+		groups[i].Config, err = query.SelectConfig(c.tx, "instances_groups_config", "group_id = ?", groups[i].ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to fetch instance group config")
+		}
+	}
+
+	return groups, nil
+}
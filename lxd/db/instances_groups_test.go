@@ -0,0 +1,53 @@
+// +build linux,cgo,!agent
+
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Add, update and delete instance groups, and list their members.
+func TestInstanceGroups(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	nodeID, err := tx.NodeAdd("node2", "1.2.3.4:666")
+	require.NoError(t, err)
+
+	addContainer(t, tx, 1, "c1")
+	addContainer(t, tx, nodeID, "c2")
+	addContainer(t, tx, nodeID, "c3")
+	addContainerConfig(t, tx, "c1", "groups", "web,db")
+	addContainerConfig(t, tx, "c2", "groups", "web")
+	addContainerConfig(t, tx, "c3", "groups", "website")
+
+	id, err := tx.InstanceGroupCreate("default", "web", "Web servers", map[string]string{"scheduler.anti_affinity": "true"})
+	require.NoError(t, err)
+
+	group, err := tx.InstanceGroupGet("default", "web")
+	require.NoError(t, err)
+	assert.Equal(t, "Web servers", group.Description)
+	assert.Equal(t, map[string]string{"scheduler.anti_affinity": "true"}, group.Config)
+
+	members, err := tx.InstanceGroupMembers("default", "web")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"c1": "none", "c2": "node2"}, members)
+
+	err = tx.InstanceGroupUpdate(id, "", map[string]string{"snapshots.schedule": "0 2 * * *"})
+	require.NoError(t, err)
+
+	groups, err := tx.InstanceGroups("default")
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	assert.Equal(t, map[string]string{"snapshots.schedule": "0 2 * * *"}, groups[0].Config)
+
+	err = tx.InstanceGroupDelete(id)
+	require.NoError(t, err)
+
+	_, err = tx.InstanceGroupGet("default", "web")
+	assert.Equal(t, db.ErrNoSuchObject, err)
+}
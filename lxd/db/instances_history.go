@@ -0,0 +1,65 @@
+// +build linux,cgo,!agent
+
+package db
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db/query"
+)
+
+// InstanceHistoryEntry holds a single state change of an instance.
+type InstanceHistoryEntry struct {
+	ID        int64     // Stable, monotonically increasing identifier
+	Date      time.Time // Time at which the change was recorded
+	Action    string    // Action performed (start, stop, ...)
+	Operation string    // UUID of the operation performing the change, if any
+	Requestor string    // User who requested the change, if any
+}
+
+// InstanceHistoryAdd records a state change of the instance with the given ID.
+func (c *ClusterTx) InstanceHistoryAdd(instanceID int, action string, operation string, requestor string) error {
+	columns := []string{"instance_id", "date", "action", "operation", "requestor"}
+	values := []interface{}{instanceID, time.Now().UTC(), action, operation, requestor}
+	_, err := query.UpsertObject(c.tx, "instances_history", columns, values)
+	return err
+}
+
+// InstanceHistory returns the recorded state changes of the instance with the
+// given name, in the order they happened.
+func (c *ClusterTx) InstanceHistory(project string, name string) ([]InstanceHistoryEntry, error) {
+	entries := []InstanceHistoryEntry{}
+	dest := func(i int) []interface{} {
+		entries = append(entries, InstanceHistoryEntry{})
+		return []interface{}{
+			&entries[i].ID,
+			&entries[i].Date,
+			&entries[i].Action,
+			&entries[i].Operation,
+			&entries[i].Requestor,
+		}
+	}
+
+	stmt, err := c.tx.Prepare(`
+SELECT instances_history.id, instances_history.date, instances_history.action,
+       instances_history.operation, instances_history.requestor
+  FROM instances_history
+  JOIN instances ON instances.id = instances_history.instance_id
+  JOIN projects ON projects.id = instances.project_id
+ WHERE projects.name = ? AND instances.name = ?
+ ORDER BY instances_history.id
+`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	err = query.SelectObjects(stmt, dest, project, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch instance history")
+	}
+
+	return entries, nil
+}
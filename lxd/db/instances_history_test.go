@@ -0,0 +1,43 @@
+// +build linux,cgo,!agent
+
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Record state changes of an instance and read them back in order.
+func TestInstanceHistory(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	nodeID, err := tx.NodeAdd("node2", "1.2.3.4:666")
+	require.NoError(t, err)
+
+	addContainer(t, tx, nodeID, "c1")
+	id := int(getContainerID(t, tx, "c1"))
+
+	err = tx.InstanceHistoryAdd(id, "start", "abcd", "alice")
+	require.NoError(t, err)
+
+	err = tx.InstanceHistoryAdd(id, "shutdown", "", "")
+	require.NoError(t, err)
+
+	entries, err := tx.InstanceHistory("default", "c1")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, "start", entries[0].Action)
+	assert.Equal(t, "abcd", entries[0].Operation)
+	assert.Equal(t, "alice", entries[0].Requestor)
+	assert.Equal(t, "shutdown", entries[1].Action)
+	assert.True(t, entries[0].ID < entries[1].ID)
+
+	entries, err = tx.InstanceHistory("default", "c2")
+	require.NoError(t, err)
+	assert.Len(t, entries, 0)
+}
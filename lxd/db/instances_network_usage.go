@@ -0,0 +1,119 @@
+// +build linux,cgo,!agent
+
+package db
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db/query"
+)
+
+// InstanceNetworkUsage holds the network accounting state of an instance.
+type InstanceNetworkUsage struct {
+	Period  string // Accounting period the usage belongs to
+	Usage   int64  // Bytes sent during the period
+	Counter int64  // Bytes sent through the instance's interfaces as of the last record
+}
+
+// InstanceNetworkUsageGet returns the network usage last recorded for the instance with the given
+// ID, or ErrNoSuchObject if none was.
+func (c *ClusterTx) InstanceNetworkUsageGet(instanceID int) (InstanceNetworkUsage, error) {
+	usage := InstanceNetworkUsage{}
+	err := c.tx.QueryRow("SELECT period, usage, counter FROM instances_network_usage WHERE instance_id = ?", instanceID).Scan(&usage.Period, &usage.Usage, &usage.Counter)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return usage, ErrNoSuchObject
+		}
+
+		return usage, err
+	}
+
+	return usage, nil
+}
+
+// InstanceNetworkUsageSet records the network usage of the instance with the given ID.
+func (c *ClusterTx) InstanceNetworkUsageSet(instanceID int, usage InstanceNetworkUsage) error {
+	columns := []string{"instance_id", "period", "usage", "counter"}
+	values := []interface{}{instanceID, usage.Period, usage.Usage, usage.Counter}
+	_, err := query.UpsertObject(c.tx, "instances_network_usage", columns, values)
+	return err
+}
+
+// ProjectNetworkUsageAdd adds the given number of bytes to the network usage of a project for the
+// given accounting period. The usage of a project outlives its instances.
+func (c *ClusterTx) ProjectNetworkUsageAdd(project string, period string, bytes int64) error {
+	result, err := c.tx.Exec(`
+UPDATE projects_network_usage SET usage = usage + ?
+ WHERE project_id = (SELECT id FROM projects WHERE name = ?) AND period = ?
+`, bytes, project, period)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		return nil
+	}
+
+	result, err = c.tx.Exec(`
+INSERT INTO projects_network_usage (project_id, period, usage)
+  SELECT id, ?, ? FROM projects WHERE name = ?
+`, period, bytes, project)
+	if err != nil {
+		return err
+	}
+
+	n, err = result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n != 1 {
+		return errors.Wrapf(ErrNoSuchObject, "Failed to record network usage of project %q", project)
+	}
+
+	return nil
+}
+
+// ProjectsNetworkUsage returns the network usage recorded for each project during the given
+// accounting period.
+func (c *ClusterTx) ProjectsNetworkUsage(period string) (map[string]int64, error) {
+	stmt := `
+SELECT projects.name, projects_network_usage.usage
+  FROM projects_network_usage
+  JOIN projects ON projects.id = projects_network_usage.project_id
+ WHERE projects_network_usage.period = ?
+`
+
+	rows, err := c.tx.Query(stmt, period)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := map[string]int64{}
+	for rows.Next() {
+		var project string
+		var total int64
+
+		err := rows.Scan(&project, &total)
+		if err != nil {
+			return nil, err
+		}
+
+		usage[project] = total
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
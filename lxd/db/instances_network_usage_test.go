@@ -0,0 +1,64 @@
+// +build linux,cgo,!agent
+
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The usage of an instance is replaced on each record.
+func TestInstanceNetworkUsage(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	addContainer(t, tx, 1, "c1")
+	id := int(getContainerID(t, tx, "c1"))
+
+	_, err := tx.InstanceNetworkUsageGet(id)
+	assert.Equal(t, db.ErrNoSuchObject, err)
+
+	err = tx.InstanceNetworkUsageSet(id, db.InstanceNetworkUsage{Period: "2020-03", Usage: 1000, Counter: 5000})
+	require.NoError(t, err)
+
+	err = tx.InstanceNetworkUsageSet(id, db.InstanceNetworkUsage{Period: "2020-03", Usage: 1500, Counter: 5500})
+	require.NoError(t, err)
+
+	usage, err := tx.InstanceNetworkUsageGet(id)
+	require.NoError(t, err)
+	assert.Equal(t, db.InstanceNetworkUsage{Period: "2020-03", Usage: 1500, Counter: 5500}, usage)
+}
+
+// The usage of a project adds up per period and outlives its instances.
+func TestProjectsNetworkUsage(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	addContainer(t, tx, 1, "c1")
+	id := int(getContainerID(t, tx, "c1"))
+
+	require.NoError(t, tx.InstanceNetworkUsageSet(id, db.InstanceNetworkUsage{Period: "2020-03", Usage: 1000}))
+	require.NoError(t, tx.ProjectNetworkUsageAdd("default", "2020-03", 1000))
+	require.NoError(t, tx.ProjectNetworkUsageAdd("default", "2020-03", 500))
+	require.NoError(t, tx.ProjectNetworkUsageAdd("default", "2020-02", 200))
+
+	_, err := tx.Tx().Exec("DELETE FROM instances WHERE id = ?", id)
+	require.NoError(t, err)
+
+	_, err = tx.InstanceNetworkUsageGet(id)
+	assert.Equal(t, db.ErrNoSuchObject, err)
+
+	usage, err := tx.ProjectsNetworkUsage("2020-03")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"default": 1500}, usage)
+
+	usage, err = tx.ProjectsNetworkUsage("2020-04")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{}, usage)
+
+	err = tx.ProjectNetworkUsageAdd("missing", "2020-03", 100)
+	assert.Error(t, err)
+}
@@ -0,0 +1,149 @@
+// +build linux,cgo,!agent
+
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db/query"
+)
+
+// InstanceReplication holds the replication of an instance to another server, where a replica of
+// it is kept in sync at a regular interval.
+type InstanceReplication struct {
+	ID                int64     // Identifier of the replication
+	Project           string    // Project of the instance
+	Instance          string    // Name of the instance
+	Name              string    // Name of the replication, unique for the instance
+	Description       string    // Description of the replication
+	Target            string    // Address of the target server
+	TargetCertificate string    // Certificate of the target server, if not trusted by the system
+	TargetProject     string    // Project of the replica on the target server
+	Interval          int       // Minutes between syncs
+	Snapshots         string    // Comma separated patterns of the snapshots to replicate
+	LastSyncAt        time.Time // Time of the last sync, zero if it never happened
+	LastError         string    // Error of the last sync, empty if it succeeded
+}
+
+// InstanceReplications returns the replications of the instance with the given name.
+func (c *ClusterTx) InstanceReplications(project string, instance string) ([]InstanceReplication, error) {
+	return c.instanceReplications("WHERE projects.name = ? AND instances.name = ?", project, instance)
+}
+
+// InstanceReplicationGet returns the replication with the given name of an instance.
+func (c *ClusterTx) InstanceReplicationGet(project string, instance string, name string) (InstanceReplication, error) {
+	replications, err := c.instanceReplications("WHERE projects.name = ? AND instances.name = ? AND instances_replications.name = ?", project, instance, name)
+	if err != nil {
+		return InstanceReplication{}, err
+	}
+
+	switch len(replications) {
+	case 0:
+		return InstanceReplication{}, ErrNoSuchObject
+	case 1:
+		return replications[0], nil
+	default:
+		return InstanceReplication{}, fmt.Errorf("More than one replication matches")
+	}
+}
+
+// InstanceReplicationsNode returns the replications of all the instances on this node.
+func (c *ClusterTx) InstanceReplicationsNode() ([]InstanceReplication, error) {
+	return c.instanceReplications("WHERE instances.node_id = ?", c.nodeID)
+}
+
+// InstanceReplicationCreate adds a replication to the instance with the given ID.
+func (c *ClusterTx) InstanceReplicationCreate(instanceID int, replication InstanceReplication) (int64, error) {
+	columns := []string{
+		"instance_id", "name", "description", "target", "target_certificate", "target_project",
+		"interval", "snapshots", "last_sync_at", "last_error",
+	}
+	values := []interface{}{
+		instanceID, replication.Name, replication.Description, replication.Target, replication.TargetCertificate, replication.TargetProject,
+		replication.Interval, replication.Snapshots, replication.LastSyncAt.UTC(), replication.LastError,
+	}
+	return query.UpsertObject(c.tx, "instances_replications", columns, values)
+}
+
+// InstanceReplicationUpdate updates the description, target, interval and snapshots of the
+// replication with the given ID.
+func (c *ClusterTx) InstanceReplicationUpdate(id int64, replication InstanceReplication) error {
+	_, err := c.tx.Exec(`
+UPDATE instances_replications
+   SET description = ?, target = ?, target_certificate = ?, target_project = ?, interval = ?, snapshots = ?
+ WHERE id = ?`,
+		replication.Description, replication.Target, replication.TargetCertificate, replication.TargetProject,
+		replication.Interval, replication.Snapshots, id)
+	return err
+}
+
+// InstanceReplicationSynced records the time and the outcome of the last sync of the replication
+// with the given ID.
+func (c *ClusterTx) InstanceReplicationSynced(id int64, at time.Time, lastError string) error {
+	_, err := c.tx.Exec(
+		"UPDATE instances_replications SET last_sync_at = ?, last_error = ? WHERE id = ?",
+		at.UTC(), lastError, id)
+	return err
+}
+
+// InstanceReplicationDelete removes the replication with the given ID.
+func (c *ClusterTx) InstanceReplicationDelete(id int64) error {
+	deleted, err := query.DeleteObject(c.tx, "instances_replications", id)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return ErrNoSuchObject
+	}
+
+	return nil
+}
+
+func (c *ClusterTx) instanceReplications(where string, args ...interface{}) ([]InstanceReplication, error) {
+	replications := []InstanceReplication{}
+	dest := func(i int) []interface{} {
+		replications = append(replications, InstanceReplication{})
+		return []interface{}{
+			&replications[i].ID,
+			&replications[i].Project,
+			&replications[i].Instance,
+			&replications[i].Name,
+			&replications[i].Description,
+			&replications[i].Target,
+			&replications[i].TargetCertificate,
+			&replications[i].TargetProject,
+			&replications[i].Interval,
+			&replications[i].Snapshots,
+			&replications[i].LastSyncAt,
+			&replications[i].LastError,
+		}
+	}
+
+	stmt, err := c.tx.Prepare(fmt.Sprintf(`
+SELECT instances_replications.id, projects.name, instances.name, instances_replications.name,
+       instances_replications.description, instances_replications.target,
+       instances_replications.target_certificate, instances_replications.target_project,
+       instances_replications.interval, instances_replications.snapshots,
+       instances_replications.last_sync_at, instances_replications.last_error
+  FROM instances_replications
+  JOIN instances ON instances.id = instances_replications.instance_id
+  JOIN projects ON projects.id = instances.project_id
+ %s
+ ORDER BY projects.name, instances.name, instances_replications.name
+`, where))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	err = query.SelectObjects(stmt, dest, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch instance replications")
+	}
+
+	return replications, nil
+}
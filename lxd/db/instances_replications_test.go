@@ -0,0 +1,81 @@
+// +build linux,cgo,!agent
+
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Add, list, update and delete the replications of instances, and record their syncs.
+func TestInstanceReplications(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	nodeID, err := tx.NodeAdd("node2", "1.2.3.4:666")
+	require.NoError(t, err)
+
+	addContainer(t, tx, 1, "c1")
+	addContainer(t, tx, nodeID, "c2")
+
+	_, err = tx.InstanceReplicationCreate(int(getContainerID(t, tx, "c1")), db.InstanceReplication{
+		Name:          "dr",
+		Target:        "10.0.0.1:8443",
+		TargetProject: "default",
+		Interval:      60,
+		Snapshots:     "daily-*",
+	})
+	require.NoError(t, err)
+
+	_, err = tx.InstanceReplicationCreate(int(getContainerID(t, tx, "c2")), db.InstanceReplication{
+		Name:          "offsite",
+		Description:   "Backup site",
+		Target:        "10.0.0.2:8443",
+		TargetProject: "replicas",
+		Interval:      15,
+	})
+	require.NoError(t, err)
+
+	replications, err := tx.InstanceReplications("default", "c2")
+	require.NoError(t, err)
+	require.Len(t, replications, 1)
+	assert.Equal(t, "offsite", replications[0].Name)
+	assert.Equal(t, "Backup site", replications[0].Description)
+	assert.Equal(t, "replicas", replications[0].TargetProject)
+	assert.True(t, replications[0].LastSyncAt.IsZero())
+
+	// Only the replications of the instances on this node are returned.
+	replications, err = tx.InstanceReplicationsNode()
+	require.NoError(t, err)
+	require.Len(t, replications, 1)
+	assert.Equal(t, "dr", replications[0].Name)
+
+	replication, err := tx.InstanceReplicationGet("default", "c1", "dr")
+	require.NoError(t, err)
+
+	replication.Interval = 30
+	replication.Snapshots = ""
+	err = tx.InstanceReplicationUpdate(replication.ID, replication)
+	require.NoError(t, err)
+
+	now := time.Now().Truncate(time.Second)
+	err = tx.InstanceReplicationSynced(replication.ID, now, "Target unreachable")
+	require.NoError(t, err)
+
+	replication, err = tx.InstanceReplicationGet("default", "c1", "dr")
+	require.NoError(t, err)
+	assert.Equal(t, 30, replication.Interval)
+	assert.Equal(t, "", replication.Snapshots)
+	assert.True(t, now.Equal(replication.LastSyncAt))
+	assert.Equal(t, "Target unreachable", replication.LastError)
+
+	err = tx.InstanceReplicationDelete(replication.ID)
+	require.NoError(t, err)
+
+	_, err = tx.InstanceReplicationGet("default", "c1", "dr")
+	assert.Equal(t, db.ErrNoSuchObject, err)
+}
@@ -0,0 +1,116 @@
+// +build linux,cgo,!agent
+
+package db
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db/query"
+)
+
+// InstanceSchedule holds an action performed on an instance at scheduled times.
+type InstanceSchedule struct {
+	ID          int64  // Identifier of the schedule
+	Project     string // Project of the instance
+	Instance    string // Name of the instance
+	Name        string // Name of the schedule, unique for the instance
+	Description string // Description of the schedule
+	Action      string // Action to perform (start, stop or snapshot)
+	Schedule    string // Cron expression of when to perform the action
+}
+
+// InstanceSchedules returns the schedules of the instance with the given name.
+func (c *ClusterTx) InstanceSchedules(project string, instance string) ([]InstanceSchedule, error) {
+	return c.instanceSchedules("WHERE projects.name = ? AND instances.name = ?", project, instance)
+}
+
+// InstanceScheduleGet returns the schedule with the given name of an instance.
+func (c *ClusterTx) InstanceScheduleGet(project string, instance string, name string) (InstanceSchedule, error) {
+	schedules, err := c.instanceSchedules("WHERE projects.name = ? AND instances.name = ? AND instances_schedules.name = ?", project, instance, name)
+	if err != nil {
+		return InstanceSchedule{}, err
+	}
+
+	switch len(schedules) {
+	case 0:
+		return InstanceSchedule{}, ErrNoSuchObject
+	case 1:
+		return schedules[0], nil
+	default:
+		return InstanceSchedule{}, fmt.Errorf("More than one schedule matches")
+	}
+}
+
+// InstanceSchedulesNode returns the schedules of all the instances on this node.
+func (c *ClusterTx) InstanceSchedulesNode() ([]InstanceSchedule, error) {
+	return c.instanceSchedules("WHERE instances.node_id = ?", c.nodeID)
+}
+
+// InstanceScheduleCreate adds a schedule to the instance with the given ID.
+func (c *ClusterTx) InstanceScheduleCreate(instanceID int, schedule InstanceSchedule) (int64, error) {
+	columns := []string{"instance_id", "name", "description", "action", "schedule"}
+	values := []interface{}{instanceID, schedule.Name, schedule.Description, schedule.Action, schedule.Schedule}
+	return query.UpsertObject(c.tx, "instances_schedules", columns, values)
+}
+
+// InstanceScheduleUpdate updates the description, action and cron expression of the schedule with
+// the given ID.
+func (c *ClusterTx) InstanceScheduleUpdate(id int64, description string, action string, schedule string) error {
+	_, err := c.tx.Exec(
+		"UPDATE instances_schedules SET description = ?, action = ?, schedule = ? WHERE id = ?",
+		description, action, schedule, id)
+	return err
+}
+
+// InstanceScheduleDelete removes the schedule with the given ID.
+func (c *ClusterTx) InstanceScheduleDelete(id int64) error {
+	deleted, err := query.DeleteObject(c.tx, "instances_schedules", id)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return ErrNoSuchObject
+	}
+
+	return nil
+}
+
+func (c *ClusterTx) instanceSchedules(where string, args ...interface{}) ([]InstanceSchedule, error) {
+	schedules := []InstanceSchedule{}
+	dest := func(i int) []interface{} {
+		schedules = append(schedules, InstanceSchedule{})
+		return []interface{}{
+			&schedules[i].ID,
+			&schedules[i].Project,
+			&schedules[i].Instance,
+			&schedules[i].Name,
+			&schedules[i].Description,
+			&schedules[i].Action,
+			&schedules[i].Schedule,
+		}
+	}
+
+	stmt, err := c.tx.Prepare(fmt.Sprintf(`
+SELECT instances_schedules.id, projects.name, instances.name, instances_schedules.name,
+       instances_schedules.description, instances_schedules.action, instances_schedules.schedule
+  FROM instances_schedules
+  JOIN instances ON instances.id = instances_schedules.instance_id
+  JOIN projects ON projects.id = instances.project_id
+ %s
+ ORDER BY projects.name, instances.name, instances_schedules.name
+`, where))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	err = query.SelectObjects(stmt, dest, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch instance schedules")
+	}
+
+	return schedules, nil
+}
@@ -0,0 +1,67 @@
+// +build linux,cgo,!agent
+
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Add, list, update and delete the schedules of instances.
+func TestInstanceSchedules(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	nodeID, err := tx.NodeAdd("node2", "1.2.3.4:666")
+	require.NoError(t, err)
+
+	addContainer(t, tx, 1, "c1")
+	addContainer(t, tx, nodeID, "c2")
+
+	_, err = tx.InstanceScheduleCreate(int(getContainerID(t, tx, "c1")), db.InstanceSchedule{
+		Name:     "nightly",
+		Action:   "snapshot",
+		Schedule: "0 2 * * *",
+	})
+	require.NoError(t, err)
+
+	_, err = tx.InstanceScheduleCreate(int(getContainerID(t, tx, "c2")), db.InstanceSchedule{
+		Name:        "morning",
+		Description: "Office hours",
+		Action:      "start",
+		Schedule:    "0 8 * * 1-5",
+	})
+	require.NoError(t, err)
+
+	schedules, err := tx.InstanceSchedules("default", "c2")
+	require.NoError(t, err)
+	require.Len(t, schedules, 1)
+	assert.Equal(t, "morning", schedules[0].Name)
+	assert.Equal(t, "Office hours", schedules[0].Description)
+	assert.Equal(t, "c2", schedules[0].Instance)
+
+	// Only the schedules of the instances on this node are returned.
+	schedules, err = tx.InstanceSchedulesNode()
+	require.NoError(t, err)
+	require.Len(t, schedules, 1)
+	assert.Equal(t, "nightly", schedules[0].Name)
+
+	schedule, err := tx.InstanceScheduleGet("default", "c1", "nightly")
+	require.NoError(t, err)
+
+	err = tx.InstanceScheduleUpdate(schedule.ID, "", "snapshot", "0 3 * * *")
+	require.NoError(t, err)
+
+	schedule, err = tx.InstanceScheduleGet("default", "c1", "nightly")
+	require.NoError(t, err)
+	assert.Equal(t, "0 3 * * *", schedule.Schedule)
+
+	err = tx.InstanceScheduleDelete(schedule.ID)
+	require.NoError(t, err)
+
+	_, err = tx.InstanceScheduleGet("default", "c1", "nightly")
+	assert.Equal(t, db.ErrNoSuchObject, err)
+}
@@ -0,0 +1,147 @@
+// +build linux,cgo,!agent
+
+package db
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db/query"
+)
+
+// NetworkPeer holds a peering letting traffic flow directly between two networks.
+type NetworkPeer struct {
+	ID            int64
+	Name          string
+	Description   string
+	TargetNetwork string // Name of the network peered with
+}
+
+// NetworkPeers returns the peerings defined on the network with the given ID.
+func (c *ClusterTx) NetworkPeers(networkID int64) ([]NetworkPeer, error) {
+	return c.networkPeers("networks_peers.network_id = ?", networkID)
+}
+
+// NetworkPeer returns the peering with the given name on the network with the given ID.
+func (c *ClusterTx) NetworkPeer(networkID int64, name string) (NetworkPeer, error) {
+	null := NetworkPeer{}
+	peers, err := c.networkPeers("networks_peers.network_id = ? AND networks_peers.name = ?", networkID, name)
+	if err != nil {
+		return null, err
+	}
+
+	switch len(peers) {
+	case 0:
+		return null, ErrNoSuchObject
+	case 1:
+		return peers[0], nil
+	default:
+		return null, fmt.Errorf("More than one peer matches")
+	}
+}
+
+// NetworkPeerCreate adds a new peering from the network with the given ID to the target network.
+func (c *ClusterTx) NetworkPeerCreate(networkID int64, name string, description string, targetNetworkID int64) error {
+	columns := []string{"network_id", "name", "description", "target_network_id"}
+	values := []interface{}{networkID, name, description, targetNetworkID}
+	_, err := query.UpsertObject(c.tx, "networks_peers", columns, values)
+	return err
+}
+
+// NetworkPeerUpdateDescription sets the description of the given peering.
+func (c *ClusterTx) NetworkPeerUpdateDescription(networkID int64, name string, description string) error {
+	result, err := c.tx.Exec("UPDATE networks_peers SET description = ? WHERE network_id = ? AND name = ?", description, networkID, name)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n != 1 {
+		return ErrNoSuchObject
+	}
+
+	return nil
+}
+
+// NetworkPeerDelete removes the given peering.
+func (c *ClusterTx) NetworkPeerDelete(networkID int64, name string) error {
+	result, err := c.tx.Exec("DELETE FROM networks_peers WHERE network_id = ? AND name = ?", networkID, name)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n != 1 {
+		return ErrNoSuchObject
+	}
+
+	return nil
+}
+
+// NetworkPeeredNetworks returns the names of the networks peered with the given one, whichever
+// side the peering was defined on.
+func (c *Cluster) NetworkPeeredNetworks(name string) ([]string, error) {
+	var names []string
+	err := c.Transaction(func(tx *ClusterTx) error {
+		var err error
+		names, err = query.SelectStrings(tx.tx, `
+SELECT targets.name FROM networks_peers
+  JOIN networks AS sources ON sources.id = networks_peers.network_id
+  JOIN networks AS targets ON targets.id = networks_peers.target_network_id
+ WHERE sources.name = ?
+UNION
+SELECT sources.name FROM networks_peers
+  JOIN networks AS sources ON sources.id = networks_peers.network_id
+  JOIN networks AS targets ON targets.id = networks_peers.target_network_id
+ WHERE targets.name = ?`, name, name)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch peered networks")
+	}
+
+	return names, nil
+}
+
+// Return the network peerings, filtered by the given clause.
+func (c *ClusterTx) networkPeers(where string, args ...interface{}) ([]NetworkPeer, error) {
+	peers := []NetworkPeer{}
+	dest := func(i int) []interface{} {
+		peers = append(peers, NetworkPeer{})
+		return []interface{}{
+			&peers[i].ID,
+			&peers[i].Name,
+			&peers[i].Description,
+			&peers[i].TargetNetwork,
+		}
+	}
+
+	q := fmt.Sprintf(`
+SELECT networks_peers.id, networks_peers.name, networks_peers.description, networks.name
+  FROM networks_peers
+  JOIN networks ON networks.id = networks_peers.target_network_id
+ WHERE %s
+ ORDER BY networks_peers.name`, where)
+
+	stmt, err := c.tx.Prepare(q)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	err = query.SelectObjects(stmt, dest, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch network peers")
+	}
+
+	return peers, nil
+}
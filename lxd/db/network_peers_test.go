@@ -0,0 +1,53 @@
+// +build linux,cgo,!agent
+
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Peerings are visible from both networks, and removed along with either of them.
+func TestNetworkPeers(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	frontendID, err := cluster.NetworkCreate("frontend", "", map[string]string{})
+	require.NoError(t, err)
+
+	backendID, err := cluster.NetworkCreate("backend", "", map[string]string{})
+	require.NoError(t, err)
+
+	err = cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.NetworkPeerCreate(frontendID, "db", "Databases", backendID)
+	})
+	require.NoError(t, err)
+
+	var peer db.NetworkPeer
+	err = cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		peer, err = tx.NetworkPeer(frontendID, "db")
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "backend", peer.TargetNetwork)
+	assert.Equal(t, "Databases", peer.Description)
+
+	peered, err := cluster.NetworkPeeredNetworks("frontend")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backend"}, peered)
+
+	peered, err = cluster.NetworkPeeredNetworks("backend")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"frontend"}, peered)
+
+	err = cluster.NetworkDelete("backend")
+	require.NoError(t, err)
+
+	peered, err = cluster.NetworkPeeredNetworks("frontend")
+	require.NoError(t, err)
+	assert.Len(t, peered, 0)
+}
@@ -0,0 +1,167 @@
+// +build linux,cgo,!agent
+
+package db
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db/query"
+)
+
+// NetworkReservation holds an address reserved on a network ahead of its use by an instance.
+type NetworkReservation struct {
+	ID          int64
+	Name        string
+	Description string
+	IPv4Address string // Empty if no IPv4 address is reserved
+	IPv6Address string // Empty if no IPv6 address is reserved
+
+	// Instance using the reserved addresses, if any.
+	InstanceID      int64
+	InstanceName    string
+	InstanceProject string
+}
+
+// NetworkReservations returns the address reservations of the network with the given ID.
+func (c *ClusterTx) NetworkReservations(networkID int64) ([]NetworkReservation, error) {
+	return c.networkReservations("networks_reservations.network_id = ?", networkID)
+}
+
+// NetworkReservation returns the reservation with the given name on the network with the given ID.
+func (c *ClusterTx) NetworkReservation(networkID int64, name string) (NetworkReservation, error) {
+	null := NetworkReservation{}
+	reservations, err := c.networkReservations("networks_reservations.network_id = ? AND networks_reservations.name = ?", networkID, name)
+	if err != nil {
+		return null, err
+	}
+
+	switch len(reservations) {
+	case 0:
+		return null, ErrNoSuchObject
+	case 1:
+		return reservations[0], nil
+	default:
+		return null, fmt.Errorf("More than one reservation matches")
+	}
+}
+
+// NetworkReservationCreate adds a new reservation to the network with the given ID.
+func (c *ClusterTx) NetworkReservationCreate(networkID int64, reservation NetworkReservation) error {
+	columns := []string{"network_id", "name", "description", "ipv4_address", "ipv6_address"}
+	values := []interface{}{networkID, reservation.Name, reservation.Description, reservation.IPv4Address, reservation.IPv6Address}
+	_, err := query.UpsertObject(c.tx, "networks_reservations", columns, values)
+	return err
+}
+
+// NetworkReservationUpdateDescription sets the description of the given reservation.
+func (c *ClusterTx) NetworkReservationUpdateDescription(networkID int64, name string, description string) error {
+	result, err := c.tx.Exec("UPDATE networks_reservations SET description = ? WHERE network_id = ? AND name = ?", description, networkID, name)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n != 1 {
+		return ErrNoSuchObject
+	}
+
+	return nil
+}
+
+// NetworkReservationDelete releases the given reservation.
+func (c *ClusterTx) NetworkReservationDelete(networkID int64, name string) error {
+	result, err := c.tx.Exec("DELETE FROM networks_reservations WHERE network_id = ? AND name = ?", networkID, name)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n != 1 {
+		return ErrNoSuchObject
+	}
+
+	return nil
+}
+
+// NetworkReservationsClaim records the given instance as the user of the reservations holding the
+// given addresses on the network with the given ID. Reservations already used by another instance
+// are left alone.
+func (c *ClusterTx) NetworkReservationsClaim(networkID int64, instanceID int64, addresses []string) error {
+	for _, address := range addresses {
+		_, err := c.tx.Exec(`
+UPDATE networks_reservations SET instance_id = ?
+ WHERE network_id = ? AND (ipv4_address = ? OR ipv6_address = ?) AND instance_id IS NULL`,
+			instanceID, networkID, address, address)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NetworkReservationsRelease releases the reservations holding the given addresses on the network
+// with the given ID, if used by the given instance.
+func (c *ClusterTx) NetworkReservationsRelease(networkID int64, instanceID int64, addresses []string) error {
+	for _, address := range addresses {
+		_, err := c.tx.Exec(`
+UPDATE networks_reservations SET instance_id = NULL
+ WHERE network_id = ? AND (ipv4_address = ? OR ipv6_address = ?) AND instance_id = ?`,
+			networkID, address, address, instanceID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Return the network reservations, filtered by the given clause.
+func (c *ClusterTx) networkReservations(where string, args ...interface{}) ([]NetworkReservation, error) {
+	reservations := []NetworkReservation{}
+	dest := func(i int) []interface{} {
+		reservations = append(reservations, NetworkReservation{})
+		return []interface{}{
+			&reservations[i].ID,
+			&reservations[i].Name,
+			&reservations[i].Description,
+			&reservations[i].IPv4Address,
+			&reservations[i].IPv6Address,
+			&reservations[i].InstanceID,
+			&reservations[i].InstanceName,
+			&reservations[i].InstanceProject,
+		}
+	}
+
+	q := fmt.Sprintf(`
+SELECT networks_reservations.id, networks_reservations.name, networks_reservations.description,
+       ipv4_address, ipv6_address, COALESCE(instances.id, 0), COALESCE(instances.name, ''), COALESCE(projects.name, '')
+  FROM networks_reservations
+  LEFT JOIN instances ON instances.id = networks_reservations.instance_id
+  LEFT JOIN projects ON projects.id = instances.project_id
+ WHERE %s
+ ORDER BY networks_reservations.name`, where)
+
+	stmt, err := c.tx.Prepare(q)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	err = query.SelectObjects(stmt, dest, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch network reservations")
+	}
+
+	return reservations, nil
+}
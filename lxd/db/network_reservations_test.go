@@ -0,0 +1,63 @@
+// +build linux,cgo,!agent
+
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Reservations record the instance using them, until released or the instance is deleted.
+func TestNetworkReservationsClaim(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	err := tx.NetworkCreatePending("none", "lxdbr0", map[string]string{})
+	require.NoError(t, err)
+
+	networkID, err := tx.NetworkID("lxdbr0")
+	require.NoError(t, err)
+
+	err = tx.NetworkReservationCreate(networkID, db.NetworkReservation{Name: "web", IPv4Address: "10.0.0.100"})
+	require.NoError(t, err)
+
+	instanceIDs := []int64{}
+	for _, name := range []string{"c1", "c2"} {
+		id, err := tx.InstanceCreate(db.Instance{
+			Project:      "default",
+			Name:         name,
+			Node:         "none",
+			Architecture: 1,
+			LastUseDate:  time.Now(),
+		})
+		require.NoError(t, err)
+		instanceIDs = append(instanceIDs, id)
+	}
+
+	err = tx.NetworkReservationsClaim(networkID, instanceIDs[0], []string{"10.0.0.100"})
+	require.NoError(t, err)
+
+	// Reservations used by another instance are left alone.
+	err = tx.NetworkReservationsClaim(networkID, instanceIDs[1], []string{"10.0.0.100"})
+	require.NoError(t, err)
+
+	err = tx.NetworkReservationsRelease(networkID, instanceIDs[1], []string{"10.0.0.100"})
+	require.NoError(t, err)
+
+	reservation, err := tx.NetworkReservation(networkID, "web")
+	require.NoError(t, err)
+	assert.Equal(t, instanceIDs[0], reservation.InstanceID)
+	assert.Equal(t, "c1", reservation.InstanceName)
+	assert.Equal(t, "default", reservation.InstanceProject)
+
+	err = tx.NetworkReservationsRelease(networkID, instanceIDs[0], []string{"10.0.0.100"})
+	require.NoError(t, err)
+
+	reservation, err = tx.NetworkReservation(networkID, "web")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), reservation.InstanceID)
+}
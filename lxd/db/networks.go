@@ -598,4 +598,5 @@ func (c *Cluster) NetworkRename(oldName string, newName string) error {
 // NetworkNodeConfigKeys lists all network config keys which are node-specific.
 var NetworkNodeConfigKeys = []string{
 	"bridge.external_interfaces",
+	"bridge.priority_uplinks",
 }
@@ -24,9 +24,18 @@ type ClusterRole string
 // ClusterRoleDatabase represents the database role in a cluster.
 const ClusterRoleDatabase = ClusterRole("database")
 
+// ClusterRoleDatabaseStandBy represents the stand-by database role in a
+// cluster. It's not stored, but derived from the raft configuration.
+const ClusterRoleDatabaseStandBy = ClusterRole("database-standby")
+
+// ClusterRoleEventHub represents the role of a member relaying the events of
+// all the other members.
+const ClusterRoleEventHub = ClusterRole("event-hub")
+
 // ClusterRoles maps role ids into human-readable names.
 var ClusterRoles = map[int]ClusterRole{
 	0: ClusterRoleDatabase,
+	1: ClusterRoleEventHub,
 }
 
 // NodeInfo holds information about a single LXD instance in a cluster.
@@ -619,11 +628,45 @@ func (c *ClusterTx) NodeOfflineThreshold() (time.Duration, error) {
 	return threshold, nil
 }
 
+// NodeHeartbeatInterval returns the amount of time between two rounds of
+// heartbeats sent by the leader.
+func (c *ClusterTx) NodeHeartbeatInterval() (time.Duration, error) {
+	interval := time.Duration(DefaultHeartbeatInterval) * time.Second
+	values, err := query.SelectStrings(
+		c.tx, "SELECT value FROM config WHERE key='cluster.heartbeat_interval'")
+	if err != nil {
+		return -1, err
+	}
+	if len(values) > 0 {
+		seconds, err := strconv.Atoi(values[0])
+		if err != nil {
+			return -1, err
+		}
+		interval = time.Duration(seconds) * time.Second
+	}
+	return interval, nil
+}
+
 // NodeWithLeastContainers returns the name of the non-offline node with with
 // the least number of containers (either already created or being created with
 // an operation). If archs is not empty, then return only nodes with an
 // architecture in that list.
 func (c *ClusterTx) NodeWithLeastContainers(archs []int) (string, error) {
+	return c.NodeWithLeastContainersAvoiding(archs, nil)
+}
+
+// NodeWithLeastContainersAvoiding is like NodeWithLeastContainers, but first
+// prefers the nodes with the lowest count in the given map of node names to
+// number of instances to keep apart from, such as the members of an instance
+// group.
+func (c *ClusterTx) NodeWithLeastContainersAvoiding(archs []int, avoid map[string]int) (string, error) {
+	return c.NodeWithLeastContainersInGroups(archs, nil, avoid)
+}
+
+// NodeWithLeastContainersInGroups is like NodeWithLeastContainersAvoiding, but
+// if groups is not nil, then return only nodes which are members of at least
+// one of the given cluster groups.
+func (c *ClusterTx) NodeWithLeastContainersInGroups(archs []int, groups []string, avoid map[string]int) (string, error) {
 	threshold, err := c.NodeOfflineThreshold()
 	if err != nil {
 		return "", errors.Wrap(err, "failed to get offline threshold")
@@ -634,8 +677,23 @@ func (c *ClusterTx) NodeWithLeastContainers(archs []int) (string, error) {
 		return "", errors.Wrap(err, "failed to get current nodes")
 	}
 
+	members := []string{}
+	for _, group := range groups {
+		names, err := query.SelectStrings(c.tx, `
+SELECT nodes.name FROM nodes
+  JOIN nodes_cluster_groups ON nodes_cluster_groups.node_id = nodes.id
+  JOIN cluster_groups ON cluster_groups.id = nodes_cluster_groups.group_id
+ WHERE cluster_groups.name = ?`, group)
+		if err != nil {
+			return "", errors.Wrap(err, "Failed to get cluster group members")
+		}
+
+		members = append(members, names...)
+	}
+
 	name := ""
 	containers := -1
+	avoided := -1
 	for _, node := range nodes {
 		if node.IsOffline(threshold) {
 			continue
@@ -645,6 +703,10 @@ func (c *ClusterTx) NodeWithLeastContainers(archs []int) (string, error) {
 			continue
 		}
 
+		if groups != nil && !shared.StringInSlice(node.Name, members) {
+			continue
+		}
+
 		// Fetch the number of containers already created on this node.
 		created, err := query.Count(c.tx, "instances", "node_id=?", node.ID)
 		if err != nil {
@@ -659,7 +721,8 @@ func (c *ClusterTx) NodeWithLeastContainers(archs []int) (string, error) {
 		}
 
 		count := created + pending
-		if containers == -1 || count < containers {
+		if avoided == -1 || avoid[node.Name] < avoided || (avoid[node.Name] == avoided && count < containers) {
+			avoided = avoid[node.Name]
 			containers = count
 			name = node.Name
 		}
@@ -696,3 +759,7 @@ func nodeIsOffline(threshold time.Duration, heartbeat time.Time) bool {
 // DefaultOfflineThreshold is the default value for the
 // cluster.offline_threshold configuration key, expressed in seconds.
 const DefaultOfflineThreshold = 20
+
+// DefaultHeartbeatInterval is the default value for the
+// cluster.heartbeat_interval configuration key, expressed in seconds.
+const DefaultHeartbeatInterval = 10
@@ -343,6 +343,49 @@ INSERT INTO instances (id, node_id, name, architecture, type, project_id) VALUES
 	assert.Equal(t, "buzz", name)
 }
 
+// Nodes hosting fewer of the instances to avoid are preferred, whatever their
+// total number of containers.
+func TestNodeWithLeastContainers_Avoiding(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	_, err := tx.NodeAdd("buzz", "1.2.3.4:666")
+	require.NoError(t, err)
+
+	// Add a container to the default node (ID 1)
+	_, err = tx.Tx().Exec(`
+INSERT INTO instances (id, node_id, name, architecture, type, project_id) VALUES (1, 1, 'foo', 1, 1, 1)
+`)
+	require.NoError(t, err)
+
+	name, err := tx.NodeWithLeastContainersAvoiding(nil, map[string]int{"buzz": 1})
+	require.NoError(t, err)
+	assert.Equal(t, "none", name)
+}
+
+// Only members of the given cluster groups are considered, whatever their
+// number of containers.
+func TestNodeWithLeastContainers_Groups(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	_, err := tx.NodeAdd("buzz", "1.2.3.4:666")
+	require.NoError(t, err)
+
+	_, err = tx.ClusterGroupCreate("rack1", "", []string{"none"})
+	require.NoError(t, err)
+
+	// Add a container to the default node (ID 1)
+	_, err = tx.Tx().Exec(`
+INSERT INTO instances (id, node_id, name, architecture, type, project_id) VALUES (1, 1, 'foo', 1, 1, 1)
+`)
+	require.NoError(t, err)
+
+	name, err := tx.NodeWithLeastContainersInGroups(nil, []string{"rack1"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "none", name)
+}
+
 // If there are 2 online nodes, and a container is pending on one of them,
 // return the address of the other one number of containers.
 func TestNodeWithLeastContainers_Pending(t *testing.T) {
@@ -0,0 +1,134 @@
+// +build linux,cgo,!agent
+
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db/query"
+)
+
+// OperationHistoryEntry holds a completed operation.
+type OperationHistoryEntry struct {
+	UUID       string    // UUID of the operation
+	Project    string    // Project the operation belonged to, if any
+	Type       string    // Description of the operation type
+	Requestor  string    // User who requested the operation, if any
+	FinishedAt time.Time // Time at which the operation completed
+	Operation  string    // JSON encoded final state of the operation
+}
+
+// OperationHistoryAdd records a completed operation.
+func (c *ClusterTx) OperationHistoryAdd(entry OperationHistoryEntry) error {
+	columns := []string{"uuid", "project", "type", "requestor", "finished_at", "operation"}
+	values := []interface{}{entry.UUID, entry.Project, entry.Type, entry.Requestor, entry.FinishedAt.UTC(), entry.Operation}
+	_, err := query.UpsertObject(c.tx, "operations_history", columns, values)
+	return err
+}
+
+// OperationHistoryFilter can be used to filter the completed operations returned by
+// OperationsHistory.
+type OperationHistoryFilter struct {
+	Project   string // Only operations of this project or not tied to any, if set
+	Type      string // Only operations of this type, if set
+	Requestor string // Only operations requested by this user, if set
+	Limit     int    // Maximum number of operations returned, if set
+	Offset    int    // Number of matching operations skipped, starting with the most recent one
+}
+
+// OperationsHistory returns the completed operations matching the given filter, starting with the
+// most recent ones.
+func (c *ClusterTx) OperationsHistory(filter OperationHistoryFilter) ([]OperationHistoryEntry, error) {
+	sql := `
+SELECT uuid, project, type, requestor, finished_at, operation
+  FROM operations_history
+`
+	where := []string{}
+	args := []interface{}{}
+	if filter.Project != "" {
+		where = append(where, "(project = ? OR project = '')")
+		args = append(args, filter.Project)
+	}
+
+	if filter.Type != "" {
+		where = append(where, "type = ?")
+		args = append(args, filter.Type)
+	}
+
+	if filter.Requestor != "" {
+		where = append(where, "requestor = ?")
+		args = append(args, filter.Requestor)
+	}
+
+	if len(where) > 0 {
+		sql += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	sql += " ORDER BY finished_at DESC, id DESC"
+
+	if filter.Limit > 0 {
+		sql += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	return c.operationsHistory(sql, args...)
+}
+
+// OperationHistoryByUUID returns the completed operation with the given UUID.
+func (c *ClusterTx) OperationHistoryByUUID(uuid string) (OperationHistoryEntry, error) {
+	entries, err := c.operationsHistory(`
+SELECT uuid, project, type, requestor, finished_at, operation
+  FROM operations_history
+ WHERE uuid = ?
+`, uuid)
+	if err != nil {
+		return OperationHistoryEntry{}, err
+	}
+
+	switch len(entries) {
+	case 0:
+		return OperationHistoryEntry{}, ErrNoSuchObject
+	case 1:
+		return entries[0], nil
+	default:
+		return OperationHistoryEntry{}, fmt.Errorf("More than one operation matches")
+	}
+}
+
+// OperationsHistoryPrune removes the operations which completed before the given time.
+func (c *ClusterTx) OperationsHistoryPrune(before time.Time) error {
+	_, err := c.tx.Exec("DELETE FROM operations_history WHERE finished_at < ?", before.UTC())
+	return err
+}
+
+func (c *ClusterTx) operationsHistory(sql string, args ...interface{}) ([]OperationHistoryEntry, error) {
+	entries := []OperationHistoryEntry{}
+	dest := func(i int) []interface{} {
+		entries = append(entries, OperationHistoryEntry{})
+		return []interface{}{
+			&entries[i].UUID,
+			&entries[i].Project,
+			&entries[i].Type,
+			&entries[i].Requestor,
+			&entries[i].FinishedAt,
+			&entries[i].Operation,
+		}
+	}
+
+	stmt, err := c.tx.Prepare(sql)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	err = query.SelectObjects(stmt, dest, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch operations history")
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,86 @@
+// +build linux,cgo,!agent
+
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Record completed operations, read them back and prune the old ones.
+func TestOperationsHistory(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	now := time.Now()
+
+	err := tx.OperationHistoryAdd(db.OperationHistoryEntry{
+		UUID:       "abcd",
+		Project:    "default",
+		Type:       "Starting container",
+		Requestor:  "alice",
+		FinishedAt: now.Add(-2 * time.Hour),
+		Operation:  "{}",
+	})
+	require.NoError(t, err)
+
+	err = tx.OperationHistoryAdd(db.OperationHistoryEntry{
+		UUID:       "efgh",
+		Project:    "other",
+		Type:       "Stopping container",
+		FinishedAt: now,
+		Operation:  "{}",
+	})
+	require.NoError(t, err)
+
+	err = tx.OperationHistoryAdd(db.OperationHistoryEntry{
+		UUID:       "ijkl",
+		Type:       "Trimming storage pool",
+		FinishedAt: now.Add(-time.Minute),
+		Operation:  "{}",
+	})
+	require.NoError(t, err)
+
+	entries, err := tx.OperationsHistory(db.OperationHistoryFilter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "efgh", entries[0].UUID)
+	assert.Equal(t, "ijkl", entries[1].UUID)
+	assert.Equal(t, "abcd", entries[2].UUID)
+
+	// Operations not tied to a project are part of all of them.
+	entries, err = tx.OperationsHistory(db.OperationHistoryFilter{Project: "default"})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "ijkl", entries[0].UUID)
+	assert.Equal(t, "alice", entries[1].Requestor)
+
+	entries, err = tx.OperationsHistory(db.OperationHistoryFilter{Type: "Stopping container"})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "efgh", entries[0].UUID)
+
+	entries, err = tx.OperationsHistory(db.OperationHistoryFilter{Requestor: "alice"})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "abcd", entries[0].UUID)
+
+	entries, err = tx.OperationsHistory(db.OperationHistoryFilter{Limit: 1, Offset: 1})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "ijkl", entries[0].UUID)
+
+	entry, err := tx.OperationHistoryByUUID("efgh")
+	require.NoError(t, err)
+	assert.Equal(t, "Stopping container", entry.Type)
+
+	err = tx.OperationsHistoryPrune(now.Add(-time.Hour))
+	require.NoError(t, err)
+
+	_, err = tx.OperationHistoryByUUID("abcd")
+	assert.Equal(t, db.ErrNoSuchObject, err)
+}
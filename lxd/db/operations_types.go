@@ -56,6 +56,15 @@ const (
 	OperationBackupsExpire
 	OperationSnapshotsExpire
 	OperationCustomVolumeSnapshotsExpire
+	OperationOrphansPrune
+	OperationSnapshotFilesRestore
+	OperationSnapshotMount
+	OperationSnapshotUnmount
+	OperationContainerRebuild
+	OperationInstanceStateUpdate
+	OperationInstanceReplicationSync
+	OperationStoragePoolTrim
+	OperationSnapshotDiff
 )
 
 // Description return a human-readable description of the operation type.
@@ -153,6 +162,24 @@ func (t OperationType) Description() string {
 		return "Cleaning up expired instance snapshots"
 	case OperationCustomVolumeSnapshotsExpire:
 		return "Cleaning up expired volume snapshots"
+	case OperationOrphansPrune:
+		return "Pruning orphaned artifacts"
+	case OperationSnapshotFilesRestore:
+		return "Restoring files from snapshot"
+	case OperationSnapshotMount:
+		return "Mounting snapshot"
+	case OperationSnapshotUnmount:
+		return "Unmounting snapshot"
+	case OperationContainerRebuild:
+		return "Rebuilding container"
+	case OperationInstanceStateUpdate:
+		return "Updating instance state"
+	case OperationInstanceReplicationSync:
+		return "Syncing instance replica"
+	case OperationStoragePoolTrim:
+		return "Trimming storage pool"
+	case OperationSnapshotDiff:
+		return "Comparing snapshot"
 	default:
 		return "Executing operation"
 	}
@@ -193,6 +220,8 @@ func (t OperationType) Permission() string {
 		return "operate-containers"
 	case OperationSnapshotDelete:
 		return "operate-containers"
+	case OperationInstanceStateUpdate:
+		return "operate-containers"
 
 	case OperationContainerCreate:
 		return "manage-containers"
@@ -208,6 +237,16 @@ func (t OperationType) Permission() string {
 		return "manage-containers"
 	case OperationSnapshotRestore:
 		return "manage-containers"
+	case OperationSnapshotFilesRestore:
+		return "manage-containers"
+	case OperationSnapshotMount:
+		return "manage-containers"
+	case OperationSnapshotUnmount:
+		return "manage-containers"
+	case OperationContainerRebuild:
+		return "manage-containers"
+	case OperationInstanceReplicationSync:
+		return "manage-containers"
 
 	case OperationImageDownload:
 		return "manage-images"
@@ -5,6 +5,9 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/shared/api"
@@ -84,13 +87,11 @@ type ProfileFilter struct {
 // Profiles returns a string list of profiles.
 func (c *Cluster) Profiles(project string) ([]string, error) {
 	err := c.Transaction(func(tx *ClusterTx) error {
-		enabled, err := tx.ProjectHasProfiles(project)
+		profilesProject, err := tx.ProjectProfilesProject(project)
 		if err != nil {
 			return errors.Wrap(err, "Check if project has profiles")
 		}
-		if !enabled {
-			project = "default"
-		}
+		project = profilesProject
 		return nil
 	})
 	if err != nil {
@@ -125,13 +126,11 @@ func (c *Cluster) ProfileGet(project, name string) (int64, *api.Profile, error)
 	var id int64
 
 	err := c.Transaction(func(tx *ClusterTx) error {
-		enabled, err := tx.ProjectHasProfiles(project)
+		profilesProject, err := tx.ProjectProfilesProject(project)
 		if err != nil {
 			return errors.Wrap(err, "Check if project has profiles")
 		}
-		if !enabled {
-			project = "default"
-		}
+		project = profilesProject
 
 		profile, err := tx.ProfileGet(project, name)
 		if err != nil {
@@ -155,13 +154,11 @@ func (c *Cluster) ProfilesGet(project string, names []string) ([]api.Profile, er
 	profiles := make([]api.Profile, len(names))
 
 	err := c.Transaction(func(tx *ClusterTx) error {
-		enabled, err := tx.ProjectHasProfiles(project)
+		profilesProject, err := tx.ProjectProfilesProject(project)
 		if err != nil {
 			return errors.Wrap(err, "Check if project has profiles")
 		}
-		if !enabled {
-			project = "default"
-		}
+		project = profilesProject
 
 		for i, name := range names {
 			profile, err := tx.ProfileGet(project, name)
@@ -183,13 +180,11 @@ func (c *Cluster) ProfilesGet(project string, names []string) ([]api.Profile, er
 // ProfileConfig gets the profile configuration map from the DB.
 func (c *Cluster) ProfileConfig(project, name string) (map[string]string, error) {
 	err := c.Transaction(func(tx *ClusterTx) error {
-		enabled, err := tx.ProjectHasProfiles(project)
+		profilesProject, err := tx.ProjectProfilesProject(project)
 		if err != nil {
 			return errors.Wrap(err, "Check if project has profiles")
 		}
-		if !enabled {
-			project = "default"
-		}
+		project = profilesProject
 		return nil
 	})
 	if err != nil {
@@ -295,13 +290,11 @@ func ProfileConfigAdd(tx *sql.Tx, id int64, config map[string]string) error {
 // profile with the given name.
 func (c *Cluster) ProfileContainersGet(project, profile string) (map[string][]string, error) {
 	err := c.Transaction(func(tx *ClusterTx) error {
-		enabled, err := tx.ProjectHasProfiles(project)
+		profilesProject, err := tx.ProjectProfilesProject(project)
 		if err != nil {
 			return errors.Wrap(err, "Check if project has profiles")
 		}
-		if !enabled {
-			project = "default"
-		}
+		project = profilesProject
 		return nil
 	})
 	if err != nil {
@@ -378,9 +371,107 @@ func ProfilesExpandConfig(config map[string]string, profiles []api.Profile) map[
 	return expandedConfig
 }
 
+// ProfilesConflicts returns a description of each config key and device
+// which more than one of the given profiles define with different values, in
+// which case the last profile silently wins. Keys and devices which are set
+// in the given instance config and devices aren't conflicts, since the
+// instance value is the one in use.
+func ProfilesConflicts(config map[string]string, devices deviceConfig.Devices, profiles []api.Profile) []string {
+	conflicts := []string{}
+
+	// Index of the first profile defining each key and device.
+	configOwners := map[string]int{}
+	deviceOwners := map[string]int{}
+
+	for i, profile := range profiles {
+		keys := make([]string, 0, len(profile.Config))
+		for k := range profile.Config {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			_, ok := config[k]
+			if ok {
+				continue
+			}
+
+			owner, ok := configOwners[k]
+			if !ok {
+				configOwners[k] = i
+				continue
+			}
+
+			if profiles[owner].Config[k] != profile.Config[k] {
+				conflicts = append(conflicts, fmt.Sprintf("Config key %q is set to different values by profiles %q and %q", k, profiles[owner].Name, profile.Name))
+			}
+		}
+
+		names := make([]string, 0, len(profile.Devices))
+		for k := range profile.Devices {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+
+		for _, k := range names {
+			// Device templates never conflict, they get a name of
+			// their own.
+			if strings.Contains(k, profileDeviceTemplateMarker) {
+				continue
+			}
+
+			_, ok := devices[k]
+			if ok {
+				continue
+			}
+
+			owner, ok := deviceOwners[k]
+			if !ok {
+				deviceOwners[k] = i
+				continue
+			}
+
+			ownerDevices := deviceConfig.NewDevices(profiles[owner].Devices)
+			if !ownerDevices.Contains(k, deviceConfig.Device(profile.Devices[k])) {
+				conflicts = append(conflicts, fmt.Sprintf("Device %q is defined differently by profiles %q and %q", k, profiles[owner].Name, profile.Name))
+			}
+		}
+	}
+
+	return conflicts
+}
+
 // ProfilesExpandDevices expands the given container devices with the devices
 // defined in the given profiles.
-func ProfilesExpandDevices(devices deviceConfig.Devices, profiles []api.Profile) deviceConfig.Devices {
+//
+// Profile devices whose name contains a "#" are templates, expanded to the
+// first name not used by any other device by replacing the "#" with a number
+// (e.g. "eth#" becomes "eth1" if "eth0" exists). The same number is used in
+// the device "name" key, if it contains a "#" too. The numbers recorded in the
+// given container config (see ProfilesDeviceTemplateKeys) are used first, so
+// that templates keep their name when other devices are added.
+func ProfilesExpandDevices(devices deviceConfig.Devices, profiles []api.Profile, config map[string]string) deviceConfig.Devices {
+	expandedDevices, _ := profilesExpandDevices(devices, profiles, config)
+	return expandedDevices
+}
+
+// ProfilesDeviceTemplateKeys returns the volatile keys recording the numbers
+// picked for the device templates of the given profiles, which aren't recorded
+// in the given container config yet.
+func ProfilesDeviceTemplateKeys(devices deviceConfig.Devices, profiles []api.Profile, config map[string]string) map[string]string {
+	_, numbers := profilesExpandDevices(devices, profiles, config)
+
+	keys := map[string]string{}
+	for key, number := range numbers {
+		if config[key] != number {
+			keys[key] = number
+		}
+	}
+
+	return keys
+}
+
+func profilesExpandDevices(devices deviceConfig.Devices, profiles []api.Profile, config map[string]string) (deviceConfig.Devices, map[string]string) {
 	expandedDevices := deviceConfig.Devices{}
 
 	// Apply all the profiles
@@ -390,6 +481,10 @@ func ProfilesExpandDevices(devices deviceConfig.Devices, profiles []api.Profile)
 	}
 	for i := range profileDevices {
 		for k, v := range profileDevices[i] {
+			if strings.Contains(k, profileDeviceTemplateMarker) {
+				continue
+			}
+
 			expandedDevices[k] = v
 		}
 	}
@@ -399,5 +494,86 @@ func ProfilesExpandDevices(devices deviceConfig.Devices, profiles []api.Profile)
 		expandedDevices[k] = v
 	}
 
-	return expandedDevices
+	// List the device templates, in profile order
+	type deviceTemplate struct {
+		key    string
+		name   string
+		device deviceConfig.Device
+	}
+
+	templates := []deviceTemplate{}
+	for i := range profileDevices {
+		names := make([]string, 0, len(profileDevices[i]))
+		for k := range profileDevices[i] {
+			if strings.Contains(k, profileDeviceTemplateMarker) {
+				names = append(names, k)
+			}
+		}
+		sort.Strings(names)
+
+		for _, k := range names {
+			key := fmt.Sprintf("volatile.template.%s.%s", profiles[i].Name, k)
+			templates = append(templates, deviceTemplate{key: key, name: k, device: profileDevices[i][k]})
+		}
+	}
+
+	// Expand the templates with a recorded number first, then the others
+	numbers := map[string]string{}
+	for _, template := range templates {
+		n, err := strconv.Atoi(config[template.key])
+		if err != nil || n < 0 {
+			continue
+		}
+
+		if profileExpandDeviceTemplate(expandedDevices, template.name, template.device, n) {
+			numbers[template.key] = strconv.Itoa(n)
+		}
+	}
+
+	for _, template := range templates {
+		if numbers[template.key] != "" {
+			continue
+		}
+
+		for n := 0; ; n++ {
+			if profileExpandDeviceTemplate(expandedDevices, template.name, template.device, n) {
+				numbers[template.key] = strconv.Itoa(n)
+				break
+			}
+		}
+	}
+
+	return expandedDevices, numbers
+}
+
+// Character marking the position of the number in device name templates.
+const profileDeviceTemplateMarker = "#"
+
+// Add the given device template to the devices using the given number, unless
+// it clashes with the name or interface name of an existing device.
+func profileExpandDeviceTemplate(devices deviceConfig.Devices, template string, device deviceConfig.Device, n int) bool {
+	used := map[string]bool{}
+	for k, v := range devices {
+		used[k] = true
+		if v["name"] != "" {
+			used[v["name"]] = true
+		}
+	}
+
+	number := strconv.Itoa(n)
+	name := strings.Replace(template, profileDeviceTemplateMarker, number, -1)
+	if used[name] {
+		return false
+	}
+
+	expanded := device.Clone()
+	if strings.Contains(expanded["name"], profileDeviceTemplateMarker) {
+		expanded["name"] = strings.Replace(expanded["name"], profileDeviceTemplateMarker, number, -1)
+		if expanded["name"] != name && used[expanded["name"]] {
+			return false
+		}
+	}
+
+	devices[name] = expanded
+	return true
 }
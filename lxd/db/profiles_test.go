@@ -0,0 +1,97 @@
+// +build linux,cgo,!agent
+
+package db_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/lxd/db"
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestProfilesConflicts(t *testing.T) {
+	profiles := []api.Profile{
+		{
+			Name: "default",
+			ProfilePut: api.ProfilePut{
+				Config:  map[string]string{"limits.cpu": "2", "limits.memory": "1GB"},
+				Devices: map[string]map[string]string{"eth0": {"type": "nic", "nictype": "bridged", "parent": "lxdbr0"}},
+			},
+		},
+		{
+			Name: "big",
+			ProfilePut: api.ProfilePut{
+				Config: map[string]string{"limits.cpu": "2", "limits.memory": "4GB"},
+			},
+		},
+		{
+			Name: "macvlan",
+			ProfilePut: api.ProfilePut{
+				Devices: map[string]map[string]string{"eth0": {"type": "nic", "nictype": "macvlan", "parent": "eth0"}},
+			},
+		},
+	}
+
+	conflicts := db.ProfilesConflicts(nil, nil, profiles)
+	assert.Equal(t, []string{
+		`Config key "limits.memory" is set to different values by profiles "default" and "big"`,
+		`Device "eth0" is defined differently by profiles "default" and "macvlan"`,
+	}, conflicts)
+
+	// Keys and devices set on the instance itself override the profiles.
+	config := map[string]string{"limits.memory": "2GB"}
+	devices := deviceConfig.Devices{"eth0": {"type": "nic", "nictype": "p2p"}}
+	conflicts = db.ProfilesConflicts(config, devices, profiles)
+	assert.Len(t, conflicts, 0)
+}
+
+// Device templates in profiles get the first free number.
+func TestProfilesExpandDevices_Templates(t *testing.T) {
+	profiles := []api.Profile{
+		{
+			Name: "default",
+			ProfilePut: api.ProfilePut{
+				Devices: map[string]map[string]string{
+					"eth0": {"type": "nic", "nictype": "bridged", "parent": "lxdbr0", "name": "eth0"},
+					"eth#": {"type": "nic", "nictype": "bridged", "parent": "br1", "name": "eth#"},
+				},
+			},
+		},
+		{
+			Name: "storage",
+			ProfilePut: api.ProfilePut{
+				Devices: map[string]map[string]string{
+					"eth#": {"type": "nic", "nictype": "macvlan", "parent": "eno2", "name": "eth#"},
+				},
+			},
+		},
+	}
+
+	devices := deviceConfig.Devices{
+		"eth1": {"type": "nic", "nictype": "p2p", "name": "eth1"},
+	}
+
+	expanded := db.ProfilesExpandDevices(devices, profiles, nil)
+	assert.Equal(t, deviceConfig.Devices{
+		"eth0": {"type": "nic", "nictype": "bridged", "parent": "lxdbr0", "name": "eth0"},
+		"eth1": {"type": "nic", "nictype": "p2p", "name": "eth1"},
+		"eth2": {"type": "nic", "nictype": "bridged", "parent": "br1", "name": "eth2"},
+		"eth3": {"type": "nic", "nictype": "macvlan", "parent": "eno2", "name": "eth3"},
+	}, expanded)
+
+	// Templates don't conflict with each other.
+	assert.Len(t, db.ProfilesConflicts(nil, devices, profiles), 0)
+
+	// The picked numbers are recorded, and kept when another device takes their place.
+	keys := db.ProfilesDeviceTemplateKeys(devices, profiles, nil)
+	assert.Equal(t, map[string]string{"volatile.template.default.eth#": "2", "volatile.template.storage.eth#": "3"}, keys)
+
+	devices["eth2"] = deviceConfig.Device{"type": "nic", "nictype": "p2p", "name": "eth2"}
+	expanded = db.ProfilesExpandDevices(devices, profiles, map[string]string{"volatile.template.storage.eth#": "3"})
+	assert.Equal(t, "br1", expanded["eth4"]["parent"])
+	assert.Equal(t, "eno2", expanded["eth3"]["parent"])
+	assert.Len(t, db.ProfilesDeviceTemplateKeys(devices, profiles, keys), 1)
+}
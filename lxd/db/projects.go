@@ -48,12 +48,6 @@ type ProjectFilter struct {
 	Name string // If non-empty, return only the project with this name.
 }
 
-// ProjectHasProfiles is a helper to check if a project has the profiles
-// feature enabled.
-func (c *ClusterTx) ProjectHasProfiles(name string) (bool, error) {
-	return projectHasProfiles(c.tx, name)
-}
-
 // ProjectNames returns the names of all available projects.
 func (c *ClusterTx) ProjectNames() ([]string, error) {
 	stmt := "SELECT name FROM projects"
@@ -97,36 +91,98 @@ func (c *ClusterTx) ProjectMap() (map[int64]string, error) {
 	return result, nil
 }
 
-func projectHasProfiles(tx *sql.Tx, name string) (bool, error) {
+// ProjectProfilesProject returns the name of the project whose profiles are
+// used by the given project.
+func (c *ClusterTx) ProjectProfilesProject(name string) (string, error) {
+	return projectFeatureProject(c.tx, name, "features.profiles")
+}
+
+// ProjectImagesProject returns the name of the project whose images are used
+// by the given project.
+func (c *ClusterTx) ProjectImagesProject(name string) (string, error) {
+	exists, err := c.ProjectExists(name)
+	if err != nil {
+		return "", errors.Wrap(err, "fetch project")
+	}
+
+	if !exists {
+		return "", errors.Wrap(ErrNoSuchObject, "fetch project")
+	}
+
+	return projectFeatureProject(c.tx, name, "features.images")
+}
+
+// Return the project providing the given feature to the given project.
+//
+// That's the project itself if it has the feature enabled, otherwise the
+// closest of its ancestors (as set by the "parent" config key) which has it
+// enabled. Projects without a parent inherit from the default project.
+func projectFeatureProject(tx *sql.Tx, name string, feature string) (string, error) {
+	return projectFeatureResolve(name, feature, func(name string) (map[string]string, error) {
+		return projectConfig(tx, name)
+	})
+}
+
+// projectFeatureResolve walks up the chain of parents of the given project
+// until it finds one which has the given feature enabled, using the config
+// function to get the configuration of each project.
+func projectFeatureResolve(name string, feature string, config func(name string) (map[string]string, error)) (string, error) {
+	seen := map[string]bool{}
+	for name != "default" {
+		if seen[name] {
+			return "", fmt.Errorf("Project %q is part of a parent cycle", name)
+		}
+		seen[name] = true
+
+		values, err := config(name)
+		if err != nil {
+			return "", err
+		}
+
+		if shared.IsTrue(values[feature]) {
+			return name, nil
+		}
+
+		name = values["parent"]
+		if name == "" {
+			name = "default"
+		}
+	}
+
+	return "default", nil
+}
+
+// Return the configuration of the given project.
+func projectConfig(tx *sql.Tx, name string) (map[string]string, error) {
 	stmt := `
-SELECT projects_config.value
+SELECT projects_config.key, projects_config.value
   FROM projects_config
   JOIN projects ON projects.id=projects_config.project_id
- WHERE projects.name=? AND projects_config.key='features.profiles'
+ WHERE projects.name=?
 `
-	values, err := query.SelectStrings(tx, stmt, name)
+	rows, err := tx.Query(stmt, name)
 	if err != nil {
-		return false, errors.Wrap(err, "Fetch project config")
+		return nil, errors.Wrap(err, "Fetch project config")
 	}
+	defer rows.Close()
 
-	if len(values) == 0 {
-		return false, nil
-	}
+	config := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		err := rows.Scan(&key, &value)
+		if err != nil {
+			return nil, errors.Wrap(err, "Fetch project config")
+		}
 
-	return shared.IsTrue(values[0]), nil
-}
+		config[key] = value
+	}
 
-// ProjectHasImages is a helper to check if a project has the images
-// feature enabled.
-func (c *ClusterTx) ProjectHasImages(name string) (bool, error) {
-	project, err := c.ProjectGet(name)
+	err = rows.Err()
 	if err != nil {
-		return false, errors.Wrap(err, "fetch project")
+		return nil, errors.Wrap(err, "Fetch project config")
 	}
 
-	enabled := shared.IsTrue(project.Config["features.images"])
-
-	return enabled, nil
+	return config, nil
 }
 
 // ProjectUpdate updates the project matching the given key parameters.
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -20,3 +21,44 @@ func TestProjectsList(t *testing.T) {
 	assert.Len(t, project.UsedBy, 1)
 	assert.Equal(t, "/1.0/profiles/default?project=default", project.UsedBy[0])
 }
+
+// Projects without a feature use the one of their closest ancestor which has
+// it, or the default project.
+func TestProjectProfilesProject(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	projects := []api.ProjectsPost{
+		{Name: "parent", ProjectPut: api.ProjectPut{Config: map[string]string{"features.profiles": "true"}}},
+		{Name: "child", ProjectPut: api.ProjectPut{Config: map[string]string{"parent": "parent"}}},
+		{Name: "grandchild", ProjectPut: api.ProjectPut{Config: map[string]string{"parent": "child"}}},
+		{Name: "orphan", ProjectPut: api.ProjectPut{Config: map[string]string{"features.images": "true"}}},
+	}
+
+	for _, project := range projects {
+		_, err := tx.ProjectCreate(project)
+		require.NoError(t, err)
+	}
+
+	cases := map[string]string{
+		"default":    "default",
+		"parent":     "parent",
+		"child":      "parent",
+		"grandchild": "parent",
+		"orphan":     "default",
+	}
+
+	for name, expected := range cases {
+		profilesProject, err := tx.ProjectProfilesProject(name)
+		require.NoError(t, err)
+		assert.Equal(t, expected, profilesProject, name)
+	}
+
+	imagesProject, err := tx.ProjectImagesProject("grandchild")
+	require.NoError(t, err)
+	assert.Equal(t, "default", imagesProject)
+
+	imagesProject, err = tx.ProjectImagesProject("orphan")
+	require.NoError(t, err)
+	assert.Equal(t, "orphan", imagesProject)
+}
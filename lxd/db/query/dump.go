@@ -1,12 +1,15 @@
 package query
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/pkg/errors"
 )
@@ -111,9 +114,14 @@ func dumpTable(tx *sql.Tx, table, schema string) (string, error) {
 			case int64:
 				values[j] = strconv.FormatInt(v, 10)
 			case string:
-				values[j] = fmt.Sprintf("'%s'", v)
+				values[j] = dumpQuote(v)
 			case []byte:
-				values[j] = fmt.Sprintf("'%s'", string(v))
+				// Text may be returned as bytes, only write actual binary data as a blob.
+				if utf8.Valid(v) && bytes.IndexByte(v, 0) < 0 {
+					values[j] = dumpQuote(string(v))
+				} else {
+					values[j] = fmt.Sprintf("X'%s'", hex.EncodeToString(v))
+				}
 			case time.Time:
 				values[j] = strconv.FormatInt(v.Unix(), 10)
 			default:
@@ -129,6 +137,11 @@ func dumpTable(tx *sql.Tx, table, schema string) (string, error) {
 	return strings.Join(statements, "\n") + "\n", nil
 }
 
+// Return the given string as a SQL literal.
+func dumpQuote(s string) string {
+	return fmt.Sprintf("'%s'", strings.Replace(s, "'", "''", -1))
+}
+
 // Schema of the schema table.
 const dumpSchemaTable = `CREATE TABLE schema (
     id         INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
@@ -136,3 +149,85 @@ const dumpSchemaTable = `CREATE TABLE schema (
     updated_at DATETIME NOT NULL,
     UNIQUE (version)
 );`
+
+// Restore replaces the rows of all tables defined in the given schema with the
+// rows contained in a SQL text dump generated by Dump. The schema table and
+// the sqlite_sequence table are left untouched, so the dump must have been
+// taken from a database with the same schema version.
+func Restore(tx *sql.Tx, schema string, dump string) error {
+	tables := dumpParseSchema(schema)
+
+	// Rows get inserted table by table in alphabetical order, so references
+	// between tables can only be checked once everything is in place.
+	_, err := tx.Exec("PRAGMA defer_foreign_keys=ON")
+	if err != nil {
+		return errors.Wrap(err, "failed to defer foreign keys")
+	}
+
+	for table := range tables {
+		_, err := tx.Exec(fmt.Sprintf("DELETE FROM %s", table))
+		if err != nil {
+			return errors.Wrapf(err, "failed to clear table %s", table)
+		}
+	}
+
+	for _, statement := range restoreParseInserts(dump) {
+		table := strings.Fields(statement)[2]
+		_, ok := tables[table]
+		if !ok {
+			continue
+		}
+
+		_, err := tx.Exec(statement)
+		if err != nil {
+			return errors.Wrapf(err, "failed to restore row in table %s", table)
+		}
+	}
+
+	return nil
+}
+
+// Return the INSERT statements contained in a SQL text dump generated by Dump.
+// Since text values may contain new lines, a statement may span several lines,
+// and a line only starts a new statement if it's not part of a text value.
+func restoreParseInserts(dump string) []string {
+	prefixes := []string{"INSERT INTO ", "CREATE TABLE ", "DELETE FROM ", "PRAGMA ", "BEGIN TRANSACTION;", "COMMIT;"}
+
+	statements := []string{}
+	current := ""
+	quoted := false
+	flush := func() {
+		if strings.HasPrefix(current, "INSERT INTO ") {
+			statements = append(statements, current)
+		}
+		current = ""
+	}
+
+	for _, line := range strings.Split(dump, "\n") {
+		isStart := false
+		for _, prefix := range prefixes {
+			if !quoted && strings.HasPrefix(line, prefix) {
+				isStart = true
+				break
+			}
+		}
+
+		// Quotes within values are doubled, so each one flips whether we're in a value.
+		if strings.Count(line, "'")%2 == 1 {
+			quoted = !quoted
+		}
+
+		if isStart {
+			flush()
+			current = line
+			continue
+		}
+
+		if current != "" {
+			current += "\n" + line
+		}
+	}
+	flush()
+
+	return statements
+}
@@ -160,6 +160,55 @@ func TestDumpParseSchema(t *testing.T) {
 	}
 }
 
+func TestRestore(t *testing.T) {
+	tx := newTxForDump(t, "local")
+	dump, err := query.Dump(tx, schemas["local"], false /* schemaOnly */)
+	require.NoError(t, err)
+
+	_, err = tx.Exec("DELETE FROM patches")
+	require.NoError(t, err)
+	_, err = tx.Exec("INSERT INTO config VALUES(1, 'core.https_address', '[::]:8443')")
+	require.NoError(t, err)
+
+	err = query.Restore(tx, schemas["local"], dump)
+	require.NoError(t, err)
+
+	names, err := query.SelectStrings(tx, "SELECT name FROM patches ORDER BY id")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"invalid_profile_names", "leftover_profile_config"}, names)
+
+	count, err := query.Count(tx, "config", "")
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	versions, err := query.SelectIntegers(tx, "SELECT version FROM schema")
+	require.NoError(t, err)
+	assert.Equal(t, []int{37}, versions)
+}
+
+// Values containing quotes and statement prefixes survive a dump and restore.
+func TestRestore_Quoting(t *testing.T) {
+	tx := newTxForDump(t, "local")
+
+	value := "it's\nINSERT INTO config VALUES(2,'x','y');\n'); DELETE FROM patches; --"
+	_, err := tx.Exec("INSERT INTO config VALUES(1, 'core.https_address', ?)", value)
+	require.NoError(t, err)
+
+	dump, err := query.Dump(tx, schemas["local"], false /* schemaOnly */)
+	require.NoError(t, err)
+
+	err = query.Restore(tx, schemas["local"], dump)
+	require.NoError(t, err)
+
+	values, err := query.SelectStrings(tx, "SELECT value FROM config")
+	require.NoError(t, err)
+	assert.Equal(t, []string{value}, values)
+
+	count, err := query.Count(tx, "patches", "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
 // Return a new transaction against an in-memory SQLite database pupulated with
 // a few tables and data, according to the given schema.
 func newTxForDump(t *testing.T, schema string) *sql.Tx {
@@ -1,6 +1,7 @@
 package query
 
 import (
+	"context"
 	"database/sql"
 	"strings"
 	"time"
@@ -16,9 +17,22 @@ import (
 //
 // This should by typically used to wrap transactions.
 func Retry(f func() error) error {
+	return RetryContext(context.Background(), f)
+}
+
+// RetryContext is like Retry, but gives up as soon as the given context is
+// done.
+func RetryContext(ctx context.Context, f func() error) error {
 	// TODO: the retry loop should be configurable.
 	var err error
 	for i := 0; i < 5; i++ {
+		if ctx.Err() != nil {
+			if err == nil {
+				err = ctx.Err()
+			}
+			break
+		}
+
 		err = f()
 		if err != nil {
 			// No point in re-trying or logging a no-row error.
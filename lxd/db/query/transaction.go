@@ -1,6 +1,7 @@
 package query
 
 import (
+	"context"
 	"database/sql"
 
 	"github.com/lxc/lxd/shared/logger"
@@ -9,7 +10,14 @@ import (
 
 // Transaction executes the given function within a database transaction.
 func Transaction(db *sql.DB, f func(*sql.Tx) error) error {
-	tx, err := db.Begin()
+	return TransactionContext(context.Background(), db, f)
+}
+
+// TransactionContext executes the given function within a database
+// transaction bound to the given context. If the context is cancelled before
+// the transaction is committed, the transaction is rolled back.
+func TransactionContext(ctx context.Context, db *sql.DB, f func(*sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return errors.Wrap(err, "failed to begin transaction")
 	}
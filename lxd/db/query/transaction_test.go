@@ -1,6 +1,7 @@
 package query_test
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"testing"
@@ -39,6 +40,22 @@ func TestTransaction_FunctionError(t *testing.T) {
 	assert.NotContains(t, tables, "test")
 }
 
+// A cancelled context prevents the transaction from being started.
+func TestTransactionContext_Cancelled(t *testing.T) {
+	db := newDB(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	called := false
+	err := query.TransactionContext(ctx, db, func(*sql.Tx) error {
+		called = true
+		return nil
+	})
+	assert.NotNil(t, err)
+	assert.False(t, called)
+}
+
 // Return a new in-memory SQLite database.
 func newDB(t *testing.T) *sql.DB {
 	db, err := sql.Open("sqlite3", ":memory:")
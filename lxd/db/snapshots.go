@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/lxc/lxd/lxd/db/query"
 	"github.com/lxc/lxd/shared"
 )
 
@@ -115,6 +116,83 @@ func InstanceSnapshotUpdate(tx *sql.Tx, id int, description string, expiryDate t
 	return nil
 }
 
+// InstanceSnapshotScheduledSet flags the instance snapshot with the given ID as taken on schedule.
+func (c *ClusterTx) InstanceSnapshotScheduledSet(id int) error {
+	_, err := c.tx.Exec("UPDATE instances_snapshots SET scheduled=1 WHERE id=?", id)
+	return err
+}
+
+// InstanceSnapshotsScheduled returns the names of the snapshots of the given instance which were
+// taken on schedule, from the oldest to the most recent.
+func (c *ClusterTx) InstanceSnapshotsScheduled(project, instance string) ([]string, error) {
+	stmt := `
+SELECT instances_snapshots.name
+  FROM instances_snapshots
+  JOIN instances ON instances.id = instances_snapshots.instance_id
+  JOIN projects ON projects.id = instances.project_id
+ WHERE projects.name = ? AND instances.name = ? AND instances_snapshots.scheduled = 1
+ ORDER BY instances_snapshots.creation_date, instances_snapshots.id
+`
+	return query.SelectStrings(c.tx, stmt, project, instance)
+}
+
+// InstanceSnapshotsDeviceRename renames the device with the given name and source in all the
+// snapshots of the given instance having it, also changing its source.
+func (c *ClusterTx) InstanceSnapshotsDeviceRename(project, instance, name, source, newName, newSource string) error {
+	ids, err := c.instanceSnapshotsDeviceIDs(project, instance, name, source)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		_, err := c.tx.Exec("UPDATE instances_snapshots_devices SET name = ? WHERE id = ?", newName, id)
+		if err != nil {
+			return err
+		}
+
+		_, err = c.tx.Exec("UPDATE instances_snapshots_devices_config SET value = ? WHERE instance_snapshot_device_id = ? AND key = 'source'", newSource, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// InstanceSnapshotsDeviceRemove removes the device with the given name and source from all the
+// snapshots of the given instance having it.
+func (c *ClusterTx) InstanceSnapshotsDeviceRemove(project, instance, name, source string) error {
+	ids, err := c.instanceSnapshotsDeviceIDs(project, instance, name, source)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		_, err := c.tx.Exec("DELETE FROM instances_snapshots_devices WHERE id = ?", id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Return the IDs of the devices with the given name and source in the snapshots of the given
+// instance.
+func (c *ClusterTx) instanceSnapshotsDeviceIDs(project, instance, name, source string) ([]int, error) {
+	stmt := `
+SELECT instances_snapshots_devices.id
+  FROM instances_snapshots_devices
+  JOIN instances_snapshots_devices_config ON instances_snapshots_devices_config.instance_snapshot_device_id = instances_snapshots_devices.id
+  JOIN instances_snapshots ON instances_snapshots.id = instances_snapshots_devices.instance_snapshot_id
+  JOIN instances ON instances.id = instances_snapshots.instance_id
+  JOIN projects ON projects.id = instances.project_id
+ WHERE projects.name = ? AND instances.name = ? AND instances_snapshots_devices.name = ?
+   AND instances_snapshots_devices_config.key = 'source' AND instances_snapshots_devices_config.value = ?
+`
+	return query.SelectIntegers(c.tx, stmt, project, instance, name, source)
+}
+
 // InstanceSnapshotID returns the ID of the snapshot with the given name.
 func (c *Cluster) InstanceSnapshotID(project, instance, name string) (int, error) {
 	var id int64
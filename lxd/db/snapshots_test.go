@@ -155,6 +155,74 @@ func TestInstanceSnapshotList_SameNameInDifferentProjects(t *testing.T) {
 	assert.Equal(t, "s1", snapshot.Name)
 }
 
+// Only the snapshots flagged as scheduled are listed, from the oldest one.
+func TestInstanceSnapshotsScheduled(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	addContainer(t, tx, 1, "c1")
+
+	now := time.Now()
+	for i, name := range []string{"snap1", "manual", "snap0"} {
+		id, err := tx.InstanceSnapshotCreate(db.InstanceSnapshot{
+			Project:      "default",
+			Instance:     "c1",
+			Name:         name,
+			CreationDate: now.Add(time.Duration(i) * time.Minute),
+		})
+		require.NoError(t, err)
+
+		if name != "manual" {
+			require.NoError(t, tx.InstanceSnapshotScheduledSet(int(id)))
+		}
+	}
+
+	names, err := tx.InstanceSnapshotsScheduled("default", "c1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"snap1", "snap0"}, names)
+
+	names, err = tx.InstanceSnapshotsScheduled("default", "c2")
+	require.NoError(t, err)
+	assert.Len(t, names, 0)
+}
+
+// Only the devices with the given name and source are renamed or removed.
+func TestInstanceSnapshotsDeviceRenameAndRemove(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	addContainer(t, tx, 1, "c1")
+	addInstanceSnapshot(t, tx, 1, "snap1")
+	addInstanceSnapshot(t, tx, 1, "snap2")
+	addInstanceSnapshot(t, tx, 1, "snap3")
+
+	addInstanceSnapshotDevice(t, tx, "c1", "snap2", "snapshot-snap1", "disk", map[string]string{"source": "snapshot:snap1", "path": "/snap"})
+	addInstanceSnapshotDevice(t, tx, "c1", "snap3", "snapshot-snap1", "disk", map[string]string{"source": "snapshot:snap1", "path": "/other"})
+	addInstanceSnapshotDevice(t, tx, "c1", "snap3", "data", "disk", map[string]string{"source": "/srv", "path": "/srv"})
+
+	err := tx.InstanceSnapshotsDeviceRename("default", "c1", "snapshot-snap1", "snapshot:snap1", "snapshot-renamed", "snapshot:renamed")
+	require.NoError(t, err)
+
+	snapshots, err := tx.InstanceSnapshotList(db.InstanceSnapshotFilter{Project: "default", Instance: "c1"})
+	require.NoError(t, err)
+	require.Len(t, snapshots, 3)
+	assert.Len(t, snapshots[0].Devices, 0)
+	assert.Equal(t, map[string]map[string]string{"snapshot-renamed": {"type": "disk", "source": "snapshot:renamed", "path": "/snap"}}, snapshots[1].Devices)
+	assert.Len(t, snapshots[2].Devices, 2)
+	assert.Equal(t, map[string]string{"type": "disk", "source": "snapshot:renamed", "path": "/other"}, snapshots[2].Devices["snapshot-renamed"])
+
+	err = tx.InstanceSnapshotsDeviceRemove("default", "c1", "data", "/other")
+	require.NoError(t, err)
+
+	err = tx.InstanceSnapshotsDeviceRemove("default", "c1", "snapshot-renamed", "snapshot:renamed")
+	require.NoError(t, err)
+
+	snapshots, err = tx.InstanceSnapshotList(db.InstanceSnapshotFilter{Project: "default", Instance: "c1"})
+	require.NoError(t, err)
+	assert.Len(t, snapshots[1].Devices, 0)
+	assert.Equal(t, map[string]map[string]string{"data": {"type": "disk", "source": "/srv", "path": "/srv"}}, snapshots[2].Devices)
+}
+
 func addInstanceSnapshot(t *testing.T, tx *db.ClusterTx, instanceID int64, name string) {
 	stmt := `
 INSERT INTO instances_snapshots(instance_id, name, creation_date) VALUES (?, ?, ?)
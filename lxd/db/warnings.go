@@ -0,0 +1,215 @@
+// +build linux,cgo,!agent
+
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db/query"
+)
+
+// Warning statuses.
+const (
+	WarningStatusNew          = "new"
+	WarningStatusAcknowledged = "acknowledged"
+	WarningStatusResolved     = "resolved"
+)
+
+// Warning holds information about a degraded condition detected on a node.
+type Warning struct {
+	ID            int64
+	UUID          string
+	Node          string // Name of the node the warning was raised on
+	Project       string // Project the warning relates to, if any
+	EntityURL     string // API URL of the affected entity, if any
+	Type          string
+	Severity      string
+	Status        string
+	Message       string // Last message reported for this warning
+	Count         int    // Number of times the condition was detected
+	FirstSeenDate time.Time
+	LastSeenDate  time.Time
+}
+
+// WarningUpsert records a warning of the given type about the given entity
+// on this node. If an unresolved warning of the same type already exists for
+// the entity, its count and message are updated instead.
+func (c *ClusterTx) WarningUpsert(project string, entityURL string, typ string, severity string, message string) error {
+	projectID, err := c.warningProjectID(project)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+
+	result, err := c.tx.Exec(`
+UPDATE warnings SET count = count + 1, last_seen_date = ?, severity = ?, message = ?
+ WHERE node_id = ? AND project_id IS ? AND entity = ? AND type = ? AND status != ?
+`, now, severity, message, c.nodeID, projectID, entityURL, typ, WarningStatusResolved)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		return nil
+	}
+
+	columns := []string{"uuid", "node_id", "project_id", "entity", "type", "severity", "status", "message", "count", "first_seen_date", "last_seen_date"}
+	values := []interface{}{uuid.NewRandom().String(), c.nodeID, projectID, entityURL, typ, severity, WarningStatusNew, message, 1, now, now}
+	_, err = query.UpsertObject(c.tx, "warnings", columns, values)
+	return err
+}
+
+// WarningResolve marks any unresolved warning of the given type about the
+// given entity on this node as resolved.
+func (c *ClusterTx) WarningResolve(project string, entityURL string, typ string) error {
+	projectID, err := c.warningProjectID(project)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.tx.Exec(`
+UPDATE warnings SET status = ?
+ WHERE node_id = ? AND project_id IS ? AND entity = ? AND type = ? AND status != ?
+`, WarningStatusResolved, c.nodeID, projectID, entityURL, typ, WarningStatusResolved)
+	return err
+}
+
+// Warnings returns all warnings in the cluster.
+func (c *ClusterTx) Warnings() ([]Warning, error) {
+	return c.warnings("")
+}
+
+// WarningByUUID returns the warning with the given UUID.
+func (c *ClusterTx) WarningByUUID(uuid string) (Warning, error) {
+	null := Warning{}
+	warnings, err := c.warnings("warnings.uuid = ?", uuid)
+	if err != nil {
+		return null, err
+	}
+
+	switch len(warnings) {
+	case 0:
+		return null, ErrNoSuchObject
+	case 1:
+		return warnings[0], nil
+	default:
+		return null, fmt.Errorf("More than one warning matches")
+	}
+}
+
+// WarningUpdateStatus sets the status of the warning with the given UUID.
+func (c *ClusterTx) WarningUpdateStatus(uuid string, status string) error {
+	result, err := c.tx.Exec("UPDATE warnings SET status = ? WHERE uuid = ?", status, uuid)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n != 1 {
+		return ErrNoSuchObject
+	}
+
+	return nil
+}
+
+// WarningDelete removes the warning with the given UUID.
+func (c *ClusterTx) WarningDelete(uuid string) error {
+	result, err := c.tx.Exec("DELETE FROM warnings WHERE uuid = ?", uuid)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n != 1 {
+		return ErrNoSuchObject
+	}
+
+	return nil
+}
+
+// Return the ID of the given project, or nil if no project is given.
+func (c *ClusterTx) warningProjectID(project string) (interface{}, error) {
+	if project == "" {
+		return nil, nil
+	}
+
+	id, err := c.ProjectID(project)
+	if err != nil {
+		return nil, errors.Wrap(err, "Fetch project ID")
+	}
+
+	return id, nil
+}
+
+// Return the warnings in the cluster, filtered by the given clause.
+func (c *ClusterTx) warnings(where string, args ...interface{}) ([]Warning, error) {
+	warnings := []Warning{}
+	projects := []sql.NullString{}
+	dest := func(i int) []interface{} {
+		warnings = append(warnings, Warning{})
+		projects = append(projects, sql.NullString{})
+		return []interface{}{
+			&warnings[i].ID,
+			&warnings[i].UUID,
+			&warnings[i].Node,
+			&projects[i],
+			&warnings[i].EntityURL,
+			&warnings[i].Type,
+			&warnings[i].Severity,
+			&warnings[i].Status,
+			&warnings[i].Message,
+			&warnings[i].Count,
+			&warnings[i].FirstSeenDate,
+			&warnings[i].LastSeenDate,
+		}
+	}
+
+	q := `
+SELECT warnings.id, warnings.uuid, nodes.name, projects.name, warnings.entity,
+       warnings.type, warnings.severity, warnings.status, warnings.message,
+       warnings.count, warnings.first_seen_date, warnings.last_seen_date
+  FROM warnings
+  JOIN nodes ON nodes.id = warnings.node_id
+  LEFT OUTER JOIN projects ON projects.id = warnings.project_id
+`
+	if where != "" {
+		q += fmt.Sprintf("WHERE %s ", where)
+	}
+	q += "ORDER BY warnings.id"
+
+	stmt, err := c.tx.Prepare(q)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	err = query.SelectObjects(stmt, dest, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch warnings")
+	}
+
+	for i := range warnings {
+		warnings[i].Project = projects[i].String
+	}
+
+	return warnings, nil
+}
@@ -0,0 +1,75 @@
+// +build linux,cgo,!agent
+
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Raise a warning several times, acknowledge it, resolve it and delete it.
+func TestWarning(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	err := tx.WarningUpsert("default", "/1.0/images/abcd", "image-refresh-failed", "low", "Failed once")
+	require.NoError(t, err)
+
+	err = tx.WarningUpsert("default", "/1.0/images/abcd", "image-refresh-failed", "low", "Failed twice")
+	require.NoError(t, err)
+
+	warnings, err := tx.Warnings()
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+
+	warning := warnings[0]
+	assert.Equal(t, "default", warning.Project)
+	assert.Equal(t, "Failed twice", warning.Message)
+	assert.Equal(t, 2, warning.Count)
+	assert.Equal(t, db.WarningStatusNew, warning.Status)
+
+	err = tx.WarningUpdateStatus(warning.UUID, db.WarningStatusAcknowledged)
+	require.NoError(t, err)
+
+	err = tx.WarningResolve("default", "/1.0/images/abcd", "image-refresh-failed")
+	require.NoError(t, err)
+
+	warning, err = tx.WarningByUUID(warning.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, db.WarningStatusResolved, warning.Status)
+
+	// A new occurrence after resolution raises a new warning.
+	err = tx.WarningUpsert("default", "/1.0/images/abcd", "image-refresh-failed", "low", "Failed again")
+	require.NoError(t, err)
+
+	warnings, err = tx.Warnings()
+	require.NoError(t, err)
+	assert.Len(t, warnings, 2)
+
+	err = tx.WarningDelete(warning.UUID)
+	require.NoError(t, err)
+
+	_, err = tx.WarningByUUID(warning.UUID)
+	assert.Equal(t, db.ErrNoSuchObject, err)
+}
+
+// Warnings which don't relate to any project.
+func TestWarningNoProject(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	err := tx.WarningUpsert("", "/1.0/storage-pools/default", "storage-pool-nearly-full", "moderate", "Pool is 95% full")
+	require.NoError(t, err)
+
+	err = tx.WarningUpsert("", "/1.0/storage-pools/default", "storage-pool-nearly-full", "moderate", "Pool is 96% full")
+	require.NoError(t, err)
+
+	warnings, err := tx.Warnings()
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	assert.Equal(t, "", warnings[0].Project)
+	assert.Equal(t, 2, warnings[0].Count)
+}
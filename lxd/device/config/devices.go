@@ -163,6 +163,34 @@ func (list Devices) CloneNative() map[string]map[string]string {
 	return copy
 }
 
+// SecretKeys lists the device options holding secrets, which the API renders
+// as "true" for obfuscating the actual value.
+var SecretKeys = []string{"cifs.password"}
+
+// HideSecrets replaces the values of the secret options of the given devices
+// with "true".
+func HideSecrets(devices map[string]map[string]string) {
+	for _, device := range devices {
+		for _, key := range SecretKeys {
+			if device[key] != "" {
+				device[key] = "true"
+			}
+		}
+	}
+}
+
+// KeepSecrets replaces the secret options of the given devices set to "true",
+// meaning "keep it unchanged", with their value in the current devices.
+func KeepSecrets(devices map[string]map[string]string, current map[string]map[string]string) {
+	for name, device := range devices {
+		for _, key := range SecretKeys {
+			if device[key] == "true" && current[name][key] != "" {
+				device[key] = current[name][key]
+			}
+		}
+	}
+}
+
 // Sorted returns the name of all devices in the set, sorted properly.
 func (list Devices) Sorted() DevicesSortable {
 	sortable := DevicesSortable{}
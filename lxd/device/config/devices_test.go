@@ -37,3 +37,26 @@ func TestSortableDevices(t *testing.T) {
 		t.Error("devices reverse sorted incorrectly")
 	}
 }
+
+func TestHideAndKeepSecrets(t *testing.T) {
+	current := map[string]map[string]string{
+		"share": {"type": "disk", "fstype": "cifs", "cifs.password": "secret"},
+	}
+
+	devices := Devices(map[string]Device{"share": current["share"]}).CloneNative()
+	HideSecrets(devices)
+	if devices["share"]["cifs.password"] != "true" || current["share"]["cifs.password"] != "secret" {
+		t.Error("secret not hidden")
+	}
+
+	KeepSecrets(devices, current)
+	if !reflect.DeepEqual(devices, current) {
+		t.Error("secret not kept")
+	}
+
+	devices["share"]["cifs.password"] = "other"
+	KeepSecrets(devices, current)
+	if devices["share"]["cifs.password"] != "other" {
+		t.Error("new secret not applied")
+	}
+}
@@ -20,6 +20,7 @@ var devTypes = map[string]func(deviceConfig.Device) device{
 	"unix-hotplug": func(c deviceConfig.Device) device { return &unixHotplug{} },
 	"disk":         func(c deviceConfig.Device) device { return &disk{} },
 	"none":         func(c deviceConfig.Device) device { return &none{} },
+	"plugin":       func(c deviceConfig.Device) device { return &plugin{} },
 }
 
 // load instantiates a device and initialises its internal state. It does not validate the config supplied.
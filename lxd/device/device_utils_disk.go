@@ -52,11 +52,13 @@ func DiskMount(srcPath string, dstPath string, readonly bool, recursive bool, pr
 		flags |= unix.MS_RDONLY
 	}
 
-	// Detect the filesystem
+	// Detect the filesystem unless one was explicitly requested
 	if IsBlockdev(srcPath) {
-		fsName, err = BlockFsDetect(srcPath)
-		if err != nil {
-			return err
+		if fsName == "none" {
+			fsName, err = BlockFsDetect(srcPath)
+			if err != nil {
+				return err
+			}
 		}
 	} else {
 		if fsName == "none" {
@@ -115,6 +117,41 @@ func DiskMount(srcPath string, dstPath string, readonly bool, recursive bool, pr
 	return nil
 }
 
+// diskRemoteFsTypes lists the network filesystems which can be used as disk device fstype.
+var diskRemoteFsTypes = []string{"nfs", "nfs4", "cifs"}
+
+// diskMountRemote mounts a network filesystem using the mount helpers so that server names get
+// resolved. The CIFS password, if any, is passed through the environment rather than the command line.
+func diskMountRemote(fsType string, source string, dstPath string, readonly bool, mountOptions string, password string) error {
+	options := []string{}
+	if mountOptions != "" {
+		options = append(options, mountOptions)
+	}
+
+	if readonly {
+		options = append(options, "ro")
+	}
+
+	args := []string{"-t", fsType}
+	if len(options) > 0 {
+		args = append(args, "-o", strings.Join(options, ","))
+	}
+
+	args = append(args, source, dstPath)
+
+	var env []string
+	if password != "" {
+		env = append(os.Environ(), fmt.Sprintf("PASSWD=%s", password))
+	}
+
+	_, _, err := shared.RunCommandSplit(env, "mount", args...)
+	if err != nil {
+		return fmt.Errorf("Unable to mount %s at %s: %v", source, dstPath, err)
+	}
+
+	return nil
+}
+
 func diskCephRbdMap(clusterName string, userName string, poolName string, volumeName string) (string, error) {
 	devPath, err := shared.RunCommand(
 		"rbd",
@@ -20,6 +20,7 @@ import (
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/network"
+	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/revert"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/lxd/util"
@@ -227,6 +228,78 @@ func networkRandomDevName(prefix string) string {
 	return iface
 }
 
+// networkHostNameTemplateFields lists the placeholders supported in host_name.template.
+var networkHostNameTemplateFields = []string{"{instance}", "{id}", "{device}", "{random}"}
+
+// networkHostName returns the name to use for the host side interface of a nic device. This is
+// either the configured host_name, the rendered host_name.template or a random name with prefix.
+func networkHostName(inst instance.Instance, devName string, m deviceConfig.Device, prefix string) (string, error) {
+	if m["host_name"] != "" {
+		return m["host_name"], nil
+	}
+
+	if m["host_name.template"] == "" {
+		return networkRandomDevName(prefix), nil
+	}
+
+	hostName, err := networkHostNameRender(m["host_name.template"], project.Instance(inst.Project(), inst.Name()), inst.ID(), devName)
+	if err != nil {
+		return "", err
+	}
+
+	if shared.PathExists(fmt.Sprintf("/sys/class/net/%s", hostName)) {
+		return "", fmt.Errorf("Host interface %q rendered from %q already exists", hostName, m["host_name.template"])
+	}
+
+	return hostName, nil
+}
+
+// networkHostNameRender renders a host_name.template for the given instance and device, checking
+// that the result is a valid interface name.
+func networkHostNameRender(template string, instName string, instID int, devName string) (string, error) {
+	randBytes := make([]byte, 2)
+	rand.Read(randBytes)
+
+	replacer := strings.NewReplacer(
+		"{instance}", instName,
+		"{id}", strconv.Itoa(instID),
+		"{device}", devName,
+		"{random}", hex.EncodeToString(randBytes),
+	)
+
+	hostName := replacer.Replace(template)
+	if len(hostName) > 15 {
+		return "", fmt.Errorf("Host interface name %q rendered from %q is too long (maximum 15 characters)", hostName, template)
+	}
+
+	if !networkValidHostNameRegex.MatchString(hostName) {
+		return "", fmt.Errorf("Host interface name %q rendered from %q contains invalid characters", hostName, template)
+	}
+
+	return hostName, nil
+}
+
+var networkValidHostNameRegex = regexp.MustCompile("^[-_a-zA-Z0-9.]+$")
+
+// networkValidHostNameTemplate validates a host_name.template value, checking that it only uses
+// known placeholders.
+func networkValidHostNameTemplate(value string) error {
+	stripped := value
+	for _, field := range networkHostNameTemplateFields {
+		stripped = strings.Replace(stripped, field, "", -1)
+	}
+
+	if strings.ContainsAny(stripped, "{}") {
+		return fmt.Errorf("Invalid placeholder in %q (supported: %s)", value, strings.Join(networkHostNameTemplateFields, ", "))
+	}
+
+	if stripped != "" && !networkValidHostNameRegex.MatchString(stripped) {
+		return fmt.Errorf("Host interface name template %q contains invalid characters", value)
+	}
+
+	return nil
+}
+
 // networkCreateVethPair creates and configures a veth pair. It will set the hwaddr and mtu settings
 // in the supplied config to the newly created peer interface. If mtu is not specified, but parent
 // is supplied in config, then the MTU of the new peer interface will inherit the parent MTU.
@@ -533,6 +606,127 @@ func networkSetVethLimits(m deviceConfig.Device) error {
 	return nil
 }
 
+// networkQuotaThrottle replaces the egress limit of a nic device with the rate recorded in
+// volatile.network.throttle while the instance or its project is over its network quota and
+// throttling was requested.
+func networkQuotaThrottle(inst instance.Instance, m deviceConfig.Device) {
+	throttle := inst.LocalConfig()["volatile.network.throttle"]
+	if throttle == "" {
+		return
+	}
+
+	if m["limits.max"] != "" {
+		m["limits.ingress"] = m["limits.max"]
+		delete(m, "limits.max")
+	}
+
+	m["limits.egress"] = throttle
+}
+
+// NetworkRefreshQuotaLimits re-applies the rate limits of the running veth based nic devices of an
+// instance, so that a change to its network quota state takes effect immediately.
+func NetworkRefreshQuotaLimits(inst instance.Instance) error {
+	for name, dev := range inst.ExpandedDevices() {
+		nicType := dev.NICType()
+		if nicType != "bridged" && nicType != "p2p" {
+			continue
+		}
+
+		m := dev.Clone()
+		if m["host_name"] == "" {
+			m["host_name"] = inst.LocalConfig()[fmt.Sprintf("volatile.%s.host_name", name)]
+		}
+
+		if m["host_name"] == "" {
+			continue
+		}
+
+		networkQuotaThrottle(inst, m)
+		err := networkSetVethLimits(m)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to apply limits to device %q", name)
+		}
+	}
+
+	return nil
+}
+
+// networkSetupPriority sets the priority of the packets forwarded from a bridged nic device to the class
+// of the uplink qdisc matching the instance's limits.network.priority (see network.PriorityClass).
+func networkSetupPriority(s *state.State, inst instance.Instance, deviceName string, m deviceConfig.Device) error {
+	err := s.Firewall.InstanceClearNetPriority(inst.Project(), inst.Name(), deviceName)
+	if err != nil {
+		return err
+	}
+
+	value := inst.ExpandedConfig()["limits.network.priority"]
+	if value == "" {
+		return nil
+	}
+
+	priority, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+
+	return s.Firewall.InstanceSetupNetPriority(inst.Project(), inst.Name(), deviceName, m["parent"], m["host_name"], m["hwaddr"], network.PriorityClass(priority))
+}
+
+// NetworkRefreshPriority re-applies the priority of the running bridged nic devices of an instance, so
+// that a change to limits.network.priority takes effect immediately.
+func NetworkRefreshPriority(s *state.State, inst instance.Instance) error {
+	for name, dev := range inst.ExpandedDevices() {
+		if dev.NICType() != "bridged" {
+			continue
+		}
+
+		m := dev.Clone()
+		for _, key := range []string{"host_name", "hwaddr"} {
+			if m[key] == "" {
+				m[key] = inst.LocalConfig()[fmt.Sprintf("volatile.%s.%s", name, key)]
+			}
+		}
+
+		if m["host_name"] == "" {
+			continue
+		}
+
+		err := networkSetupPriority(s, inst, name, m)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to apply network priority to device %q", name)
+		}
+	}
+
+	return nil
+}
+
+// NetworkQuotaCounter returns the number of bytes sent by an instance through its running veth based
+// nic devices. It's read from the host side of the veth pairs, so that the instance can't tamper
+// with it.
+func NetworkQuotaCounter(inst instance.Instance) int64 {
+	var sent int64
+	for name, dev := range inst.ExpandedDevices() {
+		nicType := dev.NICType()
+		if nicType != "bridged" && nicType != "p2p" {
+			continue
+		}
+
+		hostName := dev["host_name"]
+		if hostName == "" {
+			hostName = inst.LocalConfig()[fmt.Sprintf("volatile.%s.host_name", name)]
+		}
+
+		if hostName == "" {
+			continue
+		}
+
+		// What the instance sends is received by the host side.
+		sent += shared.NetworkGetCounters(hostName).BytesReceived
+	}
+
+	return sent
+}
+
 // networkValidMAC validates an ethernet MAC address. e.g. "32:47:ae:06:22:f9".
 func networkValidMAC(value string) error {
 	regexHwaddr, err := regexp.Compile("^([0-9a-fA-F]{2}:){5}[0-9a-fA-F]{2}$")
@@ -0,0 +1,40 @@
+package device
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkValidHostNameTemplate(t *testing.T) {
+	for _, value := range []string{"lxd-{instance}", "{id}-{device}", "veth{random}", "static"} {
+		assert.NoError(t, networkValidHostNameTemplate(value), value)
+	}
+
+	assert.EqualError(t, networkValidHostNameTemplate("lxd-{name}"), `Invalid placeholder in "lxd-{name}" (supported: {instance}, {id}, {device}, {random})`)
+	assert.Error(t, networkValidHostNameTemplate("lxd-{instance"))
+	assert.EqualError(t, networkValidHostNameTemplate("lxd {device}"), `Host interface name template "lxd {device}" contains invalid characters`)
+}
+
+func TestNetworkHostNameRender(t *testing.T) {
+	hostName, err := networkHostNameRender("{instance}-{device}", "c1", 12, "eth0")
+	require.NoError(t, err)
+	assert.Equal(t, "c1-eth0", hostName)
+
+	// Instances of other projects get their name qualified with the project.
+	hostName, err = networkHostNameRender("{instance}-{id}", "p1_c1", 12, "eth0")
+	require.NoError(t, err)
+	assert.Equal(t, "p1_c1-12", hostName)
+
+	hostName, err = networkHostNameRender("veth{random}", "c1", 12, "eth0")
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile("^veth[0-9a-f]{4}$"), hostName)
+
+	_, err = networkHostNameRender("{instance}-{device}", "averylongname", 12, "eth0")
+	assert.EqualError(t, err, `Host interface name "averylongname-eth0" rendered from "{instance}-{device}" is too long (maximum 15 characters)`)
+
+	_, err = networkHostNameRender("{device}", "c1", 12, "eth/0")
+	assert.EqualError(t, err, `Host interface name "eth/0" rendered from "{device}" contains invalid characters`)
+}
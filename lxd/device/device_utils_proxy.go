@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"sync"
 
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/shared"
 )
 
@@ -65,3 +67,71 @@ func ProxyParseAddr(addr string) (*deviceConfig.ProxyAddress, error) {
 
 	return newProxyAddr, nil
 }
+
+// ProxyActivate is called with the connections accepted on the address of a proxy device with
+// activation enabled while its instance is stopped, it's set by LXD when it starts.
+var ProxyActivate func(s *state.State, instanceID int, listenAddr string, connectAddr string, conn net.Conn)
+
+// Listeners holding the address of proxy devices with activation enabled while
+// their instance is stopped, keyed by instance ID and device name.
+var proxyActivationListeners = map[string]net.Listener{}
+var proxyActivationMu sync.Mutex
+
+// proxyActivationKey returns the key identifying the activation listener of
+// the given proxy device. The instance ID is used as it survives renames.
+func proxyActivationKey(instanceID int, devName string) string {
+	return fmt.Sprintf("%d/%s", instanceID, devName)
+}
+
+// ProxyActivationListen starts listening on the address of a proxy device with
+// activation enabled, calling activate for each incoming connection. It does
+// nothing if the device already has a listener.
+func ProxyActivationListen(instanceID int, devName string, addr string, activate func(conn net.Conn)) error {
+	key := proxyActivationKey(instanceID, devName)
+
+	proxyActivationMu.Lock()
+	defer proxyActivationMu.Unlock()
+
+	_, ok := proxyActivationListeners[key]
+	if ok {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	proxyActivationListeners[key] = listener
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				// The listener was released.
+				return
+			}
+
+			go activate(conn)
+		}
+	}()
+
+	return nil
+}
+
+// ProxyActivationRelease stops listening on the address of the given proxy
+// device, so that the device itself can bind it.
+func ProxyActivationRelease(instanceID int, devName string) {
+	key := proxyActivationKey(instanceID, devName)
+
+	proxyActivationMu.Lock()
+	defer proxyActivationMu.Unlock()
+
+	listener, ok := proxyActivationListeners[key]
+	if !ok {
+		return
+	}
+
+	listener.Close()
+	delete(proxyActivationListeners, key)
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/lxc/lxd/lxd/revert"
 	storagePools "github.com/lxc/lxd/lxd/storage"
 	storageDrivers "github.com/lxc/lxd/lxd/storage/drivers"
+	"github.com/lxc/lxd/lxd/tools"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/idmap"
@@ -34,6 +35,9 @@ import (
 // Special disk "source" value used for generating a VM cloud-init config ISO.
 const diskSourceCloudInit = "cloud-init:config"
 
+// Prefix of the disk "source" values exposing a snapshot of the container itself, read-only.
+const diskSourceSnapshot = "snapshot:"
+
 type diskBlockLimit struct {
 	readBps   int64
 	readIops  int64
@@ -87,6 +91,9 @@ func (d *disk) validateConfig(instConf instance.ConfigReader) error {
 		"pool":              shared.IsAny,
 		"propagation":       validatePropagation,
 		"raw.mount.options": shared.IsAny,
+		"fstype":            shared.IsAny,
+		"cifs.username":     shared.IsAny,
+		"cifs.password":     shared.IsAny,
 		"ceph.cluster_name": shared.IsAny,
 		"ceph.user_name":    shared.IsAny,
 		"boot.priority":     shared.IsUint32,
@@ -118,7 +125,7 @@ func (d *disk) validateConfig(instConf instance.ConfigReader) error {
 		return fmt.Errorf("Only the root disk may have a size quota")
 	}
 
-	if d.config["recursive"] != "" && (d.config["path"] == "/" || !shared.IsDir(shared.HostPath(d.config["source"]))) {
+	if d.config["recursive"] != "" && d.config["fstype"] == "" && (d.config["path"] == "/" || !shared.IsDir(shared.HostPath(d.config["source"]))) {
 		return fmt.Errorf("The recursive option is only supported for additional bind-mounted paths")
 	}
 
@@ -131,6 +138,29 @@ func (d *disk) validateConfig(instConf instance.ConfigReader) error {
 		return fmt.Errorf("Invalid options ceph.cluster_name/ceph.user_name for source %q", d.config["source"])
 	}
 
+	if strings.HasPrefix(d.config["source"], diskSourceSnapshot) && (d.config["pool"] != "" || d.config["fstype"] != "" || d.config["shift"] != "" || d.config["recursive"] != "") {
+		return fmt.Errorf(`Snapshot disk entries can't have the "pool", "fstype", "shift" or "recursive" properties set`)
+	}
+
+	if d.config["fstype"] != "" {
+		if instConf.Type() == instancetype.VM {
+			return fmt.Errorf(`The "fstype" property is only supported for containers`)
+		}
+
+		if d.config["path"] == "/" || d.config["pool"] != "" || strings.HasPrefix(d.config["source"], "ceph:") || strings.HasPrefix(d.config["source"], "cephfs:") {
+			return fmt.Errorf(`The "fstype" property can only be used with host block devices or network filesystems`)
+		}
+
+		if d.config["recursive"] != "" || d.config["shift"] != "" {
+			return fmt.Errorf(`The "recursive" and "shift" properties cannot be used with "fstype"`)
+		}
+	}
+
+	// Check cifs options are only used when mounting a cifs share.
+	if d.config["fstype"] != "cifs" && (d.config["cifs.username"] != "" || d.config["cifs.password"] != "") {
+		return fmt.Errorf("Invalid options cifs.username/cifs.password for fstype %q", d.config["fstype"])
+	}
+
 	// Check no other devices also have the same path as us. Use LocalDevices for this check so
 	// that we can check before the config is expanded or when a profile is being checked.
 	// Don't take into account the device names, only count active devices that point to the
@@ -149,9 +179,10 @@ func (d *disk) validateConfig(instConf instance.ConfigReader) error {
 
 	// When we want to attach a storage volume created via the storage api the "source" only
 	// contains the name of the storage volume, not the path where it is mounted. So only check
-	// for the existence of "source" when "pool" is empty and ceph type source not being used.
-	if d.config["pool"] == "" && d.config["source"] != "" && d.config["source"] != diskSourceCloudInit && d.isRequired(d.config) && !shared.PathExists(shared.HostPath(d.config["source"])) &&
-		!strings.HasPrefix(d.config["source"], "ceph:") && !strings.HasPrefix(d.config["source"], "cephfs:") {
+	// for the existence of "source" when "pool" is empty and neither ceph type source nor network
+	// filesystem are being used.
+	if d.config["pool"] == "" && d.config["source"] != "" && d.config["source"] != diskSourceCloudInit && !strings.HasPrefix(d.config["source"], diskSourceSnapshot) && d.isRequired(d.config) && !shared.PathExists(shared.HostPath(d.config["source"])) &&
+		!strings.HasPrefix(d.config["source"], "ceph:") && !strings.HasPrefix(d.config["source"], "cephfs:") && !shared.StringInSlice(d.config["fstype"], diskRemoteFsTypes) {
 		return fmt.Errorf("Missing source %q for disk %q", d.config["source"], d.name)
 	}
 
@@ -203,6 +234,10 @@ func (d *disk) validateEnvironment() error {
 		return fmt.Errorf("disks with source=%s are only supported by virtual machines", diskSourceCloudInit)
 	}
 
+	if d.inst.Type() != instancetype.Container && strings.HasPrefix(d.config["source"], diskSourceSnapshot) {
+		return fmt.Errorf("disks with source=%s<name> are only supported by containers", diskSourceSnapshot)
+	}
+
 	return nil
 }
 
@@ -608,10 +643,15 @@ func (d *disk) applyQuota(newSize string) error {
 
 // generateLimits adds a set of cgroup rules to apply specified limits to the supplied RunConfig.
 func (d *disk) generateLimits(runConf *deviceConfig.RunConfig) error {
-	// Disk priority limits.
+	// Disk priority limits (on the unified hierarchy, the instance driver applies the I/O weight).
 	diskPriority := d.inst.ExpandedConfig()["limits.disk.priority"]
 	if diskPriority != "" {
-		if d.state.OS.CGInfo.Supports(cgroup.BlkioWeight, nil) {
+		version, ok := d.state.OS.CGInfo.SupportsVersion(cgroup.BlkioWeight)
+		if !ok {
+			return fmt.Errorf("Cannot apply limits.disk.priority as blkio.weight cgroup controller is missing")
+		}
+
+		if version == cgroup.V1 {
 			priorityInt, err := strconv.Atoi(diskPriority)
 			if err != nil {
 				return err
@@ -628,8 +668,6 @@ func (d *disk) generateLimits(runConf *deviceConfig.RunConfig) error {
 				Key:   "blkio.weight",
 				Value: fmt.Sprintf("%d", priority),
 			})
-		} else {
-			return fmt.Errorf("Cannot apply limits.disk.priority as blkio.weight cgroup controller is missing")
 		}
 	}
 
@@ -779,11 +817,26 @@ func (d *disk) createDevice() (string, error) {
 
 	mntOptions := d.config["raw.mount.options"]
 	fsName := "none"
+	fsType := d.config["fstype"]
+	isRemote := shared.StringInSlice(fsType, diskRemoteFsTypes)
 
 	isFile := false
 	if d.config["pool"] == "" {
 		isFile = !shared.IsDir(srcPath) && !IsBlockdev(srcPath)
-		if strings.HasPrefix(d.config["source"], "cephfs:") {
+		if isRemote {
+			// Network filesystems are mounted straight from the configured source.
+			if d.config["cifs.username"] != "" {
+				userOption := fmt.Sprintf("username=%s", d.config["cifs.username"])
+				if mntOptions == "" {
+					mntOptions = userOption
+				} else {
+					mntOptions += "," + userOption
+				}
+			}
+
+			srcPath = d.config["source"]
+			isFile = false
+		} else if strings.HasPrefix(d.config["source"], "cephfs:") {
 			// Get fs name and path from d.config.
 			fields := strings.SplitN(d.config["source"], ":", 2)
 			fields = strings.SplitN(fields[1], "/", 2)
@@ -835,6 +888,28 @@ func (d *disk) createDevice() (string, error) {
 
 			srcPath = rbdPath
 			isFile = false
+		} else if strings.HasPrefix(d.config["source"], diskSourceSnapshot) {
+			// Mount the snapshot, which is always exposed read-only.
+			snap, err := d.loadSnapshot()
+			if err == nil {
+				_, err = snap.StorageStart()
+			}
+
+			if err != nil {
+				msg := fmt.Sprintf("Could not mount snapshot for device %q: %v", d.name, err)
+				if !isRequired {
+					// Will fail the PathExists test below.
+					logger.Warn(msg)
+				} else {
+					return "", fmt.Errorf(msg)
+				}
+			} else {
+				revert.Add(func() { snap.StorageStop() })
+				srcPath = snap.RootfsPath()
+			}
+
+			isReadOnly = true
+			isFile = false
 		}
 	} else {
 		// Mount the pool volume.
@@ -850,14 +925,23 @@ func (d *disk) createDevice() (string, error) {
 		}
 	}
 
-	// Check if the source exists unless it is a cephfs.
-	if fsName != "ceph" && !shared.PathExists(srcPath) {
+	// Check if the source exists unless it is a cephfs or a network filesystem.
+	if fsName != "ceph" && !isRemote && !shared.PathExists(srcPath) {
 		if !isRequired {
 			return "", nil
 		}
 		return "", fmt.Errorf("Source path %q doesn't exist for device %q", srcPath, d.name)
 	}
 
+	// Any other filesystem type must be mounted from a block device.
+	if fsType != "" && !isRemote {
+		if !IsBlockdev(srcPath) {
+			return "", fmt.Errorf("Source path %q isn't a block device for device %q", srcPath, d.name)
+		}
+
+		fsName = fsType
+	}
+
 	// Create the devices directory if missing.
 	if !shared.PathExists(d.inst.DevicesPath()) {
 		err := os.Mkdir(d.inst.DevicesPath(), 0711)
@@ -890,7 +974,12 @@ func (d *disk) createDevice() (string, error) {
 	}
 
 	// Mount the fs.
-	err := DiskMount(srcPath, devPath, isReadOnly, isRecursive, d.config["propagation"], mntOptions, fsName)
+	var err error
+	if isRemote {
+		err = diskMountRemote(fsType, srcPath, devPath, isReadOnly, mntOptions, d.config["cifs.password"])
+	} else {
+		err = DiskMount(srcPath, devPath, isReadOnly, isRecursive, d.config["propagation"], mntOptions, fsName)
+	}
 	if err != nil {
 		return "", err
 	}
@@ -899,6 +988,13 @@ func (d *disk) createDevice() (string, error) {
 	return devPath, nil
 }
 
+// loadSnapshot loads the snapshot of the instance exposed by a disk with a snapshot source.
+func (d *disk) loadSnapshot() (instance.Instance, error) {
+	snapName := strings.TrimPrefix(d.config["source"], diskSourceSnapshot)
+
+	return instance.LoadByProjectAndName(d.state, d.inst.Project(), d.inst.Name()+shared.SnapshotDelimiter+snapName)
+}
+
 func (d *disk) storagePoolVolumeAttachShift(projectName, poolName, volumeName string, volumeType int, remapPath string) error {
 	// Load the DB records.
 	poolID, pool, err := d.state.Cluster.StoragePoolGet(poolName)
@@ -1138,6 +1234,15 @@ func (d *disk) postStop() error {
 
 	// Clean any existing entry.
 	if shared.PathExists(devPath) {
+		// Filesystems mounted with an explicit type are unmounted normally first so that any
+		// pending writes (especially to network filesystems) get flushed before detaching.
+		if d.config["fstype"] != "" {
+			err := unix.Unmount(devPath, 0)
+			if err != nil && err != unix.EINVAL {
+				logger.Warnf("Failed to cleanly unmount %q, detaching it: %v", devPath, err)
+			}
+		}
+
 		// Unmount the host side if not already.
 		// Don't check for errors here as this is just to catch any existing mounts that
 		// we not unmounted on the host after device was started.
@@ -1150,6 +1255,17 @@ func (d *disk) postStop() error {
 		}
 	}
 
+	if strings.HasPrefix(d.config["source"], diskSourceSnapshot) {
+		snap, err := d.loadSnapshot()
+		if err == nil {
+			_, err = snap.StorageStop()
+		}
+
+		if err != nil && err != db.ErrNoSuchObject {
+			return err
+		}
+	}
+
 	if strings.HasPrefix(d.config["source"], "ceph:") {
 		v := d.volatileGet()
 
@@ -1417,7 +1533,7 @@ func (d *disk) getParentBlocks(path string) ([]string, error) {
 		// Accessible zfs filesystems
 		poolName := strings.Split(dev[1], "/")[0]
 
-		output, err := shared.RunCommand("zpool", "status", "-P", "-L", poolName)
+		output, err := tools.RunCommand("zpool", "status", "-P", "-L", poolName)
 		if err != nil {
 			return nil, fmt.Errorf("Failed to query zfs filesystem information for %q: %v", dev[1], err)
 		}
@@ -1471,7 +1587,7 @@ func (d *disk) getParentBlocks(path string) ([]string, error) {
 		}
 	} else if fs == "btrfs" && shared.PathExists(dev[1]) {
 		// Accessible btrfs filesystems
-		output, err := shared.RunCommand("btrfs", "filesystem", "show", dev[1])
+		output, err := tools.RunCommand("btrfs", "filesystem", "show", dev[1])
 		if err != nil {
 			return nil, fmt.Errorf("Failed to query btrfs filesystem information for %q: %v", dev[1], err)
 		}
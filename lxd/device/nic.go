@@ -36,6 +36,7 @@ func nicValidationRules(requiredFields []string, optionalFields []string) map[st
 		"vlan":                    shared.IsAny,
 		"hwaddr":                  networkValidMAC,
 		"host_name":               shared.IsAny,
+		"host_name.template":      networkValidHostNameTemplate,
 		"limits.ingress":          shared.IsAny,
 		"limits.egress":           shared.IsAny,
 		"limits.max":              shared.IsAny,
@@ -49,6 +49,7 @@ func (d *nicBridged) validateConfig(instConf instance.ConfigReader) error {
 		"mtu",
 		"hwaddr",
 		"host_name",
+		"host_name.template",
 		"limits.ingress",
 		"limits.egress",
 		"limits.max",
@@ -98,6 +99,11 @@ func (d *nicBridged) validateConfig(instConf instance.ConfigReader) error {
 			if !d.networkDHCPValidIP(subnet, nil, net.ParseIP(d.config["ipv4.address"])) {
 				return fmt.Errorf("Device IP address %q not within network %q subnet", d.config["ipv4.address"], d.config["network"])
 			}
+
+			err = d.networkCheckReservedIP(n, net.ParseIP(d.config["ipv4.address"]))
+			if err != nil {
+				return err
+			}
 		}
 
 		if d.config["ipv6.address"] != "" {
@@ -116,6 +122,11 @@ func (d *nicBridged) validateConfig(instConf instance.ConfigReader) error {
 			if !d.networkDHCPValidIP(subnet, nil, net.ParseIP(d.config["ipv6.address"])) {
 				return fmt.Errorf("Device IP address %q not within network %q subnet", d.config["ipv6.address"], d.config["network"])
 			}
+
+			err = d.networkCheckReservedIP(n, net.ParseIP(d.config["ipv6.address"]))
+			if err != nil {
+				return err
+			}
 		}
 
 		// Link device to network bridge.
@@ -167,8 +178,14 @@ func (d *nicBridged) CanHotPlug() (bool, []string) {
 
 // Add is run when a device is added to an instance whether or not the instance is running.
 func (d *nicBridged) Add() error {
+	// Record the instance as the user of the reserved addresses.
+	err := d.networkUpdateReservations(nil, d.config)
+	if err != nil {
+		return err
+	}
+
 	// Rebuild dnsmasq entry if needed and reload.
-	err := d.rebuildDnsmasqEntry()
+	err = d.rebuildDnsmasqEntry()
 	if err != nil {
 		return err
 	}
@@ -184,20 +201,23 @@ func (d *nicBridged) Start() (*deviceConfig.RunConfig, error) {
 	}
 
 	saveData := make(map[string]string)
-	saveData["host_name"] = d.config["host_name"]
 
 	var peerName string
 
 	// Create veth pair and configure the peer end with custom hwaddr and mtu if supplied.
 	if d.inst.Type() == instancetype.Container {
-		if saveData["host_name"] == "" {
-			saveData["host_name"] = networkRandomDevName("veth")
+		saveData["host_name"], err = networkHostName(d.inst, d.name, d.config, "veth")
+		if err != nil {
+			return nil, err
 		}
+
 		peerName, err = networkCreateVethPair(saveData["host_name"], d.config)
 	} else if d.inst.Type() == instancetype.VM {
-		if saveData["host_name"] == "" {
-			saveData["host_name"] = networkRandomDevName("tap")
+		saveData["host_name"], err = networkHostName(d.inst, d.name, d.config, "tap")
+		if err != nil {
+			return nil, err
 		}
+
 		peerName = saveData["host_name"] // VMs use the host_name to link to the TAP FD.
 		err = networkCreateTap(saveData["host_name"], d.config)
 	}
@@ -207,6 +227,7 @@ func (d *nicBridged) Start() (*deviceConfig.RunConfig, error) {
 	}
 
 	// Apply and host-side limits and routes.
+	networkQuotaThrottle(d.inst, d.config)
 	err = networkSetupHostVethDevice(d.config, nil, saveData)
 	if err != nil {
 		NetworkRemoveInterface(saveData["host_name"])
@@ -227,6 +248,13 @@ func (d *nicBridged) Start() (*deviceConfig.RunConfig, error) {
 		return nil, err
 	}
 
+	// Apply the network priority of the instance.
+	err = networkSetupPriority(d.state, d.inst, d.name, d.config)
+	if err != nil {
+		NetworkRemoveInterface(saveData["host_name"])
+		return nil, err
+	}
+
 	// Attach host side veth interface to bridge.
 	err = network.AttachInterface(d.config["parent"], saveData["host_name"])
 	if err != nil {
@@ -234,6 +262,21 @@ func (d *nicBridged) Start() (*deviceConfig.RunConfig, error) {
 		return nil, err
 	}
 
+	// Apply the multicast flooding policy of managed parent bridges.
+	n, err := network.LoadByName(d.state, d.config["parent"])
+	if err != nil && err != db.ErrNoSuchObject {
+		NetworkRemoveInterface(saveData["host_name"])
+		return nil, err
+	}
+
+	if n != nil {
+		err = n.SetupPortMulticast(saveData["host_name"])
+		if err != nil {
+			NetworkRemoveInterface(saveData["host_name"])
+			return nil, err
+		}
+	}
+
 	// Attempt to disable router advertisement acceptance.
 	err = util.SysctlSet(fmt.Sprintf("net/ipv6/conf/%s/accept_ra", saveData["host_name"]), "0")
 	if err != nil && !os.IsNotExist(err) {
@@ -290,6 +333,7 @@ func (d *nicBridged) Update(oldDevices deviceConfig.Devices, isRunning bool) err
 		}
 
 		// Apply and host-side limits and routes.
+		networkQuotaThrottle(d.inst, d.config)
 		err = networkSetupHostVethDevice(d.config, oldConfig, v)
 		if err != nil {
 			return err
@@ -300,10 +344,22 @@ func (d *nicBridged) Update(oldDevices deviceConfig.Devices, isRunning bool) err
 		if err != nil {
 			return err
 		}
+
+		// Apply the network priority of the instance.
+		err = networkSetupPriority(d.state, d.inst, d.name, d.config)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Move the reservations along with the static addresses.
+	err := d.networkUpdateReservations(oldConfig, d.config)
+	if err != nil {
+		return err
 	}
 
 	// Rebuild dnsmasq entry if needed and reload.
-	err := d.rebuildDnsmasqEntry()
+	err = d.rebuildDnsmasqEntry()
 	if err != nil {
 		return err
 	}
@@ -361,12 +417,23 @@ func (d *nicBridged) postStop() error {
 	networkRemoveVethRoutes(d.config)
 	d.removeFilters(d.config)
 
+	err := d.state.Firewall.InstanceClearNetPriority(d.inst.Project(), d.inst.Name(), d.name)
+	if err != nil {
+		logger.Errorf("Failed to remove network priority rules for %q: %v", d.name, err)
+	}
+
 	return nil
 }
 
 // Remove is run when the device is removed from the instance or the instance is deleted.
 func (d *nicBridged) Remove() error {
-	err := d.networkClearLease(d.inst.Name(), d.config["parent"], d.config["hwaddr"], clearLeaseAll)
+	// Release the reserved addresses.
+	err := d.networkUpdateReservations(d.config, nil)
+	if err != nil {
+		return err
+	}
+
+	err = d.networkClearLease(d.inst.Name(), d.config["parent"], d.config["hwaddr"], clearLeaseAll)
 	if err != nil {
 		return err
 	}
@@ -738,6 +805,91 @@ func (d *nicBridged) networkDHCPValidIP(subnet *net.IPNet, ranges []network.DHCP
 	return false
 }
 
+// networkCheckReservedIP checks that a static IP isn't excluded from allocation on the network and,
+// if it's part of the network's reservation ranges, that it has been reserved.
+func (d *nicBridged) networkCheckReservedIP(n *network.Network, IP net.IP) error {
+	excluded := n.DHCPv4Exclusions()
+	reserved := n.ReservationV4Ranges()
+	if IP.To4() == nil {
+		excluded = n.DHCPv6Exclusions()
+		reserved = n.ReservationV6Ranges()
+	}
+
+	if !network.IPInRanges(IP, reserved) {
+		if network.IPInRanges(IP, excluded) {
+			return fmt.Errorf("Device IP address %q is excluded from allocation on network %q", IP.String(), n.Name())
+		}
+
+		return nil
+	}
+
+	var reservations []db.NetworkReservation
+	err := d.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		networkID, err := tx.NetworkID(n.Name())
+		if err != nil {
+			return err
+		}
+
+		reservations, err = tx.NetworkReservations(networkID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, reservation := range reservations {
+		if !IP.Equal(net.ParseIP(reservation.IPv4Address)) && !IP.Equal(net.ParseIP(reservation.IPv6Address)) {
+			continue
+		}
+
+		if reservation.InstanceID != 0 && (d.inst == nil || int64(d.inst.ID()) != reservation.InstanceID) {
+			return fmt.Errorf("Device IP address %q is reserved for instance %q in project %q", IP.String(), reservation.InstanceName, reservation.InstanceProject)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("Device IP address %q isn't reserved on network %q", IP.String(), n.Name())
+}
+
+// networkUpdateReservations records the instance as the user of the reservations holding the
+// static addresses of the new config, and releases those of the old config which are no longer used.
+func (d *nicBridged) networkUpdateReservations(oldConfig deviceConfig.Device, newConfig deviceConfig.Device) error {
+	claim := []string{}
+	release := []string{}
+	for _, key := range []string{"ipv4.address", "ipv6.address"} {
+		if newConfig[key] != "" {
+			claim = append(claim, newConfig[key])
+		}
+
+		if oldConfig[key] != "" && oldConfig[key] != newConfig[key] {
+			release = append(release, oldConfig[key])
+		}
+	}
+
+	if d.config["network"] == "" || (len(claim) == 0 && len(release) == 0) {
+		return nil
+	}
+
+	return d.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		networkID, err := tx.NetworkID(d.config["network"])
+		if err == db.ErrNoSuchObject {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = tx.NetworkReservationsRelease(networkID, int64(d.inst.ID()), release)
+		if err != nil {
+			return err
+		}
+
+		return tx.NetworkReservationsClaim(networkID, int64(d.inst.ID()), claim)
+	})
+}
+
 // getDHCPFreeIPv4 attempts to find a free IPv4 address for the device.
 // It first checks whether there is an existing allocation for the instance.
 // If no previous allocation, then a free IP is picked from the ranges configured.
@@ -770,6 +922,9 @@ func (d *nicBridged) getDHCPFreeIPv4(usedIPs map[[4]byte]dnsmasq.DHCPAllocation,
 		)
 	}
 
+	// Leave out the excluded and reserved addresses.
+	dhcpRanges = network.SubtractIPRanges(dhcpRanges, n.DHCPv4Exclusions())
+
 	// If no valid existing allocation found, try and find a free one in the subnet pool/ranges.
 	for _, IPRange := range dhcpRanges {
 		inc := big.NewInt(1)
@@ -846,11 +1001,11 @@ func (d *nicBridged) getDHCPFreeIPv6(usedIPs map[[16]byte]dnsmasq.DHCPAllocation
 			return nil, err
 		}
 
-		// Check IP is not already allocated and not the LXD IP.
+		// Check IP is not already allocated, not excluded and not the LXD IP.
 		var IPKey [16]byte
 		copy(IPKey[:], IP.To16())
 		_, inUse := usedIPs[IPKey]
-		if !inUse && !IP.Equal(lxdIP) {
+		if !inUse && !IP.Equal(lxdIP) && !network.IPInRanges(IP, n.DHCPv6Exclusions()) {
 			return IP, nil
 		}
 	}
@@ -863,6 +1018,9 @@ func (d *nicBridged) getDHCPFreeIPv6(usedIPs map[[16]byte]dnsmasq.DHCPAllocation
 		)
 	}
 
+	// Leave out the excluded and reserved addresses.
+	dhcpRanges = network.SubtractIPRanges(dhcpRanges, n.DHCPv6Exclusions())
+
 	// If we get here, then someone already has our SLAAC IP, or we are using custom ranges.
 	// Try and find a free one in the subnet pool/ranges.
 	for _, IPRange := range dhcpRanges {
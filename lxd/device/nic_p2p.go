@@ -24,6 +24,7 @@ func (d *nicP2P) validateConfig(instConf instance.ConfigReader) error {
 		"mtu",
 		"hwaddr",
 		"host_name",
+		"host_name.template",
 		"limits.ingress",
 		"limits.egress",
 		"limits.max",
@@ -62,20 +63,23 @@ func (d *nicP2P) Start() (*deviceConfig.RunConfig, error) {
 	}
 
 	saveData := make(map[string]string)
-	saveData["host_name"] = d.config["host_name"]
 
 	var peerName string
 
 	// Create veth pair and configure the peer end with custom hwaddr and mtu if supplied.
 	if d.inst.Type() == instancetype.Container {
-		if saveData["host_name"] == "" {
-			saveData["host_name"] = networkRandomDevName("veth")
+		saveData["host_name"], err = networkHostName(d.inst, d.name, d.config, "veth")
+		if err != nil {
+			return nil, err
 		}
+
 		peerName, err = networkCreateVethPair(saveData["host_name"], d.config)
 	} else if d.inst.Type() == instancetype.VM {
-		if saveData["host_name"] == "" {
-			saveData["host_name"] = networkRandomDevName("tap")
+		saveData["host_name"], err = networkHostName(d.inst, d.name, d.config, "tap")
+		if err != nil {
+			return nil, err
 		}
+
 		peerName = saveData["host_name"] // VMs use the host_name to link to the TAP FD.
 		err = networkCreateTap(saveData["host_name"], d.config)
 	}
@@ -85,6 +89,7 @@ func (d *nicP2P) Start() (*deviceConfig.RunConfig, error) {
 	}
 
 	// Apply and host-side limits and routes.
+	networkQuotaThrottle(d.inst, d.config)
 	err = networkSetupHostVethDevice(d.config, nil, saveData)
 	if err != nil {
 		NetworkRemoveInterface(saveData["host_name"])
@@ -131,6 +136,7 @@ func (d *nicP2P) Update(oldDevices deviceConfig.Devices, isRunning bool) error {
 	v := d.volatileGet()
 
 	// Apply and host-side limits and routes.
+	networkQuotaThrottle(d.inst, d.config)
 	err = networkSetupHostVethDevice(d.config, oldConfig, v)
 	if err != nil {
 		return err
@@ -39,6 +39,7 @@ func (d *nicRouted) validateConfig(instConf instance.ConfigReader) error {
 		"mtu",
 		"hwaddr",
 		"host_name",
+		"host_name.template",
 		"vlan",
 		"ipv4.gateway",
 		"ipv6.gateway",
@@ -199,9 +200,9 @@ func (d *nicRouted) Start() (*deviceConfig.RunConfig, error) {
 		}
 	}
 
-	hostName := d.config["host_name"]
-	if hostName == "" {
-		hostName = networkRandomDevName("veth")
+	hostName, err := networkHostName(d.inst, d.name, d.config, "veth")
+	if err != nil {
+		return nil, err
 	}
 	saveData["host_name"] = hostName
 
@@ -0,0 +1,277 @@
+package device
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/shared"
+)
+
+// How long a device plugin may take to answer a request.
+const pluginTimeout = 30 * time.Second
+
+// Request sent to device plugins on stdin.
+type pluginRequest struct {
+	Action       string            `json:"action"`
+	Name         string            `json:"name"`
+	Config       map[string]string `json:"config"`
+	InstanceType string            `json:"instance_type"`
+
+	// Only set if the action applies to an existing instance.
+	Instance *pluginInstance `json:"instance,omitempty"`
+}
+
+// Instance details passed to device plugins.
+type pluginInstance struct {
+	Name    string `json:"name"`
+	Project string `json:"project"`
+	PID     int    `json:"pid"`
+}
+
+// Response of device plugins on stdout. Paths are relative to the instance's
+// root filesystem.
+type pluginResponse struct {
+	Mounts []struct {
+		Source  string   `json:"source"`
+		Target  string   `json:"target"`
+		FSType  string   `json:"fstype"`
+		Options []string `json:"options"`
+	} `json:"mounts"`
+
+	CGroups []struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	} `json:"cgroups"`
+
+	Uevents [][]string `json:"uevents"`
+}
+
+// Options allowed for the mounts requested by device plugins, which may only be bind mounts.
+var pluginMountOptions = []string{"bind", "rbind", "ro", "rw", "nosuid", "nodev", "noexec", "create=file", "create=dir", "optional"}
+
+// Device cgroup keys and rules (such as "c 240:1 rwm") allowed for device plugins.
+var pluginCGroupKeys = []string{"devices.allow", "devices.deny"}
+var pluginCGroupRule = regexp.MustCompile(`^[abc] (\d+|\*):(\d+|\*) [rwm]+$`)
+
+type plugin struct {
+	deviceCommon
+}
+
+// PluginPath returns the path of the executable implementing the given device plugin.
+func PluginPath(name string) string {
+	return shared.VarPath("plugins", "devices", name)
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *plugin) validateConfig(instConf instance.ConfigReader) error {
+	if !instanceSupported(instConf.Type(), instancetype.Container) {
+		return ErrUnsupportedDevType
+	}
+
+	name := d.config["plugin"]
+	if name == "" {
+		return fmt.Errorf("Missing plugin name")
+	}
+
+	if strings.Contains(name, "/") || shared.StringInSlice(name, []string{".", ".."}) {
+		return fmt.Errorf("Invalid plugin name %q", name)
+	}
+
+	if !shared.PathExists(PluginPath(name)) {
+		return fmt.Errorf("Device plugin %q isn't installed", name)
+	}
+
+	// The other keys are validated by the plugin itself when the device is
+	// added to an instance or started, validation being run too often (and
+	// on profiles) to call out to the plugin.
+	return nil
+}
+
+// Add is run when the device is added to the instance.
+func (d *plugin) Add() error {
+	_, err := d.run("add", d.inst.Type())
+	return err
+}
+
+// Start is run when the device is added to the container.
+func (d *plugin) Start() (*deviceConfig.RunConfig, error) {
+	resp, err := d.run("start", d.inst.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.validateResponse(resp, true)
+	if err != nil {
+		return nil, err
+	}
+
+	runConf := deviceConfig.RunConfig{}
+	for _, mount := range resp.Mounts {
+		opts := mount.Options
+		if !shared.StringInSlice("bind", opts) && !shared.StringInSlice("rbind", opts) {
+			opts = append([]string{"bind"}, opts...)
+		}
+
+		runConf.Mounts = append(runConf.Mounts, deviceConfig.MountEntryItem{
+			DevName:    d.name,
+			DevPath:    mount.Source,
+			TargetPath: strings.TrimPrefix(mount.Target, "/"),
+			FSType:     "none",
+			Opts:       opts,
+		})
+	}
+
+	for _, cgroup := range resp.CGroups {
+		runConf.CGroups = append(runConf.CGroups, deviceConfig.RunConfigItem{Key: cgroup.Key, Value: cgroup.Value})
+	}
+
+	runConf.Uevents = resp.Uevents
+
+	return &runConf, nil
+}
+
+// Stop is run when the device is removed from the instance.
+func (d *plugin) Stop() (*deviceConfig.RunConfig, error) {
+	resp, err := d.run("stop", d.inst.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.validateResponse(resp, false)
+	if err != nil {
+		return nil, err
+	}
+
+	runConf := deviceConfig.RunConfig{}
+	for _, mount := range resp.Mounts {
+		runConf.Mounts = append(runConf.Mounts, deviceConfig.MountEntryItem{
+			DevName:    d.name,
+			TargetPath: strings.TrimPrefix(mount.Target, "/"),
+		})
+	}
+
+	for _, cgroup := range resp.CGroups {
+		runConf.CGroups = append(runConf.CGroups, deviceConfig.RunConfigItem{Key: cgroup.Key, Value: cgroup.Value})
+	}
+
+	runConf.Uevents = resp.Uevents
+
+	return &runConf, nil
+}
+
+// Remove is run when the device is removed from the instance or the instance is deleted.
+func (d *plugin) Remove() error {
+	_, err := d.run("remove", d.inst.Type())
+	return err
+}
+
+// validateResponse checks that the plugin only asks for bind mounts of existing host paths into the
+// instance and for device cgroup rules. Mount sources are only used, and so checked, when starting.
+func (d *plugin) validateResponse(resp *pluginResponse, start bool) error {
+	plugin := d.config["plugin"]
+
+	for _, mount := range resp.Mounts {
+		target := strings.TrimPrefix(mount.Target, "/")
+		if target == "" || shared.StringInSlice("..", strings.Split(target, "/")) {
+			return fmt.Errorf("Device plugin %q returned invalid mount target %q", plugin, mount.Target)
+		}
+
+		if !start {
+			continue
+		}
+
+		if !filepath.IsAbs(mount.Source) || filepath.Clean(mount.Source) != mount.Source || !shared.PathExists(mount.Source) {
+			return fmt.Errorf("Device plugin %q returned invalid mount source %q", plugin, mount.Source)
+		}
+
+		if mount.FSType != "" && mount.FSType != "none" {
+			return fmt.Errorf("Device plugin %q returned unsupported filesystem type %q", plugin, mount.FSType)
+		}
+
+		for _, option := range mount.Options {
+			if !shared.StringInSlice(option, pluginMountOptions) {
+				return fmt.Errorf("Device plugin %q returned unsupported mount option %q", plugin, option)
+			}
+		}
+	}
+
+	for _, cgroup := range resp.CGroups {
+		if !shared.StringInSlice(cgroup.Key, pluginCGroupKeys) {
+			return fmt.Errorf("Device plugin %q returned unsupported cgroup key %q", plugin, cgroup.Key)
+		}
+
+		if !pluginCGroupRule.MatchString(cgroup.Value) {
+			return fmt.Errorf("Device plugin %q returned invalid cgroup rule %q", plugin, cgroup.Value)
+		}
+	}
+
+	return nil
+}
+
+// run calls the plugin for the given action and returns its parsed answer. The plugin reports
+// failures by exiting with a non-zero status, its standard error being used as the error message.
+func (d *plugin) run(action string, instanceType instancetype.Type) (*pluginResponse, error) {
+	req := pluginRequest{
+		Action:       action,
+		Name:         d.name,
+		Config:       d.config,
+		InstanceType: instanceType.String(),
+	}
+
+	if d.inst != nil {
+		req.Instance = &pluginInstance{
+			Name:    d.inst.Name(),
+			Project: d.inst.Project(),
+		}
+
+		if d.inst.IsRunning() {
+			req.Instance.PID = d.inst.InitPID()
+		}
+	}
+
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pluginTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, PluginPath(d.config["plugin"]), action)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+
+		return nil, fmt.Errorf("Device plugin %q failed to %s device %q: %s", d.config["plugin"], action, d.name, msg)
+	}
+
+	resp := pluginResponse{}
+	if len(bytes.TrimSpace(stdout.Bytes())) > 0 {
+		err = json.Unmarshal(stdout.Bytes(), &resp)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid answer from device plugin %q", d.config["plugin"])
+		}
+	}
+
+	return &resp, nil
+}
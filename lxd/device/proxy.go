@@ -20,6 +20,7 @@ import (
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/shared"
+	log "github.com/lxc/lxd/shared/log15"
 	"github.com/lxc/lxd/shared/logger"
 )
 
@@ -72,6 +73,7 @@ func (d *proxy) validateConfig(instConf instance.ConfigReader) error {
 		"security.uid":   unixValidUserID,
 		"security.gid":   unixValidUserID,
 		"proxy_protocol": shared.IsBool,
+		"activation":     shared.IsBool,
 	}
 
 	err := d.config.Validate(rules)
@@ -116,6 +118,20 @@ func (d *proxy) validateConfig(instConf instance.ConfigReader) error {
 		}
 	}
 
+	if shared.IsTrue(d.config["activation"]) {
+		if d.config["bind"] != "" && d.config["bind"] != "host" {
+			return fmt.Errorf("Only host-bound proxies can use activation")
+		}
+
+		if shared.IsTrue(d.config["nat"]) {
+			return fmt.Errorf("Activation can't be used with NAT")
+		}
+
+		if listenAddr.ConnType != "tcp" || connectAddr.ConnType != "tcp" || len(listenAddr.Addr) != 1 {
+			return fmt.Errorf("Activation is only supported for proxying a single tcp port")
+		}
+	}
+
 	return nil
 }
 
@@ -135,6 +151,9 @@ func (d *proxy) Start() (*deviceConfig.RunConfig, error) {
 		return nil, err
 	}
 
+	// Free the address if it was held to start the container on demand.
+	ProxyActivationRelease(d.inst.ID(), d.name)
+
 	// Proxy devices have to be setup once the container is running.
 	runConf := deviceConfig.RunConfig{}
 	runConf.PostHooks = []func() error{
@@ -238,6 +257,7 @@ func (d *proxy) Stop() (*deviceConfig.RunConfig, error) {
 
 	if !shared.PathExists(devPath) {
 		// There's no proxy process if NAT is enabled
+		d.activationListen()
 		return nil, nil
 	}
 
@@ -246,9 +266,55 @@ func (d *proxy) Stop() (*deviceConfig.RunConfig, error) {
 		return nil, err
 	}
 
+	// Hold the address now that the proxy released it.
+	d.activationListen()
+
 	return nil, nil
 }
 
+// Add holds the address of the device while the container is stopped if activation is enabled.
+func (d *proxy) Add() error {
+	if !d.inst.IsRunning() {
+		d.activationListen()
+	}
+
+	return nil
+}
+
+// Remove releases the address of the device if it was held for activation.
+func (d *proxy) Remove() error {
+	ProxyActivationRelease(d.inst.ID(), d.name)
+	return nil
+}
+
+// activationListen starts listening on the address of the device if activation is enabled, so that
+// incoming connections start the container. Failures are only logged as the container can still
+// be started by other means.
+func (d *proxy) activationListen() {
+	if !shared.IsTrue(d.config["activation"]) || ProxyActivate == nil {
+		return
+	}
+
+	listenAddr, err := ProxyParseAddr(d.config["listen"])
+	if err != nil || len(listenAddr.Addr) != 1 {
+		return
+	}
+
+	connectAddr, err := ProxyParseAddr(d.config["connect"])
+	if err != nil || len(connectAddr.Addr) == 0 {
+		return
+	}
+
+	s := d.state
+	instanceID := d.inst.ID()
+	err = ProxyActivationListen(instanceID, d.name, listenAddr.Addr[0], func(conn net.Conn) {
+		ProxyActivate(s, instanceID, listenAddr.Addr[0], connectAddr.Addr[0], conn)
+	})
+	if err != nil {
+		logger.Warn("Failed to listen for proxy activation", log.Ctx{"project": d.inst.Project(), "instance": d.inst.Name(), "device": d.name, "err": err})
+	}
+}
+
 func (d *proxy) setupNAT() error {
 	listenAddr, err := ProxyParseAddr(d.config["listen"])
 	if err != nil {
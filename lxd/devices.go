@@ -17,9 +17,11 @@ import (
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/warnings"
 	"github.com/lxc/lxd/shared"
 	log "github.com/lxc/lxd/shared/log15"
 	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
 )
 
 /*
@@ -384,8 +386,12 @@ func deviceTaskBalance(s *state.State) {
 	balancedInstances := map[instance.Instance]int{}
 	for _, c := range instances {
 		conf := c.ExpandedConfig()
-		cpulimit, ok := conf["limits.cpu"]
-		if !ok || cpulimit == "" {
+		cpulimit := conf["limits.cpu"]
+		if conf["volatile.autoscale.cpu"] != "" {
+			cpulimit = conf["volatile.autoscale.cpu"]
+		}
+
+		if cpulimit == "" {
 			cpulimit = effectiveCpus
 		}
 
@@ -485,7 +491,8 @@ func deviceTaskBalance(s *state.State) {
 		sort.Strings(set)
 		err := ctn.CGroupSet("cpuset.cpus", strings.Join(set, ","))
 		if err != nil {
-			logger.Error("balance: Unable to set cpuset", log.Ctx{"name": ctn.Name(), "err": err, "value": strings.Join(set, ",")})
+			entityURL := fmt.Sprintf("/%s/instances/%s", version.APIVersion, ctn.Name())
+			warnings.Raise(s.Cluster, ctn.Project(), entityURL, warnings.TypeLimitNotApplied, warnings.SeverityModerate, fmt.Sprintf("Failed to apply limits.cpu to %q: %v", ctn.Name(), err))
 		}
 	}
 }
@@ -10,6 +10,7 @@ import (
 	"sync"
 
 	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/tools"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/subprocess"
 	"github.com/lxc/lxd/shared/version"
@@ -101,13 +102,12 @@ func Kill(name string, reload bool) error {
 
 // GetVersion returns the version of dnsmasq.
 func GetVersion() (*version.DottedVersion, error) {
-	output, err := shared.RunCommandCLocale("dnsmasq", "--version")
+	dnsmasqVersion, err := tools.Version("dnsmasq")
 	if err != nil {
 		return nil, fmt.Errorf("Failed to check dnsmasq version: %v", err)
 	}
 
-	lines := strings.Split(string(output), " ")
-	return version.NewDottedVersion(lines[2])
+	return version.NewDottedVersion(dnsmasqVersion)
 }
 
 // DHCPStaticIPs retrieves the dnsmasq statically allocated IPs for a container.
@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/response"
@@ -94,5 +96,43 @@ func eventsSocket(d *Daemon, r *http.Request, w http.ResponseWriter) error {
 }
 
 func eventsGet(d *Daemon, r *http.Request) response.Response {
+	if shared.IsTrue(r.FormValue("history")) {
+		return eventsHistoryGet(d, r)
+	}
+
 	return &eventsServe{req: r, d: d}
 }
+
+// eventsHistoryGet returns the events retained by this member, oldest first.
+func eventsHistoryGet(d *Daemon, r *http.Request) response.Response {
+	project := projectParam(r)
+	if !d.userHasPermission(r, project, "view") {
+		return response.Forbidden(nil)
+	}
+
+	typeStr := r.FormValue("type")
+	if typeStr == "" {
+		typeStr = "operation,lifecycle"
+	}
+
+	since := time.Time{}
+	if r.FormValue("since") != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, r.FormValue("since"))
+		if err != nil {
+			return response.BadRequest(fmt.Errorf("Invalid since timestamp: %v", err))
+		}
+	}
+
+	var serverName string
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		serverName, err = tx.NodeName()
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, d.events.History(project, strings.Split(typeStr, ","), since, serverName))
+}
@@ -22,6 +22,19 @@ type Server struct {
 
 	listeners map[string]*Listener
 	lock      sync.Mutex
+
+	// Events kept around for later queries, oldest first.
+	history       []historyEvent
+	historyLock   sync.Mutex
+	historyPeriod time.Duration
+}
+
+// Maximum number of events kept in the history, whatever the retention period.
+const historyMax = 10000
+
+type historyEvent struct {
+	group string
+	event api.Event
 }
 
 // NewServer returns a new event server.
@@ -59,6 +72,75 @@ func (s *Server) AddListener(group string, connection *websocket.Conn, messageTy
 	return listener, nil
 }
 
+// SetRetention sets for how long events are kept in the history, zero disabling it.
+func (s *Server) SetRetention(period time.Duration) {
+	s.historyLock.Lock()
+	defer s.historyLock.Unlock()
+
+	s.historyPeriod = period
+	s.pruneHistory(time.Now())
+}
+
+// History returns the events of the given group (or of all groups if "*") and types sent since the
+// given time, oldest first. Events without a location get the given one.
+func (s *Server) History(group string, messageTypes []string, since time.Time, location string) []api.Event {
+	s.historyLock.Lock()
+	defer s.historyLock.Unlock()
+
+	s.pruneHistory(time.Now())
+
+	events := []api.Event{}
+	for _, entry := range s.history {
+		if group != "*" && entry.group != "" && entry.group != group {
+			continue
+		}
+
+		if !shared.StringInSlice(entry.event.Type, messageTypes) || entry.event.Timestamp.Before(since) {
+			continue
+		}
+
+		event := entry.event
+		if event.Location == "" {
+			event.Location = location
+		}
+
+		events = append(events, event)
+	}
+
+	return events
+}
+
+// record adds an event to the history. Logging events are never kept.
+func (s *Server) record(group string, event api.Event) {
+	if event.Type == "logging" {
+		return
+	}
+
+	s.historyLock.Lock()
+	defer s.historyLock.Unlock()
+
+	if s.historyPeriod <= 0 {
+		return
+	}
+
+	s.history = append(s.history, historyEvent{group: group, event: event})
+	s.pruneHistory(event.Timestamp)
+}
+
+// pruneHistory drops the events older than the retention period. Must be called with historyLock.
+func (s *Server) pruneHistory(now time.Time) {
+	i := 0
+	for i < len(s.history) && (s.historyPeriod <= 0 || now.Sub(s.history[i].event.Timestamp) > s.historyPeriod) {
+		i++
+	}
+
+	if len(s.history)-i > historyMax {
+		i = len(s.history) - historyMax
+	}
+
+	s.history = s.history[i:]
+}
+
 // SendLifecycle broadcasts a lifecycle event.
 func (s *Server) SendLifecycle(group, action, source string,
 	context map[string]interface{}) error {
@@ -110,6 +192,8 @@ func (s *Server) Forward(id int64, event api.Event) {
 }
 
 func (s *Server) broadcast(group string, event api.Event, isForward bool) error {
+	s.record(group, event)
+
 	s.lock.Lock()
 	listeners := s.listeners
 	for _, listener := range listeners {
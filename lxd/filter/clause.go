@@ -45,6 +45,9 @@ func Parse(s string) ([]Clause, error) {
 			return nil, fmt.Errorf("clause has no operator")
 		}
 		clause.Operator = parts[index]
+		if !shared.StringInSlice(clause.Operator, []string{"eq", "ne"}) {
+			return nil, fmt.Errorf("invalid operator %q", clause.Operator)
+		}
 
 		index++
 		if index == len(parts) {
@@ -1,12 +1,17 @@
 package filter
 
+import (
+	"reflect"
+	"strconv"
+)
+
 // Match returns true if the given object matches the given filter.
 func Match(obj interface{}, clauses []Clause) bool {
 	match := true
 
 	for _, clause := range clauses {
 		value := ValueOf(obj, clause.Field)
-		clauseMatch := value == clause.Value
+		clauseMatch := matchValue(value, clause.Value)
 
 		if clause.Operator == "ne" {
 			clauseMatch = !clauseMatch
@@ -29,3 +34,28 @@ func Match(obj interface{}, clauses []Clause) bool {
 
 	return match
 }
+
+// matchValue returns whether a field value matches the value of a clause. Booleans and numbers are
+// compared through their string representation.
+func matchValue(value interface{}, expected string) bool {
+	if value == nil {
+		return false
+	}
+
+	var str string
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String:
+		str = v.String()
+	case reflect.Bool:
+		str = strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		str = strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		str = strconv.FormatUint(v.Uint(), 10)
+	default:
+		return false
+	}
+
+	return str == expected
+}
@@ -31,7 +31,8 @@ func TestMatch_Instance(t *testing.T) {
 				"type": "disk",
 			},
 		},
-		Status: "Running",
+		Status:     "Running",
+		StatusCode: api.Running,
 	}
 	cases := map[string]interface{}{
 		"architecture eq x86_64":                                         true,
@@ -40,6 +41,9 @@ func TestMatch_Instance(t *testing.T) {
 		"config.image.os eq BusyBox and expanded_devices.root.path eq /": true,
 		"name eq c2 or status eq Running":                                true,
 		"name eq c2 or name eq c3":                                       false,
+		"status eq running and config.image.os eq BusyBox":               false,
+		"stateful eq false and not status_code ne 103":                   true,
+		"expanded_devices eq root":                                       false,
 	}
 	for s := range cases {
 		t.Run(s, func(t *testing.T) {
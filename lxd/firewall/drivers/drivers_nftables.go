@@ -220,6 +220,39 @@ func (d Nftables) NetworkSetupOutboundNAT(networkName string, subnet *net.IPNet,
 	return nil
 }
 
+// NetworkSetupPeer allows forwarding between the network and a peered network, bypassing outbound NAT.
+// The rules are inserted at the start of the network's chains so that they take precedence over the
+// NAT and forwarding policy rules.
+func (d Nftables) NetworkSetupPeer(networkName string, peerNetworkName string, subnet *net.IPNet, peerSubnet *net.IPNet) error {
+	family := "ip"
+	if subnet.IP.To4() == nil {
+		family = "ip6"
+	}
+
+	tplFields := map[string]interface{}{
+		"namespace":       nftablesNamespace,
+		"chainSeparator":  nftablesChainSeparator,
+		"networkName":     networkName,
+		"peerNetworkName": peerNetworkName,
+		"family":          family,
+		"subnet":          subnet.String(),
+		"peerSubnet":      peerSubnet.String(),
+	}
+
+	// Make sure the chains exist, even if NAT or the forwarding policy aren't in use.
+	err := d.applyNftConfig(nftablesNetPeer, tplFields)
+	if err != nil {
+		return errors.Wrapf(err, "Failed adding peer rules for network %q (%s)", networkName, family)
+	}
+
+	err = d.applyNftRules(nftablesNetPeerRules, tplFields)
+	if err != nil {
+		return errors.Wrapf(err, "Failed adding peer rules for network %q (%s)", networkName, family)
+	}
+
+	return nil
+}
+
 // NetworkSetupDHCPDNSAccess sets up basic nftables overrides for DHCP/DNS.
 func (d Nftables) NetworkSetupDHCPDNSAccess(networkName string, ipVersion uint) error {
 	family, err := d.getIPFamily(ipVersion)
@@ -442,6 +475,23 @@ func (d Nftables) applyNftConfig(tpl *template.Template, tplFields map[string]in
 	return nil
 }
 
+// applyNftRules loads the specified template and then runs it as a standalone nftables script, for
+// rule changes that can't be expressed as a table definition.
+func (d Nftables) applyNftRules(tpl *template.Template, tplFields map[string]interface{}) error {
+	config := &strings.Builder{}
+	err := tpl.Execute(config, tplFields)
+	if err != nil {
+		return errors.Wrapf(err, "Failed running %q template", tpl.Name())
+	}
+
+	_, err = shared.RunCommand("nft", config.String())
+	if err != nil {
+		return errors.Wrapf(err, "Failed apply nftables rules")
+	}
+
+	return nil
+}
+
 // removeChains removes the specified chains from the specified families.
 // If not empty, chain suffix is appended to each chain name, separated with "_".
 func (d Nftables) removeChains(families []string, chainSuffix string, chains ...string) error {
@@ -509,3 +559,39 @@ func (d Nftables) InstanceClearRPFilter(projectName string, instanceName string,
 
 	return nil
 }
+
+// InstanceSetupNetPriority sets the priority of the packets forwarded from the specified instance device to
+// the class of the uplink qdisc they should be queued in.
+func (d Nftables) InstanceSetupNetPriority(projectName string, instanceName string, deviceName string, parentName string, hostName string, hwAddr string, class string) error {
+	deviceLabel := d.instanceDeviceLabel(projectName, instanceName, deviceName)
+	tplFields := map[string]interface{}{
+		"namespace":      nftablesNamespace,
+		"chainSeparator": nftablesChainSeparator,
+		"deviceLabel":    deviceLabel,
+		"parentName":     parentName,
+		"hostName":       hostName,
+		"hwAddr":         hwAddr,
+		"class":          class,
+	}
+
+	for _, family := range []string{"bridge", "ip", "ip6"} {
+		tplFields["family"] = family
+		err := d.applyNftConfig(nftablesInstanceNetPriority, tplFields)
+		if err != nil {
+			return errors.Wrapf(err, "Failed adding priority rules for instance device %q (%s)", deviceLabel, family)
+		}
+	}
+
+	return nil
+}
+
+// InstanceClearNetPriority removes the priority rules for the specified instance device.
+func (d Nftables) InstanceClearNetPriority(projectName string, instanceName string, deviceName string) error {
+	deviceLabel := d.instanceDeviceLabel(projectName, instanceName, deviceName)
+	err := d.removeChains([]string{"bridge", "ip", "ip6"}, deviceLabel, "prio")
+	if err != nil {
+		return errors.Wrapf(err, "Failed clearing priority rules for instance device %q", deviceLabel)
+	}
+
+	return nil
+}
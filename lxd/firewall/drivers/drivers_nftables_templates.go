@@ -29,6 +29,24 @@ chain pstrt{{.chainSeparator}}{{.networkName}} {
 }
 `))
 
+var nftablesNetPeer = template.Must(template.New("nftablesNetPeer").Parse(`
+chain fwd{{.chainSeparator}}{{.networkName}} {
+	type filter hook forward priority 0; policy accept;
+}
+
+chain pstrt{{.chainSeparator}}{{.networkName}} {
+	type nat hook postrouting priority 100; policy accept;
+}
+`))
+
+// nftablesNetPeerRules is applied on its own rather than inside the common table, as the peering rules
+// need inserting ahead of the existing NAT and forwarding policy rules of the network's chains.
+var nftablesNetPeerRules = template.Must(template.New("nftablesNetPeerRules").Parse(`
+insert rule {{.family}} {{.namespace}} pstrt{{.chainSeparator}}{{.networkName}} {{.family}} saddr {{.subnet}} {{.family}} daddr {{.peerSubnet}} accept
+insert rule {{.family}} {{.namespace}} fwd{{.chainSeparator}}{{.networkName}} iifname "{{.networkName}}" oifname "{{.peerNetworkName}}" accept
+insert rule {{.family}} {{.namespace}} fwd{{.chainSeparator}}{{.networkName}} iifname "{{.peerNetworkName}}" oifname "{{.networkName}}" accept
+`))
+
 var nftablesNetDHCPDNS = template.Must(template.New("nftablesNetDHCPDNS").Parse(`
 chain in{{.chainSeparator}}{{.networkName}} {
 	type filter hook input priority 0; policy accept;
@@ -134,3 +152,16 @@ chain prert{{.chainSeparator}}{{.deviceLabel}} {
 	iif "{{.hostName}}" fib saddr . iif oif missing drop
 }
 `))
+
+// nftablesInstanceNetPriority defines the rules to set the priority of the packets sent by an instance.
+// Bridged packets are matched on the host side interface, routed ones on the parent and MAC address.
+var nftablesInstanceNetPriority = template.Must(template.New("nftablesInstanceNetPriority").Parse(`
+chain prio{{.chainSeparator}}{{.deviceLabel}} {
+	type filter hook forward priority 0; policy accept;
+	{{if eq .family "bridge" -}}
+	iifname "{{.hostName}}" meta priority set {{.class}}
+	{{- else -}}
+	iifname "{{.parentName}}" ether saddr {{.hwAddr}} meta priority set {{.class}}
+	{{- end}}
+}
+`))
@@ -193,6 +193,33 @@ func (d Xtables) NetworkSetupOutboundNAT(networkName string, subnet *net.IPNet,
 	return nil
 }
 
+// NetworkSetupPeer allows forwarding between the network and a peered network, bypassing outbound NAT.
+// The rules are prepended so that they take precedence over the NAT and forwarding policy rules.
+func (d Xtables) NetworkSetupPeer(networkName string, peerNetworkName string, subnet *net.IPNet, peerSubnet *net.IPNet) error {
+	family := uint(4)
+	if subnet.IP.To4() == nil {
+		family = 6
+	}
+
+	comment := d.networkIPTablesComment(networkName)
+	err := d.iptablesPrepend(family, comment, "nat", "POSTROUTING", "-s", subnet.String(), "-d", peerSubnet.String(), "-j", "RETURN")
+	if err != nil {
+		return err
+	}
+
+	err = d.iptablesPrepend(family, comment, "filter", "FORWARD", "-i", networkName, "-o", peerNetworkName, "-j", "ACCEPT")
+	if err != nil {
+		return err
+	}
+
+	err = d.iptablesPrepend(family, comment, "filter", "FORWARD", "-i", peerNetworkName, "-o", networkName, "-j", "ACCEPT")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // NetworkSetupDHCPDNSAccess sets up basic iptables overrides for DHCP/DNS.
 func (d Xtables) NetworkSetupDHCPDNSAccess(networkName string, ipVersion uint) error {
 	var rules [][]string
@@ -711,3 +738,44 @@ func (d Xtables) InstanceClearRPFilter(projectName string, instanceName string,
 
 	return nil
 }
+
+// InstanceSetupNetPriority sets the priority of the packets forwarded from the specified instance device to
+// the class of the uplink qdisc they should be queued in. Only routed packets are covered, as well as
+// bridged ones when br_netfilter passes them to iptables.
+func (d Xtables) InstanceSetupNetPriority(projectName string, instanceName string, deviceName string, parentName string, hostName string, hwAddr string, class string) error {
+	comment := fmt.Sprintf("%s priority", d.instanceDeviceIPTablesComment(projectName, instanceName, deviceName))
+	args := []string{
+		"-i", parentName,
+		"-m", "mac",
+		"--mac-source", hwAddr,
+		"-j", "CLASSIFY",
+		"--set-class", class,
+	}
+
+	for _, ipVersion := range []uint{4, 6} {
+		err := d.iptablesPrepend(ipVersion, comment, "mangle", "FORWARD", args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// InstanceClearNetPriority removes the priority rules for the specified instance device.
+func (d Xtables) InstanceClearNetPriority(projectName string, instanceName string, deviceName string) error {
+	comment := fmt.Sprintf("%s priority", d.instanceDeviceIPTablesComment(projectName, instanceName, deviceName))
+	errs := []error{}
+	for _, ipVersion := range []uint{4, 6} {
+		err := d.iptablesClear(ipVersion, comment, "mangle")
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("Failed to remove priority rules for %q: %v", deviceName, errs)
+	}
+
+	return nil
+}
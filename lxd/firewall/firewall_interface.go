@@ -15,6 +15,7 @@ type Firewall interface {
 	NetworkSetupOutboundNAT(networkName string, subnet *net.IPNet, srcIP net.IP, append bool) error
 	NetworkSetupDHCPDNSAccess(networkName string, ipVersion uint) error
 	NetworkSetupDHCPv4Checksum(networkName string) error
+	NetworkSetupPeer(networkName string, peerNetworkName string, subnet *net.IPNet, peerSubnet *net.IPNet) error
 	NetworkClear(networkName string, ipVersion uint) error
 
 	InstanceSetupBridgeFilter(projectName string, instanceName string, deviceName string, parentName string, hostName string, hwAddr string, IPv4 net.IP, IPv6 net.IP) error
@@ -25,4 +26,7 @@ type Firewall interface {
 
 	InstanceSetupRPFilter(projectName string, instanceName string, deviceName string, hostName string) error
 	InstanceClearRPFilter(projectName string, instanceName string, deviceName string) error
+
+	InstanceSetupNetPriority(projectName string, instanceName string, deviceName string, parentName string, hostName string, hwAddr string, class string) error
+	InstanceClearNetPriority(projectName string, instanceName string, deviceName string) error
 }
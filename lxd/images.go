@@ -38,6 +38,7 @@ import (
 	storagePools "github.com/lxc/lxd/lxd/storage"
 	"github.com/lxc/lxd/lxd/task"
 	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/lxd/warnings"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/ioprogress"
@@ -1108,14 +1109,17 @@ func autoUpdateImage(d *Daemon, op *operations.Operation, id int, info *api.Imag
 
 	// Update the image on each pool where it currently exists.
 	hash := fingerprint
+	entityURL := fmt.Sprintf("/%s/images/%s", version.APIVersion, fingerprint)
 
 	for _, poolName := range poolNames {
 		newInfo, err := d.ImageDownload(op, source.Server, source.Protocol, source.Certificate, "", source.Alias, info.Type, false, true, poolName, false, project)
 		if err != nil {
-			logger.Error("Failed to update the image", log.Ctx{"err": err, "fp": fingerprint})
+			warnings.Raise(d.cluster, project, entityURL, warnings.TypeImageRefreshFailed, warnings.SeverityLow, fmt.Sprintf("Failed to refresh image %q from %q: %v", fingerprint, source.Server, err))
 			continue
 		}
 
+		warnings.Resolve(d.cluster, project, entityURL, warnings.TypeImageRefreshFailed)
+
 		hash = newInfo.Fingerprint
 		if hash == fingerprint {
 			logger.Debug("Already up to date", log.Ctx{"fp": fingerprint})
@@ -1697,11 +1701,29 @@ func imageAliasesPost(d *Daemon, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
+	channels, err := imageAliasChannels(d, project, req.Channels)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
 	err = d.cluster.ImageAliasAdd(project, req.Name, id, req.Description)
 	if err != nil {
 		return response.SmartError(err)
 	}
 
+	if len(channels) > 0 {
+		aliasID, _, err := d.cluster.ImageAliasGet(project, req.Name, true)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		err = d.cluster.ImageAliasChannelsUpdate(aliasID, channels)
+		if err != nil {
+			d.cluster.ImageAliasDelete(project, req.Name)
+			return response.SmartError(err)
+		}
+	}
+
 	return response.SyncResponseLocation(true, nil, fmt.Sprintf("/%s/images/aliases/%s", version.APIVersion, req.Name))
 }
 
@@ -1741,7 +1763,7 @@ func imageAliasGet(d *Daemon, r *http.Request) response.Response {
 	name := mux.Vars(r)["name"]
 	public := d.checkTrustedClient(r) != nil || allowProjectPermission("images", "view")(d, r) != response.EmptySyncResponse
 
-	_, alias, err := d.cluster.ImageAliasGet(project, name, !public)
+	alias, err := d.cluster.ImageAliasResolve(project, name, !public)
 	if err != nil {
 		return response.SmartError(err)
 	}
@@ -1794,11 +1816,24 @@ func imageAliasPut(d *Daemon, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
+	channels, err := imageAliasChannels(d, project, req.Channels)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
 	err = d.cluster.ImageAliasUpdate(id, imageId, req.Description)
 	if err != nil {
 		return response.SmartError(err)
 	}
 
+	// Leave the channels alone for clients unaware of them.
+	if req.Channels != nil {
+		err = d.cluster.ImageAliasChannelsUpdate(id, channels)
+		if err != nil {
+			return response.SmartError(err)
+		}
+	}
+
 	return response.EmptySyncResponse
 }
 
@@ -1842,16 +1877,44 @@ func imageAliasPatch(d *Daemon, r *http.Request) response.Response {
 		alias.Description = description
 	}
 
+	_, ok = req["channels"]
+	if ok {
+		value, ok := req["channels"].(map[string]interface{})
+		if !ok && req["channels"] != nil {
+			return response.BadRequest(fmt.Errorf("Invalid value for channels"))
+		}
+
+		alias.Channels = map[string]string{}
+		for channel, target := range value {
+			targetStr, ok := target.(string)
+			if !ok {
+				return response.BadRequest(fmt.Errorf("Invalid target for channel %q", channel))
+			}
+
+			alias.Channels[channel] = targetStr
+		}
+	}
+
 	imageId, _, err := d.cluster.ImageGet(project, alias.Target, false, false)
 	if err != nil {
 		return response.SmartError(err)
 	}
 
+	channels, err := imageAliasChannels(d, project, alias.Channels)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
 	err = d.cluster.ImageAliasUpdate(id, imageId, alias.Description)
 	if err != nil {
 		return response.SmartError(err)
 	}
 
+	err = d.cluster.ImageAliasChannelsUpdate(id, channels)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
 	return response.EmptySyncResponse
 }
 
@@ -1883,6 +1946,26 @@ func imageAliasPost(d *Daemon, r *http.Request) response.Response {
 	return response.SyncResponseLocation(true, nil, fmt.Sprintf("/%s/images/aliases/%s", version.APIVersion, req.Name))
 }
 
+// imageAliasChannels validates the given alias channels and returns the IDs of their target
+// images, indexed by channel name.
+func imageAliasChannels(d *Daemon, project string, channels map[string]string) (map[string]int, error) {
+	ids := map[string]int{}
+	for channel, target := range channels {
+		if channel == "" || strings.ContainsAny(channel, ":/") {
+			return nil, fmt.Errorf("Invalid channel name %q", channel)
+		}
+
+		id, _, err := d.cluster.ImageGet(project, target, false, false)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Fetch target image %q of channel %q", target, channel)
+		}
+
+		ids[channel] = id
+	}
+
+	return ids, nil
+}
+
 func imageExport(d *Daemon, r *http.Request) response.Response {
 	project := projectParam(r)
 	fingerprint := mux.Vars(r)["fingerprint"]
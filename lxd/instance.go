@@ -4,15 +4,14 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
 	liblxc "gopkg.in/lxc/go-lxc.v2"
-	cron "gopkg.in/robfig/cron.v2"
 
 	"github.com/flosch/pongo2"
 	"github.com/lxc/lxd/lxd/cluster"
@@ -20,13 +19,17 @@ import (
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/instance/operationlock"
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/revert"
 	"github.com/lxc/lxd/lxd/state"
 	storagePools "github.com/lxc/lxd/lxd/storage"
 	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/lxd/tools"
+	"github.com/lxc/lxd/lxd/tracing"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	log "github.com/lxc/lxd/shared/log15"
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/osarch"
@@ -42,14 +45,9 @@ func instanceCreateAsEmpty(d *Daemon, args db.InstanceArgs) (instance.Instance,
 		return nil, err
 	}
 
-	revert := true
-	defer func() {
-		if !revert {
-			return
-		}
-
-		inst.Delete()
-	}()
+	revert := revert.New()
+	defer revert.Fail()
+	revert.Add(func() { inst.Delete() })
 
 	pool, err := storagePools.GetPoolByInstance(d.State(), inst)
 	if err != nil {
@@ -67,10 +65,39 @@ func instanceCreateAsEmpty(d *Daemon, args db.InstanceArgs) (instance.Instance,
 		return nil, err
 	}
 
-	revert = false
+	revert.Success()
 	return inst, nil
 }
 
+// instanceImageEnsureLocal makes sure the image is available on this node, importing it from
+// another node of the cluster if needed.
+func instanceImageEnsureLocal(d *Daemon, projectName string, hash string) error {
+	nodeAddress, err := d.cluster.ImageLocate(hash)
+	if err != nil {
+		return errors.Wrapf(err, "Locate image %s in the cluster", hash)
+	}
+
+	if nodeAddress == "" {
+		return nil
+	}
+
+	// The image is available from another node, let's try to import it.
+	logger.Debugf("Transferring image %s from node %s", hash, nodeAddress)
+	client, err := cluster.Connect(nodeAddress, d.endpoints.NetworkCert(), false)
+	if err != nil {
+		return err
+	}
+
+	client = client.UseProject(projectName)
+
+	err = imageImportFromNode(filepath.Join(d.os.VarDir, "images"), client, hash)
+	if err != nil {
+		return err
+	}
+
+	return d.cluster.ImageAssociateNode(projectName, hash)
+}
+
 // instanceCreateFromImage creates an instance from a rootfs image.
 func instanceCreateFromImage(d *Daemon, args db.InstanceArgs, hash string, op *operations.Operation) (instance.Instance, error) {
 	s := d.State()
@@ -97,29 +124,11 @@ func instanceCreateFromImage(d *Daemon, args db.InstanceArgs, hash string, op *o
 	}
 
 	// Check if the image is available locally or it's on another node.
-	nodeAddress, err := s.Cluster.ImageLocate(hash)
+	_, span := tracing.StartChild(op.Context(), "image transfer")
+	err = instanceImageEnsureLocal(d, args.Project, hash)
+	span.End(err)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Locate image %s in the cluster", hash)
-	}
-	if nodeAddress != "" {
-		// The image is available from another node, let's try to import it.
-		logger.Debugf("Transferring image %s from node %s", hash, nodeAddress)
-		client, err := cluster.Connect(nodeAddress, d.endpoints.NetworkCert(), false)
-		if err != nil {
-			return nil, err
-		}
-
-		client = client.UseProject(args.Project)
-
-		err = imageImportFromNode(filepath.Join(d.os.VarDir, "images"), client, hash)
-		if err != nil {
-			return nil, err
-		}
-
-		err = d.cluster.ImageAssociateNode(args.Project, hash)
-		if err != nil {
-			return nil, err
-		}
+		return nil, err
 	}
 
 	// Set the "image.*" keys.
@@ -133,19 +142,16 @@ func instanceCreateFromImage(d *Daemon, args db.InstanceArgs, hash string, op *o
 	args.BaseImage = hash
 
 	// Create the instance.
-	inst, err := instanceCreateInternal(s, args)
+	ctx, span := tracing.StartChild(op.Context(), "instance record")
+	inst, err := instanceCreateInternalContext(ctx, s, args)
+	span.End(err)
 	if err != nil {
 		return nil, errors.Wrap(err, "Create instance")
 	}
 
-	revert := true
-	defer func() {
-		if !revert {
-			return
-		}
-
-		inst.Delete()
-	}()
+	revert := revert.New()
+	defer revert.Fail()
+	revert.Add(func() { inst.Delete() })
 
 	err = s.Cluster.ImageLastAccessUpdate(hash, time.Now().UTC())
 	if err != nil {
@@ -157,7 +163,10 @@ func instanceCreateFromImage(d *Daemon, args db.InstanceArgs, hash string, op *o
 		return nil, errors.Wrap(err, "Load instance storage pool")
 	}
 
+	_, span = tracing.StartChild(op.Context(), "image unpack")
+	span.SetAttribute("lxd.image.fingerprint", hash)
 	err = pool.CreateInstanceFromImage(inst, hash, op)
+	span.End(err)
 	if err != nil {
 		return nil, errors.Wrap(err, "Create instance from image")
 	}
@@ -168,30 +177,23 @@ func instanceCreateFromImage(d *Daemon, args db.InstanceArgs, hash string, op *o
 		return nil, errors.Wrap(err, "Configure instance")
 	}
 
-	revert = false
+	revert.Success()
 	return inst, nil
 }
 
 func instanceCreateAsCopy(s *state.State, args db.InstanceArgs, sourceInst instance.Instance, instanceOnly bool, refresh bool, op *operations.Operation) (instance.Instance, error) {
-	var inst, revertInst instance.Instance
+	var inst instance.Instance
 	var err error
 
-	defer func() {
-		if revertInst == nil {
-			return
-		}
-
-		revertInst.Delete()
-	}()
+	revert := revert.New()
+	defer revert.Fail()
 
 	if refresh {
 		// Load the target instance.
 		inst, err = instance.LoadByProjectAndName(s, args.Project, args.Name)
 		if err != nil {
 			refresh = false // Instance doesn't exist, so switch to copy mode.
-		}
-
-		if inst.IsRunning() {
+		} else if inst.IsRunning() {
 			return nil, fmt.Errorf("Cannot refresh a running instance")
 		}
 	}
@@ -203,7 +205,9 @@ func instanceCreateAsCopy(s *state.State, args db.InstanceArgs, sourceInst insta
 		if err != nil {
 			return nil, err
 		}
-		revertInst = inst
+
+		// Deleting the instance also deletes any snapshot created below.
+		revert.Add(func() { inst.Delete() })
 	}
 
 	// At this point we have already figured out the parent container's root disk device so we
@@ -286,6 +290,12 @@ func instanceCreateAsCopy(s *state.State, args db.InstanceArgs, sourceInst insta
 				return nil, err
 			}
 
+			// When refreshing the target instance is kept on failure, so
+			// only the snapshots added to it need to be removed.
+			if refresh {
+				revert.Add(func() { snapInst.Delete() })
+			}
+
 			// Set snapshot creation date to that of the source snapshot.
 			err = s.Cluster.InstanceSnapshotCreationUpdate(snapInst.ID(), srcSnap.CreationDate())
 			if err != nil {
@@ -329,7 +339,7 @@ func instanceCreateAsCopy(s *state.State, args db.InstanceArgs, sourceInst insta
 		}
 	}
 
-	revertInst = nil
+	revert.Success()
 	return inst, nil
 }
 
@@ -338,14 +348,37 @@ func instanceCreateAsSnapshot(s *state.State, args db.InstanceArgs, sourceInstan
 		return nil, fmt.Errorf("Source instance and snapshot instance types do not match")
 	}
 
+	revert := revert.New()
+	defer revert.Fail()
+
+	// Run the snapshot hooks of the instance around the snapshot.
+	err := instanceSnapshotHook(sourceInstance, "snapshots.pre")
+	if err != nil {
+		logger.Warn("Failed to run pre-snapshot hook", log.Ctx{"project": sourceInstance.Project(), "instance": sourceInstance.Name(), "err": err})
+		if sourceInstance.ExpandedConfig()["snapshots.hooks.failure"] != "ignore" {
+			instanceSnapshotHook(sourceInstance, "snapshots.post")
+			return nil, errors.Wrap(err, "Pre-snapshot hook")
+		}
+	}
+
+	defer func() {
+		err := instanceSnapshotHook(sourceInstance, "snapshots.post")
+		if err != nil {
+			logger.Warn("Failed to run post-snapshot hook", log.Ctx{"project": sourceInstance.Project(), "instance": sourceInstance.Name(), "err": err})
+		}
+	}()
+
 	// Deal with state.
 	if args.Stateful {
+		if sourceInstance.Type() != instancetype.Container {
+			return nil, fmt.Errorf("Stateful snapshots are only supported for containers")
+		}
+
 		if !sourceInstance.IsRunning() {
 			return nil, fmt.Errorf("Unable to create a stateful snapshot. The instance isn't running")
 		}
 
-		_, err := exec.LookPath("criu")
-		if err != nil {
+		if !tools.Available("criu") {
 			return nil, fmt.Errorf("Unable to create a stateful snapshot. CRIU isn't installed")
 		}
 
@@ -375,16 +408,16 @@ func instanceCreateAsSnapshot(s *state.State, args db.InstanceArgs, sourceInstan
 			PreDumpDir:   "",
 		}
 
+		// The dump is copied into the snapshot along with the filesystem, the source only keeps
+		// it until then.
+		revert.Add(func() { os.RemoveAll(sourceInstance.StatePath()) })
+
 		err = sourceInstance.Migrate(&criuMigrationArgs)
 		if err != nil {
-			os.RemoveAll(sourceInstance.StatePath())
 			return nil, err
 		}
 	}
 
-	revert := revert.New()
-	defer revert.Fail()
-
 	// Create the snapshot.
 	inst, err := instanceCreateInternal(s, args)
 	if err != nil {
@@ -432,8 +465,112 @@ func instanceCreateAsSnapshot(s *state.State, args db.InstanceArgs, sourceInstan
 	return inst, nil
 }
 
+// instanceSnapshotHook runs the shell command held by the snapshots.pre or snapshots.post key of
+// a running instance, killing it after snapshots.hooks.timeout seconds. Its output is appended to
+// snapshot-hooks.log.
+func instanceSnapshotHook(inst instance.Instance, key string) error {
+	command := inst.ExpandedConfig()[key]
+	if command == "" || !inst.IsRunning() || inst.IsFrozen() {
+		return nil
+	}
+
+	// A zero timeout lets the command run for as long as it needs.
+	timeout := 30 * time.Second
+	if inst.ExpandedConfig()["snapshots.hooks.timeout"] != "" {
+		seconds, err := strconv.Atoi(inst.ExpandedConfig()["snapshots.hooks.timeout"])
+		if err != nil {
+			return err
+		}
+
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	logFile, err := os.OpenFile(filepath.Join(inst.LogPath(), "snapshot-hooks.log"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		return err
+	}
+	defer stdin.Close()
+
+	fmt.Fprintf(logFile, "Running %s %q\n", key, command)
+
+	req := api.InstanceExecPost{
+		Command: []string{"/bin/sh", "-c", command},
+		Environment: map[string]string{
+			"PATH": "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+			"HOME": "/root",
+			"USER": "root",
+			"LANG": "C.UTF-8",
+		},
+	}
+
+	cmd, err := inst.Exec(req, stdin, logFile, logFile)
+	if err != nil {
+		return err
+	}
+
+	exitCodeCh := make(chan int, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		exitCode, err := cmd.Wait()
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		exitCodeCh <- exitCode
+	}()
+
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timeoutCh = time.After(timeout)
+	}
+
+	select {
+	case exitCode := <-exitCodeCh:
+		if exitCode != 0 {
+			return fmt.Errorf("Command exited with status %d", exitCode)
+		}
+
+		return nil
+	case err := <-errCh:
+		return err
+	case <-timeoutCh:
+		instanceSnapshotHookKill(inst, cmd)
+		fmt.Fprintf(logFile, "Killed %s after %s\n", key, timeout)
+		return fmt.Errorf("Command timed out after %s", timeout)
+	}
+}
+
+// instanceSnapshotHookKill kills a snapshot hook command which timed out, along with the processes it
+// started, by killing its whole process group. The group is left alone if it's the one of LXD.
+func instanceSnapshotHookKill(inst instance.Instance, cmd instance.Cmd) {
+	if inst.Type() == instancetype.Container {
+		pgid, err := unix.Getpgid(cmd.PID())
+		if err == nil && pgid > 1 && pgid != unix.Getpgrp() {
+			err = unix.Kill(-pgid, unix.SIGKILL)
+			if err == nil {
+				return
+			}
+		}
+	}
+
+	cmd.Signal(unix.SIGKILL)
+}
+
 // instanceCreateInternal creates an instance record and storage volume record in the database.
 func instanceCreateInternal(s *state.State, args db.InstanceArgs) (instance.Instance, error) {
+	return instanceCreateInternalContext(context.Background(), s, args)
+}
+
+// instanceCreateInternalContext is like instanceCreateInternal, but the database records are created
+// within the given context so that they show up in its trace.
+func instanceCreateInternalContext(ctx context.Context, s *state.State, args db.InstanceArgs) (instance.Instance, error) {
 	// Set default values.
 	if args.Project == "" {
 		args.Project = project.Default
@@ -520,7 +657,7 @@ func instanceCreateInternal(s *state.State, args db.InstanceArgs) (instance.Inst
 
 	var dbInst db.Instance
 
-	err = s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+	err = s.Cluster.TransactionContext(ctx, func(tx *db.ClusterTx) error {
 		node, err := tx.NodeName()
 		if err != nil {
 			return err
@@ -696,6 +833,26 @@ func instanceLoadNodeProjectAll(s *state.State, project string, instanceType ins
 	return instance.LoadAllInternal(s, cts)
 }
 
+// Render option obfuscating the secret device options of instances and
+// snapshots returned by the API.
+func instanceRenderHideSecrets(response interface{}) error {
+	switch inst := response.(type) {
+	case *api.Instance:
+		deviceConfig.HideSecrets(inst.Devices)
+		deviceConfig.HideSecrets(inst.ExpandedDevices)
+	case *api.InstanceSnapshot:
+		deviceConfig.HideSecrets(inst.Devices)
+		deviceConfig.HideSecrets(inst.ExpandedDevices)
+	case *api.InstanceFull:
+		instanceRenderHideSecrets(&inst.Instance)
+		for i := range inst.Snapshots {
+			instanceRenderHideSecrets(&inst.Snapshots[i])
+		}
+	}
+
+	return nil
+}
+
 func autoCreateContainerSnapshotsTask(d *Daemon) (task.Func, task.Schedule) {
 	f := func(ctx context.Context) {
 		// Load all local instances
@@ -707,36 +864,48 @@ func autoCreateContainerSnapshotsTask(d *Daemon) (task.Func, task.Schedule) {
 
 		// Figure out which need snapshotting (if any)
 		instances := []instance.Instance{}
+		groups := map[string]map[string]db.InstanceGroup{}
 		for _, c := range allContainers {
-			schedule := c.ExpandedConfig()["snapshots.schedule"]
+			schedules := []string{}
+			if c.ExpandedConfig()["snapshots.schedule"] != "" {
+				schedules = append(schedules, c.ExpandedConfig()["snapshots.schedule"])
+			}
 
-			if schedule == "" {
-				continue
+			// Instances also get snapshotted on the schedules of their groups.
+			names, _ := shared.InstanceGroupsParse(c.LocalConfig()["groups"])
+			if len(names) > 0 && groups[c.Project()] == nil {
+				groups[c.Project()], err = instanceGroupsLoad(d.State(), c.Project())
+				if err != nil {
+					logger.Error("Failed to load instance groups for scheduled snapshots", log.Ctx{"project": c.Project(), "err": err})
+					groups[c.Project()] = map[string]db.InstanceGroup{}
+				}
 			}
 
-			// Extend our schedule to one that is accepted by the used cron parser
-			sched, err := cron.Parse(fmt.Sprintf("* %s", schedule))
-			if err != nil {
+			for _, name := range names {
+				schedule := groups[c.Project()][name].Config["snapshots.schedule"]
+				if schedule != "" {
+					schedules = append(schedules, schedule)
+				}
+			}
+
+			if len(schedules) == 0 {
 				continue
 			}
 
 			// Check if it's time to snapshot
-			now := time.Now()
-
-			// Truncate the time now back to the start of the minute, before passing to
-			// the cron scheduler, as it will add 1s to the scheduled time and we don't
-			// want the next scheduled time to roll over to the next minute and break
-			// the time comparison below.
-			now = now.Truncate(time.Minute)
-
-			// Calculate the next scheduled time based on the snapshots.schedule
-			// pattern and the time now.
-			next := sched.Next(now)
-
-			// Ignore everything that is more precise than minutes.
-			next = next.Truncate(time.Minute)
+			now := time.Now().Truncate(time.Minute)
+			due := false
+			for _, schedule := range schedules {
+				if instanceScheduleIsDue(schedule, now) {
+					due = true
+					break
+				}
+			}
 
-			if !now.Equal(next) {
+			// Snapshots of running instances deferred until their maintenance window are
+			// taken as soon as it opens.
+			deferred := shared.IsTrue(c.LocalConfig()["volatile.snapshots.deferred"])
+			if !due && !deferred {
 				continue
 			}
 
@@ -745,6 +914,26 @@ func autoCreateContainerSnapshotsTask(d *Daemon) (task.Func, task.Schedule) {
 				continue
 			}
 
+			inWindow := shared.InstanceInMaintenanceWindow(c.ExpandedConfig(), now)
+			if c.IsRunning() && !inWindow {
+				if !deferred {
+					logger.Info("Deferring scheduled snapshot until the maintenance window", log.Ctx{"project": c.Project(), "instance": c.Name()})
+					err = c.VolatileSet(map[string]string{"volatile.snapshots.deferred": "true"})
+					if err != nil {
+						logger.Error("Failed to defer scheduled snapshot", log.Ctx{"project": c.Project(), "instance": c.Name(), "err": err})
+					}
+				}
+
+				continue
+			}
+
+			if deferred {
+				err = c.VolatileSet(map[string]string{"volatile.snapshots.deferred": ""})
+				if err != nil {
+					logger.Error("Failed to clear deferred scheduled snapshot", log.Ctx{"project": c.Project(), "instance": c.Name(), "err": err})
+				}
+			}
+
 			instances = append(instances, c)
 		}
 
@@ -822,9 +1011,19 @@ func autoCreateContainerSnapshots(ctx context.Context, d *Daemon, instances []in
 				ExpiryDate:   expiry,
 			}
 
-			_, err = instanceCreateAsSnapshot(d.State(), args, c, nil)
+			snap, err := instanceCreateAsSnapshot(d.State(), args, c, nil)
 			if err != nil {
 				logger.Error("Error creating snapshots", log.Ctx{"err": err, "container": c})
+				ch <- nil
+				return
+			}
+
+			// Only the snapshots taken on schedule count against snapshots.retention.
+			err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+				return tx.InstanceSnapshotScheduledSet(snap.ID())
+			})
+			if err != nil {
+				logger.Error("Error flagging scheduled snapshot", log.Ctx{"err": err, "container": c})
 			}
 
 			ch <- nil
@@ -857,7 +1056,34 @@ func pruneExpiredContainerSnapshotsTask(d *Daemon) (task.Func, task.Schedule) {
 				continue
 			}
 
+			// Only keep the most recent scheduled snapshots, if limited.
+			pruned := map[string]bool{}
+			retention, err := strconv.Atoi(c.ExpandedConfig()["snapshots.retention"])
+			if err == nil && retention > 0 {
+				var scheduled []string
+				err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+					var err error
+					scheduled, err = tx.InstanceSnapshotsScheduled(c.Project(), c.Name())
+					return err
+				})
+				if err != nil {
+					logger.Error("Failed to list scheduled instance snapshots", log.Ctx{"err": err, "instance": c.Name(), "project": c.Project()})
+					continue
+				}
+
+				for len(scheduled) > retention {
+					pruned[scheduled[0]] = true
+					scheduled = scheduled[1:]
+				}
+			}
+
 			for _, snapshot := range snapshots {
+				_, snapName, _ := shared.InstanceGetParentAndSnapshotName(snapshot.Name())
+				if pruned[snapName] {
+					expiredSnapshots = append(expiredSnapshots, snapshot)
+					continue
+				}
+
 				// Since zero time causes some issues due to timezones, we check the
 				// unix timestamp instead of IsZero().
 				if snapshot.ExpiryDate().Unix() <= 0 {
@@ -913,7 +1139,12 @@ func pruneExpiredContainerSnapshotsTask(d *Daemon) (task.Func, task.Schedule) {
 func pruneExpiredContainerSnapshots(ctx context.Context, d *Daemon, snapshots []instance.Instance) error {
 	// Find snapshots to delete
 	for _, snapshot := range snapshots {
-		err := snapshot.Delete()
+		err := snapshotMountRemove(d.State(), snapshot)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to unmount expired instance snapshot '%s' in project '%s'", snapshot.Name(), snapshot.Project())
+		}
+
+		err = snapshot.Delete()
 		if err != nil {
 			return errors.Wrapf(err, "Failed to delete expired instance snapshot '%s' in project '%s'", snapshot.Name(), snapshot.Project())
 		}
@@ -969,3 +1200,151 @@ func containerDetermineNextSnapshotName(d *Daemon, c instance.Instance, defaultP
 
 	return pattern, nil
 }
+
+// instanceSnapshotBeforeChange snapshots the instance before a destructive
+// action if snapshots.before_changes is enabled. The snapshot is named after
+// the action and its description references the operation performing it.
+func instanceSnapshotBeforeChange(s *state.State, inst instance.Instance, action string, op *operations.Operation) (instance.Instance, error) {
+	if !shared.IsTrue(inst.ExpandedConfig()["snapshots.before_changes"]) {
+		return nil, nil
+	}
+
+	pattern := fmt.Sprintf("pre-%s-%%d", action)
+	i := s.Cluster.ContainerNextSnapshot(inst.Project(), inst.Name(), pattern)
+	name := strings.Replace(pattern, "%d", strconv.Itoa(i), 1)
+
+	expiry, err := shared.GetSnapshotExpiry(time.Now(), inst.ExpandedConfig()["snapshots.expiry"])
+	if err != nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("Automatic snapshot before %s", action)
+	if op != nil {
+		description = fmt.Sprintf("%s (operation %s)", description, op.ID())
+	}
+
+	args := db.InstanceArgs{
+		Project:      inst.Project(),
+		Architecture: inst.Architecture(),
+		Config:       inst.LocalConfig(),
+		Description:  description,
+		Type:         inst.Type(),
+		Snapshot:     true,
+		Devices:      inst.LocalDevices(),
+		Ephemeral:    inst.IsEphemeral(),
+		Name:         inst.Name() + shared.SnapshotDelimiter + name,
+		Profiles:     inst.Profiles(),
+		ExpiryDate:   expiry,
+	}
+
+	snap, err := instanceCreateAsSnapshot(s, args, inst, op)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to snapshot instance before %s", action)
+	}
+
+	return snap, nil
+}
+
+// instanceUpdateWithSnapshot applies a user requested update to the instance, taking a snapshot of
+// it first if the change is major and snapshots.before_changes is set. The snapshot is only taken
+// once the new configuration is known to be valid, and deleted if the update fails anyway.
+func instanceUpdateWithSnapshot(s *state.State, inst instance.Instance, args db.InstanceArgs, op *operations.Operation) error {
+	if instanceUpdateIsMajor(inst, args) {
+		err := instance.ValidConfig(s.OS, args.Config, false, false)
+		if err != nil {
+			return errors.Wrap(err, "Invalid config")
+		}
+
+		err = instance.ValidDevices(s, s.Cluster, inst.Type(), args.Devices, false)
+		if err != nil {
+			return errors.Wrap(err, "Invalid devices")
+		}
+
+		snap, err := instanceSnapshotBeforeChange(s, inst, "update", op)
+		if err != nil {
+			return err
+		}
+
+		if snap != nil {
+			err = inst.Update(args, true)
+			if err != nil {
+				snap.Delete()
+				return err
+			}
+
+			return nil
+		}
+	}
+
+	return inst.Update(args, true)
+}
+
+// instanceUpdateIsMajor returns whether applying args to the instance changes
+// its profiles, devices or any configuration key other than user, environment
+// and volatile ones.
+func instanceUpdateIsMajor(inst instance.Instance, args db.InstanceArgs) bool {
+	if strings.Join(args.Profiles, "\n") != strings.Join(inst.Profiles(), "\n") {
+		return true
+	}
+
+	devices := inst.LocalDevices()
+	if len(args.Devices) != len(devices) {
+		return true
+	}
+
+	for name, dev := range args.Devices {
+		if !devices.Contains(name, dev) {
+			return true
+		}
+	}
+
+	minor := func(key string) bool {
+		return strings.HasPrefix(key, "user.") || strings.HasPrefix(key, "environment.") || strings.HasPrefix(key, "volatile.")
+	}
+
+	config := inst.LocalConfig()
+	for key, value := range args.Config {
+		if !minor(key) && config[key] != value {
+			return true
+		}
+	}
+
+	for key := range config {
+		_, ok := args.Config[key]
+		if !minor(key) && !ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// instanceOperationLockHold takes the operation lock of an instance, keeping other state changes
+// such as starting it from happening, for an action which may last longer than the lock's timeout.
+// The returned function releases it.
+func instanceOperationLockHold(inst instance.Instance, action string) (func(), error) {
+	opLock, err := operationlock.Create(inst.ID(), action, true, false)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				opLock.Reset()
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		opLock.Done(nil)
+	}, nil
+}
@@ -1,10 +1,14 @@
 package drivers
 
 import (
+	"strings"
+
 	"github.com/lxc/lxd/lxd/db"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/state"
+	storageDrivers "github.com/lxc/lxd/lxd/storage/drivers"
 	"github.com/lxc/lxd/shared/api"
 )
 
@@ -68,7 +72,46 @@ func (c *common) expandDevices(profiles []api.Profile) error {
 		}
 	}
 
-	c.expandedDevices = db.ProfilesExpandDevices(c.localDevices, profiles)
+	c.expandedDevices = db.ProfilesExpandDevices(c.localDevices, profiles, c.localConfig)
 
 	return nil
 }
+
+// deviceTemplatesRecord records the numbers picked for the profile device templates of the instance, so that
+// the devices keep their name when other devices are added later on.
+func deviceTemplatesRecord(s *state.State, inst instance.Instance) error {
+	profiles, err := s.Cluster.ProfilesGet(inst.Project(), inst.Profiles())
+	if err != nil {
+		return err
+	}
+
+	keys := db.ProfilesDeviceTemplateKeys(inst.LocalDevices(), profiles, inst.LocalConfig())
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return inst.VolatileSet(keys)
+}
+
+// interpolationLookup returns the function resolving the variables which can be referenced from the
+// config of the given instance, other than its own config keys.
+func interpolationLookup(projectName string, instanceName string) func(name string) (string, bool) {
+	return func(name string) (string, bool) {
+		switch name {
+		case "instance.name":
+			return instanceName, true
+		case "instance.project":
+			return projectName, true
+		}
+
+		// ${pools.<name>.path} is the mount path of the storage pool.
+		if strings.HasPrefix(name, "pools.") && strings.HasSuffix(name, ".path") {
+			poolName := strings.TrimSuffix(strings.TrimPrefix(name, "pools."), ".path")
+			if poolName != "" {
+				return storageDrivers.GetPoolMountPath(poolName), true
+			}
+		}
+
+		return "", false
+	}
+}
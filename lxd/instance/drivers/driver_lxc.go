@@ -38,7 +38,9 @@ import (
 	"github.com/lxc/lxd/lxd/instance/operationlock"
 	"github.com/lxc/lxd/lxd/maas"
 	"github.com/lxc/lxd/lxd/network"
+	"github.com/lxc/lxd/lxd/node"
 	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/procevents"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/revert"
 	"github.com/lxc/lxd/lxd/seccomp"
@@ -46,7 +48,10 @@ import (
 	storagePools "github.com/lxc/lxd/lxd/storage"
 	storageDrivers "github.com/lxc/lxd/lxd/storage/drivers"
 	"github.com/lxc/lxd/lxd/template"
+	"github.com/lxc/lxd/lxd/tools"
+	"github.com/lxc/lxd/lxd/tracing"
 	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/lxd/warnings"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/idmap"
@@ -56,6 +61,7 @@ import (
 	"github.com/lxc/lxd/shared/netutils"
 	"github.com/lxc/lxd/shared/osarch"
 	"github.com/lxc/lxd/shared/units"
+	"github.com/lxc/lxd/shared/version"
 )
 
 // Helper functions
@@ -356,15 +362,24 @@ func lxcLoad(s *state.State, args db.InstanceArgs, profiles []api.Profile) (inst
 	// Setup finalizer
 	runtime.SetFinalizer(c, lxcUnload)
 
-	// Expand config and devices
+	// Expand config and devices. Invalid config references are only logged
+	// so that the container can still be loaded and its config fixed.
 	err := c.(*lxc).expandConfig(profiles)
 	if err != nil {
-		return nil, err
+		if errors.Cause(err) != instance.ErrInvalidReference {
+			return nil, err
+		}
+
+		logger.Warn("Failed to resolve config references", log.Ctx{"project": args.Project, "instance": args.Name, "err": err})
 	}
 
 	err = c.(*lxc).expandDevices(profiles)
 	if err != nil {
-		return nil, err
+		if errors.Cause(err) != instance.ErrInvalidReference {
+			return nil, err
+		}
+
+		logger.Warn("Failed to resolve device references", log.Ctx{"project": args.Project, "instance": args.Name, "err": err})
 	}
 
 	return c, nil
@@ -760,29 +775,69 @@ func (c *lxc) initLXC(config bool) error {
 		return nil
 	}
 
+	// Capabilities, either keeping only the configured ones or dropping some on top of the defaults
+	mandatoryDrop := []string{}
 	if c.IsPrivileged() {
 		// Base config
-		toDrop := "sys_time sys_module sys_rawio"
+		mandatoryDrop = append(mandatoryDrop, "sys_time", "sys_module", "sys_rawio")
 		if !c.state.OS.AppArmorStacking || c.state.OS.AppArmorStacked {
-			toDrop = toDrop + " mac_admin mac_override"
+			mandatoryDrop = append(mandatoryDrop, "mac_admin", "mac_override")
+		}
+	}
+
+	if c.expandedConfig["security.capabilities.keep"] != "" {
+		// The capabilities always dropped can't be kept.
+		toKeep := []string{}
+		for _, capability := range shared.SplitCapabilities(c.expandedConfig["security.capabilities.keep"]) {
+			if !shared.StringInSlice(capability, mandatoryDrop) {
+				toKeep = append(toKeep, capability)
+			}
 		}
 
-		err = lxcSetConfigItem(cc, "lxc.cap.drop", toDrop)
+		if len(toKeep) == 0 {
+			toKeep = append(toKeep, "none")
+		}
+
+		err = lxcSetConfigItem(cc, "lxc.cap.keep", strings.Join(toKeep, " "))
 		if err != nil {
 			return err
 		}
+	} else {
+		toDrop := append([]string{}, mandatoryDrop...)
+		for _, capability := range shared.SplitCapabilities(c.expandedConfig["security.capabilities.drop"]) {
+			if !shared.StringInSlice(capability, toDrop) {
+				toDrop = append(toDrop, capability)
+			}
+		}
+
+		if len(toDrop) > 0 {
+			err = lxcSetConfigItem(cc, "lxc.cap.drop", strings.Join(toDrop, " "))
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	// Set an appropriate /proc, /sys/ and /sys/fs/cgroup
-	mounts := []string{}
+	procMode := "rw"
+	sysMode := "rw"
 	if c.IsPrivileged() && !c.state.OS.RunningInUserNS {
-		mounts = append(mounts, "proc:mixed")
-		mounts = append(mounts, "sys:mixed")
-	} else {
-		mounts = append(mounts, "proc:rw")
-		mounts = append(mounts, "sys:rw")
+		procMode = "mixed"
+		sysMode = "mixed"
 	}
 
+	if c.expandedConfig["security.proc"] != "" {
+		procMode = c.expandedConfig["security.proc"]
+	}
+
+	if c.expandedConfig["security.sys"] != "" {
+		sysMode = c.expandedConfig["security.sys"]
+	}
+
+	mounts := []string{}
+	mounts = append(mounts, fmt.Sprintf("proc:%s", procMode))
+	mounts = append(mounts, fmt.Sprintf("sys:%s", sysMode))
+
 	cgInfo := cgroup.GetInfo()
 	if cgInfo.Namespacing {
 		if cgInfo.Layout == cgroup.CgroupsUnified {
@@ -799,19 +854,43 @@ func (c *lxc) initLXC(config bool) error {
 		return err
 	}
 
-	err = lxcSetConfigItem(cc, "lxc.autodev", "1")
+	// Setup /dev, either as a tmpfs populated by LXC or as provided by the image.
+	devMode := c.expandedConfig["linux.dev"]
+	if devMode == "none" {
+		err = lxcSetConfigItem(cc, "lxc.autodev", "0")
+	} else {
+		err = lxcSetConfigItem(cc, "lxc.autodev", "1")
+	}
 	if err != nil {
 		return err
 	}
 
+	if devMode != "none" && c.expandedConfig["linux.dev.size"] != "" {
+		size, err := units.ParseByteSizeString(c.expandedConfig["linux.dev.size"])
+		if err != nil {
+			return err
+		}
+
+		// LXC takes the size in MiB.
+		err = lxcSetConfigItem(cc, "lxc.autodev.tmpfs.size", fmt.Sprintf("%d", size/1024/1024))
+		if err != nil {
+			return errors.Wrapf(err, "Setting the size of /dev requires liblxc >= 4.0")
+		}
+	}
+
 	err = lxcSetConfigItem(cc, "lxc.pty.max", "1024")
 	if err != nil {
 		return err
 	}
 
-	bindMounts := []string{
-		"/dev/fuse",
-		"/dev/net/tun",
+	bindMounts := []string{}
+
+	// The minimal /dev only holds the basic device nodes.
+	if devMode != "minimal" {
+		bindMounts = append(bindMounts, "/dev/fuse", "/dev/net/tun")
+	}
+
+	bindMounts = append(bindMounts,
 		"/proc/sys/fs/binfmt_misc",
 		"/sys/firmware/efi/efivars",
 		"/sys/fs/fuse/connections",
@@ -820,7 +899,7 @@ func (c *lxc) initLXC(config bool) error {
 		"/sys/kernel/debug",
 		"/sys/kernel/security",
 		"/sys/kernel/tracing",
-	}
+	)
 
 	if c.IsPrivileged() && !c.state.OS.RunningInUserNS {
 		err = lxcSetConfigItem(cc, "lxc.mount.entry", "mqueue dev/mqueue mqueue rw,relatime,create=dir,optional 0 0")
@@ -870,19 +949,24 @@ func (c *lxc) initLXC(config bool) error {
 		}
 
 		devices := []string{
-			"b *:* m",      // Allow mknod of block devices
-			"c *:* m",      // Allow mknod of char devices
-			"c 136:* rwm",  // /dev/pts devices
-			"c 1:3 rwm",    // /dev/null
-			"c 1:5 rwm",    // /dev/zero
-			"c 1:7 rwm",    // /dev/full
-			"c 1:8 rwm",    // /dev/random
-			"c 1:9 rwm",    // /dev/urandom
-			"c 5:0 rwm",    // /dev/tty
-			"c 5:1 rwm",    // /dev/console
-			"c 5:2 rwm",    // /dev/ptmx
-			"c 10:229 rwm", // /dev/fuse
-			"c 10:200 rwm", // /dev/net/tun
+			"b *:* m",     // Allow mknod of block devices
+			"c *:* m",     // Allow mknod of char devices
+			"c 136:* rwm", // /dev/pts devices
+			"c 1:3 rwm",   // /dev/null
+			"c 1:5 rwm",   // /dev/zero
+			"c 1:7 rwm",   // /dev/full
+			"c 1:8 rwm",   // /dev/random
+			"c 1:9 rwm",   // /dev/urandom
+			"c 5:0 rwm",   // /dev/tty
+			"c 5:1 rwm",   // /dev/console
+			"c 5:2 rwm",   // /dev/ptmx
+		}
+
+		if devMode != "minimal" {
+			devices = append(devices,
+				"c 10:229 rwm", // /dev/fuse
+				"c 10:200 rwm", // /dev/net/tun
+			)
 		}
 
 		for _, dev := range devices {
@@ -1040,6 +1124,39 @@ func (c *lxc) initLXC(config bool) error {
 		}
 	}
 
+	// Setup the timezone and locale of the host, unless overridden through the environment
+	_, ok := c.expandedConfig["environment.TZ"]
+	switch c.expandedConfig["host.timezone"] {
+	case "mount":
+		err = lxcSetConfigItem(cc, "lxc.mount.entry", fmt.Sprintf("%s etc/localtime none bind,ro,optional 0 0", c.hostTimezonePath()))
+		if err != nil {
+			return err
+		}
+	case "env":
+		timezone, err := util.HostTimezone()
+		if err != nil {
+			logger.Warn("Failed to propagate the host timezone", log.Ctx{"project": c.project, "instance": c.name, "err": err})
+		} else if !ok {
+			err = lxcSetConfigItem(cc, "lxc.environment", fmt.Sprintf("TZ=%s", timezone))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, ok = c.expandedConfig["environment.LANG"]
+	if shared.IsTrue(c.expandedConfig["host.locale"]) && !ok {
+		locale, err := util.HostLocale()
+		if err != nil {
+			logger.Warn("Failed to propagate the host locale", log.Ctx{"project": c.project, "instance": c.name, "err": err})
+		} else {
+			err = lxcSetConfigItem(cc, "lxc.environment", fmt.Sprintf("LANG=%s", locale))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	// Setup NVIDIA runtime
 	if shared.IsTrue(c.expandedConfig["nvidia.runtime"]) {
 		hookDir := os.Getenv("LXD_LXC_HOOK")
@@ -1868,6 +1985,13 @@ func (c *lxc) expandConfig(profiles []api.Profile) error {
 
 	c.expandedConfig = db.ProfilesExpandConfig(c.localConfig, profiles)
 
+	config, err := instance.InterpolateConfig(c.expandedConfig, interpolationLookup(c.project, c.name))
+	if err != nil {
+		return err
+	}
+
+	c.expandedConfig = config
+
 	return nil
 }
 
@@ -1880,7 +2004,14 @@ func (c *lxc) expandDevices(profiles []api.Profile) error {
 		}
 	}
 
-	c.expandedDevices = db.ProfilesExpandDevices(c.localDevices, profiles)
+	c.expandedDevices = db.ProfilesExpandDevices(c.localDevices, profiles, c.localConfig)
+
+	devices, err := instance.InterpolateDevices(c.expandedDevices, c.expandedConfig, interpolationLookup(c.project, c.name))
+	if err != nil {
+		return err
+	}
+
+	c.expandedDevices = devices
 
 	return nil
 }
@@ -1913,6 +2044,21 @@ func (c *lxc) startCommon() (string, []func() error, error) {
 		}
 	}
 
+	// Start from a pristine copy of the base image if requested.
+	if shared.IsTrue(c.expandedConfig["boot.reset_rootfs"]) {
+		if instance.SnapshotBeforeChange != nil {
+			err = instance.SnapshotBeforeChange(c.state, c, "reset")
+			if err != nil {
+				return "", postStartHooks, errors.Wrap(err, "Reset rootfs")
+			}
+		}
+
+		err = c.resetRootfs()
+		if err != nil {
+			return "", postStartHooks, errors.Wrap(err, "Reset rootfs")
+		}
+	}
+
 	/* Deal with idmap changes */
 	nextIdmap, err := c.NextIdmap()
 	if err != nil {
@@ -1942,6 +2088,8 @@ func (c *lxc) startCommon() (string, []func() error, error) {
 			return "", postStartHooks, errors.Wrap(err, "Storage type")
 		}
 
+		_, span := tracing.StartChild(c.op.Context(), "idmap shift")
+
 		if diskIdmap != nil {
 			if storageType == "zfs" {
 				err = diskIdmap.UnshiftRootfs(c.RootfsPath(), storageDrivers.ShiftZFSSkipper)
@@ -1951,6 +2099,7 @@ func (c *lxc) startCommon() (string, []func() error, error) {
 				err = diskIdmap.UnshiftRootfs(c.RootfsPath(), nil)
 			}
 			if err != nil {
+				span.End(err)
 				if ourStart {
 					c.unmount()
 				}
@@ -1967,6 +2116,7 @@ func (c *lxc) startCommon() (string, []func() error, error) {
 				err = nextIdmap.ShiftRootfs(c.RootfsPath(), nil)
 			}
 			if err != nil {
+				span.End(err)
 				if ourStart {
 					c.unmount()
 				}
@@ -1974,6 +2124,8 @@ func (c *lxc) startCommon() (string, []func() error, error) {
 			}
 		}
 
+		span.End(nil)
+
 		jsonDiskIdmap := "[]"
 		if nextIdmap != nil && !c.state.OS.Shiftfs {
 			idmapBytes, err := json.Marshal(nextIdmap.Idmap)
@@ -2008,6 +2160,20 @@ func (c *lxc) startCommon() (string, []func() error, error) {
 		}
 	}
 
+	// Start over from the configured limits rather than the autoscaled ones.
+	if c.localConfig["volatile.autoscale.cpu"] != "" || c.localConfig["volatile.autoscale.memory"] != "" {
+		err = c.VolatileSet(map[string]string{"volatile.autoscale.cpu": "", "volatile.autoscale.memory": ""})
+		if err != nil {
+			return "", postStartHooks, errors.Wrapf(err, "Clear volatile.autoscale config keys on container %q (id %d)", c.name, c.id)
+		}
+	}
+
+	// Keep the names picked for the profile device templates.
+	err = deviceTemplatesRecord(c.state, c)
+	if err != nil {
+		return "", postStartHooks, errors.Wrapf(err, "Record device templates of container %q (id %d)", c.name, c.id)
+	}
+
 	// Generate the Seccomp profile
 	if err := seccomp.CreateProfile(c.state, c); err != nil {
 		return "", postStartHooks, err
@@ -2033,6 +2199,18 @@ func (c *lxc) startCommon() (string, []func() error, error) {
 		return "", postStartHooks, err
 	}
 
+	// Join the namespaces shared by other containers.
+	err = c.setupSharedNamespaces()
+	if err != nil {
+		return "", postStartHooks, errors.Wrap(err, "Shared namespaces")
+	}
+
+	// Hide the requested paths, now that the root filesystem is mounted.
+	err = c.setupMaskedPaths()
+	if err != nil {
+		return "", postStartHooks, errors.Wrap(err, "Masked paths")
+	}
+
 	// Create the devices
 	nicID := -1
 
@@ -2072,8 +2250,13 @@ func (c *lxc) startCommon() (string, []func() error, error) {
 				return "", postStartHooks, errors.Wrapf(err, "Failed to setup device rootfs '%s'", dev.Name)
 			}
 
-			if len(runConf.RootFS.Opts) > 0 {
-				err = lxcSetConfigItem(c.c, "lxc.rootfs.options", strings.Join(runConf.RootFS.Opts, ","))
+			rootfsOpts := runConf.RootFS.Opts
+			if shared.IsTrue(c.expandedConfig["security.readonly_rootfs"]) {
+				rootfsOpts = append(rootfsOpts, "ro")
+			}
+
+			if len(rootfsOpts) > 0 {
+				err = lxcSetConfigItem(c.c, "lxc.rootfs.options", strings.Join(rootfsOpts, ","))
 				if err != nil {
 					return "", postStartHooks, errors.Wrapf(err, "Failed to setup device rootfs '%s'", dev.Name)
 				}
@@ -2170,13 +2353,14 @@ func (c *lxc) startCommon() (string, []func() error, error) {
 		}
 	}
 
-	// Rotate the log file
-	logfile := c.LogFilePath()
-	if shared.PathExists(logfile) {
-		os.Remove(logfile + ".old")
-		err := os.Rename(logfile, logfile+".old")
-		if err != nil {
-			return "", postStartHooks, err
+	// Rotate the log files, keeping the console output of the previous boot around.
+	for _, logfile := range []string{c.LogFilePath(), c.ConsoleBufferLogPath()} {
+		if shared.PathExists(logfile) {
+			os.Remove(logfile + ".old")
+			err := os.Rename(logfile, logfile+".old")
+			if err != nil {
+				return "", postStartHooks, err
+			}
 		}
 	}
 
@@ -2186,6 +2370,33 @@ func (c *lxc) startCommon() (string, []func() error, error) {
 		return "", postStartHooks, err
 	}
 
+	// Disk priority, only on the unified hierarchy as the disk device handles the legacy one. This
+	// needs the storage mounted to find out the I/O scheduler in use.
+	diskPriority := c.expandedConfig["limits.disk.priority"]
+	version, ok := c.state.OS.CGInfo.SupportsVersion(cgroup.BlkioWeight)
+	if diskPriority != "" && ok && version == cgroup.V2 {
+		cg, err := c.cgroup(c.c)
+		if err != nil {
+			return "", postStartHooks, err
+		}
+
+		priorityInt, err := strconv.Atoi(diskPriority)
+		if err != nil {
+			return "", postStartHooks, err
+		}
+
+		// Minimum valid value is 10
+		priority := priorityInt * 100
+		if priority == 0 {
+			priority = 10
+		}
+
+		err = cg.SetBlkioWeight(fmt.Sprintf("%d", priority), c.diskUsesBFQ())
+		if err != nil {
+			return "", postStartHooks, err
+		}
+	}
+
 	// Generate the LXC config
 	configPath := filepath.Join(c.LogPath(), "lxc.conf")
 	err = c.c.SaveConfigFile(configPath)
@@ -2242,9 +2453,157 @@ func (c *lxc) startCommon() (string, []func() error, error) {
 	// Unmount any previously mounted shiftfs
 	unix.Unmount(c.RootfsPath(), unix.MNT_DETACH)
 
+	// Mount the scratch layer for ephemeral root filesystems.
+	if shared.IsTrue(c.expandedConfig["security.ephemeral_rootfs"]) {
+		err = c.mountRootfsOverlay()
+		if err != nil {
+			return "", postStartHooks, errors.Wrap(err, "Ephemeral rootfs")
+		}
+	}
+
 	return configPath, postStartHooks, nil
 }
 
+// diskUsesBFQ returns whether the block device backing the container's root filesystem uses the
+// BFQ I/O scheduler, which only honours io.bfq.weight on the unified hierarchy.
+func (c *lxc) diskUsesBFQ() bool {
+	scheduler, err := util.BlockDeviceScheduler(c.RootfsPath())
+	if err != nil {
+		logger.Debug("Failed to get the I/O scheduler of the container's storage", log.Ctx{"container": c.Name(), "err": err})
+		return false
+	}
+
+	return scheduler == "bfq"
+}
+
+// resetRootfs replaces the container's root volume with a fresh copy of its base image.
+func (c *lxc) resetRootfs() error {
+	fingerprint := c.localConfig["volatile.base_image"]
+	if fingerprint == "" {
+		return fmt.Errorf("The container wasn't created from an image")
+	}
+
+	pool, err := c.getStoragePool()
+	if err != nil {
+		return err
+	}
+
+	// The volume can't be replaced while it's mounted.
+	_, err = c.unmount()
+	if err != nil {
+		return err
+	}
+
+	c.updateProgress("Resetting container filesystem")
+	defer c.updateProgress("")
+
+	err = pool.ResetInstanceFromImage(c, fingerprint, nil)
+	if err != nil {
+		return err
+	}
+
+	// The new volume isn't shifted and hasn't been provisioned yet.
+	volatile := map[string]string{"volatile.last_state.idmap": "[]"}
+	for k := range c.localConfig {
+		if strings.HasPrefix(k, "volatile.provision.") {
+			volatile[k] = ""
+		}
+	}
+
+	return c.VolatileSet(volatile)
+}
+
+// rootfsOverlayPath returns the path of the tmpfs holding the writable layer of an ephemeral rootfs.
+func (c *lxc) rootfsOverlayPath() string {
+	return filepath.Join(c.DevicesPath(), "rootfs.overlay")
+}
+
+// mountRootfsOverlay mounts an overlay on top of the container's rootfs so that all the writes go
+// to a tmpfs, which is discarded when the container stops.
+func (c *lxc) mountRootfsOverlay() error {
+	scratchPath := c.rootfsOverlayPath()
+
+	// Clear any scratch layer left behind by a failed start.
+	if shared.IsMountPoint(scratchPath) {
+		unix.Unmount(scratchPath, unix.MNT_DETACH)
+	}
+
+	err := os.MkdirAll(scratchPath, 0700)
+	if err != nil {
+		return err
+	}
+
+	err = unix.Mount("tmpfs", scratchPath, "tmpfs", 0, "mode=0700")
+	if err != nil {
+		return errors.Wrap(err, "Failed to mount the scratch tmpfs")
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	revert.Add(func() { unix.Unmount(scratchPath, unix.MNT_DETACH) })
+
+	// The upper directory becomes the root of the overlay, so it must match the real rootfs.
+	var rootStat unix.Stat_t
+	err = unix.Stat(c.RootfsPath(), &rootStat)
+	if err != nil {
+		return err
+	}
+
+	upperPath := filepath.Join(scratchPath, "upper")
+	workPath := filepath.Join(scratchPath, "work")
+
+	for _, path := range []string{upperPath, workPath} {
+		err = os.Mkdir(path, 0700)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.Chown(upperPath, int(rootStat.Uid), int(rootStat.Gid))
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(upperPath, os.FileMode(rootStat.Mode&0777))
+	if err != nil {
+		return err
+	}
+
+	options := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", c.RootfsPath(), upperPath, workPath)
+	err = unix.Mount("overlay", c.RootfsPath(), "overlay", 0, options)
+	if err != nil {
+		return errors.Wrap(err, "Failed to mount the rootfs overlay")
+	}
+
+	revert.Success()
+	return nil
+}
+
+// unmountRootfsOverlay removes the overlay mounted on top of the container's rootfs along with
+// its scratch layer.
+func (c *lxc) unmountRootfsOverlay() error {
+	scratchPath := c.rootfsOverlayPath()
+	if !shared.PathExists(scratchPath) {
+		return nil
+	}
+
+	// The overlay is only ever mounted while its scratch layer is.
+	if shared.IsMountPoint(scratchPath) {
+		err := unix.Unmount(c.RootfsPath(), unix.MNT_DETACH)
+		if err != nil && err != unix.EINVAL {
+			return errors.Wrap(err, "Failed to unmount the rootfs overlay")
+		}
+
+		err = unix.Unmount(scratchPath, unix.MNT_DETACH)
+		if err != nil {
+			return errors.Wrap(err, "Failed to unmount the scratch tmpfs")
+		}
+	}
+
+	return os.RemoveAll(scratchPath)
+}
+
 // detachInterfaceRename enters the container's network namespace and moves the named interface
 // in ifName back to the network namespace of the running process as the name specified in hostName.
 func (c *lxc) detachInterfaceRename(netns string, ifName string, hostName string) error {
@@ -2290,6 +2649,12 @@ func (c *lxc) Start(stateful bool) error {
 		return errors.Wrap(err, "Common start logic")
 	}
 
+	revert := revert.New()
+	defer revert.Fail()
+
+	// Drop the ephemeral rootfs overlay if the container doesn't end up running.
+	revert.Add(func() { c.unmountRootfsOverlay() })
+
 	// Ensure that the container storage volume is mounted.
 	_, err = c.mount()
 	if err != nil {
@@ -2328,6 +2693,8 @@ func (c *lxc) Start(stateful bool) error {
 			return errors.Wrap(err, "Migrate")
 		}
 
+		revert.Success()
+
 		os.RemoveAll(c.StatePath())
 		c.stateful = false
 
@@ -2405,6 +2772,23 @@ func (c *lxc) Start(stateful bool) error {
 		return err
 	}
 
+	revert.Success()
+
+	// Put the container in its own core scheduling domain.
+	if shared.IsTrue(c.expandedConfig["security.core_scheduling"]) {
+		pids, err := processTree(c.InitPID())
+		if err == nil {
+			err = c.coreScheduling(pids, false)
+		}
+
+		if err != nil {
+			// Attempt to stop container.
+			op.Done(err)
+			c.Stop(false)
+			return errors.Wrap(err, "Core scheduling")
+		}
+	}
+
 	// Run any post start hooks.
 	err = c.runHooks(postStartHooks)
 	if err != nil {
@@ -2418,36 +2802,158 @@ func (c *lxc) Start(stateful bool) error {
 	c.state.Events.SendLifecycle(c.project, "container-started",
 		fmt.Sprintf("/1.0/containers/%s", c.name), nil)
 
+	// Record how init exits.
+	c.WatchInit()
+
+	// Run the provisioning steps in the background.
+	if c.expandedConfig["boot.provision"] != "" {
+		go c.provision()
+	}
+
 	return nil
 }
 
-// OnHook is the top-level hook handler.
-func (c *lxc) OnHook(hookName string, args map[string]string) error {
-	switch hookName {
-	case instance.HookStart:
-		return c.onStart(args)
-	case instance.HookStopNS:
-		return c.onStopNS(args)
-	case instance.HookStop:
-		return c.onStop(args)
-	default:
-		return instance.ErrNotImplemented
+// WatchInit records how the init process of the running container exits, so that crashes can be
+// told apart from requested shutdowns.
+func (c *lxc) WatchInit() {
+	pid := c.InitPID()
+	if pid <= 0 {
+		return
 	}
+
+	procevents.Watch(pid, func(status unix.WaitStatus) {
+		c.recordInitExit(pid, status)
+	})
 }
 
-// onStart implements the start hook.
-func (c *lxc) onStart(_ map[string]string) error {
-	// Make sure we can't call go-lxc functions by mistake
-	c.fromHook = true
+func (c *lxc) recordInitExit(pid int, status unix.WaitStatus) {
+	lastExit := api.InstanceStateLastExit{Time: time.Now().UTC()}
+	if status.Signaled() {
+		lastExit.Signal = int(status.Signal())
+	} else {
+		lastExit.Code = status.ExitStatus()
+	}
 
-	// Start the storage for this container
-	ourStart, err := c.mount()
+	// Init gets SIGINT or SIGHUP when the container powers off or reboots, any other signal or a
+	// failure exit code which wasn't requested through LXD is a crash.
+	clean := lastExit.Code == 0 && (lastExit.Signal == 0 || lastExit.Signal == int(unix.SIGINT) || lastExit.Signal == int(unix.SIGHUP))
+	lastExit.Crashed = !clean && operationlock.Get(c.id) == nil
+
+	if status.CoreDump() {
+		coreDump := fmt.Sprintf("core.%d", pid)
+		if shared.PathExists(filepath.Join(c.LogPath(), coreDump)) {
+			lastExit.CoreDump = coreDump
+		}
+	}
+
+	data, err := json.Marshal(lastExit)
 	if err != nil {
-		return err
+		return
 	}
 
-	// Load the container AppArmor profile
-	err = apparmor.LoadProfile(c.state, c)
+	err = c.VolatileSet(map[string]string{"volatile.last_exit": string(data)})
+	if err != nil {
+		logger.Warn("Failed to record container exit", log.Ctx{"project": c.project, "instance": c.name, "err": err})
+	}
+
+	if lastExit.Crashed {
+		logger.Warn("Container init exited unexpectedly", log.Ctx{"project": c.project, "instance": c.name, "code": lastExit.Code, "signal": lastExit.Signal})
+		c.state.Events.SendLifecycle(c.project, "container-crashed",
+			fmt.Sprintf("/1.0/containers/%s", c.name), nil)
+	}
+}
+
+// coreScheduling puts the given processes in the core scheduling domain of the container, so that they
+// never share a physical core (SMT siblings) with processes of other containers or of the host. The domain
+// is created when the container starts, with a single cookie shared by all its processes. Processes added
+// later on join it by taking the cookie of the container's init process.
+func (c *lxc) coreScheduling(pids []int, join bool) error {
+	args := []string{"forkcoresched"}
+	if join {
+		args = append(args, fmt.Sprintf("--share-from=%d", c.InitPID()))
+	}
+
+	for _, pid := range pids {
+		args = append(args, fmt.Sprintf("%d", pid))
+	}
+
+	_, err := shared.RunCommand(c.state.OS.ExecPath, args...)
+	return err
+}
+
+// processTree returns the given process and all its descendants.
+func processTree(pid int) ([]int, error) {
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	children := map[int][]int{}
+	for _, entry := range entries {
+		child, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+
+		// The command name may contain spaces, the parent PID is the second field after it.
+		i := strings.LastIndex(string(content), ")")
+		if i < 0 {
+			continue
+		}
+
+		fields := strings.Fields(string(content)[i+1:])
+		if len(fields) < 2 {
+			continue
+		}
+
+		parent, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+
+		children[parent] = append(children[parent], child)
+	}
+
+	pids := []int{pid}
+	for i := 0; i < len(pids); i++ {
+		pids = append(pids, children[pids[i]]...)
+	}
+
+	return pids, nil
+}
+
+// OnHook is the top-level hook handler.
+func (c *lxc) OnHook(hookName string, args map[string]string) error {
+	switch hookName {
+	case instance.HookStart:
+		return c.onStart(args)
+	case instance.HookStopNS:
+		return c.onStopNS(args)
+	case instance.HookStop:
+		return c.onStop(args)
+	default:
+		return instance.ErrNotImplemented
+	}
+}
+
+// onStart implements the start hook.
+func (c *lxc) onStart(_ map[string]string) error {
+	// Make sure we can't call go-lxc functions by mistake
+	c.fromHook = true
+
+	// Start the storage for this container
+	ourStart, err := c.mount()
+	if err != nil {
+		return err
+	}
+
+	// Load the container AppArmor profile
+	err = apparmor.LoadProfile(c.state, c)
 	if err != nil {
 		if ourStart {
 			c.unmount()
@@ -2488,6 +2994,18 @@ func (c *lxc) onStart(_ map[string]string) error {
 		return err
 	}
 
+	// Share the timezone of the host
+	if c.expandedConfig["host.timezone"] == "mount" {
+		err = c.hostTimezoneSetup()
+		if err != nil {
+			apparmor.Destroy(c.state, c)
+			if ourStart {
+				c.unmount()
+			}
+			return errors.Wrap(err, "Failed to setup the host timezone")
+		}
+	}
+
 	// Trigger a rebalance
 	cgroup.TaskSchedulerTrigger("container", c.name, "started")
 
@@ -2497,7 +3015,8 @@ func (c *lxc) onStart(_ map[string]string) error {
 			c.fromHook = false
 			err := c.setNetworkPriority()
 			if err != nil {
-				logger.Error("Failed to apply network priority", log.Ctx{"container": c.name, "err": err})
+				entityURL := fmt.Sprintf("/%s/instances/%s", version.APIVersion, c.name)
+				warnings.Raise(c.state.Cluster, c.project, entityURL, warnings.TypeLimitNotApplied, warnings.SeverityModerate, fmt.Sprintf("Failed to apply limits.network.priority to %q: %v", c.name, err))
 			}
 		}(c)
 	}
@@ -2534,6 +3053,12 @@ func (c *lxc) Stop(stateful bool) error {
 		return fmt.Errorf("The container is already stopped")
 	}
 
+	// Stop the containers sharing our namespaces first.
+	err := c.stopSharedNamespaceDependents()
+	if err != nil {
+		return err
+	}
+
 	// Setup a new operation
 	op, err := operationlock.Create(c.id, "stop", false, true)
 	if err != nil {
@@ -2669,6 +3194,16 @@ func (c *lxc) Stop(stateful bool) error {
 
 // Shutdown stops the instance.
 func (c *lxc) Shutdown(timeout time.Duration) error {
+	return c.shutdown(timeout, "")
+}
+
+// ShutdownWithSignal stops the container by sending the given signal to its init process instead
+// of the configured halt signal, and waiting for it to exit.
+func (c *lxc) ShutdownWithSignal(timeout time.Duration, signal string) error {
+	return c.shutdown(timeout, signal)
+}
+
+func (c *lxc) shutdown(timeout time.Duration, signal string) error {
 	var ctxMap log.Ctx
 
 	// Check that we're not already stopped
@@ -2676,6 +3211,12 @@ func (c *lxc) Shutdown(timeout time.Duration) error {
 		return fmt.Errorf("The container is already stopped")
 	}
 
+	// Stop the containers sharing our namespaces first.
+	err := c.stopSharedNamespaceDependents()
+	if err != nil {
+		return err
+	}
+
 	// Setup a new operation
 	op, err := operationlock.Create(c.id, "stop", true, true)
 	if err != nil {
@@ -2691,6 +3232,10 @@ func (c *lxc) Shutdown(timeout time.Duration) error {
 		"used":      c.lastUsedDate,
 		"timeout":   timeout}
 
+	if signal != "" {
+		ctxMap["signal"] = signal
+	}
+
 	logger.Info("Shutting down container", ctxMap)
 
 	// Load the go-lxc struct
@@ -2710,6 +3255,25 @@ func (c *lxc) Shutdown(timeout time.Duration) error {
 		}
 	}
 
+	if signal != "" {
+		// Restore the configured halt signal, in case the container outlives the timeout.
+		haltSignal := c.c.ConfigItem("lxc.signal.halt")
+		defer func() {
+			if len(haltSignal) > 0 && haltSignal[0] != "" {
+				c.c.SetConfigItem("lxc.signal.halt", haltSignal[0])
+			} else {
+				c.c.ClearConfigItem("lxc.signal.halt")
+			}
+		}()
+
+		err = lxcSetConfigItem(c.c, "lxc.signal.halt", signal)
+		if err != nil {
+			op.Done(err)
+			logger.Error("Failed shutting down container", ctxMap)
+			return err
+		}
+	}
+
 	if err := c.c.Shutdown(timeout); err != nil {
 		op.Done(err)
 		logger.Error("Failed shutting down container", ctxMap)
@@ -2786,6 +3350,16 @@ func (c *lxc) onStop(args map[string]string) error {
 		return err
 	}
 
+	// Discard the writable layer of an ephemeral rootfs
+	err = c.unmountRootfsOverlay()
+	if err != nil {
+		if op != nil {
+			op.Done(err)
+		}
+
+		return err
+	}
+
 	// Stop the storage for this container
 	_, err = c.unmount()
 	if err != nil {
@@ -2808,6 +3382,13 @@ func (c *lxc) onStop(args map[string]string) error {
 
 	if op == nil {
 		logger.Info(fmt.Sprintf("Container initiated %s", target), ctxMap)
+
+		err = c.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+			return tx.InstanceHistoryAdd(c.id, target, "", "")
+		})
+		if err != nil {
+			logger.Warn("Failed to record container state change", log.Ctx{"container": c.Name(), "err": err})
+		}
 	}
 
 	// Record power state
@@ -2874,7 +3455,9 @@ func (c *lxc) onStop(args map[string]string) error {
 
 // cleanupDevices performs any needed device cleanup steps when container is stopped.
 func (c *lxc) cleanupDevices(netns string) {
-	for _, dev := range c.expandedDevices.Sorted() {
+	// Stop the devices in the reverse order they were started in, so that nested mounts are
+	// unmounted before their parents.
+	for _, dev := range c.expandedDevices.Reversed() {
 		// Use the device interface if device supports it.
 		err := c.deviceStop(dev.Name, dev.Config, netns)
 		if err == device.ErrUnsupportedDevType {
@@ -2906,7 +3489,9 @@ func (c *lxc) Freeze() error {
 
 	// Check if the CGroup is available
 	if !c.state.OS.CGInfo.Supports(cgroup.Freezer, cg) {
-		logger.Info("Unable to freeze container (lack of kernel support)", ctxMap)
+		// Internal callers like snapshots freeze on a best-effort basis, the API rejects
+		// such requests up front.
+		logger.Warn("Unable to freeze container (lack of kernel support)", ctxMap)
 		return nil
 	}
 
@@ -2960,7 +3545,7 @@ func (c *lxc) Unfreeze() error {
 
 	// Check if the CGroup is available
 	if !c.state.OS.CGInfo.Supports(cgroup.Freezer, cg) {
-		logger.Info("Unable to unfreeze container (lack of kernel support)", ctxMap)
+		logger.Warn("Unable to unfreeze container (lack of kernel support)", ctxMap)
 		return nil
 	}
 
@@ -2980,14 +3565,16 @@ func (c *lxc) Unfreeze() error {
 
 	err = c.c.Unfreeze()
 	if err != nil {
+		ctxMap["err"] = err
 		logger.Error("Failed unfreezing container", ctxMap)
+		return err
 	}
 
 	logger.Info("Unfroze container", ctxMap)
 	c.state.Events.SendLifecycle(c.project, "container-resumed",
 		fmt.Sprintf("/1.0/containers/%s", c.name), nil)
 
-	return err
+	return nil
 }
 
 // Get lxc container state, with 1 second timeout
@@ -3041,6 +3628,7 @@ func (c *lxc) Render(options ...func(response interface{}) error) (interface{},
 		snapState.Ephemeral = c.ephemeral
 		snapState.Profiles = c.profiles
 		snapState.ExpiresAt = c.expiryDate
+		snapState.Description = c.description
 
 		for _, option := range options {
 			err := option(&snapState)
@@ -3170,9 +3758,18 @@ func (c *lxc) RenderState() (*api.InstanceState, error) {
 		status.Network = c.networkState()
 		status.Pid = int64(pid)
 		status.Processes = c.processesState()
+		status.Pressure = c.pressureState()
 	}
 	status.Disk = c.diskState()
 
+	if c.localConfig["volatile.last_exit"] != "" {
+		lastExit := api.InstanceStateLastExit{}
+		err = json.Unmarshal([]byte(c.localConfig["volatile.last_exit"]), &lastExit)
+		if err == nil {
+			status.LastExit = &lastExit
+		}
+	}
+
 	return &status, nil
 }
 
@@ -3255,8 +3852,7 @@ func (c *lxc) Restore(sourceContainer instance.Instance, stateful bool) error {
 
 	// Check for CRIU if necessary, before doing a bunch of filesystem manipulations.
 	if shared.PathExists(c.StatePath()) {
-		_, err := exec.LookPath("criu")
-		if err != nil {
+		if !tools.Available("criu") {
 			return fmt.Errorf("Failed to restore container state. CRIU isn't installed")
 		}
 	}
@@ -3441,6 +4037,14 @@ func (c *lxc) Delete() error {
 		return err
 	}
 
+	if !c.IsSnapshot() {
+		err := c.checkNoSharedNamespaceDependents()
+		if err != nil {
+			logger.Warn("Failed to delete container", log.Ctx{"name": c.Name(), "err": err})
+			return err
+		}
+	}
+
 	pool, err := storagePools.GetPoolByInstance(c.state, c)
 	if err != nil && err != db.ErrNoSuchObject {
 		return err
@@ -3486,13 +4090,15 @@ func (c *lxc) Delete() error {
 
 	// Perform other cleanup steps if not snapshot.
 	if !c.IsSnapshot() {
-		// Remove all backups.
+		// Remove all backups, newest first so that incremental backups are removed before
+		// the backups they're based upon.
 		backups, err := c.Backups()
 		if err != nil {
 			return err
 		}
 
-		for _, backup := range backups {
+		for i := len(backups) - 1; i >= 0; i-- {
+			backup := backups[i]
 			err = backup.Delete()
 			if err != nil {
 				return err
@@ -3558,6 +4164,13 @@ func (c *lxc) Rename(newName string) error {
 		return err
 	}
 
+	if !c.IsSnapshot() {
+		err = c.checkNoSharedNamespaceDependents()
+		if err != nil {
+			return err
+		}
+	}
+
 	if c.IsRunning() {
 		return fmt.Errorf("Renaming of running container not allowed")
 	}
@@ -3571,11 +4184,35 @@ func (c *lxc) Rename(newName string) error {
 	}
 
 	if c.IsSnapshot() {
+		parentName, oldSnapName, _ := shared.InstanceGetParentAndSnapshotName(oldName)
 		_, newSnapName, _ := shared.InstanceGetParentAndSnapshotName(newName)
+
+		// Rename the database entry first and put it back if the storage volume can't be
+		// renamed, so that the snapshot never ends up half renamed.
+		err = c.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+			return tx.InstanceSnapshotRename(c.project, parentName, oldSnapName, newSnapName)
+		})
+		if err != nil {
+			logger.Error("Failed renaming snapshot", ctxMap)
+			return err
+		}
+
 		err = pool.RenameInstanceSnapshot(c, newSnapName, nil)
 		if err != nil {
+			c.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+				return tx.InstanceSnapshotRename(c.project, parentName, newSnapName, oldSnapName)
+			})
 			return errors.Wrap(err, "Rename instance snapshot")
 		}
+
+		// The backup file of the parent lists its snapshots.
+		parent, err := instance.LoadByProjectAndName(c.state, c.project, parentName)
+		if err == nil {
+			err = pool.UpdateInstanceBackupFile(parent, nil)
+		}
+		if err != nil {
+			logger.Warn("Failed to update the backup file after renaming snapshot", log.Ctx{"project": c.project, "instance": parentName, "err": err})
+		}
 	} else {
 		err = pool.RenameInstance(c, newName, nil)
 		if err != nil {
@@ -3605,19 +4242,15 @@ func (c *lxc) Rename(newName string) error {
 		}
 	}
 
-	// Rename the instance database entry.
-	err = c.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
-		if c.IsSnapshot() {
-			oldParts := strings.SplitN(oldName, shared.SnapshotDelimiter, 2)
-			newParts := strings.SplitN(newName, shared.SnapshotDelimiter, 2)
-			return tx.InstanceSnapshotRename(c.project, oldParts[0], oldParts[1], newParts[1])
+	// Rename the instance database entry, snapshots being renamed along with their volume.
+	if !c.IsSnapshot() {
+		err = c.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+			return tx.InstanceRename(c.project, oldName, newName)
+		})
+		if err != nil {
+			logger.Error("Failed renaming container", ctxMap)
+			return err
 		}
-
-		return tx.InstanceRename(c.project, oldName, newName)
-	})
-	if err != nil {
-		logger.Error("Failed renaming container", ctxMap)
-		return err
 	}
 
 	// Rename the logging path.
@@ -3779,8 +4412,14 @@ func (c *lxc) Update(args db.InstanceArgs, userRequested bool) error {
 	}
 
 	if userRequested {
+		// Keep the keys only LXD may set.
+		err := instance.KeepInternalConfig(args.Config, c.localConfig)
+		if err != nil {
+			return errors.Wrap(err, "Invalid config")
+		}
+
 		// Validate the new config
-		err := instance.ValidConfig(c.state.OS, args.Config, false, false)
+		err = instance.ValidConfig(c.state.OS, args.Config, false, false)
 		if err != nil {
 			return errors.Wrap(err, "Invalid config")
 		}
@@ -3981,6 +4620,15 @@ func (c *lxc) Update(args db.InstanceArgs, userRequested bool) error {
 		return err
 	}
 
+	// Limits changed by the user take over from the ones set by the autoscaling policy.
+	for _, key := range []string{"limits.cpu", "limits.memory"} {
+		if shared.StringInSlice(key, changedConfig) {
+			volatileKey := fmt.Sprintf("volatile.autoscale.%s", strings.TrimPrefix(key, "limits."))
+			delete(c.localConfig, volatileKey)
+			delete(c.expandedConfig, volatileKey)
+		}
+	}
+
 	// If apparmor changed, re-validate the apparmor profile
 	if shared.StringInSlice("raw.apparmor", changedConfig) || shared.StringInSlice("security.nesting", changedConfig) {
 		err = apparmor.ParseProfile(c.state, c)
@@ -4076,6 +4724,12 @@ func (c *lxc) Update(args db.InstanceArgs, userRequested bool) error {
 						return err
 					}
 				}
+			} else if key == "host.timezone.follow" && shared.IsTrue(value) && c.expandedConfig["host.timezone"] == "mount" {
+				// Catch up with changes of the host timezone made while not following it
+				err = c.HostTimezoneSync()
+				if err != nil {
+					return err
+				}
 			} else if key == "linux.kernel_modules" && value != "" {
 				for _, module := range strings.Split(value, ",") {
 					module = strings.TrimPrefix(module, " ")
@@ -4085,7 +4739,7 @@ func (c *lxc) Update(args db.InstanceArgs, userRequested bool) error {
 					}
 				}
 			} else if key == "limits.disk.priority" {
-				if !c.state.OS.CGInfo.Supports(cgroup.Blkio, cg) {
+				if !c.state.OS.CGInfo.Supports(cgroup.BlkioWeight, cg) {
 					continue
 				}
 
@@ -4104,7 +4758,7 @@ func (c *lxc) Update(args db.InstanceArgs, userRequested bool) error {
 					priority = 10
 				}
 
-				cg.SetBlkioWeight(fmt.Sprintf("%d", priority))
+				err = cg.SetBlkioWeight(fmt.Sprintf("%d", priority), c.diskUsesBFQ())
 				if err != nil {
 					return err
 				}
@@ -4114,8 +4768,12 @@ func (c *lxc) Update(args db.InstanceArgs, userRequested bool) error {
 					continue
 				}
 
-				// Set the new memory limit
+				// Set the new memory limit, keeping the one set by the autoscaling policy
 				memory := c.expandedConfig["limits.memory"]
+				if c.expandedConfig["volatile.autoscale.memory"] != "" {
+					memory = c.expandedConfig["volatile.autoscale.memory"]
+				}
+
 				memoryEnforce := c.expandedConfig["limits.memory.enforce"]
 				memorySwap := c.expandedConfig["limits.memory.swap"]
 
@@ -4269,6 +4927,11 @@ func (c *lxc) Update(args db.InstanceArgs, userRequested bool) error {
 				if err != nil {
 					return err
 				}
+
+				err = device.NetworkRefreshPriority(c.state, c)
+				if err != nil {
+					return err
+				}
 			} else if key == "limits.cpu" {
 				// Trigger a scheduler re-run
 				cgroup.TaskSchedulerTrigger("container", c.name, "changed")
@@ -4810,8 +5473,7 @@ func (c *lxc) Migrate(args *instance.CriuMigrationArgs) error {
 		"features":     args.Features,
 		"stop":         args.Stop}
 
-	_, err := exec.LookPath("criu")
-	if err != nil {
+	if !tools.Available("criu") {
 		return fmt.Errorf("Unable to perform container live migration. CRIU isn't installed")
 	}
 
@@ -5642,6 +6304,18 @@ func (c *lxc) Exec(req api.InstanceExecPost, stdin *os.File, stdout *os.File, st
 	}
 	logger.Debugf("Retrieved PID %d of executing child process", attachedPid)
 
+	// Attached processes are forked from LXD rather than from the container, add them to the core
+	// scheduling domain of the container.
+	if shared.IsTrue(c.expandedConfig["security.core_scheduling"]) {
+		err = c.coreScheduling([]int{int(attachedPid)}, true)
+		if err != nil {
+			// Don't let the process run outside of the domain.
+			unix.Kill(int(attachedPid), unix.SIGKILL)
+			cmd.Wait()
+			return nil, errors.Wrap(err, "Failed to add executing child process to core scheduling domain")
+		}
+	}
+
 	instCmd := &lxcCmd{
 		cmd:              &cmd,
 		attachedChildPid: int(attachedPid),
@@ -5680,6 +6354,42 @@ func (c *lxc) cpuState() api.InstanceStateCPU {
 	return cpu
 }
 
+// pressureState returns the pressure stall information of the container, only available on the
+// unified cgroup hierarchy.
+func (c *lxc) pressureState() map[string]api.InstanceStatePressure {
+	cg, err := c.cgroup(nil)
+	if err != nil {
+		return nil
+	}
+
+	toAPI := func(stall cgroup.PressureStall) api.InstanceStatePressureStall {
+		return api.InstanceStatePressureStall{Avg10: stall.Avg10, Avg60: stall.Avg60, Avg300: stall.Avg300, Total: stall.Total}
+	}
+
+	result := map[string]api.InstanceStatePressure{}
+	for _, resource := range []string{"cpu", "memory", "io"} {
+		stalls, err := cg.GetPressure(resource)
+		if err != nil {
+			continue
+		}
+
+		pressure := api.InstanceStatePressure{Some: toAPI(stalls["some"])}
+		full, ok := stalls["full"]
+		if ok {
+			fullStall := toAPI(full)
+			pressure.Full = &fullStall
+		}
+
+		result[resource] = pressure
+	}
+
+	if len(result) == 0 {
+		return nil
+	}
+
+	return result
+}
+
 func (c *lxc) diskState() map[string]api.InstanceStateDisk {
 	disk := map[string]api.InstanceStateDisk{}
 
@@ -5775,9 +6485,122 @@ func (c *lxc) memoryState() api.InstanceStateMemory {
 		}
 	}
 
+	oomKills, err := cg.GetMemoryOOMKills()
+	if err == nil {
+		memory.OOMKills = oomKills
+	}
+
 	return memory
 }
 
+// MemoryOOMKills returns the number of processes of the container killed by the OOM killer.
+func (c *lxc) MemoryOOMKills() (int64, error) {
+	cg, err := c.cgroup(nil)
+	if err != nil {
+		return -1, err
+	}
+
+	if !c.state.OS.CGInfo.Supports(cgroup.Memory, cg) {
+		return -1, cgroup.ErrControllerMissing
+	}
+
+	return cg.GetMemoryOOMKills()
+}
+
+// MemoryPressure returns the share of the last minute, in percent, during which all the processes of
+// the container were stalled waiting for memory. It's only available on the unified cgroup hierarchy.
+func (c *lxc) MemoryPressure() (float64, error) {
+	cg, err := c.cgroup(nil)
+	if err != nil {
+		return -1, err
+	}
+
+	stalls, err := cg.GetPressure("memory")
+	if err != nil {
+		return -1, err
+	}
+
+	full, ok := stalls["full"]
+	if !ok {
+		return -1, cgroup.ErrControllerMissing
+	}
+
+	return full.Avg60, nil
+}
+
+// MemoryWorkingSet returns the memory used by the container, not counting the page cache which
+// can be reclaimed.
+func (c *lxc) MemoryWorkingSet() (int64, error) {
+	err := c.initLXC(false)
+	if err != nil {
+		return -1, err
+	}
+
+	cg, err := c.cgroup(nil)
+	if err != nil {
+		return -1, err
+	}
+
+	if !c.state.OS.CGInfo.Supports(cgroup.Memory, cg) {
+		return -1, cgroup.ErrControllerMissing
+	}
+
+	return cg.GetMemoryWorkingSet()
+}
+
+// MemoryLimitSet applies a memory limit to the running container the way limits.memory would be,
+// without changing its configuration.
+func (c *lxc) MemoryLimitSet(limit int64) error {
+	err := c.initLXC(false)
+	if err != nil {
+		return err
+	}
+
+	if !c.IsRunning() {
+		return fmt.Errorf("Can't set cgroups on a stopped container")
+	}
+
+	cg, err := c.cgroup(nil)
+	if err != nil {
+		return err
+	}
+
+	if !c.state.OS.CGInfo.Supports(cgroup.Memory, cg) {
+		return cgroup.ErrControllerMissing
+	}
+
+	memory := fmt.Sprintf("%d", limit)
+	if c.expandedConfig["limits.memory.enforce"] == "soft" {
+		return cg.SetMemorySoftLimit(memory)
+	}
+
+	memorySwap := c.expandedConfig["limits.memory.swap"]
+	limitSwap := c.state.OS.CGInfo.Supports(cgroup.MemorySwap, cg) && (memorySwap == "" || shared.IsTrue(memorySwap))
+
+	// The swap limit can't be lower than the memory one, lift it while the latter changes.
+	if limitSwap {
+		err = cg.SetMemorySwapMax("-1")
+		if err != nil {
+			return err
+		}
+	}
+
+	err = cg.SetMemoryMaxUsage(memory)
+	if err != nil {
+		return err
+	}
+
+	if limitSwap {
+		err = cg.SetMemorySwapMax(memory)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Set soft limit to value 10% less than hard limit
+	return cg.SetMemorySoftLimit(fmt.Sprintf("%.0f", float64(limit)*0.9))
+}
+
 func (c *lxc) networkState() map[string]api.InstanceStateNetwork {
 	result := map[string]api.InstanceStateNetwork{}
 
@@ -5825,17 +6648,69 @@ func (c *lxc) networkState() map[string]api.InstanceStateNetwork {
 		result = nw
 	}
 
-	// Get host_name from volatile data if not set already.
+	// Map the interface names back to the nic devices they belong to.
+	nicDevices := map[string]string{}
+	for devName, m := range c.expandedDevices {
+		if m["type"] != "nic" {
+			continue
+		}
+
+		ifName := m["name"]
+		if ifName == "" {
+			ifName = c.localConfig[fmt.Sprintf("volatile.%s.name", devName)]
+		}
+
+		nicDevices[ifName] = devName
+	}
+
 	for name, dev := range result {
-		if dev.HostName == "" {
-			dev.HostName = c.localConfig[fmt.Sprintf("volatile.%s.host_name", name)]
-			result[name] = dev
+		dev.Device = nicDevices[name]
+
+		// Get host_name from volatile data if not set already.
+		if dev.HostName == "" && dev.Device != "" {
+			dev.HostName = c.localConfig[fmt.Sprintf("volatile.%s.host_name", dev.Device)]
+		}
+
+		// Add the state of the host side interface, so it can be correlated with host data.
+		if dev.HostName != "" && shared.PathExists(fmt.Sprintf("/sys/class/net/%s", dev.HostName)) {
+			dev.LinkState, dev.Speed = networkLinkState(dev.HostName)
+
+			counters := shared.NetworkGetCounters(dev.HostName)
+			dev.HostCounters = &api.InstanceStateNetworkCounters{
+				BytesReceived:   counters.BytesReceived,
+				BytesSent:       counters.BytesSent,
+				PacketsReceived: counters.PacketsReceived,
+				PacketsSent:     counters.PacketsSent,
+			}
 		}
+
+		result[name] = dev
 	}
 
 	return result
 }
 
+// networkLinkState returns the operational state and the speed (in Mbit/s) of the given host
+// interface. The speed is 0 when the driver doesn't report it.
+func networkLinkState(ifName string) (string, int64) {
+	linkState := "unknown"
+	content, err := ioutil.ReadFile(fmt.Sprintf("/sys/class/net/%s/operstate", ifName))
+	if err == nil {
+		linkState = strings.TrimSpace(string(content))
+	}
+
+	speed := int64(0)
+	content, err = ioutil.ReadFile(fmt.Sprintf("/sys/class/net/%s/speed", ifName))
+	if err == nil {
+		value, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+		if err == nil && value > 0 {
+			speed = value
+		}
+	}
+
+	return linkState, speed
+}
+
 func (c *lxc) processesState() int64 {
 	// Return 0 if not running
 	pid := c.InitPID()
@@ -6928,3 +7803,318 @@ func (c *lxc) SaveConfigFile() error {
 
 	return nil
 }
+
+// lxcShareableNamespaces lists the namespaces a container can join from another container, through
+// the linux.namespaces.NAMESPACE config keys.
+var lxcShareableNamespaces = []string{"net", "ipc", "pid", "uts"}
+
+// sharedNamespaces returns the namespaces the container joins, mapped to the name of the container
+// (in the same project) owning them.
+func (c *lxc) sharedNamespaces() map[string]string {
+	namespaces := map[string]string{}
+	for _, ns := range lxcShareableNamespaces {
+		target := c.expandedConfig[fmt.Sprintf("linux.namespaces.%s", ns)]
+		if target != "" {
+			namespaces[ns] = target
+		}
+	}
+
+	return namespaces
+}
+
+// setupMaskedPaths hides the paths listed in security.masked_paths, directories get an empty
+// read-only tmpfs and anything else gets /dev/null mounted on top of it. Paths are looked up in the
+// root filesystem of the container, except those under /proc and /sys which come from the kernel.
+// Paths which don't exist or go through a symlink can't be masked and fail the start, rather than
+// leaving the container running with them exposed.
+func (c *lxc) setupMaskedPaths() error {
+	for _, mnt := range strings.Split(c.expandedConfig["security.masked_paths"], ",") {
+		mnt = filepath.Clean(strings.TrimSpace(mnt))
+		if mnt == "." || mnt == "/" {
+			continue
+		}
+
+		root := c.RootfsPath()
+		if mnt == "/proc" || mnt == "/sys" || strings.HasPrefix(mnt, "/proc/") || strings.HasPrefix(mnt, "/sys/") {
+			root = "/"
+		}
+
+		// Don't follow symlinks, which could lead out of the root filesystem of the container.
+		var info os.FileInfo
+		var err error
+		path := root
+		for _, part := range strings.Split(strings.TrimPrefix(mnt, "/"), "/") {
+			path = filepath.Join(path, part)
+			info, err = os.Lstat(path)
+			if err != nil || info.Mode()&os.ModeSymlink != 0 {
+				break
+			}
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "Failed to mask path %q", mnt)
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("Failed to mask path %q, it goes through a symlink", mnt)
+		}
+
+		if info.IsDir() {
+			err = lxcSetConfigItem(c.c, "lxc.mount.entry", fmt.Sprintf("tmpfs %s tmpfs ro,size=0 0 0", strings.TrimPrefix(mnt, "/")))
+		} else {
+			err = lxcSetConfigItem(c.c, "lxc.mount.entry", fmt.Sprintf("/dev/null %s none bind,ro 0 0", strings.TrimPrefix(mnt, "/")))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setupSharedNamespaces configures LXC to join the namespaces shared by other containers, starting
+// those containers first if needed. Unprivileged containers also join the user namespace owning the
+// shared namespaces, so both sides must use the same idmap.
+func (c *lxc) setupSharedNamespaces() error {
+	namespaces := c.sharedNamespaces()
+	if len(namespaces) == 0 {
+		return nil
+	}
+
+	if !util.RuntimeLiblxcVersionAtLeast(3, 0, 0) {
+		return fmt.Errorf("Sharing namespaces requires liblxc >= 3.0")
+	}
+
+	if namespaces["net"] != "" {
+		for devName, m := range c.expandedDevices {
+			if shared.StringInSlice(m["type"], []string{"nic", "infiniband"}) {
+				return fmt.Errorf("Device %q can't be used when sharing the network namespace of %q", devName, namespaces["net"])
+			}
+		}
+	}
+
+	userTarget := ""
+	for _, ns := range lxcShareableNamespaces {
+		targetName := namespaces[ns]
+		if targetName == "" {
+			continue
+		}
+
+		if targetName == c.name {
+			return fmt.Errorf("Container can't share its own %s namespace", ns)
+		}
+
+		target, err := instance.LoadByProjectAndName(c.state, c.project, targetName)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to load container %q sharing the %s namespace", targetName, ns)
+		}
+
+		if target.Type() != instancetype.Container {
+			return fmt.Errorf("Namespaces can only be shared with containers, %q isn't one", targetName)
+		}
+
+		if target.Location() != c.Location() {
+			return fmt.Errorf("Namespaces can only be shared with containers on the same cluster member, %q is on %q", targetName, target.Location())
+		}
+
+		if target.ExpandedConfig()[fmt.Sprintf("linux.namespaces.%s", ns)] != "" {
+			return fmt.Errorf("Container %q can't share a %s namespace it joined itself", targetName, ns)
+		}
+
+		if target.IsPrivileged() != c.IsPrivileged() {
+			return fmt.Errorf("Namespaces can't be shared between privileged and unprivileged containers")
+		}
+
+		// Processes sharing IPC or PID namespaces can interact with each other, so neither side
+		// may be less confined than the other.
+		if shared.StringInSlice(ns, []string{"ipc", "pid"}) {
+			err = lxcCheckSameConfinement(c.expandedConfig, target.ExpandedConfig())
+			if err != nil {
+				return errors.Wrapf(err, "Can't share the %s namespace of %q", ns, targetName)
+			}
+		}
+
+		if !c.IsPrivileged() {
+			if userTarget != "" && userTarget != targetName {
+				return fmt.Errorf("Unprivileged containers can only share namespaces with a single container")
+			}
+
+			userTarget = targetName
+		}
+
+		if !target.IsRunning() {
+			maintenance, err := node.Maintenance(c.state.Node)
+			if err != nil {
+				return err
+			}
+
+			if maintenance {
+				return fmt.Errorf("The server is in maintenance mode (core.maintenance), container %q sharing the %s namespace can't be started", targetName, ns)
+			}
+
+			err = target.Start(false)
+			if err != nil {
+				return errors.Wrapf(err, "Failed to start container %q sharing the %s namespace", targetName, ns)
+			}
+		}
+
+		if !c.IsPrivileged() {
+			targetIdmap, err := target.(instance.Container).CurrentIdmap()
+			if err != nil {
+				return err
+			}
+
+			idmap, err := c.NextIdmap()
+			if err != nil {
+				return err
+			}
+
+			if !idmap.Equals(targetIdmap) {
+				return fmt.Errorf("Container %q uses a different idmap, namespaces can't be shared (is security.idmap.isolated set?)", targetName)
+			}
+		}
+
+		pid := fmt.Sprintf("%d", target.InitPID())
+		err = lxcSetConfigItem(c.c, fmt.Sprintf("lxc.namespace.share.%s", ns), pid)
+		if err != nil {
+			return err
+		}
+
+		if userTarget == targetName {
+			err = lxcSetConfigItem(c.c, "lxc.namespace.share.user", pid)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Keep the hostname of the container owning a shared UTS namespace.
+		if ns == "uts" {
+			err = lxcSetConfigItem(c.c, "lxc.uts.name", targetName)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// lxcCheckSameConfinement fails if the two configurations differ in any setting affecting how the
+// containers are confined.
+func lxcCheckSameConfinement(config map[string]string, otherConfig map[string]string) error {
+	keys := map[string]bool{}
+	for _, values := range []map[string]string{config, otherConfig} {
+		for key := range values {
+			if (strings.HasPrefix(key, "security.") && !strings.HasPrefix(key, "security.protection.")) || shared.StringInSlice(key, []string{"raw.apparmor", "raw.lxc", "raw.seccomp"}) {
+				keys[key] = true
+			}
+		}
+	}
+
+	for key := range keys {
+		if config[key] != otherConfig[key] {
+			return fmt.Errorf("Both containers must have the same %q setting", key)
+		}
+	}
+
+	return nil
+}
+
+// sharedNamespaceDependents returns the containers of the same project configured to join one of
+// the container's namespaces.
+func (c *lxc) sharedNamespaceDependents() ([]instance.Instance, error) {
+	names := []string{}
+	err := c.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		for _, ns := range lxcShareableNamespaces {
+			nsNames, err := tx.InstanceNamesWithConfig(c.project, fmt.Sprintf("linux.namespaces.%s", ns), c.name)
+			if err != nil {
+				return err
+			}
+
+			for _, name := range nsNames {
+				if !shared.StringInSlice(name, names) {
+					names = append(names, name)
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dependents := []instance.Instance{}
+	for _, name := range names {
+		if name == c.name {
+			continue
+		}
+
+		inst, err := instance.LoadByProjectAndName(c.state, c.project, name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ns := range lxcShareableNamespaces {
+			if inst.ExpandedConfig()[fmt.Sprintf("linux.namespaces.%s", ns)] == c.name {
+				dependents = append(dependents, inst)
+				break
+			}
+		}
+	}
+
+	return dependents, nil
+}
+
+// stopSharedNamespaceDependents stops the running containers which joined the container's
+// namespaces, as they'd otherwise be left with half torn down namespaces. They're shut down cleanly
+// first, waiting for boot.host_shutdown_timeout as when stopping the server, then killed.
+func (c *lxc) stopSharedNamespaceDependents() error {
+	dependents, err := c.sharedNamespaceDependents()
+	if err != nil {
+		return err
+	}
+
+	for _, dependent := range dependents {
+		if !dependent.IsRunning() {
+			continue
+		}
+
+		timeout := 30
+		value, ok := dependent.ExpandedConfig()["boot.host_shutdown_timeout"]
+		if ok {
+			configured, err := strconv.Atoi(value)
+			if err == nil {
+				timeout = configured
+			}
+		}
+
+		err = dependent.Shutdown(time.Duration(timeout) * time.Second)
+		if err == nil || !dependent.IsRunning() {
+			continue
+		}
+
+		logger.Warn("Failed to shut down container sharing namespaces, stopping it", log.Ctx{"project": c.project, "instance": dependent.Name(), "owner": c.name, "err": err})
+		err = dependent.Stop(false)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to stop container %q sharing namespaces with %q", dependent.Name(), c.name)
+		}
+	}
+
+	return nil
+}
+
+// checkNoSharedNamespaceDependents fails if other containers are configured to join the container's
+// namespaces.
+func (c *lxc) checkNoSharedNamespaceDependents() error {
+	dependents, err := c.sharedNamespaceDependents()
+	if err != nil {
+		return err
+	}
+
+	if len(dependents) > 0 {
+		return fmt.Errorf("Container %q shares its namespaces with %q", c.name, dependents[0].Name())
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package drivers
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// hostTimezonePath returns the path of the copy of the host's zone file which is bind-mounted
+// over /etc/localtime in the container when host.timezone is set to "mount".
+func (c *lxc) hostTimezonePath() string {
+	return filepath.Join(c.DevicesPath(), "localtime")
+}
+
+// hostTimezoneSetup writes the copy of the host's zone file mounted in the container. LXC
+// doesn't mount over symlinks, so a symlinked /etc/localtime is replaced by a copy of the zone
+// file beforehand when host.timezone.replace is set, and left alone otherwise, in which case the
+// container keeps its own timezone. This must be called with the container stopped and its rootfs
+// mounted.
+func (c *lxc) hostTimezoneSetup() error {
+	data, err := util.HostTimezoneData()
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(c.hostTimezonePath(), data, 0644)
+	if err != nil {
+		return err
+	}
+
+	// Don't follow symlinks leading outside of the container.
+	etcPath := filepath.Join(c.RootfsPath(), "etc")
+	fi, err := os.Lstat(etcPath)
+	if err != nil || !fi.IsDir() {
+		return nil
+	}
+
+	target := filepath.Join(etcPath, "localtime")
+	fi, err = os.Lstat(target)
+	if err == nil && fi.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil && !shared.IsTrue(c.expandedConfig["host.timezone.replace"]) {
+		logger.Warn("Not mounting the host timezone over the symlinked /etc/localtime of the container", log.Ctx{"project": c.project, "instance": c.name})
+		return nil
+	}
+
+	os.Remove(target)
+	err = ioutil.WriteFile(target, data, 0644)
+	if err != nil {
+		return err
+	}
+
+	idmapset, err := c.DiskIdmap()
+	if err != nil {
+		return err
+	}
+
+	if idmapset != nil {
+		uid, gid := idmapset.ShiftIntoNs(0, 0)
+		err = os.Lchown(target, int(uid), int(gid))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// HostTimezoneSync updates the zone file mounted in the running container when the timezone of
+// the host changed. The file is rewritten in place, so that the mount keeps pointing to it.
+func (c *lxc) HostTimezoneSync() error {
+	if c.expandedConfig["host.timezone"] != "mount" {
+		return fmt.Errorf("The host timezone isn't mounted in the container")
+	}
+
+	data, err := util.HostTimezoneData()
+	if err != nil {
+		return err
+	}
+
+	current, err := ioutil.ReadFile(c.hostTimezonePath())
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(current, data) {
+		return nil
+	}
+
+	return ioutil.WriteFile(c.hostTimezonePath(), data, 0644)
+}
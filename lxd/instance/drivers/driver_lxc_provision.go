@@ -0,0 +1,158 @@
+package drivers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// provision runs the boot.provision steps which didn't complete yet, recording their status in
+// volatile.provision.<id>.status. It stops at the first failing step, which is then retried on the
+// next start. It runs in the background once the daemon is ready, on its own copy of the instance.
+func (c *lxc) provision() {
+	<-c.state.Ready
+
+	inst, err := instance.LoadByProjectAndName(c.state, c.project, c.name)
+	if err != nil {
+		logger.Error("Failed to load instance for provisioning", log.Ctx{"project": c.project, "instance": c.name, "err": err})
+		return
+	}
+
+	d, ok := inst.(*lxc)
+	if !ok || !d.IsRunning() {
+		return
+	}
+
+	steps, err := shared.InstanceProvisionParse(d.expandedConfig["boot.provision"])
+	if err != nil {
+		logger.Error("Failed to parse provisioning steps", log.Ctx{"project": d.project, "instance": d.name, "err": err})
+		return
+	}
+
+	keys := shared.InstanceProvisionStatusKeys(steps)
+
+	// Forget about the steps which were removed from the list.
+	stale := map[string]string{}
+	for key := range d.localConfig {
+		if strings.HasPrefix(key, "volatile.provision.") && !shared.StringInSlice(key, keys) {
+			stale[key] = ""
+		}
+	}
+
+	if len(stale) > 0 {
+		err := d.VolatileSet(stale)
+		if err != nil {
+			logger.Error("Failed to clear provisioning status", log.Ctx{"project": d.project, "instance": d.name, "err": err})
+			return
+		}
+	}
+
+	logFile, err := os.OpenFile(filepath.Join(d.LogPath(), "provision.log"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		logger.Error("Failed to open provisioning log", log.Ctx{"project": d.project, "instance": d.name, "err": err})
+		return
+	}
+	defer logFile.Close()
+
+	for i, step := range steps {
+		key := keys[i]
+		if d.localConfig[key] == "done" {
+			continue
+		}
+
+		err := d.VolatileSet(map[string]string{key: "running"})
+		if err != nil {
+			logger.Error("Failed to record provisioning status", log.Ctx{"project": d.project, "instance": d.name, "err": err})
+			return
+		}
+
+		status := "done"
+		err = d.provisionStep(step, logFile)
+		if err != nil {
+			logger.Error("Failed provisioning step", log.Ctx{"project": d.project, "instance": d.name, "step": i, "err": err})
+			fmt.Fprintf(logFile, "Step %d failed: %v\n", i, err)
+			status = "failed"
+		}
+
+		// The instance may have been stopped or deleted while we were running.
+		if d.IsRunning() || status == "done" {
+			d.VolatileSet(map[string]string{key: status})
+		}
+
+		if status != "done" {
+			return
+		}
+	}
+}
+
+// provisionStep runs a single boot.provision step, writing the command output to logFile.
+func (c *lxc) provisionStep(step shared.InstanceProvisionStep, logFile *os.File) error {
+	if step.File != nil {
+		mode := 0644
+		if step.File.Mode != "" {
+			value, err := strconv.ParseUint(step.File.Mode, 8, 32)
+			if err != nil {
+				return err
+			}
+
+			mode = int(value)
+		}
+
+		tmp, err := ioutil.TempFile("", "lxd_provision_")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+
+		_, err = tmp.WriteString(step.File.Content)
+		tmp.Close()
+		if err != nil {
+			return err
+		}
+
+		return c.FilePush("file", tmp.Name(), step.File.Path, step.File.UID, step.File.GID, mode, "overwrite")
+	}
+
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		return err
+	}
+	defer stdin.Close()
+
+	fmt.Fprintf(logFile, "Running %q\n", step.Command)
+
+	req := api.InstanceExecPost{
+		Command: step.Command,
+		Environment: map[string]string{
+			"PATH": "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+			"HOME": "/root",
+			"USER": "root",
+			"LANG": "C.UTF-8",
+		},
+	}
+
+	cmd, err := c.Exec(req, stdin, logFile, logFile)
+	if err != nil {
+		return err
+	}
+
+	exitCode, err := cmd.Wait()
+	if err != nil {
+		return err
+	}
+
+	if exitCode != 0 {
+		return fmt.Errorf("Command exited with status %d", exitCode)
+	}
+
+	return nil
+}
@@ -1,9 +1,13 @@
 package drivers
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
 	"io"
 	"io/ioutil"
 	"net"
@@ -71,15 +75,24 @@ func qemuLoad(s *state.State, args db.InstanceArgs, profiles []api.Profile) (ins
 	// Create the instance struct.
 	vm := qemuInstantiate(s, args, nil)
 
-	// Expand config and devices.
+	// Expand config and devices. Invalid config references are only logged so that the
+	// instance can still be loaded and its config fixed.
 	err := vm.expandConfig(profiles)
 	if err != nil {
-		return nil, err
+		if errors.Cause(err) != instance.ErrInvalidReference {
+			return nil, err
+		}
+
+		logger.Warn("Failed to resolve config references", log.Ctx{"project": args.Project, "instance": args.Name, "err": err})
 	}
 
 	err = vm.expandDevices(profiles)
 	if err != nil {
-		return nil, err
+		if errors.Cause(err) != instance.ErrInvalidReference {
+			return nil, err
+		}
+
+		logger.Warn("Failed to resolve device references", log.Ctx{"project": args.Project, "instance": args.Name, "err": err})
 	}
 
 	return vm, nil
@@ -501,6 +514,16 @@ func (vm *qemu) OnStop(target string) error {
 		return err
 	}
 
+	// Record instance initiated state changes.
+	if op == nil {
+		err = vm.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+			return tx.InstanceHistoryAdd(vm.id, target, "", "")
+		})
+		if err != nil {
+			logger.Warn("Failed to record instance state change", log.Ctx{"instance": vm.Name(), "err": err})
+		}
+	}
+
 	if target == "reboot" {
 		err := vm.Start(false)
 		if err != nil {
@@ -648,6 +671,13 @@ func (vm *qemu) Start(stateful bool) error {
 		vm.VolatileSet(map[string]string{"volatile.vm.uuid": vmUUID})
 	}
 
+	// Keep the names picked for the profile device templates.
+	err = deviceTemplatesRecord(vm.state, vm)
+	if err != nil {
+		op.Done(err)
+		return err
+	}
+
 	// Copy OVMF settings firmware to nvram file.
 	// This firmware file can be modified by the VM so it must be copied from the defaults.
 	if !shared.PathExists(vm.getNvramPath()) {
@@ -1531,6 +1561,7 @@ func (vm *qemu) generateQemuConfigFile(devConfs []*deviceConfig.RunConfig, fdFil
 	err := qemuBase.Execute(sb, map[string]interface{}{
 		"architecture":     vm.architectureName,
 		"ringbufSizeBytes": qmp.RingbufSize,
+		"display":          shared.IsTrue(vm.expandedConfig["console.display"]),
 	})
 	if err != nil {
 		return "", err
@@ -2186,11 +2217,35 @@ func (vm *qemu) Rename(newName string) error {
 	}
 
 	if vm.IsSnapshot() {
+		parentName, oldSnapName, _ := shared.InstanceGetParentAndSnapshotName(oldName)
 		_, newSnapName, _ := shared.InstanceGetParentAndSnapshotName(newName)
+
+		// Rename the database entry first and put it back if the storage volume can't be
+		// renamed, so that the snapshot never ends up half renamed.
+		err = vm.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+			return tx.InstanceSnapshotRename(vm.project, parentName, oldSnapName, newSnapName)
+		})
+		if err != nil {
+			logger.Error("Failed renaming snapshot", ctxMap)
+			return err
+		}
+
 		err = pool.RenameInstanceSnapshot(vm, newSnapName, nil)
 		if err != nil {
+			vm.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+				return tx.InstanceSnapshotRename(vm.project, parentName, newSnapName, oldSnapName)
+			})
 			return errors.Wrap(err, "Rename instance snapshot")
 		}
+
+		// The backup file of the parent lists its snapshots.
+		parent, err := instance.LoadByProjectAndName(vm.state, vm.project, parentName)
+		if err == nil {
+			err = pool.UpdateInstanceBackupFile(parent, nil)
+		}
+		if err != nil {
+			logger.Warn("Failed to update the backup file after renaming snapshot", log.Ctx{"project": vm.project, "instance": parentName, "err": err})
+		}
 	} else {
 		err = pool.RenameInstance(vm, newName, nil)
 		if err != nil {
@@ -2220,19 +2275,15 @@ func (vm *qemu) Rename(newName string) error {
 		}
 	}
 
-	// Rename the instance database entry.
-	err = vm.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
-		if vm.IsSnapshot() {
-			oldParts := strings.SplitN(oldName, shared.SnapshotDelimiter, 2)
-			newParts := strings.SplitN(newName, shared.SnapshotDelimiter, 2)
-			return tx.InstanceSnapshotRename(vm.project, oldParts[0], oldParts[1], newParts[1])
+	// Rename the instance database entry, snapshots being renamed along with their volume.
+	if !vm.IsSnapshot() {
+		err = vm.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+			return tx.InstanceRename(vm.project, oldName, newName)
+		})
+		if err != nil {
+			logger.Error("Failed renaming instance", ctxMap)
+			return err
 		}
-
-		return tx.InstanceRename(vm.project, oldName, newName)
-	})
-	if err != nil {
-		logger.Error("Failed renaming instance", ctxMap)
-		return err
 	}
 
 	// Rename the logging path.
@@ -2330,8 +2381,14 @@ func (vm *qemu) Update(args db.InstanceArgs, userRequested bool) error {
 	}
 
 	if userRequested {
+		// Keep the keys only LXD may set.
+		err := instance.KeepInternalConfig(args.Config, vm.localConfig)
+		if err != nil {
+			return errors.Wrap(err, "Invalid config")
+		}
+
 		// Validate the new config.
-		err := instance.ValidConfig(vm.state.OS, args.Config, false, false)
+		err = instance.ValidConfig(vm.state.OS, args.Config, false, false)
 		if err != nil {
 			return errors.Wrap(err, "Invalid config")
 		}
@@ -2798,7 +2855,9 @@ func (vm *qemu) cleanup() {
 
 // cleanupDevices performs any needed device cleanup steps when instance is stopped.
 func (vm *qemu) cleanupDevices() {
-	for _, dev := range vm.expandedDevices.Sorted() {
+	// Stop the devices in the reverse order they were started in, so that nested mounts are
+	// unmounted before their parents.
+	for _, dev := range vm.expandedDevices.Reversed() {
 		// Use the device interface if device supports it.
 		err := vm.deviceStop(dev.Name, dev.Config)
 		if err == device.ErrUnsupportedDevType {
@@ -2809,6 +2868,40 @@ func (vm *qemu) cleanupDevices() {
 	}
 }
 
+// expandConfig expands the profiles config and resolves the config references.
+func (vm *qemu) expandConfig(profiles []api.Profile) error {
+	err := vm.common.expandConfig(profiles)
+	if err != nil {
+		return err
+	}
+
+	config, err := instance.InterpolateConfig(vm.expandedConfig, interpolationLookup(vm.project, vm.name))
+	if err != nil {
+		return err
+	}
+
+	vm.expandedConfig = config
+
+	return nil
+}
+
+// expandDevices expands the profiles devices and resolves the device config references.
+func (vm *qemu) expandDevices(profiles []api.Profile) error {
+	err := vm.common.expandDevices(profiles)
+	if err != nil {
+		return err
+	}
+
+	devices, err := instance.InterpolateDevices(vm.expandedDevices, vm.expandedConfig, interpolationLookup(vm.project, vm.name))
+	if err != nil {
+		return err
+	}
+
+	vm.expandedDevices = devices
+
+	return nil
+}
+
 func (vm *qemu) init() error {
 	// Compute the expanded config and device list.
 	err := vm.expandConfig(nil)
@@ -2877,13 +2970,15 @@ func (vm *qemu) Delete() error {
 
 	// Perform other cleanup steps if not snapshot.
 	if !vm.IsSnapshot() {
-		// Remove all backups.
+		// Remove all backups, newest first so that incremental backups are removed before
+		// the backups they're based upon.
 		backups, err := vm.Backups()
 		if err != nil {
 			return err
 		}
 
-		for _, backup := range backups {
+		for i := len(backups) - 1; i >= 0; i-- {
+			backup := backups[i]
 			err = backup.Delete()
 			if err != nil {
 				return err
@@ -3406,6 +3501,127 @@ func (vm *qemu) Console() (*os.File, chan error, error) {
 	return console, chDisconnect, nil
 }
 
+// ConsoleScreenshot writes a PNG screenshot of the instance's display.
+func (vm *qemu) ConsoleScreenshot(w io.Writer) error {
+	if !shared.IsTrue(vm.expandedConfig["console.display"]) {
+		return fmt.Errorf("The instance doesn't have a display, set console.display to add one")
+	}
+
+	// Connect to the monitor.
+	monitor, err := qmp.Connect(vm.getMonitorPath(), vm.getMonitorEventHandler())
+	if err != nil {
+		return err // The VM isn't running as no monitor socket available.
+	}
+
+	// QEMU is chrooted into the instance path and may not be running as root, so give it a
+	// directory of its own to write to in there.
+	dir, err := ioutil.TempDir(vm.Path(), "screenshot.")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	if vm.state.OS.UnprivUser != "" {
+		err = os.Chown(dir, vm.state.OS.UnprivUID, -1)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = monitor.Screendump(filepath.Join("/", filepath.Base(dir), "screen.ppm"))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(filepath.Join(dir, "screen.ppm"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	img, err := decodePPM(bufio.NewReader(f))
+	if err != nil {
+		return errors.Wrap(err, "Failed to decode screenshot")
+	}
+
+	return png.Encode(w, img)
+}
+
+// decodePPM decodes a binary PPM image, as written by QEMU's screendump command.
+func decodePPM(r *bufio.Reader) (image.Image, error) {
+	// Read the magic number, the width, the height and the maximum value of the header, each
+	// followed by a single whitespace and possibly preceded by comments.
+	fields := make([]int, 3)
+	for i := -1; i < len(fields); i++ {
+		var token []byte
+		for {
+			c, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+
+			if c == '#' && len(token) == 0 {
+				_, err := r.ReadString('\n')
+				if err != nil {
+					return nil, err
+				}
+
+				continue
+			}
+
+			if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+				if len(token) == 0 {
+					continue
+				}
+
+				break
+			}
+
+			token = append(token, c)
+		}
+
+		if i == -1 {
+			if string(token) != "P6" {
+				return nil, fmt.Errorf("Unsupported image format %q", string(token))
+			}
+
+			continue
+		}
+
+		value, err := strconv.Atoi(string(token))
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("Invalid image header")
+		}
+
+		fields[i] = value
+	}
+
+	width, height, maxValue := fields[0], fields[1], fields[2]
+	if maxValue > 255 {
+		return nil, fmt.Errorf("Unsupported image depth")
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	pixel := make([]byte, 3)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			_, err := io.ReadFull(r, pixel)
+			if err != nil {
+				return nil, err
+			}
+
+			img.Set(x, y, color.RGBA{
+				R: uint8(int(pixel[0]) * 255 / maxValue),
+				G: uint8(int(pixel[1]) * 255 / maxValue),
+				B: uint8(int(pixel[2]) * 255 / maxValue),
+				A: 255,
+			})
+		}
+	}
+
+	return img, nil
+}
+
 // Exec a command inside the instance.
 func (vm *qemu) Exec(req api.InstanceExecPost, stdin *os.File, stdout *os.File, stderr *os.File) (instance.Cmd, error) {
 	revert := revert.New()
@@ -3500,6 +3716,7 @@ func (vm *qemu) Render(options ...func(response interface{}) error) (interface{}
 		snapState.Ephemeral = vm.ephemeral
 		snapState.Profiles = vm.profiles
 		snapState.ExpiresAt = vm.expiryDate
+		snapState.Description = vm.description
 
 		for _, option := range options {
 			err := option(&snapState)
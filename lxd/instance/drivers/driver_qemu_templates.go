@@ -117,6 +117,22 @@ addr = "0x0"
 # Console
 [chardev "console"]
 backend = "pty"
+{{- if .display}}
+
+# Display, so that screenshots of the console can be taken
+[device "qemu_gpu"]
+{{- if eq .architecture "x86_64"}}
+driver = "virtio-vga"
+{{- else}}
+driver = "virtio-gpu-pci"
+{{- end}}
+{{- if eq .architecture "ppc64le"}}
+bus = "pci.0"
+{{- else}}
+bus = "pcie.0"
+addr = "0x1"
+{{- end}}
+{{- end}}
 `))
 
 var qemuMemory = template.Must(template.New("qemuMemory").Parse(`
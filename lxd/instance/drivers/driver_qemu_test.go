@@ -0,0 +1,48 @@
+package drivers
+
+import (
+	"bufio"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodePPM(t *testing.T) {
+	data := "P6\n# Created by QEMU\n2 1\n255\n" + "\xff\x00\x00" + "\x00\x80\xff"
+
+	img, err := decodePPM(bufio.NewReader(strings.NewReader(data)))
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, img.Bounds().Dx())
+	assert.Equal(t, 1, img.Bounds().Dy())
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, img.At(0, 0))
+	assert.Equal(t, color.RGBA{G: 128, B: 255, A: 255}, img.At(1, 0))
+}
+
+func TestDecodePPMScalesMaxValue(t *testing.T) {
+	data := "P6 1 1 15\n" + "\x0f\x00\x05"
+
+	img, err := decodePPM(bufio.NewReader(strings.NewReader(data)))
+	require.NoError(t, err)
+
+	assert.Equal(t, color.RGBA{R: 255, B: 85, A: 255}, img.At(0, 0))
+}
+
+func TestDecodePPMInvalid(t *testing.T) {
+	cases := map[string]string{
+		"plain format":   "P3\n1 1\n255\n255 0 0\n",
+		"zero width":     "P6\n0 1\n255\n",
+		"bad height":     "P6\n1 x\n255\n",
+		"16 bits":        "P6\n1 1\n65535\n\x00\x00\x00\x00\x00\x00",
+		"truncated data": "P6\n2 2\n255\n\x00\x00\x00",
+		"no header":      "",
+	}
+
+	for name, data := range cases {
+		_, err := decodePPM(bufio.NewReader(strings.NewReader(data)))
+		assert.Error(t, err, name)
+	}
+}
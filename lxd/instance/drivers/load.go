@@ -68,6 +68,11 @@ func validDevices(state *state.State, cluster *db.Cluster, instanceType instance
 	// Check each device individually using the device package.
 	// Use instConf.localDevices so that the cloned config is passed into the driver, so it cannot modify it.
 	for name, config := range instConf.localDevices {
+		// Devices referencing other variables are validated once expanded.
+		if !expanded && deviceHasReference(config) {
+			continue
+		}
+
 		err := device.Validate(instConf, state, name, config)
 		if err != nil {
 			return errors.Wrapf(err, "Device validation failed %q", name)
@@ -86,6 +91,17 @@ func validDevices(state *state.State, cluster *db.Cluster, instanceType instance
 	return nil
 }
 
+// deviceHasReference returns whether any of the device config values references a variable.
+func deviceHasReference(config deviceConfig.Device) bool {
+	for _, value := range config {
+		if instance.HasReference(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func create(s *state.State, args db.InstanceArgs) (instance.Instance, error) {
 	if args.Type == instancetype.Container {
 		return lxcCreate(s, args)
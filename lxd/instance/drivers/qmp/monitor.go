@@ -285,6 +285,31 @@ func (m *Monitor) AgentReady() bool {
 	return m.agentReady
 }
 
+// Screendump saves a screenshot of the VM's display in PPM format. The path is relative to the
+// root QEMU is running in.
+func (m *Monitor) Screendump(path string) error {
+	// Check if disconnected
+	if m.disconnected {
+		return ErrMonitorDisconnect
+	}
+
+	cmd, err := json.Marshal(map[string]interface{}{
+		"execute":   "screendump",
+		"arguments": map[string]string{"filename": path},
+	})
+	if err != nil {
+		return err
+	}
+
+	// The command fails if the display has no surface yet, which doesn't affect the monitor.
+	_, err = m.qmp.Run(cmd)
+	if err != nil {
+		return fmt.Errorf("Failed taking screenshot: %v", err)
+	}
+
+	return nil
+}
+
 // GetCPUs fetches the vCPU information for pinning.
 func (m *Monitor) GetCPUs() ([]int, error) {
 	// Check if disconnected
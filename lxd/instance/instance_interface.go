@@ -141,6 +141,20 @@ type Container interface {
 	NextIdmap() (*idmap.IdmapSet, error)
 	ConsoleLog(opts liblxc.ConsoleLogOptions) (string, error)
 	InsertSeccompUnixDevice(prefix string, m deviceConfig.Device, pid int) error
+	WatchInit()
+	MemoryOOMKills() (int64, error)
+	MemoryPressure() (float64, error)
+	MemoryWorkingSet() (int64, error)
+	MemoryLimitSet(limit int64) error
+	ShutdownWithSignal(timeout time.Duration, signal string) error
+	HostTimezoneSync() error
+}
+
+// VM interface is for VM specific functions.
+type VM interface {
+	Instance
+
+	ConsoleScreenshot(w io.Writer) error
 }
 
 // CriuMigrationArgs arguments for CRIU migration.
@@ -39,6 +39,10 @@ var Load func(s *state.State, args db.InstanceArgs, profiles []api.Profile) (Ins
 // Create is linked from instance/drivers.create to allow difference instance types to be created.
 var Create func(s *state.State, args db.InstanceArgs) (Instance, error)
 
+// SnapshotBeforeChange is set by LXD to snapshot an instance with snapshots.before_changes set
+// before the drivers make a destructive change to it, such as resetting its root filesystem.
+var SnapshotBeforeChange func(s *state.State, inst Instance, action string) error
+
 // CompareSnapshots returns a list of snapshots to sync to the target and a list of
 // snapshots to remove from the target. A snapshot will be marked as "to sync" if it either doesn't
 // exist in the target or its creation date is different to the source. A snapshot will be marked
@@ -117,6 +121,20 @@ func ValidConfig(sysOS *sys.OS, config map[string]string, profile bool, expanded
 			return fmt.Errorf("Image keys can only be set on instances")
 		}
 
+		if profile && k == "groups" {
+			return fmt.Errorf("Instance groups can only be set on instances")
+		}
+
+		// Values referencing other variables are validated once expanded.
+		if !expanded && HasReference(v) {
+			_, err := shared.ConfigKeyChecker(k)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
 		err := validConfigKey(sysOS, k, v)
 		if err != nil {
 			return err
@@ -137,6 +155,18 @@ func ValidConfig(sysOS *sys.OS, config map[string]string, profile bool, expanded
 		return fmt.Errorf("security.syscalls.whitelist is mutually exclusive with security.syscalls.blacklist*")
 	}
 
+	if config["security.capabilities.keep"] != "" && config["security.capabilities.drop"] != "" {
+		return fmt.Errorf("security.capabilities.keep is mutually exclusive with security.capabilities.drop")
+	}
+
+	if shared.IsTrue(config["security.readonly_rootfs"]) && shared.IsTrue(config["security.ephemeral_rootfs"]) {
+		return fmt.Errorf("security.readonly_rootfs is mutually exclusive with security.ephemeral_rootfs")
+	}
+
+	if config["linux.dev"] == "none" && config["linux.dev.size"] != "" {
+		return fmt.Errorf("linux.dev.size can't be set when /dev is provided by the image")
+	}
+
 	_, err := seccomp.SyscallInterceptMountFilter(config)
 	if err != nil {
 		return err
@@ -163,6 +193,27 @@ func ValidConfig(sysOS *sys.OS, config map[string]string, profile bool, expanded
 	return nil
 }
 
+// internalConfigKeys are the keys only ever set by LXD itself, which users can neither set, change
+// nor remove.
+var internalConfigKeys = []string{"volatile.network.quota_exceeded", "volatile.network.throttle", "volatile.warm_pool.template"}
+
+// KeepInternalConfig carries the internal keys of the current config over to the new config
+// requested by a user, failing if the request sets or changes any of them.
+func KeepInternalConfig(newConfig map[string]string, oldConfig map[string]string) error {
+	for _, key := range internalConfigKeys {
+		value, ok := newConfig[key]
+		if ok && value != oldConfig[key] {
+			return fmt.Errorf("Key %q can only be set by LXD", key)
+		}
+
+		if oldConfig[key] != "" {
+			newConfig[key] = oldConfig[key]
+		}
+	}
+
+	return nil
+}
+
 func validConfigKey(os *sys.OS, key string, value string) error {
 	f, err := shared.ConfigKeyChecker(key)
 	if err != nil {
@@ -585,6 +636,9 @@ func LoadNodeAll(s *state.State, instanceType instancetype.Type) ([]Instance, er
 }
 
 // DeleteSnapshots calls the Delete() function on each of the supplied instance's snapshots.
+// It stops at the first snapshot which fails to be deleted, so that the caller doesn't go on deleting the parent
+// instance and leave snapshot records behind. Snapshots already deleted stay deleted, so the operation can
+// simply be retried.
 func DeleteSnapshots(s *state.State, projectName, instanceName string) error {
 	results, err := s.Cluster.ContainerGetSnapshots(projectName, instanceName)
 	if err != nil {
@@ -595,11 +649,13 @@ func DeleteSnapshots(s *state.State, projectName, instanceName string) error {
 		snapInst, err := LoadByProjectAndName(s, projectName, snapName)
 		if err != nil {
 			logger.Error("DeleteSnapshots: Failed to load the snapshot", log.Ctx{"project": projectName, "instance": instanceName, "snapshot": snapName, "err": err})
-			continue
+			return errors.Wrapf(err, "Failed to load snapshot %q", snapName)
 		}
 
-		if err := snapInst.Delete(); err != nil {
+		err = snapInst.Delete()
+		if err != nil {
 			logger.Error("DeleteSnapshots: Failed to delete the snapshot", log.Ctx{"project": projectName, "instance": instanceName, "snapshot": snapName, "err": err})
+			return errors.Wrapf(err, "Failed to delete snapshot %q", snapName)
 		}
 	}
 
@@ -639,7 +695,7 @@ func BackupLoadByName(s *state.State, project, name string) (*backup.Backup, err
 		return nil, errors.Wrap(err, "Load instance from database")
 	}
 
-	return backup.New(s, instance, args.ID, name, args.CreationDate, args.ExpiryDate, args.InstanceOnly, args.OptimizedStorage), nil
+	return backup.New(s, instance, args.ID, name, args.CreationDate, args.ExpiryDate, args.InstanceOnly, args.OptimizedStorage, args.Parent), nil
 }
 
 // ResolveImage takes an instance source and returns a hash suitable for instance creation or download.
@@ -653,7 +709,7 @@ func ResolveImage(s *state.State, project string, source api.InstanceSource) (st
 			return source.Alias, nil
 		}
 
-		_, alias, err := s.Cluster.ImageAliasGet(project, source.Alias, true)
+		alias, err := s.Cluster.ImageAliasResolve(project, source.Alias, true)
 		if err != nil {
 			return "", err
 		}
@@ -0,0 +1,204 @@
+package instance
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/shared"
+)
+
+// ErrInvalidReference is returned when a config value references a variable which can't be resolved.
+var ErrInvalidReference = fmt.Errorf("Invalid config reference")
+
+// Namespaces of the variables which can be referenced from config values using ${namespace.name}.
+// References outside of those namespaces are left untouched, so that values such as shell scripts
+// in user keys keep working.
+var interpolationNamespaces = []string{"config.", "instance.", "pools."}
+
+// InterpolateConfig returns a copy of the config with all ${...} references resolved.
+//
+// References of the form ${config.<key>} are replaced by the (resolved) value of another key of the
+// same config, all other references are passed to the lookup function. When references.escape is
+// enabled, a literal "${" can be obtained with "$${". Volatile keys are never interpolated.
+func InterpolateConfig(config map[string]string, lookup func(name string) (string, bool)) (map[string]string, error) {
+	resolved := make(map[string]string, len(config))
+	unescape := shared.IsTrue(config["references.escape"])
+	resolving := map[string]bool{}
+
+	var resolve func(key string) (string, error)
+	resolve = func(key string) (string, error) {
+		value, ok := resolved[key]
+		if ok {
+			return value, nil
+		}
+
+		raw, ok := config[key]
+		if !ok {
+			return "", errors.Wrapf(ErrInvalidReference, "Config key %q doesn't exist", key)
+		}
+
+		if strings.HasPrefix(key, "volatile.") {
+			resolved[key] = raw
+			return raw, nil
+		}
+
+		if resolving[key] {
+			return "", errors.Wrapf(ErrInvalidReference, "Config key %q is part of a reference cycle", key)
+		}
+
+		resolving[key] = true
+		value, err := interpolateValue(raw, unescape, func(name string) (string, error) {
+			if strings.HasPrefix(name, "config.") {
+				return resolve(strings.TrimPrefix(name, "config."))
+			}
+
+			return interpolateLookup(name, lookup)
+		})
+		delete(resolving, key)
+		if err != nil {
+			return "", errors.Wrapf(err, "Config key %q", key)
+		}
+
+		resolved[key] = value
+		return value, nil
+	}
+
+	// Resolve in a stable order so that errors are reproducible.
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		_, err := resolve(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return resolved, nil
+}
+
+// InterpolateDevices returns a copy of the devices with all ${...} references in their config resolved.
+// The config passed in is expected to be already interpolated.
+func InterpolateDevices(devices deviceConfig.Devices, config map[string]string, lookup func(name string) (string, bool)) (deviceConfig.Devices, error) {
+	resolved := deviceConfig.Devices{}
+	unescape := shared.IsTrue(config["references.escape"])
+
+	for _, dev := range devices.Sorted() {
+		newDev := deviceConfig.Device{}
+		for key, raw := range dev.Config {
+			value, err := interpolateValue(raw, unescape, func(name string) (string, error) {
+				if strings.HasPrefix(name, "config.") {
+					value, ok := config[strings.TrimPrefix(name, "config.")]
+					if !ok {
+						return "", errors.Wrapf(ErrInvalidReference, "Config key %q doesn't exist", strings.TrimPrefix(name, "config."))
+					}
+
+					return value, nil
+				}
+
+				return interpolateLookup(name, lookup)
+			})
+			if err != nil {
+				return nil, errors.Wrapf(err, "Device %q key %q", dev.Name, key)
+			}
+
+			newDev[key] = value
+		}
+
+		resolved[dev.Name] = newDev
+	}
+
+	return resolved, nil
+}
+
+// HasReference returns whether the value references a variable. Such values can only be validated
+// once the references are resolved.
+func HasReference(value string) bool {
+	for i := strings.Index(value, "${"); i >= 0; i = strings.Index(value, "${") {
+		value = value[i+2:]
+		if interpolationKnown(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// interpolationKnown returns whether the name starts with one of the known namespaces.
+func interpolationKnown(name string) bool {
+	for _, namespace := range interpolationNamespaces {
+		if strings.HasPrefix(name, namespace) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// interpolateLookup resolves a non-config variable through the lookup function.
+func interpolateLookup(name string, lookup func(name string) (string, bool)) (string, error) {
+	if lookup != nil {
+		value, ok := lookup(name)
+		if ok {
+			return value, nil
+		}
+	}
+
+	return "", errors.Wrapf(ErrInvalidReference, "Unknown variable %q", name)
+}
+
+// interpolateValue replaces the references found in value using the resolve function. References
+// outside of the known namespaces are kept as-is, and "$${" is only turned into "${" with unescape.
+func interpolateValue(value string, unescape bool, resolve func(name string) (string, error)) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); {
+		rest := value[i:]
+
+		if unescape && strings.HasPrefix(rest, "$${") {
+			b.WriteString("${")
+			i += 3
+			continue
+		}
+
+		if !strings.HasPrefix(rest, "${") {
+			b.WriteByte(value[i])
+			i++
+			continue
+		}
+
+		end := strings.Index(rest, "}")
+		if end < 0 {
+			// Unterminated, keep the remainder as-is.
+			b.WriteString(rest)
+			break
+		}
+
+		name := rest[2:end]
+		if !interpolationKnown(name) {
+			b.WriteString(rest[:end+1])
+			i += end + 1
+			continue
+		}
+
+		resolved, err := resolve(name)
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(resolved)
+		i += end + 1
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,98 @@
+package instance
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+)
+
+func testInterpolationLookup(name string) (string, bool) {
+	switch name {
+	case "instance.name":
+		return "c1", true
+	case "pools.default.path":
+		return "/var/lib/lxd/storage-pools/default", true
+	}
+
+	return "", false
+}
+
+func TestInterpolateConfig(t *testing.T) {
+	config := map[string]string{
+		"user.base":     "/srv/${instance.name}",
+		"user.data":     "${config.user.base}/data",
+		"user.script":   "echo ${HOME} $${config.user.base}",
+		"user.pid":      "echo $${HOME}",
+		"volatile.test": "${config.missing}",
+	}
+
+	resolved, err := InterpolateConfig(config, testInterpolationLookup)
+	require.NoError(t, err)
+
+	assert.Equal(t, "/srv/c1", resolved["user.base"])
+	assert.Equal(t, "/srv/c1/data", resolved["user.data"])
+	assert.Equal(t, "echo ${HOME} $/srv/c1", resolved["user.script"])
+	assert.Equal(t, "echo $${HOME}", resolved["user.pid"])
+	assert.Equal(t, "${config.missing}", resolved["volatile.test"])
+
+	// The original config is left untouched.
+	assert.Equal(t, "${config.user.base}/data", config["user.data"])
+}
+
+// With references.escape, "$${" is a literal "${".
+func TestInterpolateConfig_Escape(t *testing.T) {
+	config := map[string]string{
+		"references.escape": "true",
+		"user.base":         "/srv",
+		"user.script":       "echo $${config.user.base} ${config.user.base}",
+	}
+
+	resolved, err := InterpolateConfig(config, testInterpolationLookup)
+	require.NoError(t, err)
+
+	assert.Equal(t, "echo ${config.user.base} /srv", resolved["user.script"])
+}
+
+func TestHasReference(t *testing.T) {
+	assert.True(t, HasReference("${config.user.mem}"))
+	assert.True(t, HasReference("${HOME}/${pools.default.path}"))
+	assert.False(t, HasReference("${HOME}"))
+	assert.False(t, HasReference("1GB"))
+}
+
+func TestInterpolateConfig_Errors(t *testing.T) {
+	cases := map[string]map[string]string{
+		"cycle":   {"user.a": "${config.user.b}", "user.b": "${config.user.a}"},
+		"self":    {"user.a": "x${config.user.a}"},
+		"missing": {"user.a": "${config.user.b}"},
+		"unknown": {"user.a": "${pools.default.size}"},
+	}
+
+	for name, config := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := InterpolateConfig(config, testInterpolationLookup)
+			assert.Equal(t, ErrInvalidReference, errors.Cause(err))
+		})
+	}
+}
+
+func TestInterpolateDevices(t *testing.T) {
+	devices := deviceConfig.Devices{
+		"shared": deviceConfig.Device{"type": "disk", "source": "${pools.default.path}/shared", "path": "${config.user.path}"},
+	}
+
+	resolved, err := InterpolateDevices(devices, map[string]string{"user.path": "/mnt"}, testInterpolationLookup)
+	require.NoError(t, err)
+
+	assert.Equal(t, "/var/lib/lxd/storage-pools/default/shared", resolved["shared"]["source"])
+	assert.Equal(t, "/mnt", resolved["shared"]["path"])
+	assert.Equal(t, "${pools.default.path}/shared", devices["shared"]["source"])
+
+	devices["shared"]["path"] = "${config.user.missing}"
+	_, err = InterpolateDevices(devices, map[string]string{}, testInterpolationLookup)
+	assert.Equal(t, ErrInvalidReference, errors.Cause(err))
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/device"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/instance/operationlock"
+	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// How long an incoming connection is held while its container starts.
+const proxyActivationTimeout = 60 * time.Second
+
+// Listen on the address of the proxy devices with activation enabled of all stopped containers.
+// This is only needed when LXD starts, the devices then hold their address whenever their container
+// stops.
+func proxyActivationSetup(s *state.State) {
+	device.ProxyActivate = proxyActivationHandle
+
+	insts, err := instance.LoadNodeAll(s, instancetype.Container)
+	if err != nil {
+		logger.Error("Failed to load containers for proxy activation", log.Ctx{"err": err})
+		return
+	}
+
+	for _, inst := range insts {
+		if inst.IsRunning() {
+			continue
+		}
+
+		for _, dev := range inst.ExpandedDevices().Sorted() {
+			if dev.Config["type"] != "proxy" || !shared.IsTrue(dev.Config["activation"]) {
+				continue
+			}
+
+			listenAddr, err := device.ProxyParseAddr(dev.Config["listen"])
+			if err != nil || len(listenAddr.Addr) != 1 {
+				continue
+			}
+
+			connectAddr, err := device.ProxyParseAddr(dev.Config["connect"])
+			if err != nil || len(connectAddr.Addr) == 0 {
+				continue
+			}
+
+			instanceID := inst.ID()
+			err = device.ProxyActivationListen(instanceID, dev.Name, listenAddr.Addr[0], func(conn net.Conn) {
+				proxyActivationHandle(s, instanceID, listenAddr.Addr[0], connectAddr.Addr[0], conn)
+			})
+			if err != nil {
+				logger.Warn("Failed to listen for proxy activation", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "device": dev.Name, "err": err})
+			}
+		}
+	}
+}
+
+// Handle a connection to the address of a stopped container, logging failures.
+func proxyActivationHandle(s *state.State, instanceID int, listenAddr string, connectAddr string, conn net.Conn) {
+	defer conn.Close()
+
+	err := proxyActivationForward(s, instanceID, listenAddr, connectAddr, conn)
+	if err != nil {
+		logger.Error("Failed to activate container", log.Ctx{"id": instanceID, "err": err})
+	}
+}
+
+// Start the container, wait for its service to listen and then relay the
+// connection through the proxy device.
+func proxyActivationForward(s *state.State, instanceID int, listenAddr string, connectAddr string, conn net.Conn) error {
+	inst, err := instance.LoadByID(s, instanceID)
+	if err != nil {
+		return err
+	}
+
+	if !inst.IsRunning() {
+		maintenance, err := node.Maintenance(s.Node)
+		if err != nil {
+			return err
+		}
+
+		if maintenance {
+			return fmt.Errorf("The server is in maintenance mode (core.maintenance), instances can't be started")
+		}
+
+		// Leave the container alone while it's being stopped, restored, migrated and so on.
+		// Start takes the operation lock itself, failing if another operation got it since.
+		// Several connections may race to start the container though.
+		op := operationlock.Get(inst.ID())
+		if op != nil && op.Action() != "start" {
+			return fmt.Errorf("Container is busy with a %s operation", op.Action())
+		}
+
+		if op == nil {
+			logger.Info("Starting container on incoming connection", log.Ctx{"project": inst.Project(), "instance": inst.Name()})
+
+			err = inst.Start(false)
+			if err != nil && !inst.IsRunning() {
+				return errors.Wrap(err, "Failed to start container")
+			}
+		}
+	}
+
+	_, port, err := net.SplitHostPort(connectAddr)
+	if err != nil {
+		return err
+	}
+
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		return err
+	}
+
+	// Wait for the service to listen inside the container and for the proxy
+	// device to be listening on the host.
+	var backend net.Conn
+	deadline := time.Now().Add(proxyActivationTimeout)
+	for {
+		if proxyActivationIsListening(inst.InitPID(), portNumber) {
+			backend, err = net.DialTimeout("tcp", proxyActivationDialAddr(listenAddr), time.Second)
+			if err == nil {
+				break
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Timed out waiting for the service on port %d", portNumber)
+		}
+
+		time.Sleep(250 * time.Millisecond)
+	}
+	defer backend.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(backend, conn)
+		done <- struct{}{}
+	}()
+
+	go func() {
+		io.Copy(conn, backend)
+		done <- struct{}{}
+	}()
+
+	<-done
+	return nil
+}
+
+// Return the address to connect to in order to reach a listener on addr.
+func proxyActivationDialAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	switch host {
+	case "0.0.0.0":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+// Return whether a tcp socket is listening on the given port in the network
+// namespace of the given process.
+func proxyActivationIsListening(pid int, port int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	for _, name := range []string{"tcp", "tcp6"} {
+		content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/net/%s", pid, name))
+		if err != nil {
+			continue
+		}
+
+		if procNetHasListener(string(content), port) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Return whether the given /proc/net/tcp content has a listening socket on
+// the given port.
+func procNetHasListener(content string, port int) bool {
+	for _, line := range strings.Split(content, "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+
+		// State 0A is TCP_LISTEN.
+		if fields[3] != "0A" {
+			continue
+		}
+
+		i := strings.LastIndex(fields[1], ":")
+		if i < 0 {
+			continue
+		}
+
+		localPort, err := strconv.ParseInt(fields[1][i+1:], 16, 32)
+		if err == nil && int(localPort) == port {
+			return true
+		}
+	}
+
+	return false
+}
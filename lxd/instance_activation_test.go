@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcNetHasListener(t *testing.T) {
+	content := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0050 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 21345 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 0100007F:B2A4 01 00000000:00000000 00:00000000 00000000     0        0 21346 1 0000000000000000 20 4 30 10 -1
+`
+
+	assert.True(t, procNetHasListener(content, 80))
+	assert.False(t, procNetHasListener(content, 8080))
+	assert.False(t, procNetHasListener(content, 22))
+}
+
+func TestProxyActivationDialAddr(t *testing.T) {
+	assert.Equal(t, "127.0.0.1:80", proxyActivationDialAddr("0.0.0.0:80"))
+	assert.Equal(t, "[::1]:80", proxyActivationDialAddr("[::]:80"))
+	assert.Equal(t, "10.0.0.1:80", proxyActivationDialAddr("10.0.0.1:80"))
+}
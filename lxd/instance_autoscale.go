@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lxc/lxd/lxd/cgroup"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/units"
+)
+
+// Interval at which the usage of instances with an autoscaling policy is sampled.
+const autoscaleCheckInterval = 30 * time.Second
+
+// Default value of autoscale.window, in minutes.
+const autoscaleDefaultWindow = 5
+
+// A limit is widened when usage stays above the high threshold (as a share of the limit) for the
+// whole window, and narrowed when it stays below the low one.
+const (
+	autoscaleHighThreshold = 0.9
+	autoscaleLowThreshold  = 0.5
+)
+
+// Usage of an instance at the time of the last check, along with since when each of its limits
+// has been continuously under or over used.
+type autoscaleSample struct {
+	time time.Time
+	cpu  int64
+
+	memoryHigh time.Time
+	memoryLow  time.Time
+	cpuHigh    time.Time
+	cpuLow     time.Time
+}
+
+// Samples of the instances with an autoscaling policy, keyed by project and name.
+// Only accessed by the autoscale check task.
+var autoscaleSamples = map[string]*autoscaleSample{}
+
+func autoscaleCheckTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		autoscaleCheck(d.State(), time.Now())
+	}
+
+	return f, task.Every(autoscaleCheckInterval)
+}
+
+// Widen or narrow limits.memory and limits.cpu of the running containers with an autoscaling
+// policy, within their configured bounds, based on their sustained usage.
+func autoscaleCheck(s *state.State, now time.Time) {
+	insts, err := instance.LoadNodeAll(s, instancetype.Container)
+	if err != nil {
+		logger.Error("Failed to load containers for autoscaling", log.Ctx{"err": err})
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, inst := range insts {
+		config := autoscaleConfig(inst.ExpandedConfig())
+		scaleMemory := config["autoscale.memory.min"] != "" && config["autoscale.memory.max"] != ""
+		scaleCPU := config["autoscale.cpu.min"] != "" && config["autoscale.cpu.max"] != ""
+		if (!scaleMemory && !scaleCPU) || !inst.IsRunning() || inst.IsFrozen() {
+			continue
+		}
+
+		key := project.Instance(inst.Project(), inst.Name())
+		seen[key] = true
+
+		instState, err := inst.RenderState()
+		if err != nil {
+			logger.Warn("Failed to get container state for autoscaling", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "err": err})
+			continue
+		}
+
+		current := &autoscaleSample{time: now, cpu: instState.CPU.Usage}
+		previous, ok := autoscaleSamples[key]
+		autoscaleSamples[key] = current
+		if !ok {
+			current.memoryHigh, current.memoryLow, current.cpuHigh, current.cpuLow = now, now, now, now
+			continue
+		}
+
+		window, err := strconv.Atoi(config["autoscale.window"])
+		if err != nil {
+			window = autoscaleDefaultWindow
+		}
+
+		sustained := func(since time.Time) bool {
+			return now.Sub(since) >= time.Duration(window)*time.Minute
+		}
+
+		changes := map[string]string{}
+
+		if scaleMemory {
+			// Leave out the page cache, which the kernel reclaims before hitting the limit.
+			usage, err := inst.(instance.Container).MemoryWorkingSet()
+			if err != nil {
+				usage = instState.Memory.Usage
+			}
+
+			value := autoscaleMemory(config, usage, previous, current, sustained)
+			if value != "" && value != config["limits.memory"] {
+				changes["limits.memory"] = value
+				current.memoryHigh, current.memoryLow = now, now
+			}
+		}
+
+		if scaleCPU {
+			used := float64(current.cpu-previous.cpu) / float64(now.Sub(previous.time).Nanoseconds())
+			value := autoscaleCPU(config, used, previous, current, sustained)
+			if value != "" && value != config["limits.cpu"] {
+				changes["limits.cpu"] = value
+				current.cpuHigh, current.cpuLow = now, now
+			}
+		}
+
+		if len(changes) == 0 {
+			continue
+		}
+
+		err = autoscaleApply(s, inst.(instance.Container), changes)
+		if err != nil {
+			logger.Error("Failed to autoscale container", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "err": err})
+		}
+	}
+
+	for key := range autoscaleSamples {
+		if !seen[key] {
+			delete(autoscaleSamples, key)
+		}
+	}
+}
+
+// autoscaleConfig returns the given configuration with the limits replaced by the ones set by the
+// autoscaling policy, if any.
+func autoscaleConfig(expanded map[string]string) map[string]string {
+	config := map[string]string{}
+	for key, value := range expanded {
+		config[key] = value
+	}
+
+	for _, key := range []string{"limits.cpu", "limits.memory"} {
+		value := expanded[fmt.Sprintf("volatile.autoscale.%s", strings.TrimPrefix(key, "limits."))]
+		if value != "" {
+			config[key] = value
+		}
+	}
+
+	return config
+}
+
+// autoscaleMemory tracks the memory usage against limits.memory and returns the new value of
+// limits.memory once usage has been too high or too low for long enough.
+func autoscaleMemory(config map[string]string, usage int64, previous *autoscaleSample, current *autoscaleSample, sustained func(time.Time) bool) string {
+	min, err := units.ParseByteSizeString(config["autoscale.memory.min"])
+	if err != nil {
+		return ""
+	}
+
+	max, err := units.ParseByteSizeString(config["autoscale.memory.max"])
+	if err != nil || max < min {
+		return ""
+	}
+
+	// Start from the upper bound if there's no limit yet, percentages aren't supported.
+	limit := max
+	if config["limits.memory"] != "" {
+		limit, err = units.ParseByteSizeString(config["limits.memory"])
+		if err != nil {
+			return ""
+		}
+	}
+
+	current.memoryHigh, current.memoryLow = previous.memoryHigh, previous.memoryLow
+	if float64(usage) <= autoscaleHighThreshold*float64(limit) {
+		current.memoryHigh = current.time
+	}
+
+	if float64(usage) >= autoscaleLowThreshold*float64(limit) {
+		current.memoryLow = current.time
+	}
+
+	target := limit
+	if limit < min {
+		target = min
+	} else if limit > max {
+		target = max
+	} else if sustained(current.memoryHigh) {
+		target = limit + limit/4
+	} else if sustained(current.memoryLow) {
+		target = limit - limit/5
+	}
+
+	if target > max {
+		target = max
+	}
+
+	if target < min {
+		target = min
+	}
+
+	if target == limit && config["limits.memory"] != "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%dB", target)
+}
+
+// autoscaleCPU tracks the CPU usage (in number of CPUs) against limits.cpu and returns the new
+// value of limits.cpu once usage has been too high or too low for long enough. Only limits given
+// as a number of CPUs are scaled, not sets of pinned CPUs.
+func autoscaleCPU(config map[string]string, used float64, previous *autoscaleSample, current *autoscaleSample, sustained func(time.Time) bool) string {
+	min, err := strconv.Atoi(config["autoscale.cpu.min"])
+	if err != nil || min < 1 {
+		return ""
+	}
+
+	max, err := strconv.Atoi(config["autoscale.cpu.max"])
+	if err != nil || max < min {
+		return ""
+	}
+
+	limit := max
+	if config["limits.cpu"] != "" {
+		limit, err = strconv.Atoi(config["limits.cpu"])
+		if err != nil {
+			return ""
+		}
+	}
+
+	current.cpuHigh, current.cpuLow = previous.cpuHigh, previous.cpuLow
+	if used <= autoscaleHighThreshold*float64(limit) {
+		current.cpuHigh = current.time
+	}
+
+	if used >= autoscaleLowThreshold*float64(limit-1) || limit == 1 {
+		current.cpuLow = current.time
+	}
+
+	target := limit
+	if limit < min {
+		target = min
+	} else if limit > max {
+		target = max
+	} else if sustained(current.cpuHigh) && limit < max {
+		target = limit + 1
+	} else if sustained(current.cpuLow) && limit > min {
+		target = limit - 1
+	}
+
+	if target == limit && config["limits.cpu"] != "" {
+		return ""
+	}
+
+	return strconv.Itoa(target)
+}
+
+// autoscaleApply applies the given limits to the running container and records them in its
+// volatile.autoscale.* keys, leaving its configuration untouched, then emits an event for each of
+// them. The limits must fit within the ones of the project, as if they were configured.
+func autoscaleApply(s *state.State, inst instance.Container, changes map[string]string) error {
+	previous := autoscaleConfig(inst.ExpandedConfig())
+	config := map[string]string{}
+	for key, value := range inst.LocalConfig() {
+		config[key] = value
+	}
+
+	for key, value := range changes {
+		config[key] = value
+	}
+
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		req := api.InstancePut{
+			Config:   config,
+			Devices:  inst.LocalDevices().CloneNative(),
+			Profiles: inst.Profiles(),
+		}
+
+		return project.AllowInstanceUpdate(tx, inst.Project(), inst.Name(), req, inst.LocalConfig())
+	})
+	if err != nil {
+		return err
+	}
+
+	volatile := map[string]string{}
+	for key, value := range changes {
+		// CPUs are pinned by the balancing task, which picks up volatile.autoscale.cpu.
+		if key == "limits.memory" {
+			limit, err := units.ParseByteSizeString(value)
+			if err != nil {
+				return err
+			}
+
+			err = inst.MemoryLimitSet(limit)
+			if err != nil {
+				return err
+			}
+		}
+
+		volatile[fmt.Sprintf("volatile.autoscale.%s", strings.TrimPrefix(key, "limits."))] = value
+	}
+
+	err = inst.VolatileSet(volatile)
+	if err != nil {
+		return err
+	}
+
+	if changes["limits.cpu"] != "" {
+		cgroup.TaskSchedulerTrigger("container", inst.Name(), "changed")
+	}
+
+	for key, value := range changes {
+		logger.Info("Autoscaled container", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "key": key, "old": previous[key], "new": value})
+		s.Events.SendLifecycle(inst.Project(), "container-autoscaled",
+			fmt.Sprintf("/1.0/containers/%s", inst.Name()), map[string]interface{}{"key": key, "old": previous[key], "new": value})
+	}
+
+	return nil
+}
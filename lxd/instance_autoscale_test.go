@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoscaleMemory(t *testing.T) {
+	now := time.Now()
+	start := now.Add(-10 * time.Minute)
+	sustained := func(since time.Time) bool {
+		return now.Sub(since) >= 5*time.Minute
+	}
+
+	config := map[string]string{
+		"autoscale.memory.min": "512MiB",
+		"autoscale.memory.max": "2GiB",
+		"limits.memory":        "1GiB",
+	}
+
+	cases := []struct {
+		name  string
+		usage int64
+		limit string
+		value string
+	}{
+		{"high", 1000 * 1024 * 1024, "1GiB", "1342177280B"},
+		{"low", 100 * 1024 * 1024, "1GiB", "858993460B"},
+		{"steady", 700 * 1024 * 1024, "1GiB", ""},
+		{"capped", 2000 * 1024 * 1024, "1800MiB", "2147483648B"},
+		{"outside", 700 * 1024 * 1024, "4GiB", "2147483648B"},
+	}
+
+	for _, c := range cases {
+		config["limits.memory"] = c.limit
+		previous := &autoscaleSample{memoryHigh: start, memoryLow: start}
+		current := &autoscaleSample{time: now}
+		assert.Equal(t, c.value, autoscaleMemory(config, c.usage, previous, current, sustained), c.name)
+	}
+}
+
+func TestAutoscaleCPU(t *testing.T) {
+	now := time.Now()
+	sustained := func(since time.Time) bool {
+		return now.Sub(since) >= 5*time.Minute
+	}
+
+	config := map[string]string{
+		"autoscale.cpu.min": "1",
+		"autoscale.cpu.max": "4",
+	}
+
+	cases := []struct {
+		name  string
+		used  float64
+		limit string
+		since time.Time
+		value string
+	}{
+		{"high", 1.95, "2", now.Add(-10 * time.Minute), "3"},
+		{"recent", 1.95, "2", now.Add(-time.Minute), ""},
+		{"low", 0.1, "2", now.Add(-10 * time.Minute), "1"},
+		{"max", 3.9, "4", now.Add(-10 * time.Minute), ""},
+		{"unset", 1.0, "", now.Add(-10 * time.Minute), "4"},
+		{"pinned", 0.1, "0-1", now.Add(-10 * time.Minute), ""},
+	}
+
+	for _, c := range cases {
+		config["limits.cpu"] = c.limit
+		previous := &autoscaleSample{cpuHigh: c.since, cpuLow: c.since}
+		current := &autoscaleSample{time: now}
+		assert.Equal(t, c.value, autoscaleCPU(config, c.used, previous, current, sustained), c.name)
+	}
+}
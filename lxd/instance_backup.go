@@ -4,20 +4,25 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os/exec"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 
+	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/response"
+	storagePools "github.com/lxc/lxd/lxd/storage"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/version"
 )
 
@@ -159,7 +164,32 @@ func containerBackupsPost(d *Daemon, r *http.Request) response.Response {
 	fullName := name + shared.SnapshotDelimiter + req.Name
 	instanceOnly := req.InstanceOnly || req.ContainerOnly
 
+	// Incremental backups are based upon another backup of the instance.
+	parentName := ""
+	if req.Parent != "" {
+		if req.OptimizedStorage {
+			return response.BadRequest(fmt.Errorf("Incremental backups can't use optimized storage"))
+		}
+
+		parentName = name + shared.SnapshotDelimiter + req.Parent
+		_, err := instance.BackupLoadByName(d.State(), project, parentName)
+		if err != nil {
+			return response.SmartError(err)
+		}
+	}
+
+	// Heavy backups can be deferred to a later time or to the off-peak hours.
+	deferral, err := newOperationDeferral(d.State(), req.NotBefore, req.OffPeak)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
 	backup := func(op *operations.Operation) error {
+		err := deferral.Wait(d.State(), op)
+		if err != nil {
+			return err
+		}
+
 		args := db.InstanceBackupArgs{
 			Name:                 fullName,
 			InstanceID:           inst.ID(),
@@ -168,9 +198,10 @@ func containerBackupsPost(d *Daemon, r *http.Request) response.Response {
 			InstanceOnly:         instanceOnly,
 			OptimizedStorage:     req.OptimizedStorage,
 			CompressionAlgorithm: req.CompressionAlgorithm,
+			Parent:               parentName,
 		}
 
-		err := backupCreate(d.State(), args, inst)
+		err = backupCreate(d.State(), args, inst)
 		if err != nil {
 			return errors.Wrap(err, "Create backup")
 		}
@@ -184,7 +215,7 @@ func containerBackupsPost(d *Daemon, r *http.Request) response.Response {
 	resources["backups"] = []string{req.Name}
 
 	op, err := operations.OperationCreate(d.State(), project, operations.OperationClassTask,
-		db.OperationBackupCreate, resources, nil, backup, nil, nil)
+		db.OperationBackupCreate, resources, nil, backup, deferral.OnCancel(), nil)
 	if err != nil {
 		return response.InternalError(err)
 	}
@@ -350,9 +381,111 @@ func containerBackupExportGet(d *Daemon, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
+	// Incremental backups are merged with the backups they're based upon into a full backup.
+	if backup.Parent() != "" {
+		compress, err := cluster.ConfigGetString(d.cluster, "backups.compression_algorithm")
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		// Squashfs archives can't be written to a stream.
+		if compress == "squashfs" {
+			compress = "gzip"
+		}
+
+		return response.ManualResponse(func(w http.ResponseWriter) error {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Trailer", "X-LXD-Error")
+			w.WriteHeader(http.StatusOK)
+
+			err := backupWriteMerged(d.State(), proj, backup, compress, w)
+			if err != nil {
+				logger.Error("Failed to merge incremental backup", log.Ctx{"project": proj, "backup": backup.Name(), "err": err})
+				w.Header().Set("X-LXD-Error", err.Error())
+			}
+
+			return nil
+		})
+	}
+
 	ent := response.FileResponseEntry{
 		Path: shared.VarPath("backups", project.Instance(proj, backup.Name())),
 	}
 
 	return response.FileResponse(r, []response.FileResponseEntry{ent}, nil, false)
 }
+
+// Stream the backup tarball of an instance straight to the client, without storing it on the
+// server first.
+func containerExportGet(d *Daemon, r *http.Request) response.Response {
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	proj := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, proj, name, instanceType)
+	if err != nil {
+		return response.SmartError(err)
+	}
+	if resp != nil {
+		return resp
+	}
+
+	inst, err := instance.LoadByProjectAndName(d.State(), proj, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// The algorithm is the name of the command run to compress the tarball, only allow the usual
+	// ones from clients. Squashfs archives can't be written to a stream.
+	compress := queryParam(r, "compression")
+	if compress != "" && !shared.StringInSlice(compress, []string{"gzip", "bzip2", "xz", "lzma", "zstd", "none"}) {
+		return response.BadRequest(fmt.Errorf("Unsupported compression algorithm %q", compress))
+	}
+
+	if compress == "" {
+		compress, err = cluster.ConfigGetString(d.cluster, "backups.compression_algorithm")
+		if err != nil {
+			return response.SmartError(err)
+		}
+	}
+
+	if compress == "squashfs" {
+		return response.BadRequest(fmt.Errorf("Exports can't be compressed with squashfs"))
+	}
+
+	if compress != "none" {
+		_, err = exec.LookPath(compress)
+		if err != nil {
+			return response.BadRequest(fmt.Errorf("Unsupported compression algorithm %q", compress))
+		}
+	}
+
+	pool, err := storagePools.GetPoolByInstance(d.State(), inst)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	instanceOnly := shared.IsTrue(queryParam(r, "instance-only"))
+	optimized := shared.IsTrue(queryParam(r, "optimized-storage")) && pool.Driver().Info().OptimizedBackups
+
+	// Errors happening once the tarball started being sent are reported in a trailer, as the
+	// status code can't be changed anymore.
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Trailer", "X-LXD-Error")
+		w.WriteHeader(http.StatusOK)
+
+		err := backupWriteTarball(inst, pool, optimized, !instanceOnly, nil, compress, w)
+		if err != nil {
+			logger.Error("Failed to stream instance export", log.Ctx{"project": proj, "instance": name, "err": err})
+			w.Header().Set("X-LXD-Error", err.Error())
+		}
+
+		return nil
+	})
+}
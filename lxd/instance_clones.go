@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/operations"
+	projecthelpers "github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/revert"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/osarch"
+)
+
+// Maximum number of copies created by a single request.
+const instanceClonesMax = 100
+
+// Create several copies of an instance, using storage level copies where the pool supports them.
+func containerClonesPost(d *Daemon, r *http.Request) response.Response {
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to an instance on a different node
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, project, name, instanceType)
+	if err != nil {
+		return response.SmartError(err)
+	}
+	if resp != nil {
+		return resp
+	}
+
+	source, err := instance.LoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	req := api.InstanceClonesPost{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if req.Count < 1 {
+		return response.BadRequest(fmt.Errorf("At least one copy must be requested"))
+	}
+
+	if req.Count > instanceClonesMax {
+		return response.BadRequest(fmt.Errorf("At most %d copies can be requested at once", instanceClonesMax))
+	}
+
+	if req.Count > 1 && !strings.Contains(req.Name, "{index}") {
+		return response.BadRequest(fmt.Errorf(`The name pattern must contain "{index}" when creating several copies`))
+	}
+
+	names := make([]string, req.Count)
+	for i := range names {
+		names[i] = instanceCloneExpand(req.Name, i+1, "")
+
+		err := instance.ValidName(names[i], false)
+		if err != nil {
+			return response.BadRequest(err)
+		}
+	}
+
+	for cloneName := range req.Overrides {
+		if !shared.StringInSlice(cloneName, names) {
+			return response.BadRequest(fmt.Errorf("Config override for unknown copy %q", cloneName))
+		}
+	}
+
+	// Check for conflicts up front rather than failing half way through.
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		existing, err := tx.InstanceNames(project)
+		if err != nil {
+			return err
+		}
+
+		for _, cloneName := range names {
+			if shared.StringInSlice(cloneName, existing) {
+				return fmt.Errorf("Instance %q already exists", cloneName)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	// Don't create instances on a node being drained.
+	resp = maintenanceCheck(d)
+	if resp != nil {
+		return resp
+	}
+
+	// Let the admission scriptlet rewrite or reject each copy, like any other instance creation.
+	username, _ := r.Context().Value("username").(string)
+	protocol, _ := r.Context().Value("protocol").(string)
+	cloneArgs := make([]db.InstanceArgs, len(names))
+	for i, cloneName := range names {
+		cloneArgs[i] = instanceCloneArgs(source, req, i+1, cloneName)
+
+		err := instanceCloneAdmission(d, username, protocol, source, &cloneArgs[i])
+		if err != nil {
+			return response.BadRequest(err)
+		}
+	}
+
+	run := func(op *operations.Operation) error {
+		revert := revert.New()
+		defer revert.Fail()
+
+		for i, cloneName := range names {
+			args := cloneArgs[i]
+
+			// Check the project limits as each copy gets created.
+			err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+				return projecthelpers.AllowInstanceCreation(tx, project, api.InstancesPost{
+					InstancePut: api.InstancePut{
+						Config:   args.Config,
+						Devices:  args.Devices.CloneNative(),
+						Profiles: args.Profiles,
+					},
+					Name: cloneName,
+					Type: api.InstanceType(args.Type.String()),
+				})
+			})
+			if err != nil {
+				return err
+			}
+
+			inst, err := instanceCreateAsCopy(d.State(), args, source, req.InstanceOnly, false, op)
+			if err != nil {
+				return err
+			}
+
+			revert.Add(func() { inst.Delete() })
+
+			op.UpdateMetadata(map[string]interface{}{"clones_created": fmt.Sprintf("%d/%d", i+1, len(names))})
+		}
+
+		revert.Success()
+		return nil
+	}
+
+	resources := map[string][]string{}
+	resources["instances"] = append([]string{name}, names...)
+	resources["containers"] = resources["instances"] // Populate old field name.
+
+	op, err := operations.OperationCreate(d.State(), project, operations.OperationClassTask, db.OperationContainerCreate, resources, nil, run, nil, nil)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	return operations.OperationResponse(op)
+}
+
+// instanceCloneArgs returns the arguments to create the copy of the given source instance with the
+// given index and name. Like for regular copies, the volatile keys aren't carried over apart from the
+// base image and the on-disk idmap.
+func instanceCloneArgs(source instance.Instance, req api.InstanceClonesPost, index int, name string) db.InstanceArgs {
+	config := map[string]string{}
+	for key, value := range source.LocalConfig() {
+		if strings.HasPrefix(key, "volatile.") && !shared.StringInSlice(key, []string{"volatile.base_image", "volatile.base_image.alias", "volatile.last_state.idmap"}) {
+			continue
+		}
+
+		config[key] = value
+	}
+
+	for key, value := range req.Config {
+		config[key] = instanceCloneExpand(value, index, name)
+	}
+
+	for key, value := range req.Overrides[name] {
+		config[key] = value
+	}
+
+	return db.InstanceArgs{
+		Project:      source.Project(),
+		Architecture: source.Architecture(),
+		BaseImage:    config["volatile.base_image"],
+		Config:       config,
+		Type:         source.Type(),
+		Description:  source.Description(),
+		Devices:      deviceConfig.NewDevices(source.LocalDevices().CloneNative()),
+		Name:         name,
+		Profiles:     source.Profiles(),
+	}
+}
+
+// instanceCloneAdmission runs the admission scriptlet against the creation of a copy, applying the
+// changes it makes to the copy's arguments. Copies are created on the member hosting their source, so
+// the scriptlet can't place them elsewhere.
+func instanceCloneAdmission(d *Daemon, username string, protocol string, source instance.Instance, args *db.InstanceArgs) error {
+	architecture, err := osarch.ArchitectureName(source.Architecture())
+	if err != nil {
+		return err
+	}
+
+	req := api.InstancesPost{
+		InstancePut: api.InstancePut{
+			Architecture: architecture,
+			Config:       args.Config,
+			Devices:      args.Devices.CloneNative(),
+			Profiles:     args.Profiles,
+			Description:  args.Description,
+		},
+		Name: args.Name,
+		Type: api.InstanceType(args.Type.String()),
+		Source: api.InstanceSource{
+			Type:    "copy",
+			Source:  source.Name(),
+			Project: source.Project(),
+		},
+	}
+
+	target, err := instanceAdmissionAs(d, username, protocol, args.Project, "", &req)
+	if err != nil {
+		return err
+	}
+
+	if target != "" {
+		var localName string
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			var err error
+			localName, err = tx.NodeName()
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		if target != localName {
+			return fmt.Errorf("Admission scriptlet can't place copy %q on another cluster member", args.Name)
+		}
+	}
+
+	args.Config = req.Config
+	args.Devices = deviceConfig.NewDevices(req.Devices)
+	args.Profiles = req.Profiles
+	args.Description = req.Description
+
+	return nil
+}
+
+// instanceCloneExpand replaces the "{index}" and "{name}" placeholders in the given value.
+func instanceCloneExpand(value string, index int, name string) string {
+	return strings.NewReplacer("{index}", strconv.Itoa(index), "{name}", name).Replace(value)
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstanceCloneExpand(t *testing.T) {
+	assert.Equal(t, "student-3", instanceCloneExpand("student-{index}", 3, ""))
+	assert.Equal(t, "seat 3 of lab-3", instanceCloneExpand("seat {index} of {name}", 3, "lab-3"))
+	assert.Equal(t, "fixed", instanceCloneExpand("fixed", 1, "c1"))
+}
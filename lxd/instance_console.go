@@ -1,8 +1,10 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -158,9 +160,8 @@ func (s *consoleWs) Do(op *operations.Operation) error {
 				break
 			}
 
-			command := api.InstanceConsoleControl{}
-
-			err = json.Unmarshal(buf, &command)
+			// Accept the same control messages as exec, including numeric window sizes.
+			command, err := execControlUnmarshal(buf)
 			if err != nil {
 				logger.Debugf("Failed to unmarshal control socket command: %s", err)
 				continue
@@ -357,8 +358,13 @@ func containerConsoleLogGet(d *Daemon, r *http.Request) response.Response {
 	c := inst.(instance.Container)
 	ent := response.FileResponseEntry{}
 	if !c.IsRunning() {
-		// Hand back the contents of the console ringbuffer logfile.
+		// Hand back the contents of the console ringbuffer logfile, if the container ever ran.
 		consoleBufferLogPath := c.ConsoleBufferLogPath()
+		if !shared.PathExists(consoleBufferLogPath) {
+			ent.Filename = "console.log"
+			return response.FileResponse(r, []response.FileResponseEntry{ent}, nil, false)
+		}
+
 		ent.Path = consoleBufferLogPath
 		ent.Filename = consoleBufferLogPath
 		return response.FileResponse(r, []response.FileResponseEntry{ent}, nil, false)
@@ -391,6 +397,57 @@ func containerConsoleLogGet(d *Daemon, r *http.Request) response.Response {
 	return response.FileResponse(r, []response.FileResponseEntry{ent}, nil, false)
 }
 
+func instanceConsoleScreenshotGet(d *Daemon, r *http.Request) response.Response {
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Forward the request if the instance is remote.
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, project, name, instanceType)
+	if err != nil {
+		return response.SmartError(err)
+	}
+	if resp != nil {
+		return resp
+	}
+
+	inst, err := instance.LoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if inst.Type() != instancetype.VM {
+		return response.BadRequest(fmt.Errorf("Screenshots are only supported for virtual machines"))
+	}
+
+	if !shared.IsTrue(inst.ExpandedConfig()["console.display"]) {
+		return response.BadRequest(fmt.Errorf("The instance doesn't have a display, set console.display to add one"))
+	}
+
+	if !inst.IsRunning() {
+		return response.BadRequest(fmt.Errorf("Instance is not running"))
+	}
+
+	// Take the screenshot before sending anything, so that errors can still be reported.
+	buf := &bytes.Buffer{}
+	err = inst.(instance.VM).ConsoleScreenshot(buf)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", buf.Len()))
+
+		_, err := io.Copy(w, buf)
+		return err
+	})
+}
+
 func containerConsoleLogDelete(d *Daemon, r *http.Request) response.Response {
 	if !util.RuntimeLiblxcVersionAtLeast(3, 0, 0) {
 		return response.BadRequest(fmt.Errorf("Clearing the console buffer requires liblxc >= 3.0"))
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+const coreDumpPatternPath = "/proc/sys/kernel/core_pattern"
+
+// containersWatchInit records how the init process of the running containers exits.
+func containersWatchInit(s *state.State) {
+	insts, err := instance.LoadNodeAll(s, instancetype.Container)
+	if err != nil {
+		logger.Error("Failed to load containers to watch", log.Ctx{"err": err})
+		return
+	}
+
+	for _, inst := range insts {
+		if !inst.IsRunning() {
+			continue
+		}
+
+		inst.(instance.Container).WatchInit()
+	}
+}
+
+// coreDumpsSetup points the kernel's core dump pattern to forkcoredump when enabled, so that the
+// core dumps of container processes end up in the container's log directory while the others are
+// passed on to the previous pattern. The previous pattern is saved and restored once disabled.
+func coreDumpsSetup(execPath string, enabled bool) error {
+	content, err := ioutil.ReadFile(coreDumpPatternPath)
+	if err != nil {
+		return err
+	}
+
+	current := strings.TrimSpace(string(content))
+	ours := strings.Contains(current, " forkcoredump ")
+	savedPath := shared.VarPath("core_pattern.orig")
+
+	if enabled {
+		if !ours {
+			err = ioutil.WriteFile(savedPath, []byte(current), 0600)
+			if err != nil {
+				return err
+			}
+		}
+
+		// The helper runs outside of our environment, tell it where LXD is along with what it
+		// needs to pass the dumps of other processes on to the previous pattern.
+		lxdDir := os.Getenv("LXD_DIR")
+		if lxdDir == "" {
+			lxdDir = "-"
+		}
+
+		pattern := fmt.Sprintf("|%s forkcoredump %s", execPath, lxdDir)
+		for _, specifier := range coreDumpSpecifiers {
+			pattern += fmt.Sprintf(" %%%s", specifier)
+		}
+
+		// The kernel truncates longer patterns.
+		if len(pattern) > 127 {
+			return fmt.Errorf("Core dump pattern %q is too long", pattern)
+		}
+
+		return ioutil.WriteFile(coreDumpPatternPath, []byte(pattern), 0644)
+	}
+
+	if !ours {
+		return nil
+	}
+
+	saved, err := ioutil.ReadFile(savedPath)
+	if err != nil || len(saved) == 0 {
+		saved = []byte("core")
+	}
+
+	err = ioutil.WriteFile(coreDumpPatternPath, saved, 0644)
+	if err != nil {
+		return err
+	}
+
+	os.Remove(savedPath)
+	return nil
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -21,13 +23,16 @@ import (
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/idmap"
 	log "github.com/lxc/lxd/shared/log15"
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/logging"
 	"github.com/lxc/lxd/shared/netutils"
 	"github.com/lxc/lxd/shared/version"
+	"github.com/pkg/errors"
 )
 
 type execWs struct {
@@ -206,7 +211,69 @@ func (s *execWs) Do(op *operations.Operation) error {
 	logger.Debug("Instance process started")
 
 	// Now that process has started, we can start the mirroring of the process channels and websockets.
-	if s.req.Interactive {
+	if s.req.Interactive && s.req.Framing == "terminal" {
+		wgEOF.Add(1)
+		go func() {
+			s.connsLock.Lock()
+			conn := s.conns[0]
+			s.connsLock.Unlock()
+
+			logger.Debug("Started mirroring terminal websocket")
+			defer logger.Debug("Finished mirroring terminal websocket")
+
+			// Binary messages are input, text messages may also be control messages.
+			go func() {
+				for {
+					mt, r, err := conn.NextReader()
+					if err != nil {
+						er, ok := err.(*websocket.CloseError)
+						if ok && er.Code == websocket.CloseAbnormalClosure {
+							// If an abnormal closure occurred, kill the attached child.
+							err := cmd.Signal(unix.SIGKILL)
+							if err != nil {
+								logger.Debug("Failed to send SIGKILL signal", log.Ctx{"err": err})
+							}
+						}
+
+						return
+					}
+
+					buf, err := ioutil.ReadAll(r)
+					if err != nil {
+						logger.Debug("Failed to read message", log.Ctx{"err": err})
+						return
+					}
+
+					if mt == websocket.TextMessage {
+						command, err := execControlUnmarshal(buf)
+						if err == nil && execControl(logger, cmd, ptys[0], command) {
+							continue
+						}
+					}
+
+					_, err = ptys[0].Write(buf)
+					if err != nil {
+						logger.Debug("Failed to write to terminal", log.Ctx{"err": err})
+						return
+					}
+				}
+			}()
+
+			in := shared.ExecReaderToChannel(ptys[0], -1, attachedChildIsDead, int(ptys[0].Fd()))
+			for buf := range in {
+				err := conn.WriteMessage(websocket.BinaryMessage, buf)
+				if err != nil {
+					logger.Debug("Failed to write message", log.Ctx{"err": err})
+					break
+				}
+			}
+
+			closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+			conn.WriteMessage(websocket.CloseMessage, closeMsg)
+			conn.Close()
+			wgEOF.Done()
+		}()
+	} else if s.req.Interactive {
 		wgEOF.Add(1)
 		go func() {
 			logger.Debug("Interactive child process handler started")
@@ -257,38 +324,13 @@ func (s *execWs) Do(op *operations.Operation) error {
 					break
 				}
 
-				command := api.InstanceExecControl{}
-
-				if err := json.Unmarshal(buf, &command); err != nil {
+				command, err := execControlUnmarshal(buf)
+				if err != nil {
 					logger.Debug("Failed to unmarshal control socket command", log.Ctx{"err": err})
 					continue
 				}
 
-				if command.Command == "window-resize" {
-					winchWidth, err := strconv.Atoi(command.Args["width"])
-					if err != nil {
-						logger.Debug("Unable to extract window width", log.Ctx{"err": err})
-						continue
-					}
-
-					winchHeight, err := strconv.Atoi(command.Args["height"])
-					if err != nil {
-						logger.Debug("Unable to extract window height", log.Ctx{"err": err})
-						continue
-					}
-
-					err = cmd.WindowResize(int(ptys[0].Fd()), winchWidth, winchHeight)
-					if err != nil {
-						logger.Debug("Failed to set window size", winchWidth, winchHeight, log.Ctx{"err": err, "width": winchWidth, "height": winchHeight})
-						continue
-					}
-				} else if command.Command == "signal" {
-					err := cmd.Signal(unix.Signal(command.Signal))
-					if err != nil {
-						logger.Debug("Failed forwarding signal", log.Ctx{"err": err, "signal": command.Signal})
-						continue
-					}
-				}
+				execControl(logger, cmd, ptys[0], command)
 			}
 		}()
 
@@ -335,6 +377,192 @@ func (s *execWs) Do(op *operations.Operation) error {
 	return finisher(exitCode, err)
 }
 
+// execInitEnvironment returns the environment of the given init process.
+func execInitEnvironment(pid int) (map[string]string, error) {
+	if pid <= 0 {
+		return nil, fmt.Errorf("Instance is not running")
+	}
+
+	content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/environ", pid))
+	if err != nil {
+		return nil, err
+	}
+
+	env := map[string]string{}
+	for _, entry := range strings.Split(string(content), "\x00") {
+		fields := strings.SplitN(entry, "=", 2)
+		if len(fields) != 2 || fields[0] == "" {
+			continue
+		}
+
+		env[fields[0]] = fields[1]
+	}
+
+	return env, nil
+}
+
+// Maximum size of the /etc/passwd file read from an instance to look up the user of a command.
+const execPasswdMaxSize = 1024 * 1024
+
+// execPasswdLookup returns the name and home directory of the given user from the instance's
+// /etc/passwd, read through the instance so that its symlinks can't point outside of it. The file
+// is pulled into memory rather than onto the host, and files above execPasswdMaxSize are refused.
+func execPasswdLookup(inst instance.Instance, uid uint32) (string, string, error) {
+	fd, err := unix.MemfdCreate("lxd_exec_passwd", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return "", "", err
+	}
+
+	f := os.NewFile(uintptr(fd), "lxd_exec_passwd")
+	defer f.Close()
+
+	// Containers pull the file by writing over the given one, which can then be kept from
+	// growing past the maximum size. Virtual machines get it from their agent and replace it.
+	if inst.Type() == instancetype.Container {
+		err = f.Truncate(execPasswdMaxSize)
+		if err != nil {
+			return "", "", err
+		}
+
+		_, err = unix.FcntlInt(f.Fd(), unix.F_ADD_SEALS, unix.F_SEAL_GROW)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	// The pull reopens the file, possibly from a child process.
+	_, _, _, _, _, err = inst.FilePull("/etc/passwd", fmt.Sprintf("/proc/%d/fd/%d", os.Getpid(), f.Fd()))
+	if err != nil {
+		return "", "", errors.Wrap(err, "Failed to read /etc/passwd")
+	}
+
+	content, err := ioutil.ReadAll(io.LimitReader(f, execPasswdMaxSize+1))
+	if err != nil {
+		return "", "", err
+	}
+
+	if len(content) > execPasswdMaxSize {
+		return "", "", fmt.Errorf("The /etc/passwd file is larger than %d bytes", execPasswdMaxSize)
+	}
+
+	// Drop what's left of the space set aside for containers.
+	i := bytes.IndexByte(content, 0)
+	if i >= 0 {
+		content = content[:i]
+	}
+
+	return execPasswdParse(string(content), uid)
+}
+
+// execPasswdParse returns the name and home directory of the given user in a passwd file.
+func execPasswdParse(content string, uid uint32) (string, string, error) {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) < 7 || fields[2] != strconv.FormatUint(uint64(uid), 10) {
+			continue
+		}
+
+		return fields[0], fields[5], nil
+	}
+
+	return "", "", fmt.Errorf("User %d not found", uid)
+}
+
+// execCheckIdmap checks that the user and group an exec runs as are mapped to host IDs by the
+// idmap of the container. A nil idmap is the one of a privileged container where all IDs are valid.
+func execCheckIdmap(idmapset *idmap.IdmapSet, uid uint32, gid uint32) error {
+	if idmapset == nil {
+		return nil
+	}
+
+	hostUID, hostGID := idmapset.ShiftIntoNs(int64(uid), int64(gid))
+	if hostUID < 0 {
+		return fmt.Errorf("User %d isn't mapped in the container", uid)
+	}
+
+	if hostGID < 0 {
+		return fmt.Errorf("Group %d isn't mapped in the container", gid)
+	}
+
+	return nil
+}
+
+// execControlUnmarshal decodes a control message. The arguments are strings in the API but clients
+// commonly send numbers for the window size, so any scalar value is accepted.
+func execControlUnmarshal(buf []byte) (api.InstanceExecControl, error) {
+	raw := struct {
+		Command string                 `json:"command"`
+		Args    map[string]interface{} `json:"args"`
+		Signal  int                    `json:"signal"`
+	}{}
+
+	err := json.Unmarshal(buf, &raw)
+	if err != nil {
+		return api.InstanceExecControl{}, err
+	}
+
+	command := api.InstanceExecControl{
+		Command: raw.Command,
+		Args:    map[string]string{},
+		Signal:  raw.Signal,
+	}
+
+	for key, value := range raw.Args {
+		switch v := value.(type) {
+		case string:
+			command.Args[key] = v
+		case float64:
+			command.Args[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			command.Args[key] = strconv.FormatBool(v)
+		default:
+			return api.InstanceExecControl{}, fmt.Errorf("Invalid value for control argument %q", key)
+		}
+	}
+
+	return command, nil
+}
+
+// execControl applies a control message to the running command. It returns false if the message
+// isn't a known control command.
+func execControl(l logger.Logger, cmd instance.Cmd, pty *os.File, command api.InstanceExecControl) bool {
+	switch command.Command {
+	case "window-resize":
+		winchWidth, err := strconv.Atoi(command.Args["width"])
+		if err != nil {
+			l.Debug("Unable to extract window width", log.Ctx{"err": err})
+			return true
+		}
+
+		winchHeight, err := strconv.Atoi(command.Args["height"])
+		if err != nil {
+			l.Debug("Unable to extract window height", log.Ctx{"err": err})
+			return true
+		}
+
+		err = cmd.WindowResize(int(pty.Fd()), winchWidth, winchHeight)
+		if err != nil {
+			l.Debug("Failed to set window size", log.Ctx{"err": err, "width": winchWidth, "height": winchHeight})
+		}
+
+		return true
+	case "signal":
+		if command.Signal <= 0 || command.Signal > 64 {
+			l.Debug("Ignoring invalid signal", log.Ctx{"signal": command.Signal})
+			return true
+		}
+
+		err := cmd.Signal(unix.Signal(command.Signal))
+		if err != nil {
+			l.Debug("Failed forwarding signal", log.Ctx{"err": err, "signal": command.Signal})
+		}
+
+		return true
+	}
+
+	return false
+}
+
 func containerExecPost(d *Daemon, r *http.Request) response.Response {
 	instanceType, err := urlInstanceTypeDetect(r)
 	if err != nil {
@@ -385,6 +613,23 @@ func containerExecPost(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(fmt.Errorf("Instance is frozen"))
 	}
 
+	if post.Cwd != "" && !filepath.IsAbs(post.Cwd) {
+		return response.BadRequest(fmt.Errorf("The working directory must be an absolute path"))
+	}
+
+	// Fail early rather than in the middle of attaching if the IDs can't exist in the container.
+	if inst.Type() == instancetype.Container {
+		idmapset, err := inst.(instance.Container).CurrentIdmap()
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		err = execCheckIdmap(idmapset, post.User, post.Group)
+		if err != nil {
+			return response.BadRequest(err)
+		}
+	}
+
 	// Process environment.
 	if post.Environment == nil {
 		post.Environment = map[string]string{}
@@ -400,6 +645,24 @@ func containerExecPost(d *Daemon, r *http.Request) response.Response {
 		}
 	}
 
+	// Fill in the rest from the environment of init if requested.
+	if post.EnvironmentInherit {
+		if inst.Type() != instancetype.Container {
+			return response.BadRequest(fmt.Errorf("Inheriting the environment is only supported for containers"))
+		}
+
+		initEnv, err := execInitEnvironment(inst.InitPID())
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		for k, v := range initEnv {
+			if _, found := post.Environment[k]; !found {
+				post.Environment[k] = v
+			}
+		}
+	}
+
 	// Set default value for PATH.
 	_, ok := post.Environment["PATH"]
 	if !ok {
@@ -424,10 +687,60 @@ func containerExecPost(d *Daemon, r *http.Request) response.Response {
 		}
 	}
 
-	// Set default value for LANG.
+	// For other users, look them up in the instance.
+	_, hasHome := post.Environment["HOME"]
+	_, hasUser := post.Environment["USER"]
+	if post.User != 0 && (!hasHome || !hasUser) {
+		username, home, err := execPasswdLookup(inst, post.User)
+		if err != nil {
+			logger.Debug("Failed to look up exec user", log.Ctx{"instance": inst.Name(), "user": post.User, "err": err})
+		}
+
+		if !hasHome && home != "" {
+			post.Environment["HOME"] = home
+		}
+
+		if !hasUser && username != "" {
+			post.Environment["USER"] = username
+		}
+	}
+
+	// Set default value for LANG, following the host when requested.
 	_, ok = post.Environment["LANG"]
 	if !ok {
 		post.Environment["LANG"] = "C.UTF-8"
+
+		if shared.IsTrue(inst.ExpandedConfig()["host.locale"]) {
+			locale, err := util.HostLocale()
+			if err == nil {
+				post.Environment["LANG"] = locale
+			}
+		}
+	}
+
+	// Pass the timezone of the host when requested.
+	_, ok = post.Environment["TZ"]
+	if !ok && inst.ExpandedConfig()["host.timezone"] == "env" {
+		timezone, err := util.HostTimezone()
+		if err == nil {
+			post.Environment["TZ"] = timezone
+		}
+	}
+
+	// Set default value for TERM, clients normally pass their own.
+	_, ok = post.Environment["TERM"]
+	if !ok && post.Interactive {
+		post.Environment["TERM"] = "xterm"
+	}
+
+	if post.Framing != "" {
+		if post.Framing != "terminal" {
+			return response.BadRequest(fmt.Errorf("Invalid websocket framing %q", post.Framing))
+		}
+
+		if !post.WaitForWS || !post.Interactive {
+			return response.BadRequest(fmt.Errorf("Terminal framing requires wait-for-websocket and interactive"))
+		}
 	}
 
 	if post.WaitForWS {
@@ -447,15 +760,18 @@ func containerExecPost(d *Daemon, r *http.Request) response.Response {
 		}
 
 		ws.conns = map[int]*websocket.Conn{}
-		ws.conns[-1] = nil
 		ws.conns[0] = nil
+		if post.Framing != "terminal" {
+			ws.conns[-1] = nil
+		}
+
 		if !post.Interactive {
 			ws.conns[1] = nil
 			ws.conns[2] = nil
 		}
 		ws.allConnected = make(chan struct{})
 		ws.controlConnected = make(chan struct{})
-		for i := -1; i < len(ws.conns)-1; i++ {
+		for i := range ws.conns {
 			ws.fds[i], err = shared.RandomCryptoString()
 			if err != nil {
 				return response.InternalError(err)
@@ -508,10 +824,15 @@ func containerExecPost(d *Daemon, r *http.Request) response.Response {
 			}
 
 			// Update metadata with the right URLs
+			query := ""
+			if inst.Project() != "default" {
+				query = fmt.Sprintf("?project=%s", inst.Project())
+			}
+
 			metadata["return"] = exitCode
 			metadata["output"] = shared.Jmap{
-				"1": fmt.Sprintf("/%s/instances/%s/logs/%s", version.APIVersion, inst.Name(), filepath.Base(stdout.Name())),
-				"2": fmt.Sprintf("/%s/instances/%s/logs/%s", version.APIVersion, inst.Name(), filepath.Base(stderr.Name())),
+				"1": fmt.Sprintf("/%s/instances/%s/logs/%s%s", version.APIVersion, inst.Name(), filepath.Base(stdout.Name()), query),
+				"2": fmt.Sprintf("/%s/instances/%s/logs/%s%s", version.APIVersion, inst.Name(), filepath.Base(stderr.Name()), query),
 			}
 		} else {
 			cmd, err := inst.Exec(post, nil, nil, nil)
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared/idmap"
+)
+
+// Window sizes are accepted both as strings and as numbers.
+func TestExecControlUnmarshal(t *testing.T) {
+	for _, msg := range []string{
+		`{"command": "window-resize", "args": {"width": "80", "height": "25"}}`,
+		`{"command": "window-resize", "args": {"width": 80, "height": 25}}`,
+	} {
+		command, err := execControlUnmarshal([]byte(msg))
+		require.NoError(t, err)
+		assert.Equal(t, "window-resize", command.Command)
+		assert.Equal(t, map[string]string{"width": "80", "height": "25"}, command.Args)
+	}
+
+	command, err := execControlUnmarshal([]byte(`{"command": "signal", "signal": 10}`))
+	require.NoError(t, err)
+	assert.Equal(t, 10, command.Signal)
+
+	_, err = execControlUnmarshal([]byte(`{"command": "window-resize", "args": {"width": [80]}}`))
+	assert.Error(t, err)
+}
+
+func TestExecPasswdParse(t *testing.T) {
+	content := "root:x:0:0:root:/root:/bin/bash\nubuntu:x:1000:1000:Ubuntu:/home/ubuntu:/bin/bash\n"
+
+	name, home, err := execPasswdParse(content, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, "ubuntu", name)
+	assert.Equal(t, "/home/ubuntu", home)
+
+	_, _, err = execPasswdParse(content, 1001)
+	assert.Error(t, err)
+}
+
+func TestExecCheckIdmap(t *testing.T) {
+	idmapset := &idmap.IdmapSet{Idmap: []idmap.IdmapEntry{
+		{Isuid: true, Hostid: 1000000, Nsid: 0, Maprange: 65536},
+		{Isgid: true, Hostid: 1000000, Nsid: 0, Maprange: 1000},
+	}}
+
+	assert.NoError(t, execCheckIdmap(idmapset, 0, 0))
+	assert.NoError(t, execCheckIdmap(idmapset, 1000, 999))
+	assert.Error(t, execCheckIdmap(idmapset, 65536, 0))
+	assert.Error(t, execCheckIdmap(idmapset, 1000, 1000))
+	assert.NoError(t, execCheckIdmap(nil, 65536, 65536))
+}
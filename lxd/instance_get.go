@@ -31,7 +31,7 @@ func containerGet(d *Daemon, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
-	state, etag, err := c.Render()
+	state, etag, err := c.Render(instanceRenderHideSecrets)
 	if err != nil {
 		return response.SmartError(err)
 	}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
+)
+
+var instanceGroupsCmd = APIEndpoint{
+	Path: "instance-groups",
+
+	Get:  APIEndpointAction{Handler: instanceGroupsGet, AccessHandler: allowProjectPermission("containers", "view")},
+	Post: APIEndpointAction{Handler: instanceGroupsPost, AccessHandler: allowProjectPermission("containers", "manage-containers")},
+}
+
+var instanceGroupCmd = APIEndpoint{
+	Path: "instance-groups/{name}",
+
+	Delete: APIEndpointAction{Handler: instanceGroupDelete, AccessHandler: allowProjectPermission("containers", "manage-containers")},
+	Get:    APIEndpointAction{Handler: instanceGroupGet, AccessHandler: allowProjectPermission("containers", "view")},
+	Put:    APIEndpointAction{Handler: instanceGroupPut, AccessHandler: allowProjectPermission("containers", "manage-containers")},
+}
+
+// Config keys of instance groups, on top of user.* keys.
+var instanceGroupConfigKeys = map[string]func(value string) error{
+	"scheduler.anti_affinity": shared.IsBool,
+	"snapshots.schedule":      shared.KnownInstanceConfigKeys["snapshots.schedule"],
+}
+
+func instanceGroupsGet(d *Daemon, r *http.Request) response.Response {
+	project := projectParam(r)
+	recursion := util.IsRecursionRequest(r)
+
+	var groups []db.InstanceGroup
+	members := map[string]map[string]string{}
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		groups, err = tx.InstanceGroups(project)
+		if err != nil {
+			return err
+		}
+
+		if !recursion {
+			return nil
+		}
+
+		for _, group := range groups {
+			members[group.Name], err = tx.InstanceGroupMembers(project, group.Name)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	resultString := []string{}
+	resultMap := []api.InstanceGroup{}
+	for _, group := range groups {
+		if !recursion {
+			resultString = append(resultString, fmt.Sprintf("/%s/instance-groups/%s", version.APIVersion, group.Name))
+		} else {
+			resultMap = append(resultMap, instanceGroupToAPI(group, members[group.Name]))
+		}
+	}
+
+	if !recursion {
+		return response.SyncResponse(true, resultString)
+	}
+
+	return response.SyncResponse(true, resultMap)
+}
+
+func instanceGroupsPost(d *Daemon, r *http.Request) response.Response {
+	project := projectParam(r)
+
+	req := api.InstanceGroupsPost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = shared.InstanceGroupValidName(req.Name)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = instanceGroupValidateConfig(req.Config)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	exists := false
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		_, err := tx.InstanceGroupGet(project, req.Name)
+		if err == nil {
+			exists = true
+			return nil
+		}
+
+		if err != db.ErrNoSuchObject {
+			return err
+		}
+
+		_, err = tx.InstanceGroupCreate(project, req.Name, req.Description, req.Config)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if exists {
+		return response.Conflict(fmt.Errorf("Instance group '%s' already exists", req.Name))
+	}
+
+	return response.SyncResponseLocation(true, nil, fmt.Sprintf("/%s/instance-groups/%s", version.APIVersion, req.Name))
+}
+
+func instanceGroupGet(d *Daemon, r *http.Request) response.Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	var group db.InstanceGroup
+	var members map[string]string
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		group, err = tx.InstanceGroupGet(project, name)
+		if err != nil {
+			return err
+		}
+
+		members, err = tx.InstanceGroupMembers(project, name)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	render := instanceGroupToAPI(group, members)
+	return response.SyncResponseETag(true, render, render.Writable())
+}
+
+func instanceGroupPut(d *Daemon, r *http.Request) response.Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	req := api.InstanceGroupPut{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = instanceGroupValidateConfig(req.Config)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	var group db.InstanceGroup
+	var members map[string]string
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		group, err = tx.InstanceGroupGet(project, name)
+		if err != nil {
+			return err
+		}
+
+		members, err = tx.InstanceGroupMembers(project, name)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Validate the ETag
+	current := instanceGroupToAPI(group, members)
+	err = util.EtagCheck(r, current.Writable())
+	if err != nil {
+		return response.PreconditionFailed(err)
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.InstanceGroupUpdate(group.ID, req.Description, req.Config)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func instanceGroupDelete(d *Daemon, r *http.Request) response.Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	var members map[string]string
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		group, err := tx.InstanceGroupGet(project, name)
+		if err != nil {
+			return err
+		}
+
+		members, err = tx.InstanceGroupMembers(project, name)
+		if err != nil {
+			return err
+		}
+
+		if len(members) > 0 {
+			return nil
+		}
+
+		return tx.InstanceGroupDelete(group.ID)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if len(members) > 0 {
+		return response.BadRequest(fmt.Errorf("Instance group '%s' still has instances", name))
+	}
+
+	return response.EmptySyncResponse
+}
+
+func instanceGroupToAPI(group db.InstanceGroup, members map[string]string) api.InstanceGroup {
+	render := api.InstanceGroup{
+		InstanceGroupPut: api.InstanceGroupPut{
+			Description: group.Description,
+			Config:      group.Config,
+		},
+		Name:   group.Name,
+		UsedBy: []string{},
+	}
+
+	for name := range members {
+		render.UsedBy = append(render.UsedBy, fmt.Sprintf("/%s/instances/%s", version.APIVersion, name))
+	}
+
+	sort.Strings(render.UsedBy)
+	return render
+}
+
+// instanceGroupValidateConfig checks the config of an instance group.
+func instanceGroupValidateConfig(config map[string]string) error {
+	for key, value := range config {
+		if strings.HasPrefix(key, "user.") {
+			continue
+		}
+
+		validator, ok := instanceGroupConfigKeys[key]
+		if !ok {
+			return fmt.Errorf("Invalid instance group configuration key %q", key)
+		}
+
+		err := validator(value)
+		if err != nil {
+			return fmt.Errorf("Invalid value for instance group configuration key %q: %v", key, err)
+		}
+	}
+
+	return nil
+}
+
+// instanceGroupsLoad returns the instance groups of the given project, indexed by name.
+func instanceGroupsLoad(s *state.State, project string) (map[string]db.InstanceGroup, error) {
+	var groups []db.InstanceGroup
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		groups, err = tx.InstanceGroups(project)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]db.InstanceGroup{}
+	for _, group := range groups {
+		result[group.Name] = group
+	}
+
+	return result, nil
+}
+
+// instanceGroupsAntiAffinity returns how many members of the anti-affine groups among the given
+// ones each node hosts, to be avoided when placing a new member of those groups.
+func instanceGroupsAntiAffinity(tx *db.ClusterTx, project string, names []string) (map[string]int, error) {
+	avoid := map[string]int{}
+	for _, name := range names {
+		group, err := tx.InstanceGroupGet(project, name)
+		if err == db.ErrNoSuchObject {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !shared.IsTrue(group.Config["scheduler.anti_affinity"]) {
+			continue
+		}
+
+		members, err := tx.InstanceGroupMembers(project, name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, node := range members {
+			avoid[node]++
+		}
+	}
+
+	return avoid, nil
+}
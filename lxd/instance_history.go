@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+)
+
+func containerHistoryGet(d *Daemon, r *http.Request) response.Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// The history is stored in the global database, so there's no need to
+	// forward the request, but make sure the instance exists.
+	var entries []db.InstanceHistoryEntry
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		_, err := tx.InstanceID(project, name)
+		if err != nil {
+			return err
+		}
+
+		entries, err = tx.InstanceHistory(project, name)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	history := make([]api.InstanceHistoryEntry, len(entries))
+	for i, entry := range entries {
+		history[i] = api.InstanceHistoryEntry{
+			ID:        entry.ID,
+			CreatedAt: entry.Date,
+			Action:    entry.Action,
+			Requestor: entry.Requestor,
+		}
+
+		if entry.Operation != "" {
+			history[i].Operation = fmt.Sprintf("/%s/operations/%s", version.APIVersion, entry.Operation)
+		}
+	}
+
+	return response.SyncResponse(true, history)
+}
+
+// Return a description of who performed the request, for the instance history.
+func instanceHistoryRequestor(r *http.Request) string {
+	username, _ := r.Context().Value("username").(string)
+	if username != "" {
+		return username
+	}
+
+	// Local users and cluster members don't have a username.
+	protocol, _ := r.Context().Value("protocol").(string)
+	return protocol
+}
+
+// Record a state change of the given instance, performed by the given
+// operation on behalf of requestor. Failures are only logged, since the
+// state change itself already happened.
+func instanceHistoryRecord(s *state.State, inst instance.Instance, action string, op *operations.Operation, requestor string) {
+	uuid := ""
+	if op != nil {
+		uuid = op.ID()
+	}
+
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.InstanceHistoryAdd(inst.ID(), action, uuid, requestor)
+	})
+	if err != nil {
+		logger.Warn("Failed to record instance state change", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "action": action, "err": err})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// Interval at which the host timezone is checked for changes to propagate to the containers
+// following it.
+const hostTimezoneSyncInterval = time.Minute
+
+func hostTimezoneSyncTask(d *Daemon) (task.Func, task.Schedule) {
+	// Zone file of the host as of the last check. Containers get a fresh copy when starting, so
+	// they only need to be looked at when it changes.
+	var last []byte
+
+	f := func(ctx context.Context) {
+		data, err := util.HostTimezoneData()
+		if err != nil {
+			logger.Debug("Failed to read the host timezone", log.Ctx{"err": err})
+			return
+		}
+
+		if last != nil && bytes.Equal(data, last) {
+			return
+		}
+
+		hostTimezoneSync(d.State())
+		last = data
+	}
+
+	return f, task.Every(hostTimezoneSyncInterval)
+}
+
+// Update the timezone mounted in the running containers with host.timezone.follow set.
+func hostTimezoneSync(s *state.State) {
+	insts, err := instance.LoadNodeAll(s, instancetype.Container)
+	if err != nil {
+		logger.Error("Failed to load containers for host timezone sync", log.Ctx{"err": err})
+		return
+	}
+
+	for _, inst := range insts {
+		config := inst.ExpandedConfig()
+		if config["host.timezone"] != "mount" || !shared.IsTrue(config["host.timezone.follow"]) || !inst.IsRunning() {
+			continue
+		}
+
+		err := inst.(instance.Container).HostTimezoneSync()
+		if err != nil {
+			logger.Warn("Failed to sync the host timezone", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "err": err})
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// Interval at which the activity of instances with an idle policy is sampled.
+const idleCheckInterval = 10 * time.Second
+
+// Default value of idle.timeout, in minutes.
+const idleDefaultTimeout = 30
+
+// An instance is considered active if it used more than this share of a
+// single CPU or transferred more than this many bytes per second since the
+// previous sample.
+const (
+	idleCPUThreshold     = 0.01
+	idleNetworkThreshold = 1024
+)
+
+// Resource usage of an instance at the time of the last check.
+type idleSample struct {
+	time    time.Time
+	cpu     int64
+	network int64
+
+	// Last time activity was seen.
+	active time.Time
+
+	// Whether the instance was frozen by the idle policy.
+	frozen bool
+}
+
+// Samples of the instances with an idle policy, keyed by project and name.
+// Only accessed by the idle check task.
+var idleSamples = map[string]*idleSample{}
+
+func idleCheckTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		idleCheck(d.State(), time.Now())
+	}
+
+	return f, task.Every(idleCheckInterval)
+}
+
+// Freeze or stop the running containers which have been idle for longer than
+// their idle.timeout, and thaw the ones we froze if traffic reaches them.
+func idleCheck(s *state.State, now time.Time) {
+	// Only load the instances with an idle policy rather than all the local
+	// instances, this runs every few seconds.
+	var ids []int
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		ids, err = tx.LocalInstanceIDsWithExpandedConfigKeys("idle.action")
+		return err
+	})
+	if err != nil {
+		logger.Error("Failed to load containers for idle check", log.Ctx{"err": err})
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, id := range ids {
+		inst, err := instance.LoadByID(s, id)
+		if err != nil {
+			logger.Error("Failed to load container for idle check", log.Ctx{"id": id, "err": err})
+			continue
+		}
+
+		config := inst.ExpandedConfig()
+		action := config["idle.action"]
+		if inst.Type() != instancetype.Container || action == "" || !inst.IsRunning() {
+			continue
+		}
+
+		key := project.Instance(inst.Project(), inst.Name())
+		seen[key] = true
+
+		instState, err := inst.RenderState()
+		if err != nil {
+			logger.Warn("Failed to get container state for idle check", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "err": err})
+			continue
+		}
+
+		current := &idleSample{time: now, cpu: instState.CPU.Usage, active: now}
+		for _, nic := range instState.Network {
+			current.network += nic.Counters.BytesReceived + nic.Counters.BytesSent
+		}
+
+		previous, ok := idleSamples[key]
+		idleSamples[key] = current
+		if !ok {
+			continue
+		}
+
+		if idleIsActive(previous, current) {
+			if previous.frozen && inst.IsFrozen() && shared.IsTrue(config["idle.resume"]) {
+				logger.Info("Resuming idle container", log.Ctx{"project": inst.Project(), "instance": inst.Name()})
+				err := inst.Unfreeze()
+				if err != nil {
+					logger.Error("Failed to resume idle container", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "err": err})
+				}
+			}
+
+			continue
+		}
+
+		current.active = previous.active
+		current.frozen = previous.frozen
+
+		if inst.IsFrozen() {
+			continue
+		}
+
+		timeout, err := strconv.Atoi(config["idle.timeout"])
+		if err != nil {
+			timeout = idleDefaultTimeout
+		}
+
+		if now.Sub(current.active) < time.Duration(timeout)*time.Minute {
+			continue
+		}
+
+		logger.Info("Suspending idle container", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "action": action})
+		switch action {
+		case "freeze":
+			err = inst.Freeze()
+			current.frozen = err == nil
+		case "stop":
+			err = inst.Shutdown(30 * time.Second)
+			if err != nil {
+				err = inst.Stop(false)
+			}
+		}
+
+		if err != nil {
+			logger.Error("Failed to suspend idle container", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "err": err})
+		}
+	}
+
+	for key := range idleSamples {
+		if !seen[key] {
+			delete(idleSamples, key)
+		}
+	}
+}
+
+// Return whether the instance did anything between the two samples.
+func idleIsActive(previous *idleSample, current *idleSample) bool {
+	elapsed := current.time.Sub(previous.time)
+	if elapsed <= 0 {
+		return true
+	}
+
+	// Counters going backwards mean the instance was restarted.
+	if current.cpu < previous.cpu || current.network < previous.network {
+		return true
+	}
+
+	if float64(current.cpu-previous.cpu) > idleCPUThreshold*float64(elapsed.Nanoseconds()) {
+		return true
+	}
+
+	if float64(current.network-previous.network) > idleNetworkThreshold*elapsed.Seconds() {
+		return true
+	}
+
+	return false
+}
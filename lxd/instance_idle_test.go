@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdleIsActive(t *testing.T) {
+	now := time.Now()
+	previous := &idleSample{time: now, cpu: 1000000000, network: 50000}
+
+	cases := []struct {
+		name   string
+		sample *idleSample
+		active bool
+	}{
+		{"idle", &idleSample{time: now.Add(10 * time.Second), cpu: 1010000000, network: 51000}, false},
+		{"cpu", &idleSample{time: now.Add(10 * time.Second), cpu: 2000000000, network: 50000}, true},
+		{"network", &idleSample{time: now.Add(10 * time.Second), cpu: 1000000000, network: 100000}, true},
+		{"restarted", &idleSample{time: now.Add(10 * time.Second), cpu: 100, network: 0}, true},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.active, idleIsActive(previous, c.sample), c.name)
+	}
+}
@@ -53,11 +53,11 @@ func containerLogsGet(d *Daemon, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
-	project := projectParam(r)
+	projectName := projectParam(r)
 	name := mux.Vars(r)["name"]
 
 	// Handle requests targeted to a container on a different node
-	resp, err := forwardedResponseIfInstanceIsRemote(d, r, project, name, instanceType)
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, projectName, name, instanceType)
 	if err != nil {
 		return response.SmartError(err)
 	}
@@ -72,7 +72,7 @@ func containerLogsGet(d *Daemon, r *http.Request) response.Response {
 
 	result := []string{}
 
-	dents, err := ioutil.ReadDir(shared.LogPath(name))
+	dents, err := ioutil.ReadDir(shared.LogPath(project.Instance(projectName, name)))
 	if err != nil {
 		return response.SmartError(err)
 	}
@@ -95,9 +95,12 @@ func validLogFileName(fname string) bool {
 	return fname == "lxc.log" ||
 		fname == "lxc.conf" ||
 		fname == "qemu.log" ||
+		fname == "console.log" ||
+		fname == "console.log.old" ||
 		strings.HasPrefix(fname, "migration_") ||
 		strings.HasPrefix(fname, "snapshot_") ||
-		strings.HasPrefix(fname, "exec_")
+		strings.HasPrefix(fname, "exec_") ||
+		strings.HasPrefix(fname, "core.")
 }
 
 func containerLogGet(d *Daemon, r *http.Request) response.Response {
@@ -143,11 +146,11 @@ func containerLogDelete(d *Daemon, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
-	project := projectParam(r)
+	projectName := projectParam(r)
 	name := mux.Vars(r)["name"]
 
 	// Handle requests targeted to a container on a different node
-	resp, err := forwardedResponseIfInstanceIsRemote(d, r, project, name, instanceType)
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, projectName, name, instanceType)
 	if err != nil {
 		return response.SmartError(err)
 	}
@@ -170,5 +173,5 @@ func containerLogDelete(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(fmt.Errorf("lxc.log and lxc.conf may not be deleted"))
 	}
 
-	return response.SmartError(os.Remove(shared.LogPath(name, file)))
+	return response.SmartError(os.Remove(shared.LogPath(project.Instance(projectName, name), file)))
 }
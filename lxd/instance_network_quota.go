@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/device"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/units"
+)
+
+// Interval at which the network usage of instances with a network quota is accounted.
+const networkQuotaCheckInterval = time.Minute
+
+// Interval at which the network usage of instances is written to the database, unless it crosses
+// a quota or a new period starts.
+const networkQuotaPersistInterval = 15 * time.Minute
+
+// networkQuotaSample holds the network accounting state of a running container between checks.
+type networkQuotaSample struct {
+	counter         int64     // Bytes sent as of the last check.
+	usage           int64     // Usage in the current period, including what isn't persisted yet.
+	period          string    // Accounting period the usage belongs to.
+	pending         int64     // Bytes not yet added to the usage of the project.
+	persistedPeriod string    // Accounting period of the persisted usage.
+	persistedAt     time.Time // When the usage was last persisted.
+}
+
+// Accounting state of the containers with a network quota, keyed by project and name, and the
+// period in which each project last exceeded its quota. Only accessed by the network quota task.
+var networkQuotaSamples = map[string]*networkQuotaSample{}
+var networkQuotaProjectsExceeded = map[string]string{}
+
+func networkQuotaCheckTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		// Only the leader emits the project events, so that they're emitted once.
+		checkProjects := true
+		address, err := node.ClusterAddress(d.db)
+		if err == nil && address != "" {
+			leader, err := d.gateway.LeaderAddress()
+			checkProjects = err == nil && leader == address
+		}
+
+		networkQuotaCheck(d.State(), time.Now(), checkProjects)
+	}
+
+	return f, task.Every(networkQuotaCheckInterval)
+}
+
+// Account the egress traffic of the running containers with a limits.network.quota, or in a
+// project with one, warn once they or their project exceed it and throttle them until the end of
+// the period if requested.
+func networkQuotaCheck(s *state.State, now time.Time, checkProjects bool) {
+	var projectsConfig map[string]map[string]string
+	var projectsUsage map[string]int64
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		projectsConfig, err = tx.ProjectConfigRef(db.ProjectFilter{})
+		if err != nil {
+			return err
+		}
+
+		projectsUsage, err = tx.ProjectsNetworkUsage(networkQuotaPeriod("month", now))
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.Error("Failed to load projects for network quota check", log.Ctx{"err": err})
+		return
+	}
+
+	projectsExceeded := networkQuotaProjectsCheck(projectsConfig, projectsUsage)
+	if checkProjects {
+		networkQuotaProjectsNotify(s, now, projectsExceeded, projectsConfig, projectsUsage)
+	}
+
+	insts, err := instance.LoadNodeAll(s, instancetype.Container)
+	if err != nil {
+		logger.Error("Failed to load containers for network quota check", log.Ctx{"err": err})
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, inst := range insts {
+		config := inst.ExpandedConfig()
+		projectConfig := projectsConfig[inst.Project()]
+		wasExceeded := shared.IsTrue(config["volatile.network.quota_exceeded"])
+		wasThrottle := config["volatile.network.throttle"]
+		if config["limits.network.quota"] == "" && !wasExceeded && wasThrottle == "" && projectConfig["limits.network.quota"] == "" {
+			continue
+		}
+
+		key := project.Instance(inst.Project(), inst.Name())
+		if !inst.IsRunning() {
+			// Persist the usage left over by the containers stopped since the last check. Their
+			// interfaces get recreated on start, so count from zero from then on.
+			sample, ok := networkQuotaSamples[key]
+			if ok {
+				sample.counter = 0
+				err := networkQuotaPersist(s, inst, sample, now)
+				if err != nil {
+					logger.Error("Failed to record network usage of container", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "err": err})
+				}
+			}
+
+			continue
+		}
+
+		seen[key] = true
+
+		counter := device.NetworkQuotaCounter(inst)
+		period := networkQuotaPeriod(config["limits.network.quota.period"], now)
+
+		sample, ok := networkQuotaSamples[key]
+		if !ok {
+			// Pick up from what was last persisted, catching up with the traffic sent since then
+			// if the interfaces are still the same, e.g. across restarts of LXD.
+			sample, err = networkQuotaLoad(s, inst, counter, now)
+			if err != nil {
+				logger.Error("Failed to load network usage of container", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "err": err})
+				continue
+			}
+
+			networkQuotaSamples[key] = sample
+		}
+
+		if sample.period != period {
+			sample.usage = 0
+			sample.period = period
+		}
+
+		delta := networkQuotaDelta(sample.counter, counter)
+		sample.usage += delta
+		sample.pending += delta
+		sample.counter = counter
+
+		var quota int64
+		if config["limits.network.quota"] != "" {
+			quota, err = units.ParseByteSizeString(config["limits.network.quota"])
+			if err != nil {
+				continue
+			}
+		}
+
+		exceeded := quota > 0 && sample.usage > quota
+
+		// Throttle according to the container's own quota first, then to its project's.
+		throttle := ""
+		if exceeded && config["limits.network.quota.action"] == "throttle" {
+			throttle = networkQuotaThrottleRate(config)
+		} else if projectsExceeded[inst.Project()] && projectConfig["limits.network.quota.action"] == "throttle" {
+			throttle = networkQuotaThrottleRate(projectConfig)
+		}
+
+		if sample.persistedPeriod == period && exceeded == wasExceeded && throttle == wasThrottle && now.Sub(sample.persistedAt) < networkQuotaPersistInterval {
+			continue
+		}
+
+		err = networkQuotaPersist(s, inst, sample, now)
+		if err != nil {
+			logger.Error("Failed to record network usage of container", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "err": err})
+			continue
+		}
+
+		if exceeded == wasExceeded && throttle == wasThrottle {
+			continue
+		}
+
+		changes := map[string]string{
+			"volatile.network.quota_exceeded": "",
+			"volatile.network.throttle":       throttle,
+		}
+
+		if exceeded {
+			changes["volatile.network.quota_exceeded"] = "true"
+		}
+
+		err = inst.VolatileSet(changes)
+		if err != nil {
+			logger.Error("Failed to record network quota state of container", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "err": err})
+			continue
+		}
+
+		if exceeded != wasExceeded {
+			ctx := log.Ctx{"project": inst.Project(), "instance": inst.Name(), "usage": sample.usage, "quota": quota}
+			if exceeded {
+				logger.Warn("Container exceeded its network quota", ctx)
+				s.Events.SendLifecycle(inst.Project(), "container-network-quota-exceeded",
+					fmt.Sprintf("/1.0/containers/%s", inst.Name()), map[string]interface{}{"usage": sample.usage, "quota": quota, "period": period})
+			} else {
+				logger.Info("Container is back within its network quota", ctx)
+			}
+		}
+
+		if throttle != wasThrottle {
+			err = device.NetworkRefreshQuotaLimits(inst)
+			if err != nil {
+				logger.Error("Failed to apply network quota limits to container", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "err": err})
+			}
+		}
+	}
+
+	for key := range networkQuotaSamples {
+		if !seen[key] {
+			delete(networkQuotaSamples, key)
+		}
+	}
+}
+
+// networkQuotaLoad returns the accounting state of a container as last persisted, starting from
+// the given counter if none was.
+func networkQuotaLoad(s *state.State, inst instance.Instance, counter int64, now time.Time) (*networkQuotaSample, error) {
+	sample := &networkQuotaSample{counter: counter, persistedAt: now}
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		usage, err := tx.InstanceNetworkUsageGet(inst.ID())
+		if err != nil {
+			if err == db.ErrNoSuchObject {
+				return nil
+			}
+
+			return err
+		}
+
+		sample.counter = usage.Counter
+		sample.usage = usage.Usage
+		sample.period = usage.Period
+		sample.persistedPeriod = usage.Period
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sample, nil
+}
+
+// networkQuotaPersist records the usage of a container, adding the traffic sent since the last
+// record to the monthly usage of its project.
+func networkQuotaPersist(s *state.State, inst instance.Instance, sample *networkQuotaSample, now time.Time) error {
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		err := tx.InstanceNetworkUsageSet(inst.ID(), db.InstanceNetworkUsage{Period: sample.period, Usage: sample.usage, Counter: sample.counter})
+		if err != nil {
+			return err
+		}
+
+		if sample.pending == 0 {
+			return nil
+		}
+
+		return tx.ProjectNetworkUsageAdd(inst.Project(), networkQuotaPeriod("month", now), sample.pending)
+	})
+	if err != nil {
+		return err
+	}
+
+	sample.pending = 0
+	sample.persistedPeriod = sample.period
+	sample.persistedAt = now
+	return nil
+}
+
+// networkQuotaThrottleRate returns the egress rate limit set by limits.network.quota.throttle in the
+// given instance or project config.
+func networkQuotaThrottleRate(config map[string]string) string {
+	if config["limits.network.quota.throttle"] == "" {
+		return "1Mbit"
+	}
+
+	return config["limits.network.quota.throttle"]
+}
+
+// networkQuotaProjectsCheck returns the projects whose containers sent more than the project's
+// limits.network.quota during the current month, based on the usage persisted by all the cluster
+// members.
+func networkQuotaProjectsCheck(projectsConfig map[string]map[string]string, projectsUsage map[string]int64) map[string]bool {
+	exceeded := map[string]bool{}
+	for name, config := range projectsConfig {
+		if config["limits.network.quota"] == "" {
+			continue
+		}
+
+		quota, err := units.ParseByteSizeString(config["limits.network.quota"])
+		if err != nil || quota <= 0 {
+			continue
+		}
+
+		exceeded[name] = projectsUsage[name] > quota
+	}
+
+	return exceeded
+}
+
+// Warn once per period about the projects which exceeded their network quota.
+func networkQuotaProjectsNotify(s *state.State, now time.Time, projectsExceeded map[string]bool, projectsConfig map[string]map[string]string, projectsUsage map[string]int64) {
+	period := networkQuotaPeriod("month", now)
+	for name := range networkQuotaProjectsExceeded {
+		_, ok := projectsExceeded[name]
+		if !ok {
+			delete(networkQuotaProjectsExceeded, name)
+		}
+	}
+
+	for name, exceeded := range projectsExceeded {
+		if !exceeded || networkQuotaProjectsExceeded[name] == period {
+			continue
+		}
+
+		networkQuotaProjectsExceeded[name] = period
+
+		usage := projectsUsage[name]
+		quota, _ := units.ParseByteSizeString(projectsConfig[name]["limits.network.quota"])
+
+		logger.Warn("Project exceeded its network quota", log.Ctx{"project": name, "usage": usage, "quota": quota})
+		s.Events.SendLifecycle(name, "project-network-quota-exceeded",
+			fmt.Sprintf("/1.0/projects/%s", name), map[string]interface{}{"usage": usage, "quota": quota, "period": period})
+	}
+}
+
+// networkQuotaPeriod returns the identifier of the accounting period the given time belongs to.
+func networkQuotaPeriod(period string, now time.Time) string {
+	if period == "day" {
+		return now.UTC().Format("2006-01-02")
+	}
+
+	return now.UTC().Format("2006-01")
+}
+
+// networkQuotaDelta returns the number of bytes sent since the previous check.
+func networkQuotaDelta(previous int64, current int64) int64 {
+	// Counters going backwards mean the interfaces were recreated.
+	if current < previous {
+		return current
+	}
+
+	return current - previous
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkQuotaPeriod(t *testing.T) {
+	now := time.Date(2020, time.March, 31, 23, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, "2020-03", networkQuotaPeriod("", now))
+	assert.Equal(t, "2020-03", networkQuotaPeriod("month", now))
+	assert.Equal(t, "2020-03-31", networkQuotaPeriod("day", now))
+	assert.Equal(t, "2020-04", networkQuotaPeriod("month", now.Add(time.Hour)))
+}
+
+func TestNetworkQuotaDelta(t *testing.T) {
+	assert.Equal(t, int64(500), networkQuotaDelta(1000, 1500))
+	assert.Equal(t, int64(0), networkQuotaDelta(1000, 1000))
+	assert.Equal(t, int64(200), networkQuotaDelta(1000, 200))
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// Interval at which the OOM kill counters and memory pressure of the containers are checked.
+const oomCheckInterval = time.Minute
+
+// Share of time, in percent, all the processes of a container must have been stalled waiting for
+// memory over the last minute for it to be reported as under memory pressure.
+const oomPressureThreshold = 10.0
+
+// oomSample holds what was last seen of a running container by the OOM check.
+type oomSample struct {
+	started  time.Time // Start time of the container, telling whether its counter was reset.
+	kills    int64     // OOM kill counter.
+	pressure bool      // Whether the container was under memory pressure.
+}
+
+// Last state seen for each running container, keyed by project and name, and the time of the last
+// check. Only accessed by the OOM check task.
+var oomSamples = map[string]*oomSample{}
+var oomLastCheck = time.Now()
+
+func oomCheckTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		oomCheck(d.State(), time.Now())
+	}
+
+	return f, task.Every(oomCheckInterval)
+}
+
+// Report the processes killed by the OOM killer in the running containers since the last check,
+// through a lifecycle event and an entry in the instance history, as well as the containers coming
+// under memory pressure.
+func oomCheck(s *state.State, now time.Time) {
+	insts, err := instance.LoadNodeAll(s, instancetype.Container)
+	if err != nil {
+		logger.Error("Failed to load containers for OOM check", log.Ctx{"err": err})
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, inst := range insts {
+		if !inst.IsRunning() {
+			continue
+		}
+
+		c := inst.(instance.Container)
+		key := project.Instance(inst.Project(), inst.Name())
+		seen[key] = true
+
+		sample, ok := oomSamples[key]
+		if !ok || !sample.started.Equal(inst.LastUsedDate()) {
+			previous := sample
+			sample = &oomSample{started: inst.LastUsedDate()}
+			oomSamples[key] = sample
+
+			// The counter starts from zero along with the container. Only the containers which were
+			// already running before the previous check, e.g. when LXD starts, may have had their
+			// kills reported already.
+			if previous == nil && inst.LastUsedDate().Before(oomLastCheck) {
+				sample.kills = -1
+			}
+		}
+
+		count, err := c.MemoryOOMKills()
+		if err == nil {
+			if sample.kills >= 0 && count > sample.kills {
+				logger.Warn("Processes killed by the OOM killer", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "count": count - sample.kills})
+				s.Events.SendLifecycle(inst.Project(), "container-oom",
+					fmt.Sprintf("/1.0/containers/%s", inst.Name()), map[string]interface{}{"kills": count - sample.kills})
+				instanceHistoryRecord(s, inst, "oom", nil, "")
+			}
+
+			sample.kills = count
+		}
+
+		pressure, err := c.MemoryPressure()
+		if err != nil {
+			continue
+		}
+
+		underPressure := pressure >= oomPressureThreshold
+		if underPressure && !sample.pressure {
+			logger.Warn("Container is under memory pressure", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "pressure": pressure})
+			s.Events.SendLifecycle(inst.Project(), "container-memory-pressure",
+				fmt.Sprintf("/1.0/containers/%s", inst.Name()), map[string]interface{}{"pressure": pressure})
+		}
+
+		sample.pressure = underPressure
+	}
+
+	for key := range oomSamples {
+		if !seen[key] {
+			delete(oomSamples, key)
+		}
+	}
+
+	oomLastCheck = now
+}
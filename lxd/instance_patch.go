@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"reflect"
 
 	"github.com/gorilla/mux"
 
@@ -74,6 +75,8 @@ func containerPatch(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(fmt.Errorf("Can't call PATCH in restore mode"))
 	}
 
+	deviceConfig.KeepSecrets(req.Devices, c.LocalDevices().CloneNative())
+
 	// Check if architecture was passed
 	var architecture int
 	_, err = reqRaw.GetString("architecture")
@@ -121,6 +124,14 @@ func containerPatch(d *Daemon, r *http.Request) response.Response {
 		}
 	}
 
+	// Reject profiles conflicting with each other when changing them.
+	if !reflect.DeepEqual(req.Profiles, c.Profiles()) {
+		err = profilesValidateConflicts(d.cluster, project, req.Profiles, req.Config, req.Devices)
+		if err != nil {
+			return response.BadRequest(err)
+		}
+	}
+
 	// Check project limits.
 	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
 		return projecthelpers.AllowInstanceUpdate(tx, project, name, req, c.LocalConfig())
@@ -140,7 +151,7 @@ func containerPatch(d *Daemon, r *http.Request) response.Response {
 		Project:      project,
 	}
 
-	err = c.Update(args, true)
+	err = instanceUpdateWithSnapshot(d.State(), c, args, nil)
 	if err != nil {
 		return response.SmartError(err)
 	}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/pborman/uuid"
@@ -171,13 +172,47 @@ func containerPost(d *Daemon, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
+	// A POST with a different project moves the instance to that project.
+	if req.Project != "" && req.Project != project {
+		if req.Migration {
+			return response.BadRequest(fmt.Errorf("Moving an instance to another project can't be combined with a migration"))
+		}
+
+		return containerPostProjectMove(d, r, inst, req.Project, req.Name)
+	}
+
 	if req.Migration {
+		maxDowntime, err := instancePostMaxDowntime(req, stateful)
+		if err != nil {
+			return response.BadRequest(err)
+		}
+
 		if targetNode != "" {
+			if maxDowntime > 0 {
+				return response.BadRequest(fmt.Errorf("A maximum downtime can't be set when moving an instance between cluster members"))
+			}
+
 			// Check whether the container is running.
 			if !sourceNodeOffline && inst.IsRunning() {
 				return response.BadRequest(fmt.Errorf("Container is running"))
 			}
 
+			// Check that the target is allowed by the cluster groups of the instance.
+			placement := api.InstancesPost{
+				InstancePut: api.InstancePut{
+					Config:   inst.LocalConfig(),
+					Profiles: inst.Profiles(),
+				},
+			}
+
+			err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+				_, err := clusterGroupsPlacement(tx, project, placement, targetNode)
+				return err
+			})
+			if err != nil {
+				return response.BadRequest(err)
+			}
+
 			// Check if we are migrating a ceph-based container.
 			poolName, err := d.cluster.InstancePool(project, name)
 			if err != nil {
@@ -211,6 +246,8 @@ func containerPost(d *Daemon, r *http.Request) response.Response {
 			return response.InternalError(err)
 		}
 
+		ws.maxDowntime = maxDowntime
+
 		resources := map[string][]string{}
 		resources["instances"] = []string{name}
 		resources["containers"] = resources["instances"]
@@ -265,6 +302,138 @@ func containerPost(d *Daemon, r *http.Request) response.Response {
 	return operations.OperationResponse(op)
 }
 
+// Move an instance, along with its snapshots, to another project on the same
+// node. The instance is copied into the target project and then deleted from
+// its current one.
+// instancePostMaxDowntime returns the maximum downtime requested for a migration, which only applies to
+// stateful migrations.
+func instancePostMaxDowntime(req api.InstancePost, stateful bool) (time.Duration, error) {
+	if req.MaxDowntime < 0 {
+		return 0, fmt.Errorf("Invalid maximum downtime")
+	}
+
+	if req.MaxDowntime > 0 && !stateful {
+		return 0, fmt.Errorf("A maximum downtime can only be set for live migrations")
+	}
+
+	return time.Duration(req.MaxDowntime) * time.Millisecond, nil
+}
+
+func containerPostProjectMove(d *Daemon, r *http.Request, inst instance.Instance, targetProject string, newName string) response.Response {
+	if newName == "" {
+		newName = inst.Name()
+	}
+
+	// The permission on the source project was checked by the access handler, the instance is
+	// created in the target project as well.
+	if !d.userIsAdmin(r) && !d.userHasPermission(r, targetProject, "manage-containers") {
+		return response.Forbidden(nil)
+	}
+
+	if inst.IsRunning() {
+		return response.BadRequest(fmt.Errorf("Instance must be stopped to be moved to another project"))
+	}
+
+	// Custom volumes attached to the instance stay where they are, so they
+	// must be reachable from the target project too.
+	sourceVolumesProject, err := project.StorageVolumeProject(d.cluster, inst.Project(), db.StoragePoolVolumeTypeCustom)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	targetVolumesProject, err := project.StorageVolumeProject(d.cluster, targetProject, db.StoragePoolVolumeTypeCustom)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	for devName, dev := range inst.LocalDevices() {
+		if dev["type"] == "disk" && dev["pool"] != "" && dev["path"] != "/" && sourceVolumesProject != targetVolumesProject {
+			return response.BadRequest(fmt.Errorf("Custom volume %q attached as %q isn't available in project %q, detach it first", dev["source"], devName, targetProject))
+		}
+	}
+
+	nameInUse := false
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		_, err := tx.ProjectGet(targetProject)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to load project %q", targetProject)
+		}
+
+		_, err = tx.InstanceID(targetProject, newName)
+		if err == nil {
+			nameInUse = true
+			return nil
+		}
+
+		if err != db.ErrNoSuchObject {
+			return err
+		}
+
+		// Revalidate the target project limits and restrictions.
+		req := api.InstancesPost{
+			Name: newName,
+			Type: api.InstanceType(inst.Type().String()),
+			InstancePut: api.InstancePut{
+				Config:   inst.LocalConfig(),
+				Devices:  inst.LocalDevices().CloneNative(),
+				Profiles: inst.Profiles(),
+			},
+		}
+
+		return project.AllowInstanceCreation(tx, targetProject, req)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if nameInUse {
+		return response.Conflict(fmt.Errorf("Name %q already in use in project %q, pick a new name for the instance", newName, targetProject))
+	}
+
+	// The profiles must exist in the target project as well.
+	_, err = d.cluster.ProfilesGet(targetProject, inst.Profiles())
+	if err != nil {
+		return response.BadRequest(errors.Wrapf(err, "Profiles of the instance aren't available in project %q", targetProject))
+	}
+
+	args := db.InstanceArgs{
+		Project:      targetProject,
+		Architecture: inst.Architecture(),
+		Config:       inst.LocalConfig(),
+		Type:         inst.Type(),
+		Description:  inst.Description(),
+		Devices:      inst.LocalDevices(),
+		Ephemeral:    inst.IsEphemeral(),
+		Name:         newName,
+		Profiles:     inst.Profiles(),
+	}
+
+	run := func(op *operations.Operation) error {
+		_, err := instanceCreateAsCopy(d.State(), args, inst, false, false, op)
+		if err != nil {
+			return errors.Wrap(err, "Failed to copy instance to the target project")
+		}
+
+		err = inst.Delete()
+		if err != nil {
+			return errors.Wrap(err, "Failed to delete instance from the source project")
+		}
+
+		return nil
+	}
+
+	resources := map[string][]string{}
+	resources["instances"] = []string{inst.Name()}
+	resources["containers"] = resources["instances"]
+
+	op, err := operations.OperationCreate(d.State(), inst.Project(), operations.OperationClassTask, db.OperationContainerMigrate, resources, nil, run, nil, nil)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	return operations.OperationResponse(op)
+}
+
 // Move a non-ceph container to another cluster node.
 func containerPostClusteringMigrate(d *Daemon, c instance.Instance, oldName, newName, newNode string) response.Response {
 	cert := d.endpoints.NetworkCert()
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestInstancePostMaxDowntime(t *testing.T) {
+	maxDowntime, err := instancePostMaxDowntime(api.InstancePost{Migration: true, Live: true, MaxDowntime: 500}, true)
+	require.NoError(t, err)
+	assert.Equal(t, 500*time.Millisecond, maxDowntime)
+
+	maxDowntime, err = instancePostMaxDowntime(api.InstancePost{Migration: true}, false)
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), maxDowntime)
+
+	_, err = instancePostMaxDowntime(api.InstancePost{Migration: true, Live: true, MaxDowntime: -1}, true)
+	assert.EqualError(t, err, "Invalid maximum downtime")
+
+	_, err = instancePostMaxDowntime(api.InstancePost{Migration: true, MaxDowntime: 500}, false)
+	assert.EqualError(t, err, "A maximum downtime can only be set for live migrations")
+}
@@ -1,15 +1,20 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"path/filepath"
+	"reflect"
 
 	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
 
 	"github.com/lxc/lxd/lxd/db"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/operations"
 	projecthelpers "github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/response"
@@ -61,6 +66,8 @@ func containerPut(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
+	deviceConfig.KeepSecrets(configRaw.Devices, c.LocalDevices().CloneNative())
+
 	architecture, err := osarch.ArchitectureId(configRaw.Architecture)
 	if err != nil {
 		architecture = 0
@@ -74,6 +81,35 @@ func containerPut(d *Daemon, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
+	// Validate the paths of a partial restore.
+	if len(configRaw.RestorePaths) > 0 {
+		if configRaw.Restore == "" {
+			return response.BadRequest(fmt.Errorf("Paths to restore require a snapshot to restore from"))
+		}
+
+		if configRaw.Stateful {
+			return response.BadRequest(fmt.Errorf("Stateful restores can't be limited to some paths"))
+		}
+
+		if c.Type() != instancetype.Container {
+			return response.BadRequest(fmt.Errorf("Restoring some paths is only supported for containers"))
+		}
+
+		for _, path := range configRaw.RestorePaths {
+			if !filepath.IsAbs(path) || filepath.Clean(path) != path || path == "/" {
+				return response.BadRequest(fmt.Errorf("Invalid path to restore %q", path))
+			}
+		}
+	}
+
+	// Reject profiles conflicting with each other when changing them.
+	if configRaw.Restore == "" && !reflect.DeepEqual(configRaw.Profiles, c.Profiles()) {
+		err = profilesValidateConflicts(d.cluster, project, configRaw.Profiles, configRaw.Config, configRaw.Devices)
+		if err != nil {
+			return response.BadRequest(err)
+		}
+	}
+
 	var do func(*operations.Operation) error
 	var opType db.OperationType
 	if configRaw.Restore == "" {
@@ -89,7 +125,7 @@ func containerPut(d *Daemon, r *http.Request) response.Response {
 				Project:      project,
 			}
 
-			err = c.Update(args, true)
+			err = instanceUpdateWithSnapshot(d.State(), c, args, op)
 			if err != nil {
 				return err
 			}
@@ -101,6 +137,15 @@ func containerPut(d *Daemon, r *http.Request) response.Response {
 	} else {
 		// Snapshot Restore
 		do = func(op *operations.Operation) error {
+			_, err := instanceSnapshotBeforeChange(d.State(), c, "restore", op)
+			if err != nil {
+				return err
+			}
+
+			if len(configRaw.RestorePaths) > 0 {
+				return instanceSnapRestorePaths(op.Context(), d.State(), project, name, configRaw.Restore, configRaw.RestorePaths)
+			}
+
 			return instanceSnapRestore(d.State(), project, name, configRaw.Restore, configRaw.Stateful)
 		}
 
@@ -146,3 +191,53 @@ func instanceSnapRestore(s *state.State, project, name, snap string, stateful bo
 
 	return nil
 }
+
+// Restore only the given paths of the container from the snapshot, the same
+// way as restoring snapshot files.
+func instanceSnapRestorePaths(ctx context.Context, s *state.State, project, name, snap string, paths []string) error {
+	// normalize snapshot name
+	if !shared.IsSnapshot(snap) {
+		snap = name + shared.SnapshotDelimiter + snap
+	}
+
+	inst, err := instance.LoadByProjectAndName(s, project, name)
+	if err != nil {
+		return err
+	}
+
+	source, err := instance.LoadByProjectAndName(s, project, snap)
+	if err != nil {
+		switch err {
+		case db.ErrNoSuchObject:
+			return fmt.Errorf("Snapshot %s does not exist", snap)
+		default:
+			return err
+		}
+	}
+
+	// Keep the snapshot and the instance mounted for the whole copy.
+	ourStart, err := source.StorageStart()
+	if err != nil {
+		return err
+	}
+	if ourStart {
+		defer source.StorageStop()
+	}
+
+	ourStart, err = inst.StorageStart()
+	if err != nil {
+		return err
+	}
+	if ourStart {
+		defer inst.StorageStop()
+	}
+
+	for _, path := range paths {
+		err := snapshotFilesRestore(ctx, source, inst, path, path)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to restore %q", path)
+		}
+	}
+
+	return nil
+}
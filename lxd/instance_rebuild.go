@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/response"
+	storagePools "github.com/lxc/lxd/lxd/storage"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/osarch"
+)
+
+// Replace the root filesystem of a stopped container with a fresh copy of an image, keeping its
+// name, configuration, profiles, devices and MAC addresses.
+func containerRebuildPost(d *Daemon, r *http.Request) response.Response {
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to an instance on a different node
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, project, name, instanceType)
+	if err != nil {
+		return response.SmartError(err)
+	}
+	if resp != nil {
+		return resp
+	}
+
+	req := api.InstanceRebuildPost{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if req.Source.Type != "" && req.Source.Type != "image" {
+		return response.BadRequest(fmt.Errorf("Instances can only be rebuilt from an image"))
+	}
+
+	inst, err := instance.LoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if inst.Type() != instancetype.Container {
+		return response.BadRequest(fmt.Errorf("Only containers can be rebuilt"))
+	}
+
+	if inst.IsRunning() {
+		return response.BadRequest(fmt.Errorf("The container must be stopped to be rebuilt"))
+	}
+
+	// Default to the image currently targeted by the alias (and channel) the container was
+	// created from, falling back to the image it was created from if that alias is gone.
+	if req.Source.Fingerprint == "" && req.Source.Alias == "" && req.Source.Properties == nil {
+		alias := inst.LocalConfig()["volatile.base_image.alias"]
+		if alias != "" {
+			_, err := d.cluster.ImageAliasResolve(project, alias, true)
+			if err == nil {
+				req.Source.Alias = alias
+			}
+		}
+
+		if req.Source.Alias == "" {
+			req.Source.Fingerprint = inst.LocalConfig()["volatile.base_image"]
+			if req.Source.Fingerprint == "" {
+				return response.BadRequest(fmt.Errorf("The container wasn't created from an image, one must be specified"))
+			}
+		}
+	}
+
+	hash, err := instance.ResolveImage(d.State(), project, req.Source)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	requestor := instanceHistoryRequestor(r)
+
+	run := func(op *operations.Operation) error {
+		var info *api.Image
+		if req.Source.Server != "" {
+			autoUpdate, err := cluster.ConfigGetBool(d.cluster, "images.auto_update_cached")
+			if err != nil {
+				return err
+			}
+
+			info, err = d.ImageDownload(
+				op, req.Source.Server, req.Source.Protocol, req.Source.Certificate,
+				req.Source.Secret, hash, "container", true, autoUpdate, "", true, project)
+			if err != nil {
+				return err
+			}
+		} else {
+			_, info, err = d.cluster.ImageGet(project, hash, false, false)
+			if err != nil {
+				return err
+			}
+		}
+
+		if info.Type != "" && info.Type != "container" {
+			return fmt.Errorf("Image %s isn't a container image", info.Fingerprint)
+		}
+
+		arch, err := osarch.ArchitectureId(info.Architecture)
+		if err != nil {
+			return err
+		}
+
+		if arch != inst.Architecture() {
+			return fmt.Errorf("The architecture of image %s doesn't match the one of the container", info.Fingerprint)
+		}
+
+		err = instanceImageEnsureLocal(d, project, info.Fingerprint)
+		if err != nil {
+			return err
+		}
+
+		// Keep the container from being started while it's rebuilt.
+		unlock, err := instanceOperationLockHold(inst, "rebuild")
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		if inst.IsRunning() {
+			return fmt.Errorf("The container must be stopped to be rebuilt")
+		}
+
+		_, err = instanceSnapshotBeforeChange(d.State(), inst, "rebuild", op)
+		if err != nil {
+			return err
+		}
+
+		pool, err := storagePools.GetPoolByInstance(d.State(), inst)
+		if err != nil {
+			return err
+		}
+
+		// The volume can't be replaced while it's mounted.
+		_, err = pool.UnmountInstance(inst, op)
+		if err != nil {
+			return err
+		}
+
+		err = pool.ResetInstanceFromImage(inst, info.Fingerprint, op)
+		if err != nil {
+			return err
+		}
+
+		err = d.cluster.ImageLastAccessUpdate(info.Fingerprint, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+
+		args := db.InstanceArgs{
+			Architecture: inst.Architecture(),
+			Config:       instanceRebuildConfig(inst.LocalConfig(), info.Properties),
+			Description:  inst.Description(),
+			Devices:      inst.LocalDevices(),
+			Ephemeral:    inst.IsEphemeral(),
+			Profiles:     inst.Profiles(),
+			Project:      inst.Project(),
+			Type:         inst.Type(),
+			Snapshot:     inst.IsSnapshot(),
+		}
+
+		err = inst.Update(args, false)
+		if err != nil {
+			return err
+		}
+
+		// Aliases of remote servers don't mean anything locally.
+		alias := ""
+		if req.Source.Server == "" {
+			alias = req.Source.Alias
+		}
+
+		err = inst.VolatileSet(instanceRebuildVolatile(inst.LocalConfig(), info.Fingerprint, alias))
+		if err != nil {
+			return err
+		}
+
+		instanceHistoryRecord(d.State(), inst, "rebuild", op, requestor)
+		d.State().Events.SendLifecycle(project, "container-rebuilt",
+			fmt.Sprintf("/1.0/containers/%s", name), map[string]interface{}{"image": info.Fingerprint})
+
+		return nil
+	}
+
+	resources := map[string][]string{}
+	resources["instances"] = []string{name}
+	resources["containers"] = resources["instances"]
+
+	op, err := operations.OperationCreate(d.State(), project, operations.OperationClassTask, db.OperationContainerRebuild, resources, nil, run, nil, nil)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	return operations.OperationResponse(op)
+}
+
+// instanceRebuildConfig returns the local configuration of a container rebuilt from an image with
+// the given properties, its "image.*" keys being replaced with the ones of the new image.
+func instanceRebuildConfig(localConfig map[string]string, properties map[string]string) map[string]string {
+	config := map[string]string{}
+	for k, v := range localConfig {
+		if !strings.HasPrefix(k, "image.") {
+			config[k] = v
+		}
+	}
+
+	for k, v := range properties {
+		config[fmt.Sprintf("image.%s", k)] = v
+	}
+
+	return config
+}
+
+// instanceRebuildVolatile returns the volatile keys to set on a container rebuilt from the given
+// image. The new volume isn't shifted and hasn't been provisioned yet.
+func instanceRebuildVolatile(localConfig map[string]string, fingerprint string, alias string) map[string]string {
+	volatile := map[string]string{
+		"volatile.base_image":       fingerprint,
+		"volatile.base_image.alias": alias,
+		"volatile.last_state.idmap": "[]",
+	}
+
+	for k := range localConfig {
+		if strings.HasPrefix(k, "volatile.provision.") {
+			volatile[k] = ""
+		}
+	}
+
+	return volatile
+}
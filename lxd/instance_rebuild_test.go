@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The image keys are replaced, the rest of the configuration is kept.
+func TestInstanceRebuildConfig(t *testing.T) {
+	localConfig := map[string]string{
+		"image.os":             "ubuntu",
+		"image.release":        "bionic",
+		"limits.cpu":           "2",
+		"volatile.eth0.hwaddr": "00:16:3e:00:00:01",
+	}
+
+	config := instanceRebuildConfig(localConfig, map[string]string{"os": "debian", "architecture": "amd64"})
+	assert.Equal(t, map[string]string{
+		"image.os":             "debian",
+		"image.architecture":   "amd64",
+		"limits.cpu":           "2",
+		"volatile.eth0.hwaddr": "00:16:3e:00:00:01",
+	}, config)
+
+	// The configuration of the instance isn't modified.
+	assert.Equal(t, "bionic", localConfig["image.release"])
+}
+
+// The provisioning state and the idmap of the old volume are reset.
+func TestInstanceRebuildVolatile(t *testing.T) {
+	localConfig := map[string]string{
+		"volatile.base_image":           "old",
+		"volatile.eth0.hwaddr":          "00:16:3e:00:00:01",
+		"volatile.provision.cloud-init": "done",
+		"volatile.last_state.idmap":     `[{"Isuid":true}]`,
+	}
+
+	volatile := instanceRebuildVolatile(localConfig, "new", "ubuntu/focal")
+	assert.Equal(t, map[string]string{
+		"volatile.base_image":           "new",
+		"volatile.base_image.alias":     "ubuntu/focal",
+		"volatile.last_state.idmap":     "[]",
+		"volatile.provision.cloud-init": "",
+	}, volatile)
+}
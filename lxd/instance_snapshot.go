@@ -23,6 +23,7 @@ import (
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/version"
+	"github.com/pkg/errors"
 )
 
 func containerSnapshotsGet(d *Daemon, r *http.Request) response.Response {
@@ -75,7 +76,7 @@ func containerSnapshotsGet(d *Daemon, r *http.Request) response.Response {
 		}
 
 		for _, snap := range snaps {
-			render, _, err := snap.Render(storagePools.RenderSnapshotUsage(d.State(), snap))
+			render, _, err := snap.Render(storagePools.RenderSnapshotUsage(d.State(), snap), instanceRenderHideSecrets)
 			if err != nil {
 				continue
 			}
@@ -306,7 +307,7 @@ func snapshotPut(d *Daemon, r *http.Request, sc instance.Instance, name string)
 }
 
 func snapshotGet(s *state.State, snapInst instance.Instance, name string) response.Response {
-	render, _, err := snapInst.Render(storagePools.RenderSnapshotUsage(s, snapInst))
+	render, _, err := snapInst.Render(storagePools.RenderSnapshotUsage(s, snapInst), instanceRenderHideSecrets)
 	if err != nil {
 		return response.SmartError(err)
 	}
@@ -414,7 +415,7 @@ func snapshotPost(d *Daemon, r *http.Request, sc instance.Instance, containerNam
 	}
 
 	rename := func(op *operations.Operation) error {
-		return sc.Rename(fullName)
+		return snapshotMountRename(d.State(), sc, newName)
 	}
 
 	resources := map[string][]string{}
@@ -430,6 +431,11 @@ func snapshotPost(d *Daemon, r *http.Request, sc instance.Instance, containerNam
 
 func snapshotDelete(s *state.State, sc instance.Instance, name string) response.Response {
 	remove := func(op *operations.Operation) error {
+		err := snapshotMountRemove(s, sc)
+		if err != nil {
+			return errors.Wrap(err, "Failed to unmount snapshot")
+		}
+
 		return sc.Delete()
 	}
 
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/response"
+	storagePools "github.com/lxc/lxd/lxd/storage"
+	storageDrivers "github.com/lxc/lxd/lxd/storage/drivers"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Kinds of changes reported by the snapshot diff.
+const (
+	snapshotDiffAdded   = "added"
+	snapshotDiffChanged = "changed"
+	snapshotDiffRemoved = "removed"
+)
+
+// List the files which differ between a snapshot and either another snapshot
+// of the same instance (?target=<snapshot>) or the current instance.
+func containerSnapshotDiffGet(d *Daemon, r *http.Request) response.Response {
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	project := projectParam(r)
+	containerName := mux.Vars(r)["name"]
+
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, project, containerName, instanceType)
+	if err != nil {
+		return response.SmartError(err)
+	}
+	if resp != nil {
+		return resp
+	}
+
+	snapshotName, err := url.QueryUnescape(mux.Vars(r)["snapshotName"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	snap, err := instance.LoadByProjectAndName(d.State(), project, containerName+shared.SnapshotDelimiter+snapshotName)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if snap.Type() != instancetype.Container {
+		return response.BadRequest(fmt.Errorf("Snapshot diff is only supported for containers"))
+	}
+
+	targetName := containerName
+	target := r.FormValue("target")
+	if target != "" {
+		targetName = containerName + shared.SnapshotDelimiter + target
+	}
+
+	targetInst, err := instance.LoadByProjectAndName(d.State(), project, targetName)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	run := func(op *operations.Operation) error {
+		for _, inst := range []instance.Instance{snap, targetInst} {
+			ourStart, err := inst.StorageStart()
+			if err != nil {
+				return errors.Wrapf(err, "Failed to mount %q", inst.Name())
+			}
+			if ourStart {
+				defer inst.StorageStop()
+			}
+		}
+
+		// Use the storage driver when it can compare the volumes,
+		// otherwise walk the mounted filesystems.
+		pool, err := storagePools.GetPoolByInstance(d.State(), snap)
+		if err != nil {
+			return err
+		}
+
+		diff, err := pool.DiffInstanceSnapshot(snap, targetInst, op)
+		if errors.Cause(err) == storageDrivers.ErrNotSupported {
+			diff, err = snapshotDiff(snap.RootfsPath(), targetInst.RootfsPath())
+		}
+		if err != nil {
+			return err
+		}
+
+		return op.UpdateMetadata(map[string]interface{}{"changes": diff})
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{containerName}
+
+	op, err := operations.OperationCreate(d.State(), project, operations.OperationClassTask, db.OperationSnapshotDiff, resources, nil, run, nil, nil)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	return operations.OperationResponse(op)
+}
+
+// Metadata compared by the snapshot diff for a single path.
+type snapshotDiffInfo struct {
+	mode  os.FileMode
+	size  int64
+	mtime int64
+	uid   uint32
+	gid   uint32
+	link  string
+}
+
+// Return the changes needed to go from the tree at from to the tree at to,
+// sorted by path. Files are compared using their metadata rather than their
+// content, directories are only reported as changed if their mode or owner
+// changed.
+func snapshotDiff(from string, to string) ([]api.InstanceSnapshotDiffEntry, error) {
+	fromInfo, err := snapshotDiffWalk(from)
+	if err != nil {
+		return nil, err
+	}
+
+	toInfo, err := snapshotDiffWalk(to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := []api.InstanceSnapshotDiffEntry{}
+	for path, before := range fromInfo {
+		after, ok := toInfo[path]
+		if !ok {
+			diff = append(diff, api.InstanceSnapshotDiffEntry{Path: path, Change: snapshotDiffRemoved})
+			continue
+		}
+
+		if before.mode != after.mode || before.uid != after.uid || before.gid != after.gid {
+			diff = append(diff, api.InstanceSnapshotDiffEntry{Path: path, Change: snapshotDiffChanged})
+			continue
+		}
+
+		if !before.mode.IsDir() && (before.size != after.size || before.mtime != after.mtime || before.link != after.link) {
+			diff = append(diff, api.InstanceSnapshotDiffEntry{Path: path, Change: snapshotDiffChanged})
+		}
+	}
+
+	for path := range toInfo {
+		_, ok := fromInfo[path]
+		if !ok {
+			diff = append(diff, api.InstanceSnapshotDiffEntry{Path: path, Change: snapshotDiffAdded})
+		}
+	}
+
+	sort.Slice(diff, func(i, j int) bool { return diff[i].Path < diff[j].Path })
+
+	return diff, nil
+}
+
+// Collect the metadata of all paths under root, keyed by their absolute path
+// relative to root.
+func snapshotDiffWalk(root string) (map[string]snapshotDiffInfo, error) {
+	infos := map[string]snapshotDiffInfo{}
+
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// Skip the paths removed during the walk.
+			if os.IsNotExist(err) {
+				return nil
+			}
+
+			return err
+		}
+
+		if path == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		info := snapshotDiffInfo{
+			mode:  fi.Mode(),
+			size:  fi.Size(),
+			mtime: fi.ModTime().UnixNano(),
+		}
+
+		stat, ok := fi.Sys().(*syscall.Stat_t)
+		if ok {
+			info.uid = stat.Uid
+			info.gid = stat.Gid
+		}
+
+		if fi.Mode()&os.ModeSymlink != 0 {
+			info.link, err = os.Readlink(path)
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+
+				return err
+			}
+		}
+
+		infos["/"+rel] = info
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to walk %q", root)
+	}
+
+	return infos, nil
+}
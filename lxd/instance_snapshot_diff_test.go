@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestSnapshotDiff(t *testing.T) {
+	from, err := ioutil.TempDir("", "lxd-snapshot-diff-")
+	require.NoError(t, err)
+	defer os.RemoveAll(from)
+
+	to, err := ioutil.TempDir("", "lxd-snapshot-diff-")
+	require.NoError(t, err)
+	defer os.RemoveAll(to)
+
+	mtime := time.Now().Add(-time.Hour)
+	for _, root := range []string{from, to} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "etc"), 0755))
+		for _, name := range []string{"etc/hosts", "etc/hostname", "etc/passwd"} {
+			path := filepath.Join(root, name)
+			require.NoError(t, ioutil.WriteFile(path, []byte("content"), 0644))
+			require.NoError(t, os.Chtimes(path, mtime, mtime))
+		}
+	}
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(to, "etc/hosts"), []byte("new content"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(to, "etc/hostname")))
+	require.NoError(t, os.Chmod(filepath.Join(to, "etc/passwd"), 0600))
+	require.NoError(t, os.Symlink("/etc/hosts", filepath.Join(to, "hosts")))
+
+	diff, err := snapshotDiff(from, to)
+	require.NoError(t, err)
+
+	assert.Equal(t, []api.InstanceSnapshotDiffEntry{
+		{Path: "/etc/hostname", Change: "removed"},
+		{Path: "/etc/hosts", Change: "changed"},
+		{Path: "/etc/passwd", Change: "changed"},
+		{Path: "/hosts", Change: "added"},
+	}, diff)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/tools"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+func containerSnapshotFilesHandler(d *Daemon, r *http.Request) response.Response {
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, project, name, instanceType)
+	if err != nil {
+		return response.SmartError(err)
+	}
+	if resp != nil {
+		return resp
+	}
+
+	snapshotName, err := url.QueryUnescape(mux.Vars(r)["snapshotName"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	snap, err := instance.LoadByProjectAndName(d.State(), project, name+shared.SnapshotDelimiter+snapshotName)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if snap.Type() != instancetype.Container {
+		return response.BadRequest(fmt.Errorf("Snapshot files are only supported for containers"))
+	}
+
+	switch r.Method {
+	case "GET":
+		path := r.FormValue("path")
+		if path == "" {
+			return response.BadRequest(fmt.Errorf("missing path argument"))
+		}
+
+		return containerFileGet(snap, path, r)
+	case "POST":
+		return snapshotFilesPost(d, r, snap, name)
+	default:
+		return response.NotFound(fmt.Errorf("Method '%s' not found", r.Method))
+	}
+}
+
+// Copy the requested paths from the snapshot back into the instance, leaving
+// the rest of the instance untouched.
+func snapshotFilesPost(d *Daemon, r *http.Request, snap instance.Instance, name string) response.Response {
+	req := api.InstanceSnapshotFilesPost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if len(req.Paths) == 0 {
+		return response.BadRequest(fmt.Errorf("No paths to restore were provided"))
+	}
+
+	if req.Target != "" && len(req.Paths) > 1 {
+		return response.BadRequest(fmt.Errorf("A target can only be set when restoring a single path"))
+	}
+
+	for _, path := range req.Paths {
+		if !filepath.IsAbs(path) {
+			return response.BadRequest(fmt.Errorf("Path %q isn't absolute", path))
+		}
+	}
+
+	inst, err := instance.LoadByProjectAndName(d.State(), snap.Project(), name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	run := func(op *operations.Operation) error {
+		// Keep the snapshot and the instance mounted for the whole copy.
+		ourStart, err := snap.StorageStart()
+		if err != nil {
+			return err
+		}
+		if ourStart {
+			defer snap.StorageStop()
+		}
+
+		ourStart, err = inst.StorageStart()
+		if err != nil {
+			return err
+		}
+		if ourStart {
+			defer inst.StorageStop()
+		}
+
+		for _, path := range req.Paths {
+			target := path
+			if req.Target != "" {
+				target = req.Target
+			}
+
+			err := snapshotFilesRestore(op.Context(), snap, inst, path, target)
+			if err != nil {
+				return errors.Wrapf(err, "Failed to restore %q", path)
+			}
+		}
+
+		return nil
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operations.OperationCreate(d.State(), snap.Project(), operations.OperationClassTask, db.OperationSnapshotFilesRestore, resources, nil, run, nil, nil)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	return operations.OperationResponse(op)
+}
+
+// Copy srcPath from the snapshot to dstPath in the instance with rsync, recursing into
+// directories and keeping ownership, permissions, hard links, ACLs, extended attributes and
+// special files. Each file is replaced atomically.
+func snapshotFilesRestore(ctx context.Context, snap instance.Instance, inst instance.Instance, srcPath string, dstPath string) error {
+	src, err := snapshotFilesResolve(snap.RootfsPath(), srcPath)
+	if err != nil {
+		return err
+	}
+
+	dst, err := snapshotFilesResolve(inst.RootfsPath(), dstPath)
+	if err != nil {
+		return err
+	}
+
+	srcInfo, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	// Replace a symlink in the way rather than writing through it.
+	dstInfo, err := os.Lstat(dst)
+	if err == nil && dstInfo.Mode()&os.ModeSymlink != 0 && srcInfo.Mode()&os.ModeSymlink == 0 {
+		err = os.Remove(dst)
+		if err != nil {
+			return err
+		}
+	}
+
+	if srcInfo.IsDir() {
+		src = shared.AddSlash(src)
+	}
+
+	_, err = tools.Run(ctx, "rsync", "-aHAX", "--numeric-ids", "--", src, dst)
+	return err
+}
+
+// snapshotFilesResolve returns the host path of the given path within a root filesystem, failing
+// if its parent doesn't exist or goes through a symlink, which could point outside of it.
+func snapshotFilesResolve(rootfs string, path string) (string, error) {
+	path = filepath.Clean("/" + path)
+	if path == "/" {
+		return "", fmt.Errorf("The root directory can't be restored")
+	}
+
+	current := rootfs
+	for _, part := range strings.Split(filepath.Dir(path), "/") {
+		if part == "" {
+			continue
+		}
+
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if err != nil {
+			return "", err
+		}
+
+		if !info.IsDir() {
+			return "", fmt.Errorf("Parent %q of %q isn't a directory", strings.TrimPrefix(current, rootfs), path)
+		}
+	}
+
+	return filepath.Join(rootfs, path), nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Paths resolve within the root filesystem, unless their parent goes through a symlink.
+func TestSnapshotFilesResolve(t *testing.T) {
+	rootfs, err := ioutil.TempDir("", "lxd_snapshot_files_")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootfs)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(rootfs, "etc", "app"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(rootfs, "etc", "hosts"), []byte{}, 0644))
+	require.NoError(t, os.Symlink("/etc", filepath.Join(rootfs, "link")))
+
+	path, err := snapshotFilesResolve(rootfs, "/etc/app/config")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(rootfs, "etc", "app", "config"), path)
+
+	path, err = snapshotFilesResolve(rootfs, "etc/../etc/hosts")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(rootfs, "etc", "hosts"), path)
+
+	path, err = snapshotFilesResolve(rootfs, "/../../etc/hosts")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(rootfs, "etc", "hosts"), path)
+
+	// The symlink itself can be restored, just not what it points to.
+	path, err = snapshotFilesResolve(rootfs, "/link")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(rootfs, "link"), path)
+
+	_, err = snapshotFilesResolve(rootfs, "/link/passwd")
+	assert.EqualError(t, err, `Parent "/link" of "/link/passwd" isn't a directory`)
+
+	_, err = snapshotFilesResolve(rootfs, "/etc/hosts/file")
+	assert.EqualError(t, err, `Parent "/etc/hosts" of "/etc/hosts/file" isn't a directory`)
+
+	_, err = snapshotFilesResolve(rootfs, "/missing/file")
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = snapshotFilesResolve(rootfs, "/")
+	assert.EqualError(t, err, "The root directory can't be restored")
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Default path at which snapshots are exposed inside the container.
+const snapshotMountDefaultPath = "/snap-restore"
+
+// Expose a snapshot read-only inside its container (POST) or stop exposing it
+// (DELETE). The snapshot is added to the container as a disk device named
+// after the snapshot and referencing it by name, so it shows up in its
+// configuration and survives restarts of the container.
+func containerSnapshotMountHandler(d *Daemon, r *http.Request) response.Response {
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, project, name, instanceType)
+	if err != nil {
+		return response.SmartError(err)
+	}
+	if resp != nil {
+		return resp
+	}
+
+	snapshotName, err := url.QueryUnescape(mux.Vars(r)["snapshotName"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	snap, err := instance.LoadByProjectAndName(d.State(), project, name+shared.SnapshotDelimiter+snapshotName)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if snap.Type() != instancetype.Container {
+		return response.BadRequest(fmt.Errorf("Mounting snapshots is only supported for containers"))
+	}
+
+	inst, err := instance.LoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	devName := snapshotMountDeviceName(snapshotName)
+
+	var run func(op *operations.Operation) error
+	var opType db.OperationType
+
+	switch r.Method {
+	case "POST":
+		req := api.InstanceSnapshotMountPost{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			return response.BadRequest(err)
+		}
+
+		if req.Path == "" {
+			req.Path = snapshotMountDefaultPath
+		}
+
+		if !filepath.IsAbs(req.Path) {
+			return response.BadRequest(fmt.Errorf("Path %q isn't absolute", req.Path))
+		}
+
+		dev, ok := inst.LocalDevices()[devName]
+		if ok && dev["path"] != req.Path {
+			return response.BadRequest(fmt.Errorf("Snapshot %q is already mounted at %q", snapshotName, dev["path"]))
+		}
+
+		opType = db.OperationSnapshotMount
+		run = func(op *operations.Operation) error {
+			if ok {
+				return nil
+			}
+
+			devices := inst.LocalDevices().Clone()
+			devices[devName] = deviceConfig.Device{
+				"type":     "disk",
+				"source":   snapshotMountSource(snapshotName),
+				"path":     req.Path,
+				"readonly": "true",
+				"required": "false",
+			}
+
+			return snapshotMountUpdateDevices(inst, devices)
+		}
+	case "DELETE":
+		_, ok := inst.LocalDevices()[devName]
+		if !ok {
+			return response.NotFound(fmt.Errorf("Snapshot %q isn't mounted", snapshotName))
+		}
+
+		opType = db.OperationSnapshotUnmount
+		run = func(op *operations.Operation) error {
+			devices := inst.LocalDevices().Clone()
+			delete(devices, devName)
+
+			return snapshotMountUpdateDevices(inst, devices)
+		}
+	default:
+		return response.NotFound(fmt.Errorf("Method '%s' not found", r.Method))
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operations.OperationCreate(d.State(), project, operations.OperationClassTask, opType, resources, nil, run, nil, nil)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	return operations.OperationResponse(op)
+}
+
+// Name of the disk device exposing the given snapshot.
+func snapshotMountDeviceName(snapshotName string) string {
+	return fmt.Sprintf("snapshot-%s", snapshotName)
+}
+
+// Disk source referencing the given snapshot of the container the disk is
+// attached to.
+func snapshotMountSource(snapshotName string) string {
+	return fmt.Sprintf("snapshot:%s", snapshotName)
+}
+
+// Stop exposing the given snapshot inside its container if it is, so that it
+// can be deleted. The device is also dropped from the other snapshots of the
+// container, which would otherwise reference a missing snapshot once restored.
+func snapshotMountRemove(s *state.State, snap instance.Instance) error {
+	parentName, snapshotName, _ := shared.InstanceGetParentAndSnapshotName(snap.Name())
+
+	inst, err := instance.LoadByProjectAndName(s, snap.Project(), parentName)
+	if err != nil {
+		return err
+	}
+
+	devName := snapshotMountDeviceName(snapshotName)
+	source := snapshotMountSource(snapshotName)
+
+	err = s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.InstanceSnapshotsDeviceRemove(inst.Project(), inst.Name(), devName, source)
+	})
+	if err != nil {
+		return err
+	}
+
+	dev, ok := inst.LocalDevices()[devName]
+	if !ok || dev["source"] != source {
+		return nil
+	}
+
+	devices := inst.LocalDevices().Clone()
+	delete(devices, devName)
+
+	return snapshotMountUpdateDevices(inst, devices)
+}
+
+// Rename the given snapshot, keeping it exposed inside its container if it
+// is. The device is removed while renaming so that the snapshot gets
+// unmounted, then added back under the new name. The other snapshots of the
+// container get their copy of the device renamed too.
+func snapshotMountRename(s *state.State, snap instance.Instance, newSnapshotName string) error {
+	parentName, snapshotName, _ := shared.InstanceGetParentAndSnapshotName(snap.Name())
+
+	inst, err := instance.LoadByProjectAndName(s, snap.Project(), parentName)
+	if err != nil {
+		return err
+	}
+
+	devName := snapshotMountDeviceName(snapshotName)
+	source := snapshotMountSource(snapshotName)
+	origDevices := inst.LocalDevices().Clone()
+
+	dev, mounted := origDevices[devName]
+	if mounted && dev["source"] != source {
+		mounted = false
+	}
+
+	if mounted {
+		devices := origDevices.Clone()
+		delete(devices, devName)
+
+		err = snapshotMountUpdateDevices(inst, devices)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = snap.Rename(parentName + shared.SnapshotDelimiter + newSnapshotName)
+	if err != nil {
+		if mounted {
+			snapshotMountUpdateDevices(inst, origDevices)
+		}
+
+		return err
+	}
+
+	newDevName := snapshotMountDeviceName(newSnapshotName)
+	newSource := snapshotMountSource(newSnapshotName)
+
+	err = s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.InstanceSnapshotsDeviceRename(inst.Project(), inst.Name(), devName, source, newDevName, newSource)
+	})
+	if err != nil {
+		return err
+	}
+
+	if !mounted {
+		return nil
+	}
+
+	devices := inst.LocalDevices().Clone()
+	devices[newDevName] = dev.Clone()
+	devices[newDevName]["source"] = newSource
+
+	return snapshotMountUpdateDevices(inst, devices)
+}
+
+// Replace the local devices of the instance, leaving the rest of its
+// configuration untouched.
+func snapshotMountUpdateDevices(inst instance.Instance, devices deviceConfig.Devices) error {
+	args := db.InstanceArgs{
+		Architecture: inst.Architecture(),
+		Config:       inst.LocalConfig(),
+		Description:  inst.Description(),
+		Devices:      devices,
+		Ephemeral:    inst.IsEphemeral(),
+		Profiles:     inst.Profiles(),
+		Project:      inst.Project(),
+		Type:         inst.Type(),
+		Snapshot:     inst.IsSnapshot(),
+	}
+
+	return inst.Update(args, true)
+}
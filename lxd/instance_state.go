@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"regexp"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -11,6 +12,7 @@ import (
 	"github.com/lxc/lxd/lxd/cgroup"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/shared"
@@ -83,10 +85,76 @@ func containerStatePut(d *Daemon, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
+	if shared.InstanceAction(raw.Action) == shared.Start {
+		// Don't start instances on a node being drained.
+		resp := maintenanceCheck(d)
+		if resp != nil {
+			return resp
+		}
+	}
+
+	opType, do, err := instanceStateAction(d, c, raw)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	// Record the state change once it succeeded.
+	requestor := instanceHistoryRequestor(r)
+	run := func(op *operations.Operation) error {
+		err := do(op)
+		if err != nil {
+			return err
+		}
+
+		instanceHistoryRecord(d.State(), c, raw.Action, op, requestor)
+		return nil
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operations.OperationCreate(d.State(), project, operations.OperationClassTask, opType, resources, nil, run, nil, nil)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	return operations.OperationResponse(op)
+}
+
+// Signals which can be sent to stop a container, by name (including real-time signals such as
+// SIGRTMIN+3) or number.
+var stopSignalRegexp = regexp.MustCompile(`^(SIG[A-Z0-9]+([+-][0-9]+)?|[0-9]+)$`)
+
+// instanceStateAction returns the type of operation and the function changing the state of the
+// instance as requested.
+func instanceStateAction(d *Daemon, c instance.Instance, raw api.InstanceStatePut) (db.OperationType, func(*operations.Operation) error, error) {
+	if raw.Signal != "" {
+		if shared.InstanceAction(raw.Action) != shared.Stop {
+			return -1, nil, fmt.Errorf("A signal can only be used to stop an instance")
+		}
+
+		if c.Type() != instancetype.Container {
+			return -1, nil, fmt.Errorf("A signal can only be used to stop a container")
+		}
+
+		if raw.Stateful {
+			return -1, nil, fmt.Errorf("A signal can't be used for stateful stops")
+		}
+
+		if !stopSignalRegexp.MatchString(raw.Signal) {
+			return -1, nil, fmt.Errorf("Invalid signal %q", raw.Signal)
+		}
+	}
+
+	var err error
 	var opType db.OperationType
 	var do func(*operations.Operation) error
 	switch shared.InstanceAction(raw.Action) {
 	case shared.Start:
+		if c.LocalConfig()["volatile.replica.source"] != "" {
+			return -1, nil, fmt.Errorf("Instance %q is a replica, it must be promoted before being started", c.Name())
+		}
+
 		opType = db.OperationContainerStart
 		do = func(op *operations.Operation) error {
 			c.SetOperation(op)
@@ -107,6 +175,35 @@ func containerStatePut(d *Daemon, r *http.Request) response.Response {
 
 				return nil
 			}
+		} else if raw.Signal != "" {
+			// Send the signal, then only kill the container if it didn't stop in time and
+			// this was requested.
+			do = func(op *operations.Operation) error {
+				c.SetOperation(op)
+				metadata := map[string]interface{}{"signal": raw.Signal}
+				op.UpdateMetadata(metadata)
+
+				if c.IsFrozen() {
+					err := c.Unfreeze()
+					if err != nil {
+						return err
+					}
+				}
+
+				err := c.(instance.Container).ShutdownWithSignal(time.Duration(raw.Timeout)*time.Second, raw.Signal)
+				if err == nil || !c.IsRunning() {
+					return nil
+				}
+
+				if !raw.Force {
+					return err
+				}
+
+				metadata["escalated"] = true
+				op.UpdateMetadata(metadata)
+
+				return c.Stop(false)
+			}
 		} else if raw.Timeout == 0 || raw.Force {
 			do = func(op *operations.Operation) error {
 				c.SetOperation(op)
@@ -191,8 +288,9 @@ func containerStatePut(d *Daemon, r *http.Request) response.Response {
 			return nil
 		}
 	case shared.Freeze:
-		if !d.os.CGInfo.Supports(cgroup.Freezer, nil) {
-			return response.BadRequest(fmt.Errorf("This system doesn't support freezing instances"))
+		// Virtual machines are paused by QEMU, only containers need the cgroup freezer.
+		if c.Type() == instancetype.Container && !d.os.CGInfo.Supports(cgroup.Freezer, nil) {
+			return -1, nil, fmt.Errorf("This system doesn't support freezing containers")
 		}
 
 		opType = db.OperationContainerFreeze
@@ -201,8 +299,8 @@ func containerStatePut(d *Daemon, r *http.Request) response.Response {
 			return c.Freeze()
 		}
 	case shared.Unfreeze:
-		if !d.os.CGInfo.Supports(cgroup.Freezer, nil) {
-			return response.BadRequest(fmt.Errorf("This system doesn't support unfreezing instances"))
+		if c.Type() == instancetype.Container && !d.os.CGInfo.Supports(cgroup.Freezer, nil) {
+			return -1, nil, fmt.Errorf("This system doesn't support unfreezing containers")
 		}
 
 		opType = db.OperationContainerUnfreeze
@@ -211,16 +309,8 @@ func containerStatePut(d *Daemon, r *http.Request) response.Response {
 			return c.Unfreeze()
 		}
 	default:
-		return response.BadRequest(fmt.Errorf("unknown action %s", raw.Action))
-	}
-
-	resources := map[string][]string{}
-	resources["containers"] = []string{name}
-
-	op, err := operations.OperationCreate(d.State(), project, operations.OperationClassTask, opType, resources, nil, do, nil, nil)
-	if err != nil {
-		return response.InternalError(err)
+		return -1, nil, fmt.Errorf("unknown action %s", raw.Action)
 	}
 
-	return operations.OperationResponse(op)
+	return opType, do, nil
 }
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/instance"
+	projecthelpers "github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+)
+
+// Interval at which the warm pools are refilled, on top of the refills following claims.
+const warmPoolRefillInterval = time.Minute
+
+// Longest part of the template name kept in the names of the warm pool instances, leaving room for
+// the "-warm-<random>" suffix within the 63 characters allowed.
+const warmPoolNamePrefixMax = 63 - len("-warm-") - 8
+
+// Serializes claims, so that an instance can't be handed out twice.
+var warmPoolClaimMu sync.Mutex
+
+// Serializes refills, so that concurrent ones don't overfill the pools.
+var warmPoolRefillMu sync.Mutex
+
+// Claim an instance of the warm pool of a template instance, renaming it if requested.
+func containerWarmPoolClaimPost(d *Daemon, r *http.Request) response.Response {
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to an instance on a different node
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, project, name, instanceType)
+	if err != nil {
+		return response.SmartError(err)
+	}
+	if resp != nil {
+		return resp
+	}
+
+	template, err := instance.LoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if warmPoolSize(template) == 0 {
+		return response.BadRequest(fmt.Errorf("Instance %q doesn't have a warm pool", name))
+	}
+
+	req := api.InstanceWarmPoolClaimPost{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	frozen := template.LocalConfig()["warm_pool.state"] == "frozen"
+	if req.Name != "" {
+		if frozen {
+			return response.BadRequest(fmt.Errorf("Instances of frozen warm pools can't be renamed"))
+		}
+
+		err = instance.ValidName(req.Name, false)
+		if err != nil {
+			return response.BadRequest(err)
+		}
+
+		id, _ := d.cluster.InstanceID(project, req.Name)
+		if id > 0 {
+			return response.Conflict(fmt.Errorf("Name '%s' already in use", req.Name))
+		}
+	}
+
+	warmPoolClaimMu.Lock()
+	defer warmPoolClaimMu.Unlock()
+
+	members, err := warmPoolMembers(d.State(), template)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Only hand out instances which finished getting ready.
+	var inst instance.Instance
+	for _, member := range members {
+		if frozen == member.IsFrozen() && (frozen || !member.IsRunning()) {
+			inst = member
+			break
+		}
+	}
+
+	if inst == nil {
+		return response.Unavailable(fmt.Errorf("The warm pool of instance %q is empty", name))
+	}
+
+	err = inst.VolatileSet(map[string]string{"volatile.warm_pool.template": ""})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if req.Name != "" {
+		err = inst.Rename(req.Name)
+		if err != nil {
+			inst.VolatileSet(map[string]string{"volatile.warm_pool.template": template.Name()})
+			return response.SmartError(err)
+		}
+	}
+
+	if frozen {
+		err = inst.Unfreeze()
+		if err != nil {
+			return response.SmartError(err)
+		}
+	}
+
+	go warmPoolRefill(d)
+
+	render, _, err := inst.Render(instanceRenderHideSecrets)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponseLocation(true, render, fmt.Sprintf("/%s/instances/%s", version.APIVersion, inst.Name()))
+}
+
+// warmPoolSize returns the number of instances to keep in the warm pool of the given instance.
+// Only the local config counts, so that the members and other instances using the same profiles
+// don't get pools of their own.
+func warmPoolSize(inst instance.Instance) int {
+	if inst.LocalConfig()["volatile.warm_pool.template"] != "" {
+		return 0
+	}
+
+	size, err := strconv.Atoi(inst.LocalConfig()["warm_pool.size"])
+	if err != nil {
+		return 0
+	}
+
+	return size
+}
+
+// warmPoolMembers returns the unclaimed instances of the warm pool of the given template instance.
+func warmPoolMembers(s *state.State, template instance.Instance) ([]instance.Instance, error) {
+	insts, err := instanceLoadNodeProjectAll(s, template.Project(), template.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	members := []instance.Instance{}
+	for _, inst := range insts {
+		if inst.LocalConfig()["volatile.warm_pool.template"] == template.Name() {
+			members = append(members, inst)
+		}
+	}
+
+	return members, nil
+}
+
+func warmPoolRefillTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		warmPoolRefill(d)
+	}
+
+	return f, task.Every(warmPoolRefillInterval)
+}
+
+// Bring the warm pools of the local instances back to their configured size, creating the missing
+// instances and deleting the ones in excess or left over by deleted templates.
+func warmPoolRefill(d *Daemon) {
+	warmPoolRefillMu.Lock()
+	defer warmPoolRefillMu.Unlock()
+
+	s := d.State()
+
+	// Only load the templates and members rather than all the local instances.
+	var ids []int
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		ids, err = tx.LocalInstanceIDsWithConfigKeys("warm_pool.size", "volatile.warm_pool.template")
+		return err
+	})
+	if err != nil {
+		logger.Error("Failed to load instances for warm pool refill", log.Ctx{"err": err})
+		return
+	}
+
+	insts := []instance.Instance{}
+	for _, id := range ids {
+		inst, err := instance.LoadByID(s, id)
+		if err != nil {
+			logger.Error("Failed to load instance for warm pool refill", log.Ctx{"id": id, "err": err})
+			return
+		}
+
+		insts = append(insts, inst)
+	}
+
+	templates := map[string]instance.Instance{}
+	members := map[string][]instance.Instance{}
+	for _, inst := range insts {
+		key := projecthelpers.Instance(inst.Project(), inst.Name())
+		if warmPoolSize(inst) > 0 {
+			templates[key] = inst
+		}
+
+		templateName := inst.LocalConfig()["volatile.warm_pool.template"]
+		if templateName != "" {
+			key = projecthelpers.Instance(inst.Project(), templateName)
+			members[key] = append(members[key], inst)
+		}
+	}
+
+	for key, pool := range members {
+		size := 0
+		template, ok := templates[key]
+		if ok {
+			size = warmPoolSize(template)
+		}
+
+		for len(pool) > size {
+			inst := pool[len(pool)-1]
+			pool = pool[:len(pool)-1]
+
+			err = warmPoolDelete(s, inst)
+			if err != nil {
+				logger.Warn("Failed to delete warm pool instance", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "err": err})
+			}
+		}
+	}
+
+	for key, template := range templates {
+		for i := len(members[key]); i < warmPoolSize(template); i++ {
+			err := warmPoolCreate(d, template)
+			if err != nil {
+				logger.Warn("Failed to refill warm pool", log.Ctx{"project": template.Project(), "instance": template.Name(), "err": err})
+				break
+			}
+		}
+	}
+}
+
+// warmPoolDelete removes an instance in excess from a warm pool, unless it got claimed since the
+// pool was listed.
+func warmPoolDelete(s *state.State, member instance.Instance) error {
+	warmPoolClaimMu.Lock()
+	defer warmPoolClaimMu.Unlock()
+
+	inst, err := instance.LoadByID(s, member.ID())
+	if err != nil {
+		if err == db.ErrNoSuchObject {
+			return nil
+		}
+
+		return err
+	}
+
+	if inst.LocalConfig()["volatile.warm_pool.template"] != member.LocalConfig()["volatile.warm_pool.template"] {
+		return nil
+	}
+
+	if inst.IsRunning() {
+		// Running members are only stopped during their maintenance windows, the next
+		// refills catch up once one opens.
+		if !shared.InstanceInMaintenanceWindow(inst.ExpandedConfig(), time.Now()) {
+			return nil
+		}
+
+		err = inst.Stop(false)
+		if err != nil {
+			return err
+		}
+	}
+
+	return inst.Delete()
+}
+
+// warmPoolCreate adds an instance to the warm pool of the given template instance, freezing it if
+// requested. The creation goes through the admission scriptlet like any other, as made by the
+// "warm-pool" protocol.
+func warmPoolCreate(d *Daemon, template instance.Instance) error {
+	s := d.State()
+
+	suffix, err := shared.RandomCryptoString()
+	if err != nil {
+		return err
+	}
+
+	// Shorten the template name so that the suffix fits in the maximum instance name length.
+	prefix := template.Name()
+	if len(prefix) > warmPoolNamePrefixMax {
+		prefix = prefix[:warmPoolNamePrefixMax]
+	}
+
+	name := fmt.Sprintf("%s-warm-%s", prefix, suffix[:8])
+	args := instanceCloneArgs(template, api.InstanceClonesPost{}, 1, name)
+	for key := range args.Config {
+		if strings.HasPrefix(key, "warm_pool.") {
+			delete(args.Config, key)
+		}
+	}
+
+	err = instanceCloneAdmission(d, "", "warm-pool", template, &args)
+	if err != nil {
+		return err
+	}
+
+	if args.Config == nil {
+		args.Config = map[string]string{}
+	}
+
+	args.Config["volatile.warm_pool.template"] = template.Name()
+
+	err = s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		return projecthelpers.AllowInstanceCreation(tx, template.Project(), api.InstancesPost{
+			InstancePut: api.InstancePut{
+				Config:   args.Config,
+				Devices:  args.Devices.CloneNative(),
+				Profiles: args.Profiles,
+			},
+			Name: name,
+			Type: api.InstanceType(args.Type.String()),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	inst, err := instanceCreateAsCopy(s, args, template, true, false, nil)
+	if err != nil {
+		return err
+	}
+
+	if template.LocalConfig()["warm_pool.state"] != "frozen" {
+		return nil
+	}
+
+	err = inst.Start(false)
+	if err != nil {
+		inst.Delete()
+		return err
+	}
+
+	err = inst.Freeze()
+	if err != nil {
+		inst.Stop(false)
+		inst.Delete()
+		return err
+	}
+
+	return nil
+}
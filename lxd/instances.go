@@ -11,6 +11,7 @@ import (
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/node"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/shared"
@@ -27,6 +28,7 @@ var instancesCmd = APIEndpoint{
 
 	Get:  APIEndpointAction{Handler: containersGet, AccessHandler: allowProjectPermission("containers", "view")},
 	Post: APIEndpointAction{Handler: containersPost, AccessHandler: allowProjectPermission("containers", "manage-containers")},
+	Put:  APIEndpointAction{Handler: containersPut, AccessHandler: allowProjectPermission("containers", "operate-containers")},
 }
 
 var instanceCmd = APIEndpoint{
@@ -56,6 +58,110 @@ var instanceStateCmd = APIEndpoint{
 	Put: APIEndpointAction{Handler: containerStatePut, AccessHandler: allowProjectPermission("containers", "operate-containers")},
 }
 
+var instanceClonesCmd = APIEndpoint{
+	Name: "instanceClones",
+	Path: "instances/{name}/clones",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerClones", Path: "containers/{name}/clones"},
+		{Name: "vmClones", Path: "virtual-machines/{name}/clones"},
+	},
+
+	Post: APIEndpointAction{Handler: containerClonesPost, AccessHandler: allowProjectPermission("containers", "manage-containers")},
+}
+
+var instanceWarmPoolClaimCmd = APIEndpoint{
+	Name: "instanceWarmPoolClaim",
+	Path: "instances/{name}/claim",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerWarmPoolClaim", Path: "containers/{name}/claim"},
+		{Name: "vmWarmPoolClaim", Path: "virtual-machines/{name}/claim"},
+	},
+
+	Post: APIEndpointAction{Handler: containerWarmPoolClaimPost, AccessHandler: allowProjectPermission("containers", "manage-containers")},
+}
+
+var instanceRebuildCmd = APIEndpoint{
+	Name: "instanceRebuild",
+	Path: "instances/{name}/rebuild",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerRebuild", Path: "containers/{name}/rebuild"},
+	},
+
+	Post: APIEndpointAction{Handler: containerRebuildPost, AccessHandler: allowProjectPermission("containers", "manage-containers")},
+}
+
+var instanceHistoryCmd = APIEndpoint{
+	Name: "instanceHistory",
+	Path: "instances/{name}/history",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerHistory", Path: "containers/{name}/history"},
+		{Name: "vmHistory", Path: "virtual-machines/{name}/history"},
+	},
+
+	Get: APIEndpointAction{Handler: containerHistoryGet, AccessHandler: allowProjectPermission("containers", "view")},
+}
+
+var instanceSchedulesCmd = APIEndpoint{
+	Name: "instanceSchedules",
+	Path: "instances/{name}/schedules",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerSchedules", Path: "containers/{name}/schedules"},
+		{Name: "vmSchedules", Path: "virtual-machines/{name}/schedules"},
+	},
+
+	Get:  APIEndpointAction{Handler: containerSchedulesGet, AccessHandler: allowProjectPermission("containers", "view")},
+	Post: APIEndpointAction{Handler: containerSchedulesPost, AccessHandler: allowProjectPermission("containers", "operate-containers")},
+}
+
+var instanceScheduleCmd = APIEndpoint{
+	Name: "instanceSchedule",
+	Path: "instances/{name}/schedules/{scheduleName}",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerSchedule", Path: "containers/{name}/schedules/{scheduleName}"},
+		{Name: "vmSchedule", Path: "virtual-machines/{name}/schedules/{scheduleName}"},
+	},
+
+	Get:    APIEndpointAction{Handler: containerScheduleGet, AccessHandler: allowProjectPermission("containers", "view")},
+	Put:    APIEndpointAction{Handler: containerSchedulePut, AccessHandler: allowProjectPermission("containers", "operate-containers")},
+	Delete: APIEndpointAction{Handler: containerScheduleDelete, AccessHandler: allowProjectPermission("containers", "operate-containers")},
+}
+
+var instanceReplicationsCmd = APIEndpoint{
+	Name: "instanceReplications",
+	Path: "instances/{name}/replications",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerReplications", Path: "containers/{name}/replications"},
+		{Name: "vmReplications", Path: "virtual-machines/{name}/replications"},
+	},
+
+	Get:  APIEndpointAction{Handler: containerReplicationsGet, AccessHandler: allowProjectPermission("containers", "view")},
+	Post: APIEndpointAction{Handler: containerReplicationsPost},
+}
+
+var instanceReplicationCmd = APIEndpoint{
+	Name: "instanceReplication",
+	Path: "instances/{name}/replications/{replicationName}",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerReplication", Path: "containers/{name}/replications/{replicationName}"},
+		{Name: "vmReplication", Path: "virtual-machines/{name}/replications/{replicationName}"},
+	},
+
+	Get:    APIEndpointAction{Handler: containerReplicationGet, AccessHandler: allowProjectPermission("containers", "view")},
+	Put:    APIEndpointAction{Handler: containerReplicationPut},
+	Delete: APIEndpointAction{Handler: containerReplicationDelete, AccessHandler: allowProjectPermission("containers", "manage-containers")},
+}
+
+var instancePromoteCmd = APIEndpoint{
+	Name: "instancePromote",
+	Path: "instances/{name}/promote",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerPromote", Path: "containers/{name}/promote"},
+		{Name: "vmPromote", Path: "virtual-machines/{name}/promote"},
+	},
+
+	Post: APIEndpointAction{Handler: containerPromotePost, AccessHandler: allowProjectPermission("containers", "manage-containers")},
+}
+
 var instanceFileCmd = APIEndpoint{
 	Name: "instanceFile",
 	Path: "instances/{name}/files",
@@ -95,6 +201,38 @@ var instanceSnapshotCmd = APIEndpoint{
 	Put:    APIEndpointAction{Handler: containerSnapshotHandler, AccessHandler: allowProjectPermission("containers", "operate-containers")},
 }
 
+var instanceSnapshotDiffCmd = APIEndpoint{
+	Name: "instanceSnapshotDiff",
+	Path: "instances/{name}/snapshots/{snapshotName}/diff",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerSnapshotDiff", Path: "containers/{name}/snapshots/{snapshotName}/diff"},
+	},
+
+	Get: APIEndpointAction{Handler: containerSnapshotDiffGet, AccessHandler: allowProjectPermission("containers", "view")},
+}
+
+var instanceSnapshotFilesCmd = APIEndpoint{
+	Name: "instanceSnapshotFiles",
+	Path: "instances/{name}/snapshots/{snapshotName}/files",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerSnapshotFiles", Path: "containers/{name}/snapshots/{snapshotName}/files"},
+	},
+
+	Get:  APIEndpointAction{Handler: containerSnapshotFilesHandler, AccessHandler: allowProjectPermission("containers", "operate-containers")},
+	Post: APIEndpointAction{Handler: containerSnapshotFilesHandler, AccessHandler: allowProjectPermission("containers", "manage-containers")},
+}
+
+var instanceSnapshotMountCmd = APIEndpoint{
+	Name: "instanceSnapshotMount",
+	Path: "instances/{name}/snapshots/{snapshotName}/mount",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerSnapshotMount", Path: "containers/{name}/snapshots/{snapshotName}/mount"},
+	},
+
+	Post:   APIEndpointAction{Handler: containerSnapshotMountHandler, AccessHandler: allowProjectPermission("containers", "manage-containers")},
+	Delete: APIEndpointAction{Handler: containerSnapshotMountHandler, AccessHandler: allowProjectPermission("containers", "manage-containers")},
+}
+
 var instanceConsoleCmd = APIEndpoint{
 	Name: "instanceConsole",
 	Path: "instances/{name}/console",
@@ -108,6 +246,16 @@ var instanceConsoleCmd = APIEndpoint{
 	Delete: APIEndpointAction{Handler: containerConsoleLogDelete, AccessHandler: allowProjectPermission("containers", "operate-containers")},
 }
 
+var instanceConsoleScreenshotCmd = APIEndpoint{
+	Name: "instanceConsoleScreenshot",
+	Path: "instances/{name}/console/screenshot",
+	Aliases: []APIEndpointAlias{
+		{Name: "vmConsoleScreenshot", Path: "virtual-machines/{name}/console/screenshot"},
+	},
+
+	Get: APIEndpointAction{Handler: instanceConsoleScreenshotGet, AccessHandler: allowProjectPermission("containers", "view")},
+}
+
 var instanceExecCmd = APIEndpoint{
 	Name: "instanceExec",
 	Path: "instances/{name}/exec",
@@ -170,6 +318,17 @@ var instanceBackupCmd = APIEndpoint{
 	Delete: APIEndpointAction{Handler: containerBackupDelete, AccessHandler: allowProjectPermission("containers", "operate-containers")},
 }
 
+var instanceExportCmd = APIEndpoint{
+	Name: "instanceExport",
+	Path: "instances/{name}/export",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerExport", Path: "containers/{name}/export"},
+		{Name: "vmExport", Path: "virtual-machines/{name}/export"},
+	},
+
+	Get: APIEndpointAction{Handler: containerExportGet, AccessHandler: allowProjectPermission("containers", "operate-containers")},
+}
+
 var instanceBackupExportCmd = APIEndpoint{
 	Name: "instanceBackupExport",
 	Path: "instances/{name}/backups/{backupName}/export",
@@ -219,6 +378,17 @@ func containersRestart(s *state.State) error {
 
 	sort.Sort(containerAutostartList(instances))
 
+	// Don't start instances on a node being drained.
+	maintenance, err := node.Maintenance(s.Node)
+	if err != nil {
+		return err
+	}
+
+	if maintenance {
+		logger.Warnf("Not starting instances, the server is in maintenance mode (core.maintenance)")
+		return nil
+	}
+
 	// Restart the instances
 	for _, c := range instances {
 		config := c.ExpandedConfig()
@@ -228,7 +398,8 @@ func containersRestart(s *state.State) error {
 		autoStartDelay := config["boot.autostart.delay"]
 
 		if shared.IsTrue(autoStart) || (autoStart == "" && lastState == "RUNNING") {
-			if c.IsRunning() {
+			// Replicas only get started once promoted.
+			if c.IsRunning() || config["volatile.replica.source"] != "" {
 				continue
 			}
 
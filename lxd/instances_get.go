@@ -192,7 +192,8 @@ func doContainersGet(d *Daemon, r *http.Request) (interface{}, error) {
 				defer wg.Done()
 				cert := d.endpoints.NetworkCert()
 
-				if recursion == 1 {
+				// Filtered URL listings also need the instances, not their full state.
+				if recursion < 2 {
 					cs, err := doContainersGetFromNode(project, address, cert, instanceType)
 					if err != nil {
 						for _, name := range containers {
@@ -255,7 +256,7 @@ func doContainersGet(d *Daemon, r *http.Request) (interface{}, error) {
 						}
 
 						if recursion < 2 {
-							c, _, err := nodeCts[container].Render()
+							c, _, err := nodeCts[container].Render(instanceRenderHideSecrets)
 							if err != nil {
 								resultListAppend(container, api.Instance{}, err)
 							} else {
@@ -269,6 +270,7 @@ func doContainersGet(d *Daemon, r *http.Request) (interface{}, error) {
 						if err != nil {
 							resultFullListAppend(container, api.InstanceFull{}, err)
 						} else {
+							instanceRenderHideSecrets(c)
 							resultFullListAppend(container, *c, err)
 						}
 					}
@@ -27,6 +27,7 @@ import (
 	projecthelpers "github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/lxd/revert"
+	"github.com/lxc/lxd/lxd/scriptlet"
 	storagePools "github.com/lxc/lxd/lxd/storage"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -63,6 +64,16 @@ func createFromImage(d *Daemon, project string, req *api.InstancesPost) response
 			return err
 		}
 
+		// Record the local alias, and so the channel, the instance was created from, for
+		// rebuilds to follow it.
+		if req.Source.Alias != "" && req.Source.Server == "" {
+			if args.Config == nil {
+				args.Config = map[string]string{}
+			}
+
+			args.Config["volatile.base_image.alias"] = req.Source.Alias
+		}
+
 		var info *api.Image
 		if req.Source.Server != "" {
 			autoUpdate, err := cluster.ConfigGetBool(d.cluster, "images.auto_update_cached")
@@ -397,6 +408,10 @@ func createFromCopy(d *Daemon, project string, req *api.InstancesPost) response.
 		}
 
 		if serverName != source.Location() {
+			if !req.Source.NotBefore.IsZero() || req.Source.OffPeak {
+				return response.BadRequest(fmt.Errorf("Copies from other cluster members can't be deferred"))
+			}
+
 			// Check if we are copying from a ceph-based container.
 			_, rootDevice, _ := shared.GetRootDiskDevice(source.ExpandedDevices().CloneNative())
 			sourcePoolName := rootDevice["pool"]
@@ -515,9 +530,20 @@ func createFromCopy(d *Daemon, project string, req *api.InstancesPost) response.
 		Stateful:     req.Stateful,
 	}
 
+	// Heavy copies can be deferred to a later time or to the off-peak hours.
+	deferral, err := newOperationDeferral(d.State(), req.Source.NotBefore, req.Source.OffPeak)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
 	run := func(op *operations.Operation) error {
+		err := deferral.Wait(d.State(), op)
+		if err != nil {
+			return err
+		}
+
 		instanceOnly := req.Source.InstanceOnly || req.Source.ContainerOnly
-		_, err := instanceCreateAsCopy(d.State(), args, source, instanceOnly, req.Source.Refresh, op)
+		_, err = instanceCreateAsCopy(d.State(), args, source, instanceOnly, req.Source.Refresh, op)
 		if err != nil {
 			return err
 		}
@@ -528,7 +554,7 @@ func createFromCopy(d *Daemon, project string, req *api.InstancesPost) response.
 	resources["instances"] = []string{req.Name, req.Source.Source}
 	resources["containers"] = resources["instances"] // Populate old field name.
 
-	op, err := operations.OperationCreate(d.State(), targetProject, operations.OperationClassTask, db.OperationContainerCreate, resources, nil, run, nil, nil)
+	op, err := operations.OperationCreate(d.State(), targetProject, operations.OperationClassTask, db.OperationContainerCreate, resources, nil, run, deferral.OnCancel(), nil)
 	if err != nil {
 		return response.InternalError(err)
 	}
@@ -536,7 +562,7 @@ func createFromCopy(d *Daemon, project string, req *api.InstancesPost) response.
 	return operations.OperationResponse(op)
 }
 
-func createFromBackup(d *Daemon, project string, data io.Reader, pool string) response.Response {
+func createFromBackup(d *Daemon, r *http.Request, project string, data io.Reader, pool string) response.Response {
 	revert := revert.New()
 	defer revert.Fail()
 
@@ -610,6 +636,34 @@ func createFromBackup(d *Daemon, project string, data io.Reader, pool string) re
 		"snapshots": bInfo.Snapshots,
 	})
 
+	// Let the admission scriptlet reject the import. The instance is created
+	// from the configuration stored in the backup, so the scriptlet can't
+	// modify the request nor pick another cluster member.
+	admissionReq := api.InstancesPost{
+		Name:   bInfo.Name,
+		Type:   bInfo.Type,
+		Source: api.InstanceSource{Type: "backup"},
+	}
+
+	origReq, err := json.Marshal(admissionReq)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	scriptletTarget, err := instanceAdmission(d, r, project, "", &admissionReq)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	newReq, err := json.Marshal(admissionReq)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	if scriptletTarget != "" || !bytes.Equal(origReq, newReq) {
+		return response.BadRequest(fmt.Errorf("The admission scriptlet can't modify backup imports"))
+	}
+
 	// Check storage pool exists.
 	_, _, err = d.State().Cluster.StoragePoolGet(bInfo.Pool)
 	if errors.Cause(err) == db.ErrNoSuchObject {
@@ -724,7 +778,12 @@ func containersPost(d *Daemon, r *http.Request) response.Response {
 
 	// If we're getting binary content, process separately
 	if r.Header.Get("Content-Type") == "application/octet-stream" {
-		return createFromBackup(d, project, r.Body, r.Header.Get("X-LXD-pool"))
+		resp := maintenanceCheck(d)
+		if resp != nil {
+			return resp
+		}
+
+		return createFromBackup(d, r, project, r.Body, r.Header.Get("X-LXD-pool"))
 	}
 
 	// Parse the request
@@ -734,6 +793,11 @@ func containersPost(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
+	err = instance.KeepInternalConfig(req.Config, nil)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
 	// Set type from URL if missing
 	urlType, err := urlInstanceTypeDetect(r)
 	if err != nil {
@@ -745,6 +809,66 @@ func containersPost(d *Daemon, r *http.Request) response.Response {
 	}
 
 	targetNode := queryParam(r, "target")
+
+	// Let the admission scriptlet rewrite or reject the request, forwarded
+	// requests having already been through it.
+	protocol, _ := r.Context().Value("protocol").(string)
+	if protocol != "cluster" {
+		scriptletTarget, err := instanceAdmission(d, r, project, targetNode, &req)
+		if err != nil {
+			return response.BadRequest(err)
+		}
+
+		if scriptletTarget != "" {
+			targetNode = scriptletTarget
+		}
+	}
+
+	// Apply the default profiles of the storage pool the instance is put
+	// on or of the project, copies keep the ones of their source instead.
+	if req.Profiles == nil && req.Source.Type != "copy" {
+		var poolConfig map[string]string
+		_, rootDisk, err := shared.GetRootDiskDevice(req.Devices)
+		if err == nil && rootDisk["pool"] != "" {
+			_, pool, err := d.cluster.StoragePoolGet(rootDisk["pool"])
+			if err != nil {
+				return response.SmartError(err)
+			}
+
+			poolConfig = pool.Config
+		}
+
+		err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			req.Profiles, err = projecthelpers.DefaultProfiles(tx, project, poolConfig)
+			return err
+		})
+		if err != nil {
+			return response.SmartError(err)
+		}
+	}
+
+	// Restrict the placement to the cluster groups allowed for the
+	// instance, if any.
+	var clusterGroups []string
+	clustered, err := cluster.Enabled(d.db)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if clustered && protocol != "cluster" {
+		err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			clusterGroups, err = clusterGroupsPlacement(tx, project, req, targetNode)
+			return err
+		})
+		if err != nil {
+			return response.BadRequest(err)
+		}
+
+		if strings.HasPrefix(targetNode, "@") {
+			targetNode = ""
+		}
+	}
+
 	if targetNode == "" {
 		// If no target node was specified, pick the node with the
 		// least number of containers. If there's just one node, or if
@@ -755,14 +879,29 @@ func containersPost(d *Daemon, r *http.Request) response.Response {
 		if err != nil {
 			return response.BadRequest(err)
 		}
+
+		// Keep the members of anti-affine groups apart.
+		groups, err := shared.InstanceGroupsParse(req.Config["groups"])
+		if err != nil {
+			return response.BadRequest(err)
+		}
+
 		err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
-			var err error
-			targetNode, err = tx.NodeWithLeastContainers(architectures)
+			avoid, err := instanceGroupsAntiAffinity(tx, project, groups)
+			if err != nil {
+				return err
+			}
+
+			targetNode, err = tx.NodeWithLeastContainersInGroups(architectures, clusterGroups, avoid)
 			return err
 		})
 		if err != nil {
 			return response.SmartError(err)
 		}
+
+		if targetNode == "" && clusterGroups != nil {
+			return response.BadRequest(fmt.Errorf("No suitable cluster member found in cluster groups %q", clusterGroups))
+		}
 	}
 
 	if targetNode != "" {
@@ -791,6 +930,12 @@ func containersPost(d *Daemon, r *http.Request) response.Response {
 		}
 	}
 
+	// Don't create instances on a node being drained.
+	resp := maintenanceCheck(d)
+	if resp != nil {
+		return resp
+	}
+
 	// If no storage pool is found, error out.
 	pools, err := d.cluster.StoragePools()
 	if err != nil || len(pools) == 0 {
@@ -878,6 +1023,19 @@ func containersPost(d *Daemon, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
+	// Reject profiles conflicting with each other, migrated instances
+	// keep the profiles they already had.
+	if req.Source.Type != "migration" && req.Profiles != nil {
+		err = profilesValidateConflicts(d.cluster, project, req.Profiles, req.Config, req.Devices)
+		if err != nil {
+			return response.BadRequest(err)
+		}
+	}
+
+	if req.Source.Type != "copy" && (!req.Source.NotBefore.IsZero() || req.Source.OffPeak) {
+		return response.BadRequest(fmt.Errorf("Only copies can be deferred"))
+	}
+
 	switch req.Source.Type {
 	case "image":
 		return createFromImage(d, project, &req)
@@ -1033,3 +1191,74 @@ func clusterCopyContainerInternal(d *Daemon, source instance.Instance, project s
 	// Run the migration
 	return createFromMigration(d, project, req)
 }
+
+// Run the admission scriptlet, if any, against an instance creation request.
+// Returns the cluster member selected by the scriptlet.
+func instanceAdmission(d *Daemon, r *http.Request, project string, target string, req *api.InstancesPost) (string, error) {
+	username, _ := r.Context().Value("username").(string)
+	protocol, _ := r.Context().Value("protocol").(string)
+
+	return instanceAdmissionAs(d, username, protocol, project, target, req)
+}
+
+// Run the admission scriptlet, if any, against an instance creation request made on behalf of the
+// given user and protocol, such as the ones LXD makes itself.
+func instanceAdmissionAs(d *Daemon, username string, protocol string, project string, target string, req *api.InstancesPost) (string, error) {
+	src, err := cluster.ConfigGetString(d.cluster, "instances.admission_scriptlet")
+	if err != nil {
+		return "", err
+	}
+
+	if src == "" {
+		return "", nil
+	}
+
+	ctx := scriptlet.AdmissionContext{
+		Project:  project,
+		Username: username,
+		Protocol: protocol,
+		Target:   target,
+		Members:  []string{},
+	}
+
+	clustered, err := cluster.Enabled(d.db)
+	if err != nil {
+		return "", err
+	}
+
+	if clustered {
+		err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			threshold, err := tx.NodeOfflineThreshold()
+			if err != nil {
+				return err
+			}
+
+			nodes, err := tx.Nodes()
+			if err != nil {
+				return err
+			}
+
+			for _, node := range nodes {
+				if !node.IsOffline(threshold) {
+					ctx.Members = append(ctx.Members, node.Name)
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	scriptletTarget, err := scriptlet.Admission(src, req, ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if scriptletTarget != "" && !shared.StringInSlice(scriptletTarget, ctx.Members) {
+		return "", fmt.Errorf("Admission scriptlet selected unknown or offline cluster member %q", scriptletTarget)
+	}
+
+	return scriptletTarget, nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Maximum number of instances whose state is changed concurrently by a bulk state change.
+const instancesStateWorkers = 8
+
+func containersPut(d *Daemon, r *http.Request) response.Response {
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	project := projectParam(r)
+
+	// Don't mess with instances while in setup mode.
+	<-d.readyChan
+
+	req := api.InstancesPut{State: &api.InstanceStatePut{Timeout: -1}}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if req.State == nil || req.State.Action == "" {
+		return response.BadRequest(fmt.Errorf("No state change requested"))
+	}
+
+	action := shared.InstanceAction(req.State.Action)
+	if action != shared.Start && action != shared.Stop && action != shared.Restart && action != shared.Freeze && action != shared.Unfreeze {
+		return response.BadRequest(fmt.Errorf("unknown action %s", req.State.Action))
+	}
+
+	if action == shared.Start {
+		// Don't start instances on a node being drained.
+		resp := maintenanceCheck(d)
+		if resp != nil {
+			return resp
+		}
+	}
+
+	// Get the location of the instances of the project.
+	var result map[string][]string // Instances by node address
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		result, err = tx.ContainersListByNodeAddress(project, instanceType)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	addresses := map[string]string{}
+	for address, names := range result {
+		for _, name := range names {
+			addresses[name] = address
+		}
+	}
+
+	names := req.Instances
+	if req.Group != "" {
+		if len(names) > 0 {
+			return response.BadRequest(fmt.Errorf("Instances and group can't both be given"))
+		}
+
+		var members map[string]string
+		err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			var err error
+			members, err = tx.InstanceGroupMembers(project, req.Group)
+			return err
+		})
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		for name := range members {
+			_, ok := addresses[name]
+			if ok {
+				names = append(names, name)
+			}
+		}
+
+		if len(names) == 0 {
+			return response.BadRequest(fmt.Errorf("Group '%s' has no instances", req.Group))
+		}
+
+		sort.Strings(names)
+	} else if len(names) == 0 {
+		return response.BadRequest(fmt.Errorf("No instances given, use \"*\" for all the instances of the project"))
+	} else if len(names) == 1 && names[0] == "*" {
+		names = []string{}
+		for name := range addresses {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+	}
+
+	for _, name := range names {
+		address, ok := addresses[name]
+		if !ok {
+			return response.NotFound(fmt.Errorf("Instance '%s' not found", name))
+		}
+
+		if address == "0.0.0.0" {
+			return response.SmartError(fmt.Errorf("The node hosting instance '%s' is offline", name))
+		}
+	}
+
+	requestor := instanceHistoryRequestor(r)
+
+	// Change the state of a single instance, either locally or on the node hosting it.
+	updateState := func(op *operations.Operation, name string) error {
+		address := addresses[name]
+		if address != "" {
+			client, err := cluster.Connect(address, d.endpoints.NetworkCert(), true)
+			if err != nil {
+				return errors.Wrapf(err, "Failed to connect to node %s", address)
+			}
+
+			remoteOp, err := client.UseProject(project).UpdateInstanceState(name, *req.State, "")
+			if err != nil {
+				return err
+			}
+
+			return remoteOp.Wait()
+		}
+
+		c, err := instance.LoadByProjectAndName(d.State(), project, name)
+		if err != nil {
+			return err
+		}
+
+		_, do, err := instanceStateAction(d, c, *req.State)
+		if err != nil {
+			return err
+		}
+
+		err = do(op)
+		if err != nil {
+			return err
+		}
+
+		instanceHistoryRecord(d.State(), c, req.State.Action, op, requestor)
+		return nil
+	}
+
+	// Closed once the state changes are over, cancelling waiting for the ones in progress.
+	finished := make(chan struct{})
+
+	run := func(op *operations.Operation) error {
+		defer close(finished)
+
+		failures, err := instancesStateRun(op.Context(), names, func(name string) error {
+			return updateState(op, name)
+		})
+
+		if len(failures) > 0 {
+			op.UpdateMetadata(map[string]interface{}{"failures": failures})
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if len(failures) == 0 {
+			return nil
+		}
+
+		failed := []string{}
+		for name := range failures {
+			failed = append(failed, name)
+		}
+
+		sort.Strings(failed)
+		return fmt.Errorf("Failed to %s instances: %s", req.State.Action, strings.Join(failed, ", "))
+	}
+
+	onCancel := func(op *operations.Operation) error {
+		<-finished
+		return nil
+	}
+
+	resources := map[string][]string{}
+	resources["instances"] = names
+	resources["containers"] = names
+
+	op, err := operations.OperationCreate(d.State(), project, operations.OperationClassTask, db.OperationInstanceStateUpdate, resources, nil, run, onCancel, nil)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	return operations.OperationResponse(op)
+}
+
+// instancesStateRun calls updateState for each of the named instances, using up to
+// instancesStateWorkers workers, and returns the errors by instance name. Once the context is
+// cancelled, the instances not handled yet are left alone and an error is returned.
+func instancesStateRun(ctx context.Context, names []string, updateState func(name string) error) (map[string]string, error) {
+	failures := map[string]string{}
+	handled := 0
+	mu := sync.Mutex{}
+
+	queue := make(chan string)
+	wg := sync.WaitGroup{}
+	for i := 0; i < instancesStateWorkers && i < len(names); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range queue {
+				if ctx.Err() != nil {
+					continue
+				}
+
+				err := updateState(name)
+
+				mu.Lock()
+				handled++
+				if err != nil {
+					failures[name] = err.Error()
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, name := range names {
+		if ctx.Err() != nil {
+			break
+		}
+
+		queue <- name
+	}
+
+	close(queue)
+	wg.Wait()
+
+	if handled < len(names) {
+		return failures, fmt.Errorf("Cancelled with %d instances left unchanged", len(names)-handled)
+	}
+
+	return failures, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// All instances are handled and their failures reported.
+func TestInstancesStateRun(t *testing.T) {
+	names := []string{}
+	for i := 0; i < 20; i++ {
+		names = append(names, fmt.Sprintf("c%d", i))
+	}
+
+	handled := map[string]bool{}
+	handledMu := sync.Mutex{}
+	failures, err := instancesStateRun(context.Background(), names, func(name string) error {
+		handledMu.Lock()
+		handled[name] = true
+		handledMu.Unlock()
+
+		if name == "c3" || name == "c17" {
+			return fmt.Errorf("Boom")
+		}
+
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, handled, 20)
+	assert.Equal(t, map[string]string{"c3": "Boom", "c17": "Boom"}, failures)
+}
+
+// Instances which weren't handled yet are left alone once cancelled.
+func TestInstancesStateRun_Cancel(t *testing.T) {
+	names := []string{}
+	for i := 0; i < 20; i++ {
+		names = append(names, fmt.Sprintf("c%d", i))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{}, len(names))
+	release := make(chan struct{})
+
+	calls := 0
+	callsMu := sync.Mutex{}
+	go func() {
+		// Cancel once all workers are busy.
+		for i := 0; i < instancesStateWorkers; i++ {
+			<-started
+		}
+
+		cancel()
+		close(release)
+	}()
+
+	failures, err := instancesStateRun(ctx, names, func(name string) error {
+		callsMu.Lock()
+		calls++
+		callsMu.Unlock()
+
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+	assert.EqualError(t, err, fmt.Sprintf("Cancelled with %d instances left unchanged", len(names)-instancesStateWorkers))
+	assert.Len(t, failures, 0)
+	assert.Equal(t, instancesStateWorkers, calls)
+}
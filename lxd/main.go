@@ -119,6 +119,14 @@ func main() {
 	forkconsoleCmd := cmdForkconsole{global: &globalCmd}
 	app.AddCommand(forkconsoleCmd.Command())
 
+	// forkcoredump sub-command
+	forkcoredumpCmd := cmdForkcoredump{global: &globalCmd}
+	app.AddCommand(forkcoredumpCmd.Command())
+
+	// forkcoresched sub-command
+	forkcoreschedCmd := cmdForkcoresched{global: &globalCmd}
+	app.AddCommand(forkcoreschedCmd.Command())
+
 	// forkdns sub-command
 	forkDNSCmd := cmdForkDNS{global: &globalCmd}
 	app.AddCommand(forkDNSCmd.Command())
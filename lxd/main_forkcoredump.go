@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/shared"
+)
+
+// coreDumpSpecifiers lists the core_pattern specifiers passed to forkcoredump, in order. %E comes
+// last as it may contain spaces, which older kernels split the pattern on.
+var coreDumpSpecifiers = []string{"P", "p", "i", "I", "u", "g", "d", "s", "t", "c", "E"}
+
+// Largest core dump of a container process saved, in bytes. Longer dumps are truncated.
+const coreDumpMaxSize = 1024 * 1024 * 1024
+
+// Number of core dumps kept per container, and how long for, the older ones being deleted as new
+// ones are saved.
+const coreDumpMaxCount = 5
+const coreDumpMaxAge = 7 * 24 * time.Hour
+
+type cmdForkcoredump struct {
+	global *cmdGlobal
+}
+
+func (c *cmdForkcoredump) Command() *cobra.Command {
+	// Main subcommand
+	cmd := &cobra.Command{}
+	cmd.Use = "forkcoredump <LXD dir> <specifiers...>"
+	cmd.Short = "Save a core dump"
+	cmd.Long = `Description:
+  Save a core dump
+
+  This internal command is run by the kernel when a process dumps core. The
+  core dumps of container processes are saved in the container's log
+  directory, the others are passed on to the core pattern LXD replaced.
+
+  The LXD directory is "-" when LXD_DIR isn't set.
+`
+	cmd.RunE = c.Run
+	cmd.Hidden = true
+
+	return cmd
+}
+
+func (c *cmdForkcoredump) Run(cmd *cobra.Command, args []string) error {
+	if len(args) < len(coreDumpSpecifiers) {
+		cmd.Help()
+		return fmt.Errorf("Missing required arguments")
+	}
+
+	if args[0] != "-" {
+		os.Setenv("LXD_DIR", args[0])
+	}
+
+	// Older kernels split the expanded pattern on spaces, put %E back together.
+	values := map[string]string{}
+	for i, specifier := range coreDumpSpecifiers {
+		if i == len(coreDumpSpecifiers)-1 {
+			values[specifier] = strings.Join(args[i+1:], " ")
+			break
+		}
+
+		values[specifier] = args[i+1]
+	}
+
+	pid, err := strconv.Atoi(values["P"])
+	if err != nil {
+		return err
+	}
+
+	comm, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err == nil {
+		values["e"] = strings.TrimSpace(string(comm))
+	}
+
+	hostname, err := os.Hostname()
+	if err == nil {
+		values["h"] = hostname
+	}
+
+	name, err := coreDumpContainer(pid)
+	if err != nil || name == "" {
+		return coreDumpChain(values)
+	}
+
+	// Honor the RLIMIT_CORE of the crashing process, which the kernel doesn't for pipes.
+	limit := coreDumpLimit(values["c"])
+	if limit > coreDumpMaxSize {
+		limit = coreDumpMaxSize
+	}
+
+	if limit == 0 {
+		return nil
+	}
+
+	logPath := shared.LogPath(name)
+	err = coreDumpPrune(logPath, time.Now(), coreDumpMaxCount-1)
+	if err != nil {
+		return err
+	}
+
+	// Name the dump after the host pid so it can be matched with the exit of the process.
+	path := filepath.Join(logPath, fmt.Sprintf("core.%d", pid))
+
+	return coreDumpWrite(path, os.Stdin, limit)
+}
+
+// coreDumpLimit returns the size limit of a core dump in bytes, given the RLIMIT_CORE of the
+// crashing process as expanded from %c, or -1 if it's unlimited.
+func coreDumpLimit(value string) int64 {
+	limit, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || limit > math.MaxInt64 {
+		return -1
+	}
+
+	return int64(limit)
+}
+
+// coreDumpWrite saves a core dump to the given path, truncating it to limit bytes unless limit is
+// negative.
+func coreDumpWrite(path string, r io.Reader, limit int64) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL|unix.O_NOFOLLOW, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if limit >= 0 {
+		r = io.LimitReader(r, limit)
+	}
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// coreDumpPrune deletes the core dumps of the given log directory which are older than
+// coreDumpMaxAge, as well as the oldest ones beyond keep.
+func coreDumpPrune(dir string, now time.Time, keep int) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	dumps := []os.FileInfo{}
+	for _, entry := range entries {
+		if !entry.Mode().IsRegular() || !strings.HasPrefix(entry.Name(), "core.") {
+			continue
+		}
+
+		if now.Sub(entry.ModTime()) > coreDumpMaxAge {
+			err := os.Remove(filepath.Join(dir, entry.Name()))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+
+			continue
+		}
+
+		dumps = append(dumps, entry)
+	}
+
+	sort.Slice(dumps, func(i, j int) bool { return dumps[i].ModTime().After(dumps[j].ModTime()) })
+
+	for i := keep; i < len(dumps); i++ {
+		err := os.Remove(filepath.Join(dir, dumps[i].Name()))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// coreDumpContainer returns the project prefixed name of the container running the given process, as
+// found in its cgroup and checked against LXD, or an empty string if it doesn't run in a container.
+func coreDumpContainer(pid int) (string, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// The cgroup of a process can't be changed from within the container, unlike its command line.
+	name := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		parts := strings.Split(strings.TrimPrefix(fields[2], "/"), "/")
+		if strings.HasPrefix(parts[0], "lxc.payload.") {
+			name = strings.TrimPrefix(parts[0], "lxc.payload.")
+		} else if parts[0] == "lxc" && len(parts) > 1 {
+			name = parts[1]
+		}
+
+		if name != "" {
+			break
+		}
+	}
+
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return "", nil
+	}
+
+	projectName, instanceName := project.InstanceParts(name)
+	err = instance.ValidName(instanceName, false)
+	if err != nil {
+		return "", nil
+	}
+
+	// Only trust names of containers LXD knows about.
+	socket := os.Getenv("LXD_SOCKET")
+	if socket == "" {
+		socket = shared.VarPath("unix.socket")
+	}
+
+	d, err := lxd.ConnectLXDUnix(socket, &lxd.ConnectionArgs{SkipGetServer: true})
+	if err != nil {
+		return "", err
+	}
+
+	inst, _, err := d.UseProject(projectName).GetInstance(instanceName)
+	if err != nil || inst.Type != "container" {
+		return "", nil
+	}
+
+	return name, nil
+}
+
+// coreDumpChain passes a core dump on to the core pattern LXD replaced. Pipes are run with the
+// specifiers they use, absolute paths are written to. Relative paths, which the kernel resolves
+// against the working directory of the crashing process, are discarded.
+func coreDumpChain(values map[string]string) error {
+	content, err := ioutil.ReadFile(shared.VarPath("core_pattern.orig"))
+	if err != nil {
+		return nil
+	}
+
+	pattern := strings.TrimSpace(string(content))
+	if strings.HasPrefix(pattern, "|") {
+		fields := strings.Fields(strings.TrimPrefix(pattern, "|"))
+		if len(fields) == 0 {
+			return nil
+		}
+
+		for i := range fields {
+			fields[i] = coreDumpExpand(fields[i], values)
+		}
+
+		cmd := exec.Command(fields[0], fields[1:]...)
+		cmd.Stdin = os.Stdin
+		return cmd.Run()
+	}
+
+	path := coreDumpExpand(pattern, values)
+	if !filepath.IsAbs(path) {
+		return nil
+	}
+
+	limit := coreDumpLimit(values["c"])
+	if limit == 0 {
+		return nil
+	}
+
+	return coreDumpWrite(path, os.Stdin, limit)
+}
+
+// coreDumpExpand expands the core pattern specifiers of the given string. Specifiers which aren't
+// known are left empty.
+func coreDumpExpand(s string, values map[string]string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		if s[i] == '%' {
+			b.WriteByte('%')
+			continue
+		}
+
+		b.WriteString(values[string(s[i])])
+	}
+
+	return b.String()
+}
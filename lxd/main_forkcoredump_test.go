@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoreDumpLimit(t *testing.T) {
+	assert.Equal(t, int64(0), coreDumpLimit("0"))
+	assert.Equal(t, int64(4096), coreDumpLimit("4096"))
+	assert.Equal(t, int64(-1), coreDumpLimit("18446744073709551615"))
+	assert.Equal(t, int64(-1), coreDumpLimit(""))
+}
+
+// Dumps are truncated to the limit, and existing files aren't overwritten.
+func TestCoreDumpWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-coredump-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "core.1")
+	err = coreDumpWrite(path, bytes.NewReader([]byte("0123456789")), 4)
+	require.NoError(t, err)
+
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "0123", string(content))
+
+	err = coreDumpWrite(path, bytes.NewReader([]byte("abc")), -1)
+	assert.Error(t, err)
+
+	path = filepath.Join(dir, "core.2")
+	err = coreDumpWrite(path, bytes.NewReader([]byte("0123456789")), -1)
+	require.NoError(t, err)
+
+	content, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(content))
+}
+
+// Dumps past their age are deleted, as well as the oldest ones beyond the count, other files
+// being left alone.
+func TestCoreDumpPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-coredump-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	files := map[string]time.Duration{
+		"core.1":  coreDumpMaxAge + time.Hour,
+		"core.2":  3 * time.Hour,
+		"core.3":  2 * time.Hour,
+		"core.4":  time.Hour,
+		"lxc.log": coreDumpMaxAge + time.Hour,
+	}
+
+	for name, age := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte("core"), 0600))
+		require.NoError(t, os.Chtimes(path, now.Add(-age), now.Add(-age)))
+	}
+
+	err = coreDumpPrune(dir, now, 2)
+	require.NoError(t, err)
+
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	sort.Strings(names)
+	assert.Equal(t, []string{"core.3", "core.4", "lxc.log"}, names)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+)
+
+// Core scheduling prctl values, see Documentation/admin-guide/hw-vuln/core-scheduling.rst.
+const (
+	prSchedCore          = 62
+	prSchedCoreCreate    = 1
+	prSchedCoreShareTo   = 2
+	prSchedCoreShareFrom = 3
+	prSchedCoreScopePID  = 0
+	prSchedCoreScopeTGID = 1
+)
+
+type cmdForkcoresched struct {
+	global *cmdGlobal
+
+	flagShareFrom int
+}
+
+func (c *cmdForkcoresched) Command() *cobra.Command {
+	// Main subcommand
+	cmd := &cobra.Command{}
+	cmd.Use = "forkcoresched <PID> [<PID>...]"
+	cmd.Short = "Create or join a core scheduling domain"
+	cmd.Long = `Description:
+  Create or join a core scheduling domain
+
+  This internal command is used to create a new core scheduling cookie and
+  assign it to the given processes, so that they never share a physical core
+  with processes outside of the domain.
+
+  With --share-from, the cookie of the given process is used instead of a new
+  one, adding the processes to its existing domain.
+`
+	cmd.RunE = c.Run
+	cmd.Hidden = true
+
+	cmd.Flags().IntVar(&c.flagShareFrom, "share-from", 0, "PID of a process whose domain to join"+"``")
+
+	return cmd
+}
+
+func (c *cmdForkcoresched) Run(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		cmd.Help()
+		return fmt.Errorf("Missing required arguments")
+	}
+
+	// Only root should run this
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("This must be run as root")
+	}
+
+	// Get a cookie for ourselves, either a new one or the one of an existing
+	// domain, and then push it to the given processes.
+	if c.flagShareFrom > 0 {
+		err := unix.Prctl(prSchedCore, prSchedCoreShareFrom, uintptr(c.flagShareFrom), prSchedCoreScopePID, 0)
+		if err != nil {
+			return fmt.Errorf("Failed to get core scheduling cookie of %d: %v", c.flagShareFrom, err)
+		}
+	} else {
+		err := unix.Prctl(prSchedCore, prSchedCoreCreate, 0, prSchedCoreScopeTGID, 0)
+		if err != nil {
+			return fmt.Errorf("Failed to create core scheduling cookie: %v", err)
+		}
+	}
+
+	for _, arg := range args {
+		pid, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("Invalid PID %q", arg)
+		}
+
+		err = unix.Prctl(prSchedCore, prSchedCoreShareTo, uintptr(pid), prSchedCoreScopeTGID, 0)
+		if err != nil {
+			// The process may have exited in the meantime.
+			if err == unix.ESRCH {
+				continue
+			}
+
+			return fmt.Errorf("Failed to share core scheduling cookie with %d: %v", pid, err)
+		}
+	}
+
+	return nil
+}
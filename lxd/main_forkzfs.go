@@ -2,15 +2,16 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/sys/unix"
 
+	"github.com/lxc/lxd/lxd/tools"
 	"github.com/lxc/lxd/shared"
 )
 
@@ -89,7 +90,7 @@ func (c *cmdForkZFS) Run(cmd *cobra.Command, args []string) error {
 	}
 
 	// Run the ZFS command
-	command := exec.Command("zfs", args...)
+	command := tools.Command(context.Background(), "zfs", args...)
 	command.Stdin = os.Stdin
 	command.Stdout = os.Stdout
 	command.Stderr = os.Stderr
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/lxd/response"
+)
+
+// Return an error response if this node is in maintenance mode, so that no
+// new instance gets created or started on it while it's being drained.
+// Return nil otherwise.
+func maintenanceCheck(d *Daemon) response.Response {
+	maintenance, err := node.Maintenance(d.db)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if maintenance {
+		return response.Unavailable(fmt.Errorf("The server is in maintenance mode (core.maintenance), instances can't be created or started"))
+	}
+
+	return nil
+}
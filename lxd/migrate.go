@@ -14,6 +14,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/gorilla/websocket"
@@ -41,6 +42,10 @@ type migrationFields struct {
 	live         bool
 	instanceOnly bool
 	instance     instance.Instance
+	maxDowntime  time.Duration
+
+	// Patterns of the names of the snapshots to send, all of them if empty
+	snapshots []string
 
 	// storage specific fields
 	volumeOnly bool
@@ -1,15 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/gorilla/websocket"
@@ -24,10 +25,12 @@ import (
 	"github.com/lxc/lxd/lxd/rsync"
 	"github.com/lxc/lxd/lxd/state"
 	storagePools "github.com/lxc/lxd/lxd/storage"
+	"github.com/lxc/lxd/lxd/tools"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/idmap"
+	log "github.com/lxc/lxd/shared/log15"
 	"github.com/lxc/lxd/shared/logger"
 )
 
@@ -51,8 +54,7 @@ func newMigrationSource(inst instance.Instance, stateful bool, instanceOnly bool
 			return nil, errors.Wrap(storagePools.ErrNotImplemented, "Unable to perform VM live migration")
 		}
 
-		_, err := exec.LookPath("criu")
-		if err != nil {
+		if !tools.Available("criu") {
 			return nil, fmt.Errorf("Unable to perform container live migration. CRIU isn't installed on the source server")
 		}
 
@@ -243,7 +245,7 @@ type preDumpLoopArgs struct {
 // This function contains the actual pre-dump, the corresponding rsync
 // transfer and it tells the outer loop to abort if the threshold
 // of memory pages transferred by pre-dumping has been reached.
-func (s *migrationSourceWs) preDumpLoop(state *state.State, args *preDumpLoopArgs) (bool, error) {
+func (s *migrationSourceWs) preDumpLoop(ctx context.Context, state *state.State, args *preDumpLoopArgs) (bool, error) {
 	// Do a CRIU pre-dump
 	criuMigrationArgs := instance.CriuMigrationArgs{
 		Cmd:          liblxc.MIGRATE_PRE_DUMP,
@@ -270,7 +272,7 @@ func (s *migrationSourceWs) preDumpLoop(state *state.State, args *preDumpLoopArg
 
 	// Send the pre-dump.
 	ctName, _, _ := shared.InstanceGetParentAndSnapshotName(s.instance.Name())
-	err = rsync.Send(ctName, shared.AddSlash(args.checkpointDir), &shared.WebsocketIO{Conn: s.criuConn}, nil, args.rsyncFeatures, args.bwlimit, state.OS.ExecPath)
+	err = rsync.Send(ctx, ctName, shared.AddSlash(args.checkpointDir), &shared.WebsocketIO{Conn: s.criuConn}, nil, args.rsyncFeatures, args.bwlimit, state.OS.ExecPath)
 	if err != nil {
 		return final, err
 	}
@@ -398,8 +400,12 @@ func (s *migrationSourceWs) Do(state *state.State, migrateOp *operations.Operati
 		fullSnaps, err := s.instance.Snapshots()
 		if err == nil {
 			for _, snap := range fullSnaps {
-				snapshots = append(snapshots, snapshotToProtobuf(snap))
 				_, snapName, _ := shared.InstanceGetParentAndSnapshotName(snap.Name())
+				if len(s.snapshots) > 0 && !snapshotNameMatches(snapName, s.snapshots) {
+					continue
+				}
+
+				snapshots = append(snapshots, snapshotToProtobuf(snap))
 				snapshotNames = append(snapshotNames, snapName)
 			}
 		}
@@ -489,6 +495,27 @@ func (s *migrationSourceWs) Do(state *state.State, migrateOp *operations.Operati
 	restoreSuccess := make(chan bool, 1)
 	dumpSuccess := make(chan error, 1)
 
+	// Time at which the container got frozen for the final dump, only set if the migration can
+	// still be rolled back by letting the container run again on the source.
+	var frozen time.Time
+
+	// Closed once the container was frozen for longer than allowed, cutting the transfer of its
+	// final state so that the target never restores it.
+	var downtimeExceeded chan struct{}
+	var downtimeTimer *time.Timer
+
+	// Roll back if the transfer got cut, CRIU then resuming the container on the source.
+	rollback := func(err error) error {
+		select {
+		case <-downtimeExceeded:
+			restoreSuccess <- false
+			<-dumpSuccess
+			return abort(fmt.Errorf("Downtime exceeded the maximum of %v, migration rolled back", s.maxDowntime))
+		default:
+			return abort(err)
+		}
+	}
+
 	if s.live {
 		if respHeader.Criu == nil {
 			return abort(fmt.Errorf("Got no CRIU socket type for live migration"))
@@ -569,6 +596,10 @@ func (s *migrationSourceWs) Do(state *state.State, migrateOp *operations.Operati
 			preDumpCounter := 0
 			preDumpDir := ""
 
+			// Duration of the last pre-dump and of its transfer, the final dump having to
+			// transfer about as many memory pages.
+			var lastPreDump time.Duration
+
 			// Check if the other side knows about pre-dumping and the associated
 			// rsync protocol.
 			if respHeader.GetPredump() {
@@ -590,11 +621,14 @@ func (s *migrationSourceWs) Do(state *state.State, migrateOp *operations.Operati
 						final:         final,
 						rsyncFeatures: rsyncFeatures,
 					}
-					final, err = s.preDumpLoop(state, &loopArgs)
+					start := time.Now()
+					final, err = s.preDumpLoop(migrateOp.Context(), state, &loopArgs)
 					if err != nil {
 						os.RemoveAll(checkpointDir)
 						return abort(err)
 					}
+
+					lastPreDump = time.Since(start)
 					preDumpDir = fmt.Sprintf("%03d", preDumpCounter)
 					preDumpCounter++
 				}
@@ -602,12 +636,19 @@ func (s *migrationSourceWs) Do(state *state.State, migrateOp *operations.Operati
 				logger.Debugf("The other side does not support pre-copy")
 			}
 
+			// Don't freeze the container if it's unlikely to be migrated in time.
+			if s.maxDowntime > 0 && lastPreDump > s.maxDowntime {
+				os.RemoveAll(checkpointDir)
+				return abort(fmt.Errorf("Predicted downtime of %v exceeds the maximum of %v", lastPreDump.Round(time.Millisecond), s.maxDowntime))
+			}
+
 			_, err = actionScriptOp.Run()
 			if err != nil {
 				os.RemoveAll(checkpointDir)
 				return abort(err)
 			}
 
+			frozen = time.Now()
 			go func() {
 				criuMigrationArgs := instance.CriuMigrationArgs{
 					Cmd:          liblxc.MIGRATE_DUMP,
@@ -634,6 +675,11 @@ func (s *migrationSourceWs) Do(state *state.State, migrateOp *operations.Operati
 				logger.Debugf("Dump finished, continuing with restore...")
 			}
 		} else {
+			// Without the action script the container can't be resumed once frozen.
+			if s.maxDowntime > 0 {
+				return abort(fmt.Errorf("A maximum downtime requires liblxc 2.0.4 or newer"))
+			}
+
 			logger.Debugf("The version of liblxc is older than 2.0.4 and the live migration will probably fail")
 			defer os.RemoveAll(checkpointDir)
 			criuMigrationArgs := instance.CriuMigrationArgs{
@@ -652,15 +698,26 @@ func (s *migrationSourceWs) Do(state *state.State, migrateOp *operations.Operati
 			}
 		}
 
+		if s.maxDowntime > 0 && !frozen.IsZero() {
+			downtimeExceeded = make(chan struct{})
+			downtimeTimer = time.AfterFunc(s.maxDowntime-time.Since(frozen), func() {
+				close(downtimeExceeded)
+				s.criuConn.Close()
+				if s.fsConn != nil {
+					s.fsConn.Close()
+				}
+			})
+		}
+
 		// We do the transfer serially right now, but there's really no reason for us to;
 		// since we have separate websockets, we can do it in parallel if we wanted to.
 		// However assuming we're network bound, there's really no reason to do these in.
 		// parallel. In the future when we're using p.haul's protocol, it will make sense
 		// to do these in parallel.
 		ctName, _, _ := shared.InstanceGetParentAndSnapshotName(s.instance.Name())
-		err = rsync.Send(ctName, shared.AddSlash(checkpointDir), &shared.WebsocketIO{Conn: s.criuConn}, nil, rsyncFeatures, rsyncBwlimit, state.OS.ExecPath)
+		err = rsync.Send(migrateOp.Context(), ctName, shared.AddSlash(checkpointDir), &shared.WebsocketIO{Conn: s.criuConn}, nil, rsyncFeatures, rsyncBwlimit, state.OS.ExecPath)
 		if err != nil {
-			return abort(err)
+			return rollback(err)
 		}
 	}
 
@@ -673,10 +730,17 @@ func (s *migrationSourceWs) Do(state *state.State, migrateOp *operations.Operati
 
 		err = pool.MigrateInstance(s.instance, &shared.WebsocketIO{Conn: s.fsConn}, volSourceArgs, migrateOp)
 		if err != nil {
-			return abort(err)
+			return rollback(err)
 		}
 	}
 
+	// Once the target got the whole state it may already be running the container, so leave it
+	// to the target to report whether the migration succeeded. The restore on the target isn't
+	// accounted for.
+	if downtimeTimer != nil && !downtimeTimer.Stop() {
+		logger.Warn("Maximum downtime exceeded after the final state was transferred", log.Ctx{"project": s.instance.Project(), "instance": s.instance.Name(), "maxDowntime": s.maxDowntime})
+	}
+
 	msg := migration.MigrationControl{}
 	err = s.recv(&msg)
 	if err != nil {
@@ -748,10 +812,10 @@ func newMigrationSink(args *MigrationSinkArgs) (*migrationSink, error) {
 		sink.src.live = ok
 	}
 
-	_, err = exec.LookPath("criu")
-	if sink.push && sink.dest.live && err != nil {
+	hasCRIU := tools.Available("criu")
+	if sink.push && sink.dest.live && !hasCRIU {
 		return nil, fmt.Errorf("Unable to perform container live migration. CRIU isn't installed on the destination server")
-	} else if sink.src.live && err != nil {
+	} else if sink.src.live && !hasCRIU {
 		return nil, fmt.Errorf("Unable to perform container live migration. CRIU isn't installed on the destination server")
 	}
 
@@ -1081,7 +1145,7 @@ func (c *migrationSink) Do(state *state.State, migrateOp *operations.Operation)
 				for !sync.GetFinalPreDump() {
 					logger.Debugf("About to receive rsync")
 					// Transfer a CRIU pre-dump.
-					err = rsync.Recv(shared.AddSlash(imagesDir), &shared.WebsocketIO{Conn: criuConn}, nil, rsyncFeatures)
+					err = rsync.Recv(migrateOp.Context(), shared.AddSlash(imagesDir), &shared.WebsocketIO{Conn: criuConn}, nil, rsyncFeatures)
 					if err != nil {
 						restore <- err
 						return
@@ -1109,7 +1173,7 @@ func (c *migrationSink) Do(state *state.State, migrateOp *operations.Operation)
 			}
 
 			// Final CRIU dump.
-			err = rsync.Recv(shared.AddSlash(imagesDir), &shared.WebsocketIO{Conn: criuConn}, nil, rsyncFeatures)
+			err = rsync.Recv(migrateOp.Context(), shared.AddSlash(imagesDir), &shared.WebsocketIO{Conn: criuConn}, nil, rsyncFeatures)
 			if err != nil {
 				restore <- err
 				return
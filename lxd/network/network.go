@@ -9,18 +9,21 @@ import (
 	"os"
 	"os/exec"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/pkg/errors"
 
 	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/bgp"
 	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/daemon"
 	"github.com/lxc/lxd/lxd/dnsmasq"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/node"
 	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/tools"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -282,6 +285,18 @@ func (n *Network) setup(oldConfig map[string]string) error {
 		}
 	}
 
+	// Configure multicast snooping
+	err = n.setupMulticast(oldConfig)
+	if err != nil {
+		return err
+	}
+
+	// Setup the priority bands of the uplinks
+	err = n.setupUplinkPriority(oldConfig)
+	if err != nil {
+		return err
+	}
+
 	// Bring it up
 	_, err = shared.RunCommand("ip", "link", "set", "dev", n.name, "up")
 	if err != nil {
@@ -442,13 +457,20 @@ func (n *Network) setup(oldConfig map[string]string) error {
 				expiry = n.config["ipv4.dhcp.expiry"]
 			}
 
-			if n.config["ipv4.dhcp.ranges"] != "" {
-				for _, dhcpRange := range strings.Split(n.config["ipv4.dhcp.ranges"], ",") {
-					dhcpRange = strings.TrimSpace(dhcpRange)
-					dnsmasqCmd = append(dnsmasqCmd, []string{"--dhcp-range", fmt.Sprintf("%s,%s", strings.Replace(dhcpRange, "-", ",", -1), expiry)}...)
-				}
-			} else {
-				dnsmasqCmd = append(dnsmasqCmd, []string{"--dhcp-range", fmt.Sprintf("%s,%s,%s", GetIP(subnet, 2).String(), GetIP(subnet, -2).String(), expiry)}...)
+			dhcpRanges := n.DHCPv4Ranges()
+			if len(dhcpRanges) == 0 {
+				dhcpRanges = append(dhcpRanges, DHCPRange{Start: GetIP(subnet, 2).To4(), End: GetIP(subnet, -2).To4()})
+			}
+
+			// Leave out the excluded and reserved addresses from dynamic allocation.
+			dhcpRanges = SubtractIPRanges(dhcpRanges, n.DHCPv4Exclusions())
+			for _, dhcpRange := range dhcpRanges {
+				dnsmasqCmd = append(dnsmasqCmd, []string{"--dhcp-range", fmt.Sprintf("%s,%s,%s", dhcpRange.Start.String(), dhcpRange.End.String(), expiry)}...)
+			}
+
+			// Keep serving the static allocations if nothing is left for dynamic allocation.
+			if len(dhcpRanges) == 0 {
+				dnsmasqCmd = append(dnsmasqCmd, []string{"--dhcp-range", fmt.Sprintf("%s,static,%s,%s", subnet.IP.String(), net.IP(subnet.Mask).String(), expiry)}...)
 			}
 		}
 
@@ -479,6 +501,12 @@ func (n *Network) setup(oldConfig map[string]string) error {
 			}
 		}
 
+		// Let traffic flow directly to and from the peered networks.
+		err = n.setupPeers(subnet)
+		if err != nil {
+			return err
+		}
+
 		// Add additional routes
 		if n.config["ipv4.routes"] != "" {
 			for _, route := range strings.Split(n.config["ipv4.routes"], ",") {
@@ -560,13 +588,20 @@ func (n *Network) setup(oldConfig map[string]string) error {
 
 			if shared.IsTrue(n.config["ipv6.dhcp.stateful"]) {
 				subnetSize, _ := subnet.Mask.Size()
-				if n.config["ipv6.dhcp.ranges"] != "" {
-					for _, dhcpRange := range strings.Split(n.config["ipv6.dhcp.ranges"], ",") {
-						dhcpRange = strings.TrimSpace(dhcpRange)
-						dnsmasqCmd = append(dnsmasqCmd, []string{"--dhcp-range", fmt.Sprintf("%s,%d,%s", strings.Replace(dhcpRange, "-", ",", -1), subnetSize, expiry)}...)
-					}
-				} else {
-					dnsmasqCmd = append(dnsmasqCmd, []string{"--dhcp-range", fmt.Sprintf("%s,%s,%d,%s", GetIP(subnet, 2), GetIP(subnet, -1), subnetSize, expiry)}...)
+				dhcpRanges := n.DHCPv6Ranges()
+				if len(dhcpRanges) == 0 {
+					dhcpRanges = append(dhcpRanges, DHCPRange{Start: GetIP(subnet, 2).To16(), End: GetIP(subnet, -1).To16()})
+				}
+
+				// Leave out the excluded and reserved addresses from dynamic allocation.
+				dhcpRanges = SubtractIPRanges(dhcpRanges, n.DHCPv6Exclusions())
+				for _, dhcpRange := range dhcpRanges {
+					dnsmasqCmd = append(dnsmasqCmd, []string{"--dhcp-range", fmt.Sprintf("%s,%s,%d,%s", dhcpRange.Start.String(), dhcpRange.End.String(), subnetSize, expiry)}...)
+				}
+
+				// Keep serving the static allocations if nothing is left for dynamic allocation.
+				if len(dhcpRanges) == 0 {
+					dnsmasqCmd = append(dnsmasqCmd, []string{"--dhcp-range", fmt.Sprintf("%s,static,%d,%s", subnet.IP.String(), subnetSize, expiry)}...)
 				}
 			} else {
 				dnsmasqCmd = append(dnsmasqCmd, []string{"--dhcp-range", fmt.Sprintf("::,constructor:%s,ra-stateless,ra-names", n.name)}...)
@@ -645,6 +680,12 @@ func (n *Network) setup(oldConfig map[string]string) error {
 			}
 		}
 
+		// Let traffic flow directly to and from the peered networks.
+		err = n.setupPeers(subnet)
+		if err != nil {
+			return err
+		}
+
 		// Add additional routes
 		if n.config["ipv6.routes"] != "" {
 			for _, route := range strings.Split(n.config["ipv6.routes"], ",") {
@@ -968,8 +1009,7 @@ func (n *Network) setup(oldConfig map[string]string) error {
 		}
 
 		// Check for dnsmasq
-		_, err := exec.LookPath("dnsmasq")
-		if err != nil {
+		if !tools.Available("dnsmasq") {
 			return fmt.Errorf("dnsmasq is required for LXD managed bridges")
 		}
 
@@ -1043,6 +1083,12 @@ func (n *Network) setup(oldConfig map[string]string) error {
 		}
 	}
 
+	// Advertise the network's subnets and routes over BGP.
+	err = n.bgpSetup()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -1065,6 +1111,11 @@ func (n *Network) Stop() error {
 		}
 	}
 
+	// Restore the default qdisc of the uplinks.
+	for _, uplink := range priorityUplinks(n.config) {
+		networkClearUplinkPriority(uplink)
+	}
+
 	// Cleanup firewall rules.
 	if UsesIPv4Firewall(n.config) {
 		err := n.state.Firewall.NetworkClear(n.name, 4)
@@ -1080,8 +1131,14 @@ func (n *Network) Stop() error {
 		}
 	}
 
+	// Withdraw the network from BGP.
+	err := n.bgpClear()
+	if err != nil {
+		return err
+	}
+
 	// Kill any existing dnsmasq and forkdns daemon for this network
-	err := dnsmasq.Kill(n.name, false)
+	err = dnsmasq.Kill(n.name, false)
 	if err != nil {
 		return err
 	}
@@ -1622,6 +1679,300 @@ func (n *Network) DHCPv6Ranges() []DHCPRange {
 	return dhcpRanges
 }
 
+// setupPeers sets up the firewall so that traffic flows directly between the network and the networks
+// peered with it, for the address family of the given subnet. Peered networks without an address of
+// that family are skipped.
+func (n *Network) setupPeers(subnet *net.IPNet) error {
+	peers, err := n.state.Cluster.NetworkPeeredNetworks(n.name)
+	if err != nil {
+		return err
+	}
+
+	key := "ipv4.address"
+	if subnet.IP.To4() == nil {
+		key = "ipv6.address"
+	}
+
+	for _, peer := range peers {
+		_, peerNetwork, err := n.state.Cluster.NetworkGet(peer)
+		if err != nil {
+			return err
+		}
+
+		_, peerSubnet, err := net.ParseCIDR(peerNetwork.Config[key])
+		if err != nil {
+			continue
+		}
+
+		err = n.state.Firewall.NetworkSetupPeer(n.name, peer, subnet, peerSubnet)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setupMulticast applies the multicast settings of the bridge. With snooping enabled, multicast
+// traffic is only forwarded to the ports which joined the group rather than flooded to all of them.
+// Only the settings which are set, or were set in the old config, are applied so that the kernel
+// defaults are left alone otherwise (older kernels lack some of the knobs).
+func (n *Network) setupMulticast(oldConfig map[string]string) error {
+	changed := func(key string) bool {
+		return n.config[key] != "" || oldConfig[key] != ""
+	}
+
+	snooping := n.config["bridge.multicast.snooping"] == "" || shared.IsTrue(n.config["bridge.multicast.snooping"])
+	querier := shared.IsTrue(n.config["bridge.multicast.querier"])
+	flood := n.config["bridge.multicast.flood"] == "" || shared.IsTrue(n.config["bridge.multicast.flood"])
+
+	if n.config["bridge.driver"] == "openvswitch" {
+		if querier {
+			return fmt.Errorf("Open vSwitch bridges don't support acting as multicast querier")
+		}
+
+		settings := []string{}
+		if changed("bridge.multicast.snooping") {
+			settings = append(settings, fmt.Sprintf("mcast_snooping_enable=%t", snooping))
+		}
+
+		if changed("bridge.multicast.flood") {
+			settings = append(settings, fmt.Sprintf("other_config:mcast-snooping-disable-flood-unregistered=%t", !flood))
+		}
+
+		if len(settings) == 0 {
+			return nil
+		}
+
+		_, err := shared.RunCommand("ovs-vsctl", append([]string{"set", "bridge", n.name}, settings...)...)
+		return err
+	}
+
+	settings := map[string]bool{}
+	if changed("bridge.multicast.snooping") {
+		settings["multicast_snooping"] = snooping
+	}
+
+	if changed("bridge.multicast.querier") {
+		settings["multicast_querier"] = querier
+	}
+
+	for key, enabled := range settings {
+		value := "0"
+		if enabled {
+			value = "1"
+		}
+
+		err := ioutil.WriteFile(fmt.Sprintf("/sys/class/net/%s/bridge/%s", n.name, key), []byte(value), 0)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to set %s on bridge %q", key, n.name)
+		}
+	}
+
+	if !changed("bridge.multicast.flood") {
+		return nil
+	}
+
+	// Apply the flooding policy to the ports already on the bridge.
+	ports, err := ioutil.ReadDir(fmt.Sprintf("/sys/class/net/%s/brif", n.name))
+	if err != nil {
+		return err
+	}
+
+	for _, port := range ports {
+		err = n.setPortMulticastFlood(port.Name(), flood)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setupUplinkPriority installs a prio qdisc, with one fq_codel child per band, on the uplinks listed in
+// bridge.priority_uplinks so that the packets of the instances are queued according to their
+// limits.network.priority (see PriorityClass). The uplinks removed from the list get their default
+// qdisc back.
+func (n *Network) setupUplinkPriority(oldConfig map[string]string) error {
+	uplinks := priorityUplinks(n.config)
+	for _, uplink := range priorityUplinks(oldConfig) {
+		if !shared.StringInSlice(uplink, uplinks) {
+			networkClearUplinkPriority(uplink)
+		}
+	}
+
+	for _, uplink := range uplinks {
+		if !shared.PathExists(fmt.Sprintf("/sys/class/net/%s", uplink)) {
+			continue
+		}
+
+		_, err := shared.RunCommand("tc", "qdisc", "replace", "dev", uplink, "root", "handle", priorityHandle, "prio", "bands", "3")
+		if err != nil {
+			return errors.Wrapf(err, "Failed to setup the priority qdisc of %q", uplink)
+		}
+
+		for band := 1; band <= 3; band++ {
+			_, err = shared.RunCommand("tc", "qdisc", "replace", "dev", uplink, "parent", fmt.Sprintf("%s%d", priorityHandle, band), "fq_codel")
+			if err != nil {
+				return errors.Wrapf(err, "Failed to setup the priority qdisc of %q", uplink)
+			}
+		}
+	}
+
+	return nil
+}
+
+// SetupPortMulticast applies the network's policy for unregistered multicast traffic to a port of
+// the bridge, either flooding it (default) or only forwarding it to the multicast routers. Nothing
+// is done unless bridge.multicast.flood is set.
+func (n *Network) SetupPortMulticast(devName string) error {
+	// Open vSwitch applies the policy to the whole bridge.
+	if n.config["bridge.driver"] == "openvswitch" || n.config["bridge.multicast.flood"] == "" {
+		return nil
+	}
+
+	return n.setPortMulticastFlood(devName, shared.IsTrue(n.config["bridge.multicast.flood"]))
+}
+
+func (n *Network) setPortMulticastFlood(devName string, flood bool) error {
+	value := "0"
+	if flood {
+		value = "1"
+	}
+
+	err := ioutil.WriteFile(fmt.Sprintf("/sys/class/net/%s/brport/multicast_flood", devName), []byte(value), 0)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to set multicast flooding on %q", devName)
+	}
+
+	return nil
+}
+
+// bgpSetup advertises the network over BGP to the peers configured on it. Subnets are only
+// advertised when they aren't NATed, along with the additional routes of the network. Only the
+// peers and prefixes which changed are updated, leaving the other sessions alone.
+func (n *Network) bgpSetup() error {
+	if n.state.BGP == nil {
+		return nil
+	}
+
+	owner := fmt.Sprintf("network_%s", n.name)
+
+	// Get the peers.
+	peers := []bgp.Peer{}
+	for k, address := range n.config {
+		fields := strings.Split(k, ".")
+		if len(fields) != 4 || fields[0] != "bgp" || fields[1] != "peers" || fields[3] != "address" {
+			continue
+		}
+
+		peer := strings.Join(fields[:3], ".")
+		asn, err := strconv.ParseUint(n.config[peer+".asn"], 10, 32)
+		if err != nil {
+			return errors.Wrapf(err, "Invalid ASN for BGP peer %q", fields[2])
+		}
+
+		peers = append(peers, bgp.Peer{
+			Address:  net.ParseIP(address),
+			ASN:      uint32(asn),
+			Password: n.config[peer+".password"],
+		})
+	}
+
+	// Get the prefixes.
+	prefixes := []bgp.Prefix{}
+	for _, family := range []string{"ipv4", "ipv6"} {
+		subnets := []string{}
+
+		address := n.config[fmt.Sprintf("%s.address", family)]
+		if !shared.StringInSlice(address, []string{"", "none"}) && !shared.IsTrue(n.config[fmt.Sprintf("%s.nat", family)]) {
+			subnets = append(subnets, address)
+		}
+
+		routes := n.config[fmt.Sprintf("%s.routes", family)]
+		if routes != "" {
+			for _, route := range strings.Split(routes, ",") {
+				subnets = append(subnets, strings.TrimSpace(route))
+			}
+		}
+
+		var nexthop net.IP
+		if n.config[fmt.Sprintf("bgp.%s.nexthop", family)] != "" {
+			nexthop = net.ParseIP(n.config[fmt.Sprintf("bgp.%s.nexthop", family)])
+		}
+
+		for _, prefix := range subnets {
+			_, subnet, err := net.ParseCIDR(prefix)
+			if err != nil {
+				return err
+			}
+
+			prefixes = append(prefixes, bgp.Prefix{Prefix: *subnet, Nexthop: nexthop})
+		}
+	}
+
+	// Update the peers first, so that the prefixes are only sent to the current ones.
+	err := n.state.BGP.UpdatePeers(owner, peers)
+	if err != nil {
+		return err
+	}
+
+	return n.state.BGP.UpdatePrefixes(owner, prefixes)
+}
+
+// bgpClear withdraws the network's prefixes and drops its BGP peers.
+func (n *Network) bgpClear() error {
+	if n.state.BGP == nil {
+		return nil
+	}
+
+	owner := fmt.Sprintf("network_%s", n.name)
+
+	err := n.state.BGP.RemovePrefixes(owner)
+	if err != nil {
+		return err
+	}
+
+	return n.state.BGP.RemovePeers(owner)
+}
+
+// DHCPv4Exclusions returns the IPv4 ranges which mustn't be dynamically allocated, including the
+// ranges holding address reservations.
+func (n *Network) DHCPv4Exclusions() []DHCPRange {
+	return n.configRanges("ipv4.dhcp.exclude", "ipv4.reservations.ranges")
+}
+
+// DHCPv6Exclusions returns the IPv6 ranges which mustn't be dynamically allocated, including the
+// ranges holding address reservations.
+func (n *Network) DHCPv6Exclusions() []DHCPRange {
+	return n.configRanges("ipv6.dhcp.exclude", "ipv6.reservations.ranges")
+}
+
+// ReservationV4Ranges returns the IPv4 ranges from which address reservations are allocated.
+func (n *Network) ReservationV4Ranges() []DHCPRange {
+	return n.configRanges("ipv4.reservations.ranges")
+}
+
+// ReservationV6Ranges returns the IPv6 ranges from which address reservations are allocated.
+func (n *Network) ReservationV6Ranges() []DHCPRange {
+	return n.configRanges("ipv6.reservations.ranges")
+}
+
+// configRanges returns the IP ranges listed in the given config keys, skipping invalid values.
+func (n *Network) configRanges(keys ...string) []DHCPRange {
+	ranges := []DHCPRange{}
+	for _, key := range keys {
+		parsed, err := ParseIPRanges(n.config[key])
+		if err != nil {
+			continue
+		}
+
+		ranges = append(ranges, parsed...)
+	}
+
+	return ranges
+}
+
 // HasIPv4Firewall indicates whether the network has IPv4 firewall enabled.
 func (n *Network) HasIPv4Firewall() bool {
 	if n.config["ipv4.firewall"] == "" || shared.IsTrue(n.config["ipv4.firewall"]) {
@@ -2,6 +2,7 @@ package network
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
@@ -849,3 +850,150 @@ func UsesIPv6Firewall(netConfig map[string]string) bool {
 
 	return false
 }
+
+// ParseIPRanges parses a comma separated list of IP ranges in FIRST-LAST format.
+func ParseIPRanges(value string) ([]DHCPRange, error) {
+	ranges := []DHCPRange{}
+	if value == "" {
+		return ranges, nil
+	}
+
+	for _, r := range strings.Split(value, ",") {
+		r = strings.TrimSpace(r)
+		parts := strings.SplitN(r, "-", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid IP range %q (must be FIRST-LAST)", r)
+		}
+
+		start := net.ParseIP(strings.TrimSpace(parts[0]))
+		end := net.ParseIP(strings.TrimSpace(parts[1]))
+		if start == nil || end == nil {
+			return nil, fmt.Errorf("Invalid IP range %q", r)
+		}
+
+		if (start.To4() == nil) != (end.To4() == nil) {
+			return nil, fmt.Errorf("Invalid IP range %q (mixed address families)", r)
+		}
+
+		if start.To4() != nil {
+			start = start.To4()
+			end = end.To4()
+		} else {
+			start = start.To16()
+			end = end.To16()
+		}
+
+		if bytes.Compare(start, end) > 0 {
+			return nil, fmt.Errorf("Invalid IP range %q (start is after end)", r)
+		}
+
+		ranges = append(ranges, DHCPRange{Start: start, End: end})
+	}
+
+	return ranges, nil
+}
+
+// SubtractIPRanges returns the parts of the ranges which aren't covered by any of the excluded ranges.
+func SubtractIPRanges(ranges []DHCPRange, excluded []DHCPRange) []DHCPRange {
+	result := ranges
+	for _, ex := range excluded {
+		remaining := []DHCPRange{}
+		for _, r := range result {
+			// Ranges of a different family or not overlapping are kept as is.
+			if len(r.Start) != len(ex.Start) || bytes.Compare(ex.End, r.Start) < 0 || bytes.Compare(ex.Start, r.End) > 0 {
+				remaining = append(remaining, r)
+				continue
+			}
+
+			if bytes.Compare(ex.Start, r.Start) > 0 {
+				remaining = append(remaining, DHCPRange{Start: r.Start, End: ipAdd(ex.Start, -1)})
+			}
+
+			if bytes.Compare(ex.End, r.End) < 0 {
+				remaining = append(remaining, DHCPRange{Start: ipAdd(ex.End, 1), End: r.End})
+			}
+		}
+
+		result = remaining
+	}
+
+	return result
+}
+
+// IPInRanges returns whether the IP is part of one of the ranges.
+func IPInRanges(IP net.IP, ranges []DHCPRange) bool {
+	for _, r := range ranges {
+		ip := IP.To16()
+		if len(r.Start) == net.IPv4len {
+			ip = IP.To4()
+		}
+
+		if ip == nil {
+			continue
+		}
+
+		if bytes.Compare(ip, r.Start) >= 0 && bytes.Compare(ip, r.End) <= 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ipAdd returns the IP offset by delta, keeping the length of the original IP.
+func ipAdd(IP net.IP, delta int64) net.IP {
+	bigIP := big.NewInt(0)
+	bigIP.SetBytes(IP)
+	bigIP.Add(bigIP, big.NewInt(delta))
+
+	newIP := make(net.IP, len(IP))
+	ipBytes := bigIP.Bytes()
+	copy(newIP[len(newIP)-len(ipBytes):], ipBytes)
+	return newIP
+}
+
+// priorityHandle is the handle of the prio qdisc installed on the uplinks listed in bridge.priority_uplinks.
+const priorityHandle = "4c58:"
+
+// PriorityClass returns the class of the uplink prio qdisc which the packets of an instance with the
+// given limits.network.priority are queued in. Its minor number directly selects the band: 7 and above
+// go in the first band, 4 to 6 in the second one (along with the unmarked traffic) and 3 and below in
+// the last one.
+func PriorityClass(priority int) string {
+	band := 2
+	if priority >= 7 {
+		band = 1
+	} else if priority <= 3 {
+		band = 3
+	}
+
+	return fmt.Sprintf("%s%d", priorityHandle, band)
+}
+
+// priorityUplinks returns the interfaces listed in bridge.priority_uplinks.
+func priorityUplinks(config map[string]string) []string {
+	uplinks := []string{}
+	for _, entry := range strings.Split(config["bridge.priority_uplinks"], ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		uplinks = append(uplinks, entry)
+	}
+
+	return uplinks
+}
+
+// networkClearUplinkPriority restores the default qdisc of an uplink if it still has the prio one.
+func networkClearUplinkPriority(uplink string) {
+	out, err := shared.RunCommand("tc", "qdisc", "show", "dev", uplink, "root")
+	if err != nil || !strings.Contains(out, fmt.Sprintf("qdisc prio %s", priorityHandle)) {
+		return
+	}
+
+	_, err = shared.RunCommand("tc", "qdisc", "del", "dev", uplink, "root")
+	if err != nil {
+		logger.Errorf("Failed to remove the priority qdisc of %q: %v", uplink, err)
+	}
+}
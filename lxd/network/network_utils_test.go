@@ -0,0 +1,57 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIPRanges(t *testing.T) {
+	ranges, err := ParseIPRanges("10.0.0.10-10.0.0.20, fd42::10-fd42::20")
+	require.NoError(t, err)
+	require.Len(t, ranges, 2)
+	assert.Equal(t, "10.0.0.10", ranges[0].Start.String())
+	assert.Len(t, ranges[0].Start, 4)
+	assert.Equal(t, "fd42::20", ranges[1].End.String())
+
+	for _, value := range []string{"10.0.0.10", "10.0.0.20-10.0.0.10", "10.0.0.10-fd42::20", "foo-bar"} {
+		_, err := ParseIPRanges(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestSubtractIPRanges(t *testing.T) {
+	ranges, err := ParseIPRanges("10.0.0.2-10.0.0.254")
+	require.NoError(t, err)
+
+	excluded, err := ParseIPRanges("10.0.0.1-10.0.0.9,10.0.0.100-10.0.0.199,fd42::1-fd42::ff")
+	require.NoError(t, err)
+
+	result := SubtractIPRanges(ranges, excluded)
+	require.Len(t, result, 2)
+	assert.Equal(t, "10.0.0.10", result[0].Start.String())
+	assert.Equal(t, "10.0.0.99", result[0].End.String())
+	assert.Equal(t, "10.0.0.200", result[1].Start.String())
+	assert.Equal(t, "10.0.0.254", result[1].End.String())
+
+	assert.True(t, IPInRanges(result[0].Start, result))
+	assert.False(t, IPInRanges(excluded[1].Start, result))
+
+	// Excluding everything leaves nothing.
+	assert.Len(t, SubtractIPRanges(ranges, ranges), 0)
+}
+
+func TestPriorityClass(t *testing.T) {
+	assert.Equal(t, "4c58:1", PriorityClass(10))
+	assert.Equal(t, "4c58:1", PriorityClass(7))
+	assert.Equal(t, "4c58:2", PriorityClass(6))
+	assert.Equal(t, "4c58:2", PriorityClass(4))
+	assert.Equal(t, "4c58:3", PriorityClass(3))
+	assert.Equal(t, "4c58:3", PriorityClass(0))
+}
+
+func TestPriorityUplinks(t *testing.T) {
+	assert.Equal(t, []string{"eth0", "eth1"}, priorityUplinks(map[string]string{"bridge.priority_uplinks": "eth0, eth1,"}))
+	assert.Equal(t, []string{}, priorityUplinks(nil))
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/network"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
+)
+
+var networkPeersCmd = APIEndpoint{
+	Path: "networks/{name}/peers",
+
+	Get:  APIEndpointAction{Handler: networkPeersGet, AccessHandler: allowAuthenticated},
+	Post: APIEndpointAction{Handler: networkPeersPost},
+}
+
+var networkPeerCmd = APIEndpoint{
+	Path: "networks/{name}/peers/{peer}",
+
+	Delete: APIEndpointAction{Handler: networkPeerDelete},
+	Get:    APIEndpointAction{Handler: networkPeerGet, AccessHandler: allowAuthenticated},
+	Patch:  APIEndpointAction{Handler: networkPeerPut},
+	Put:    APIEndpointAction{Handler: networkPeerPut},
+}
+
+var internalNetworkPeersCmd = APIEndpoint{
+	Path: "networks/peers",
+
+	Post: APIEndpointAction{Handler: internalNetworkPeersPost},
+}
+
+// Request sent to the other cluster members when peerings change.
+type internalNetworkPeersRequest struct {
+	Networks []string `json:"networks"`
+}
+
+func networkPeersGet(d *Daemon, r *http.Request) response.Response {
+	name := mux.Vars(r)["name"]
+	recursion := util.IsRecursionRequest(r)
+
+	var dbPeers []db.NetworkPeer
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		networkID, err := tx.NetworkID(name)
+		if err != nil {
+			return err
+		}
+
+		dbPeers, err = tx.NetworkPeers(networkID)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	resultString := []string{}
+	resultMap := []api.NetworkPeer{}
+	for _, dbPeer := range dbPeers {
+		if !recursion {
+			resultString = append(resultString, fmt.Sprintf("/%s/networks/%s/peers/%s", version.APIVersion, name, dbPeer.Name))
+		} else {
+			resultMap = append(resultMap, networkPeerToAPI(dbPeer))
+		}
+	}
+
+	if !recursion {
+		return response.SyncResponse(true, resultString)
+	}
+
+	return response.SyncResponse(true, resultMap)
+}
+
+func networkPeersPost(d *Daemon, r *http.Request) response.Response {
+	name := mux.Vars(r)["name"]
+
+	req := api.NetworkPeersPost{}
+	err := shared.ReadToJSON(r.Body, &req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if req.Name == "" {
+		return response.BadRequest(fmt.Errorf("No name provided"))
+	}
+
+	if strings.Contains(req.Name, "/") {
+		return response.BadRequest(fmt.Errorf("Peer names may not contain slashes"))
+	}
+
+	if req.TargetNetwork == "" {
+		return response.BadRequest(fmt.Errorf("No target network provided"))
+	}
+
+	if req.TargetNetwork == name {
+		return response.BadRequest(fmt.Errorf("A network can't be peered with itself"))
+	}
+
+	peered, err := d.cluster.NetworkPeeredNetworks(name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if shared.StringInSlice(req.TargetNetwork, peered) {
+		return response.SmartError(errors.Wrapf(db.ErrAlreadyDefined, "Network %q is already peered with %q", name, req.TargetNetwork))
+	}
+
+	var networkID int64
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		networkID, err = tx.NetworkID(name)
+		if err != nil {
+			return err
+		}
+
+		targetID, err := tx.NetworkID(req.TargetNetwork)
+		if err != nil {
+			if err == db.ErrNoSuchObject {
+				return errors.Wrapf(err, "Target network %q doesn't exist", req.TargetNetwork)
+			}
+
+			return err
+		}
+
+		_, err = tx.NetworkPeer(networkID, req.Name)
+		if err == nil {
+			return errors.Wrapf(db.ErrAlreadyDefined, "Peer %q already exists", req.Name)
+		} else if err != db.ErrNoSuchObject {
+			return err
+		}
+
+		return tx.NetworkPeerCreate(networkID, req.Name, req.Description, targetID)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = networkPeersApply(d, []string{name, req.TargetNetwork})
+	if err != nil {
+		d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			return tx.NetworkPeerDelete(networkID, req.Name)
+		})
+
+		networkPeersApply(d, []string{name, req.TargetNetwork})
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponseLocation(true, nil, fmt.Sprintf("/%s/networks/%s/peers/%s", version.APIVersion, name, req.Name))
+}
+
+func networkPeerGet(d *Daemon, r *http.Request) response.Response {
+	name := mux.Vars(r)["name"]
+	peerName := mux.Vars(r)["peer"]
+
+	var dbPeer db.NetworkPeer
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		networkID, err := tx.NetworkID(name)
+		if err != nil {
+			return err
+		}
+
+		dbPeer, err = tx.NetworkPeer(networkID, peerName)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	peer := networkPeerToAPI(dbPeer)
+	return response.SyncResponseETag(true, peer, peer.Writable())
+}
+
+func networkPeerPut(d *Daemon, r *http.Request) response.Response {
+	name := mux.Vars(r)["name"]
+	peerName := mux.Vars(r)["peer"]
+
+	var networkID int64
+	var dbPeer db.NetworkPeer
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		networkID, err = tx.NetworkID(name)
+		if err != nil {
+			return err
+		}
+
+		dbPeer, err = tx.NetworkPeer(networkID, peerName)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Validate the ETag
+	peer := networkPeerToAPI(dbPeer)
+	err = util.EtagCheck(r, peer.Writable())
+	if err != nil {
+		return response.PreconditionFailed(err)
+	}
+
+	req := api.NetworkPeerPut{}
+	err = shared.ReadToJSON(r.Body, &req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.NetworkPeerUpdateDescription(networkID, peerName, req.Description)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func networkPeerDelete(d *Daemon, r *http.Request) response.Response {
+	name := mux.Vars(r)["name"]
+	peerName := mux.Vars(r)["peer"]
+
+	var dbPeer db.NetworkPeer
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		networkID, err := tx.NetworkID(name)
+		if err != nil {
+			return err
+		}
+
+		dbPeer, err = tx.NetworkPeer(networkID, peerName)
+		if err != nil {
+			return err
+		}
+
+		return tx.NetworkPeerDelete(networkID, peerName)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = networkPeersApply(d, []string{name, dbPeer.TargetNetwork})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+// Set up the networks again after their peerings changed on another cluster member.
+func internalNetworkPeersPost(d *Daemon, r *http.Request) response.Response {
+	req := internalNetworkPeersRequest{}
+	err := shared.ReadToJSON(r.Body, &req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = networkPeersRestart(d, req.Networks)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func networkPeerToAPI(dbPeer db.NetworkPeer) api.NetworkPeer {
+	return api.NetworkPeer{
+		NetworkPeerPut: api.NetworkPeerPut{
+			Description: dbPeer.Description,
+		},
+		Name:          dbPeer.Name,
+		TargetNetwork: dbPeer.TargetNetwork,
+	}
+}
+
+// networkPeersApply sets up the given networks again on all the cluster members, so that their
+// firewall rules match their current peerings.
+func networkPeersApply(d *Daemon, names []string) error {
+	err := networkPeersRestart(d, names)
+	if err != nil {
+		return err
+	}
+
+	notifier, err := cluster.NewNotifier(d.State(), d.endpoints.NetworkCert(), cluster.NotifyAlive)
+	if err != nil {
+		return err
+	}
+
+	return notifier(func(client lxd.InstanceServer) error {
+		_, _, err := client.RawQuery("POST", "/internal/networks/peers", internalNetworkPeersRequest{Networks: names}, "")
+		return err
+	})
+}
+
+// networkPeersRestart sets up the given networks again on this member, skipping those which
+// aren't running.
+func networkPeersRestart(d *Daemon, names []string) error {
+	for _, name := range names {
+		n, err := network.LoadByName(d.State(), name)
+		if err != nil {
+			return err
+		}
+
+		if !n.IsRunning() {
+			continue
+		}
+
+		err = n.Start()
+		if err != nil {
+			return errors.Wrapf(err, "Failed to set up network %q", name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/dnsmasq"
+	"github.com/lxc/lxd/lxd/network"
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
+)
+
+var networkReservationsCmd = APIEndpoint{
+	Path: "networks/{name}/reservations",
+
+	Get:  APIEndpointAction{Handler: networkReservationsGet, AccessHandler: allowAuthenticated},
+	Post: APIEndpointAction{Handler: networkReservationsPost},
+}
+
+var networkReservationCmd = APIEndpoint{
+	Path: "networks/{name}/reservations/{reservation}",
+
+	Delete: APIEndpointAction{Handler: networkReservationDelete},
+	Get:    APIEndpointAction{Handler: networkReservationGet, AccessHandler: allowAuthenticated},
+	Patch:  APIEndpointAction{Handler: networkReservationPut},
+	Put:    APIEndpointAction{Handler: networkReservationPut},
+}
+
+func networkReservationsGet(d *Daemon, r *http.Request) response.Response {
+	name := mux.Vars(r)["name"]
+	recursion := util.IsRecursionRequest(r)
+
+	var dbReservations []db.NetworkReservation
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		networkID, err := tx.NetworkID(name)
+		if err != nil {
+			return err
+		}
+
+		dbReservations, err = tx.NetworkReservations(networkID)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	resultString := []string{}
+	resultMap := []api.NetworkReservation{}
+	for _, dbReservation := range dbReservations {
+		if !recursion {
+			resultString = append(resultString, fmt.Sprintf("/%s/networks/%s/reservations/%s", version.APIVersion, name, dbReservation.Name))
+		} else {
+			resultMap = append(resultMap, networkReservationToAPI(dbReservation))
+		}
+	}
+
+	if !recursion {
+		return response.SyncResponse(true, resultString)
+	}
+
+	return response.SyncResponse(true, resultMap)
+}
+
+// Reserve addresses on the network, either the requested ones or free ones from the network's
+// reservation ranges.
+func networkReservationsPost(d *Daemon, r *http.Request) response.Response {
+	name := mux.Vars(r)["name"]
+
+	req := api.NetworkReservationsPost{}
+	err := shared.ReadToJSON(r.Body, &req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if req.Name == "" {
+		return response.BadRequest(fmt.Errorf("No name provided"))
+	}
+
+	if strings.Contains(req.Name, "/") {
+		return response.BadRequest(fmt.Errorf("Reservation names may not contain slashes"))
+	}
+
+	n, err := network.LoadByName(d.State(), name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	netConfig := n.Config()
+
+	// Addresses currently handed out by DHCP can't be reserved.
+	dnsmasq.ConfigMutex.Lock()
+	IPv4Allocs, IPv6Allocs, err := dnsmasq.DHCPAllocatedIPs(name)
+	dnsmasq.ConfigMutex.Unlock()
+	if err != nil && !os.IsNotExist(err) {
+		return response.SmartError(err)
+	}
+
+	used := map[string]bool{}
+	for _, alloc := range IPv4Allocs {
+		used[alloc.IP.String()] = true
+	}
+
+	for _, alloc := range IPv6Allocs {
+		used[alloc.IP.String()] = true
+	}
+
+	reservation := db.NetworkReservation{
+		Name:        req.Name,
+		Description: req.Description,
+	}
+
+	// Errors caused by the request itself, as opposed to database failures.
+	var reqErr error
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		networkID, err := tx.NetworkID(name)
+		if err != nil {
+			return err
+		}
+
+		reservations, err := tx.NetworkReservations(networkID)
+		if err != nil {
+			return err
+		}
+
+		for _, existing := range reservations {
+			if existing.Name == req.Name {
+				return errors.Wrapf(db.ErrAlreadyDefined, "A reservation named %q already exists", req.Name)
+			}
+
+			used[existing.IPv4Address] = true
+			used[existing.IPv6Address] = true
+		}
+
+		reservation.IPv4Address, reqErr = networkReservationAddress(netConfig["ipv4.address"], n.ReservationV4Ranges(), req.IPv4Address, used)
+		if reqErr != nil {
+			return reqErr
+		}
+
+		reservation.IPv6Address, reqErr = networkReservationAddress(netConfig["ipv6.address"], n.ReservationV6Ranges(), req.IPv6Address, used)
+		if reqErr != nil {
+			return reqErr
+		}
+
+		if reservation.IPv4Address == "" && reservation.IPv6Address == "" {
+			reqErr = fmt.Errorf("Network %q has no reservation ranges configured", name)
+			return reqErr
+		}
+
+		return tx.NetworkReservationCreate(networkID, reservation)
+	})
+	if reqErr != nil {
+		return response.BadRequest(reqErr)
+	}
+
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponseLocation(true, nil, fmt.Sprintf("/%s/networks/%s/reservations/%s", version.APIVersion, name, req.Name))
+}
+
+func networkReservationGet(d *Daemon, r *http.Request) response.Response {
+	name := mux.Vars(r)["name"]
+	reservationName := mux.Vars(r)["reservation"]
+
+	var dbReservation db.NetworkReservation
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		networkID, err := tx.NetworkID(name)
+		if err != nil {
+			return err
+		}
+
+		dbReservation, err = tx.NetworkReservation(networkID, reservationName)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	reservation := networkReservationToAPI(dbReservation)
+	return response.SyncResponseETag(true, reservation, reservation.Writable())
+}
+
+func networkReservationPut(d *Daemon, r *http.Request) response.Response {
+	name := mux.Vars(r)["name"]
+	reservationName := mux.Vars(r)["reservation"]
+
+	var networkID int64
+	var dbReservation db.NetworkReservation
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		networkID, err = tx.NetworkID(name)
+		if err != nil {
+			return err
+		}
+
+		dbReservation, err = tx.NetworkReservation(networkID, reservationName)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Validate the ETag
+	reservation := networkReservationToAPI(dbReservation)
+	err = util.EtagCheck(r, reservation.Writable())
+	if err != nil {
+		return response.PreconditionFailed(err)
+	}
+
+	req := api.NetworkReservationPut{}
+	err = shared.ReadToJSON(r.Body, &req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.NetworkReservationUpdateDescription(networkID, reservationName, req.Description)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func networkReservationDelete(d *Daemon, r *http.Request) response.Response {
+	name := mux.Vars(r)["name"]
+	reservationName := mux.Vars(r)["reservation"]
+
+	var inUse error
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		networkID, err := tx.NetworkID(name)
+		if err != nil {
+			return err
+		}
+
+		reservation, err := tx.NetworkReservation(networkID, reservationName)
+		if err != nil {
+			return err
+		}
+
+		// Addresses can't be released while an instance still uses them.
+		if reservation.InstanceID != 0 {
+			inUse = fmt.Errorf("The reservation is currently in use by instance %q in project %q", reservation.InstanceName, reservation.InstanceProject)
+			return inUse
+		}
+
+		return tx.NetworkReservationDelete(networkID, reservationName)
+	})
+	if inUse != nil {
+		return response.BadRequest(inUse)
+	}
+
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func networkReservationToAPI(dbReservation db.NetworkReservation) api.NetworkReservation {
+	reservation := api.NetworkReservation{
+		NetworkReservationPut: api.NetworkReservationPut{
+			Description: dbReservation.Description,
+		},
+		Name:        dbReservation.Name,
+		IPv4Address: dbReservation.IPv4Address,
+		IPv6Address: dbReservation.IPv6Address,
+		UsedBy:      []string{},
+	}
+
+	if dbReservation.InstanceID != 0 {
+		uri := fmt.Sprintf("/%s/instances/%s", version.APIVersion, dbReservation.InstanceName)
+		if dbReservation.InstanceProject != project.Default {
+			uri += fmt.Sprintf("?project=%s", dbReservation.InstanceProject)
+		}
+
+		reservation.UsedBy = append(reservation.UsedBy, uri)
+	}
+
+	return reservation
+}
+
+// networkReservationAddress returns the address to reserve for one address family. The requested
+// address must be part of the reservation ranges, otherwise the first free one is picked. Nothing is
+// reserved if the network has no address or no reservation ranges for the family.
+func networkReservationAddress(netAddress string, ranges []network.DHCPRange, requested string, used map[string]bool) (string, error) {
+	if shared.StringInSlice(netAddress, []string{"", "none"}) || len(ranges) == 0 {
+		if requested != "" {
+			return "", fmt.Errorf("Address %q isn't part of the network's reservation ranges", requested)
+		}
+
+		return "", nil
+	}
+
+	lxdIP, _, err := net.ParseCIDR(netAddress)
+	if err != nil {
+		return "", err
+	}
+
+	if requested != "" {
+		IP := net.ParseIP(requested)
+		if IP == nil {
+			return "", fmt.Errorf("Invalid address %q", requested)
+		}
+
+		if !network.IPInRanges(IP, ranges) {
+			return "", fmt.Errorf("Address %q isn't part of the network's reservation ranges", requested)
+		}
+
+		if used[IP.String()] || IP.Equal(lxdIP) {
+			return "", fmt.Errorf("Address %q is already in use", requested)
+		}
+
+		return IP.String(), nil
+	}
+
+	for _, IPRange := range ranges {
+		inc := big.NewInt(1)
+		startBig := big.NewInt(0)
+		startBig.SetBytes(IPRange.Start)
+		endBig := big.NewInt(0)
+		endBig.SetBytes(IPRange.End)
+
+		for ; startBig.Cmp(endBig) <= 0; startBig.Add(startBig, inc) {
+			IP := make(net.IP, len(IPRange.Start))
+			startBytes := startBig.Bytes()
+			copy(IP[len(IP)-len(startBytes):], startBytes)
+
+			if used[IP.String()] || IP.Equal(lxdIP) {
+				continue
+			}
+
+			return IP.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("No free address left in the network's reservation ranges")
+}
@@ -459,6 +459,15 @@ func networkDelete(d *Daemon, r *http.Request) response.Response {
 			return response.BadRequest(fmt.Errorf("The network is currently in use"))
 		}
 
+		peers, err := d.cluster.NetworkPeeredNetworks(name)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		if len(peers) > 0 {
+			return response.BadRequest(fmt.Errorf("The network is peered with other networks"))
+		}
+
 		// Notify all other nodes. If any node is down, an error will be returned.
 		notifier, err := cluster.NewNotifier(d.State(), d.endpoints.NetworkCert(), cluster.NotifyAll)
 		if err != nil {
@@ -538,6 +547,16 @@ func networkPost(d *Daemon, r *http.Request) response.Response {
 		return response.Conflict(fmt.Errorf("Network '%s' already exists", req.Name))
 	}
 
+	// Peered networks refer to each other by name in their firewall rules.
+	peers, err := d.cluster.NetworkPeeredNetworks(name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if len(peers) > 0 {
+		return response.BadRequest(fmt.Errorf("Peered networks can't be renamed"))
+	}
+
 	// Rename it
 	err = n.Rename(req.Name)
 	if err != nil {
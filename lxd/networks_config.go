@@ -27,8 +27,25 @@ var networkConfigKeys = map[string]func(value string) error{
 
 		return nil
 	},
-	"bridge.hwaddr": shared.IsAny,
-	"bridge.mtu":    shared.IsInt64,
+	"bridge.hwaddr":             shared.IsAny,
+	"bridge.mtu":                shared.IsInt64,
+	"bridge.multicast.flood":    shared.IsBool,
+	"bridge.multicast.querier":  shared.IsBool,
+	"bridge.multicast.snooping": shared.IsBool,
+	"bridge.priority_uplinks": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		for _, entry := range strings.Split(value, ",") {
+			entry = strings.TrimSpace(entry)
+			if networkValidName(entry) != nil {
+				return fmt.Errorf("Invalid interface name '%s'", entry)
+			}
+		}
+
+		return nil
+	},
 	"bridge.mode": func(value string) error {
 		return shared.IsOneOf(value, []string{"standard", "fan"})
 	},
@@ -56,6 +73,12 @@ var networkConfigKeys = map[string]func(value string) error{
 	"tunnel.TARGET.interface": networkValidName,
 	"tunnel.TARGET.ttl":       shared.IsUint8,
 
+	"bgp.peers.NAME.address":  device.NetworkValidAddress,
+	"bgp.peers.NAME.asn":      shared.IsUint32,
+	"bgp.peers.NAME.password": shared.IsAny,
+	"bgp.ipv4.nexthop":        device.NetworkValidAddressV4,
+	"bgp.ipv6.nexthop":        device.NetworkValidAddressV6,
+
 	"ipv4.address": func(value string) error {
 		if shared.IsOneOf(value, []string{"none", "auto"}) == nil {
 			return nil
@@ -73,9 +96,12 @@ var networkConfigKeys = map[string]func(value string) error{
 	"ipv4.dhcp.gateway": device.NetworkValidAddressV4,
 	"ipv4.dhcp.expiry":  shared.IsAny,
 	"ipv4.dhcp.ranges":  shared.IsAny,
+	"ipv4.dhcp.exclude": networkValidIPRangesV4,
 	"ipv4.routes":       shared.IsAny,
 	"ipv4.routing":      shared.IsBool,
 
+	"ipv4.reservations.ranges": networkValidIPRangesV4,
+
 	"ipv6.address": func(value string) error {
 		if shared.IsOneOf(value, []string{"none", "auto"}) == nil {
 			return nil
@@ -93,9 +119,12 @@ var networkConfigKeys = map[string]func(value string) error{
 	"ipv6.dhcp.expiry":   shared.IsAny,
 	"ipv6.dhcp.stateful": shared.IsBool,
 	"ipv6.dhcp.ranges":   shared.IsAny,
+	"ipv6.dhcp.exclude":  networkValidIPRangesV6,
 	"ipv6.routes":        shared.IsAny,
 	"ipv6.routing":       shared.IsBool,
 
+	"ipv6.reservations.ranges": networkValidIPRangesV6,
+
 	"dns.domain": shared.IsAny,
 	"dns.mode": func(value string) error {
 		return shared.IsOneOf(value, []string{"dynamic", "managed", "none"})
@@ -136,6 +165,16 @@ func networkValidateConfig(name string, config map[string]string) error {
 			key = fmt.Sprintf("tunnel.TARGET.%s", fields[2])
 		}
 
+		// BGP peer keys have the peer name in their name, so extract the real key
+		if strings.HasPrefix(key, "bgp.peers.") {
+			fields := strings.Split(key, ".")
+			if len(fields) != 4 {
+				return fmt.Errorf("Invalid network configuration key: %s", k)
+			}
+
+			key = fmt.Sprintf("bgp.peers.NAME.%s", fields[3])
+		}
+
 		// Then validate
 		validator, ok := networkConfigKeys[key]
 		if !ok {
@@ -191,5 +230,27 @@ func networkValidateConfig(name string, config map[string]string) error {
 		}
 	}
 
+	// The querier relies on snooping
+	if shared.IsTrue(config["bridge.multicast.querier"]) && config["bridge.multicast.snooping"] != "" && !shared.IsTrue(config["bridge.multicast.snooping"]) {
+		return fmt.Errorf("The multicast querier requires multicast snooping")
+	}
+
+	// Without snooping, nothing would forward the multicast traffic which isn't flooded
+	if config["bridge.multicast.flood"] != "" && !shared.IsTrue(config["bridge.multicast.flood"]) && config["bridge.multicast.snooping"] != "" && !shared.IsTrue(config["bridge.multicast.snooping"]) {
+		return fmt.Errorf("Disabling multicast flooding requires multicast snooping")
+	}
+
+	// BGP peers need both an address and an ASN
+	for k := range config {
+		if !strings.HasPrefix(k, "bgp.peers.") {
+			continue
+		}
+
+		peer := strings.Join(strings.Split(k, ".")[:3], ".")
+		if config[peer+".address"] == "" || config[peer+".asn"] == "" {
+			return fmt.Errorf("BGP peer %q requires both an address and an ASN", strings.Split(k, ".")[2])
+		}
+	}
+
 	return nil
 }
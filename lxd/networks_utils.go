@@ -134,6 +134,36 @@ func networkValidAddressCIDRV4(value string) error {
 	return nil
 }
 
+func networkValidIPRangesV4(value string) error {
+	ranges, err := network.ParseIPRanges(value)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range ranges {
+		if r.Start.To4() == nil {
+			return fmt.Errorf("Not an IPv4 range: %s-%s", r.Start, r.End)
+		}
+	}
+
+	return nil
+}
+
+func networkValidIPRangesV6(value string) error {
+	ranges, err := network.ParseIPRanges(value)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range ranges {
+		if r.Start.To4() != nil {
+			return fmt.Errorf("Not an IPv6 range: %s-%s", r.Start, r.End)
+		}
+	}
+
+	return nil
+}
+
 // networkUpdateForkdnsServersTask runs every 30s and refreshes the forkdns servers list.
 func networkUpdateForkdnsServersTask(s *state.State, heartbeatData *cluster.APIHeartbeat) error {
 	// Get a list of managed networks
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 
+	"github.com/lxc/lxd/lxd/bgp"
 	"github.com/lxc/lxd/lxd/config"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/shared"
@@ -67,6 +68,34 @@ func (c *Config) StorageImagesVolume() string {
 	return c.m.GetString("storage.images_volume")
 }
 
+// BGPAddress returns the address and port the BGP speaker should listen on, if any.
+func (c *Config) BGPAddress() string {
+	return c.m.GetString("core.bgp_address")
+}
+
+// BGPRouterID returns the router ID the BGP speaker should use, if set.
+func (c *Config) BGPRouterID() string {
+	return c.m.GetString("core.bgp_routerid")
+}
+
+// Maintenance returns whether this LXD node is in maintenance mode, refusing
+// to create or start instances.
+func (c *Config) Maintenance() bool {
+	return c.m.GetBool("core.maintenance")
+}
+
+// CoreDumps returns whether the core dumps of container processes should be captured into the
+// container's log directory.
+func (c *Config) CoreDumps() bool {
+	return c.m.GetBool("core.core_dumps")
+}
+
+// OrphansReportOnly returns whether the daily cleanup of orphaned artifacts should only report
+// them rather than remove them.
+func (c *Config) OrphansReportOnly() bool {
+	return c.m.GetBool("core.orphans_report_only")
+}
+
 // Dump current configuration keys and their values. Keys with values matching
 // their defaults are omitted.
 func (c *Config) Dump() map[string]interface{} {
@@ -136,6 +165,22 @@ func DebugAddress(node *db.Node) (string, error) {
 	return config.DebugAddress(), nil
 }
 
+// Maintenance is a convenience for loading the node configuration and
+// returning the value of core.maintenance.
+func Maintenance(node *db.Node) (bool, error) {
+	var config *Config
+	err := node.Transaction(func(tx *db.NodeTx) error {
+		var err error
+		config, err = ConfigLoad(tx)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return config.Maintenance(), nil
+}
+
 func (c *Config) update(values map[string]interface{}) (map[string]string, error) {
 	changed, err := c.m.Change(values)
 	if err != nil {
@@ -161,6 +206,19 @@ var ConfigSchema = config.Schema{
 	// Network address for the debug server
 	"core.debug_address": {},
 
+	// Network address and router ID for the BGP speaker
+	"core.bgp_address":  {Validator: bgp.ValidateAddress},
+	"core.bgp_routerid": {Validator: validateBGPRouterID},
+
+	// Whether to refuse creating or starting instances on this node
+	"core.maintenance": {Type: config.Bool},
+
+	// Whether to capture the core dumps of container processes
+	"core.core_dumps": {Type: config.Bool},
+
+	// Whether to only report orphaned artifacts rather than remove them
+	"core.orphans_report_only": {Type: config.Bool},
+
 	// MAAS machine this LXD instance is associated with
 	"maas.machine": {},
 
@@ -182,3 +240,16 @@ func validateClusterHTTPSAddress(value string) error {
 	}
 	return nil
 }
+
+func validateBGPRouterID(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil || ip.To4() == nil {
+		return fmt.Errorf("The BGP router ID must be an IPv4 address")
+	}
+
+	return nil
+}
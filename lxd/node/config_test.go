@@ -142,3 +142,27 @@ func TestClusterAddress(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "127.0.0.1:666", address)
 }
+
+// The core.maintenance config key is fetched from the db with a new
+// transaction.
+func TestMaintenance(t *testing.T) {
+	nodeDB, cleanup := db.NewTestNode(t)
+	defer cleanup()
+
+	maintenance, err := node.Maintenance(nodeDB)
+	require.NoError(t, err)
+	assert.False(t, maintenance)
+
+	err = nodeDB.Transaction(func(tx *db.NodeTx) error {
+		config, err := node.ConfigLoad(tx)
+		require.NoError(t, err)
+		_, err = config.Replace(map[string]interface{}{"core.maintenance": "true"})
+		require.NoError(t, err)
+		return nil
+	})
+	require.NoError(t, err)
+
+	maintenance, err = node.Maintenance(nodeDB)
+	require.NoError(t, err)
+	assert.True(t, maintenance)
+}
@@ -0,0 +1,274 @@
+// Package openapi generates OpenAPI 3 documents describing a REST API from the Go types it
+// exchanges, so that clients in other languages can be generated rather than hand-written.
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Version is the version of the OpenAPI specification the generated documents follow.
+const Version = "3.0.3"
+
+// Document is the root of an OpenAPI document.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem holds the operations available on a path, indexed by lower case HTTP method.
+type PathItem map[string]*Operation
+
+// Operation describes a single API call.
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter describes a path or query parameter.
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody describes the body of a request.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes a response to an operation.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType associates a schema to a content type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the schemas referenced from the rest of the document.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema describes a JSON value.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Generator builds an OpenAPI document, registering the schemas of the Go types it's given.
+type Generator struct {
+	doc *Document
+}
+
+// NewGenerator returns a generator for a new document with the given title and version.
+func NewGenerator(title string, version string) *Generator {
+	return &Generator{
+		doc: &Document{
+			OpenAPI:    Version,
+			Info:       Info{Title: title, Version: version},
+			Paths:      map[string]*PathItem{},
+			Components: Components{Schemas: map[string]*Schema{}},
+		},
+	}
+}
+
+// Document returns the generated document.
+func (g *Generator) Document() *Document {
+	return g.doc
+}
+
+// pathParameterRegex matches the parameters of mux path patterns, with their optional regex.
+var pathParameterRegex = regexp.MustCompile(`{([^}:]+)(:[^}]*)?}`)
+
+// AddOperation adds an operation to the document. The path may be a mux pattern, whose parameters
+// get declared as path parameters. If not nil, request and response are values of the types of the
+// request body and of the response respectively.
+func (g *Generator) AddOperation(method string, path string, operationID string, request interface{}, response *Response) {
+	op := &Operation{
+		OperationID: operationID,
+		Responses:   map[string]*Response{},
+	}
+
+	for _, match := range pathParameterRegex.FindAllStringSubmatch(path, -1) {
+		op.Parameters = append(op.Parameters, &Parameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+
+	if request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: g.Schema(request)}},
+		}
+	}
+
+	if response != nil {
+		op.Responses["200"] = response
+	} else {
+		op.Responses["200"] = &Response{Description: "Success"}
+	}
+
+	path = pathParameterRegex.ReplaceAllString(path, "{$1}")
+
+	item, ok := g.doc.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		g.doc.Paths[path] = item
+	}
+
+	(*item)[strings.ToLower(method)] = op
+}
+
+// AddResponse sets the response to the given status code on all the operations added so far.
+func (g *Generator) AddResponse(code string, response *Response) {
+	for _, item := range g.doc.Paths {
+		for _, op := range *item {
+			op.Responses[code] = response
+		}
+	}
+}
+
+// JSONResponse returns a response whose body is a value of the type of the given value.
+func (g *Generator) JSONResponse(description string, value interface{}) *Response {
+	return &Response{
+		Description: description,
+		Content:     map[string]MediaType{"application/json": {Schema: g.Schema(value)}},
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Schema returns the schema of the type of the given value. Named struct types get registered as
+// components and are returned as references.
+func (g *Generator) Schema(value interface{}) *Schema {
+	schema, ok := value.(*Schema)
+	if ok {
+		return schema
+	}
+
+	return g.schemaOf(reflect.TypeOf(value))
+}
+
+func (g *Generator) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+
+		return &Schema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+
+		ref := &Schema{Ref: "#/components/schemas/" + t.Name()}
+		_, ok := g.doc.Components.Schemas[t.Name()]
+		if !ok {
+			// Register a placeholder first in case the type refers to itself.
+			g.doc.Components.Schemas[t.Name()] = &Schema{}
+			g.doc.Components.Schemas[t.Name()] = g.structSchema(t)
+		}
+
+		return ref
+	}
+
+	// Interfaces and anything else can hold any value.
+	return &Schema{}
+}
+
+// structSchema returns the schema of a struct, flattening the embedded structs like encoding/json.
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			embedded := g.structSchema(fieldType)
+			for key, value := range embedded.Properties {
+				_, ok := schema.Properties[key]
+				if !ok {
+					schema.Properties[key] = value
+				}
+			}
+
+			continue
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = g.schemaOf(field.Type)
+	}
+
+	return schema
+}
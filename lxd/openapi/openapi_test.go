@@ -0,0 +1,59 @@
+package openapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPut struct {
+	Description string            `json:"description"`
+	Config      map[string]string `json:"config"`
+}
+
+type testObject struct {
+	testPut `yaml:",inline"`
+
+	Name      string       `json:"name"`
+	CreatedAt time.Time    `json:"created_at"`
+	Size      int64        `json:"size"`
+	Children  []testObject `json:"children"`
+	Hidden    string       `json:"-"`
+	private   string
+}
+
+func TestGeneratorSchema(t *testing.T) {
+	g := NewGenerator("test", "1.0")
+
+	schema := g.Schema(testObject{})
+	assert.Equal(t, "#/components/schemas/testObject", schema.Ref)
+
+	object := g.Document().Components.Schemas["testObject"]
+	require.NotNil(t, object)
+	assert.Equal(t, "object", object.Type)
+	assert.Len(t, object.Properties, 6)
+	assert.Equal(t, "string", object.Properties["description"].Type)
+	assert.Equal(t, "string", object.Properties["config"].AdditionalProperties.Type)
+	assert.Equal(t, "date-time", object.Properties["created_at"].Format)
+	assert.Equal(t, "int64", object.Properties["size"].Format)
+	assert.Equal(t, "#/components/schemas/testObject", object.Properties["children"].Items.Ref)
+}
+
+func TestGeneratorAddOperation(t *testing.T) {
+	g := NewGenerator("test", "1.0")
+	g.AddOperation("POST", "/1.0/objects/{name}/aliases/{alias:.*}", "objectAliasPost", testPut{}, nil)
+	g.AddResponse("default", &Response{Description: "Error"})
+
+	item := g.Document().Paths["/1.0/objects/{name}/aliases/{alias}"]
+	require.NotNil(t, item)
+
+	op := (*item)["post"]
+	require.NotNil(t, op)
+	assert.Equal(t, "objectAliasPost", op.OperationID)
+	require.Len(t, op.Parameters, 2)
+	assert.Equal(t, "alias", op.Parameters[1].Name)
+	assert.Equal(t, "#/components/schemas/testPut", op.RequestBody.Content["application/json"].Schema.Ref)
+	assert.Equal(t, "Error", op.Responses["default"].Description)
+}
@@ -72,6 +72,11 @@ func operationGet(d *Daemon, r *http.Request) response.Response {
 		address = operation.NodeAddress
 		return nil
 	})
+	if err == db.ErrNoSuchObject {
+		// The operation may have completed a while ago.
+		return operationHistoryGet(d, r, id)
+	}
+
 	if err != nil {
 		return response.SmartError(err)
 	}
@@ -138,6 +143,11 @@ func operationsGet(d *Daemon, r *http.Request) response.Response {
 	project := projectParam(r)
 	recursion := util.IsRecursionRequest(r)
 
+	// Completed operations are kept in the global database, no need to ask the other members.
+	if shared.IsTrue(r.FormValue("history")) {
+		return operationsHistoryGet(d, r, project)
+	}
+
 	localOperationURLs := func() (shared.Jmap, error) {
 		// Get all the operations
 		operations.Lock()
@@ -3,6 +3,9 @@
 package operations
 
 import (
+	"encoding/json"
+	"time"
+
 	"github.com/pkg/errors"
 
 	"github.com/lxc/lxd/lxd/db"
@@ -36,6 +39,35 @@ func removeDBOperation(op *Operation) error {
 	return err
 }
 
+func recordDBOperationHistory(op *Operation) error {
+	if op.state == nil {
+		return nil
+	}
+
+	_, body, err := op.Render()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	entry := db.OperationHistoryEntry{
+		UUID:       op.id,
+		Project:    op.project,
+		Type:       op.description,
+		Requestor:  body.Requestor,
+		FinishedAt: time.Now(),
+		Operation:  string(data),
+	}
+
+	return op.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.OperationHistoryAdd(entry)
+	})
+}
+
 func getServerName(op *Operation) (string, error) {
 	if op.state == nil {
 		return "", nil
@@ -24,6 +24,14 @@ func removeDBOperation(op *Operation) error {
 	return nil
 }
 
+func recordDBOperationHistory(op *Operation) error {
+	if op.state != nil {
+		return fmt.Errorf("recordDBOperationHistory not supported on this platform")
+	}
+
+	return nil
+}
+
 func getServerName(op *Operation) (string, error) {
 	if op.state != nil {
 		return "", fmt.Errorf("registerDBOperation not supported on this platform")
@@ -1,6 +1,7 @@
 package operations
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"sync"
@@ -8,10 +9,12 @@ import (
 
 	"github.com/pborman/uuid"
 
+	"github.com/lxc/lxd/lxd/crashlog"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/events"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/tracing"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/cancel"
@@ -93,6 +96,7 @@ type Operation struct {
 	canceler    *cancel.Canceler
 	description string
 	permission  string
+	requestor   string
 
 	// Those functions are called at various points in the Operation lifecycle
 	onRun     func(*Operation) error
@@ -102,6 +106,13 @@ type Operation struct {
 	// Channels used for error reporting and state tracking of background actions
 	chanDone chan error
 
+	// Context cancelled when the operation is cancelled or completes
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Trace span covering the run of the operation
+	span *tracing.Span
+
 	// Locking for concurent access to the Operation
 	lock sync.Mutex
 
@@ -125,6 +136,7 @@ func OperationCreate(s *state.State, project string, opClass operationClass, opT
 	op.url = fmt.Sprintf("/%s/operations/%s", version.APIVersion, op.id)
 	op.resources = opResources
 	op.chanDone = make(chan error)
+	op.ctx, op.cancel = context.WithCancel(context.Background())
 	op.state = s
 
 	if s != nil {
@@ -191,9 +203,24 @@ func (op *Operation) done() {
 	op.onCancel = nil
 	op.onConnect = nil
 	close(op.chanDone)
+	op.cancel()
+
+	if op.err != "" {
+		op.span.End(fmt.Errorf("%s", op.err))
+	} else {
+		op.span.End(nil)
+	}
 	op.lock.Unlock()
 
 	time.AfterFunc(time.Second*5, func() {
+		// Keep the final state of the operation around once it's gone from memory. This is done
+		// here rather than synchronously so that completing an operation doesn't wait on the
+		// database.
+		err := recordDBOperationHistory(op)
+		if err != nil {
+			logger.Warnf("Failed to record operation %s in history: %s", op.id, err)
+		}
+
 		operationsLock.Lock()
 		_, ok := operations[op.id]
 		if !ok {
@@ -208,7 +235,7 @@ func (op *Operation) done() {
 			return
 		}
 
-		err := removeDBOperation(op)
+		err = removeDBOperation(op)
 		if err != nil {
 			logger.Warnf("Failed to delete operation %s: %s", op.id, err)
 		}
@@ -227,9 +254,13 @@ func (op *Operation) Run() (chan error, error) {
 	op.lock.Lock()
 	op.status = api.Running
 
+	op.ctx, op.span = tracing.Start(op.ctx, op.description)
+	op.span.SetAttribute("lxd.operation", op.id)
+	op.span.SetAttribute("lxd.project", op.project)
+
 	if op.onRun != nil {
 		go func(op *Operation, chanRun chan error) {
-			err := op.onRun(op)
+			err := op.run()
 			if err != nil {
 				op.lock.Lock()
 				op.status = api.Failure
@@ -315,6 +346,9 @@ func (op *Operation) Cancel() (chan error, error) {
 	_, md, _ := op.Render()
 	op.sendEvent(md)
 
+	// Stop any work tied to the operation context (storage tools, transfers...).
+	op.cancel()
+
 	if op.canceler != nil {
 		err := op.canceler.Cancel()
 		if err != nil {
@@ -353,7 +387,7 @@ func (op *Operation) Connect(r *http.Request, w http.ResponseWriter) (chan error
 	op.lock.Lock()
 
 	go func(op *Operation, chanConnect chan error) {
-		err := op.onConnect(op, r, w)
+		err := op.connect(r, w)
 		if err != nil {
 			chanConnect <- err
 
@@ -372,6 +406,32 @@ func (op *Operation) Connect(r *http.Request, w http.ResponseWriter) (chan error
 	return chanConnect, nil
 }
 
+// run calls the onRun hook, turning a panic into an operation failure.
+func (op *Operation) run() (err error) {
+	defer func() {
+		p := recover()
+		if p != nil {
+			crashlog.Record(fmt.Sprintf("operation %s", op.id), p)
+			err = fmt.Errorf("Operation crashed: %v", p)
+		}
+	}()
+
+	return op.onRun(op)
+}
+
+// connect calls the onConnect hook, turning a panic into a connection failure.
+func (op *Operation) connect(r *http.Request, w http.ResponseWriter) (err error) {
+	defer func() {
+		p := recover()
+		if p != nil {
+			crashlog.Record(fmt.Sprintf("operation %s", op.id), p)
+			err = fmt.Errorf("Operation crashed: %v", p)
+		}
+	}()
+
+	return op.onConnect(op, r, w)
+}
+
 func (op *Operation) mayCancel() bool {
 	if op.class == OperationClassToken {
 		return true
@@ -424,6 +484,7 @@ func (op *Operation) Render() (string, *api.Operation, error) {
 		MayCancel:   op.mayCancel(),
 		Err:         op.err,
 		Location:    serverName,
+		Requestor:   op.requestor,
 	}, nil
 }
 
@@ -517,6 +578,17 @@ func (op *Operation) Metadata() map[string]interface{} {
 	return op.metadata
 }
 
+// Context returns the context associated with the operation. It is cancelled
+// when the operation is cancelled or done. A nil operation returns a
+// background context so callers don't need to special-case it.
+func (op *Operation) Context() context.Context {
+	if op == nil {
+		return context.Background()
+	}
+
+	return op.ctx
+}
+
 // URL returns the operation URL.
 func (op *Operation) URL() string {
 	return op.url
@@ -532,6 +604,22 @@ func (op *Operation) SetCanceler(canceler *cancel.Canceler) {
 	op.canceler = canceler
 }
 
+// SetRequestor sets who requested the operation.
+func (op *Operation) SetRequestor(requestor string) {
+	op.lock.Lock()
+	op.requestor = requestor
+	op.lock.Unlock()
+}
+
+// SetTraceContext makes the operation part of the trace carried by the given context, such as the
+// one of the request which created it. It must be called before the operation is run.
+func (op *Operation) SetTraceContext(ctx context.Context) {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	op.ctx = tracing.Inherit(op.ctx, ctx)
+}
+
 // Permission returns the operation permission.
 func (op *Operation) Permission() string {
 	return op.permission
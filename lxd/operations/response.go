@@ -1,6 +1,7 @@
 package operations
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -20,6 +21,24 @@ func OperationResponse(op *Operation) response.Response {
 	return &operationResponse{op}
 }
 
+// SetResponseRequestor records who requested the operation returned by an operation response. It
+// does nothing for other responses.
+func SetResponseRequestor(resp response.Response, requestor string) {
+	opResp, ok := resp.(*operationResponse)
+	if ok {
+		opResp.op.SetRequestor(requestor)
+	}
+}
+
+// SetResponseTraceContext makes the operation returned by an operation response part of the trace
+// carried by the given context. It does nothing for other responses.
+func SetResponseTraceContext(resp response.Response, ctx context.Context) {
+	opResp, ok := resp.(*operationResponse)
+	if ok {
+		opResp.op.SetTraceContext(ctx)
+	}
+}
+
 func (r *operationResponse) Render(w http.ResponseWriter) error {
 	_, err := r.op.Run()
 	if err != nil {
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// Interval at which deferred operations check whether they may start.
+const operationDeferralInterval = 30 * time.Second
+
+// operationDeferral holds when a heavy storage operation, such as a copy or a backup, may start.
+// Until then, the operation can be cancelled.
+type operationDeferral struct {
+	notBefore time.Time
+	offPeak   bool
+
+	// Running instance the operation would disrupt outside of its maintenance windows.
+	inst instance.Instance
+
+	mu        sync.Mutex
+	started   bool
+	cancelled chan struct{}
+}
+
+// newOperationDeferral returns the deferral of an operation which shouldn't start before the given
+// time, or only during the off-peak windows, or nil if it can start right away.
+func newOperationDeferral(s *state.State, notBefore time.Time, offPeak bool) (*operationDeferral, error) {
+	if notBefore.IsZero() && !offPeak {
+		return nil, nil
+	}
+
+	if offPeak {
+		windows, err := operationOffPeakWindows(s)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(windows) == 0 {
+			return nil, fmt.Errorf("No off-peak windows are configured (core.offpeak_windows)")
+		}
+	}
+
+	return &operationDeferral{
+		notBefore: notBefore,
+		offPeak:   offPeak,
+		cancelled: make(chan struct{}),
+	}, nil
+}
+
+// newInstanceMaintenanceDeferral returns the deferral of an operation disrupting the given instance
+// until its next maintenance window, or nil if it can start right away.
+func newInstanceMaintenanceDeferral(inst instance.Instance) *operationDeferral {
+	if !inst.IsRunning() || shared.InstanceInMaintenanceWindow(inst.ExpandedConfig(), time.Now()) {
+		return nil
+	}
+
+	return &operationDeferral{
+		inst:      inst,
+		cancelled: make(chan struct{}),
+	}
+}
+
+// operationOffPeakWindows returns the configured off-peak windows.
+func operationOffPeakWindows(s *state.State) ([]shared.InstanceMaintenanceWindow, error) {
+	var value string
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		config, err := cluster.ConfigLoad(tx)
+		if err != nil {
+			return err
+		}
+
+		value = config.OffPeakWindows()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shared.InstanceMaintenanceWindowsParse(value)
+}
+
+// ready returns whether the operation may start at the given time.
+func (d *operationDeferral) ready(s *state.State, t time.Time) bool {
+	if t.Before(d.notBefore) {
+		return false
+	}
+
+	if d.inst != nil && d.inst.IsRunning() && !shared.InstanceInMaintenanceWindow(d.inst.ExpandedConfig(), t) {
+		return false
+	}
+
+	if !d.offPeak {
+		return true
+	}
+
+	windows, err := operationOffPeakWindows(s)
+	if err != nil {
+		logger.Warn("Failed to load the off-peak windows", log.Ctx{"err": err})
+		return false
+	}
+
+	// The windows got removed since, don't wait forever.
+	if len(windows) == 0 {
+		return true
+	}
+
+	for _, window := range windows {
+		if window.Contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Wait blocks until the operation may start, returning an error if it got cancelled meanwhile.
+// It returns right away for operations which weren't deferred.
+func (d *operationDeferral) Wait(s *state.State, op *operations.Operation) error {
+	if d == nil {
+		return nil
+	}
+
+	metadata := map[string]interface{}{"deferred": true}
+	if !d.notBefore.IsZero() {
+		metadata["not_before"] = d.notBefore
+	}
+
+	if d.offPeak {
+		metadata["off_peak"] = true
+	}
+
+	if d.inst != nil {
+		metadata["maintenance_window"] = true
+	}
+
+	op.UpdateMetadata(metadata)
+
+	for !d.ready(s, time.Now()) {
+		select {
+		case <-d.cancelled:
+			return fmt.Errorf("The operation was cancelled before starting")
+		case <-time.After(operationDeferralInterval):
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	select {
+	case <-d.cancelled:
+		return fmt.Errorf("The operation was cancelled before starting")
+	default:
+	}
+
+	d.started = true
+	op.UpdateMetadata(map[string]interface{}{})
+
+	return nil
+}
+
+// OnCancel returns the cancel hook of the deferred operation, which only succeeds until the
+// operation starts, or nil for operations which weren't deferred.
+func (d *operationDeferral) OnCancel() func(op *operations.Operation) error {
+	if d == nil {
+		return nil
+	}
+
+	return func(op *operations.Operation) error {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		if d.started {
+			return fmt.Errorf("The operation already started")
+		}
+
+		close(d.cancelled)
+		return nil
+	}
+}
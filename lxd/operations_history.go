@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// Maximum number of completed operations returned at once.
+const operationsHistoryLimit = 1000
+
+// operationsHistoryGet returns the completed operations of the project, most recent first, filtered
+// by the type, requestor and target query parameters. The target is the URL of a resource the
+// operation acted on, such as /1.0/instances/c1. The limit and offset query parameters page
+// through them.
+func operationsHistoryGet(d *Daemon, r *http.Request, project string) response.Response {
+	if !d.userHasPermission(r, project, "view") {
+		return response.Forbidden(nil)
+	}
+
+	limit := operationsHistoryLimit
+	if r.FormValue("limit") != "" {
+		value, err := strconv.Atoi(r.FormValue("limit"))
+		if err != nil || value <= 0 {
+			return response.BadRequest(fmt.Errorf("Invalid limit %q", r.FormValue("limit")))
+		}
+
+		if value < limit {
+			limit = value
+		}
+	}
+
+	offset := 0
+	if r.FormValue("offset") != "" {
+		value, err := strconv.Atoi(r.FormValue("offset"))
+		if err != nil || value < 0 {
+			return response.BadRequest(fmt.Errorf("Invalid offset %q", r.FormValue("offset")))
+		}
+
+		offset = value
+	}
+
+	// Operations not tied to a project are visible from all of them.
+	filter := db.OperationHistoryFilter{
+		Project:   project,
+		Type:      r.FormValue("type"),
+		Requestor: r.FormValue("requestor"),
+	}
+
+	// The resources are only known once the operations are decoded, so operations filtered by
+	// target get paged through afterwards.
+	target := r.FormValue("target")
+	if target == "" {
+		filter.Limit = limit
+		filter.Offset = offset
+	}
+
+	var entries []db.OperationHistoryEntry
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		entries, err = tx.OperationsHistory(filter)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	ops := []*api.Operation{}
+	for _, entry := range entries {
+		op := api.Operation{}
+		err := json.Unmarshal([]byte(entry.Operation), &op)
+		if err != nil {
+			logger.Warn("Failed to parse operation from history", log.Ctx{"operation": entry.UUID, "err": err})
+			continue
+		}
+
+		if target != "" {
+			if !operationHasResource(op, target) {
+				continue
+			}
+
+			if offset > 0 {
+				offset--
+				continue
+			}
+
+			if len(ops) == limit {
+				break
+			}
+		}
+
+		ops = append(ops, &op)
+	}
+
+	return response.SyncResponse(true, ops)
+}
+
+// operationHistoryGet returns a completed operation from the history.
+func operationHistoryGet(d *Daemon, r *http.Request, id string) response.Response {
+	var entry db.OperationHistoryEntry
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		entry, err = tx.OperationHistoryByUUID(id)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if entry.Project != "" && !d.userHasPermission(r, entry.Project, "view") {
+		return response.Forbidden(nil)
+	}
+
+	op := api.Operation{}
+	err = json.Unmarshal([]byte(entry.Operation), &op)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, op)
+}
+
+// operationHasResource returns whether the operation acted on the resource with the given URL.
+func operationHasResource(op api.Operation, url string) bool {
+	for _, urls := range op.Resources {
+		if shared.StringInSlice(url, urls) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func operationsHistoryPruneTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			config, err := cluster.ConfigLoad(tx)
+			if err != nil {
+				return err
+			}
+
+			retention := config.OperationsRetention()
+			return tx.OperationsHistoryPrune(time.Now().Add(-retention))
+		})
+		if err != nil {
+			logger.Error("Failed to prune the operations history", log.Ctx{"err": err})
+		}
+	}
+
+	return f, task.Every(time.Hour)
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// Types of orphaned artifacts.
+const (
+	orphanTypeImage     = "image"
+	orphanTypeSnapshots = "snapshots"
+	orphanTypeVeth      = "veth"
+	orphanTypeOperation = "operation"
+)
+
+// orphansGracePeriod is how long a file must have been left untouched before it's considered
+// orphaned, so that files of images being downloaded, uploaded or unpacked are left alone.
+const orphansGracePeriod = time.Hour
+
+// orphansPruneLock serializes the cleanups started by the daily task and through the internal API,
+// which would otherwise race removing the same artifacts.
+var orphansPruneLock sync.Mutex
+
+// orphan is a leftover artifact which isn't referenced by anything anymore, typically
+// because the daemon crashed half-way through an operation.
+type orphan struct {
+	Type string `json:"type" yaml:"type"`
+	Name string `json:"name" yaml:"name"`
+}
+
+// orphansFind returns the list of orphaned artifacts found on this node.
+func orphansFind(s *state.State) ([]orphan, error) {
+	orphans := []orphan{}
+
+	images, err := orphansFindImages(s)
+	if err != nil {
+		return nil, err
+	}
+	orphans = append(orphans, images...)
+
+	insts, err := instance.LoadNodeAll(s, instancetype.Any)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to retrieve the list of instances")
+	}
+
+	snapshots, err := orphansFindSnapshots(insts)
+	if err != nil {
+		return nil, err
+	}
+	orphans = append(orphans, snapshots...)
+
+	veths, err := orphansFindVeths(s, insts)
+	if err != nil {
+		return nil, err
+	}
+	orphans = append(orphans, veths...)
+
+	ops, err := orphansFindOperations(s)
+	if err != nil {
+		return nil, err
+	}
+	orphans = append(orphans, ops...)
+
+	return orphans, nil
+}
+
+// Image files which don't match any image in the database.
+func orphansFindImages(s *state.State) ([]orphan, error) {
+	images, err := s.Cluster.ImagesGetLocal()
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to retrieve the list of images")
+	}
+
+	entries, err := ioutil.ReadDir(shared.VarPath("images"))
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to list the images directory")
+	}
+
+	// Images being downloaded only get their database entry once done.
+	imagesDownloadingLock.Lock()
+	downloading := []string{}
+	for fp := range imagesDownloading {
+		downloading = append(downloading, fp)
+	}
+	imagesDownloadingLock.Unlock()
+
+	orphans := []orphan{}
+	for _, entry := range entries {
+		fp := strings.Split(entry.Name(), ".")[0]
+		if shared.StringInSlice(fp, images) || shared.StringInSlice(fp, downloading) {
+			continue
+		}
+
+		if orphansRecentlyModified(shared.VarPath("images", entry.Name())) {
+			continue
+		}
+
+		orphans = append(orphans, orphan{Type: orphanTypeImage, Name: entry.Name()})
+	}
+
+	return orphans, nil
+}
+
+// orphansRecentlyModified returns whether anything under the given path was modified within the
+// grace period, as happens for temporary directories used while building or unpacking images.
+func orphansRecentlyModified(path string) bool {
+	recent := false
+	since := time.Now().Add(-orphansGracePeriod)
+	filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		// Files may vanish as they're being used.
+		if err != nil {
+			recent = true
+			return filepath.SkipDir
+		}
+
+		if info.ModTime().After(since) {
+			recent = true
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+
+	return recent
+}
+
+// Snapshot directories of instances which don't exist anymore.
+func orphansFindSnapshots(insts []instance.Instance) ([]orphan, error) {
+	names := []string{}
+	for _, inst := range insts {
+		names = append(names, project.Instance(inst.Project(), inst.Name()))
+	}
+
+	orphans := []orphan{}
+	for _, dir := range []string{"snapshots", "virtual-machines-snapshots"} {
+		entries, err := ioutil.ReadDir(shared.VarPath(dir))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, errors.Wrapf(err, "Unable to list the %s directory", dir)
+		}
+
+		for _, entry := range entries {
+			if !shared.StringInSlice(entry.Name(), names) {
+				orphans = append(orphans, orphan{Type: orphanTypeSnapshots, Name: filepath.Join(dir, entry.Name())})
+			}
+		}
+	}
+
+	return orphans, nil
+}
+
+// Host side veth interfaces connected to a managed bridge but not used by any instance.
+func orphansFindVeths(s *state.State, insts []instance.Instance) ([]orphan, error) {
+	networks, err := s.Cluster.Networks()
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to retrieve the list of networks")
+	}
+
+	used := []string{}
+	for _, inst := range insts {
+		for key, value := range inst.LocalConfig() {
+			if strings.HasPrefix(key, "volatile.") && strings.HasSuffix(key, ".host_name") {
+				used = append(used, value)
+			}
+		}
+	}
+
+	entries, err := ioutil.ReadDir("/sys/class/net")
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to list network interfaces")
+	}
+
+	orphans := []orphan{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, "veth") || shared.StringInSlice(name, used) {
+			continue
+		}
+
+		// Only consider interfaces attached to one of our bridges, other
+		// software creates veth pairs too.
+		master, err := os.Readlink(filepath.Join("/sys/class/net", name, "master"))
+		if err != nil || !shared.StringInSlice(filepath.Base(master), networks) {
+			continue
+		}
+
+		orphans = append(orphans, orphan{Type: orphanTypeVeth, Name: name})
+	}
+
+	return orphans, nil
+}
+
+// Operation records of this node which don't match any running operation.
+func orphansFindOperations(s *state.State) ([]orphan, error) {
+	var uuids []string
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		uuids, err = tx.OperationsUUIDs()
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to retrieve the list of operations")
+	}
+
+	operations.Lock()
+	ops := operations.Operations()
+	orphans := []orphan{}
+	for _, uuid := range uuids {
+		_, ok := ops[uuid]
+		if !ok {
+			orphans = append(orphans, orphan{Type: orphanTypeOperation, Name: uuid})
+		}
+	}
+	operations.Unlock()
+
+	return orphans, nil
+}
+
+// orphansPrune finds and removes orphaned artifacts, returning what was removed.
+// If dryRun is true, the orphans are only reported.
+func orphansPrune(s *state.State, dryRun bool) ([]orphan, error) {
+	orphansPruneLock.Lock()
+	defer orphansPruneLock.Unlock()
+
+	orphans, err := orphansFind(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return orphans, nil
+	}
+
+	pruned := []orphan{}
+	for _, o := range orphans {
+		switch o.Type {
+		case orphanTypeImage:
+			err = os.RemoveAll(shared.VarPath("images", o.Name))
+		case orphanTypeSnapshots:
+			// This only removes the directory or symlink, the storage
+			// volumes themselves are never touched.
+			err = os.RemoveAll(shared.VarPath(o.Name))
+		case orphanTypeVeth:
+			_, err = shared.RunCommand("ip", "link", "delete", "dev", o.Name)
+		case orphanTypeOperation:
+			err = s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+				return tx.OperationRemove(o.Name)
+			})
+		}
+
+		if err != nil {
+			logger.Warn("Failed to remove orphaned artifact", log.Ctx{"type": o.Type, "name": o.Name, "err": err})
+			continue
+		}
+
+		logger.Debug("Removed orphaned artifact", log.Ctx{"type": o.Type, "name": o.Name})
+		pruned = append(pruned, o)
+	}
+
+	return pruned, nil
+}
+
+func pruneOrphansTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		reportOnly := false
+		err := d.db.Transaction(func(tx *db.NodeTx) error {
+			config, err := node.ConfigLoad(tx)
+			if err != nil {
+				return err
+			}
+
+			reportOnly = config.OrphansReportOnly()
+			return nil
+		})
+		if err != nil {
+			logger.Error("Failed to load the configuration for the orphaned artifacts cleanup", log.Ctx{"err": err})
+			return
+		}
+
+		opRun := func(op *operations.Operation) error {
+			orphans, err := orphansPrune(d.State(), reportOnly)
+			if err != nil {
+				return err
+			}
+
+			if reportOnly {
+				for _, o := range orphans {
+					logger.Warn("Found orphaned artifact", log.Ctx{"type": o.Type, "name": o.Name})
+				}
+			}
+
+			return nil
+		}
+
+		op, err := operations.OperationCreate(d.State(), "", operations.OperationClassTask, db.OperationOrphansPrune, nil, nil, opRun, nil, nil)
+		if err != nil {
+			logger.Error("Failed to start orphaned artifacts cleanup operation", log.Ctx{"err": err})
+			return
+		}
+
+		logger.Info("Pruning orphaned artifacts")
+		_, err = op.Run()
+		if err != nil {
+			logger.Error("Failed to prune orphaned artifacts", log.Ctx{"err": err})
+		}
+		logger.Info("Done pruning orphaned artifacts")
+	}
+
+	// Skip the first run, leftovers from the previous daemon are already
+	// being dealt with at startup.
+	first := true
+	schedule := func() (time.Duration, error) {
+		interval := 24 * time.Hour
+
+		if first {
+			first = false
+			return interval, task.ErrSkip
+		}
+
+		return interval, nil
+	}
+
+	return f, schedule
+}
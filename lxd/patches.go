@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -26,6 +25,7 @@ import (
 	driver "github.com/lxc/lxd/lxd/storage"
 	storagePools "github.com/lxc/lxd/lxd/storage"
 	storageDrivers "github.com/lxc/lxd/lxd/storage/drivers"
+	"github.com/lxc/lxd/lxd/tools"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	log "github.com/lxc/lxd/shared/log15"
@@ -1651,7 +1651,7 @@ func upgradeFromStorageTypeZfs(name string, d *Daemon, defaultPoolName string, d
 		// Querying the size of a storage pool only makes sense when it
 		// is not a dataset.
 		if poolName == defaultPoolName {
-			output, err := shared.RunCommand("zpool", "get", "size", "-p", "-H", defaultPoolName)
+			output, err := tools.RunCommand("zpool", "get", "size", "-p", "-H", defaultPoolName)
 			if err == nil {
 				lidx := strings.LastIndex(output, "\t")
 				fidx := strings.LastIndex(output[:lidx-1], "\t")
@@ -1723,7 +1723,7 @@ func upgradeFromStorageTypeZfs(name string, d *Daemon, defaultPoolName string, d
 		ctDataset := fmt.Sprintf("%s/containers/%s", defaultPoolName, ct)
 		oldContainerMntPoint := shared.VarPath("containers", ct)
 		if shared.IsMountPoint(oldContainerMntPoint) {
-			_, err := shared.TryRunCommand("zfs", "unmount", "-f", ctDataset)
+			_, err := tools.TryRunCommand("zfs", "unmount", "-f", ctDataset)
 			if err != nil {
 				logger.Warnf("Failed to unmount ZFS filesystem via zfs unmount, trying lazy umount (MNT_DETACH)...")
 				err := storageDrivers.TryUnmount(oldContainerMntPoint, unix.MNT_DETACH)
@@ -1751,7 +1751,7 @@ func upgradeFromStorageTypeZfs(name string, d *Daemon, defaultPoolName string, d
 
 		// Set new mountpoint for the container's dataset it will be
 		// automatically mounted.
-		output, err := shared.RunCommand(
+		output, err := tools.RunCommand(
 			"zfs",
 			"set",
 			fmt.Sprintf("mountpoint=%s", newContainerMntPoint),
@@ -1871,7 +1871,7 @@ func upgradeFromStorageTypeZfs(name string, d *Daemon, defaultPoolName string, d
 		// around.
 		imageDataset := fmt.Sprintf("%s/images/%s", defaultPoolName, img)
 		if shared.PathExists(oldImageMntPoint) && shared.IsMountPoint(oldImageMntPoint) {
-			_, err := shared.TryRunCommand("zfs", "unmount", "-f", imageDataset)
+			_, err := tools.TryRunCommand("zfs", "unmount", "-f", imageDataset)
 			if err != nil {
 				logger.Warnf("Failed to unmount ZFS filesystem via zfs unmount, trying lazy umount (MNT_DETACH)...")
 				err := storageDrivers.TryUnmount(oldImageMntPoint, unix.MNT_DETACH)
@@ -1885,7 +1885,7 @@ func upgradeFromStorageTypeZfs(name string, d *Daemon, defaultPoolName string, d
 
 		// Set new mountpoint for the container's dataset it will be
 		// automatically mounted.
-		output, err := shared.RunCommand("zfs", "set", "mountpoint=none", imageDataset)
+		output, err := tools.RunCommand("zfs", "set", "mountpoint=none", imageDataset)
 		if err != nil {
 			logger.Warnf("Failed to set new ZFS mountpoint: %s", output)
 		}
@@ -2577,7 +2577,7 @@ func patchStorageZFSnoauto(name string, d *Daemon) error {
 		customDatasetPath := fmt.Sprintf("%s/custom", zpool)
 		paths := []string{}
 		for _, v := range []string{containersDatasetPath, customDatasetPath} {
-			_, err := shared.RunCommand("zfs", "get", "-H", "-p", "-o", "value", "name", v)
+			_, err := tools.RunCommand("zfs", "get", "-H", "-p", "-o", "value", "name", v)
 			if err == nil {
 				paths = append(paths, v)
 			}
@@ -2586,7 +2586,7 @@ func patchStorageZFSnoauto(name string, d *Daemon) error {
 		args := []string{"list", "-t", "filesystem", "-o", "name", "-H", "-r"}
 		args = append(args, paths...)
 
-		output, err := shared.RunCommand("zfs", args...)
+		output, err := tools.RunCommand("zfs", args...)
 		if err != nil {
 			return fmt.Errorf("Unable to list containers on zpool: %s", zpool)
 		}
@@ -2600,7 +2600,7 @@ func patchStorageZFSnoauto(name string, d *Daemon) error {
 				continue
 			}
 
-			_, err := shared.RunCommand("zfs", "set", "canmount=noauto", entry)
+			_, err := tools.RunCommand("zfs", "set", "canmount=noauto", entry)
 			if err != nil {
 				return fmt.Errorf("Unable to set canmount=noauto on: %s", entry)
 			}
@@ -3301,8 +3301,7 @@ func patchStorageApiRenameContainerSnapshotsDir(name string, d *Daemon) error {
 		} else {
 			// Check if btrfs might have been used
 			hasBtrfs := false
-			_, err = exec.LookPath("btrfs")
-			if err == nil {
+			if tools.Available("btrfs") {
 				hasBtrfs = true
 			}
 
@@ -14,6 +14,7 @@ import (
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/state"
 	storageDrivers "github.com/lxc/lxd/lxd/storage/drivers"
+	"github.com/lxc/lxd/lxd/tools"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/units"
 )
@@ -28,7 +29,7 @@ func btrfsSubVolumeCreate(subvol string) error {
 		}
 	}
 
-	_, err := shared.RunCommand(
+	_, err := tools.RunCommand(
 		"btrfs",
 		"subvolume",
 		"create",
@@ -41,7 +42,7 @@ func btrfsSubVolumeCreate(subvol string) error {
 }
 
 func btrfsSubVolumeQGroup(subvol string) (string, error) {
-	output, err := shared.RunCommand(
+	output, err := tools.RunCommand(
 		"btrfs",
 		"qgroup",
 		"show",
@@ -78,7 +79,7 @@ func btrfsSubVolumeDelete(subvol string) error {
 	// Attempt (but don't fail on) to delete any qgroup on the subvolume
 	qgroup, err := btrfsSubVolumeQGroup(subvol)
 	if err == nil {
-		shared.RunCommand(
+		tools.RunCommand(
 			"btrfs",
 			"qgroup",
 			"destroy",
@@ -87,10 +88,10 @@ func btrfsSubVolumeDelete(subvol string) error {
 	}
 
 	// Attempt to make the subvolume writable
-	shared.RunCommand("btrfs", "property", "set", subvol, "ro", "false")
+	tools.RunCommand("btrfs", "property", "set", subvol, "ro", "false")
 
 	// Delete the subvolume itself
-	_, err = shared.RunCommand(
+	_, err = tools.RunCommand(
 		"btrfs",
 		"subvolume",
 		"delete",
@@ -127,7 +128,7 @@ func btrfsSnapshot(s *state.State, source string, dest string, readonly bool) er
 	var output string
 	var err error
 	if readonly && !s.OS.RunningInUserNS {
-		output, err = shared.RunCommand(
+		output, err = tools.RunCommand(
 			"btrfs",
 			"subvolume",
 			"snapshot",
@@ -135,7 +136,7 @@ func btrfsSnapshot(s *state.State, source string, dest string, readonly bool) er
 			source,
 			dest)
 	} else {
-		output, err = shared.RunCommand(
+		output, err = tools.RunCommand(
 			"btrfs",
 			"subvolume",
 			"snapshot",
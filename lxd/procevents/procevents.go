@@ -0,0 +1,170 @@
+// Package procevents tracks the exit of processes through the kernel's process events connector,
+// which unlike wait(2) also works for processes that aren't children of LXD.
+package procevents
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// Values from linux/connector.h and linux/cn_proc.h.
+const (
+	cnIdxProc         = 1
+	cnValProc         = 1
+	procCnMcastListen = 1
+	procEventExit     = 0x80000000
+
+	// Size of struct cn_msg.
+	cnMsgSize = 20
+)
+
+var nativeEndian binary.ByteOrder = binary.LittleEndian
+
+func init() {
+	i := uint16(1)
+	if (*[2]byte)(unsafe.Pointer(&i))[0] == 0 {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+var watchesLock sync.Mutex
+var watches = map[int]func(unix.WaitStatus){}
+
+var startOnce sync.Once
+var startErr error
+
+// Watch calls f with the wait status of the given process once it exits. Only one function can
+// watch a given process, a later call replaces the earlier one.
+func Watch(pid int, f func(unix.WaitStatus)) {
+	watchesLock.Lock()
+	defer watchesLock.Unlock()
+
+	watches[pid] = f
+}
+
+// Unwatch stops watching the given process.
+func Unwatch(pid int) {
+	watchesLock.Lock()
+	defer watchesLock.Unlock()
+
+	delete(watches, pid)
+}
+
+// Start subscribes to the process events of the kernel and starts dispatching them to the
+// watchers. It's safe to call it several times.
+func Start() error {
+	startOnce.Do(func() {
+		var fd int
+		fd, startErr = listen()
+		if startErr != nil {
+			return
+		}
+
+		go receive(fd)
+	})
+
+	return startErr
+}
+
+func listen() (int, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, unix.NETLINK_CONNECTOR)
+	if err != nil {
+		return -1, fmt.Errorf("Failed to create process events socket: %v", err)
+	}
+
+	err = unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: cnIdxProc})
+	if err != nil {
+		unix.Close(fd)
+		return -1, fmt.Errorf("Failed to bind process events socket: %v", err)
+	}
+
+	// struct nlmsghdr, struct cn_msg and the operation.
+	buf := make([]byte, unix.NLMSG_HDRLEN+cnMsgSize+4)
+	nativeEndian.PutUint32(buf[0:], uint32(len(buf)))
+	nativeEndian.PutUint16(buf[4:], unix.NLMSG_DONE)
+	nativeEndian.PutUint32(buf[12:], uint32(os.Getpid()))
+
+	msg := buf[unix.NLMSG_HDRLEN:]
+	nativeEndian.PutUint32(msg[0:], cnIdxProc)
+	nativeEndian.PutUint32(msg[4:], cnValProc)
+	nativeEndian.PutUint16(msg[16:], 4)
+	nativeEndian.PutUint32(msg[cnMsgSize:], procCnMcastListen)
+
+	err = unix.Sendto(fd, buf, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK})
+	if err != nil {
+		unix.Close(fd)
+		return -1, fmt.Errorf("Failed to subscribe to process events: %v", err)
+	}
+
+	return fd, nil
+}
+
+func receive(fd int) {
+	buf := make([]byte, os.Getpagesize())
+
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+
+			// Events were dropped because we didn't keep up, any exit in there is lost.
+			if err == unix.ENOBUFS {
+				logger.Warn("Lost some process events")
+				continue
+			}
+
+			logger.Errorf("Failed to receive process events: %v", err)
+			return
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		for _, msg := range msgs {
+			handle(msg.Data)
+		}
+	}
+}
+
+// handle dispatches a struct cn_msg holding a struct proc_event.
+func handle(data []byte) {
+	// The exit event holds the pid, tgid, exit code and exit signal after the event header.
+	if len(data) < cnMsgSize+32 {
+		return
+	}
+
+	event := data[cnMsgSize:]
+	if nativeEndian.Uint32(event[0:]) != procEventExit {
+		return
+	}
+
+	pid := int(nativeEndian.Uint32(event[16:]))
+	tgid := int(nativeEndian.Uint32(event[20:]))
+	status := unix.WaitStatus(nativeEndian.Uint32(event[24:]))
+
+	// Only the exit of the whole process matters, not the one of its threads.
+	if pid != tgid {
+		return
+	}
+
+	watchesLock.Lock()
+	f, ok := watches[pid]
+	delete(watches, pid)
+	watchesLock.Unlock()
+
+	if ok {
+		go f(status)
+	}
+}
@@ -0,0 +1,57 @@
+package procevents
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+// Build a struct cn_msg holding an exit event.
+func exitEvent(pid int, tgid int, status uint32) []byte {
+	data := make([]byte, cnMsgSize+32)
+	event := data[cnMsgSize:]
+	nativeEndian.PutUint32(event[0:], procEventExit)
+	nativeEndian.PutUint32(event[16:], uint32(pid))
+	nativeEndian.PutUint32(event[20:], uint32(tgid))
+	nativeEndian.PutUint32(event[24:], status)
+
+	return data
+}
+
+// The watcher of a process gets its wait status once, threads exiting are ignored.
+func TestHandle(t *testing.T) {
+	ch := make(chan unix.WaitStatus, 2)
+	Watch(1234, func(status unix.WaitStatus) { ch <- status })
+
+	handle(exitEvent(1235, 1234, 0))
+	handle(exitEvent(1234, 1234, uint32(unix.SIGSEGV)|0x80))
+	handle(exitEvent(1234, 1234, 0))
+
+	select {
+	case status := <-ch:
+		assert.True(t, status.Signaled())
+		assert.Equal(t, unix.SIGSEGV, status.Signal())
+		assert.True(t, status.CoreDump())
+	case <-time.After(time.Second):
+		t.Fatal("Exit wasn't dispatched")
+	}
+
+	select {
+	case <-ch:
+		t.Fatal("Exit was dispatched more than once")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// Unwatched processes are ignored.
+func TestUnwatch(t *testing.T) {
+	called := false
+	Watch(4321, func(status unix.WaitStatus) { called = true })
+	Unwatch(4321)
+
+	handle(exitEvent(4321, 4321, 0))
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, called)
+}
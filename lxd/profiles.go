@@ -17,7 +17,6 @@ import (
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
-	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
@@ -50,14 +49,12 @@ func profilesGet(d *Daemon, r *http.Request) response.Response {
 
 	var result interface{}
 	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
-		hasProfiles, err := tx.ProjectHasProfiles(projectName)
+		profilesProject, err := tx.ProjectProfilesProject(projectName)
 		if err != nil {
 			return errors.Wrap(err, "Check project features")
 		}
 
-		if !hasProfiles {
-			projectName = project.Default
-		}
+		projectName = profilesProject
 
 		filter := db.ProfileFilter{
 			Project: projectName,
@@ -70,6 +67,7 @@ func profilesGet(d *Daemon, r *http.Request) response.Response {
 			apiProfiles := make([]*api.Profile, len(profiles))
 			for i, profile := range profiles {
 				apiProfiles[i] = db.ProfileToAPI(&profile)
+				deviceConfig.HideSecrets(apiProfiles[i].Devices)
 			}
 
 			result = apiProfiles
@@ -118,14 +116,12 @@ func profilesPost(d *Daemon, r *http.Request) response.Response {
 
 	// Update DB entry
 	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
-		hasProfiles, err := tx.ProjectHasProfiles(projectName)
+		profilesProject, err := tx.ProjectProfilesProject(projectName)
 		if err != nil {
 			return errors.Wrap(err, "Check project features")
 		}
 
-		if !hasProfiles {
-			projectName = project.Default
-		}
+		projectName = profilesProject
 
 		current, _ := tx.ProfileGet(projectName, req.Name)
 		if current != nil {
@@ -157,14 +153,12 @@ func profileGet(d *Daemon, r *http.Request) response.Response {
 	var resp *api.Profile
 
 	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
-		hasProfiles, err := tx.ProjectHasProfiles(projectName)
+		profilesProject, err := tx.ProjectProfilesProject(projectName)
 		if err != nil {
 			return errors.Wrap(err, "Check project features")
 		}
 
-		if !hasProfiles {
-			projectName = project.Default
-		}
+		projectName = profilesProject
 
 		profile, err := tx.ProfileGet(projectName, name)
 		if err != nil {
@@ -189,6 +183,11 @@ func profileGet(d *Daemon, r *http.Request) response.Response {
 	}
 
 	etag := []interface{}{resp.Config, resp.Description, resp.Devices}
+
+	// Obfuscate the secrets of a copy of the devices, leaving the ETag alone.
+	resp.Devices = deviceConfig.NewDevices(resp.Devices).CloneNative()
+	deviceConfig.HideSecrets(resp.Devices)
+
 	return response.SyncResponseETag(true, resp, etag)
 }
 
@@ -217,14 +216,12 @@ func profilePut(d *Daemon, r *http.Request) response.Response {
 	var profile *api.Profile
 
 	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
-		hasProfiles, err := tx.ProjectHasProfiles(projectName)
+		profilesProject, err := tx.ProjectProfilesProject(projectName)
 		if err != nil {
 			return errors.Wrap(err, "Check project features")
 		}
 
-		if !hasProfiles {
-			projectName = project.Default
-		}
+		projectName = profilesProject
 
 		current, err := tx.ProfileGet(projectName, name)
 		if err != nil {
@@ -252,6 +249,8 @@ func profilePut(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
+	deviceConfig.KeepSecrets(req.Devices, profile.Devices)
+
 	err = doProfileUpdate(d, projectName, name, id, profile, req)
 
 	if err == nil && !isClusterNotification(r) {
@@ -283,14 +282,12 @@ func profilePatch(d *Daemon, r *http.Request) response.Response {
 	var profile *api.Profile
 
 	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
-		hasProfiles, err := tx.ProjectHasProfiles(projectName)
+		profilesProject, err := tx.ProjectProfilesProject(projectName)
 		if err != nil {
 			return errors.Wrap(err, "Check project features")
 		}
 
-		if !hasProfiles {
-			projectName = project.Default
-		}
+		projectName = profilesProject
 
 		current, err := tx.ProfileGet(projectName, name)
 		if err != nil {
@@ -331,6 +328,8 @@ func profilePatch(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
+	deviceConfig.KeepSecrets(req.Devices, profile.Devices)
+
 	// Get Description
 	_, err = reqRaw.GetString("description")
 	if err != nil {
@@ -392,14 +391,12 @@ func profilePost(d *Daemon, r *http.Request) response.Response {
 	}
 
 	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
-		hasProfiles, err := tx.ProjectHasProfiles(projectName)
+		profilesProject, err := tx.ProjectProfilesProject(projectName)
 		if err != nil {
 			return errors.Wrap(err, "Check project features")
 		}
 
-		if !hasProfiles {
-			projectName = project.Default
-		}
+		projectName = profilesProject
 
 		// Check that the name isn't already in use
 		_, err = tx.ProfileGet(projectName, req.Name)
@@ -426,14 +423,12 @@ func profileDelete(d *Daemon, r *http.Request) response.Response {
 	}
 
 	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
-		hasProfiles, err := tx.ProjectHasProfiles(projectName)
+		profilesProject, err := tx.ProjectProfilesProject(projectName)
 		if err != nil {
 			return errors.Wrap(err, "Check project features")
 		}
 
-		if !hasProfiles {
-			projectName = project.Default
-		}
+		projectName = profilesProject
 
 		profile, err := tx.ProfileGet(projectName, name)
 		if err != nil {
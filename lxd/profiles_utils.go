@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/db/query"
@@ -269,3 +270,44 @@ func getProfileContainersInfo(cluster *db.Cluster, project, profile string) ([]d
 
 	return containers, nil
 }
+
+// Check that the given profiles don't define different values for the same
+// config keys or devices, unless the instance overrides them. The check only
+// applies to projects blocking such conflicts, the last profile winning
+// otherwise so that profiles can be layered.
+func profilesValidateConflicts(cluster *db.Cluster, project string, profileNames []string, config map[string]string, devices map[string]map[string]string) error {
+	var projectConfig map[string]string
+	err := cluster.Transaction(func(tx *db.ClusterTx) error {
+		p, err := tx.ProjectGet(project)
+		if err != nil {
+			return err
+		}
+
+		projectConfig = p.Config
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Failed to load project %q", project)
+	}
+
+	if projectConfig["profiles.conflicts"] != "block" {
+		return nil
+	}
+
+	profiles := make([]api.Profile, len(profileNames))
+	for i, name := range profileNames {
+		_, profile, err := cluster.ProfileGet(project, name)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to load profile %q", name)
+		}
+
+		profiles[i] = *profile
+	}
+
+	conflicts := db.ProfilesConflicts(config, deviceConfig.NewDevices(devices), profiles)
+	if len(conflicts) > 0 {
+		return fmt.Errorf("Conflicting profiles: %s", strings.Join(conflicts, "; "))
+	}
+
+	return nil
+}
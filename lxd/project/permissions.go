@@ -37,17 +37,20 @@ func AllowInstanceCreation(tx *db.ClusterTx, projectName string, req api.Instanc
 		return fmt.Errorf("Unexpected instance type '%s'", instanceType)
 	}
 
-	err = checkInstanceCountLimit(project, len(instances), instanceType)
-	if err != nil {
-		return err
-	}
-
-	// Add the instance being created.
+	// Add the instance being created, along with the warm pool members yet
+	// to be created.
 	instances = append(instances, db.Instance{
 		Name:     req.Name,
+		Type:     instanceType,
 		Profiles: req.Profiles,
 		Config:   req.Config,
 	})
+	instances = addWarmPoolMembers(instances)
+
+	err = checkInstanceCountLimit(project, countInstances(instances, instanceType)-1, instanceType)
+	if err != nil {
+		return err
+	}
 
 	// Special case restriction checks on volatile.* keys.
 	err = checkRestrictionsOnVolatileConfig(
@@ -64,6 +67,70 @@ func AllowInstanceCreation(tx *db.ClusterTx, projectName string, req api.Instanc
 	return nil
 }
 
+// AllowedClusterGroups returns the cluster groups that the instance described
+// by the given request can be placed on, according to the
+// restricted.cluster.groups config key of its project and to the
+// cluster.groups config key of the instance and its profiles. A nil slice
+// means that placement is not restricted.
+func AllowedClusterGroups(tx *db.ClusterTx, projectName string, req api.InstancesPost) ([]string, error) {
+	project, profiles, _, err := fetchProject(tx, projectName, false)
+	if err != nil {
+		return nil, err
+	}
+
+	names := req.Profiles
+	if names == nil {
+		names, err = DefaultProfiles(tx, projectName, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	profilesByName := map[string]db.Profile{}
+	for _, profile := range profiles {
+		profilesByName[profile.Name] = profile
+	}
+
+	apiProfiles := []api.Profile{}
+	for _, name := range names {
+		profile, ok := profilesByName[name]
+		if !ok {
+			continue
+		}
+
+		apiProfiles = append(apiProfiles, *db.ProfileToAPI(&profile))
+	}
+
+	config := db.ProfilesExpandConfig(req.Config, apiProfiles)
+	requested := splitRestrictionList(config["cluster.groups"])
+
+	var allowed []string
+	if shared.IsTrue(project.Config["restricted"]) {
+		allowed = splitRestrictionList(project.Config["restricted.cluster.groups"])
+	}
+
+	if requested == nil {
+		return allowed, nil
+	}
+
+	if allowed == nil {
+		return requested, nil
+	}
+
+	groups := []string{}
+	for _, group := range requested {
+		if shared.StringInSlice(group, allowed) {
+			groups = append(groups, group)
+		}
+	}
+
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("None of the cluster groups %q is allowed in project %q", requested, projectName)
+	}
+
+	return groups, nil
+}
+
 // Check that we have not reached the maximum number of instances for
 // this type.
 func checkInstanceCountLimit(project *api.Project, instanceCount int, instanceType instancetype.Type) error {
@@ -94,6 +161,18 @@ func checkInstanceCountLimit(project *api.Project, instanceCount int, instanceTy
 	return nil
 }
 
+// Return the number of instances of the given type.
+func countInstances(instances []db.Instance, instanceType instancetype.Type) int {
+	count := 0
+	for _, instance := range instances {
+		if instance.Type == instanceType {
+			count++
+		}
+	}
+
+	return count
+}
+
 // Check restrictions on setting volatile.* keys.
 func checkRestrictionsOnVolatileConfig(project *api.Project, instanceType instancetype.Type, instanceName string, config, currentConfig map[string]string) error {
 	if project.Config["restrict"] == "false" {
@@ -153,7 +232,11 @@ func checkRestrictionsAndAggregateLimits(tx *db.ClusterTx, project *api.Project,
 		}
 	}
 
-	if len(aggregateKeys) == 0 && !isRestricted {
+	// The allowed networks and pools apply whether the project is
+	// restricted or not.
+	hasAllowedLists := project.Config["restricted.networks"] != "" || project.Config["restricted.pools"] != ""
+
+	if len(aggregateKeys) == 0 && !isRestricted && !hasAllowedLists {
 		return nil
 	}
 
@@ -164,6 +247,11 @@ func checkRestrictionsAndAggregateLimits(tx *db.ClusterTx, project *api.Project,
 		return err
 	}
 
+	err = checkAllowedNetworksAndPools(project, instances, profiles)
+	if err != nil {
+		return err
+	}
+
 	if isRestricted {
 		err = checkRestrictions(project, instances, profiles)
 		if err != nil {
@@ -200,6 +288,64 @@ func checkAggregateLimits(project *api.Project, instances []db.Instance, aggrega
 	return nil
 }
 
+// Check that the given instances and profiles only use the networks and
+// storage pools allowed by the restricted.networks and restricted.pools config
+// keys of the project.
+func checkAllowedNetworksAndPools(project *api.Project, instances []db.Instance, profiles []db.Profile) error {
+	allowedNetworks := splitRestrictionList(project.Config["restricted.networks"])
+	allowedPools := splitRestrictionList(project.Config["restricted.pools"])
+
+	if allowedNetworks == nil && allowedPools == nil {
+		return nil
+	}
+
+	checkDevices := func(entityType, entityName string, devices map[string]map[string]string) error {
+		for name, device := range devices {
+			var err error
+			switch device["type"] {
+			case "nic":
+				network := device["network"]
+				if network == "" {
+					network = device["parent"]
+				}
+
+				if allowedNetworks != nil && network != "" && !shared.StringInSlice(network, allowedNetworks) {
+					err = fmt.Errorf("Network %q isn't allowed in this project", network)
+				}
+			case "disk":
+				if allowedPools != nil && device["pool"] != "" && !shared.StringInSlice(device["pool"], allowedPools) {
+					err = fmt.Errorf("Storage pool %q isn't allowed in this project", device["pool"])
+				}
+			}
+
+			if err != nil {
+				return errors.Wrapf(
+					err,
+					"Invalid device %q on %s %q of project %q",
+					name, entityType, entityName, project.Name)
+			}
+		}
+
+		return nil
+	}
+
+	for _, instance := range instances {
+		err := checkDevices("instance", instance.Name, instance.Devices)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, profile := range profiles {
+		err := checkDevices("profile", profile.Name, profile.Devices)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Check that the project's restrictions are not violated across the given
 // instances and profiles.
 func checkRestrictions(project *api.Project, instances []db.Instance, profiles []db.Profile) error {
@@ -287,6 +433,14 @@ func checkRestrictions(project *api.Project, instances []db.Instance, profiles [
 					return fmt.Errorf("GPU devices are forbidden")
 				}
 
+				return nil
+			}
+		case "restricted.devices.plugin":
+			devicesChecks["plugin"] = func(device map[string]string) error {
+				if restrictionValue != "allow" {
+					return fmt.Errorf("Plugin devices are forbidden")
+				}
+
 				return nil
 			}
 		case "restricted.devices.usb":
@@ -434,6 +588,7 @@ var AllRestrictions = []string{
 	"restricted.devices.infiniband",
 	"restricted.devices.gpu",
 	"restricted.devices.usb",
+	"restricted.devices.plugin",
 	"restricted.devices.nic",
 	"restricted.devices.disk",
 }
@@ -449,13 +604,34 @@ var defaultRestrictionsValues = map[string]string{
 	"restricted.devices.infiniband":        "block",
 	"restricted.devices.gpu":               "block",
 	"restricted.devices.usb":               "block",
+	"restricted.devices.plugin":            "block",
 	"restricted.devices.nic":               "managed",
 	"restricted.devices.disk":              "managed",
 }
 
+// Split a comma separated list of allowed names. Returns nil if the value is
+// empty, meaning that anything is allowed.
+func splitRestrictionList(value string) []string {
+	if value == "" {
+		return nil
+	}
+
+	names := []string{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	return names
+}
+
 // Return true if a low-level container option is forbidden.
 func isContainerLowLevelOptionForbidden(key string) bool {
-	if strings.HasPrefix(key, "security.syscalls") {
+	if strings.HasPrefix(key, "security.syscalls") || strings.HasPrefix(key, "linux.namespaces.") {
 		return true
 	}
 
@@ -466,9 +642,12 @@ func isContainerLowLevelOptionForbidden(key string) bool {
 		"raw.idmap",
 		"raw.lxc",
 		"raw.seccomp",
+		"security.capabilities.keep",
 		"security.devlxd.images",
 		"security.idmap.base",
 		"security.idmap.size",
+		"security.proc",
+		"security.sys",
 	}) {
 		return true
 	}
@@ -506,8 +685,22 @@ func AllowInstanceUpdate(tx *db.ClusterTx, projectName, instanceName string, req
 		if instance.Name != instanceName {
 			continue
 		}
+
+		// Changing an autoscaled limit drops the value the autoscaler
+		// put in effect, so the new limit is the one to count.
+		config := map[string]string{}
+		for key, value := range req.Config {
+			config[key] = value
+		}
+
+		for key, effectiveKey := range aggregateLimitEffectiveKeys {
+			if req.Config[key] != currentConfig[key] {
+				delete(config, effectiveKey)
+			}
+		}
+
 		instances[i].Profiles = req.Profiles
-		instances[i].Config = req.Config
+		instances[i].Config = config
 		instances[i].Devices = req.Devices
 		updatedInstance = &instances[i]
 	}
@@ -520,6 +713,17 @@ func AllowInstanceUpdate(tx *db.ClusterTx, projectName, instanceName string, req
 		return err
 	}
 
+	// Growing the warm pool creates instances.
+	if req.Config["warm_pool.size"] != currentConfig["warm_pool.size"] {
+		instanceType := updatedInstance.Type
+		instances = addWarmPoolMembers(instances)
+
+		err = checkInstanceCountLimit(project, countInstances(instances, instanceType)-1, instanceType)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = checkRestrictionsAndAggregateLimits(tx, project, instances, profiles)
 	if err != nil {
 		return err
@@ -579,6 +783,10 @@ func AllowProjectUpdate(tx *db.ClusterTx, projectName string, config map[string]
 			}
 
 			err := checkRestrictions(project, instances, profiles)
+			if err == nil {
+				err = checkAllowedNetworksAndPools(project, instances, profiles)
+			}
+
 			if err != nil {
 				return errors.Wrapf(err, "Conflict detected when changing %q in project %q", key, projectName)
 			}
@@ -685,6 +893,9 @@ func projectHasLimitsOrRestrictions(project *api.Project) bool {
 			return true
 		}
 
+		if (k == "restricted.networks" || k == "restricted.pools") && v != "" {
+			return true
+		}
 	}
 	return false
 }
@@ -707,11 +918,10 @@ func fetchProject(tx *db.ClusterTx, projectName string, skipIfNoLimits bool) (*a
 
 	// If the project has the profiles feature enabled, we use its own
 	// profiles to expand the instances configs, otherwise we use the
-	// profiles from the default project.
-	if projectName == Default || shared.IsTrue(project.Config["features.profiles"]) {
-		profilesFilter.Project = projectName
-	} else {
-		profilesFilter.Project = Default
+	// profiles from its parent or the default project.
+	profilesFilter.Project, err = tx.ProjectProfilesProject(projectName)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "Check project features")
 	}
 
 	profiles, err := tx.ProfileList(profilesFilter)
@@ -729,6 +939,46 @@ func fetchProject(tx *db.ClusterTx, projectName string, skipIfNoLimits bool) (*a
 
 // Expand the configuration and devices of the given instances, taking the give
 // project profiles into account.
+// Add copies of the instances having a warm pool for the members of the pool
+// which don't exist yet, so that they count against the limits.
+func addWarmPoolMembers(instances []db.Instance) []db.Instance {
+	members := map[string]int{}
+	for _, instance := range instances {
+		template := instance.Config["volatile.warm_pool.template"]
+		if template != "" {
+			members[template]++
+		}
+	}
+
+	expandedInstances := instances
+	for _, instance := range instances {
+		if instance.Config["volatile.warm_pool.template"] != "" {
+			continue
+		}
+
+		size, err := strconv.Atoi(instance.Config["warm_pool.size"])
+		if err != nil {
+			continue
+		}
+
+		for i := members[instance.Name]; i < size; i++ {
+			member := instance
+			member.Name = fmt.Sprintf("%s-warm-%d", instance.Name, i)
+			member.Config = map[string]string{}
+			for key, value := range instance.Config {
+				if !strings.HasPrefix(key, "warm_pool.") {
+					member.Config[key] = value
+				}
+			}
+			member.Config["volatile.warm_pool.template"] = instance.Name
+
+			expandedInstances = append(expandedInstances, member)
+		}
+	}
+
+	return expandedInstances
+}
+
 func expandInstancesConfigAndDevices(instances []db.Instance, profiles []db.Profile) []db.Instance {
 	expandedInstances := make([]db.Instance, len(instances))
 
@@ -749,7 +999,7 @@ func expandInstancesConfigAndDevices(instances []db.Instance, profiles []db.Prof
 		expandedInstances[i] = instance
 		expandedInstances[i].Config = db.ProfilesExpandConfig(instance.Config, profiles)
 		expandedInstances[i].Devices = db.ProfilesExpandDevices(
-			deviceconfig.NewDevices(instance.Devices), profiles).CloneNative()
+			deviceconfig.NewDevices(instance.Devices), profiles, instance.Config).CloneNative()
 	}
 
 	return expandedInstances
@@ -785,8 +1035,13 @@ func getInstanceLimits(instance db.Instance, keys []string) (map[string]int64, e
 	limits := map[string]int64{}
 
 	for _, key := range keys {
-		value, ok := instance.Config[key]
-		if !ok || value == "" {
+		value := instance.Config[key]
+		effectiveKey, ok := aggregateLimitEffectiveKeys[key]
+		if ok && instance.Config[effectiveKey] != "" {
+			value = instance.Config[effectiveKey]
+		}
+
+		if value == "" {
 			return nil, fmt.Errorf(
 				"Instance %s in project %s has no '%s' config, either directly or via a profile",
 				instance.Name, instance.Project, key)
@@ -806,6 +1061,13 @@ func getInstanceLimits(instance db.Instance, keys []string) (map[string]int64, e
 	return limits, nil
 }
 
+// Limits which the autoscaler may raise or lower on a running instance, and
+// the volatile key holding the value currently in effect.
+var aggregateLimitEffectiveKeys = map[string]string{
+	"limits.cpu":    "volatile.autoscale.cpu",
+	"limits.memory": "volatile.autoscale.memory",
+}
+
 var aggregateLimitConfigValueParsers = map[string]func(string) (int64, error){
 	"limits.memory": func(value string) (int64, error) {
 		if strings.HasSuffix(value, "%") {
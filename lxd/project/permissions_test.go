@@ -124,3 +124,178 @@ func TestAllowInstanceCreation_DifferentType(t *testing.T) {
 	err = project.AllowInstanceCreation(tx, "p1", req)
 	assert.NoError(t, err)
 }
+
+// If the project restricts the networks which can be used, instances can't be
+// connected to other networks.
+func TestAllowInstanceCreation_RestrictedNetworks(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	_, err := tx.ProjectCreate(api.ProjectsPost{
+		Name: "p1",
+		ProjectPut: api.ProjectPut{
+			Config: map[string]string{
+				"restricted":          "true",
+				"restricted.networks": "lxdbr0, lxdbr1",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	req := api.InstancesPost{
+		Name: "c1",
+		Type: api.InstanceTypeContainer,
+		InstancePut: api.InstancePut{
+			Devices: map[string]map[string]string{
+				"eth0": {"type": "nic", "nictype": "bridged", "network": "lxdbr1"},
+			},
+		},
+	}
+
+	err = project.AllowInstanceCreation(tx, "p1", req)
+	assert.NoError(t, err)
+
+	req.Devices["eth0"]["network"] = "other"
+
+	err = project.AllowInstanceCreation(tx, "p1", req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `Network "other" isn't allowed in this project`)
+}
+
+// The members of warm pools count against the instance limits, including the
+// ones yet to be created.
+func TestAllowInstanceCreation_WarmPool(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	_, err := tx.ProjectCreate(api.ProjectsPost{
+		Name: "p1",
+		ProjectPut: api.ProjectPut{
+			Config: map[string]string{
+				"limits.containers": "3",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = tx.InstanceCreate(db.Instance{
+		Project:      "p1",
+		Name:         "c1",
+		Type:         instancetype.Container,
+		Architecture: 1,
+		Node:         "none",
+	})
+	require.NoError(t, err)
+
+	req := api.InstancesPost{
+		InstancePut: api.InstancePut{
+			Config: map[string]string{"warm_pool.size": "2"},
+		},
+		Name: "c2",
+		Type: api.InstanceTypeContainer,
+	}
+
+	err = project.AllowInstanceCreation(tx, "p1", req)
+	assert.EqualError(t, err, "Reached maximum number of instances of type container in project p1")
+
+	req.Config["warm_pool.size"] = "1"
+	err = project.AllowInstanceCreation(tx, "p1", req)
+	assert.NoError(t, err)
+}
+
+// The limits put in effect by the autoscaler count against the aggregate
+// limits, unless the update changes the limit itself.
+func TestAllowInstanceUpdate_Autoscaled(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	_, err := tx.ProjectCreate(api.ProjectsPost{
+		Name: "p1",
+		ProjectPut: api.ProjectPut{
+			Config: map[string]string{
+				"limits.memory": "3GB",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	config := map[string]string{
+		"limits.memory":             "1GB",
+		"volatile.autoscale.memory": "2GB",
+	}
+
+	_, err = tx.InstanceCreate(db.Instance{
+		Project:      "p1",
+		Name:         "c1",
+		Type:         instancetype.Container,
+		Architecture: 1,
+		Node:         "none",
+		Config:       config,
+	})
+	require.NoError(t, err)
+
+	req := api.InstancesPost{
+		InstancePut: api.InstancePut{
+			Config: map[string]string{"limits.memory": "2GB"},
+		},
+		Name: "c2",
+		Type: api.InstanceTypeContainer,
+	}
+
+	err = project.AllowInstanceCreation(tx, "p1", req)
+	assert.EqualError(t, err, `Reached maximum aggregate value 3GB for "limits.memory" in project p1`)
+
+	req.Config["limits.memory"] = "1GB"
+	err = project.AllowInstanceCreation(tx, "p1", req)
+	assert.NoError(t, err)
+
+	update := api.InstancePut{
+		Config: map[string]string{
+			"limits.memory":             "4GB",
+			"volatile.autoscale.memory": "2GB",
+		},
+	}
+
+	err = project.AllowInstanceUpdate(tx, "p1", "c1", update, config)
+	assert.EqualError(t, err, `Reached maximum aggregate value 3GB for "limits.memory" in project p1`)
+
+	update.Config["limits.memory"] = "3GB"
+	err = project.AllowInstanceUpdate(tx, "p1", "c1", update, config)
+	assert.NoError(t, err)
+}
+
+// The storage pools which can be used are restricted even if the project isn't
+// otherwise restricted.
+func TestAllowInstanceCreation_RestrictedPoolsUnrestrictedProject(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	_, err := tx.ProjectCreate(api.ProjectsPost{
+		Name: "p1",
+		ProjectPut: api.ProjectPut{
+			Config: map[string]string{
+				"restricted.pools": "pool1",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	req := api.InstancesPost{
+		Name: "c1",
+		Type: api.InstanceTypeContainer,
+		InstancePut: api.InstancePut{
+			Devices: map[string]map[string]string{
+				"root": {"type": "disk", "path": "/", "pool": "pool1"},
+			},
+		},
+	}
+
+	err = project.AllowInstanceCreation(tx, "p1", req)
+	assert.NoError(t, err)
+
+	req.Devices["root"]["pool"] = "pool2"
+
+	err = project.AllowInstanceCreation(tx, "p1", req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `Storage pool "pool2" isn't allowed in this project`)
+}
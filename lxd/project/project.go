@@ -89,3 +89,69 @@ func StorageVolumeProject(c *db.Cluster, projectName string, volumeType int) (st
 
 	return Default, nil
 }
+
+// DefaultProfiles returns the profiles to apply to new instances of the given project which don't specify any.
+// These are the ones listed in the "defaults.profiles" config key of the storage pool the instance is put on,
+// if any, then the ones of the project, and otherwise just the "default" profile.
+func DefaultProfiles(tx *db.ClusterTx, projectName string, poolConfig map[string]string) ([]string, error) {
+	if poolConfig["defaults.profiles"] != "" {
+		return defaultProfilesList(poolConfig["defaults.profiles"]), nil
+	}
+
+	project, err := tx.ProjectGet(projectName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to load project %q", projectName)
+	}
+
+	value := project.Config["defaults.profiles"]
+	if value == "" {
+		return []string{"default"}, nil
+	}
+
+	return defaultProfilesList(value), nil
+}
+
+// ValidateDefaultProfiles checks that the profiles listed in the "defaults.profiles" key of the given project
+// config exist. Projects without the profiles feature use the ones of their parent, or of the
+// default project.
+func ValidateDefaultProfiles(tx *db.ClusterTx, projectName string, config map[string]string) error {
+	profileProject := projectName
+	if !shared.IsTrue(config["features.profiles"]) {
+		profileProject = Default
+		if config["parent"] != "" {
+			var err error
+			profileProject, err = tx.ProjectProfilesProject(config["parent"])
+			if err != nil {
+				return errors.Wrapf(err, "Failed to resolve the profiles of parent project %q", config["parent"])
+			}
+		}
+	}
+
+	for _, name := range defaultProfilesList(config["defaults.profiles"]) {
+		exists, err := tx.ProfileExists(profileProject, name)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to check profile %q", name)
+		}
+
+		if !exists {
+			return fmt.Errorf("Default profile %q doesn't exist in project %q", name, profileProject)
+		}
+	}
+
+	return nil
+}
+
+// defaultProfilesList splits a "defaults.profiles" value into profile names.
+func defaultProfilesList(value string) []string {
+	profiles := []string{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		profiles = append(profiles, name)
+	}
+
+	return profiles
+}
@@ -2,8 +2,14 @@ package project_test
 
 import (
 	"fmt"
+	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/shared/api"
 )
 
 func ExampleInstance() {
@@ -44,3 +50,80 @@ func ExampleStorageVolume() {
 	// Output: default_test
 	// project_name_test1
 }
+
+// If no default profiles are configured, the "default" profile is used.
+func TestDefaultProfiles_NotConfigured(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	profiles, err := project.DefaultProfiles(tx, "default", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default"}, profiles)
+}
+
+// The default profiles are taken from the project configuration.
+func TestDefaultProfiles_Configured(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	_, err := tx.ProjectCreate(api.ProjectsPost{
+		Name: "p1",
+		ProjectPut: api.ProjectPut{
+			Config: map[string]string{
+				"defaults.profiles": "base, net",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	profiles, err := project.DefaultProfiles(tx, "p1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"base", "net"}, profiles)
+}
+
+// The default profiles of the storage pool take precedence over the project ones.
+func TestDefaultProfiles_Pool(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	profiles, err := project.DefaultProfiles(tx, "default", map[string]string{"defaults.profiles": "fast"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fast"}, profiles)
+}
+
+// Default profiles must exist in the project holding the profiles.
+func TestValidateDefaultProfiles(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	err := project.ValidateDefaultProfiles(tx, "default", map[string]string{"defaults.profiles": "default"})
+	assert.NoError(t, err)
+
+	err = project.ValidateDefaultProfiles(tx, "default", map[string]string{"defaults.profiles": "default, missing"})
+	assert.EqualError(t, err, `Default profile "missing" doesn't exist in project "default"`)
+}
+
+// Projects without the profiles feature resolve their default profiles through their parent.
+func TestValidateDefaultProfiles_Parent(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	_, err := tx.ProjectCreate(api.ProjectsPost{
+		Name: "parent",
+		ProjectPut: api.ProjectPut{
+			Config: map[string]string{"features.profiles": "true"},
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = tx.ProfileCreate(db.Profile{Project: "parent", Name: "fast"})
+	require.NoError(t, err)
+
+	config := map[string]string{"parent": "parent", "defaults.profiles": "fast"}
+	err = project.ValidateDefaultProfiles(tx, "child", config)
+	assert.NoError(t, err)
+
+	delete(config, "parent")
+	err = project.ValidateDefaultProfiles(tx, "child", config)
+	assert.EqualError(t, err, `Default profile "fast" doesn't exist in project "default"`)
+}
@@ -0,0 +1,571 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+)
+
+// Replications being synced, so that slow syncs don't pile up.
+var instanceReplicationsSyncing = map[int64]bool{}
+var instanceReplicationsSyncingMu sync.Mutex
+
+// As for the schedules, the replications are stored in the global database, so there's no need
+// to forward the requests below to the member running the instance.
+//
+// Replications are synced using the certificate of this server, as trusted by the target, so only
+// administrators may add or change them.
+
+func containerReplicationsGet(d *Daemon, r *http.Request) response.Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+	recursion := util.IsRecursionRequest(r)
+
+	var replications []db.InstanceReplication
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		_, err := tx.InstanceID(project, name)
+		if err != nil {
+			return err
+		}
+
+		replications, err = tx.InstanceReplications(project, name)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	resultString := []string{}
+	resultMap := []*api.InstanceReplication{}
+	for _, replication := range replications {
+		if !recursion {
+			resultString = append(resultString, fmt.Sprintf("/%s/instances/%s/replications/%s", version.APIVersion, name, replication.Name))
+		} else {
+			resultMap = append(resultMap, instanceReplicationRender(replication))
+		}
+	}
+
+	if !recursion {
+		return response.SyncResponse(true, resultString)
+	}
+
+	return response.SyncResponse(true, resultMap)
+}
+
+func containerReplicationsPost(d *Daemon, r *http.Request) response.Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	req := api.InstanceReplicationsPost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if req.Name == "" || strings.Contains(req.Name, "/") {
+		return response.BadRequest(fmt.Errorf("Invalid replication name %q", req.Name))
+	}
+
+	err = instanceReplicationValidate(&req.InstanceReplicationPut)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	exists := false
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		id, err := tx.InstanceID(project, name)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.InstanceReplicationGet(project, name, req.Name)
+		if err == nil {
+			exists = true
+			return nil
+		}
+
+		if err != db.ErrNoSuchObject {
+			return err
+		}
+
+		replication := instanceReplicationFromAPI(req.InstanceReplicationPut)
+		replication.Name = req.Name
+		_, err = tx.InstanceReplicationCreate(int(id), replication)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if exists {
+		return response.Conflict(fmt.Errorf("Replication %q already exists", req.Name))
+	}
+
+	return response.SyncResponseLocation(true, nil, fmt.Sprintf("/%s/instances/%s/replications/%s", version.APIVersion, name, req.Name))
+}
+
+func containerReplicationGet(d *Daemon, r *http.Request) response.Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+	replicationName := mux.Vars(r)["replicationName"]
+
+	var replication db.InstanceReplication
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		replication, err = tx.InstanceReplicationGet(project, name, replicationName)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	render := instanceReplicationRender(replication)
+	return response.SyncResponseETag(true, render, render.Writable())
+}
+
+func containerReplicationPut(d *Daemon, r *http.Request) response.Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+	replicationName := mux.Vars(r)["replicationName"]
+
+	req := api.InstanceReplicationPut{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = instanceReplicationValidate(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	var replication db.InstanceReplication
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		replication, err = tx.InstanceReplicationGet(project, name, replicationName)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Validate the ETag
+	current := instanceReplicationRender(replication)
+	err = util.EtagCheck(r, current.Writable())
+	if err != nil {
+		return response.PreconditionFailed(err)
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.InstanceReplicationUpdate(replication.ID, instanceReplicationFromAPI(req))
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func containerReplicationDelete(d *Daemon, r *http.Request) response.Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+	replicationName := mux.Vars(r)["replicationName"]
+
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		replication, err := tx.InstanceReplicationGet(project, name, replicationName)
+		if err != nil {
+			return err
+		}
+
+		return tx.InstanceReplicationDelete(replication.ID)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+// Promote a replica into a regular instance, which can then be started and doesn't get synced
+// anymore.
+func containerPromotePost(d *Daemon, r *http.Request) response.Response {
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to an instance on a different node
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, project, name, instanceType)
+	if err != nil {
+		return response.SmartError(err)
+	}
+	if resp != nil {
+		return resp
+	}
+
+	inst, err := instance.LoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if inst.LocalConfig()["volatile.replica.source"] == "" {
+		return response.BadRequest(fmt.Errorf("Instance %q isn't a replica", name))
+	}
+
+	err = inst.VolatileSet(map[string]string{"volatile.replica.source": ""})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func instanceReplicationRender(replication db.InstanceReplication) *api.InstanceReplication {
+	snapshots := []string{}
+	if replication.Snapshots != "" {
+		snapshots = strings.Split(replication.Snapshots, ",")
+	}
+
+	return &api.InstanceReplication{
+		InstanceReplicationPut: api.InstanceReplicationPut{
+			Description:       replication.Description,
+			Target:            replication.Target,
+			TargetCertificate: replication.TargetCertificate,
+			TargetProject:     replication.TargetProject,
+			Interval:          replication.Interval,
+			Snapshots:         snapshots,
+		},
+		Name:       replication.Name,
+		LastSyncAt: replication.LastSyncAt,
+		LastError:  replication.LastError,
+	}
+}
+
+func instanceReplicationFromAPI(replication api.InstanceReplicationPut) db.InstanceReplication {
+	return db.InstanceReplication{
+		Description:       replication.Description,
+		Target:            replication.Target,
+		TargetCertificate: replication.TargetCertificate,
+		TargetProject:     replication.TargetProject,
+		Interval:          replication.Interval,
+		Snapshots:         strings.Join(replication.Snapshots, ","),
+	}
+}
+
+// instanceReplicationValidate checks the fields of a replication, filling in the defaults.
+func instanceReplicationValidate(replication *api.InstanceReplicationPut) error {
+	if replication.Target == "" {
+		return fmt.Errorf("The address of the target server is required")
+	}
+
+	replication.Target = util.CanonicalNetworkAddress(replication.Target)
+
+	if replication.TargetCertificate == "" {
+		return fmt.Errorf("The certificate of the target server is required")
+	}
+
+	block, _ := pem.Decode([]byte(replication.TargetCertificate))
+	if block == nil {
+		return fmt.Errorf("Invalid target certificate")
+	}
+
+	if replication.TargetProject == "" {
+		replication.TargetProject = "default"
+	}
+
+	if replication.Interval < 1 {
+		return fmt.Errorf("The interval must be at least one minute")
+	}
+
+	for _, pattern := range replication.Snapshots {
+		if pattern == "" || strings.Contains(pattern, ",") {
+			return fmt.Errorf("Invalid snapshot pattern %q", pattern)
+		}
+
+		_, err := filepath.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("Invalid snapshot pattern %q: %v", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// snapshotNameMatches returns whether the name of a snapshot matches any of the given shell
+// patterns.
+func snapshotNameMatches(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		match, _ := filepath.Match(pattern, name)
+		if match {
+			return true
+		}
+	}
+
+	return false
+}
+
+// instanceReplicaSource returns the value of volatile.replica.source on the replicas of the given
+// instance, identifying this server and the instance.
+func instanceReplicaSource(d *Daemon, inst instance.Instance) string {
+	return fmt.Sprintf("%s/%s/%s", d.endpoints.NetworkCert().Fingerprint(), inst.Project(), inst.Name())
+}
+
+func instanceReplicationsTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		now := time.Now()
+
+		var replications []db.InstanceReplication
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			var err error
+			replications, err = tx.InstanceReplicationsNode()
+			return err
+		})
+		if err != nil {
+			logger.Error("Failed to load instance replications", log.Ctx{"err": err})
+			return
+		}
+
+		for _, replication := range replications {
+			if now.Before(replication.LastSyncAt.Add(time.Duration(replication.Interval) * time.Minute)) {
+				continue
+			}
+
+			err := instanceReplicationRun(d, replication)
+			if err != nil {
+				logger.Error("Failed to start instance replica sync", log.Ctx{"project": replication.Project, "instance": replication.Instance, "replication": replication.Name, "err": err})
+			}
+		}
+	}
+
+	return f, task.Every(time.Minute)
+}
+
+// instanceReplicationRun syncs the replica of a replication in a background operation, recording
+// the outcome once done.
+func instanceReplicationRun(d *Daemon, replication db.InstanceReplication) error {
+	instanceReplicationsSyncingMu.Lock()
+	if instanceReplicationsSyncing[replication.ID] {
+		instanceReplicationsSyncingMu.Unlock()
+		return nil
+	}
+	instanceReplicationsSyncing[replication.ID] = true
+	instanceReplicationsSyncingMu.Unlock()
+
+	done := func() {
+		instanceReplicationsSyncingMu.Lock()
+		delete(instanceReplicationsSyncing, replication.ID)
+		instanceReplicationsSyncingMu.Unlock()
+	}
+
+	inst, err := instance.LoadByProjectAndName(d.State(), replication.Project, replication.Instance)
+	if err != nil {
+		done()
+		return err
+	}
+
+	resources := map[string][]string{}
+	resources["instances"] = []string{inst.Name()}
+
+	run := func(op *operations.Operation) error {
+		defer done()
+
+		op.UpdateMetadata(map[string]interface{}{"replication": replication.Name})
+
+		logger.Info("Syncing instance replica", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "replication": replication.Name, "target": replication.Target})
+		syncErr := instanceReplicationSync(d, inst, replication, op)
+
+		lastError := ""
+		if syncErr != nil {
+			lastError = syncErr.Error()
+		}
+
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			return tx.InstanceReplicationSynced(replication.ID, time.Now(), lastError)
+		})
+		if err != nil {
+			logger.Error("Failed to record instance replica sync", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "replication": replication.Name, "err": err})
+		}
+
+		return syncErr
+	}
+
+	op, err := operations.OperationCreate(d.State(), inst.Project(), operations.OperationClassTask, db.OperationInstanceReplicationSync, resources, nil, run, nil, nil)
+	if err != nil {
+		done()
+		return err
+	}
+
+	_, err = op.Run()
+	if err != nil {
+		done()
+		return err
+	}
+
+	return nil
+}
+
+// instanceReplicationSync pushes the instance and its selected snapshots to the target server of a
+// replication, creating the replica or refreshing it. Instances on the target which aren't
+// replicas of this instance, including promoted replicas, are left alone.
+func instanceReplicationSync(d *Daemon, inst instance.Instance, replication db.InstanceReplication, op *operations.Operation) error {
+	// Replications added before the certificate was required must be updated first.
+	if replication.TargetCertificate == "" {
+		return fmt.Errorf("The certificate of the target server is required")
+	}
+
+	cert := d.endpoints.NetworkCert()
+	args := &lxd.ConnectionArgs{
+		TLSServerCert: replication.TargetCertificate,
+		TLSClientCert: string(cert.PublicKey()),
+		TLSClientKey:  string(cert.PrivateKey()),
+		UserAgent:     version.UserAgent,
+	}
+
+	target, err := lxd.ConnectLXD(fmt.Sprintf("https://%s", replication.Target), args)
+	if err != nil {
+		return errors.Wrap(err, "Failed to connect to the target server")
+	}
+
+	target = target.UseProject(replication.TargetProject)
+
+	names, err := target.GetInstanceNames(api.InstanceTypeAny)
+	if err != nil {
+		return errors.Wrap(err, "Failed to list the instances of the target server")
+	}
+
+	source := instanceReplicaSource(d, inst)
+	var replica *api.Instance
+	if shared.StringInSlice(inst.Name(), names) {
+		replica, _, err = target.GetInstance(inst.Name())
+		if err != nil {
+			return errors.Wrap(err, "Failed to get the replica")
+		}
+
+		if replica.Config["volatile.replica.source"] != source {
+			return fmt.Errorf("Instance %q on the target server isn't a replica of this instance or got promoted", inst.Name())
+		}
+	}
+
+	render, _, err := inst.Render()
+	if err != nil {
+		return err
+	}
+
+	put := render.(*api.Instance).Writable()
+	put.Stateful = false
+
+	config := map[string]string{}
+	for key, value := range put.Config {
+		if !strings.HasPrefix(key, "volatile.") {
+			config[key] = value
+		}
+	}
+
+	config["volatile.replica.source"] = source
+	put.Config = config
+
+	snapshots := []string{}
+	if replication.Snapshots != "" {
+		snapshots = strings.Split(replication.Snapshots, ",")
+	}
+
+	req := api.InstancesPost{
+		InstancePut: put,
+		Name:        inst.Name(),
+		Type:        api.InstanceType(inst.Type().String()),
+		Source: api.InstanceSource{
+			Type:         "migration",
+			Mode:         "push",
+			Refresh:      replica != nil,
+			InstanceOnly: len(snapshots) == 0,
+		},
+	}
+
+	info, err := target.GetConnectionInfo()
+	if err != nil {
+		return err
+	}
+
+	remoteOp, err := target.CreateInstance(req)
+	if err != nil {
+		return errors.Wrap(err, "Failed to create the replica")
+	}
+
+	secrets := map[string]string{}
+	for key, value := range remoteOp.Get().Metadata {
+		secrets[key], _ = value.(string)
+	}
+
+	ws, err := newMigrationSource(inst, false, len(snapshots) == 0)
+	if err != nil {
+		remoteOp.Cancel()
+		return err
+	}
+
+	ws.snapshots = snapshots
+
+	err = ws.ConnectContainerTarget(api.InstancePostTarget{
+		Certificate: info.Certificate,
+		Operation:   fmt.Sprintf("https://%s/%s/operations/%s", replication.Target, version.APIVersion, url.PathEscape(remoteOp.Get().ID)),
+		Websockets:  secrets,
+	})
+	if err != nil {
+		remoteOp.Cancel()
+		return err
+	}
+
+	err = ws.Do(d.State(), op)
+	if err != nil {
+		return err
+	}
+
+	err = remoteOp.Wait()
+	if err != nil {
+		return errors.Wrap(err, "Failed to sync the replica")
+	}
+
+	if replica == nil {
+		return nil
+	}
+
+	// Refreshes only transfer the data, so also update the configuration of existing replicas,
+	// keeping their own volatile keys.
+	for key, value := range replica.Config {
+		if strings.HasPrefix(key, "volatile.") && key != "volatile.replica.source" {
+			put.Config[key] = value
+		}
+	}
+
+	updateOp, err := target.UpdateInstance(inst.Name(), put, "")
+	if err != nil {
+		return errors.Wrap(err, "Failed to update the configuration of the replica")
+	}
+
+	return updateOp.Wait()
+}
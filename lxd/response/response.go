@@ -342,6 +342,24 @@ func (r *fileResponse) String() string {
 	return fmt.Sprintf("%d files", len(r.files))
 }
 
+type manualResponse struct {
+	hook func(w http.ResponseWriter) error
+}
+
+// ManualResponse returns a response whose rendering is entirely done by the given hook, for bodies
+// which don't follow the standard response format.
+func ManualResponse(hook func(w http.ResponseWriter) error) Response {
+	return &manualResponse{hook: hook}
+}
+
+func (r *manualResponse) Render(w http.ResponseWriter) error {
+	return r.hook(w)
+}
+
+func (r *manualResponse) String() string {
+	return "unknown"
+}
+
 type forwardedResponse struct {
 	client  lxd.InstanceServer
 	request *http.Request
@@ -386,9 +404,22 @@ func (r *forwardedResponse) Render(w http.ResponseWriter) error {
 		w.Header().Set(key, response.Header.Get(key))
 	}
 
+	// Announce the trailers, their values are only known once the body was read.
+	for key := range response.Trailer {
+		w.Header().Add("Trailer", key)
+	}
+
 	w.WriteHeader(response.StatusCode)
 	_, err = io.Copy(w, response.Body)
-	return err
+	if err != nil {
+		return err
+	}
+
+	for key, values := range response.Trailer {
+		w.Header()[key] = values
+	}
+
+	return nil
 }
 
 func (r *forwardedResponse) String() string {
@@ -1,6 +1,7 @@
 package rsync
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -13,6 +14,7 @@ import (
 	"github.com/pborman/uuid"
 
 	"github.com/lxc/lxd/lxd/daemon"
+	"github.com/lxc/lxd/lxd/tools"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/ioprogress"
 	"github.com/lxc/lxd/shared/logger"
@@ -20,6 +22,11 @@ import (
 
 // LocalCopy copies a directory using rsync (with the --devices option).
 func LocalCopy(source string, dest string, bwlimit string, xattrs bool) (string, error) {
+	return LocalCopyContext(context.Background(), source, dest, bwlimit, xattrs)
+}
+
+// LocalCopyContext is the same as LocalCopy but kills rsync when the context is cancelled.
+func LocalCopyContext(ctx context.Context, source string, dest string, bwlimit string, xattrs bool) (string, error) {
 	err := os.MkdirAll(dest, 0755)
 	if err != nil {
 		return "", err
@@ -56,7 +63,7 @@ func LocalCopy(source string, dest string, bwlimit string, xattrs bool) (string,
 		rsyncVerbosity,
 		shared.AddSlash(source),
 		dest)
-	msg, err := shared.RunCommand("rsync", args...)
+	msg, err := tools.Run(ctx, "rsync", args...)
 	if err != nil {
 		runError, ok := err.(shared.RunError)
 		if ok {
@@ -74,7 +81,7 @@ func LocalCopy(source string, dest string, bwlimit string, xattrs bool) (string,
 	return msg, nil
 }
 
-func sendSetup(name string, path string, bwlimit string, execPath string, features []string, rsyncArgs ...string) (*exec.Cmd, net.Conn, io.ReadCloser, error) {
+func sendSetup(ctx context.Context, name string, path string, bwlimit string, execPath string, features []string, rsyncArgs ...string) (*exec.Cmd, net.Conn, io.ReadCloser, error) {
 	/*
 	 * The way rsync works, it invokes a subprocess that does the actual
 	 * talking (given to it by a -E argument). Since there isn't an easy
@@ -141,7 +148,7 @@ func sendSetup(name string, path string, bwlimit string, execPath string, featur
 		"--bwlimit",
 		bwlimit}...)
 
-	cmd := exec.Command("rsync", args...)
+	cmd := tools.Command(ctx, "rsync", args...)
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
@@ -185,9 +192,10 @@ func sendSetup(name string, path string, bwlimit string, execPath string, featur
 }
 
 // Send sets up the sending half of an rsync, to recursively send the
-// directory pointed to by path over the websocket.
-func Send(name string, path string, conn io.ReadWriteCloser, tracker *ioprogress.ProgressTracker, features []string, bwlimit string, execPath string, rsyncArgs ...string) error {
-	cmd, netcatConn, stderr, err := sendSetup(name, path, bwlimit, execPath, features, rsyncArgs...)
+// directory pointed to by path over the websocket. The rsync process is
+// killed if the context is cancelled before the transfer completes.
+func Send(ctx context.Context, name string, path string, conn io.ReadWriteCloser, tracker *ioprogress.ProgressTracker, features []string, bwlimit string, execPath string, rsyncArgs ...string) error {
+	cmd, netcatConn, stderr, err := sendSetup(ctx, name, path, bwlimit, execPath, features, rsyncArgs...)
 	if err != nil {
 		return err
 	}
@@ -254,8 +262,9 @@ func Send(name string, path string, conn io.ReadWriteCloser, tracker *ioprogress
 
 // Recv sets up the receiving half of the websocket to rsync (the other
 // half set up by rsync.Send), putting the contents in the directory specified
-// by path.
-func Recv(path string, conn io.ReadWriteCloser, tracker *ioprogress.ProgressTracker, features []string) error {
+// by path. The rsync process is killed if the context is cancelled before the
+// transfer completes.
+func Recv(ctx context.Context, path string, conn io.ReadWriteCloser, tracker *ioprogress.ProgressTracker, features []string) error {
 	args := []string{
 		"--server",
 		"-vlogDtpre.iLsfx",
@@ -271,7 +280,7 @@ func Recv(path string, conn io.ReadWriteCloser, tracker *ioprogress.ProgressTrac
 
 	args = append(args, []string{".", path}...)
 
-	cmd := exec.Command("rsync", args...)
+	cmd := tools.Command(ctx, "rsync", args...)
 
 	// Forward from rsync to source.
 	stdout, err := cmd.StdoutPipe()
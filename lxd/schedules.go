@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	cron "gopkg.in/robfig/cron.v2"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+)
+
+// Actions which can be scheduled on an instance.
+var instanceScheduleActions = []string{"start", "stop", "snapshot"}
+
+// The schedules are stored in the global database, so there's no need to forward the requests
+// below to the member running the instance.
+
+func containerSchedulesGet(d *Daemon, r *http.Request) response.Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+	recursion := util.IsRecursionRequest(r)
+
+	var schedules []db.InstanceSchedule
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		_, err := tx.InstanceID(project, name)
+		if err != nil {
+			return err
+		}
+
+		schedules, err = tx.InstanceSchedules(project, name)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	resultString := []string{}
+	resultMap := []*api.InstanceSchedule{}
+	for _, schedule := range schedules {
+		if !recursion {
+			url := fmt.Sprintf("/%s/instances/%s/schedules/%s", version.APIVersion, name, schedule.Name)
+			resultString = append(resultString, url)
+		} else {
+			resultMap = append(resultMap, instanceScheduleRender(schedule))
+		}
+	}
+
+	if !recursion {
+		return response.SyncResponse(true, resultString)
+	}
+
+	return response.SyncResponse(true, resultMap)
+}
+
+func containerSchedulesPost(d *Daemon, r *http.Request) response.Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	req := api.InstanceSchedulesPost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if req.Name == "" || strings.Contains(req.Name, "/") {
+		return response.BadRequest(fmt.Errorf("Invalid schedule name %q", req.Name))
+	}
+
+	err = instanceScheduleValidate(req.InstanceSchedulePut)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	exists := false
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		id, err := tx.InstanceID(project, name)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.InstanceScheduleGet(project, name, req.Name)
+		if err == nil {
+			exists = true
+			return nil
+		}
+
+		if err != db.ErrNoSuchObject {
+			return err
+		}
+
+		_, err = tx.InstanceScheduleCreate(int(id), db.InstanceSchedule{
+			Name:        req.Name,
+			Description: req.Description,
+			Action:      req.Action,
+			Schedule:    req.Schedule,
+		})
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if exists {
+		return response.Conflict(fmt.Errorf("Schedule %q already exists", req.Name))
+	}
+
+	return response.SyncResponseLocation(true, nil, fmt.Sprintf("/%s/instances/%s/schedules/%s", version.APIVersion, name, req.Name))
+}
+
+func containerScheduleGet(d *Daemon, r *http.Request) response.Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+	scheduleName := mux.Vars(r)["scheduleName"]
+
+	var schedule db.InstanceSchedule
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		schedule, err = tx.InstanceScheduleGet(project, name, scheduleName)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, instanceScheduleRender(schedule))
+}
+
+func containerSchedulePut(d *Daemon, r *http.Request) response.Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+	scheduleName := mux.Vars(r)["scheduleName"]
+
+	req := api.InstanceSchedulePut{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = instanceScheduleValidate(req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		schedule, err := tx.InstanceScheduleGet(project, name, scheduleName)
+		if err != nil {
+			return err
+		}
+
+		return tx.InstanceScheduleUpdate(schedule.ID, req.Description, req.Action, req.Schedule)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func containerScheduleDelete(d *Daemon, r *http.Request) response.Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+	scheduleName := mux.Vars(r)["scheduleName"]
+
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		schedule, err := tx.InstanceScheduleGet(project, name, scheduleName)
+		if err != nil {
+			return err
+		}
+
+		return tx.InstanceScheduleDelete(schedule.ID)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func instanceScheduleRender(schedule db.InstanceSchedule) *api.InstanceSchedule {
+	return &api.InstanceSchedule{
+		InstanceSchedulePut: api.InstanceSchedulePut{
+			Description: schedule.Description,
+			Action:      schedule.Action,
+			Schedule:    schedule.Schedule,
+		},
+		Name: schedule.Name,
+	}
+}
+
+// instanceScheduleValidate checks the action and the cron expression of a schedule.
+func instanceScheduleValidate(schedule api.InstanceSchedulePut) error {
+	if !shared.StringInSlice(schedule.Action, instanceScheduleActions) {
+		return fmt.Errorf("Invalid action %q, must be one of: %s", schedule.Action, strings.Join(instanceScheduleActions, ", "))
+	}
+
+	if len(strings.Split(schedule.Schedule, " ")) != 5 {
+		return fmt.Errorf("Schedule must be of the form: <minute> <hour> <day-of-month> <month> <day-of-week>")
+	}
+
+	_, err := cron.Parse(fmt.Sprintf("* %s", schedule.Schedule))
+	if err != nil {
+		return fmt.Errorf("Error parsing schedule: %v", err)
+	}
+
+	return nil
+}
+
+// instanceScheduleIsDue returns whether the cron expression of a schedule matches the given
+// minute.
+func instanceScheduleIsDue(schedule string, now time.Time) bool {
+	// Extend our schedule to one that is accepted by the used cron parser
+	sched, err := cron.Parse(fmt.Sprintf("* %s", schedule))
+	if err != nil {
+		return false
+	}
+
+	// The cron scheduler adds 1s to the given time, so start from the beginning of the
+	// minute and ignore everything that is more precise than minutes.
+	now = now.Truncate(time.Minute)
+	return sched.Next(now).Truncate(time.Minute).Equal(now)
+}
+
+func instanceSchedulesTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		now := time.Now()
+
+		var schedules []db.InstanceSchedule
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			var err error
+			schedules, err = tx.InstanceSchedulesNode()
+			return err
+		})
+		if err != nil {
+			logger.Error("Failed to load instance schedules", log.Ctx{"err": err})
+			return
+		}
+
+		for _, schedule := range schedules {
+			if !instanceScheduleIsDue(schedule.Schedule, now) {
+				continue
+			}
+
+			err := instanceScheduleRun(ctx, d, schedule)
+			if err != nil {
+				logger.Error("Failed to start scheduled instance action", log.Ctx{"project": schedule.Project, "instance": schedule.Instance, "schedule": schedule.Name, "err": err})
+			}
+		}
+	}
+
+	return f, task.Every(time.Minute)
+}
+
+// instanceScheduleRun performs the action of a schedule in a background operation.
+func instanceScheduleRun(ctx context.Context, d *Daemon, schedule db.InstanceSchedule) error {
+	inst, err := instance.LoadByProjectAndName(d.State(), schedule.Project, schedule.Instance)
+	if err != nil {
+		return err
+	}
+
+	var opType db.OperationType
+	var do func() error
+	switch schedule.Action {
+	case "start":
+		if inst.IsRunning() {
+			return nil
+		}
+
+		// Don't start instances on a node being drained.
+		maintenance, err := node.Maintenance(d.db)
+		if err != nil {
+			return err
+		}
+
+		if maintenance {
+			return fmt.Errorf("The server is in maintenance mode (core.maintenance), instances can't be started")
+		}
+
+		opType = db.OperationContainerStart
+		do = func() error {
+			return inst.Start(false)
+		}
+	case "stop":
+		if !inst.IsRunning() {
+			return nil
+		}
+
+		// Keep the default timeout if the configured one can't be parsed, rather than
+		// killing the instance right away.
+		timeout := 30
+		value, ok := inst.ExpandedConfig()["boot.host_shutdown_timeout"]
+		if ok {
+			configured, err := strconv.Atoi(value)
+			if err != nil {
+				logger.Warn("Invalid boot.host_shutdown_timeout, using the default", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "value": value})
+			} else {
+				timeout = configured
+			}
+		}
+
+		opType = db.OperationContainerStop
+		do = func() error {
+			// The instance may have been stopped while waiting for its maintenance window.
+			if !inst.IsRunning() {
+				return nil
+			}
+
+			err := inst.Shutdown(time.Duration(timeout) * time.Second)
+			if err == nil {
+				return nil
+			}
+
+			return inst.Stop(false)
+		}
+	case "snapshot":
+		opType = db.OperationSnapshotCreate
+		do = func() error {
+			return autoCreateContainerSnapshots(ctx, d, []instance.Instance{inst})
+		}
+	default:
+		return fmt.Errorf("Unknown action %q", schedule.Action)
+	}
+
+	// Stopping or snapshotting a running instance waits for its maintenance window.
+	var deferral *operationDeferral
+	if schedule.Action != "start" {
+		deferral = newInstanceMaintenanceDeferral(inst)
+	}
+
+	resources := map[string][]string{}
+	resources["instances"] = []string{inst.Name()}
+
+	run := func(op *operations.Operation) error {
+		err := deferral.Wait(d.State(), op)
+		if err != nil {
+			return err
+		}
+
+		op.UpdateMetadata(map[string]interface{}{"schedule": schedule.Name})
+
+		logger.Info("Running scheduled instance action", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "schedule": schedule.Name, "action": schedule.Action})
+		err = do()
+		if err != nil {
+			return err
+		}
+
+		if schedule.Action != "snapshot" {
+			instanceHistoryRecord(d.State(), inst, schedule.Action, op, fmt.Sprintf("schedule:%s", schedule.Name))
+		}
+
+		return nil
+	}
+
+	op, err := operations.OperationCreate(d.State(), inst.Project(), operations.OperationClassTask, opType, resources, nil, run, deferral.OnCancel(), nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = op.Run()
+	return err
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestInstanceScheduleValidate(t *testing.T) {
+	cases := []struct {
+		action   string
+		schedule string
+		valid    bool
+	}{
+		{"snapshot", "0 2 * * *", true},
+		{"start", "0 8 * * 1-5", true},
+		{"restart", "0 8 * * *", false},
+		{"stop", "0 18 * *", false},
+		{"stop", "0 25 * * *", false},
+	}
+
+	for _, c := range cases {
+		err := instanceScheduleValidate(api.InstanceSchedulePut{Action: c.action, Schedule: c.schedule})
+		assert.Equal(t, c.valid, err == nil, "%s %q", c.action, c.schedule)
+	}
+}
+
+func TestInstanceScheduleIsDue(t *testing.T) {
+	monday := time.Date(2020, time.May, 4, 8, 0, 30, 0, time.Local)
+
+	assert.True(t, instanceScheduleIsDue("0 8 * * 1-5", monday))
+	assert.False(t, instanceScheduleIsDue("0 8 * * 1-5", monday.Add(time.Minute)))
+	assert.False(t, instanceScheduleIsDue("0 8 * * 1-5", monday.AddDate(0, 0, 5)))
+	assert.True(t, instanceScheduleIsDue("*/15 * * * *", monday.Add(15*time.Minute)))
+}
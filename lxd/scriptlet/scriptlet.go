@@ -0,0 +1,279 @@
+package scriptlet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"go.starlark.net/starlark"
+
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// Name of the function called by Admission.
+const admissionFunc = "admission"
+
+// Maximum number of steps the scriptlet may run for, so that a looping scriptlet fails rather
+// than blocking instance creation.
+const maxExecutionSteps = 1000000
+
+// AdmissionContext holds the details passed to the scriptlet alongside the creation request.
+type AdmissionContext struct {
+	Project  string   `json:"project"`
+	Username string   `json:"username"`
+	Protocol string   `json:"protocol"`
+	Target   string   `json:"target"`
+	Members  []string `json:"members"`
+}
+
+// Validate checks that the scriptlet compiles and defines the admission function.
+func Validate(src string) error {
+	if src == "" {
+		return nil
+	}
+
+	// The builtins are only called when running the admission function.
+	noop := func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		return starlark.None, nil
+	}
+
+	_, _, err := load(src, starlark.StringDict{
+		"reject":     starlark.NewBuiltin("reject", noop),
+		"set_target": starlark.NewBuiltin("set_target", noop),
+	})
+
+	return err
+}
+
+// Admission runs the admission function of the scriptlet against an instance creation request.
+// The function may modify the request in place, call reject() to refuse it or set_target() to
+// pick the cluster member to create the instance on. The selected target is returned, empty if
+// none was set.
+func Admission(src string, req *api.InstancesPost, ctx AdmissionContext) (string, error) {
+	target := ""
+	rejected := ""
+
+	reject := func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var reason string
+		err := starlark.UnpackArgs(b.Name(), args, kwargs, "reason", &reason)
+		if err != nil {
+			return nil, err
+		}
+
+		rejected = reason
+		return nil, fmt.Errorf("Rejected")
+	}
+
+	setTarget := func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var member string
+		err := starlark.UnpackArgs(b.Name(), args, kwargs, "member", &member)
+		if err != nil {
+			return nil, err
+		}
+
+		target = member
+		return starlark.None, nil
+	}
+
+	thread, fn, err := load(src, starlark.StringDict{
+		"reject":     starlark.NewBuiltin("reject", reject),
+		"set_target": starlark.NewBuiltin("set_target", setTarget),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	reqValue, err := marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	ctxValue, err := marshal(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = starlark.Call(thread, fn, starlark.Tuple{reqValue, ctxValue}, nil)
+	if rejected != "" {
+		return "", fmt.Errorf("Instance creation rejected: %s", rejected)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to run admission scriptlet: %v", err)
+	}
+
+	newReq := api.InstancesPost{}
+	err = unmarshal(reqValue, &newReq)
+	if err != nil {
+		return "", fmt.Errorf("Invalid request returned by admission scriptlet: %v", err)
+	}
+
+	*req = newReq
+	return target, nil
+}
+
+// load runs the top-level statements of the scriptlet and returns its admission function.
+func load(src string, predeclared starlark.StringDict) (*starlark.Thread, *starlark.Function, error) {
+	thread := &starlark.Thread{
+		Name: admissionFunc,
+		Print: func(thread *starlark.Thread, msg string) {
+			logger.Debug("Admission scriptlet", log.Ctx{"msg": msg})
+		},
+	}
+	thread.SetMaxExecutionSteps(maxExecutionSteps)
+
+	globals, err := starlark.ExecFile(thread, "admission.star", src, predeclared)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to load admission scriptlet: %v", err)
+	}
+
+	fn, ok := globals[admissionFunc].(*starlark.Function)
+	if !ok {
+		return nil, nil, fmt.Errorf("Admission scriptlet doesn't define an %q function", admissionFunc)
+	}
+
+	if fn.NumParams() != 2 {
+		return nil, nil, fmt.Errorf("The %q function of the admission scriptlet must take 2 arguments", admissionFunc)
+	}
+
+	return thread, fn, nil
+}
+
+// marshal converts a value to its starlark equivalent through its JSON representation.
+func marshal(v interface{}) (starlark.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err = decoder.Decode(&generic)
+	if err != nil {
+		return nil, err
+	}
+
+	return toStarlark(generic)
+}
+
+// unmarshal stores a starlark value into v through its JSON representation.
+func unmarshal(value starlark.Value, v interface{}) error {
+	generic, err := fromStarlark(value)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(generic)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func toStarlark(v interface{}) (starlark.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case string:
+		return starlark.String(v), nil
+	case json.Number:
+		i, err := v.Int64()
+		if err == nil {
+			return starlark.MakeInt64(i), nil
+		}
+
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+
+		return starlark.Float(f), nil
+	case []interface{}:
+		elems := make([]starlark.Value, 0, len(v))
+		for _, elem := range v {
+			value, err := toStarlark(elem)
+			if err != nil {
+				return nil, err
+			}
+
+			elems = append(elems, value)
+		}
+
+		return starlark.NewList(elems), nil
+	case map[string]interface{}:
+		dict := starlark.NewDict(len(v))
+		for key, elem := range v {
+			value, err := toStarlark(elem)
+			if err != nil {
+				return nil, err
+			}
+
+			err = dict.SetKey(starlark.String(key), value)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return dict, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported type %T", v)
+}
+
+func fromStarlark(value starlark.Value) (interface{}, error) {
+	switch value := value.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(value), nil
+	case starlark.String:
+		return value.GoString(), nil
+	case starlark.Int:
+		i, ok := value.Int64()
+		if !ok {
+			return nil, fmt.Errorf("Integer %s out of range", value.String())
+		}
+
+		return i, nil
+	case starlark.Float:
+		return float64(value), nil
+	case starlark.Indexable:
+		// Lists and tuples.
+		elems := make([]interface{}, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			elem, err := fromStarlark(value.Index(i))
+			if err != nil {
+				return nil, err
+			}
+
+			elems = append(elems, elem)
+		}
+
+		return elems, nil
+	case *starlark.Dict:
+		m := make(map[string]interface{}, value.Len())
+		for _, item := range value.Items() {
+			key, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("Dictionary keys must be strings, not %s", item[0].Type())
+			}
+
+			elem, err := fromStarlark(item[1])
+			if err != nil {
+				return nil, err
+			}
+
+			m[key.GoString()] = elem
+		}
+
+		return m, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported type %s", value.Type())
+}
@@ -0,0 +1,68 @@
+package scriptlet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+const testScriptlet = `
+def admission(request, context):
+    if not request["name"].startswith(context["project"] + "-"):
+        reject("Instance names must start with the project name")
+
+    if "base" not in request["profiles"]:
+        request["profiles"].append("base")
+
+    request["config"]["user.owner"] = context["username"]
+
+    if len(context["members"]) > 0:
+        set_target(context["members"][-1])
+`
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(""))
+	assert.NoError(t, Validate(testScriptlet))
+	assert.Error(t, Validate("def admission(:"))
+	assert.Error(t, Validate("def placement(request, context):\n    pass\n"))
+	assert.Error(t, Validate("def admission(request):\n    pass\n"))
+}
+
+func TestAdmission(t *testing.T) {
+	req := api.InstancesPost{
+		Name: "web-c1",
+		InstancePut: api.InstancePut{
+			Profiles: []string{"default"},
+			Config:   map[string]string{"limits.cpu": "2"},
+		},
+	}
+
+	ctx := AdmissionContext{Project: "web", Username: "alice", Members: []string{"node1", "node2"}}
+
+	target, err := Admission(testScriptlet, &req, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "node2", target)
+	assert.Equal(t, "web-c1", req.Name)
+	assert.Equal(t, []string{"default", "base"}, req.Profiles)
+	assert.Equal(t, map[string]string{"limits.cpu": "2", "user.owner": "alice"}, req.Config)
+}
+
+func TestAdmission_Reject(t *testing.T) {
+	req := api.InstancesPost{Name: "c1"}
+	ctx := AdmissionContext{Project: "web"}
+
+	_, err := Admission(testScriptlet, &req, ctx)
+	assert.EqualError(t, err, "Instance creation rejected: Instance names must start with the project name")
+}
+
+// Scriptlets running for too long are interrupted.
+func TestAdmission_MaxSteps(t *testing.T) {
+	src := "def admission(request, context):\n    for i in range(1000000000):\n        pass\n"
+
+	req := api.InstancesPost{Name: "c1"}
+	_, err := Admission(src, &req, AdmissionContext{})
+	assert.Error(t, err)
+}
@@ -268,6 +268,11 @@ finit_module errno 38
 delete_module errno 38
 `
 
+const keyringBlockingPolicy = `keyctl errno 38
+add_key errno 38
+request_key errno 38
+`
+
 //          8 == SECCOMP_FILTER_FLAG_NEW_LISTENER
 // 2146435072 == SECCOMP_RET_TRACE
 const seccompNotifyDisallow = `seccomp errno 22 [1,2146435072,SCMP_CMP_MASKED_EQ,2146435072]
@@ -425,6 +430,10 @@ func InstanceNeedsPolicy(c Instance) bool {
 		}
 	}
 
+	if config["security.keyring"] == "blocked" {
+		return true
+	}
+
 	return false
 }
 
@@ -530,6 +539,10 @@ func seccompGetPolicyContent(s *state.State, c Instance) (string, error) {
 		policy += fmt.Sprintf(compatBlockingPolicy, arch)
 	}
 
+	if config["security.keyring"] == "blocked" {
+		policy += keyringBlockingPolicy
+	}
+
 	blacklist := config["security.syscalls.blacklist"]
 	if blacklist != "" {
 		policy += blacklist
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/url"
 
+	"github.com/lxc/lxd/lxd/bgp"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/endpoints"
 	"github.com/lxc/lxd/lxd/events"
@@ -25,6 +26,9 @@ type State struct {
 	// MAAS server
 	MAAS *maas.Controller
 
+	// BGP speaker
+	BGP *bgp.Server
+
 	// OS access
 	OS    *sys.OS
 	Proxy func(req *http.Request) (*url.URL, error)
@@ -38,20 +42,25 @@ type State struct {
 
 	// Firewall instance
 	Firewall firewall.Firewall
+
+	// Closed when LXD is fully ready
+	Ready chan struct{}
 }
 
 // NewState returns a new State object with the given database and operating
 // system components.
-func NewState(node *db.Node, cluster *db.Cluster, maas *maas.Controller, os *sys.OS, endpoints *endpoints.Endpoints, events *events.Server, devlxdEvents *events.Server, firewall firewall.Firewall, proxy func(req *http.Request) (*url.URL, error)) *State {
+func NewState(node *db.Node, cluster *db.Cluster, maas *maas.Controller, bgp *bgp.Server, os *sys.OS, endpoints *endpoints.Endpoints, events *events.Server, devlxdEvents *events.Server, firewall firewall.Firewall, proxy func(req *http.Request) (*url.URL, error), ready chan struct{}) *State {
 	return &State{
 		Node:         node,
 		Cluster:      cluster,
 		MAAS:         maas,
+		BGP:          bgp,
 		OS:           os,
 		Endpoints:    endpoints,
 		DevlxdEvents: devlxdEvents,
 		Events:       events,
 		Firewall:     firewall,
 		Proxy:        proxy,
+		Ready:        ready,
 	}
 }
@@ -26,7 +26,10 @@ func NewTestState(t *testing.T) (*State, func()) {
 		osCleanup()
 	}
 
-	state := NewState(node, cluster, nil, os, nil, nil, nil, firewall.New(), nil)
+	ready := make(chan struct{})
+	close(ready)
+
+	state := NewState(node, cluster, nil, nil, os, nil, nil, nil, firewall.New(), nil, ready)
 
 	return state, cleanup
 }
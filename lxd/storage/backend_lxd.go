@@ -20,6 +20,7 @@ import (
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/revert"
+	"github.com/lxc/lxd/lxd/rsync"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/lxd/storage/drivers"
 	"github.com/lxc/lxd/lxd/storage/locking"
@@ -688,7 +689,7 @@ func (b *lxdBackend) CreateInstanceFromCopy(inst instance.Instance, src instance
 			return fmt.Errorf("Failed to negotiate copy migration type: %v", err)
 		}
 
-		ctx, cancel := context.WithCancel(context.Background())
+		ctx, cancel := context.WithCancel(op.Context())
 
 		// Use in-memory pipe pair to simulate a connection between the sender and receiver.
 		aEnd, bEnd := memorypipe.NewPipePair(ctx)
@@ -839,7 +840,7 @@ func (b *lxdBackend) RefreshInstance(inst instance.Instance, src instance.Instan
 			return fmt.Errorf("Failed to negotiate copy migration type: %v", err)
 		}
 
-		ctx, cancel := context.WithCancel(context.Background())
+		ctx, cancel := context.WithCancel(op.Context())
 
 		// Use in-memory pipe pair to simulate a connection between the sender and receiver.
 		aEnd, bEnd := memorypipe.NewPipePair(ctx)
@@ -922,8 +923,13 @@ func (b *lxdBackend) imageFiller(fingerprint string, op *operations.Operation) f
 					op.UpdateMetadata(metadata)
 				}}
 		}
+		ctx := context.Background()
+		if op != nil {
+			ctx = op.Context()
+		}
+
 		imageFile := shared.VarPath("images", fingerprint)
-		return ImageUnpack(imageFile, mountPath, rootBlockPath, b.driver.Info().BlockBacking, b.state.OS.RunningInUserNS, tracker)
+		return ImageUnpack(ctx, imageFile, mountPath, rootBlockPath, b.driver.Info().BlockBacking, b.state.OS.RunningInUserNS, tracker)
 	}
 }
 
@@ -959,6 +965,27 @@ func (b *lxdBackend) CreateInstanceFromImage(inst instance.Instance, fingerprint
 
 	vol := b.newVolume(volType, contentType, volStorageName, rootDiskConf)
 
+	err = b.createVolumeFromImage(vol, fingerprint, op)
+	if err != nil {
+		return err
+	}
+
+	err = b.ensureInstanceSymlink(inst.Type(), inst.Project(), inst.Name(), vol.MountPath())
+	if err != nil {
+		return err
+	}
+
+	err = inst.DeferTemplateApply("create")
+	if err != nil {
+		return err
+	}
+
+	revert = false
+	return nil
+}
+
+// createVolumeFromImage creates the given volume and populates it with the image's contents.
+func (b *lxdBackend) createVolumeFromImage(vol drivers.Volume, fingerprint string, op *operations.Operation) error {
 	// If the driver doesn't support optimized image volumes then create a new empty volume and
 	// populate it with the contents of the image archive.
 	if !b.driver.Info().OptimizedImages {
@@ -967,38 +994,156 @@ func (b *lxdBackend) CreateInstanceFromImage(inst instance.Instance, fingerprint
 			Fill:        b.imageFiller(fingerprint, op),
 		}
 
-		err = b.driver.CreateVolume(vol, &volFiller, op)
+		return b.driver.CreateVolume(vol, &volFiller, op)
+	}
+
+	// If the driver does support optimized images then ensure the optimized image
+	// volume has been created for the archive's fingerprint and then proceed to create
+	// a new volume by copying the optimized image volume.
+	err := b.EnsureImage(fingerprint, op)
+	if err != nil {
+		return err
+	}
+
+	// No config for an image volume so set to nil.
+	imgVol := b.newVolume(drivers.VolumeTypeImage, vol.ContentType(), fingerprint, nil)
+	return b.driver.CreateVolumeFromCopy(vol, imgVol, false, op)
+}
+
+// ResetInstanceFromImage replaces the volume of a stopped instance with a fresh copy of the given
+// image, discarding all the changes made since. The new volume is created under a temporary name
+// first, so that the current one is kept if that fails. Without snapshots, it's then swapped with
+// the current volume, otherwise its content is copied over the current volume, keeping the
+// snapshots which depend on it.
+func (b *lxdBackend) ResetInstanceFromImage(inst instance.Instance, fingerprint string, op *operations.Operation) error {
+	logger := logging.AddContext(b.logger, log.Ctx{"project": inst.Project(), "instance": inst.Name()})
+	logger.Debug("ResetInstanceFromImage started")
+	defer logger.Debug("ResetInstanceFromImage finished")
+
+	if inst.IsSnapshot() {
+		return fmt.Errorf("Instance must not be a snapshot")
+	}
+
+	if inst.IsRunning() {
+		return fmt.Errorf("Instance must not be running")
+	}
+
+	volType, err := InstanceTypeToVolumeType(inst.Type())
+	if err != nil {
+		return err
+	}
+
+	contentType := InstanceContentType(inst)
+	if contentType != drivers.ContentTypeFS {
+		return fmt.Errorf("Only filesystem volumes can be reset")
+	}
+
+	snapshots, err := b.state.Cluster.ContainerGetSnapshots(inst.Project(), inst.Name())
+	if err != nil {
+		return err
+	}
+
+	// Get the root disk device config.
+	rootDiskConf, err := b.instanceRootVolumeConfig(inst)
+	if err != nil {
+		return err
+	}
+
+	// Get the volume name on storage.
+	volStorageName := project.Instance(inst.Project(), inst.Name())
+
+	vol := b.newVolume(volType, contentType, volStorageName, rootDiskConf)
+
+	// Instance names can't contain dots, so the temporary names can't be in use by other instances.
+	newVol := b.newVolume(volType, contentType, fmt.Sprintf("%s.reset", volStorageName), rootDiskConf)
+	oldVol := b.newVolume(volType, contentType, fmt.Sprintf("%s.old", volStorageName), rootDiskConf)
+
+	for _, v := range []drivers.Volume{newVol, oldVol} {
+		if b.driver.HasVolume(v) {
+			return fmt.Errorf("Leftover volume %q of a previous reset must be removed first", v.Name())
+		}
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	err = b.createVolumeFromImage(newVol, fingerprint, op)
+	if err != nil {
+		return err
+	}
+
+	revert.Add(func() { b.driver.DeleteVolume(newVol, op) })
+
+	if !b.driver.HasVolume(vol) {
+		err = b.driver.RenameVolume(newVol, vol.Name(), op)
 		if err != nil {
 			return err
 		}
-	} else {
-		// If the driver does support optimized images then ensure the optimized image
-		// volume has been created for the archive's fingerprint and then proceed to create
-		// a new volume by copying the optimized image volume.
-		err := b.EnsureImage(fingerprint, op)
+	} else if len(snapshots) == 0 {
+		err = b.driver.RenameVolume(vol, oldVol.Name(), op)
 		if err != nil {
 			return err
 		}
 
-		// No config for an image volume so set to nil.
-		imgVol := b.newVolume(drivers.VolumeTypeImage, contentType, fingerprint, nil)
-		err = b.driver.CreateVolumeFromCopy(vol, imgVol, false, op)
+		revert.Add(func() { b.driver.RenameVolume(oldVol, vol.Name(), op) })
+
+		err = b.driver.RenameVolume(newVol, vol.Name(), op)
+		if err != nil {
+			return err
+		}
+
+		// The instance now uses the new volume.
+		err = b.driver.DeleteVolume(oldVol, op)
+		if err != nil {
+			logger.Warn("Failed to delete the previous volume of the instance", log.Ctx{"volume": oldVol.Name(), "err": err})
+		}
+	} else {
+		err = b.resetVolumeContent(vol, newVol, op)
 		if err != nil {
 			return err
 		}
+
+		err = b.driver.DeleteVolume(newVol, op)
+		if err != nil {
+			logger.Warn("Failed to delete the temporary volume of the instance", log.Ctx{"volume": newVol.Name(), "err": err})
+		}
 	}
 
+	revert.Success()
+
 	err = b.ensureInstanceSymlink(inst.Type(), inst.Project(), inst.Name(), vol.MountPath())
 	if err != nil {
 		return err
 	}
 
-	err = inst.DeferTemplateApply("create")
+	return inst.DeferTemplateApply("create")
+}
+
+// resetVolumeContent replaces the content of a volume with the one of another volume.
+func (b *lxdBackend) resetVolumeContent(vol drivers.Volume, srcVol drivers.Volume, op *operations.Operation) error {
+	ourMount, err := b.driver.MountVolume(srcVol, op)
 	if err != nil {
 		return err
 	}
 
-	revert = false
+	if ourMount {
+		defer b.driver.UnmountVolume(srcVol, op)
+	}
+
+	ourMount, err = b.driver.MountVolume(vol, op)
+	if err != nil {
+		return err
+	}
+
+	if ourMount {
+		defer b.driver.UnmountVolume(vol, op)
+	}
+
+	output, err := rsync.LocalCopyContext(op.Context(), srcVol.MountPath(), vol.MountPath(), "", true)
+	if err != nil {
+		return fmt.Errorf("Failed to copy the new content of the volume: %s: %s", err, output)
+	}
+
 	return nil
 }
 
@@ -1496,14 +1641,20 @@ func (b *lxdBackend) GetInstanceUsage(inst instance.Instance) (int64, error) {
 
 // SetInstanceQuota sets the quota on the instance's root volume.
 // Returns ErrRunningQuotaResizeNotSupported if the instance is running and the storage driver
-// doesn't support resizing whilst the instance is running.
+// doesn't support resizing whilst the instance is running. The filesystem of the containers on
+// block backed drivers can only be grown whilst running.
 func (b *lxdBackend) SetInstanceQuota(inst instance.Instance, size string, op *operations.Operation) error {
 	logger := logging.AddContext(b.logger, log.Ctx{"project": inst.Project(), "instance": inst.Name()})
 	logger.Debug("SetInstanceQuota started")
 	defer logger.Debug("SetInstanceQuota finished")
 
+	onlineGrowOnly := false
 	if inst.IsRunning() && !b.driver.Info().RunningQuotaResize {
-		return ErrRunningQuotaResizeNotSupported
+		if !b.driver.Info().BlockBacking || inst.Type() != instancetype.Container {
+			return ErrRunningQuotaResizeNotSupported
+		}
+
+		onlineGrowOnly = true
 	}
 
 	// Check we can convert the instance to the volume type needed.
@@ -1519,7 +1670,12 @@ func (b *lxdBackend) SetInstanceQuota(inst instance.Instance, size string, op *o
 	// There's no need to pass config as it's not needed when setting quotas.
 	vol := b.newVolume(volType, contentVolume, volStorageName, nil)
 
-	return b.driver.SetVolumeQuota(vol, size, op)
+	err = b.driver.SetVolumeQuota(vol, size, op)
+	if onlineGrowOnly && errors.Cause(err) == drivers.ErrInUse {
+		return ErrRunningQuotaResizeNotSupported
+	}
+
+	return err
 }
 
 // MountInstance mounts the instance's root volume.
@@ -1945,6 +2101,59 @@ func (b *lxdBackend) UnmountInstanceSnapshot(inst instance.Instance, op *operati
 	return b.driver.UnmountVolumeSnapshot(vol, op)
 }
 
+// DiffInstanceSnapshot lists the paths of the root filesystem which differ between an instance snapshot and
+// either another snapshot of the instance or the instance itself, using the storage driver. Returns
+// drivers.ErrNotSupported if the driver can't compare them natively.
+func (b *lxdBackend) DiffInstanceSnapshot(inst instance.Instance, target instance.Instance, op *operations.Operation) ([]api.InstanceSnapshotDiffEntry, error) {
+	logger := logging.AddContext(b.logger, log.Ctx{"project": inst.Project(), "instance": inst.Name(), "target": target.Name()})
+	logger.Debug("DiffInstanceSnapshot started")
+	defer logger.Debug("DiffInstanceSnapshot finished")
+
+	if !inst.IsSnapshot() {
+		return nil, fmt.Errorf("Instance must be a snapshot")
+	}
+
+	if inst.Type() != instancetype.Container {
+		return nil, drivers.ErrNotSupported
+	}
+
+	// Check we can convert the instance to the volume type needed.
+	volType, err := InstanceTypeToVolumeType(inst.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the root disk device config.
+	rootDiskConf, err := b.instanceRootVolumeConfig(inst)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := InstanceContentType(inst)
+
+	// Get the volumes.
+	vol := b.newVolume(volType, contentType, project.Instance(inst.Project(), inst.Name()), rootDiskConf)
+	targetVol := b.newVolume(volType, contentType, project.Instance(target.Project(), target.Name()), rootDiskConf)
+
+	changes, err := b.driver.DiffVolumes(vol, targetVol, op)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only report the changes of the root filesystem, relative to it.
+	diff := []api.InstanceSnapshotDiffEntry{}
+	for _, change := range changes {
+		if !strings.HasPrefix(change.Path, "/rootfs/") {
+			continue
+		}
+
+		change.Path = strings.TrimPrefix(change.Path, "/rootfs")
+		diff = append(diff, change)
+	}
+
+	return diff, nil
+}
+
 // poolBlockFilesystem returns the filesystem used for new block device filesystems.
 func (b *lxdBackend) poolBlockFilesystem() string {
 	if b.db.Config["volume.block.filesystem"] != "" {
@@ -2290,7 +2499,7 @@ func (b *lxdBackend) CreateCustomVolumeFromCopy(projectName string, volName stri
 		return fmt.Errorf("Failed to negotiate copy migration type: %v", err)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(op.Context())
 
 	// Use in-memory pipe pair to simulate a connection between the sender and receiver.
 	aEnd, bEnd := memorypipe.NewPipePair(ctx)
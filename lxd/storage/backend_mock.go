@@ -83,6 +83,10 @@ func (b *mockBackend) CreateInstanceFromImage(inst instance.Instance, fingerprin
 	return nil
 }
 
+func (b *mockBackend) ResetInstanceFromImage(inst instance.Instance, fingerprint string, op *operations.Operation) error {
+	return nil
+}
+
 func (b *mockBackend) CreateInstanceFromMigration(inst instance.Instance, conn io.ReadWriteCloser, args migration.VolumeTargetArgs, op *operations.Operation) error {
 	return nil
 }
@@ -163,6 +167,10 @@ func (b *mockBackend) UnmountInstanceSnapshot(inst instance.Instance, op *operat
 	return true, nil
 }
 
+func (b *mockBackend) DiffInstanceSnapshot(inst instance.Instance, target instance.Instance, op *operations.Operation) ([]api.InstanceSnapshotDiffEntry, error) {
+	return nil, drivers.ErrNotSupported
+}
+
 func (b *mockBackend) UpdateInstanceSnapshot(inst instance.Instance, newDesc string, newConfig map[string]string, op *operations.Operation) error {
 	return nil
 }
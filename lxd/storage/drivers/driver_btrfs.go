@@ -12,6 +12,7 @@ import (
 
 	"github.com/lxc/lxd/lxd/migration"
 	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/tools"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -51,7 +52,7 @@ func (d *btrfs) load() error {
 
 	// Detect and record the version.
 	if btrfsVersion == "" {
-		out, err := shared.RunCommand("btrfs", "version")
+		out, err := tools.RunCommand("btrfs", "version")
 		if err != nil {
 			return err
 		}
@@ -164,7 +165,7 @@ func (d *btrfs) Create() error {
 			}
 
 			// Create the subvolume.
-			_, err := shared.RunCommand("btrfs", "subvolume", "create", hostPath)
+			_, err := tools.RunCommand("btrfs", "subvolume", "create", hostPath)
 			if err != nil {
 				return err
 			}
@@ -5,7 +5,6 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -14,7 +13,10 @@ import (
 	"github.com/pkg/errors"
 	"golang.org/x/sys/unix"
 
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/tools"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/ioprogress"
 	"github.com/lxc/lxd/shared/logger"
 )
@@ -90,7 +92,7 @@ func (d *btrfs) snapshotSubvolume(path string, dest string, readonly bool, recur
 	// Single subvolume deletion.
 	snapshot := func(path string, dest string) error {
 		if readonly && !d.state.OS.RunningInUserNS {
-			_, err := shared.RunCommand("btrfs", "subvolume", "snapshot", "-r", path, dest)
+			_, err := tools.RunCommand("btrfs", "subvolume", "snapshot", "-r", path, dest)
 			if err != nil {
 				return err
 			}
@@ -98,7 +100,7 @@ func (d *btrfs) snapshotSubvolume(path string, dest string, readonly bool, recur
 			return nil
 		}
 
-		_, err := shared.RunCommand("btrfs", "subvolume", "snapshot", path, dest)
+		_, err := tools.RunCommand("btrfs", "subvolume", "snapshot", path, dest)
 		if err != nil {
 			return err
 		}
@@ -154,18 +156,18 @@ func (d *btrfs) deleteSubvolume(path string, recursion bool) error {
 		// Attempt (but don't fail on) to delete any qgroup on the subvolume.
 		qgroup, _, err := d.getQGroup(path)
 		if err == nil {
-			shared.RunCommand("btrfs", "qgroup", "destroy", qgroup, path)
+			tools.RunCommand("btrfs", "qgroup", "destroy", qgroup, path)
 		}
 
 		// Attempt to make the subvolume writable.
-		shared.RunCommand("btrfs", "property", "set", path, "ro", "false")
+		tools.RunCommand("btrfs", "property", "set", path, "ro", "false")
 
 		// Temporarily change ownership & mode to help with nesting.
 		os.Chmod(path, 0700)
 		os.Chown(path, 0, 0)
 
 		// Delete the subvolume itself.
-		_, err = shared.RunCommand("btrfs", "subvolume", "delete", path)
+		_, err = tools.RunCommand("btrfs", "subvolume", "delete", path)
 
 		return err
 	}
@@ -198,7 +200,7 @@ func (d *btrfs) deleteSubvolume(path string, recursion bool) error {
 
 func (d *btrfs) getQGroup(path string) (string, int64, error) {
 	// Try to get the qgroup details.
-	output, err := shared.RunCommand("btrfs", "qgroup", "show", "-e", "-f", "--raw", path)
+	output, err := tools.RunCommand("btrfs", "qgroup", "show", "-e", "-f", "--raw", path)
 	if err != nil {
 		return "", -1, errBtrfsNoQuota
 	}
@@ -232,14 +234,14 @@ func (d *btrfs) getQGroup(path string) (string, int64, error) {
 	return qgroup, usage, nil
 }
 
-func (d *btrfs) sendSubvolume(path string, parent string, conn io.ReadWriteCloser, tracker *ioprogress.ProgressTracker) error {
+func (d *btrfs) sendSubvolume(path string, parent string, conn io.ReadWriteCloser, tracker *ioprogress.ProgressTracker, op *operations.Operation) error {
 	// Assemble btrfs send command.
 	args := []string{"send"}
 	if parent != "" {
 		args = append(args, "-p", parent)
 	}
 	args = append(args, path)
-	cmd := exec.Command("btrfs", args...)
+	cmd := tools.Command(op.Context(), "btrfs", args...)
 
 	// Prepare stdout/stderr.
 	stdout, err := cmd.StdoutPipe()
@@ -301,9 +303,9 @@ func (d *btrfs) sendSubvolume(path string, parent string, conn io.ReadWriteClose
 	return nil
 }
 
-func (d *btrfs) receiveSubvolume(path string, targetPath string, conn io.ReadWriteCloser, writeWrapper func(io.WriteCloser) io.WriteCloser) error {
+func (d *btrfs) receiveSubvolume(path string, targetPath string, conn io.ReadWriteCloser, writeWrapper func(io.WriteCloser) io.WriteCloser, op *operations.Operation) error {
 	// Assemble btrfs send command.
-	cmd := exec.Command("btrfs", "receive", "-e", path)
+	cmd := tools.Command(op.Context(), "btrfs", "receive", "-e", path)
 
 	// Prepare stdin/stderr.
 	stdin, err := cmd.StdinPipe()
@@ -365,7 +367,7 @@ func (d *btrfs) receiveSubvolume(path string, targetPath string, conn io.ReadWri
 	}
 
 	// Mark the received subvolume writable.
-	_, err = shared.RunCommand("btrfs", "property", "set", "-ts", receivedSnapshot, "ro", "false")
+	_, err = tools.RunCommand("btrfs", "property", "set", "-ts", receivedSnapshot, "ro", "false")
 	if err != nil {
 		return err
 	}
@@ -378,3 +380,144 @@ func (d *btrfs) receiveSubvolume(path string, targetPath string, conn io.ReadWri
 
 	return nil
 }
+
+// btrfsParseDump converts the output of "btrfs receive --dump" for an incremental stream into changes
+// relative to the subvolume root. New entries are created under temporary names and renamed into place,
+// renames of existing entries are reported as the removal of the old path and the addition of the new one.
+// Timestamp updates are ignored as they're sent for every directory whose entries changed.
+func btrfsParseDump(dump string) []api.InstanceSnapshotDiffEntry {
+	changes := map[string]string{}
+
+	// Paths are prefixed with the name of the received subvolume.
+	prefix := ""
+	relPath := func(path string) string {
+		path = strings.TrimPrefix(path, prefix)
+		return "/" + strings.TrimPrefix(path, "/")
+	}
+
+	for _, line := range strings.Split(dump, "\n") {
+		fields := btrfsDumpFields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		command := fields[0]
+		if command == "snapshot" || command == "subvol" {
+			prefix = fields[1]
+			continue
+		}
+
+		path := relPath(fields[1])
+		if path == "/" {
+			continue
+		}
+
+		dest := ""
+		for _, field := range fields[2:] {
+			if strings.HasPrefix(field, "dest=") {
+				dest = relPath(strings.TrimPrefix(field, "dest="))
+			}
+		}
+
+		switch command {
+		case "mkfile", "mkdir", "mknod", "mkfifo", "mksock", "symlink":
+			changes[path] = "added"
+		case "link":
+			btrfsDumpAdd(changes, path)
+		case "rename":
+			if changes[path] != "added" {
+				changes[path] = "removed"
+			}
+
+			// Move the entries created below a new directory along with it.
+			for child, change := range changes {
+				if change == "added" && strings.HasPrefix(child, path+"/") {
+					delete(changes, child)
+					changes[dest+strings.TrimPrefix(child, path)] = "added"
+				}
+			}
+
+			if changes[path] == "added" {
+				delete(changes, path)
+			}
+
+			btrfsDumpAdd(changes, dest)
+		case "unlink", "rmdir":
+			if changes[path] == "added" {
+				delete(changes, path)
+			} else {
+				changes[path] = "removed"
+			}
+		case "utimes", "end":
+		default:
+			_, ok := changes[path]
+			if !ok {
+				changes[path] = "changed"
+			}
+		}
+	}
+
+	diff := make([]api.InstanceSnapshotDiffEntry, 0, len(changes))
+	for path, change := range changes {
+		diff = append(diff, api.InstanceSnapshotDiffEntry{Path: path, Change: change})
+	}
+
+	sort.Slice(diff, func(i, j int) bool { return diff[i].Path < diff[j].Path })
+
+	return diff
+}
+
+// btrfsDumpAdd records a path showing up in the stream, replacing a removed entry if any.
+func btrfsDumpAdd(changes map[string]string, path string) {
+	if changes[path] == "removed" {
+		changes[path] = "changed"
+		return
+	}
+
+	changes[path] = "added"
+}
+
+// btrfsDumpFields splits a "btrfs receive --dump" line on whitespace, decoding the backslash escapes used
+// for special characters in paths.
+func btrfsDumpFields(line string) []string {
+	fields := []string{}
+	var field strings.Builder
+	inField := false
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		switch {
+		case c == '\\' && i+1 < len(line):
+			inField = true
+
+			// Octal escapes for non-printable characters.
+			if i+3 < len(line) {
+				value, err := strconv.ParseUint(line[i+1:i+4], 8, 8)
+				if err == nil {
+					field.WriteByte(byte(value))
+					i += 3
+					continue
+				}
+			}
+
+			i++
+			field.WriteByte(line[i])
+		case c == ' ' || c == '\t':
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			inField = true
+			field.WriteByte(c)
+		}
+	}
+
+	if inField {
+		fields = append(fields, field.String())
+	}
+
+	return fields
+}
@@ -0,0 +1,34 @@
+package drivers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func Test_btrfsParseDump(t *testing.T) {
+	dump := `snapshot        ./snap1                         uuid=7d1c transid=12 parent_uuid=5a2b parent_transid=10
+utimes          ./snap1/rootfs/etc              atime=2020-01-01T00:00:00+0000 mtime=2020-01-01T00:00:00+0000 ctime=2020-01-01T00:00:00+0000
+update_extent   ./snap1/rootfs/etc/hosts        offset=0 len=12
+unlink          ./snap1/rootfs/etc/hostname
+mkdir           ./snap1/o258-12-0
+rename          ./snap1/o258-12-0               dest=./snap1/rootfs/srv/new\ dir
+mkfile          ./snap1/o259-12-0
+rename          ./snap1/o259-12-0               dest=./snap1/rootfs/srv/new\ dir/file
+rename          ./snap1/rootfs/tmp/a            dest=./snap1/rootfs/tmp/b
+chmod           ./snap1/rootfs/etc/passwd       mode=600
+`
+
+	diff := btrfsParseDump(dump)
+	assert.Equal(t, []api.InstanceSnapshotDiffEntry{
+		{Path: "/rootfs/etc/hostname", Change: "removed"},
+		{Path: "/rootfs/etc/hosts", Change: "changed"},
+		{Path: "/rootfs/etc/passwd", Change: "changed"},
+		{Path: "/rootfs/srv/new dir", Change: "added"},
+		{Path: "/rootfs/srv/new dir/file", Change: "added"},
+		{Path: "/rootfs/tmp/a", Change: "removed"},
+		{Path: "/rootfs/tmp/b", Change: "added"},
+	}, diff)
+}
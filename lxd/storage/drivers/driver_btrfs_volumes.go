@@ -1,7 +1,6 @@
 package drivers
 
 import (
-	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -14,7 +13,9 @@ import (
 	"github.com/lxc/lxd/lxd/migration"
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/revert"
+	"github.com/lxc/lxd/lxd/tools"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/instancewriter"
 	"github.com/lxc/lxd/shared/ioprogress"
 	log "github.com/lxc/lxd/shared/log15"
@@ -30,7 +31,7 @@ func (d *btrfs) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Op
 	defer revert.Fail()
 
 	// Create the volume itself.
-	_, err := shared.RunCommand("btrfs", "subvolume", "create", volPath)
+	_, err := tools.RunCommand("btrfs", "subvolume", "create", volPath)
 	if err != nil {
 		return err
 	}
@@ -88,7 +89,7 @@ func (d *btrfs) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Op
 
 	// Attempt to mark image read-only.
 	if vol.volType == VolumeTypeImage {
-		_, err = shared.RunCommand("btrfs", "property", "set", volPath, "ro", "true")
+		_, err = tools.RunCommand("btrfs", "property", "set", volPath, "ro", "true")
 		if err != nil && !d.state.OS.RunningInUserNS {
 			return err
 		}
@@ -130,7 +131,7 @@ func (d *btrfs) CreateVolumeFromBackup(vol Volume, snapshots []string, srcData i
 	// Define function to unpack a volume from a backup tarball file.
 	unpackVolume := func(r io.ReadSeeker, unpacker []string, srcFile string, mountPath string) error {
 		d.Logger().Debug("Unpacking optimized volume", log.Ctx{"source": srcFile, "target": mountPath})
-		tr, cancelFunc, err := shared.CompressedTarReader(context.Background(), r, unpacker)
+		tr, cancelFunc, err := shared.CompressedTarReader(op.Context(), r, unpacker)
 		if err != nil {
 			return err
 		}
@@ -147,7 +148,7 @@ func (d *btrfs) CreateVolumeFromBackup(vol Volume, snapshots []string, srcData i
 
 			if hdr.Name == srcFile {
 				// Extract the backup.
-				err = shared.RunCommandWithFds(tr, nil, "btrfs", "receive", "-e", mountPath)
+				err = tools.RunWithFds(op.Context(), tr, nil, "btrfs", "receive", "-e", mountPath)
 				if err != nil {
 					return err
 				}
@@ -312,7 +313,7 @@ func (d *btrfs) CreateVolumeFromMigration(vol Volume, conn io.ReadWriteCloser, v
 			fullSnapshotName := GetSnapshotVolumeName(vol.name, snapName)
 			wrapper := migration.ProgressWriter(op, "fs_progress", fullSnapshotName)
 
-			err = d.receiveSubvolume(snapshotsDir, snapshotsDir, conn, wrapper)
+			err = d.receiveSubvolume(snapshotsDir, snapshotsDir, conn, wrapper, op)
 			if err != nil {
 				return err
 			}
@@ -335,7 +336,7 @@ func (d *btrfs) CreateVolumeFromMigration(vol Volume, conn io.ReadWriteCloser, v
 	}
 
 	wrapper := migration.ProgressWriter(op, "fs_progress", vol.name)
-	err = d.receiveSubvolume(tmpVolumesMountPoint, vol.MountPath(), conn, wrapper)
+	err = d.receiveSubvolume(tmpVolumesMountPoint, vol.MountPath(), conn, wrapper, op)
 	if err != nil {
 		return err
 	}
@@ -479,7 +480,7 @@ func (d *btrfs) SetVolumeQuota(vol Volume, size string, op *operations.Operation
 
 			path := GetPoolMountPath(d.name)
 
-			_, err = shared.RunCommand("btrfs", "quota", "enable", path)
+			_, err = tools.RunCommand("btrfs", "quota", "enable", path)
 			if err != nil {
 				return err
 			}
@@ -492,7 +493,7 @@ func (d *btrfs) SetVolumeQuota(vol Volume, size string, op *operations.Operation
 		if err == errBtrfsNoQGroup {
 			// Find the volume ID.
 			var output string
-			output, err = shared.RunCommand("btrfs", "subvolume", "show", volPath)
+			output, err = tools.RunCommand("btrfs", "subvolume", "show", volPath)
 			if err != nil {
 				return errors.Wrap(err, "Failed to get subvol information")
 			}
@@ -511,7 +512,7 @@ func (d *btrfs) SetVolumeQuota(vol Volume, size string, op *operations.Operation
 			}
 
 			// Create a qgroup.
-			_, err = shared.RunCommand("btrfs", "qgroup", "create", fmt.Sprintf("0/%s", id), volPath)
+			_, err = tools.RunCommand("btrfs", "qgroup", "create", fmt.Sprintf("0/%s", id), volPath)
 			if err != nil {
 				return err
 			}
@@ -528,13 +529,13 @@ func (d *btrfs) SetVolumeQuota(vol Volume, size string, op *operations.Operation
 	// Modify the limit.
 	if sizeBytes > 0 {
 		// Apply the limit.
-		_, err := shared.RunCommand("btrfs", "qgroup", "limit", fmt.Sprintf("%d", sizeBytes), volPath)
+		_, err := tools.RunCommand("btrfs", "qgroup", "limit", fmt.Sprintf("%d", sizeBytes), volPath)
 		if err != nil {
 			return err
 		}
 	} else if qgroup != "" {
 		// Remove the limit.
-		_, err := shared.RunCommand("btrfs", "qgroup", "limit", "none", qgroup, volPath)
+		_, err := tools.RunCommand("btrfs", "qgroup", "limit", "none", qgroup, volPath)
 		if err != nil {
 			return err
 		}
@@ -548,6 +549,54 @@ func (d *btrfs) GetVolumeDiskPath(vol Volume) (string, error) {
 	return genericVFSGetVolumeDiskPath(vol)
 }
 
+// DiffVolumes lists the paths which differ between two volume snapshots by dumping an incremental send
+// stream without data. The volume itself isn't read-only and so can't be sent.
+func (d *btrfs) DiffVolumes(snapVol Volume, targetVol Volume, op *operations.Operation) ([]api.InstanceSnapshotDiffEntry, error) {
+	if snapVol.contentType != ContentTypeFS || !snapVol.IsSnapshot() || !targetVol.IsSnapshot() {
+		return nil, ErrNotSupported
+	}
+
+	// Nested subvolumes aren't part of the send stream.
+	for _, vol := range []Volume{snapVol, targetVol} {
+		subvols, err := d.getSubvolumes(vol.MountPath())
+		if err != nil {
+			return nil, err
+		}
+
+		if len(subvols) > 0 {
+			return nil, ErrNotSupported
+		}
+	}
+
+	// Both commands get killed along with the operation.
+	sender := tools.Command(op.Context(), "btrfs", "send", "-q", "--no-data", "-p", snapVol.MountPath(), targetVol.MountPath())
+	stream, err := sender.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = sender.Start()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to send the snapshot")
+	}
+
+	receiver := tools.Command(op.Context(), "btrfs", "receive", "--dump")
+	receiver.Stdin = stream
+	dump, err := receiver.Output()
+	if err != nil {
+		sender.Process.Kill()
+		sender.Wait()
+		return nil, errors.Wrap(err, "Failed to dump the send stream")
+	}
+
+	err = sender.Wait()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to send the snapshot")
+	}
+
+	return btrfsParseDump(string(dump)), nil
+}
+
 // MountVolume simulates mounting a volume.
 func (d *btrfs) MountVolume(vol Volume, op *operations.Operation) (bool, error) {
 	err := vol.EnsureMountPath()
@@ -600,7 +649,7 @@ func (d *btrfs) MigrateVolume(vol Volume, conn io.ReadWriteCloser, volSrcArgs *m
 		}
 
 		// Send snapshot to recipient (ensure local snapshot volume is mounted if needed).
-		err := d.sendSubvolume(snapshot.MountPath(), parentSnapshotPath, conn, wrapper)
+		err := d.sendSubvolume(snapshot.MountPath(), parentSnapshotPath, conn, wrapper, op)
 		if err != nil {
 			return err
 		}
@@ -642,7 +691,7 @@ func (d *btrfs) MigrateVolume(vol Volume, conn io.ReadWriteCloser, volSrcArgs *m
 	}
 
 	// Send the volume itself.
-	err = d.sendSubvolume(migrationSendSnapshot, btrfsParent, conn, wrapper)
+	err = d.sendSubvolume(migrationSendSnapshot, btrfsParent, conn, wrapper, op)
 	if err != nil {
 		return err
 	}
@@ -709,7 +758,7 @@ func (d *btrfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWr
 
 		// Write the subvolume to the file.
 		d.logger.Debug("Generating optimized volume file", log.Ctx{"sourcePath": path, "file": tmpFile.Name(), "name": fileName})
-		err = shared.RunCommandWithFds(nil, tmpFile, "btrfs", args...)
+		err = tools.RunWithFds(op.Context(), nil, tmpFile, "btrfs", args...)
 		if err != nil {
 			return err
 		}
@@ -9,8 +9,10 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/lxc/lxd/lxd/migration"
+	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	log "github.com/lxc/lxd/shared/log15"
 	"github.com/lxc/lxd/shared/logger"
 )
@@ -183,6 +185,11 @@ func (d *common) ApplyPatch(name string) error {
 	return patch()
 }
 
+// DiffVolumes isn't supported by default, callers fall back to comparing the mounted volumes.
+func (d *common) DiffVolumes(snapVol Volume, targetVol Volume, op *operations.Operation) ([]api.InstanceSnapshotDiffEntry, error) {
+	return nil, ErrNotSupported
+}
+
 // moveGPTAltHeader moves the GPT alternative header to the end of the disk device supplied.
 // If the device supplied is not detected as not being a GPT disk then no action is taken and nil is returned.
 // If the required sgdisk command is not available a warning is logged, but no error is returned, as really it is
@@ -346,7 +346,7 @@ func (d *dir) CreateVolumeSnapshot(snapVol Volume, op *operations.Operation) err
 	bwlimit := d.config["rsync.bwlimit"]
 
 	// Copy volume into snapshot directory.
-	_, err = rsync.LocalCopy(srcPath, snapPath, bwlimit, true)
+	_, err = rsync.LocalCopyContext(op.Context(), srcPath, snapPath, bwlimit, true)
 	if err != nil {
 		return err
 	}
@@ -410,7 +410,7 @@ func (d *dir) RestoreVolume(vol Volume, snapshotName string, op *operations.Oper
 
 	// Restore using rsync.
 	bwlimit := d.config["rsync.bwlimit"]
-	_, err := rsync.LocalCopy(srcPath, volPath, bwlimit, true)
+	_, err := rsync.LocalCopyContext(op.Context(), srcPath, volPath, bwlimit, true)
 	if err != nil {
 		return errors.Wrap(err, "Failed to rsync volume")
 	}
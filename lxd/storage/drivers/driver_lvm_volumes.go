@@ -907,7 +907,7 @@ func (d *lvm) RestoreVolume(vol Volume, snapshotName string, op *operations.Oper
 		// Copy source to destination (mounting each volume if needed).
 		err = snapVol.MountTask(func(srcMountPath string, op *operations.Operation) error {
 			bwlimit := d.config["rsync.bwlimit"]
-			_, err := rsync.LocalCopy(srcMountPath, mountPath, bwlimit, true)
+			_, err := rsync.LocalCopyContext(op.Context(), srcMountPath, mountPath, bwlimit, true)
 			return err
 		}, op)
 		if err != nil {
@@ -0,0 +1,119 @@
+package drivers
+
+import (
+	"os"
+
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// storagePlugin is a driver implemented by an external executable, allowing storage backends to
+// be shipped separately from LXD. The executable is called with the action as its only argument
+// and a JSON request on its standard input, and is responsible for mounting the pool and its
+// filesystem volumes at the paths LXD passes to it.
+type storagePlugin struct {
+	common
+
+	plugin string
+	info   pluginResponse
+}
+
+// load is used to run one-time action per-driver rather than per-pool.
+// The plugin is only asked for its info again when its executable changes.
+func (d *storagePlugin) load() error {
+	fi, err := os.Stat(PluginPath(d.plugin))
+	if err != nil {
+		return err
+	}
+
+	pluginInfoCacheMu.Lock()
+	defer pluginInfoCacheMu.Unlock()
+
+	entry, ok := pluginInfoCache[d.plugin]
+	if ok && entry.modTime.Equal(fi.ModTime()) && entry.size == fi.Size() {
+		d.info = entry.info
+		return nil
+	}
+
+	resp, err := d.run("info", nil, pluginRequest{})
+	if err != nil {
+		return err
+	}
+
+	pluginInfoCache[d.plugin] = pluginInfoCacheEntry{modTime: fi.ModTime(), size: fi.Size(), info: *resp}
+	d.info = *resp
+	return nil
+}
+
+// Info returns info about the driver and its environment.
+func (d *storagePlugin) Info() Info {
+	return Info{
+		Name:                  d.plugin,
+		Version:               d.info.Version,
+		OptimizedImages:       false,
+		PreservesInodes:       false,
+		Remote:                d.info.Remote,
+		VolumeTypes:           []VolumeType{VolumeTypeCustom, VolumeTypeImage, VolumeTypeContainer},
+		BlockBacking:          false,
+		RunningQuotaResize:    pluginFlag(d.info.RunningQuotaResize, false),
+		RunningSnapshotFreeze: pluginFlag(d.info.RunningSnapshotFreeze, true),
+		DirectIO:              pluginFlag(d.info.DirectIO, false),
+		MountedRoot:           false,
+	}
+}
+
+// Create is called during pool creation and is effectively using an empty driver struct.
+// WARNING: The Create() function cannot rely on any of the struct attributes being set.
+func (d *storagePlugin) Create() error {
+	_, err := d.run("create", nil, pluginRequest{})
+	return err
+}
+
+// Delete removes the storage pool from the storage device.
+func (d *storagePlugin) Delete(op *operations.Operation) error {
+	_, err := d.Unmount()
+	if err != nil {
+		return err
+	}
+
+	_, err = d.run("delete", nil, pluginRequest{})
+	return err
+}
+
+// Validate checks that all provide keys are supported and that no conflicting or missing configuration is present.
+// Driver specific keys are validated by the plugin itself.
+func (d *storagePlugin) Validate(config map[string]string) error {
+	_, err := d.run("validate", nil, pluginRequest{Config: config})
+	return err
+}
+
+// Update applies any driver changes required from a configuration change.
+func (d *storagePlugin) Update(changedConfig map[string]string) error {
+	_, err := d.run("update", nil, pluginRequest{Config: changedConfig})
+	return err
+}
+
+// Mount mounts the storage pool.
+func (d *storagePlugin) Mount() (bool, error) {
+	resp, err := d.run("mount", nil, pluginRequest{})
+	if err != nil {
+		return false, err
+	}
+
+	return resp.Changed, nil
+}
+
+// Unmount unmounts the storage pool.
+func (d *storagePlugin) Unmount() (bool, error) {
+	resp, err := d.run("unmount", nil, pluginRequest{})
+	if err != nil {
+		return false, err
+	}
+
+	return resp.Changed, nil
+}
+
+// GetResources returns the pool resource usage information.
+func (d *storagePlugin) GetResources() (*api.ResourcesStoragePool, error) {
+	return genericVFSGetResources(d)
+}
@@ -0,0 +1,182 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// How long a storage plugin may take to answer a request.
+const pluginTimeout = 10 * time.Minute
+
+// Request sent to storage plugins on stdin.
+type pluginRequest struct {
+	Action string            `json:"action"`
+	Pool   pluginRequestPool `json:"pool"`
+
+	// Only set for volume actions.
+	Volume *pluginRequestVolume `json:"volume,omitempty"`
+
+	// Action specific arguments.
+	Config  map[string]string `json:"config,omitempty"`
+	NewName string            `json:"new_name,omitempty"`
+	Size    string            `json:"size,omitempty"`
+}
+
+// Storage pool details passed to storage plugins.
+type pluginRequestPool struct {
+	Name   string            `json:"name"`
+	Config map[string]string `json:"config"`
+	Path   string            `json:"path"`
+}
+
+// Storage volume details passed to storage plugins.
+type pluginRequestVolume struct {
+	Name        string            `json:"name"`
+	Type        string            `json:"type"`
+	ContentType string            `json:"content_type"`
+	Config      map[string]string `json:"config"`
+	Path        string            `json:"path"`
+	Snapshot    bool              `json:"snapshot"`
+}
+
+// Response of storage plugins on stdout.
+type pluginResponse struct {
+	// Answer to "info". Unset flags get the most conservative value.
+	Version               string `json:"version"`
+	Remote                bool   `json:"remote"`
+	RunningQuotaResize    *bool  `json:"running_quota_resize"`
+	RunningSnapshotFreeze *bool  `json:"running_snapshot_freeze"`
+	DirectIO              *bool  `json:"direct_io"`
+
+	// Answer to "mount", "unmount", "mount_volume" and "unmount_volume".
+	Changed bool `json:"changed"`
+
+	// Answer to "usage".
+	Usage int64 `json:"usage"`
+}
+
+// pluginInfoCache holds the answers of the storage plugins to "info", so that they're only run again
+// when their executable changes.
+var pluginInfoCache = map[string]pluginInfoCacheEntry{}
+var pluginInfoCacheMu sync.Mutex
+
+type pluginInfoCacheEntry struct {
+	modTime time.Time
+	size    int64
+	info    pluginResponse
+}
+
+// pluginFlag returns the value of a flag of the plugin info, or the given default if unset.
+func pluginFlag(value *bool, defaultValue bool) bool {
+	if value == nil {
+		return defaultValue
+	}
+
+	return *value
+}
+
+// PluginPath returns the path of the executable implementing the given storage plugin.
+func PluginPath(name string) string {
+	return shared.VarPath("plugins", "storage", name)
+}
+
+// IsPlugin returns whether the given storage driver is implemented by an installed plugin.
+func IsPlugin(name string) bool {
+	_, ok := drivers[name]
+	if ok || name == "" || strings.Contains(name, "/") || shared.StringInSlice(name, []string{".", ".."}) {
+		return false
+	}
+
+	fi, err := os.Stat(PluginPath(name))
+	if err != nil {
+		return false
+	}
+
+	return !fi.IsDir() && fi.Mode()&0111 != 0
+}
+
+// pluginNames returns the names of the installed storage plugins.
+func pluginNames() []string {
+	names := []string{}
+
+	ents, err := ioutil.ReadDir(shared.VarPath("plugins", "storage"))
+	if err != nil {
+		return names
+	}
+
+	for _, ent := range ents {
+		if IsPlugin(ent.Name()) {
+			names = append(names, ent.Name())
+		}
+	}
+
+	return names
+}
+
+// run calls the plugin for the given action and returns its parsed answer. The plugin reports
+// failures by exiting with a non-zero status, its standard error being used as the error message.
+func (d *storagePlugin) run(action string, vol *Volume, req pluginRequest) (*pluginResponse, error) {
+	req.Action = action
+	req.Pool = pluginRequestPool{
+		Name:   d.name,
+		Config: d.config,
+		Path:   GetPoolMountPath(d.name),
+	}
+
+	if vol != nil {
+		req.Volume = &pluginRequestVolume{
+			Name:        vol.name,
+			Type:        string(vol.volType),
+			ContentType: string(vol.contentType),
+			Config:      vol.config,
+			Path:        vol.MountPath(),
+			Snapshot:    vol.IsSnapshot(),
+		}
+	}
+
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pluginTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, PluginPath(d.plugin), action)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+
+		return nil, fmt.Errorf("Storage plugin %q failed to %s: %s", d.plugin, strings.Replace(action, "_", " ", -1), msg)
+	}
+
+	resp := pluginResponse{}
+	if len(bytes.TrimSpace(stdout.Bytes())) > 0 {
+		err = json.Unmarshal(stdout.Bytes(), &resp)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid answer from storage plugin %q", d.plugin)
+		}
+	}
+
+	return &resp, nil
+}
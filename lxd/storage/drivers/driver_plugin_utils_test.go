@@ -0,0 +1,120 @@
+package drivers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Set up a storage plugins directory under a temporary LXD_DIR, returning a function writing
+// plugins to it and a cleanup function.
+func setupTestPlugins(t *testing.T) (func(name string, script string, mode os.FileMode), func()) {
+	dir, err := ioutil.TempDir("", "lxd_storage_plugins_")
+	require.NoError(t, err)
+
+	oldDir := os.Getenv("LXD_DIR")
+	os.Setenv("LXD_DIR", dir)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "plugins", "storage"), 0755))
+
+	pluginInfoCacheMu.Lock()
+	pluginInfoCache = map[string]pluginInfoCacheEntry{}
+	pluginInfoCacheMu.Unlock()
+
+	write := func(name string, script string, mode os.FileMode) {
+		path := filepath.Join(dir, "plugins", "storage", name)
+		require.NoError(t, ioutil.WriteFile(path+".tmp", []byte(script), mode))
+		require.NoError(t, os.Rename(path+".tmp", path))
+	}
+
+	cleanup := func() {
+		os.Setenv("LXD_DIR", oldDir)
+		os.RemoveAll(dir)
+	}
+
+	return write, cleanup
+}
+
+// Only executable files which don't shadow a built-in driver are plugins.
+func TestIsPlugin(t *testing.T) {
+	write, cleanup := setupTestPlugins(t)
+	defer cleanup()
+
+	write("fake", "#!/bin/sh\n", 0755)
+	write("notexec", "#!/bin/sh\n", 0644)
+	write("dir", "#!/bin/sh\n", 0755)
+	require.NoError(t, os.Mkdir(PluginPath("subdir"), 0755))
+
+	assert.True(t, IsPlugin("fake"))
+	assert.False(t, IsPlugin("notexec"))
+	assert.False(t, IsPlugin("dir"))
+	assert.False(t, IsPlugin("subdir"))
+	assert.False(t, IsPlugin("missing"))
+	assert.False(t, IsPlugin(""))
+	assert.False(t, IsPlugin("../fake"))
+
+	assert.Equal(t, []string{"fake"}, pluginNames())
+}
+
+// The answer of the plugin is parsed, and its standard error reported on failure.
+func TestStoragePluginRun(t *testing.T) {
+	write, cleanup := setupTestPlugins(t)
+	defer cleanup()
+
+	d := &storagePlugin{common: common{name: "pool1", config: map[string]string{}}, plugin: "fake"}
+
+	write("fake", "#!/bin/sh\n[ \"$1\" = mount ] && grep -q '\"name\":\"pool1\"' && echo '{\"changed\": true}'\n", 0755)
+	changed, err := d.Mount()
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	write("fake", "#!/bin/sh\necho 'No such device' >&2\nexit 1\n", 0755)
+	_, err = d.run("mount_volume", nil, pluginRequest{})
+	assert.EqualError(t, err, `Storage plugin "fake" failed to mount volume: No such device`)
+
+	write("fake", "#!/bin/sh\necho 'not json'\n", 0755)
+	_, err = d.run("usage", nil, pluginRequest{})
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), `Invalid answer from storage plugin "fake"`))
+}
+
+// The info is cached until the executable changes, unset flags getting conservative values.
+func TestStoragePluginLoad(t *testing.T) {
+	write, cleanup := setupTestPlugins(t)
+	defer cleanup()
+
+	counter := PluginPath("calls")
+	write("fake", "#!/bin/sh\necho x >> "+counter+"\necho '{\"version\": \"1\"}'\n", 0755)
+
+	for i := 0; i < 2; i++ {
+		d := &storagePlugin{plugin: "fake"}
+		require.NoError(t, d.load())
+
+		info := d.Info()
+		assert.Equal(t, "1", info.Version)
+		assert.False(t, info.RunningQuotaResize)
+		assert.True(t, info.RunningSnapshotFreeze)
+		assert.False(t, info.DirectIO)
+	}
+
+	calls, err := ioutil.ReadFile(counter)
+	require.NoError(t, err)
+	assert.Equal(t, "x\n", string(calls))
+
+	write("fake", "#!/bin/sh\necho x >> "+counter+"\necho '{\"version\": \"2\", \"direct_io\": true}'\n", 0755)
+
+	d := &storagePlugin{plugin: "fake"}
+	require.NoError(t, d.load())
+
+	info := d.Info()
+	assert.Equal(t, "2", info.Version)
+	assert.True(t, info.DirectIO)
+
+	calls, err = ioutil.ReadFile(counter)
+	require.NoError(t, err)
+	assert.Equal(t, "x\nx\n", string(calls))
+}
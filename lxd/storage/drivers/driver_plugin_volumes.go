@@ -0,0 +1,303 @@
+package drivers
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lxc/lxd/lxd/migration"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/revert"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/instancewriter"
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// CreateVolume creates an empty volume and can optionally fill it by executing the supplied
+// filler function.
+func (d *storagePlugin) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Operation) error {
+	if vol.contentType != ContentTypeFS {
+		return ErrNotSupported
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	volPath := vol.MountPath()
+	err := vol.EnsureMountPath()
+	if err != nil {
+		return err
+	}
+	revert.Add(func() { os.RemoveAll(volPath) })
+
+	_, err = d.run("create_volume", &vol, pluginRequest{Size: vol.ExpandedConfig("size")})
+	if err != nil {
+		return err
+	}
+	revert.Add(func() { d.run("delete_volume", &vol, pluginRequest{}) })
+
+	err = vol.MountTask(func(mountPath string, op *operations.Operation) error {
+		if filler != nil && filler.Fill != nil {
+			d.logger.Debug("Running filler function", log.Ctx{"path": volPath})
+			err := filler.Fill(mountPath, "")
+			if err != nil {
+				return err
+			}
+		}
+
+		// Run EnsureMountPath again after mounting and filling to ensure the mount directory has
+		// the correct permissions set.
+		return vol.EnsureMountPath()
+	}, op)
+	if err != nil {
+		return err
+	}
+
+	revert.Success()
+	return nil
+}
+
+// CreateVolumeFromBackup restores a backup tarball onto the storage device.
+func (d *storagePlugin) CreateVolumeFromBackup(vol Volume, snapshots []string, srcData io.ReadSeeker, optimizedStorage bool, op *operations.Operation) (func(vol Volume) error, func(), error) {
+	return genericVFSBackupUnpack(d, vol, snapshots, srcData, op)
+}
+
+// CreateVolumeFromCopy provides same-pool volume copying functionality.
+func (d *storagePlugin) CreateVolumeFromCopy(vol Volume, srcVol Volume, copySnapshots bool, op *operations.Operation) error {
+	var err error
+	var srcSnapshots []Volume
+
+	if copySnapshots && !srcVol.IsSnapshot() {
+		// Get the list of snapshots from the source.
+		srcSnapshots, err = srcVol.Snapshots(op)
+		if err != nil {
+			return err
+		}
+	}
+
+	return genericVFSCopyVolume(d, nil, vol, srcVol, srcSnapshots, false, op)
+}
+
+// CreateVolumeFromMigration creates a volume being sent via a migration.
+func (d *storagePlugin) CreateVolumeFromMigration(vol Volume, conn io.ReadWriteCloser, volTargetArgs migration.VolumeTargetArgs, preFiller *VolumeFiller, op *operations.Operation) error {
+	return genericVFSCreateVolumeFromMigration(d, nil, vol, conn, volTargetArgs, preFiller, op)
+}
+
+// RefreshVolume provides same-pool volume and specific snapshots syncing functionality.
+func (d *storagePlugin) RefreshVolume(vol Volume, srcVol Volume, srcSnapshots []Volume, op *operations.Operation) error {
+	return genericVFSCopyVolume(d, nil, vol, srcVol, srcSnapshots, true, op)
+}
+
+// DeleteVolume deletes a volume of the storage device. If any snapshots of the volume remain then
+// this function will return an error.
+func (d *storagePlugin) DeleteVolume(vol Volume, op *operations.Operation) error {
+	snapshots, err := d.VolumeSnapshots(vol, op)
+	if err != nil {
+		return err
+	}
+
+	if len(snapshots) > 0 {
+		return fmt.Errorf("Cannot remove a volume that has snapshots")
+	}
+
+	_, err = d.UnmountVolume(vol, op)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.run("delete_volume", &vol, pluginRequest{})
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(vol.MountPath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return deleteParentSnapshotDirIfEmpty(d.name, vol.volType, vol.name)
+}
+
+// HasVolume indicates whether a specific volume exists on the storage pool.
+func (d *storagePlugin) HasVolume(vol Volume) bool {
+	return genericVFSHasVolume(vol)
+}
+
+// ValidateVolume validates the supplied volume config. Optionally removes invalid keys from the volume's config.
+func (d *storagePlugin) ValidateVolume(vol Volume, removeUnknownKeys bool) error {
+	return d.validateVolume(vol, nil, removeUnknownKeys)
+}
+
+// UpdateVolume applies config changes to the volume.
+func (d *storagePlugin) UpdateVolume(vol Volume, changedConfig map[string]string) error {
+	if _, changed := changedConfig["size"]; changed {
+		err := d.SetVolumeQuota(vol, changedConfig["size"], nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetVolumeUsage returns the disk space used by the volume.
+func (d *storagePlugin) GetVolumeUsage(vol Volume) (int64, error) {
+	resp, err := d.run("usage", &vol, pluginRequest{})
+	if err != nil {
+		return -1, err
+	}
+
+	if resp.Usage < 0 {
+		return -1, ErrNotSupported
+	}
+
+	return resp.Usage, nil
+}
+
+// SetVolumeQuota sets the quota on the volume.
+func (d *storagePlugin) SetVolumeQuota(vol Volume, size string, op *operations.Operation) error {
+	_, err := d.run("set_quota", &vol, pluginRequest{Size: size})
+	return err
+}
+
+// GetVolumeDiskPath returns the location of a disk volume.
+func (d *storagePlugin) GetVolumeDiskPath(vol Volume) (string, error) {
+	return "", ErrNotSupported
+}
+
+// MountVolume asks the plugin to mount the volume at its mount path.
+func (d *storagePlugin) MountVolume(vol Volume, op *operations.Operation) (bool, error) {
+	err := vol.EnsureMountPath()
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := d.run("mount_volume", &vol, pluginRequest{})
+	if err != nil {
+		return false, err
+	}
+
+	return resp.Changed, nil
+}
+
+// UnmountVolume asks the plugin to unmount the volume.
+func (d *storagePlugin) UnmountVolume(vol Volume, op *operations.Operation) (bool, error) {
+	resp, err := d.run("unmount_volume", &vol, pluginRequest{})
+	if err != nil {
+		return false, err
+	}
+
+	return resp.Changed, nil
+}
+
+// RenameVolume renames a volume and its snapshots.
+func (d *storagePlugin) RenameVolume(vol Volume, newVolName string, op *operations.Operation) error {
+	_, err := d.run("rename_volume", &vol, pluginRequest{NewName: newVolName})
+	if err != nil {
+		return err
+	}
+
+	err = genericVFSRenameVolume(d, vol, newVolName, op)
+	if err != nil {
+		newVol := NewVolume(d, d.name, vol.volType, vol.contentType, newVolName, vol.config, vol.poolConfig)
+		d.run("rename_volume", &newVol, pluginRequest{NewName: vol.name})
+		return err
+	}
+
+	return nil
+}
+
+// MigrateVolume sends a volume for migration.
+func (d *storagePlugin) MigrateVolume(vol Volume, conn io.ReadWriteCloser, volSrcArgs *migration.VolumeSourceArgs, op *operations.Operation) error {
+	return genericVFSMigrateVolume(d, d.state, vol, conn, volSrcArgs, op)
+}
+
+// BackupVolume copies a volume (and optionally its snapshots) to a specified target path.
+// This driver does not support optimized backups.
+func (d *storagePlugin) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWriter, optimized bool, snapshots bool, op *operations.Operation) error {
+	return genericVFSBackupVolume(d, vol, tarWriter, snapshots, op)
+}
+
+// CreateVolumeSnapshot creates a snapshot of a volume.
+func (d *storagePlugin) CreateVolumeSnapshot(snapVol Volume, op *operations.Operation) error {
+	parentName, _, _ := shared.InstanceGetParentAndSnapshotName(snapVol.name)
+
+	// Create the parent directory.
+	err := createParentSnapshotDirIfMissing(d.name, snapVol.volType, parentName)
+	if err != nil {
+		return err
+	}
+
+	// Create snapshot directory.
+	err = snapVol.EnsureMountPath()
+	if err != nil {
+		return err
+	}
+
+	_, err = d.run("create_snapshot", &snapVol, pluginRequest{})
+	if err != nil {
+		os.Remove(snapVol.MountPath())
+		return err
+	}
+
+	return nil
+}
+
+// DeleteVolumeSnapshot removes a snapshot from the storage device.
+func (d *storagePlugin) DeleteVolumeSnapshot(snapVol Volume, op *operations.Operation) error {
+	_, err := d.UnmountVolumeSnapshot(snapVol, op)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.run("delete_snapshot", &snapVol, pluginRequest{})
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(snapVol.MountPath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	parentName, _, _ := shared.InstanceGetParentAndSnapshotName(snapVol.name)
+
+	// Remove the parent snapshot directory if this is the last snapshot being removed.
+	return deleteParentSnapshotDirIfEmpty(d.name, snapVol.volType, parentName)
+}
+
+// MountVolumeSnapshot asks the plugin to mount the snapshot read-only at its mount path.
+func (d *storagePlugin) MountVolumeSnapshot(snapVol Volume, op *operations.Operation) (bool, error) {
+	return d.MountVolume(snapVol, op)
+}
+
+// UnmountVolumeSnapshot asks the plugin to unmount the snapshot.
+func (d *storagePlugin) UnmountVolumeSnapshot(snapVol Volume, op *operations.Operation) (bool, error) {
+	return d.UnmountVolume(snapVol, op)
+}
+
+// VolumeSnapshots returns a list of snapshots for the volume.
+func (d *storagePlugin) VolumeSnapshots(vol Volume, op *operations.Operation) ([]string, error) {
+	return genericVFSVolumeSnapshots(d, vol, op)
+}
+
+// RestoreVolume restores a volume from a snapshot.
+func (d *storagePlugin) RestoreVolume(vol Volume, snapshotName string, op *operations.Operation) error {
+	snapVol, err := vol.NewSnapshot(snapshotName)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.run("restore_volume", &snapVol, pluginRequest{})
+	return err
+}
+
+// RenameVolumeSnapshot renames a volume snapshot.
+func (d *storagePlugin) RenameVolumeSnapshot(snapVol Volume, newSnapshotName string, op *operations.Operation) error {
+	_, err := d.run("rename_snapshot", &snapVol, pluginRequest{NewName: newSnapshotName})
+	if err != nil {
+		return err
+	}
+
+	return genericVFSRenameVolumeSnapshot(d, snapVol, newSnapshotName, op)
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/lxc/lxd/lxd/migration"
 	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/tools"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -149,7 +150,7 @@ func (d *zfs) Create() error {
 		}
 
 		// Create the zpool.
-		_, err = shared.RunCommand("zpool", "create", "-f", "-m", "none", "-O", "compression=on", d.config["zfs.pool_name"], loopPath)
+		_, err = tools.RunCommand("zpool", "create", "-f", "-m", "none", "-O", "compression=on", d.config["zfs.pool_name"], loopPath)
 		if err != nil {
 			return err
 		}
@@ -173,7 +174,7 @@ func (d *zfs) Create() error {
 		}
 
 		// Create the zpool.
-		_, err := shared.RunCommand("zpool", "create", "-f", "-m", "none", "-O", "compression=on", d.config["zfs.pool_name"], d.config["source"])
+		_, err := tools.RunCommand("zpool", "create", "-f", "-m", "none", "-O", "compression=on", d.config["zfs.pool_name"], d.config["source"])
 		if err != nil {
 			return err
 		}
@@ -291,13 +292,13 @@ func (d *zfs) Delete(op *operations.Operation) error {
 
 	if strings.Contains(d.config["zfs.pool_name"], "/") {
 		// Delete the dataset.
-		_, err := shared.RunCommand("zfs", "destroy", "-r", d.config["zfs.pool_name"])
+		_, err := tools.RunCommand("zfs", "destroy", "-r", d.config["zfs.pool_name"])
 		if err != nil {
 			return err
 		}
 	} else {
 		// Delete the pool.
-		_, err := shared.RunCommand("zpool", "destroy", d.config["zfs.pool_name"])
+		_, err := tools.RunCommand("zpool", "destroy", d.config["zfs.pool_name"])
 		if err != nil {
 			return err
 		}
@@ -357,12 +358,12 @@ func (d *zfs) Mount() (bool, error) {
 	// Import the pool.
 	if filepath.IsAbs(d.config["source"]) {
 		disksPath := shared.VarPath("disks")
-		_, err := shared.RunCommand("zpool", "import", "-f", "-d", disksPath, poolName)
+		_, err := tools.RunCommand("zpool", "import", "-f", "-d", disksPath, poolName)
 		if err != nil {
 			return false, err
 		}
 	} else {
-		_, err := shared.RunCommand("zpool", "import", poolName)
+		_, err := tools.RunCommand("zpool", "import", poolName)
 		if err != nil {
 			return false, err
 		}
@@ -390,7 +391,7 @@ func (d *zfs) Unmount() (bool, error) {
 
 	// Export the pool.
 	poolName := strings.Split(d.config["zfs.pool_name"], "/")[0]
-	_, err := shared.RunCommand("zpool", "export", poolName)
+	_, err := tools.RunCommand("zpool", "export", poolName)
 	if err != nil {
 		return false, err
 	}
@@ -4,14 +4,18 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/pborman/uuid"
 
 	"github.com/lxc/lxd/lxd/migration"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/tools"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/ioprogress"
 )
 
@@ -46,7 +50,7 @@ func (d *zfs) createDataset(dataset string, options ...string) error {
 	}
 	args = append(args, dataset)
 
-	_, err := shared.RunCommand("zfs", args...)
+	_, err := tools.RunCommand("zfs", args...)
 	if err != nil {
 		return err
 	}
@@ -64,7 +68,7 @@ func (d *zfs) createVolume(dataset string, size int64, options ...string) error
 	}
 	args = append(args, dataset)
 
-	_, err := shared.RunCommand("zfs", args...)
+	_, err := tools.RunCommand("zfs", args...)
 	if err != nil {
 		return err
 	}
@@ -73,7 +77,7 @@ func (d *zfs) createVolume(dataset string, size int64, options ...string) error
 }
 
 func (d *zfs) checkDataset(dataset string) bool {
-	out, err := shared.RunCommand("zfs", "get", "-H", "-o", "name", "name", dataset)
+	out, err := tools.RunCommand("zfs", "get", "-H", "-o", "name", "name", dataset)
 	if err != nil {
 		return false
 	}
@@ -82,7 +86,7 @@ func (d *zfs) checkDataset(dataset string) bool {
 }
 
 func (d *zfs) getClones(dataset string) ([]string, error) {
-	out, err := shared.RunCommand("zfs", "get", "-H", "-p", "-r", "-o", "value", "clones", dataset)
+	out, err := tools.RunCommand("zfs", "get", "-H", "-p", "-r", "-o", "value", "clones", dataset)
 	if err != nil {
 		return nil, err
 	}
@@ -102,7 +106,7 @@ func (d *zfs) getClones(dataset string) ([]string, error) {
 }
 
 func (d *zfs) getDatasets(dataset string) ([]string, error) {
-	out, err := shared.RunCommand("zfs", "get", "-H", "-r", "-o", "name", "name", dataset)
+	out, err := tools.RunCommand("zfs", "get", "-H", "-r", "-o", "name", "name", dataset)
 	if err != nil {
 		return nil, err
 	}
@@ -126,7 +130,7 @@ func (d *zfs) setDatasetProperties(dataset string, options ...string) error {
 	if len(zfsVersion) >= 3 && zfsVersion[0:3] == "0.6" {
 		// Slow path for ZFS 0.6
 		for _, option := range options {
-			_, err := shared.RunCommand("zfs", "set", option, dataset)
+			_, err := tools.RunCommand("zfs", "set", option, dataset)
 			if err != nil {
 				return err
 			}
@@ -139,7 +143,7 @@ func (d *zfs) setDatasetProperties(dataset string, options ...string) error {
 	args = append(args, options...)
 	args = append(args, dataset)
 
-	_, err := shared.RunCommand("zfs", args...)
+	_, err := tools.RunCommand("zfs", args...)
 	if err != nil {
 		return err
 	}
@@ -148,7 +152,7 @@ func (d *zfs) setDatasetProperties(dataset string, options ...string) error {
 }
 
 func (d *zfs) getDatasetProperty(dataset string, key string) (string, error) {
-	output, err := shared.RunCommand("zfs", "get", "-H", "-p", "-o", "value", key, dataset)
+	output, err := tools.RunCommand("zfs", "get", "-H", "-p", "-o", "value", key, dataset)
 	if err != nil {
 		return "", err
 	}
@@ -195,7 +199,7 @@ func (d *zfs) initialDatasets() []string {
 	return entries
 }
 
-func (d *zfs) sendDataset(dataset string, parent string, volSrcArgs *migration.VolumeSourceArgs, conn io.ReadWriteCloser, tracker *ioprogress.ProgressTracker) error {
+func (d *zfs) sendDataset(dataset string, parent string, volSrcArgs *migration.VolumeSourceArgs, conn io.ReadWriteCloser, tracker *ioprogress.ProgressTracker, op *operations.Operation) error {
 	// Assemble zfs send command.
 	args := []string{"send"}
 	if shared.StringInSlice("compress", volSrcArgs.MigrationType.Features) {
@@ -206,7 +210,7 @@ func (d *zfs) sendDataset(dataset string, parent string, volSrcArgs *migration.V
 		args = append(args, "-i", parent)
 	}
 	args = append(args, dataset)
-	cmd := exec.Command("zfs", args...)
+	cmd := tools.Command(op.Context(), "zfs", args...)
 
 	// Prepare stdout/stderr.
 	stdout, err := cmd.StdoutPipe()
@@ -265,9 +269,9 @@ func (d *zfs) sendDataset(dataset string, parent string, volSrcArgs *migration.V
 	return nil
 }
 
-func (d *zfs) receiveDataset(dataset string, conn io.ReadWriteCloser, writeWrapper func(io.WriteCloser) io.WriteCloser) error {
+func (d *zfs) receiveDataset(dataset string, conn io.ReadWriteCloser, writeWrapper func(io.WriteCloser) io.WriteCloser, op *operations.Operation) error {
 	// Assemble zfs send command.
-	cmd := exec.Command("zfs", "receive", "-F", "-u", dataset)
+	cmd := tools.Command(op.Context(), "zfs", "receive", "-F", "-u", dataset)
 
 	// Prepare stdin/stderr.
 	stdin, err := cmd.StdinPipe()
@@ -316,3 +320,84 @@ func (d *zfs) receiveDataset(dataset string, conn io.ReadWriteCloser, writeWrapp
 
 	return nil
 }
+
+// zfsParseDiff converts the output of "zfs diff -H -F" into changes relative to mountPath. Renames are
+// reported as the removal of the old path and the addition of the new one. Directories modified because of
+// changes to their entries are skipped.
+func zfsParseDiff(output string, mountPath string) []api.InstanceSnapshotDiffEntry {
+	changes := map[string]string{}
+
+	relPath := func(path string) (string, bool) {
+		path = zfsDiffUnescape(path)
+		if path == mountPath {
+			return "/", true
+		}
+
+		if !strings.HasPrefix(path, mountPath+"/") {
+			return "", false
+		}
+
+		return strings.TrimPrefix(path, mountPath), true
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			continue
+		}
+
+		path, ok := relPath(fields[2])
+		if !ok {
+			continue
+		}
+
+		switch fields[0] {
+		case "+":
+			changes[path] = "added"
+		case "-":
+			changes[path] = "removed"
+		case "M":
+			if fields[1] != "/" {
+				changes[path] = "changed"
+			}
+		case "R":
+			changes[path] = "removed"
+
+			if len(fields) > 3 {
+				newPath, ok := relPath(fields[3])
+				if ok {
+					changes[newPath] = "added"
+				}
+			}
+		}
+	}
+
+	diff := make([]api.InstanceSnapshotDiffEntry, 0, len(changes))
+	for path, change := range changes {
+		diff = append(diff, api.InstanceSnapshotDiffEntry{Path: path, Change: change})
+	}
+
+	sort.Slice(diff, func(i, j int) bool { return diff[i].Path < diff[j].Path })
+
+	return diff
+}
+
+// zfsDiffUnescape decodes the "\NNNN" octal sequences used by "zfs diff" for spaces and special characters.
+func zfsDiffUnescape(path string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+4 < len(path) {
+			c, err := strconv.ParseUint(path[i+1:i+5], 8, 8)
+			if err == nil {
+				b.WriteByte(byte(c))
+				i += 4
+				continue
+			}
+		}
+
+		b.WriteByte(path[i])
+	}
+
+	return b.String()
+}
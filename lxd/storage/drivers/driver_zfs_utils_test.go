@@ -0,0 +1,27 @@
+package drivers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func Test_zfsParseDiff(t *testing.T) {
+	output := `M	/	/var/lib/lxd/storage-pools/zfs/containers/c1/rootfs/etc
+M	F	/var/lib/lxd/storage-pools/zfs/containers/c1/rootfs/etc/hosts
+-	F	/var/lib/lxd/storage-pools/zfs/containers/c1/rootfs/etc/hostname
++	F	/var/lib/lxd/storage-pools/zfs/containers/c1/rootfs/root/my\0040file
+R	F	/var/lib/lxd/storage-pools/zfs/containers/c1/rootfs/tmp/a	/var/lib/lxd/storage-pools/zfs/containers/c1/rootfs/tmp/b
+`
+
+	diff := zfsParseDiff(output, "/var/lib/lxd/storage-pools/zfs/containers/c1")
+	assert.Equal(t, []api.InstanceSnapshotDiffEntry{
+		{Path: "/rootfs/etc/hostname", Change: "removed"},
+		{Path: "/rootfs/etc/hosts", Change: "changed"},
+		{Path: "/rootfs/root/my file", Change: "added"},
+		{Path: "/rootfs/tmp/a", Change: "removed"},
+		{Path: "/rootfs/tmp/b", Change: "added"},
+	}, diff)
+}
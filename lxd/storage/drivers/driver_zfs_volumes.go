@@ -1,7 +1,6 @@
 package drivers
 
 import (
-	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -19,7 +18,9 @@ import (
 	"github.com/lxc/lxd/lxd/migration"
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/revert"
+	"github.com/lxc/lxd/lxd/tools"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/instancewriter"
 	"github.com/lxc/lxd/shared/ioprogress"
 	log "github.com/lxc/lxd/shared/log15"
@@ -170,7 +171,7 @@ func (d *zfs) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Oper
 	// Setup snapshot and unset mountpoint on image.
 	if vol.volType == VolumeTypeImage {
 		// Create snapshot of the main dataset.
-		_, err := shared.RunCommand("zfs", "snapshot", fmt.Sprintf("%s@readonly", d.dataset(vol, false)))
+		_, err := tools.RunCommand("zfs", "snapshot", fmt.Sprintf("%s@readonly", d.dataset(vol, false)))
 		if err != nil {
 			return err
 		}
@@ -179,12 +180,12 @@ func (d *zfs) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Oper
 			// Re-create the FS config volume's readonly snapshot now that the filler function has run and unpacked into both config and block volumes.
 			fsVol := NewVolume(d, d.name, vol.volType, ContentTypeFS, vol.name, vol.config, vol.poolConfig)
 
-			_, err := shared.RunCommand("zfs", "destroy", fmt.Sprintf("%s@readonly", d.dataset(fsVol, false)))
+			_, err := tools.RunCommand("zfs", "destroy", fmt.Sprintf("%s@readonly", d.dataset(fsVol, false)))
 			if err != nil {
 				return err
 			}
 
-			_, err = shared.RunCommand("zfs", "snapshot", fmt.Sprintf("%s@readonly", d.dataset(fsVol, false)))
+			_, err = tools.RunCommand("zfs", "snapshot", fmt.Sprintf("%s@readonly", d.dataset(fsVol, false)))
 			if err != nil {
 				return err
 			}
@@ -241,7 +242,7 @@ func (d *zfs) CreateVolumeFromBackup(vol Volume, snapshots []string, srcData io.
 	// Define function to unpack a volume from a backup tarball file.
 	unpackVolume := func(r io.ReadSeeker, unpacker []string, srcFile string, target string) error {
 		d.Logger().Debug("Unpacking optimized volume", log.Ctx{"source": srcFile, "target": target})
-		tr, cancelFunc, err := shared.CompressedTarReader(context.Background(), r, unpacker)
+		tr, cancelFunc, err := shared.CompressedTarReader(op.Context(), r, unpacker)
 		if err != nil {
 			return err
 		}
@@ -258,7 +259,7 @@ func (d *zfs) CreateVolumeFromBackup(vol Volume, snapshots []string, srcData io.
 
 			if hdr.Name == srcFile {
 				// Extract the backup.
-				err = shared.RunCommandWithFds(tr, nil, "zfs", "receive", "-F", target)
+				err = tools.RunWithFds(op.Context(), tr, nil, "zfs", "receive", "-F", target)
 
 				if err != nil {
 					return err
@@ -334,7 +335,7 @@ func (d *zfs) CreateVolumeFromBackup(vol Volume, snapshots []string, srcData io.
 		}
 
 		if strings.HasPrefix(entry, "@") {
-			_, err := shared.RunCommand("zfs", "destroy", fmt.Sprintf("%s%s", d.dataset(vol, false), entry))
+			_, err := tools.RunCommand("zfs", "destroy", fmt.Sprintf("%s%s", d.dataset(vol, false), entry))
 			if err != nil {
 				return nil, nil, err
 			}
@@ -415,7 +416,7 @@ func (d *zfs) CreateVolumeFromCopy(vol Volume, srcVol Volume, copySnapshots bool
 		// Create a new snapshot for copy.
 		srcSnapshot = fmt.Sprintf("%s@copy-%s", d.dataset(srcVol, false), uuid.NewRandom().String())
 
-		_, err := shared.RunCommand("zfs", "snapshot", srcSnapshot)
+		_, err := tools.RunCommand("zfs", "snapshot", srcSnapshot)
 		if err != nil {
 			return err
 		}
@@ -423,11 +424,11 @@ func (d *zfs) CreateVolumeFromCopy(vol Volume, srcVol Volume, copySnapshots bool
 		// If using "zfs.clone_copy" delete the snapshot at the end.
 		if (d.config["zfs.clone_copy"] != "" && !shared.IsTrue(d.config["zfs.clone_copy"])) || len(snapshots) > 0 {
 			// Delete the snapshot at the end.
-			defer shared.RunCommand("zfs", "destroy", srcSnapshot)
+			defer tools.RunCommand("zfs", "destroy", srcSnapshot)
 		} else {
 			// Delete the snapshot on revert.
 			revert.Add(func() {
-				shared.RunCommand("zfs", "destroy", srcSnapshot)
+				tools.RunCommand("zfs", "destroy", srcSnapshot)
 			})
 		}
 	}
@@ -438,13 +439,13 @@ func (d *zfs) CreateVolumeFromCopy(vol Volume, srcVol Volume, copySnapshots bool
 
 		// Send/receive the snapshot.
 		var sender *exec.Cmd
-		receiver := exec.Command("zfs", "receive", d.dataset(vol, false))
+		receiver := tools.Command(op.Context(), "zfs", "receive", d.dataset(vol, false))
 
 		// Handle transferring snapshots.
 		if len(snapshots) > 0 {
-			sender = exec.Command("zfs", "send", "-R", srcSnapshot)
+			sender = tools.Command(op.Context(), "zfs", "send", "-R", srcSnapshot)
 		} else {
-			sender = exec.Command("zfs", "send", srcSnapshot)
+			sender = tools.Command(op.Context(), "zfs", "send", srcSnapshot)
 		}
 
 		// Configure the pipes.
@@ -469,7 +470,7 @@ func (d *zfs) CreateVolumeFromCopy(vol Volume, srcVol Volume, copySnapshots bool
 		}
 
 		// Delete the snapshot.
-		_, err = shared.RunCommand("zfs", "destroy", fmt.Sprintf("%s@%s", d.dataset(vol, false), snapName))
+		_, err = tools.RunCommand("zfs", "destroy", fmt.Sprintf("%s@%s", d.dataset(vol, false), snapName))
 		if err != nil {
 			return err
 		}
@@ -491,7 +492,7 @@ func (d *zfs) CreateVolumeFromCopy(vol Volume, srcVol Volume, copySnapshots bool
 				}
 
 				// Delete the rest.
-				_, err := shared.RunCommand("zfs", "destroy", fmt.Sprintf("%s%s", d.dataset(vol, false), entry))
+				_, err := tools.RunCommand("zfs", "destroy", fmt.Sprintf("%s%s", d.dataset(vol, false), entry))
 				if err != nil {
 					return err
 				}
@@ -510,7 +511,7 @@ func (d *zfs) CreateVolumeFromCopy(vol Volume, srcVol Volume, copySnapshots bool
 		}
 
 		// Clone the snapshot.
-		_, err := shared.RunCommand("zfs", args...)
+		_, err := tools.RunCommand("zfs", args...)
 		if err != nil {
 			return err
 		}
@@ -574,7 +575,7 @@ func (d *zfs) CreateVolumeFromMigration(vol Volume, conn io.ReadWriteCloser, vol
 			fullSnapshotName := GetSnapshotVolumeName(vol.name, snapName)
 			wrapper := migration.ProgressWriter(op, "fs_progress", fullSnapshotName)
 
-			err = d.receiveDataset(d.dataset(vol, false), conn, wrapper)
+			err = d.receiveDataset(d.dataset(vol, false), conn, wrapper, op)
 			if err != nil {
 				return err
 			}
@@ -583,7 +584,7 @@ func (d *zfs) CreateVolumeFromMigration(vol Volume, conn io.ReadWriteCloser, vol
 
 	// Transfer the main volume.
 	wrapper := migration.ProgressWriter(op, "fs_progress", vol.name)
-	err := d.receiveDataset(d.dataset(vol, false), conn, wrapper)
+	err := d.receiveDataset(d.dataset(vol, false), conn, wrapper, op)
 	if err != nil {
 		return err
 	}
@@ -601,7 +602,7 @@ func (d *zfs) CreateVolumeFromMigration(vol Volume, conn io.ReadWriteCloser, vol
 		}
 
 		if strings.HasPrefix(entry, "@") {
-			_, err := shared.RunCommand("zfs", "destroy", fmt.Sprintf("%s%s", d.dataset(vol, false), entry))
+			_, err := tools.RunCommand("zfs", "destroy", fmt.Sprintf("%s%s", d.dataset(vol, false), entry))
 			if err != nil {
 				return err
 			}
@@ -655,7 +656,7 @@ func (d *zfs) DeleteVolume(vol Volume, op *operations.Operation) error {
 			}
 
 			// Delete the dataset (and any snapshots left).
-			_, err = shared.RunCommand("zfs", "destroy", "-r", d.dataset(vol, false))
+			_, err = tools.RunCommand("zfs", "destroy", "-r", d.dataset(vol, false))
 			if err != nil {
 				return err
 			}
@@ -680,7 +681,7 @@ func (d *zfs) DeleteVolume(vol Volume, op *operations.Operation) error {
 
 					if len(clones) == 0 {
 						// Delete the origin.
-						_, err := shared.RunCommand("zfs", "destroy", "-r", dataset)
+						_, err := tools.RunCommand("zfs", "destroy", "-r", dataset)
 						if err != nil {
 							return err
 						}
@@ -954,6 +955,25 @@ func (d *zfs) GetVolumeDiskPath(vol Volume) (string, error) {
 	return "", fmt.Errorf("Could not locate a zvol for %s", d.dataset(vol, false))
 }
 
+// DiffVolumes lists the paths which differ between a volume snapshot and either another snapshot or the
+// volume itself using "zfs diff".
+func (d *zfs) DiffVolumes(snapVol Volume, targetVol Volume, op *operations.Operation) ([]api.InstanceSnapshotDiffEntry, error) {
+	if snapVol.contentType != ContentTypeFS || !snapVol.IsSnapshot() {
+		return nil, ErrNotSupported
+	}
+
+	// Paths are reported under the mountpoint of the parent dataset, whichever datasets are compared.
+	parentName, _, _ := shared.InstanceGetParentAndSnapshotName(snapVol.name)
+	parentVol := NewVolume(d, d.name, snapVol.volType, snapVol.contentType, parentName, nil, nil)
+
+	output, err := tools.Run(op.Context(), "zfs", "diff", "-H", "-F", d.dataset(snapVol, false), d.dataset(targetVol, false))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to compare the datasets")
+	}
+
+	return zfsParseDiff(output, parentVol.MountPath()), nil
+}
+
 // MountVolume simulates mounting a volume.
 func (d *zfs) MountVolume(vol Volume, op *operations.Operation) (bool, error) {
 	var err error
@@ -968,7 +988,7 @@ func (d *zfs) MountVolume(vol Volume, op *operations.Operation) (bool, error) {
 		}
 
 		// Mount the dataset.
-		_, err = shared.RunCommand("zfs", "mount", dataset)
+		_, err = tools.RunCommand("zfs", "mount", dataset)
 		if err != nil {
 			return false, err
 		}
@@ -1048,7 +1068,7 @@ func (d *zfs) UnmountVolume(vol Volume, op *operations.Operation) (bool, error)
 	// Check if still mounted.
 	if shared.IsMountPoint(mountPath) {
 		// Unmount the dataset.
-		_, err := shared.RunCommand("zfs", "unmount", dataset)
+		_, err := tools.RunCommand("zfs", "unmount", dataset)
 		if err != nil {
 			return false, err
 		}
@@ -1080,13 +1100,13 @@ func (d *zfs) RenameVolume(vol Volume, newVolName string, op *operations.Operati
 	})
 
 	// Rename the ZFS datasets.
-	_, err = shared.RunCommand("zfs", "rename", d.dataset(vol, false), d.dataset(newVol, false))
+	_, err = tools.RunCommand("zfs", "rename", d.dataset(vol, false), d.dataset(newVol, false))
 	if err != nil {
 		return err
 	}
 
 	revert.Add(func() {
-		shared.RunCommand("zfs", "rename", d.dataset(newVol, false), d.dataset(vol, false))
+		tools.RunCommand("zfs", "rename", d.dataset(newVol, false), d.dataset(vol, false))
 	})
 
 	// Update the mountpoints.
@@ -1181,7 +1201,7 @@ func (d *zfs) MigrateVolume(vol Volume, conn io.ReadWriteCloser, volSrcArgs *mig
 			}
 
 			// Send snapshot to recipient (ensure local snapshot volume is mounted if needed).
-			err := d.sendDataset(d.dataset(snapshot, false), parent, volSrcArgs, conn, wrapper)
+			err := d.sendDataset(d.dataset(snapshot, false), parent, volSrcArgs, conn, wrapper, op)
 			if err != nil {
 				return err
 			}
@@ -1200,7 +1220,7 @@ func (d *zfs) MigrateVolume(vol Volume, conn io.ReadWriteCloser, volSrcArgs *mig
 	if !vol.IsSnapshot() {
 		// Create a temporary read-only snapshot.
 		srcSnapshot = fmt.Sprintf("%s@migration-%s", d.dataset(vol, false), uuid.NewRandom().String())
-		_, err := shared.RunCommand("zfs", "snapshot", srcSnapshot)
+		_, err := tools.RunCommand("zfs", "snapshot", srcSnapshot)
 		if err != nil {
 			return err
 		}
@@ -1208,18 +1228,18 @@ func (d *zfs) MigrateVolume(vol Volume, conn io.ReadWriteCloser, volSrcArgs *mig
 		if volSrcArgs.MultiSync {
 			if volSrcArgs.FinalSync {
 				finalParent = volSrcArgs.Data.(string)
-				defer shared.RunCommand("zfs", "destroy", finalParent)
-				defer shared.RunCommand("zfs", "destroy", srcSnapshot)
+				defer tools.RunCommand("zfs", "destroy", finalParent)
+				defer tools.RunCommand("zfs", "destroy", srcSnapshot)
 			} else {
 				volSrcArgs.Data = srcSnapshot // Persist parent state for final sync.
 			}
 		} else {
-			defer shared.RunCommand("zfs", "destroy", srcSnapshot)
+			defer tools.RunCommand("zfs", "destroy", srcSnapshot)
 		}
 	}
 
 	// Send the volume itself.
-	err := d.sendDataset(srcSnapshot, finalParent, volSrcArgs, conn, wrapper)
+	err := d.sendDataset(srcSnapshot, finalParent, volSrcArgs, conn, wrapper, op)
 	if err != nil {
 		return err
 	}
@@ -1264,11 +1284,11 @@ func (d *zfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWrit
 
 			// Create a temporary snapshot.
 			srcSnapshot := fmt.Sprintf("%s@backup-%s", d.dataset(vol, false), uuid.NewRandom().String())
-			_, err = shared.RunCommand("zfs", "snapshot", srcSnapshot)
+			_, err = tools.RunCommand("zfs", "snapshot", srcSnapshot)
 			if err != nil {
 				return err
 			}
-			defer shared.RunCommand("zfs", "destroy", srcSnapshot)
+			defer tools.RunCommand("zfs", "destroy", srcSnapshot)
 			d.logger.Debug("Created backup snapshot", log.Ctx{"dev": srcSnapshot})
 
 			// Override volume's mount path with location of snapshot so genericVFSBackupVolume reads
@@ -1329,7 +1349,7 @@ func (d *zfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWrit
 		d.logger.Debug("Generating optimized volume file", log.Ctx{"sourcePath": path, "file": tmpFile.Name(), "name": fileName})
 
 		// Write the subvolume to the file.
-		err = shared.RunCommandWithFds(nil, tmpFile, "zfs", args...)
+		err = tools.RunWithFds(op.Context(), nil, tmpFile, "zfs", args...)
 		if err != nil {
 			return err
 		}
@@ -1389,11 +1409,11 @@ func (d *zfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWrit
 
 	// Create a temporary read-only snapshot.
 	srcSnapshot := fmt.Sprintf("%s@backup-%s", d.dataset(vol, false), uuid.NewRandom().String())
-	_, err := shared.RunCommand("zfs", "snapshot", srcSnapshot)
+	_, err := tools.RunCommand("zfs", "snapshot", srcSnapshot)
 	if err != nil {
 		return err
 	}
-	defer shared.RunCommand("zfs", "destroy", srcSnapshot)
+	defer tools.RunCommand("zfs", "destroy", srcSnapshot)
 
 	// Dump the container to a file.
 	fileName := "container.bin"
@@ -1434,7 +1454,7 @@ func (d *zfs) CreateVolumeSnapshot(vol Volume, op *operations.Operation) error {
 	}
 
 	// Make the snapshot.
-	_, err = shared.RunCommand("zfs", "snapshot", d.dataset(vol, false))
+	_, err = tools.RunCommand("zfs", "snapshot", d.dataset(vol, false))
 	if err != nil {
 		return err
 	}
@@ -1470,13 +1490,13 @@ func (d *zfs) DeleteVolumeSnapshot(vol Volume, op *operations.Operation) error {
 
 	if len(clones) > 0 {
 		// Move to the deleted path.
-		_, err := shared.RunCommand("zfs", "rename", d.dataset(vol, false), d.dataset(vol, true))
+		_, err := tools.RunCommand("zfs", "rename", d.dataset(vol, false), d.dataset(vol, true))
 		if err != nil {
 			return err
 		}
 	} else {
 		// Delete the snapshot.
-		_, err := shared.RunCommand("zfs", "destroy", d.dataset(vol, false))
+		_, err := tools.RunCommand("zfs", "destroy", d.dataset(vol, false))
 		if err != nil {
 			return err
 		}
@@ -1700,7 +1720,7 @@ func (d *zfs) RestoreVolume(vol Volume, snapshotName string, op *operations.Oper
 	}
 
 	// Restore the snapshot.
-	_, err = shared.RunCommand("zfs", "rollback", d.dataset(snapVol, false))
+	_, err = tools.RunCommand("zfs", "rollback", d.dataset(snapVol, false))
 	if err != nil {
 		return err
 	}
@@ -1737,13 +1757,13 @@ func (d *zfs) RenameVolumeSnapshot(vol Volume, newSnapshotName string, op *opera
 	})
 
 	// Rename the ZFS datasets.
-	_, err = shared.RunCommand("zfs", "rename", d.dataset(vol, false), d.dataset(newVol, false))
+	_, err = tools.RunCommand("zfs", "rename", d.dataset(vol, false), d.dataset(newVol, false))
 	if err != nil {
 		return err
 	}
 
 	revert.Add(func() {
-		shared.RunCommand("zfs", "rename", d.dataset(newVol, false), d.dataset(vol, false))
+		tools.RunCommand("zfs", "rename", d.dataset(newVol, false), d.dataset(vol, false))
 	})
 
 	// For VM images, create a filesystem volume too.
@@ -13,6 +13,9 @@ var ErrUnknownDriver = fmt.Errorf("Unknown driver")
 // ErrNotSupported is the "Not supported" error
 var ErrNotSupported = fmt.Errorf("Not supported")
 
+// ErrInUse is the "Volume is in use" error, returned when a volume can't be shrunk while mounted.
+var ErrInUse = fmt.Errorf("Volume is in use")
+
 // ErrDeleteSnapshots is a special error used to tell the backend to delete more recent snapshots
 type ErrDeleteSnapshots struct {
 	Snapshots []string
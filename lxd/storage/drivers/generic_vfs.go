@@ -1,7 +1,6 @@
 package drivers
 
 import (
-	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -172,7 +171,7 @@ func genericVFSMigrateVolume(d Driver, s *state.State, vol Volume, conn io.ReadW
 		path := shared.AddSlash(mountPath)
 
 		d.Logger().Debug("Sending filesystem volume", log.Ctx{"volName": vol.name, "path": path})
-		return rsync.Send(vol.name, path, conn, wrapper, volSrcArgs.MigrationType.Features, bwlimit, s.OS.ExecPath, rsyncArgs...)
+		return rsync.Send(op.Context(), vol.name, path, conn, wrapper, volSrcArgs.MigrationType.Features, bwlimit, s.OS.ExecPath, rsyncArgs...)
 	}
 
 	// Define function to send a block volume.
@@ -292,7 +291,7 @@ func genericVFSCreateVolumeFromMigration(d Driver, initVolume func(vol Volume) (
 		}
 
 		d.Logger().Debug("Receiving filesystem volume", log.Ctx{"volName": volName, "path": path})
-		return rsync.Recv(path, conn, wrapper, volTargetArgs.MigrationType.Features)
+		return rsync.Recv(op.Context(), path, conn, wrapper, volTargetArgs.MigrationType.Features)
 	}
 
 	recvBlockVol := func(volName string, conn io.ReadWriteCloser, path string) error {
@@ -624,7 +623,7 @@ func genericVFSBackupUnpack(d Driver, vol Volume, snapshots []string, srcData io
 			srcFile := fmt.Sprintf("%s.img", srcPrefix)
 			d.Logger().Debug("Unpacking virtual machine block volume", log.Ctx{"source": srcFile, "target": targetPath})
 
-			tr, cancelFunc, err := shared.CompressedTarReader(context.Background(), r, unpacker)
+			tr, cancelFunc, err := shared.CompressedTarReader(op.Context(), r, unpacker)
 			if err != nil {
 				return err
 			}
@@ -829,7 +828,7 @@ func genericVFSCopyVolume(d Driver, initVolume func(vol Volume) (func(), error),
 				// Mount the source snapshot.
 				err := srcSnapshot.MountTask(func(srcMountPath string, op *operations.Operation) error {
 					// Copy the snapshot.
-					_, err := rsync.LocalCopy(srcMountPath, mountPath, bwlimit, true)
+					_, err := rsync.LocalCopyContext(op.Context(), srcMountPath, mountPath, bwlimit, true)
 					if err != nil {
 						return err
 					}
@@ -884,7 +883,7 @@ func genericVFSCopyVolume(d Driver, initVolume func(vol Volume) (func(), error),
 
 		// Copy source to destination (mounting each volume if needed).
 		err := srcVol.MountTask(func(srcMountPath string, op *operations.Operation) error {
-			_, err := rsync.LocalCopy(srcMountPath, mountPath, bwlimit, true)
+			_, err := rsync.LocalCopyContext(op.Context(), srcMountPath, mountPath, bwlimit, true)
 			if err != nil {
 				return err
 			}
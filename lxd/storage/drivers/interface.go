@@ -55,6 +55,11 @@ type Driver interface {
 	SetVolumeQuota(vol Volume, size string, op *operations.Operation) error
 	GetVolumeDiskPath(vol Volume) (string, error)
 
+	// DiffVolumes returns the paths, relative to the volume mount path, which differ between a volume
+	// snapshot and either another snapshot or the volume itself. Returns ErrNotSupported if the driver
+	// can't compare them natively.
+	DiffVolumes(snapVol Volume, targetVol Volume, op *operations.Operation) ([]api.InstanceSnapshotDiffEntry, error)
+
 	// MountVolume mounts a storage volume, returns true if we caused a new mount, false if
 	// already mounted.
 	MountVolume(vol Volume, op *operations.Operation) (bool, error)
@@ -29,10 +29,14 @@ func Load(state *state.State, driverName string, name string, config map[string]
 		driverFunc = func() driver { return &mock{} }
 	} else {
 		df, ok := drivers[driverName]
-		if !ok {
+		if ok {
+			driverFunc = df
+		} else if IsPlugin(driverName) {
+			// Fallback to an out-of-tree driver implemented by a plugin.
+			driverFunc = func() driver { return &storagePlugin{plugin: driverName} }
+		} else {
 			return nil, ErrUnknownDriver
 		}
-		driverFunc = df
 	}
 
 	d := driverFunc()
@@ -50,7 +54,7 @@ func Load(state *state.State, driverName string, name string, config map[string]
 func SupportedDrivers(s *state.State) []Info {
 	supportedDrivers := make([]Info, 0, len(drivers))
 
-	for driverName := range drivers {
+	for _, driverName := range AllDriverNames() {
 		driver, err := Load(s, driverName, "", nil, nil, nil, nil)
 		if err != nil {
 			continue
@@ -62,12 +66,21 @@ func SupportedDrivers(s *state.State) []Info {
 	return supportedDrivers
 }
 
-// AllDriverNames returns a list of all storage driver names.
+// AllDriverNames returns a list of all storage driver names, including the installed plugins.
 func AllDriverNames() []string {
 	supportDriverNames := make([]string, 0, len(drivers))
 	for driverName := range drivers {
 		supportDriverNames = append(supportDriverNames, driverName)
 	}
 
+	for _, pluginName := range pluginNames() {
+		_, ok := drivers[pluginName]
+		if ok {
+			continue
+		}
+
+		supportDriverNames = append(supportDriverNames, pluginName)
+	}
+
 	return supportDriverNames
 }
@@ -14,6 +14,7 @@ import (
 	"golang.org/x/sys/unix"
 
 	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/tools"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/idmap"
 	"github.com/lxc/lxd/shared/units"
@@ -453,11 +454,43 @@ func resolveMountOptions(options string) (uintptr, string) {
 	return mountFlags, strings.Join(tmp, ",")
 }
 
+// shrinkFileSystemCheckUsage checks that the space used by the filesystem mounted at the given path
+// fits in the given size.
+func shrinkFileSystemCheckUsage(mountPath string, byteSize int64) error {
+	var stat unix.Statfs_t
+	err := unix.Statfs(mountPath, &stat)
+	if err != nil {
+		return err
+	}
+
+	usedBytes := int64(stat.Blocks-stat.Bfree) * int64(stat.Bsize)
+	if usedBytes >= byteSize {
+		return fmt.Errorf("Filesystem uses %s, which doesn't fit in %s", units.GetByteSizeString(usedBytes, 2), units.GetByteSizeString(byteSize, 2))
+	}
+
+	return nil
+}
+
 // shrinkFileSystem shrinks a filesystem if it is supported. Ext4 volumes will be unmounted temporarily if needed.
 func shrinkFileSystem(fsType string, devPath string, vol Volume, byteSize int64) error {
 	// The smallest unit that resize2fs accepts in byte size (rather than blocks) is kilobytes.
 	strSize := fmt.Sprintf("%dK", byteSize/1024)
 
+	if fsType == "ext4" || fsType == "btrfs" {
+		// Ext4 volumes used by a running instance are only shrunk once it's stopped.
+		if fsType == "ext4" && shared.IsMountPoint(vol.MountPath()) {
+			return ErrInUse
+		}
+
+		// Refuse to shrink below the space already used by the filesystem.
+		err := vol.MountTask(func(mountPath string, op *operations.Operation) error {
+			return shrinkFileSystemCheckUsage(mountPath, byteSize)
+		}, nil)
+		if err != nil {
+			return err
+		}
+	}
+
 	switch fsType {
 	case "": // if not specified, default to ext4.
 		fallthrough
@@ -480,7 +513,7 @@ func shrinkFileSystem(fsType string, devPath string, vol Volume, byteSize int64)
 		}, nil)
 	case "btrfs":
 		return vol.MountTask(func(mountPath string, op *operations.Operation) error {
-			_, err := shared.RunCommand("btrfs", "filesystem", "resize", strSize, mountPath)
+			_, err := tools.RunCommand("btrfs", "filesystem", "resize", strSize, mountPath)
 			if err != nil {
 				return err
 			}
@@ -505,7 +538,7 @@ func growFileSystem(fsType string, devPath string, vol Volume) error {
 		case "xfs":
 			msg, err = shared.TryRunCommand("xfs_growfs", mountPath)
 		case "btrfs":
-			msg, err = shared.TryRunCommand("btrfs", "filesystem", "resize", "max", mountPath)
+			msg, err = tools.TryRunCommand("btrfs", "filesystem", "resize", "max", mountPath)
 		default:
 			return fmt.Errorf(`Growing not supported for filesystem type "%s"`, fsType)
 		}
@@ -547,7 +580,7 @@ func regenerateFilesystemUUID(fsType string, devPath string) error {
 func regenerateFilesystemBTRFSUUID(devPath string) error {
 	// If the snapshot was taken whilst instance was running there may be outstanding transactions that will
 	// cause btrfstune to corrupt superblock, so ensure these are cleared out first.
-	_, err := shared.RunCommand("btrfs", "rescue", "zero-log", devPath)
+	_, err := tools.RunCommand("btrfs", "rescue", "zero-log", devPath)
 	if err != nil {
 		return err
 	}
@@ -706,7 +739,7 @@ func btrfsIsSubVolume(subvolPath string) bool {
 
 // BTRFSSubVolumeIsRo returns if subvolume is read only.
 func BTRFSSubVolumeIsRo(path string) bool {
-	output, err := shared.RunCommand("btrfs", "property", "get", "-ts", path)
+	output, err := tools.RunCommand("btrfs", "property", "get", "-ts", path)
 	if err != nil {
 		return false
 	}
@@ -716,13 +749,13 @@ func BTRFSSubVolumeIsRo(path string) bool {
 
 // BTRFSSubVolumeMakeRo makes a subvolume read only.
 func BTRFSSubVolumeMakeRo(path string) error {
-	_, err := shared.RunCommand("btrfs", "property", "set", "-ts", path, "ro", "true")
+	_, err := tools.RunCommand("btrfs", "property", "set", "-ts", path, "ro", "true")
 	return err
 }
 
 // BTRFSSubVolumeMakeRw makes a sub volume read/write.
 func BTRFSSubVolumeMakeRw(path string) error {
-	_, err := shared.RunCommand("btrfs", "property", "set", "-ts", path, "ro", "false")
+	_, err := tools.RunCommand("btrfs", "property", "set", "-ts", path, "ro", "false")
 	return err
 }
 
@@ -1,9 +1,14 @@
 package drivers
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
 )
 
 // Test GetVolumeMountPath
@@ -35,3 +40,27 @@ func TestGetVolumeMountPath(t *testing.T) {
 	expected = GetPoolMountPath(poolName) + "/virtual-machines/testvol"
 	assert.Equal(t, expected, path)
 }
+
+// Filesystems can't be shrunk below the space they use.
+func TestShrinkFileSystemCheckUsage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd_shrink_")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Make sure something is used, even on an empty tmpfs.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "data"), make([]byte, 65536), 0644))
+
+	var stat unix.Statfs_t
+	require.NoError(t, unix.Statfs(dir, &stat))
+	usedBytes := int64(stat.Blocks-stat.Bfree) * int64(stat.Bsize)
+
+	err = shrinkFileSystemCheckUsage(dir, usedBytes*2+1024*1024)
+	assert.NoError(t, err)
+
+	err = shrinkFileSystemCheckUsage(dir, 1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "which doesn't fit in 1B")
+
+	err = shrinkFileSystemCheckUsage(filepath.Join(dir, "missing"), 1)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -360,6 +361,31 @@ func validatePoolCommonRules() map[string]func(string) error {
 		"volume.size":             shared.IsSize,
 		"size":                    shared.IsSize,
 		"rsync.bwlimit":           shared.IsAny,
+		"defaults.profiles": func(value string) error {
+			for _, name := range strings.Split(value, ",") {
+				if strings.Contains(name, "/") {
+					return fmt.Errorf("Invalid profile name %q", strings.TrimSpace(name))
+				}
+			}
+
+			return nil
+		},
+		"trim.schedule": func(value string) error {
+			if value == "" {
+				return nil
+			}
+
+			if len(strings.Split(value, " ")) != 5 {
+				return fmt.Errorf("Schedule must be of the form: <minute> <hour> <day-of-month> <month> <day-of-week>")
+			}
+
+			_, err := cron.Parse(fmt.Sprintf("* %s", value))
+			if err != nil {
+				return errors.Wrap(err, "Error parsing schedule")
+			}
+
+			return nil
+		},
 	}
 }
 
@@ -426,7 +452,7 @@ func validateVolumeCommonRules(vol drivers.Volume) map[string]func(string) error
 // VM Format A: Separate metadata tarball and root qcow2 file.
 // 	- Unpack metadata tarball into mountPath.
 //	- Check rootBlockPath is a file and convert qcow2 file into raw format in rootBlockPath.
-func ImageUnpack(imageFile, destPath, destBlockFile string, blockBackend, runningInUserns bool, tracker *ioprogress.ProgressTracker) error {
+func ImageUnpack(ctx context.Context, imageFile, destPath, destBlockFile string, blockBackend, runningInUserns bool, tracker *ioprogress.ProgressTracker) error {
 	// For all formats, first unpack the metadata (or combined) tarball into destPath.
 	imageRootfsFile := imageFile + ".rootfs"
 
@@ -435,7 +461,7 @@ func ImageUnpack(imageFile, destPath, destBlockFile string, blockBackend, runnin
 		rootfsPath := filepath.Join(destPath, "rootfs")
 
 		// Unpack the main image file.
-		err := shared.Unpack(imageFile, destPath, blockBackend, runningInUserns, tracker)
+		err := shared.UnpackContext(ctx, imageFile, destPath, blockBackend, runningInUserns, tracker)
 		if err != nil {
 			return err
 		}
@@ -447,7 +473,7 @@ func ImageUnpack(imageFile, destPath, destBlockFile string, blockBackend, runnin
 				return fmt.Errorf("Error creating rootfs directory")
 			}
 
-			err = shared.Unpack(imageRootfsFile, rootfsPath, blockBackend, runningInUserns, tracker)
+			err = shared.UnpackContext(ctx, imageRootfsFile, rootfsPath, blockBackend, runningInUserns, tracker)
 			if err != nil {
 				return err
 			}
@@ -477,13 +503,13 @@ func ImageUnpack(imageFile, destPath, destBlockFile string, blockBackend, runnin
 
 	if shared.PathExists(imageRootfsFile) {
 		// Unpack the main image file.
-		err := shared.Unpack(imageFile, destPath, blockBackend, runningInUserns, tracker)
+		err := shared.UnpackContext(ctx, imageFile, destPath, blockBackend, runningInUserns, tracker)
 		if err != nil {
 			return err
 		}
 
 		// Convert the qcow2 format to a raw block device.
-		_, err = shared.RunCommand("qemu-img", "convert", "-O", "raw", imageRootfsFile, destBlockFile)
+		_, err = shared.RunCommandContext(ctx, "qemu-img", "convert", "-O", "raw", imageRootfsFile, destBlockFile)
 		if err != nil {
 			return fmt.Errorf("Failed converting image to raw at %s: %v", destBlockFile, err)
 		}
@@ -496,14 +522,14 @@ func ImageUnpack(imageFile, destPath, destBlockFile string, blockBackend, runnin
 		defer os.RemoveAll(tempDir)
 
 		// Unpack the whole image.
-		err = shared.Unpack(imageFile, tempDir, blockBackend, runningInUserns, tracker)
+		err = shared.UnpackContext(ctx, imageFile, tempDir, blockBackend, runningInUserns, tracker)
 		if err != nil {
 			return err
 		}
 
 		// Convert the qcow2 format to a raw block device.
 		imgPath := filepath.Join(tempDir, "rootfs.img")
-		_, err = shared.RunCommand("qemu-img", "convert", "-O", "raw", imgPath, destBlockFile)
+		_, err = shared.RunCommandContext(ctx, "qemu-img", "convert", "-O", "raw", imgPath, destBlockFile)
 		if err != nil {
 			return fmt.Errorf("Failed converting image to raw at %s: %v", destBlockFile, err)
 		}
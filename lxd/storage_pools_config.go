@@ -111,6 +111,11 @@ func storagePoolValidateConfig(name string, driver string, config map[string]str
 			continue
 		}
 
+		// Keys of plugin drivers are validated by the plugin itself.
+		if storageDrivers.IsPlugin(driver) {
+			continue
+		}
+
 		prfx := strings.HasPrefix
 		if driver == "dir" || driver == "ceph" || driver == "cephfs" {
 			if key == "size" {
@@ -152,6 +157,11 @@ func storagePoolValidateConfig(name string, driver string, config map[string]str
 }
 
 func storagePoolFillDefault(name string, driver string, config map[string]string) error {
+	// Plugin drivers have their own defaults.
+	if storageDrivers.IsPlugin(driver) {
+		return nil
+	}
+
 	if driver == "dir" || driver == "ceph" || driver == "cephfs" {
 		if config["size"] != "" {
 			return fmt.Errorf(`The "size" property does not apply `+
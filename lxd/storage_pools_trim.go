@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/state"
+	storagePools "github.com/lxc/lxd/lxd/storage"
+	storageDrivers "github.com/lxc/lxd/lxd/storage/drivers"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/lxd/tools"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+	"golang.org/x/sys/unix"
+)
+
+var storagePoolTrimCmd = APIEndpoint{
+	Path: "storage-pools/{name}/trim",
+
+	Post: APIEndpointAction{Handler: storagePoolTrimPost},
+}
+
+// Matches the number of bytes reported by "fstrim -v".
+var fstrimBytesRegexp = regexp.MustCompile(`\((\d+) bytes\) trimmed`)
+
+// /1.0/storage-pools/{name}/trim
+// Discard the unused blocks of a storage pool on this node.
+func storagePoolTrimPost(d *Daemon, r *http.Request) response.Response {
+	// If a target was specified, forward the request to the relevant node.
+	resp := forwardedResponseIfTargetIsRemote(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	poolName := mux.Vars(r)["name"]
+	pool, err := storagePools.GetPoolByName(d.State(), poolName)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	run := func(op *operations.Operation) error {
+		_, err := storagePoolTrim(d.State(), pool, op)
+		return err
+	}
+
+	resources := map[string][]string{}
+	resources["storage_pools"] = []string{poolName}
+
+	op, err := operations.OperationCreate(d.State(), "", operations.OperationClassTask, db.OperationStoragePoolTrim, resources, nil, run, nil, nil)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	return operations.OperationResponse(op)
+}
+
+// storagePoolTrim discards the unused blocks of a storage pool on this node, returning the number
+// of bytes reclaimed, or -1 if the driver doesn't report it. On block backed pools, each container
+// volume is trimmed, and on all pools, the running virtual machines are asked to trim their
+// filesystems. The bytes reclaimed from each instance are reported in the metadata of the operation.
+func storagePoolTrim(s *state.State, pool storagePools.Pool, op *operations.Operation) (int64, error) {
+	info := pool.Driver().Info()
+	if info.Remote && !info.BlockBacking {
+		return -1, fmt.Errorf("Storage pools of type %q can't be trimmed", info.Name)
+	}
+
+	reclaimed := int64(0)
+	if info.Name == "zfs" {
+		// ZFS pools are trimmed as a whole, in the background and without reporting what was
+		// reclaimed. The pool may be a dataset of a zpool holding other datasets.
+		zpoolName := strings.SplitN(pool.Driver().Config()["zfs.pool_name"], "/", 2)[0]
+		_, err := tools.RunCommand("zpool", "trim", zpoolName)
+		if err != nil {
+			return -1, errors.Wrapf(err, "Failed to trim ZFS pool %q", zpoolName)
+		}
+
+		reclaimed = -1
+	} else if !info.BlockBacking {
+		// The container volumes of pools which aren't block backed share the filesystem of the pool.
+		var err error
+		reclaimed, err = fstrim(storageDrivers.GetPoolMountPath(pool.Name()))
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	if op != nil {
+		op.UpdateMetadata(map[string]interface{}{"reclaimed_bytes": reclaimed})
+	}
+
+	insts, err := instance.LoadNodeAll(s, instancetype.Any)
+	if err != nil {
+		return -1, err
+	}
+
+	instances := map[string]int64{}
+	for _, inst := range insts {
+		var n int64
+
+		if inst.Type() == instancetype.Container {
+			if !info.BlockBacking {
+				continue
+			}
+		} else if !inst.IsRunning() {
+			// The filesystems of virtual machines can only be trimmed from the inside.
+			continue
+		}
+
+		poolName, err := s.Cluster.InstancePool(inst.Project(), inst.Name())
+		if err != nil {
+			return -1, err
+		}
+
+		if poolName != pool.Name() {
+			continue
+		}
+
+		if inst.Type() == instancetype.Container {
+			n, err = storagePoolTrimInstance(pool, inst, op)
+		} else {
+			n, err = storagePoolTrimVM(op.Context(), inst)
+		}
+		if err != nil {
+			logger.Warn("Failed to trim instance volume", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "pool": pool.Name(), "err": err})
+			continue
+		}
+
+		if reclaimed >= 0 {
+			reclaimed += n
+		}
+
+		instances[fmt.Sprintf("%s/%s", inst.Project(), inst.Name())] = n
+
+		if op != nil {
+			op.UpdateMetadata(map[string]interface{}{"reclaimed_bytes": reclaimed, "instances": instances})
+		}
+	}
+
+	return reclaimed, nil
+}
+
+// How long a virtual machine may take to trim its filesystems.
+var storagePoolTrimVMTimeout = 10 * time.Minute
+
+// storagePoolTrimVM has a running virtual machine trim its filesystems through its agent. Its disks
+// are attached with discard enabled, so the blocks it discards are freed in the pool as well.
+func storagePoolTrimVM(ctx context.Context, inst instance.Instance) (int64, error) {
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		return -1, err
+	}
+	defer stdin.Close()
+
+	output, err := ioutil.TempFile("", "lxd_trim_")
+	if err != nil {
+		return -1, err
+	}
+	defer os.Remove(output.Name())
+	defer output.Close()
+
+	req := api.InstanceExecPost{
+		Command:     []string{"fstrim", "-a", "-v"},
+		Environment: map[string]string{"PATH": "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"},
+	}
+
+	cmd, err := inst.Exec(req, stdin, output, output)
+	if err != nil {
+		return -1, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, storagePoolTrimVMTimeout)
+	defer cancel()
+
+	exitCode, err := storagePoolTrimWait(ctx, cmd)
+	if err != nil {
+		return -1, err
+	}
+
+	out, err := ioutil.ReadFile(output.Name())
+	if err != nil {
+		return -1, err
+	}
+
+	if exitCode != 0 {
+		return -1, fmt.Errorf("fstrim failed with exit code %d: %s", exitCode, strings.TrimSpace(string(out)))
+	}
+
+	return fstrimReclaimed(string(out))
+}
+
+// storagePoolTrimWait waits for a command run in an instance to exit, killing it if the context is
+// done first.
+func storagePoolTrimWait(ctx context.Context, cmd instance.Cmd) (int, error) {
+	type result struct {
+		exitCode int
+		err      error
+	}
+
+	// Buffered so that the waiting goroutine doesn't leak once the command is killed.
+	resultCh := make(chan result, 1)
+	go func() {
+		exitCode, err := cmd.Wait()
+		resultCh <- result{exitCode: exitCode, err: err}
+	}()
+
+	select {
+	case res := <-resultCh:
+		return res.exitCode, res.err
+	case <-ctx.Done():
+		err := cmd.Signal(unix.SIGKILL)
+		if err != nil {
+			logger.Warn("Failed to kill trim command", log.Ctx{"err": err})
+		}
+
+		return -1, errors.Wrap(ctx.Err(), "Failed to wait for trim command")
+	}
+}
+
+// fstrimReclaimed sums up the number of bytes reported by "fstrim -v" for each filesystem.
+func fstrimReclaimed(out string) (int64, error) {
+	reclaimed := int64(0)
+	for _, match := range fstrimBytesRegexp.FindAllStringSubmatch(out, -1) {
+		n, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return -1, err
+		}
+
+		reclaimed += n
+	}
+
+	return reclaimed, nil
+}
+
+// storagePoolTrimInstance trims the volume of a container, mounting it if needed.
+func storagePoolTrimInstance(pool storagePools.Pool, inst instance.Instance, op *operations.Operation) (int64, error) {
+	ourMount, err := pool.MountInstance(inst, op)
+	if err != nil {
+		return -1, err
+	}
+
+	if ourMount {
+		defer pool.UnmountInstance(inst, op)
+	}
+
+	return fstrim(inst.RootfsPath())
+}
+
+// fstrim discards the unused blocks of the filesystem holding the given path, returning the number
+// of bytes reclaimed.
+func fstrim(path string) (int64, error) {
+	out, err := shared.RunCommand("fstrim", "-v", path)
+	if err != nil {
+		return -1, errors.Wrapf(err, "Failed to trim %q", path)
+	}
+
+	match := fstrimBytesRegexp.FindStringSubmatch(out)
+	if match == nil {
+		return -1, fmt.Errorf("Unexpected fstrim output: %s", out)
+	}
+
+	return strconv.ParseInt(match[1], 10, 64)
+}
+
+// Whether scheduled trims are running, so that they don't overlap.
+var storagePoolsTrimming int32
+
+func storagePoolsTrimTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		poolNames, err := d.cluster.StoragePools()
+		if err != nil {
+			if err != db.ErrNoSuchObject {
+				logger.Error("Failed to load storage pools for scheduled trim", log.Ctx{"err": err})
+			}
+
+			return
+		}
+
+		now := time.Now()
+		duePools := []string{}
+		for _, poolName := range poolNames {
+			_, dbPool, err := d.cluster.StoragePoolGet(poolName)
+			if err != nil {
+				logger.Error("Failed to load storage pool for scheduled trim", log.Ctx{"pool": poolName, "err": err})
+				continue
+			}
+
+			schedule := dbPool.Config["trim.schedule"]
+			if schedule != "" && instanceScheduleIsDue(schedule, now) {
+				duePools = append(duePools, poolName)
+			}
+		}
+
+		if len(duePools) == 0 {
+			return
+		}
+
+		if !atomic.CompareAndSwapInt32(&storagePoolsTrimming, 0, 1) {
+			logger.Warn("Skipping scheduled trim of storage pools as the previous one is still running", log.Ctx{"pools": duePools})
+			return
+		}
+
+		// Trimming may take a while, so don't hold up the other tasks.
+		go func() {
+			defer atomic.StoreInt32(&storagePoolsTrimming, 0)
+
+			for _, poolName := range duePools {
+				storagePoolsTrimScheduled(d, poolName)
+			}
+		}()
+	}
+
+	return f, task.Every(time.Minute)
+}
+
+// storagePoolsTrimScheduled trims a storage pool whose trim.schedule is due, waiting for it to
+// complete so that pools aren't trimmed concurrently.
+func storagePoolsTrimScheduled(d *Daemon, poolName string) {
+	pool, err := storagePools.GetPoolByName(d.State(), poolName)
+	if err != nil {
+		logger.Error("Failed to load storage pool for scheduled trim", log.Ctx{"pool": poolName, "err": err})
+		return
+	}
+
+	reclaimed := int64(-1)
+	opRun := func(op *operations.Operation) error {
+		var err error
+		reclaimed, err = storagePoolTrim(d.State(), pool, op)
+		return err
+	}
+
+	resources := map[string][]string{}
+	resources["storage_pools"] = []string{poolName}
+
+	op, err := operations.OperationCreate(d.State(), "", operations.OperationClassTask, db.OperationStoragePoolTrim, resources, nil, opRun, nil, nil)
+	if err != nil {
+		logger.Error("Failed to start storage pool trim operation", log.Ctx{"pool": poolName, "err": err})
+		return
+	}
+
+	logger.Info("Trimming storage pool", log.Ctx{"pool": poolName})
+	errCh, err := op.Run()
+	if err != nil {
+		logger.Error("Failed to start storage pool trim operation", log.Ctx{"pool": poolName, "err": err})
+		return
+	}
+
+	err = <-errCh
+	if err != nil {
+		logger.Error("Failed to trim storage pool", log.Ctx{"pool": poolName, "err": err})
+		return
+	}
+
+	logger.Info("Done trimming storage pool", log.Ctx{"pool": poolName, "reclaimed": reclaimed})
+}
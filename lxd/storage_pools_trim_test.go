@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// Command which exits once signalled, or with the given code when released.
+type trimTestCmd struct {
+	exitCode int
+	release  chan struct{}
+	signals  chan unix.Signal
+}
+
+func (c *trimTestCmd) Wait() (int, error) {
+	select {
+	case <-c.release:
+		return c.exitCode, nil
+	case <-c.signals:
+		return -1, nil
+	}
+}
+
+func (c *trimTestCmd) PID() int {
+	return -1
+}
+
+func (c *trimTestCmd) Signal(s unix.Signal) error {
+	c.signals <- s
+	return nil
+}
+
+func (c *trimTestCmd) WindowResize(fd, winchWidth, winchHeight int) error {
+	return nil
+}
+
+func TestFstrimReclaimed(t *testing.T) {
+	out := `/boot: 420.5 MiB (440926208 bytes) trimmed on /dev/sda15
+/: 7.1 GiB (7612280832 bytes) trimmed on /dev/sda1
+`
+	reclaimed, err := fstrimReclaimed(out)
+	require.NoError(t, err)
+	assert.Equal(t, int64(440926208+7612280832), reclaimed)
+
+	reclaimed, err = fstrimReclaimed("")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), reclaimed)
+}
+
+// The exit code of the command is returned if it exits in time.
+func TestStoragePoolTrimWait(t *testing.T) {
+	cmd := &trimTestCmd{exitCode: 1, release: make(chan struct{}), signals: make(chan unix.Signal, 1)}
+	close(cmd.release)
+
+	exitCode, err := storagePoolTrimWait(context.Background(), cmd)
+	require.NoError(t, err)
+	assert.Equal(t, 1, exitCode)
+	assert.Len(t, cmd.signals, 0)
+}
+
+// The command is killed once the context is done.
+func TestStoragePoolTrimWait_Timeout(t *testing.T) {
+	cmd := &trimTestCmd{release: make(chan struct{}), signals: make(chan unix.Signal, 1)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := storagePoolTrimWait(ctx, cmd)
+	assert.EqualError(t, err, "Failed to wait for trim command: context deadline exceeded")
+}
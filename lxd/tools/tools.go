@@ -0,0 +1,232 @@
+// Package tools runs the external programs LXD relies on for storage, networking and migration.
+// Only the programs listed here can be run, with their output captured and an optional timeout.
+//
+// All the invocations of btrfs, zfs, zpool, rsync and criu go through this package, so that they
+// can be stopped along with the operation running them. The LVM, Ceph and filesystem tools, and
+// dnsmasq which runs as a daemon, are still run directly.
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// tool describes an external program which LXD may run.
+type tool struct {
+	// Arguments making the program print its version.
+	versionArgs []string
+
+	// Fallback file holding the version, for programs which can't report it.
+	versionFile string
+
+	// Whether the program ships along with another one, so its version isn't reported separately.
+	bundled bool
+}
+
+// The external programs which can be run through this package.
+var known = map[string]tool{
+	"btrfs":   {versionArgs: []string{"version"}},
+	"criu":    {versionArgs: []string{"--version"}},
+	"dnsmasq": {versionArgs: []string{"--version"}},
+	"rsync":   {versionArgs: []string{"--version"}},
+	"zfs":     {versionArgs: []string{"version"}, versionFile: "/sys/module/zfs/version"},
+	"zpool":   {versionArgs: []string{"version"}, versionFile: "/sys/module/zfs/version", bundled: true},
+}
+
+// How long the version of a program may take to be reported.
+const versionTimeout = 10 * time.Second
+
+// How long the detected versions are cached, so that newly installed programs get noticed.
+const versionCacheExpiry = 5 * time.Minute
+
+var versionRegexp = regexp.MustCompile(`\d+(\.\d+)+`)
+
+var versions map[string]string
+var versionsTime time.Time
+var versionsRefreshing bool
+var versionsMu sync.Mutex
+
+// Run runs the given program, which must be one of the known tools, and returns its standard
+// output. The program is killed if the context is cancelled before it completes. On failure, the
+// returned shared.RunError holds the standard error of the program.
+func Run(ctx context.Context, name string, arg ...string) (string, error) {
+	_, ok := known[name]
+	if !ok {
+		return "", fmt.Errorf("Running %q isn't allowed", name)
+	}
+
+	// Run with a stable locale, so that the output can be parsed.
+	stdout, _, err := shared.RunCommandSplitContext(ctx, append(os.Environ(), "LANG=C.UTF-8"), name, arg...)
+	return stdout, err
+}
+
+// RunCommand is like Run, without a context.
+func RunCommand(name string, arg ...string) (string, error) {
+	return Run(context.Background(), name, arg...)
+}
+
+// TryRunCommand is like RunCommand, retrying for up to 10s until the program succeeds.
+func TryRunCommand(name string, arg ...string) (string, error) {
+	var err error
+	var output string
+
+	for i := 0; i < 20; i++ {
+		output, err = RunCommand(name, arg...)
+		if err == nil {
+			break
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	return output, err
+}
+
+// RunWithFds is like Run, reading the standard input of the program from stdin and writing its
+// standard output to stdout.
+func RunWithFds(ctx context.Context, stdin io.Reader, stdout io.Writer, name string, arg ...string) error {
+	_, ok := known[name]
+	if !ok {
+		return fmt.Errorf("Running %q isn't allowed", name)
+	}
+
+	return shared.RunCommandWithFdsContext(ctx, stdin, stdout, name, arg...)
+}
+
+// Command returns an exec.Cmd running the given program, for callers needing to handle its standard
+// streams themselves. The command fails to start if the program isn't one of the known tools.
+func Command(ctx context.Context, name string, arg ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, arg...)
+	cmd.Env = append(os.Environ(), "LANG=C.UTF-8")
+
+	_, ok := known[name]
+	if !ok {
+		cmd.Path = ""
+	}
+
+	return cmd
+}
+
+// RunTimeout is like Run, killing the program if it didn't complete within the given timeout.
+func RunTimeout(timeout time.Duration, name string, arg ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	stdout, err := Run(ctx, name, arg...)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return stdout, fmt.Errorf("Timed out after %s running %s: %v", timeout, name, err)
+	}
+
+	return stdout, err
+}
+
+// Available returns whether the given program is known and installed.
+func Available(name string) bool {
+	_, ok := known[name]
+	if !ok {
+		return false
+	}
+
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// Version returns the version of the given program.
+func Version(name string) (string, error) {
+	t, ok := known[name]
+	if !ok {
+		return "", fmt.Errorf("Unknown tool %q", name)
+	}
+
+	output, err := RunTimeout(versionTimeout, name, t.versionArgs...)
+	if err != nil && t.versionFile != "" {
+		content, fileErr := ioutil.ReadFile(t.versionFile)
+		if fileErr == nil {
+			output, err = string(content), nil
+		}
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return parseVersion(name, output)
+}
+
+// parseVersion extracts the version of a program from its output.
+func parseVersion(name string, output string) (string, error) {
+	// Only look at the first line, the others may hold the versions of dependencies.
+	line := strings.SplitN(strings.TrimSpace(output), "\n", 2)[0]
+	version := versionRegexp.FindString(line)
+	if version == "" {
+		return "", fmt.Errorf("Couldn't find the version of %s in %q", name, line)
+	}
+
+	return version, nil
+}
+
+// Versions returns the versions of the known programs which are installed, or "unknown" for those
+// whose version couldn't be determined, as last detected. Detection runs in the background the
+// first time this is called and once the result is a few minutes old, so that newly installed
+// programs get noticed without callers ever waiting for it. Nil is returned until it first completes.
+func Versions() map[string]string {
+	versionsMu.Lock()
+	defer versionsMu.Unlock()
+
+	if !versionsRefreshing && (versions == nil || time.Since(versionsTime) >= versionCacheExpiry) {
+		versionsRefreshing = true
+		go refreshVersions()
+	}
+
+	return versions
+}
+
+// RefreshVersions detects the versions of the known programs, unless that's already in progress.
+// It's meant to be called at startup, so that the versions are known by the time they're requested.
+func RefreshVersions() {
+	versionsMu.Lock()
+	if versionsRefreshing {
+		versionsMu.Unlock()
+		return
+	}
+
+	versionsRefreshing = true
+	versionsMu.Unlock()
+
+	refreshVersions()
+}
+
+// refreshVersions detects the versions of the known programs and caches them. It must be called
+// with versionsRefreshing set.
+func refreshVersions() {
+	result := map[string]string{}
+	for name, t := range known {
+		if t.bundled || !Available(name) {
+			continue
+		}
+
+		version, err := Version(name)
+		if err != nil {
+			version = "unknown"
+		}
+
+		result[name] = version
+	}
+
+	versionsMu.Lock()
+	defer versionsMu.Unlock()
+
+	versions = result
+	versionsTime = time.Now()
+	versionsRefreshing = false
+}
@@ -0,0 +1,42 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Only the known programs can be run.
+func TestRun_NotAllowed(t *testing.T) {
+	_, err := Run(context.Background(), "rm", "-rf", "/")
+	assert.EqualError(t, err, `Running "rm" isn't allowed`)
+	assert.False(t, Available("rm"))
+}
+
+// The version is taken from the first line of the output of the programs.
+func TestParseVersion(t *testing.T) {
+	cases := map[string]string{
+		"rsync  version 3.1.3  protocol version 31\nCopyright (C) 1996-2018": "3.1.3",
+		"btrfs-progs v5.4.1\n":                                         "5.4.1",
+		"zfs-0.8.3-1ubuntu12.9\nzfs-kmod-0.8.3-1ubuntu12.5\n":          "0.8.3",
+		"Version: 3.12\n":                                              "3.12",
+		"Dnsmasq version 2.80  Copyright (c) 2000-2018 Simon Kelley\n": "2.80",
+	}
+
+	for output, expected := range cases {
+		version, err := parseVersion("test", output)
+		require.NoError(t, err)
+		assert.Equal(t, expected, version)
+	}
+
+	_, err := parseVersion("test", "no version here\n1.2.3")
+	assert.Error(t, err)
+}
+
+// Commands for unknown programs fail to start.
+func TestCommand_NotAllowed(t *testing.T) {
+	err := Command(context.Background(), "rm", "-rf", "/").Run()
+	assert.Error(t, err)
+}
@@ -0,0 +1,20 @@
+package tracing
+
+import (
+	"context"
+)
+
+// CommandHook records the subprocesses run within a traced context as child spans. It's meant to be
+// used as shared.RunCommandHook. Only the name of the executable and the number of arguments are
+// recorded, as the arguments may hold secrets such as passwords or keys.
+func CommandHook(ctx context.Context, name string, arg []string) func(err error) {
+	_, span := StartChild(ctx, "exec "+name)
+	if span == nil {
+		return func(err error) {}
+	}
+
+	span.SetAttribute("process.executable.name", name)
+	span.SetAttribute("process.args_count", len(arg))
+
+	return span.End
+}
@@ -0,0 +1,227 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// Interval at which the queued spans are sent to the collector.
+const exportInterval = 5 * time.Second
+
+// Maximum number of spans waiting to be sent, further ones are dropped.
+const maxQueuedSpans = 4096
+
+var exporterLock sync.Mutex
+var exporterEndpoint string
+var exporterClient *http.Client
+var exporterOnce sync.Once
+var queue []*Span
+var dropped int
+
+// Configure sets the URL of the OTLP/HTTP collector the spans are sent to, such as
+// http://collector:4318. An empty endpoint disables tracing.
+func Configure(endpoint string, proxy func(req *http.Request) (*url.URL, error)) {
+	exporterLock.Lock()
+	defer exporterLock.Unlock()
+
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if endpoint != "" && !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint = fmt.Sprintf("%s/v1/traces", endpoint)
+	}
+
+	exporterEndpoint = endpoint
+	exporterClient = &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{Proxy: proxy},
+	}
+
+	if endpoint == "" {
+		queue = nil
+		return
+	}
+
+	exporterOnce.Do(func() {
+		go func() {
+			for range time.Tick(exportInterval) {
+				flush()
+			}
+		}()
+	})
+}
+
+// Enabled returns whether spans are being recorded.
+func Enabled() bool {
+	exporterLock.Lock()
+	defer exporterLock.Unlock()
+
+	return exporterEndpoint != ""
+}
+
+func export(s *Span) {
+	exporterLock.Lock()
+	defer exporterLock.Unlock()
+
+	if exporterEndpoint == "" {
+		return
+	}
+
+	if len(queue) >= maxQueuedSpans {
+		dropped++
+		return
+	}
+
+	queue = append(queue, s)
+}
+
+// flush sends the queued spans to the collector.
+func flush() {
+	exporterLock.Lock()
+	spans := queue
+	queue = nil
+	endpoint := exporterEndpoint
+	client := exporterClient
+	lost := dropped
+	dropped = 0
+	exporterLock.Unlock()
+
+	if lost > 0 {
+		logger.Warn("Dropped trace spans, the collector isn't keeping up", log.Ctx{"spans": lost})
+	}
+
+	if len(spans) == 0 || endpoint == "" {
+		return
+	}
+
+	body, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		logger.Error("Failed to encode trace spans", log.Ctx{"err": err})
+		return
+	}
+
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logger.Warn("Failed to export trace spans", log.Ctx{"endpoint": endpoint, "err": err})
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		logger.Warn("Failed to export trace spans", log.Ctx{"endpoint": endpoint, "status": resp.Status})
+	}
+}
+
+// OTLP/HTTP JSON encoding of the spans, see
+// https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/trace/v1/trace.proto
+type otlpKeyValue struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func otlpAttributes(attributes map[string]string) []otlpKeyValue {
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	result := make([]otlpKeyValue, len(keys))
+	for i, k := range keys {
+		result[i].Key = k
+		result[i].Value.StringValue = attributes[k]
+	}
+
+	return result
+}
+
+func otlpRequest(spans []*Span) otlpTraces {
+	hostname, _ := os.Hostname()
+
+	resource := otlpResourceSpans{}
+	resource.Resource.Attributes = otlpAttributes(map[string]string{
+		"service.name": "lxd",
+		"host.name":    hostname,
+	})
+
+	scope := otlpScopeSpans{}
+	scope.Scope.Name = "github.com/lxc/lxd"
+
+	for _, s := range spans {
+		s.lock.Lock()
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              1, // SPAN_KIND_INTERNAL
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.attributes),
+			Status:            otlpStatus{Code: 1}, // STATUS_CODE_OK
+		}
+
+		if s.parentID != [8]byte{} {
+			span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+
+		if s.err != "" {
+			span.Status = otlpStatus{Code: 2, Message: s.err} // STATUS_CODE_ERROR
+		}
+		s.lock.Unlock()
+
+		scope.Spans = append(scope.Spans, span)
+	}
+
+	resource.ScopeSpans = []otlpScopeSpans{scope}
+
+	return otlpTraces{ResourceSpans: []otlpResourceSpans{resource}}
+}
@@ -0,0 +1,160 @@
+// Package tracing records trace spans for API requests, operations, database transactions and
+// subprocesses, and exports them to an OpenTelemetry collector using OTLP over HTTP.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type spanKey struct{}
+
+// Span represents a timed unit of work within a trace. A nil span is valid and does nothing, it's
+// returned when tracing is disabled.
+type Span struct {
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	remote   bool
+	name     string
+	start    time.Time
+	end      time.Time
+
+	lock       sync.Mutex
+	attributes map[string]string
+	err        string
+}
+
+// Start starts a new span with the given name. It's a child of the span carried by the context, if
+// any, or the root of a new trace otherwise. The returned context carries the new span.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	if !Enabled() {
+		return ctx, nil
+	}
+
+	span := &Span{
+		name:       name,
+		start:      time.Now(),
+		attributes: map[string]string{},
+	}
+
+	parent := fromContext(ctx)
+	if parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else {
+		rand.Read(span.traceID[:])
+	}
+
+	rand.Read(span.spanID[:])
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// StartChild is like Start, but only starts a span if the context already carries one. It's used
+// for fine grained work such as database transactions which isn't worth a trace of its own.
+func StartChild(ctx context.Context, name string) (context.Context, *Span) {
+	if fromContext(ctx) == nil {
+		return ctx, nil
+	}
+
+	return Start(ctx, name)
+}
+
+// Inherit returns a copy of dst carrying the span of src, if any.
+func Inherit(dst context.Context, src context.Context) context.Context {
+	span := fromContext(src)
+	if span == nil {
+		return dst
+	}
+
+	return context.WithValue(dst, spanKey{}, span)
+}
+
+// Extract returns a copy of the context carrying the remote parent span described by the given W3C
+// traceparent header, so that spans started from it join the caller's trace. Invalid headers are
+// ignored.
+func Extract(ctx context.Context, traceparent string) context.Context {
+	if traceparent == "" || !Enabled() {
+		return ctx
+	}
+
+	fields := strings.Split(traceparent, "-")
+	if len(fields) != 4 || fields[0] != "00" {
+		return ctx
+	}
+
+	span := &Span{remote: true}
+	traceID, err := hex.DecodeString(fields[1])
+	if err != nil || len(traceID) != len(span.traceID) {
+		return ctx
+	}
+
+	spanID, err := hex.DecodeString(fields[2])
+	if err != nil || len(spanID) != len(span.spanID) {
+		return ctx
+	}
+
+	copy(span.traceID[:], traceID)
+	copy(span.spanID[:], spanID)
+
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// Traceparent returns the W3C traceparent header value to propagate the span carried by the context
+// to another service, or an empty string if there's none.
+func Traceparent(ctx context.Context) string {
+	span := fromContext(ctx)
+	if span == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("00-%x-%x-01", span.traceID, span.spanID)
+}
+
+// SetAttribute records a key/value attribute on the span.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.attributes[key] = fmt.Sprintf("%v", value)
+}
+
+// End marks the span as completed, failed if err isn't nil, and queues it for export.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	if !s.end.IsZero() {
+		s.lock.Unlock()
+		return
+	}
+
+	s.end = time.Now()
+	if err != nil {
+		s.err = err.Error()
+	}
+	s.lock.Unlock()
+
+	export(s)
+}
+
+func fromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Spans started from a traced context join its trace and are sent to the collector.
+func TestExport(t *testing.T) {
+	var received otlpTraces
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	Configure(server.URL, nil)
+	defer Configure("", nil)
+
+	ctx := Extract(context.Background(), "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	ctx, parent := Start(ctx, "GET /1.0/instances")
+	_, child := StartChild(ctx, "db transaction")
+	child.SetAttribute("rows", 2)
+	child.End(fmt.Errorf("boom"))
+	parent.End(nil)
+
+	flush()
+
+	require.Len(t, received.ResourceSpans, 1)
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "db transaction", spans[0].Name)
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", spans[0].TraceID)
+	assert.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+	assert.Equal(t, otlpStatus{Code: 2, Message: "boom"}, spans[0].Status)
+	assert.Equal(t, "rows", spans[0].Attributes[0].Key)
+	assert.Equal(t, "2", spans[0].Attributes[0].Value.StringValue)
+
+	assert.Equal(t, "GET /1.0/instances", spans[1].Name)
+	assert.Equal(t, "b7ad6b7169203331", spans[1].ParentSpanID)
+	assert.Equal(t, fmt.Sprintf("00-0af7651916cd43dd8448eb211c80319c-%s-01", spans[1].SpanID), Traceparent(ctx))
+}
+
+// Nothing is recorded when tracing is disabled, or for child spans without a parent.
+func TestDisabled(t *testing.T) {
+	ctx, span := Start(context.Background(), "test")
+	assert.Nil(t, span)
+	span.SetAttribute("key", "value")
+	span.End(nil)
+
+	Configure("http://127.0.0.1:4318", nil)
+	defer Configure("", nil)
+
+	_, span = StartChild(ctx, "test")
+	assert.Nil(t, span)
+	assert.Equal(t, "", Traceparent(ctx))
+}
@@ -2,9 +2,14 @@ package util
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"golang.org/x/sys/unix"
 
+	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
 )
 
@@ -44,3 +49,44 @@ func FilesystemDetect(path string) (string, error) {
 		return fmt.Sprintf("0x%x", fs.Type), nil
 	}
 }
+
+// BlockDeviceScheduler returns the active I/O scheduler of the block device backing the given path,
+// or an empty string if it isn't backed by a block device with a scheduler.
+func BlockDeviceScheduler(path string) (string, error) {
+	var stat unix.Stat_t
+	err := unix.Stat(path, &stat)
+	if err != nil {
+		return "", err
+	}
+
+	devPath := fmt.Sprintf("/sys/dev/block/%d:%d", unix.Major(uint64(stat.Dev)), unix.Minor(uint64(stat.Dev)))
+
+	// Partitions use the queue of their parent device.
+	schedulerPath := filepath.Join(devPath, "queue", "scheduler")
+	if !shared.PathExists(schedulerPath) {
+		schedulerPath = filepath.Join(devPath, "..", "queue", "scheduler")
+	}
+
+	content, err := ioutil.ReadFile(schedulerPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return parseBlockScheduler(string(content)), nil
+}
+
+// parseBlockScheduler returns the active scheduler, shown between brackets, out of the content of
+// a queue/scheduler sysfs file.
+func parseBlockScheduler(content string) string {
+	for _, field := range strings.Fields(content) {
+		if strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]") {
+			return strings.Trim(field, "[]")
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,20 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBlockScheduler(t *testing.T) {
+	cases := map[string]string{
+		"mq-deadline kyber [bfq] none\n": "bfq",
+		"[none] mq-deadline\n":           "none",
+		"none\n":                         "",
+		"":                               "",
+	}
+
+	for content, expected := range cases {
+		assert.Equal(t, expected, parseBlockScheduler(content), content)
+	}
+}
@@ -0,0 +1,68 @@
+package util
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Files describing the timezone and locale of the host, replaced in tests.
+var (
+	hostLocaltimePath = "/etc/localtime"
+	hostTimezonePath  = "/etc/timezone"
+	hostLocalePaths   = []string{"/etc/default/locale", "/etc/locale.conf"}
+)
+
+// HostTimezone returns the name of the timezone of the host, e.g. "Europe/Paris".
+func HostTimezone() (string, error) {
+	// Most distributions have /etc/localtime point to the zone.
+	target, err := os.Readlink(hostLocaltimePath)
+	if err == nil {
+		idx := strings.Index(target, "zoneinfo/")
+		if idx >= 0 {
+			return target[idx+len("zoneinfo/"):], nil
+		}
+	}
+
+	content, err := ioutil.ReadFile(hostTimezonePath)
+	if err == nil && strings.TrimSpace(string(content)) != "" {
+		return strings.TrimSpace(string(content)), nil
+	}
+
+	return "", fmt.Errorf("Couldn't determine the timezone of the host")
+}
+
+// HostTimezoneData returns the content of the zone file of the timezone of the host.
+func HostTimezoneData() ([]byte, error) {
+	return ioutil.ReadFile(hostLocaltimePath)
+}
+
+// HostLocale returns the default locale of the host, e.g. "en_US.UTF-8".
+func HostLocale() (string, error) {
+	for _, path := range hostLocalePaths {
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "LANG=") {
+				continue
+			}
+
+			value := strings.Trim(strings.TrimPrefix(line, "LANG="), "\"'")
+			if value != "" {
+				file.Close()
+				return value, nil
+			}
+		}
+
+		file.Close()
+	}
+
+	return "", fmt.Errorf("Couldn't determine the locale of the host")
+}
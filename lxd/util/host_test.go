@@ -0,0 +1,60 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The timezone is taken from the /etc/localtime symlink, falling back to /etc/timezone.
+func TestHostTimezone(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-util-host-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(localtime string, timezone string) {
+		hostLocaltimePath = localtime
+		hostTimezonePath = timezone
+	}(hostLocaltimePath, hostTimezonePath)
+
+	hostLocaltimePath = filepath.Join(dir, "localtime")
+	hostTimezonePath = filepath.Join(dir, "timezone")
+
+	_, err = HostTimezone()
+	assert.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(hostTimezonePath, []byte("Europe/London\n"), 0644))
+	timezone, err := HostTimezone()
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/London", timezone)
+
+	require.NoError(t, os.Symlink("/usr/share/zoneinfo/America/New_York", hostLocaltimePath))
+	timezone, err = HostTimezone()
+	require.NoError(t, err)
+	assert.Equal(t, "America/New_York", timezone)
+}
+
+// The locale is the LANG setting of the first locale file defining one.
+func TestHostLocale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-util-host-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(paths []string) {
+		hostLocalePaths = paths
+	}(hostLocalePaths)
+
+	hostLocalePaths = []string{filepath.Join(dir, "locale"), filepath.Join(dir, "locale.conf")}
+
+	_, err = HostLocale()
+	assert.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(hostLocalePaths[1], []byte("# Generated\nLANG=\"fr_FR.UTF-8\"\n"), 0644))
+	locale, err := HostLocale()
+	require.NoError(t, err)
+	assert.Equal(t, "fr_FR.UTF-8", locale)
+}
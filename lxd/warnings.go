@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/response"
+	storagePools "github.com/lxc/lxd/lxd/storage"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/lxd/warnings"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+)
+
+var warningsCmd = APIEndpoint{
+	Path: "warnings",
+
+	Get: APIEndpointAction{Handler: warningsGet, AccessHandler: allowWarningsList},
+}
+
+var warningCmd = APIEndpoint{
+	Path: "warnings/{uuid}",
+
+	Delete: APIEndpointAction{Handler: warningDelete, AccessHandler: allowAdmin},
+	Get:    APIEndpointAction{Handler: warningGet, AccessHandler: allowAdmin},
+	Patch:  APIEndpointAction{Handler: warningPut, AccessHandler: allowAdmin},
+	Put:    APIEndpointAction{Handler: warningPut, AccessHandler: allowAdmin},
+}
+
+// allowWarningsList lets admins list all warnings, and other users list the
+// warnings of a project they can view.
+func allowWarningsList(d *Daemon, r *http.Request) response.Response {
+	if d.userIsAdmin(r) {
+		return response.EmptySyncResponse
+	}
+
+	if r.FormValue("project") == "" {
+		return response.Forbidden(fmt.Errorf("Only the warnings of a given project can be listed"))
+	}
+
+	return allowProjectPermission("", "view")(d, r)
+}
+
+// Percentage of used space above which a storage pool is considered nearly full.
+const storagePoolNearlyFullThreshold = 90
+
+func warningsGet(d *Daemon, r *http.Request) response.Response {
+	recursion := util.IsRecursionRequest(r)
+
+	// Only return warnings of a given project if requested.
+	project := r.FormValue("project")
+
+	var dbWarnings []db.Warning
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		dbWarnings, err = tx.Warnings()
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	resultString := []string{}
+	resultMap := []api.Warning{}
+	for _, dbWarning := range dbWarnings {
+		if project != "" && dbWarning.Project != project {
+			continue
+		}
+
+		if !recursion {
+			resultString = append(resultString, fmt.Sprintf("/%s/warnings/%s", version.APIVersion, dbWarning.UUID))
+		} else {
+			resultMap = append(resultMap, warningToAPI(dbWarning))
+		}
+	}
+
+	if !recursion {
+		return response.SyncResponse(true, resultString)
+	}
+
+	return response.SyncResponse(true, resultMap)
+}
+
+func warningGet(d *Daemon, r *http.Request) response.Response {
+	uuid := mux.Vars(r)["uuid"]
+
+	var dbWarning db.Warning
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		dbWarning, err = tx.WarningByUUID(uuid)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, warningToAPI(dbWarning))
+}
+
+// Acknowledge a warning, or set it back to new.
+func warningPut(d *Daemon, r *http.Request) response.Response {
+	uuid := mux.Vars(r)["uuid"]
+
+	req := api.WarningPut{}
+	err := shared.ReadToJSON(r.Body, &req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if !shared.StringInSlice(req.Status, []string{db.WarningStatusNew, db.WarningStatusAcknowledged}) {
+		return response.BadRequest(fmt.Errorf("Invalid warning status %q", req.Status))
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.WarningUpdateStatus(uuid, req.Status)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func warningDelete(d *Daemon, r *http.Request) response.Response {
+	uuid := mux.Vars(r)["uuid"]
+
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.WarningDelete(uuid)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func warningToAPI(dbWarning db.Warning) api.Warning {
+	return api.Warning{
+		WarningPut: api.WarningPut{
+			Status: dbWarning.Status,
+		},
+		UUID:        dbWarning.UUID,
+		Location:    dbWarning.Node,
+		Project:     dbWarning.Project,
+		EntityURL:   dbWarning.EntityURL,
+		Type:        dbWarning.Type,
+		Severity:    dbWarning.Severity,
+		Message:     dbWarning.Message,
+		Count:       dbWarning.Count,
+		FirstSeenAt: dbWarning.FirstSeenDate,
+		LastSeenAt:  dbWarning.LastSeenDate,
+	}
+}
+
+// Raise a warning for each storage pool whose space is nearly exhausted on
+// this node, and resolve it once space is available again.
+func checkStoragePoolsUsage(d *Daemon) {
+	poolNames, err := d.cluster.StoragePools()
+	if err != nil {
+		if err != db.ErrNoSuchObject {
+			logger.Error("Failed to retrieve the list of storage pools", log.Ctx{"err": err})
+		}
+
+		return
+	}
+
+	for _, poolName := range poolNames {
+		pool, err := storagePools.GetPoolByName(d.State(), poolName)
+		if err != nil {
+			logger.Error("Failed to load storage pool", log.Ctx{"pool": poolName, "err": err})
+			continue
+		}
+
+		res, err := pool.GetResources()
+		if err != nil || res.Space.Total == 0 {
+			continue
+		}
+
+		entityURL := fmt.Sprintf("/%s/storage-pools/%s", version.APIVersion, poolName)
+		used := res.Space.Used * 100 / res.Space.Total
+		if used < storagePoolNearlyFullThreshold {
+			warnings.Resolve(d.cluster, "", entityURL, warnings.TypeStoragePoolNearlyFull)
+			continue
+		}
+
+		severity := warnings.SeverityModerate
+		if used >= 98 {
+			severity = warnings.SeverityHigh
+		}
+
+		warnings.Raise(d.cluster, "", entityURL, warnings.TypeStoragePoolNearlyFull, severity, fmt.Sprintf("Storage pool %q is %d%% full", poolName, used))
+	}
+}
+
+func checkStoragePoolsUsageTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		checkStoragePoolsUsage(d)
+	}
+
+	return f, task.Every(time.Hour)
+}
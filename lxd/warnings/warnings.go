@@ -0,0 +1,47 @@
+package warnings
+
+import (
+	"github.com/lxc/lxd/lxd/db"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// Types of warnings.
+const (
+	TypeStoragePoolNearlyFull = "storage-pool-nearly-full"
+	TypeImageRefreshFailed    = "image-refresh-failed"
+	TypeLimitNotApplied       = "limit-not-applied"
+)
+
+// Severities of warnings.
+const (
+	SeverityLow      = "low"
+	SeverityModerate = "moderate"
+	SeverityHigh     = "high"
+)
+
+// Raise records a warning of the given type about the given entity on this
+// node, or bumps the count of an existing unresolved one. Failures to record
+// the warning are only logged, since warnings are raised from code paths which
+// are already handling an error.
+func Raise(cluster *db.Cluster, project string, entityURL string, typ string, severity string, message string) {
+	logger.Warn(message, log.Ctx{"project": project, "entity": entityURL, "type": typ})
+
+	err := cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.WarningUpsert(project, entityURL, typ, severity, message)
+	})
+	if err != nil {
+		logger.Error("Failed to record warning", log.Ctx{"project": project, "entity": entityURL, "type": typ, "err": err})
+	}
+}
+
+// Resolve marks any unresolved warning of the given type about the given
+// entity on this node as resolved, since the condition is gone.
+func Resolve(cluster *db.Cluster, project string, entityURL string, typ string) {
+	err := cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.WarningResolve(project, entityURL, typ)
+	})
+	if err != nil {
+		logger.Error("Failed to resolve warning", log.Ctx{"project": project, "entity": entityURL, "type": typ, "err": err})
+	}
+}
@@ -76,3 +76,35 @@ type ClusterMemberPut struct {
 	// API extension: clustering_architecture
 	Architecture string `json:"architecture" yaml:"architecture"`
 }
+
+// ClusterGroupsPost represents the fields available for a new LXD cluster group.
+//
+// API extension: cluster_groups
+type ClusterGroupsPost struct {
+	ClusterGroupPut `yaml:",inline"`
+
+	Name string `json:"name" yaml:"name"`
+}
+
+// ClusterGroupPut represents the modifiable fields of a LXD cluster group.
+//
+// API extension: cluster_groups
+type ClusterGroupPut struct {
+	Description string   `json:"description" yaml:"description"`
+	Members     []string `json:"members" yaml:"members"`
+}
+
+// ClusterGroup represents a named group of LXD cluster members.
+//
+// API extension: cluster_groups
+type ClusterGroup struct {
+	ClusterGroupPut `yaml:",inline"`
+
+	Name string `json:"name" yaml:"name"`
+}
+
+// Writable converts a full ClusterGroup struct into a ClusterGroupPut struct (filters read-only
+// fields).
+func (group *ClusterGroup) Writable() ClusterGroupPut {
+	return group.ClusterGroupPut
+}
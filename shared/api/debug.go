@@ -0,0 +1,39 @@
+package api
+
+import (
+	"time"
+)
+
+// DebugMetrics represents the runtime metrics of the LXD daemon
+//
+// API extension: debug_metrics
+type DebugMetrics struct {
+	Goroutines           int                          `json:"goroutines" yaml:"goroutines"`
+	FileDescriptors      int                          `json:"file_descriptors" yaml:"file_descriptors"`
+	FileDescriptorsLimit uint64                       `json:"file_descriptors_limit" yaml:"file_descriptors_limit"`
+	Operations           int                          `json:"operations" yaml:"operations"`
+	Memory               DebugMetricsMemory           `json:"memory" yaml:"memory"`
+	GarbageCollector     DebugMetricsGarbageCollector `json:"garbage_collector" yaml:"garbage_collector"`
+}
+
+// DebugMetricsMemory represents the memory usage of the LXD daemon, in bytes
+//
+// API extension: debug_metrics
+type DebugMetricsMemory struct {
+	HeapAllocated uint64 `json:"heap_allocated" yaml:"heap_allocated"`
+	HeapIdle      uint64 `json:"heap_idle" yaml:"heap_idle"`
+	HeapReleased  uint64 `json:"heap_released" yaml:"heap_released"`
+	StackInUse    uint64 `json:"stack_in_use" yaml:"stack_in_use"`
+	System        uint64 `json:"system" yaml:"system"`
+}
+
+// DebugMetricsGarbageCollector represents the activity of the garbage collector of the LXD daemon
+//
+// API extension: debug_metrics
+type DebugMetricsGarbageCollector struct {
+	Runs       uint32        `json:"runs" yaml:"runs"`
+	LastRun    time.Time     `json:"last_run" yaml:"last_run"`
+	LastPause  time.Duration `json:"last_pause" yaml:"last_pause"`
+	TotalPause time.Duration `json:"total_pause" yaml:"total_pause"`
+	NextTarget uint64        `json:"next_target" yaml:"next_target"`
+}
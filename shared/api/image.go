@@ -105,6 +105,9 @@ type ImageAliasesEntryPost struct {
 type ImageAliasesEntryPut struct {
 	Description string `json:"description" yaml:"description"`
 	Target      string `json:"target" yaml:"target"`
+
+	// API extension: image_alias_channels
+	Channels map[string]string `json:"channels" yaml:"channels"`
 }
 
 // ImageAliasesEntry represents a LXD image alias
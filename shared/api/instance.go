@@ -28,6 +28,21 @@ type InstancesPost struct {
 	Type         InstanceType   `json:"type" yaml:"type"`
 }
 
+// InstancesPut represents the fields available for a mass update of LXD instances.
+//
+// API extension: instance_bulk_state_change
+type InstancesPut struct {
+	State *InstanceStatePut `json:"state" yaml:"state"`
+
+	// Names of the instances to update, or "*" for all the instances of the project
+	Instances []string `json:"instances" yaml:"instances"`
+
+	// Name of the group whose members to update, instead of listing them
+	//
+	// API extension: instance_groups
+	Group string `json:"group" yaml:"group"`
+}
+
 // InstancePost represents the fields required to rename/move a LXD instance.
 //
 // API extension: instances
@@ -38,6 +53,12 @@ type InstancePost struct {
 	InstanceOnly  bool                `json:"instance_only" yaml:"instance_only"`
 	ContainerOnly bool                `json:"container_only" yaml:"container_only"` // Deprecated, use InstanceOnly.
 	Target        *InstancePostTarget `json:"target" yaml:"target"`
+
+	// API extension: instance_project_move
+	Project string `json:"project" yaml:"project"`
+
+	// API extension: migration_max_downtime
+	MaxDowntime int64 `json:"max_downtime" yaml:"max_downtime"`
 }
 
 // InstancePostTarget represents the migration target host and operation.
@@ -61,6 +82,9 @@ type InstancePut struct {
 	Restore      string                       `json:"restore,omitempty" yaml:"restore,omitempty"`
 	Stateful     bool                         `json:"stateful" yaml:"stateful"`
 	Description  string                       `json:"description" yaml:"description"`
+
+	// API extension: snapshot_restore_paths
+	RestorePaths []string `json:"restore_paths,omitempty" yaml:"restore_paths,omitempty"`
 }
 
 // Instance represents a LXD instance.
@@ -134,4 +158,8 @@ type InstanceSource struct {
 	ContainerOnly bool              `json:"container_only,omitempty" yaml:"container_only,omitempty"` // Deprecated, use InstanceOnly.
 	Refresh       bool              `json:"refresh,omitempty" yaml:"refresh,omitempty"`
 	Project       string            `json:"project,omitempty" yaml:"project,omitempty"`
+
+	// API extension: operation_not_before
+	NotBefore time.Time `json:"not_before,omitempty" yaml:"not_before,omitempty"`
+	OffPeak   bool      `json:"off_peak,omitempty" yaml:"off_peak,omitempty"`
 }
@@ -0,0 +1,24 @@
+package api
+
+// InstanceClonesPost represents the fields required to create several copies of a LXD instance
+// in a single operation.
+//
+// API extension: instance_clones
+type InstanceClonesPost struct {
+	// Number of copies to create.
+	Count int `json:"count" yaml:"count"`
+
+	// Pattern for the names of the copies, "{index}" being replaced by the index of each copy
+	// (starting at 1).
+	Name string `json:"name" yaml:"name"`
+
+	// Config applied to all the copies on top of the one of the source instance, "{index}" and
+	// "{name}" in the values being replaced by the index and name of each copy.
+	Config map[string]string `json:"config" yaml:"config"`
+
+	// Config applied to individual copies, indexed by their name.
+	Overrides map[string]map[string]string `json:"overrides" yaml:"overrides"`
+
+	// Whether to leave the snapshots of the source instance out of the copies.
+	InstanceOnly bool `json:"instance_only" yaml:"instance_only"`
+}
@@ -23,4 +23,11 @@ type InstanceExecPost struct {
 	User         uint32            `json:"user" yaml:"user"`
 	Group        uint32            `json:"group" yaml:"group"`
 	Cwd          string            `json:"cwd" yaml:"cwd"`
+
+	// API extension: exec_terminal_framing
+	Framing string `json:"framing" yaml:"framing"`
+
+	// Whether to start from the environment of the container's init process
+	// API extension: exec_environment_inherit
+	EnvironmentInherit bool `json:"environment-inherit" yaml:"environment-inherit"`
 }
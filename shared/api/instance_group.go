@@ -0,0 +1,37 @@
+package api
+
+// InstanceGroupsPost represents the fields available for a new LXD instance group.
+//
+// API extension: instance_groups
+type InstanceGroupsPost struct {
+	InstanceGroupPut `yaml:",inline"`
+
+	Name string `json:"name" yaml:"name"`
+}
+
+// InstanceGroupPut represents the modifiable fields of a LXD instance group.
+//
+// API extension: instance_groups
+type InstanceGroupPut struct {
+	Description string            `json:"description" yaml:"description"`
+	Config      map[string]string `json:"config" yaml:"config"`
+}
+
+// InstanceGroup represents a LXD instance group. Instances join groups through their groups
+// config key.
+//
+// API extension: instance_groups
+type InstanceGroup struct {
+	InstanceGroupPut `yaml:",inline"`
+
+	Name string `json:"name" yaml:"name"`
+
+	// URLs of the member instances.
+	UsedBy []string `json:"used_by" yaml:"used_by"`
+}
+
+// Writable converts a full InstanceGroup struct into a InstanceGroupPut struct (filters read-only
+// fields).
+func (group *InstanceGroup) Writable() InstanceGroupPut {
+	return group.InstanceGroupPut
+}
@@ -0,0 +1,20 @@
+package api
+
+import "time"
+
+// InstanceHistoryEntry represents a single state change of a LXD instance.
+//
+// API extension: instance_history
+type InstanceHistoryEntry struct {
+	// Sequence number, entries with a lower ID happened before.
+	ID int64 `json:"id" yaml:"id"`
+
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+	Action    string    `json:"action" yaml:"action"`
+
+	// URL of the operation which performed the change, if any.
+	Operation string `json:"operation" yaml:"operation"`
+
+	// User who requested the change, empty if initiated by the instance itself.
+	Requestor string `json:"requestor" yaml:"requestor"`
+}
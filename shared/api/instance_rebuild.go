@@ -0,0 +1,11 @@
+package api
+
+// InstanceRebuildPost represents the fields required to rebuild a LXD instance from a new image,
+// keeping its configuration and devices.
+//
+// API extension: instance_rebuild
+type InstanceRebuildPost struct {
+	// Image to rebuild the instance from, using the same fields as when creating an instance from
+	// an image. If no image is specified, the image the instance was created from is used.
+	Source InstanceSource `json:"source" yaml:"source"`
+}
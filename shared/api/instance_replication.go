@@ -0,0 +1,58 @@
+package api
+
+import (
+	"time"
+)
+
+// InstanceReplicationsPost represents the fields available for a new replication of a LXD
+// instance.
+//
+// API extension: instance_replications
+type InstanceReplicationsPost struct {
+	InstanceReplicationPut `yaml:",inline"`
+
+	Name string `json:"name" yaml:"name"`
+}
+
+// InstanceReplicationPut represents the modifiable fields of a replication of a LXD instance.
+//
+// API extension: instance_replications
+type InstanceReplicationPut struct {
+	Description string `json:"description" yaml:"description"`
+
+	// Address of the target server, which must trust the certificate of this server.
+	Target string `json:"target" yaml:"target"`
+
+	// Certificate of the target server.
+	TargetCertificate string `json:"target_certificate" yaml:"target_certificate"`
+
+	// Project of the replica on the target server, "default" if empty.
+	TargetProject string `json:"target_project" yaml:"target_project"`
+
+	// Minutes between syncs of the replica.
+	Interval int `json:"interval" yaml:"interval"`
+
+	// Shell patterns of the names of the snapshots to replicate, none if empty.
+	Snapshots []string `json:"snapshots" yaml:"snapshots"`
+}
+
+// InstanceReplication represents a replication of a LXD instance.
+//
+// API extension: instance_replications
+type InstanceReplication struct {
+	InstanceReplicationPut `yaml:",inline"`
+
+	Name string `json:"name" yaml:"name"`
+
+	// Time of the last sync of the replica, zero if it never happened.
+	LastSyncAt time.Time `json:"last_sync_at" yaml:"last_sync_at"`
+
+	// Error of the last sync, empty if it succeeded.
+	LastError string `json:"last_error" yaml:"last_error"`
+}
+
+// Writable converts a full InstanceReplication struct into a InstanceReplicationPut struct
+// (filters read-only fields).
+func (replication *InstanceReplication) Writable() InstanceReplicationPut {
+	return replication.InstanceReplicationPut
+}
@@ -0,0 +1,38 @@
+package api
+
+// InstanceSchedulesPost represents the fields available for a new schedule of a LXD instance.
+//
+// API extension: instance_schedules
+type InstanceSchedulesPost struct {
+	InstanceSchedulePut `yaml:",inline"`
+
+	Name string `json:"name" yaml:"name"`
+}
+
+// InstanceSchedulePut represents the modifiable fields of a schedule of a LXD instance.
+//
+// API extension: instance_schedules
+type InstanceSchedulePut struct {
+	Description string `json:"description" yaml:"description"`
+
+	// Action to perform, one of "start", "stop" or "snapshot".
+	Action string `json:"action" yaml:"action"`
+
+	// Cron expression of when to perform the action, as for snapshots.schedule.
+	Schedule string `json:"schedule" yaml:"schedule"`
+}
+
+// InstanceSchedule represents a schedule of a LXD instance.
+//
+// API extension: instance_schedules
+type InstanceSchedule struct {
+	InstanceSchedulePut `yaml:",inline"`
+
+	Name string `json:"name" yaml:"name"`
+}
+
+// Writable converts a full InstanceSchedule struct into a InstanceSchedulePut struct (filters
+// read-only fields).
+func (schedule *InstanceSchedule) Writable() InstanceSchedulePut {
+	return schedule.InstanceSchedulePut
+}
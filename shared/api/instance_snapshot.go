@@ -52,6 +52,9 @@ type InstanceSnapshot struct {
 
 	// API extension: snapshot_disk_usage
 	Size int64 `json:"size" yaml:"size"`
+
+	// API extension: snapshots_before_changes
+	Description string `json:"description" yaml:"description"`
 }
 
 // Writable converts a full InstanceSnapshot struct into a InstanceSnapshotPut struct
@@ -61,3 +64,32 @@ type InstanceSnapshot struct {
 func (c *InstanceSnapshot) Writable() InstanceSnapshotPut {
 	return c.InstanceSnapshotPut
 }
+
+// InstanceSnapshotDiffEntry represents a path which differs between a snapshot and another
+// snapshot or the instance itself.
+//
+// API extension: instance_snapshot_diff
+type InstanceSnapshotDiffEntry struct {
+	Path string `json:"path" yaml:"path"`
+
+	// One of "added", "changed" or "removed".
+	Change string `json:"change" yaml:"change"`
+}
+
+// InstanceSnapshotFilesPost represents the paths to restore from a snapshot into its instance.
+//
+// API extension: instance_snapshot_files
+type InstanceSnapshotFilesPost struct {
+	Paths []string `json:"paths" yaml:"paths"`
+
+	// Path to restore to, instead of the original path. Only valid when restoring a single path.
+	Target string `json:"target" yaml:"target"`
+}
+
+// InstanceSnapshotMountPost represents the request to expose a snapshot inside its instance.
+//
+// API extension: instance_snapshot_mount
+type InstanceSnapshotMountPost struct {
+	// Path inside the instance, defaults to /snap-restore.
+	Path string `json:"path" yaml:"path"`
+}
@@ -1,5 +1,9 @@
 package api
 
+import (
+	"time"
+)
+
 // InstanceStatePut represents the modifiable fields of a LXD instance's state.
 //
 // API extension: instances
@@ -8,6 +12,9 @@ type InstanceStatePut struct {
 	Timeout  int    `json:"timeout" yaml:"timeout"`
 	Force    bool   `json:"force" yaml:"force"`
 	Stateful bool   `json:"stateful" yaml:"stateful"`
+
+	// API extension: instance_stop_signal
+	Signal string `json:"signal" yaml:"signal"`
 }
 
 // InstanceState represents a LXD instance's state.
@@ -22,6 +29,55 @@ type InstanceState struct {
 	Pid        int64                           `json:"pid" yaml:"pid"`
 	Processes  int64                           `json:"processes" yaml:"processes"`
 	CPU        InstanceStateCPU                `json:"cpu" yaml:"cpu"`
+
+	// How the init process of the instance last exited, if known
+	// API extension: container_last_exit
+	LastExit *InstanceStateLastExit `json:"last_exit,omitempty" yaml:"last_exit,omitempty"`
+
+	// Pressure stall information, keyed by resource (cpu, memory or io)
+	// API extension: instance_state_pressure
+	Pressure map[string]InstanceStatePressure `json:"pressure,omitempty" yaml:"pressure,omitempty"`
+}
+
+// InstanceStatePressure represents the pressure stall information of a resource, as the time
+// some or all of the instance's tasks were stalled on it.
+//
+// API extension: instance_state_pressure
+type InstanceStatePressure struct {
+	Some InstanceStatePressureStall  `json:"some" yaml:"some"`
+	Full *InstanceStatePressureStall `json:"full,omitempty" yaml:"full,omitempty"`
+}
+
+// InstanceStatePressureStall represents the share of time tasks were stalled on a resource.
+//
+// API extension: instance_state_pressure
+type InstanceStatePressureStall struct {
+	// Percentage of time over the last 10s, 60s and 300s
+	Avg10  float64 `json:"avg10" yaml:"avg10"`
+	Avg60  float64 `json:"avg60" yaml:"avg60"`
+	Avg300 float64 `json:"avg300" yaml:"avg300"`
+
+	// Total stall time in microseconds
+	Total int64 `json:"total" yaml:"total"`
+}
+
+// InstanceStateLastExit represents how the init process of an instance last exited.
+//
+// API extension: container_last_exit
+type InstanceStateLastExit struct {
+	Time time.Time `json:"time" yaml:"time"`
+
+	// Exit code, if init exited on its own
+	Code int `json:"code" yaml:"code"`
+
+	// Signal which terminated init, if any
+	Signal int `json:"signal" yaml:"signal"`
+
+	// Whether init exited without LXD or the instance itself asking for it
+	Crashed bool `json:"crashed" yaml:"crashed"`
+
+	// Name of the log file holding the core dump of init, if one was captured
+	CoreDump string `json:"core_dump" yaml:"core_dump"`
 }
 
 // InstanceStateDisk represents the disk information section of a LXD instance's state.
@@ -46,6 +102,10 @@ type InstanceStateMemory struct {
 	UsagePeak     int64 `json:"usage_peak" yaml:"usage_peak"`
 	SwapUsage     int64 `json:"swap_usage" yaml:"swap_usage"`
 	SwapUsagePeak int64 `json:"swap_usage_peak" yaml:"swap_usage_peak"`
+
+	// Number of processes killed by the OOM killer because of the memory limit
+	// API extension: container_oom_events
+	OOMKills int64 `json:"oom_kills" yaml:"oom_kills"`
 }
 
 // InstanceStateNetwork represents the network information section of a LXD instance's state.
@@ -59,6 +119,22 @@ type InstanceStateNetwork struct {
 	Mtu       int                           `json:"mtu" yaml:"mtu"`
 	State     string                        `json:"state" yaml:"state"`
 	Type      string                        `json:"type" yaml:"type"`
+
+	// Name of the LXD device backing the interface, if any
+	// API extension: instance_state_nic_details
+	Device string `json:"device" yaml:"device"`
+
+	// Operational state of the link (e.g. "up", "down" or "lowerlayerdown")
+	// API extension: instance_state_nic_details
+	LinkState string `json:"link_state" yaml:"link_state"`
+
+	// Link speed in Mbit/s, 0 if unknown
+	// API extension: instance_state_nic_details
+	Speed int64 `json:"speed" yaml:"speed"`
+
+	// Counters of the host side interface (received is what the instance sent)
+	// API extension: instance_state_nic_details
+	HostCounters *InstanceStateNetworkCounters `json:"host_counters,omitempty" yaml:"host_counters,omitempty"`
 }
 
 // InstanceStateNetworkAddress represents a network address as part of the network section of a LXD
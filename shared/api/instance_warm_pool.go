@@ -0,0 +1,11 @@
+package api
+
+// InstanceWarmPoolClaimPost represents the fields required to claim an instance from the warm pool
+// of a LXD instance.
+//
+// API extension: instance_warm_pool
+type InstanceWarmPoolClaimPost struct {
+	// New name of the claimed instance, keeping its pool name if empty. Instances of frozen pools
+	// can't be renamed.
+	Name string `json:"name" yaml:"name"`
+}
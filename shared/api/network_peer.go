@@ -0,0 +1,35 @@
+package api
+
+// NetworkPeersPost represents the fields of a new LXD network peering.
+//
+// API extension: network_peers
+type NetworkPeersPost struct {
+	NetworkPeerPut `yaml:",inline"`
+
+	Name string `json:"name" yaml:"name"`
+
+	// Name of the network to peer with.
+	TargetNetwork string `json:"target_network" yaml:"target_network"`
+}
+
+// NetworkPeerPut represents the modifiable fields of a LXD network peering.
+//
+// API extension: network_peers
+type NetworkPeerPut struct {
+	Description string `json:"description" yaml:"description"`
+}
+
+// NetworkPeer represents a peering between two LXD networks.
+//
+// API extension: network_peers
+type NetworkPeer struct {
+	NetworkPeerPut `yaml:",inline"`
+
+	Name          string `json:"name" yaml:"name"`
+	TargetNetwork string `json:"target_network" yaml:"target_network"`
+}
+
+// Writable converts a full NetworkPeer struct into a NetworkPeerPut struct (filters read-only fields).
+func (peer *NetworkPeer) Writable() NetworkPeerPut {
+	return peer.NetworkPeerPut
+}
@@ -0,0 +1,40 @@
+package api
+
+// NetworkReservationsPost represents the fields of a new LXD network address reservation.
+//
+// API extension: network_address_reservations
+type NetworkReservationsPost struct {
+	NetworkReservationPut `yaml:",inline"`
+
+	Name string `json:"name" yaml:"name"`
+
+	// Specific addresses to reserve, one is picked from the network's reservation ranges if empty.
+	IPv4Address string `json:"ipv4_address" yaml:"ipv4_address"`
+	IPv6Address string `json:"ipv6_address" yaml:"ipv6_address"`
+}
+
+// NetworkReservationPut represents the modifiable fields of a LXD network address reservation.
+//
+// API extension: network_address_reservations
+type NetworkReservationPut struct {
+	Description string `json:"description" yaml:"description"`
+}
+
+// NetworkReservation represents an address reserved on a LXD network.
+//
+// API extension: network_address_reservations
+type NetworkReservation struct {
+	NetworkReservationPut `yaml:",inline"`
+
+	Name        string `json:"name" yaml:"name"`
+	IPv4Address string `json:"ipv4_address" yaml:"ipv4_address"`
+	IPv6Address string `json:"ipv6_address" yaml:"ipv6_address"`
+
+	// URL of the instance using the reserved addresses, if any.
+	UsedBy []string `json:"used_by" yaml:"used_by"`
+}
+
+// Writable converts a full NetworkReservation struct into a NetworkReservationPut struct (filters read-only fields).
+func (reservation *NetworkReservation) Writable() NetworkReservationPut {
+	return reservation.NetworkReservationPut
+}
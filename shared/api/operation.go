@@ -20,4 +20,7 @@ type Operation struct {
 
 	// API extension: operation_location
 	Location string `json:"location" yaml:"location"`
+
+	// API extension: operations_history
+	Requestor string `json:"requestor,omitempty" yaml:"requestor,omitempty"`
 }
@@ -40,6 +40,9 @@ type ServerEnvironment struct {
 	ServerVersion  string `json:"server_version" yaml:"server_version"`
 	Storage        string `json:"storage" yaml:"storage"`
 	StorageVersion string `json:"storage_version" yaml:"storage_version"`
+
+	// API extension: server_tools
+	Tools map[string]string `json:"tools" yaml:"tools"`
 }
 
 // ServerPut represents the modifiable fields of a LXD server configuration
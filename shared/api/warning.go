@@ -0,0 +1,40 @@
+package api
+
+import "time"
+
+// WarningPut represents the modifiable fields of a LXD warning.
+//
+// API extension: warnings
+type WarningPut struct {
+	// One of "new", "acknowledged" or "resolved".
+	Status string `json:"status" yaml:"status"`
+}
+
+// Warning represents a degraded condition detected by LXD.
+//
+// API extension: warnings
+type Warning struct {
+	WarningPut `yaml:",inline"`
+
+	UUID     string `json:"uuid" yaml:"uuid"`
+	Location string `json:"location" yaml:"location"`
+	Project  string `json:"project" yaml:"project"`
+
+	// API URL of the affected entity, if any.
+	EntityURL string `json:"entity_url" yaml:"entity_url"`
+
+	Type     string `json:"type" yaml:"type"`
+	Severity string `json:"severity" yaml:"severity"`
+	Message  string `json:"message" yaml:"message"`
+
+	// Number of times the condition was detected.
+	Count int `json:"count" yaml:"count"`
+
+	FirstSeenAt time.Time `json:"first_seen_at" yaml:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at" yaml:"last_seen_at"`
+}
+
+// Writable converts a full Warning struct into a WarningPut struct (filters read-only fields).
+func (warning *Warning) Writable() WarningPut {
+	return warning.WarningPut
+}
@@ -1,6 +1,7 @@
 package shared
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -13,6 +14,12 @@ import (
 )
 
 func Unpack(file string, path string, blockBackend bool, runningInUserns bool, tracker *ioprogress.ProgressTracker) error {
+	return UnpackContext(context.Background(), file, path, blockBackend, runningInUserns, tracker)
+}
+
+// UnpackContext is like Unpack, but the extraction is killed if the context is cancelled before it
+// completes.
+func UnpackContext(ctx context.Context, file string, path string, blockBackend bool, runningInUserns bool, tracker *ioprogress.ProgressTracker) error {
 	extractArgs, extension, _, err := DetectCompression(file)
 	if err != nil {
 		return err
@@ -74,7 +81,7 @@ func Unpack(file string, path string, blockBackend bool, runningInUserns bool, t
 		return fmt.Errorf("Unsupported image format: %s", extension)
 	}
 
-	err = RunCommandWithFds(reader, nil, command, args...)
+	err = RunCommandWithFdsContext(ctx, reader, nil, command, args...)
 	if err != nil {
 		// Check if we ran out of space
 		fs := unix.Statfs_t{}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/lxc/lxd/shared/generate/file"
+	"github.com/lxc/lxd/shared/generate/openapi"
+)
+
+// Return a new openapi command.
+func newOpenAPI() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "openapi [sub-command]",
+		Short: "OpenAPI description related code generation.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return fmt.Errorf("Not implemented")
+		},
+	}
+
+	cmd.AddCommand(newOpenAPITypes())
+
+	return cmd
+}
+
+func newOpenAPITypes() *cobra.Command {
+	var target string
+	var pkg string
+	var variable string
+
+	cmd := &cobra.Command{
+		Use:   "types",
+		Short: "Generate the map of the api structs used to describe the API calls.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			types, err := openapi.NewTypes(pkg, variable)
+			if err != nil {
+				return err
+			}
+
+			err = file.Reset(target, openapi.Imports)
+			if err != nil {
+				return err
+			}
+
+			return file.Append(target, types)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&target, "target", "t", "-", "target source file to generate")
+	flags.StringVarP(&pkg, "package", "p", "github.com/lxc/lxd/shared/api", "Go package where the structs are declared")
+	flags.StringVarP(&variable, "variable", "v", "openapiAPITypes", "name of the variable holding the map")
+
+	return cmd
+}
@@ -0,0 +1,77 @@
+package openapi
+
+import (
+	"go/ast"
+	"sort"
+
+	"github.com/lxc/lxd/shared/generate/file"
+	"github.com/lxc/lxd/shared/generate/lex"
+	"github.com/pkg/errors"
+)
+
+// Imports is a list of the package imports every generated source file has.
+var Imports = []string{
+	"github.com/lxc/lxd/shared/api",
+}
+
+// Types generates a code snippet declaring a map of the names of the structs
+// of the api package to a value of each, for describing the API calls
+// exchanging them.
+type Types struct {
+	variable string   // Name of the variable holding the map.
+	names    []string // Names of the api structs.
+}
+
+// NewTypes returns a new code snippet for the structs of the given package.
+func NewTypes(pkg string, variable string) (*Types, error) {
+	parsed, err := lex.Parse(pkg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Parse %q", pkg)
+	}
+
+	types := &Types{
+		variable: variable,
+		names:    StructNames(parsed),
+	}
+
+	return types, nil
+}
+
+// Generate the map declaration.
+func (t *Types) Generate(buf *file.Buffer) error {
+	buf.L("// %s maps the names of the api structs to a value of each.", t.variable)
+	buf.L("var %s = map[string]interface{}{", t.variable)
+	for _, name := range t.names {
+		buf.L("%q: api.%s{},", name, name)
+	}
+	buf.L("}")
+
+	return nil
+}
+
+// StructNames returns the sorted names of the exported structs of the given
+// package.
+func StructNames(pkg *ast.Package) []string {
+	names := []string{}
+	for name, object := range pkg.Scope.Objects {
+		if object.Kind != ast.Typ || !ast.IsExported(name) {
+			continue
+		}
+
+		spec, ok := object.Decl.(*ast.TypeSpec)
+		if !ok {
+			continue
+		}
+
+		_, ok = spec.Type.(*ast.StructType)
+		if !ok {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
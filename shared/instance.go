@@ -1,6 +1,9 @@
 package shared
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -9,6 +12,7 @@ import (
 
 	"github.com/pkg/errors"
 	"gopkg.in/robfig/cron.v2"
+	"gopkg.in/yaml.v2"
 
 	"github.com/lxc/lxd/shared/units"
 )
@@ -147,6 +151,58 @@ func IsDeviceID(value string) error {
 	return nil
 }
 
+// LinuxCapabilities lists the names of the Linux capabilities, in lowercase and without their
+// "cap_" prefix.
+var LinuxCapabilities = []string{
+	"audit_control", "audit_read", "audit_write", "block_suspend", "bpf", "checkpoint_restore",
+	"chown", "dac_override", "dac_read_search", "fowner", "fsetid", "ipc_lock", "ipc_owner",
+	"kill", "lease", "linux_immutable", "mac_admin", "mac_override", "mknod", "net_admin",
+	"net_bind_service", "net_broadcast", "net_raw", "perfmon", "setfcap", "setgid", "setpcap",
+	"setuid", "sys_admin", "sys_boot", "sys_chroot", "sys_module", "sys_nice", "sys_pacct",
+	"sys_ptrace", "sys_rawio", "sys_resource", "sys_time", "sys_tty_config", "syslog",
+	"wake_alarm",
+}
+
+// SplitCapabilities splits a comma separated list of capabilities.
+func SplitCapabilities(value string) []string {
+	capabilities := []string{}
+	for _, capability := range strings.Split(value, ",") {
+		capability = strings.TrimSpace(capability)
+		if capability != "" {
+			capabilities = append(capabilities, capability)
+		}
+	}
+
+	return capabilities
+}
+
+// IsCapabilityList validates a comma separated list of Linux capabilities.
+func IsCapabilityList(value string) error {
+	for _, capability := range SplitCapabilities(value) {
+		if !StringInSlice(capability, LinuxCapabilities) {
+			return fmt.Errorf("Unknown capability %q", capability)
+		}
+	}
+
+	return nil
+}
+
+// IsClusterGroupList validates a comma separated list of cluster group names.
+func IsClusterGroupList(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return fmt.Errorf("Cluster group names can't be empty")
+		}
+
+		if strings.ContainsAny(name, "/@ \t\n") {
+			return fmt.Errorf("Invalid cluster group name %q", name)
+		}
+	}
+
+	return nil
+}
+
 // IsRootDiskDevice returns true if the given device representation is configured as root disk for
 // a container. It typically get passed a specific entry of api.Instance.Devices.
 func IsRootDiskDevice(device map[string]string) bool {
@@ -190,15 +246,52 @@ var HugePageSizeKeys = [...]string{"limits.hugepages.64KB", "limits.hugepages.1M
 // HugePageSizeSuffix contains the list of known hugepage size suffixes.
 var HugePageSizeSuffix = [...]string{"64KB", "1MB", "2MB", "1GB"}
 
+// InstanceWarmPoolMaxSize is the largest value allowed for warm_pool.size.
+const InstanceWarmPoolMaxSize = 64
+
 // KnownInstanceConfigKeys maps all fully defined, well-known config keys
 // to an appropriate checker function, which validates whether or not a
 // given value is syntactically legal.
 var KnownInstanceConfigKeys = map[string]func(value string) error{
+	"autoscale.cpu.max":    IsUint32,
+	"autoscale.cpu.min":    IsUint32,
+	"autoscale.memory.max": IsSize,
+	"autoscale.memory.min": IsSize,
+	"autoscale.window":     IsUint32,
+
 	"boot.autostart":             IsBool,
 	"boot.autostart.delay":       IsInt64,
 	"boot.autostart.priority":    IsInt64,
 	"boot.stop.priority":         IsInt64,
 	"boot.host_shutdown_timeout": IsInt64,
+	"boot.reset_rootfs":          IsBool,
+
+	"boot.provision": func(value string) error {
+		_, err := InstanceProvisionParse(value)
+		return err
+	},
+
+	"cluster.groups": IsClusterGroupList,
+
+	"console.display": IsBool,
+
+	"groups": func(value string) error {
+		_, err := InstanceGroupsParse(value)
+		return err
+	},
+
+	"host.locale": IsBool,
+	"host.timezone": func(value string) error {
+		return IsOneOf(value, []string{"mount", "env"})
+	},
+	"host.timezone.follow":  IsBool,
+	"host.timezone.replace": IsBool,
+
+	"idle.action": func(value string) error {
+		return IsOneOf(value, []string{"freeze", "stop"})
+	},
+	"idle.resume":  IsBool,
+	"idle.timeout": IsUint32,
 
 	"limits.cpu": func(value string) error {
 		if value == "" {
@@ -294,10 +387,38 @@ var KnownInstanceConfigKeys = map[string]func(value string) error{
 	"limits.memory.hugepages":     IsBool,
 
 	"limits.network.priority": IsPriority,
+	"limits.network.quota":    IsSize,
+	"limits.network.quota.action": func(value string) error {
+		return IsOneOf(value, []string{"none", "throttle"})
+	},
+	"limits.network.quota.period": func(value string) error {
+		return IsOneOf(value, []string{"day", "month"})
+	},
+	"limits.network.quota.throttle": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		_, err := units.ParseBitSizeString(value)
+		return err
+	},
 
 	"limits.processes": IsInt64,
 
+	"linux.dev": func(value string) error {
+		return IsOneOf(value, []string{"full", "minimal", "none"})
+	},
+	"linux.dev.size":       IsSize,
 	"linux.kernel_modules": IsAny,
+	"linux.namespaces.ipc": IsAny,
+	"linux.namespaces.net": IsAny,
+	"linux.namespaces.pid": IsAny,
+	"linux.namespaces.uts": IsAny,
+
+	"maintenance.windows": func(value string) error {
+		_, err := InstanceMaintenanceWindowsParse(value)
+		return err
+	},
 
 	"migration.incremental.memory":            IsBool,
 	"migration.incremental.memory.iterations": IsUint32,
@@ -308,6 +429,8 @@ var KnownInstanceConfigKeys = map[string]func(value string) error{
 	"nvidia.require.cuda":        IsAny,
 	"nvidia.require.driver":      IsAny,
 
+	"references.escape": IsBool,
+
 	"security.nesting":       IsBool,
 	"security.privileged":    IsBool,
 	"security.devlxd":        IsBool,
@@ -316,6 +439,44 @@ var KnownInstanceConfigKeys = map[string]func(value string) error{
 	"security.protection.delete": IsBool,
 	"security.protection.shift":  IsBool,
 
+	"security.core_scheduling": IsBool,
+
+	"security.readonly_rootfs":  IsBool,
+	"security.ephemeral_rootfs": IsBool,
+
+	"security.capabilities.drop": IsCapabilityList,
+	"security.capabilities.keep": func(value string) error {
+		if value == "none" {
+			return nil
+		}
+
+		return IsCapabilityList(value)
+	},
+
+	"security.keyring": func(value string) error {
+		return IsOneOf(value, []string{"isolated", "blocked"})
+	},
+	"security.proc": func(value string) error {
+		return IsOneOf(value, []string{"rw", "mixed"})
+	},
+	"security.sys": func(value string) error {
+		return IsOneOf(value, []string{"rw", "mixed", "ro"})
+	},
+	"security.masked_paths": func(value string) error {
+		for _, path := range strings.Split(value, ",") {
+			path = strings.TrimSpace(path)
+			if path == "" {
+				continue
+			}
+
+			if !strings.HasPrefix(path, "/") {
+				return fmt.Errorf("Masked path %q isn't absolute", path)
+			}
+		}
+
+		return nil
+	},
+
 	"security.idmap.base":     IsUint32,
 	"security.idmap.isolated": IsBool,
 	"security.idmap.size":     IsUint32,
@@ -351,11 +512,19 @@ var KnownInstanceConfigKeys = map[string]func(value string) error{
 	},
 	"snapshots.schedule.stopped": IsBool,
 	"snapshots.pattern":          IsAny,
+	"snapshots.before_changes":   IsBool,
 	"snapshots.expiry": func(value string) error {
 		// Validate expression
 		_, err := GetSnapshotExpiry(time.Time{}, value)
 		return err
 	},
+	"snapshots.retention":     IsUint32,
+	"snapshots.pre":           IsAny,
+	"snapshots.post":          IsAny,
+	"snapshots.hooks.timeout": IsUint32,
+	"snapshots.hooks.failure": func(value string) error {
+		return IsOneOf(value, []string{"abort", "ignore"})
+	},
 
 	// Caller is responsible for full validation of any raw.* value
 	"raw.apparmor": IsAny,
@@ -364,14 +533,284 @@ var KnownInstanceConfigKeys = map[string]func(value string) error{
 	"raw.qemu":     IsAny,
 	"raw.seccomp":  IsAny,
 
+	"warm_pool.size": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		size, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("Invalid value for uint32: %s: %v", value, err)
+		}
+
+		if size > InstanceWarmPoolMaxSize {
+			return fmt.Errorf("The warm pool size can't be larger than %d", InstanceWarmPoolMaxSize)
+		}
+
+		return nil
+	},
+	"warm_pool.state": func(value string) error {
+		return IsOneOf(value, []string{"stopped", "frozen"})
+	},
+
 	"volatile.apply_template":   IsAny,
 	"volatile.base_image":       IsAny,
+	"volatile.base_image.alias": IsAny,
+	"volatile.last_exit":        IsAny,
 	"volatile.last_state.idmap": IsAny,
 	"volatile.last_state.power": IsAny,
 	"volatile.idmap.base":       IsAny,
 	"volatile.idmap.current":    IsAny,
 	"volatile.idmap.next":       IsAny,
 	"volatile.apply_quota":      IsAny,
+
+	"volatile.autoscale.cpu":    IsAny,
+	"volatile.autoscale.memory": IsAny,
+
+	"volatile.network.quota_exceeded": IsAny,
+	"volatile.network.throttle":       IsAny,
+
+	"volatile.snapshots.deferred": IsAny,
+
+	"volatile.warm_pool.template": IsAny,
+
+	"volatile.replica.source": IsAny,
+}
+
+// InstanceProvisionStep is a step of the boot.provision list, either a command or a file.
+type InstanceProvisionStep struct {
+	Command []string               `yaml:"command"`
+	File    *InstanceProvisionFile `yaml:"file"`
+}
+
+// InstanceProvisionFile is a file created by a boot.provision step.
+type InstanceProvisionFile struct {
+	Path    string `yaml:"path"`
+	Content string `yaml:"content"`
+	UID     int64  `yaml:"uid"`
+	GID     int64  `yaml:"gid"`
+	Mode    string `yaml:"mode"`
+}
+
+// InstanceProvisionParse parses and checks the value of boot.provision.
+func InstanceProvisionParse(value string) ([]InstanceProvisionStep, error) {
+	steps := []InstanceProvisionStep{}
+	err := yaml.UnmarshalStrict([]byte(value), &steps)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid provisioning steps")
+	}
+
+	for i, step := range steps {
+		if (len(step.Command) > 0) == (step.File != nil) {
+			return nil, fmt.Errorf("Provisioning step %d must have either a command or a file", i)
+		}
+
+		if step.File == nil {
+			continue
+		}
+
+		if !strings.HasPrefix(step.File.Path, "/") {
+			return nil, fmt.Errorf("Provisioning step %d: file path must be absolute", i)
+		}
+
+		if step.File.Mode != "" {
+			_, err := strconv.ParseUint(step.File.Mode, 8, 32)
+			if err != nil {
+				return nil, fmt.Errorf("Provisioning step %d: invalid file mode %q", i, step.File.Mode)
+			}
+		}
+	}
+
+	return steps, nil
+}
+
+// InstanceProvisionStatusKeys returns the volatile keys recording the status of the given boot.provision
+// steps. The keys are derived from the content of the steps, so that editing the list only re-runs the
+// added and modified steps. Identical steps are told apart by their number of occurrences.
+func InstanceProvisionStatusKeys(steps []InstanceProvisionStep) []string {
+	keys := make([]string, 0, len(steps))
+	seen := map[string]int{}
+
+	for _, step := range steps {
+		content, _ := json.Marshal(step)
+		hash := sha256.Sum256(content)
+		id := hex.EncodeToString(hash[:])[:12]
+
+		seen[id]++
+		if seen[id] > 1 {
+			id = fmt.Sprintf("%s-%d", id, seen[id])
+		}
+
+		keys = append(keys, fmt.Sprintf("volatile.provision.%s.status", id))
+	}
+
+	return keys
+}
+
+// InstanceGroupValidName checks the name of an instance group.
+func InstanceGroupValidName(name string) error {
+	if name == "" {
+		return fmt.Errorf("Group names can't be empty")
+	}
+
+	if strings.ContainsAny(name, "/, \t\n") {
+		return fmt.Errorf("Group name %q can't contain slashes, commas or whitespace", name)
+	}
+
+	return nil
+}
+
+// InstanceGroupsParse parses and checks the value of groups, a comma separated list of the names
+// of the groups an instance belongs to.
+func InstanceGroupsParse(value string) ([]string, error) {
+	groups := []string{}
+	if strings.TrimSpace(value) == "" {
+		return groups, nil
+	}
+
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		err := InstanceGroupValidName(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if !StringInSlice(name, groups) {
+			groups = append(groups, name)
+		}
+	}
+
+	return groups, nil
+}
+
+// InstanceMaintenanceWindow is a weekly time range, from maintenance.windows, during which automated
+// actions disrupting an instance may happen.
+type InstanceMaintenanceWindow struct {
+	Days  [7]bool // Days of the week the window starts on, indexed by time.Weekday
+	Start int     // Minute of the day the window starts at
+	End   int     // Minute of the day the window ends at, the next day if before Start
+}
+
+var instanceMaintenanceDays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// InstanceMaintenanceWindowsParse parses and checks the value of maintenance.windows, a comma
+// separated list of "[<day>[-<day>]] <HH:MM>-<HH:MM>" windows such as "sat-sun 02:00-06:00".
+func InstanceMaintenanceWindowsParse(value string) ([]InstanceMaintenanceWindow, error) {
+	windows := []InstanceMaintenanceWindow{}
+	if strings.TrimSpace(value) == "" {
+		return windows, nil
+	}
+
+	parseDay := func(day string) (int, error) {
+		for i, name := range instanceMaintenanceDays {
+			if strings.ToLower(day) == name {
+				return i, nil
+			}
+		}
+
+		return -1, fmt.Errorf("Invalid day %q", day)
+	}
+
+	parseTime := func(value string) (int, error) {
+		t, err := time.Parse("15:04", value)
+		if err != nil {
+			return -1, fmt.Errorf("Invalid time %q", value)
+		}
+
+		return t.Hour()*60 + t.Minute(), nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		fields := strings.Fields(entry)
+		if len(fields) < 1 || len(fields) > 2 {
+			return nil, fmt.Errorf("Invalid maintenance window %q", strings.TrimSpace(entry))
+		}
+
+		window := InstanceMaintenanceWindow{}
+		if len(fields) == 1 {
+			for i := range window.Days {
+				window.Days[i] = true
+			}
+		} else {
+			days := strings.SplitN(fields[0], "-", 2)
+			first, err := parseDay(days[0])
+			if err != nil {
+				return nil, err
+			}
+
+			last := first
+			if len(days) == 2 {
+				last, err = parseDay(days[1])
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			// Ranges may wrap around the end of the week, such as fri-mon.
+			for i := first; ; i = (i + 1) % 7 {
+				window.Days[i] = true
+				if i == last {
+					break
+				}
+			}
+		}
+
+		times := strings.SplitN(fields[len(fields)-1], "-", 2)
+		if len(times) != 2 {
+			return nil, fmt.Errorf("Invalid time range %q", fields[len(fields)-1])
+		}
+
+		var err error
+		window.Start, err = parseTime(times[0])
+		if err != nil {
+			return nil, err
+		}
+
+		window.End, err = parseTime(times[1])
+		if err != nil {
+			return nil, err
+		}
+
+		if window.Start == window.End {
+			return nil, fmt.Errorf("Empty time range %q", fields[len(fields)-1])
+		}
+
+		windows = append(windows, window)
+	}
+
+	return windows, nil
+}
+
+// Contains returns whether the given time falls within the window.
+func (w InstanceMaintenanceWindow) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.Start < w.End {
+		return w.Days[t.Weekday()] && minute >= w.Start && minute < w.End
+	}
+
+	// The window ends on the next day.
+	if minute >= w.Start {
+		return w.Days[t.Weekday()]
+	}
+
+	return minute < w.End && w.Days[(t.Weekday()+6)%7]
+}
+
+// InstanceInMaintenanceWindow returns whether the given time falls within the maintenance windows of
+// an instance. Instances without maintenance windows are always considered in one.
+func InstanceInMaintenanceWindow(config map[string]string, t time.Time) bool {
+	windows, err := InstanceMaintenanceWindowsParse(config["maintenance.windows"])
+	if err != nil || len(windows) == 0 {
+		return true
+	}
+
+	for _, window := range windows {
+		if window.Contains(t) {
+			return true
+		}
+	}
+
+	return false
 }
 
 // ConfigKeyChecker returns a function that will check whether or not
@@ -433,6 +872,14 @@ func ConfigKeyChecker(key string) (func(value string) error, error) {
 		if strings.HasSuffix(key, ".driver") {
 			return IsAny, nil
 		}
+
+		if strings.HasPrefix(key, "volatile.provision.") && strings.HasSuffix(key, ".status") {
+			return IsAny, nil
+		}
+
+		if strings.HasPrefix(key, "volatile.template.") {
+			return IsUint32, nil
+		}
 	}
 
 	if strings.HasPrefix(key, "environment.") {
@@ -0,0 +1,117 @@
+package shared
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstanceInMaintenanceWindow(t *testing.T) {
+	// 2020-10-10 is a Saturday.
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2020, 10, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		windows  string
+		time     time.Time
+		expected bool
+	}{
+		{"", at(12, 12, 0), true},
+		{"sat-sun 02:00-06:00", at(10, 3, 0), true},
+		{"sat-sun 02:00-06:00", at(11, 2, 0), true},
+		{"sat-sun 02:00-06:00", at(10, 6, 0), false},
+		{"sat-sun 02:00-06:00", at(12, 3, 0), false},
+		{"fri 23:00-01:00", at(9, 23, 30), true},
+		{"fri 23:00-01:00", at(10, 0, 30), true},
+		{"fri 23:00-01:00", at(10, 23, 30), false},
+		{"fri-mon 12:00-13:00", at(11, 12, 30), true},
+		{"fri-mon 12:00-13:00", at(13, 12, 30), false},
+		{"22:00-23:00", at(14, 22, 30), true},
+		{"mon 01:00-02:00, 22:00-23:00", at(12, 1, 30), true},
+		{"mon 01:00-02:00, 22:00-23:00", at(13, 1, 30), false},
+	}
+
+	for _, c := range cases {
+		config := map[string]string{"maintenance.windows": c.windows}
+		assert.Equal(t, c.expected, InstanceInMaintenanceWindow(config, c.time), "%q at %s", c.windows, c.time)
+	}
+}
+
+func TestInstanceMaintenanceWindowsParse_Invalid(t *testing.T) {
+	for _, value := range []string{"sat 02:00", "foo 01:00-02:00", "01:00-01:00", "25:00-26:00", "sat sun 01:00-02:00"} {
+		_, err := InstanceMaintenanceWindowsParse(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestInstanceGroupsParse(t *testing.T) {
+	groups, err := InstanceGroupsParse("web, db,web")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web", "db"}, groups)
+
+	groups, err = InstanceGroupsParse("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, groups)
+
+	for _, value := range []string{"web,,db", "a/b", "web db"} {
+		_, err := InstanceGroupsParse(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestIsClusterGroupList(t *testing.T) {
+	assert.NoError(t, IsClusterGroupList("gpu, fast-storage"))
+
+	for _, value := range []string{"gpu,,db", "a/b", "@gpu", "gpu nodes"} {
+		assert.Error(t, IsClusterGroupList(value), value)
+	}
+}
+
+func TestInstanceProvisionParse(t *testing.T) {
+	steps, err := InstanceProvisionParse(`
+- file:
+    path: /etc/motd
+    content: hello
+    mode: "0600"
+- command: ["apt-get", "install", "-y", "nginx"]
+`)
+	assert.NoError(t, err)
+	assert.Len(t, steps, 2)
+	assert.Equal(t, "/etc/motd", steps[0].File.Path)
+	assert.Equal(t, []string{"apt-get", "install", "-y", "nginx"}, steps[1].Command)
+
+	for _, value := range []string{
+		`- {}`,
+		`- {command: [ls], file: {path: /etc/motd}}`,
+		`- file: {path: etc/motd}`,
+		`- file: {path: /etc/motd, mode: "0999"}`,
+		`- unknown: true`,
+	} {
+		_, err := InstanceProvisionParse(value)
+		assert.Error(t, err, value)
+	}
+}
+
+// The status keys of the steps only depend on their content.
+func TestInstanceProvisionStatusKeys(t *testing.T) {
+	update := InstanceProvisionStep{Command: []string{"apt-get", "update"}}
+	install := InstanceProvisionStep{Command: []string{"apt-get", "install", "-y", "nginx"}}
+
+	keys := InstanceProvisionStatusKeys([]InstanceProvisionStep{update, install})
+	assert.Len(t, keys, 2)
+	assert.Regexp(t, `^volatile\.provision\.[0-9a-f]{12}\.status$`, keys[0])
+	assert.NotEqual(t, keys[0], keys[1])
+
+	// Inserting a step keeps the keys of the existing ones.
+	inserted := InstanceProvisionStatusKeys([]InstanceProvisionStep{update, {Command: []string{"true"}}, install})
+	assert.Equal(t, keys[0], inserted[0])
+	assert.Equal(t, keys[1], inserted[2])
+
+	// Identical steps get different keys.
+	repeated := InstanceProvisionStatusKeys([]InstanceProvisionStep{update, update})
+	assert.Equal(t, keys[0], repeated[0])
+	assert.Equal(t, strings.Replace(keys[0], ".status", "-2.status", 1), repeated[1])
+}
@@ -17,6 +17,7 @@ type InstanceTarWriter struct {
 	tarWriter *tar.Writer
 	idmapSet  *idmap.IdmapSet
 	linkMap   map[uint64]string
+	filter    func(name string, fi os.FileInfo) bool
 }
 
 // NewInstanceTarWriter returns a ContainerTarWriter for the provided target Writer and id map.
@@ -34,6 +35,12 @@ func (ctw *InstanceTarWriter) ResetHardLinkMap() {
 	ctw.linkMap = map[uint64]string{}
 }
 
+// SetFilter sets a function called with the name and the info of every file about to be added to the
+// tarball, which is left out if the function returns false. A nil filter adds all the files.
+func (ctw *InstanceTarWriter) SetFilter(filter func(name string, fi os.FileInfo) bool) {
+	ctw.filter = filter
+}
+
 // WriteFile adds a file to the tarball with the specified name using the srcPath file as the contents of the file.
 // The ignoreGrowth argument indicates whether to error if the srcPath file increases in size beyond the size in fi
 // during the write. If false the write will return an error. If true, no error is returned, instead only the size
@@ -84,6 +91,10 @@ func (ctw *InstanceTarWriter) WriteFile(name string, srcPath string, fi os.FileI
 		}
 	}
 
+	if ctw.filter != nil && !ctw.filter(name, fi) {
+		return nil
+	}
+
 	hdr.Devmajor = int64(major)
 	hdr.Devminor = int64(minor)
 
@@ -135,6 +146,10 @@ func (ctw *InstanceTarWriter) WriteFile(name string, srcPath string, fi os.FileI
 // WriteFileFromReader streams a file into the tarball using the src reader.
 // A manually generated os.FileInfo should be supplied so that the tar header can be added before streaming starts.
 func (ctw *InstanceTarWriter) WriteFileFromReader(src io.Reader, fi os.FileInfo) error {
+	if ctw.filter != nil && !ctw.filter(fi.Name(), fi) {
+		return nil
+	}
+
 	hdr, err := tar.FileInfoHeader(fi, "")
 	if err != nil {
 		return errors.Wrap(err, "Failed to create tar info header")
@@ -3,6 +3,7 @@ package shared
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/gob"
 	"encoding/hex"
@@ -856,7 +857,27 @@ func (e RunError) Error() string {
 // the default environment is used. If the command fails to start or returns a non-zero exit code
 // then an error is returned containing the output of stderr too.
 func RunCommandSplit(env []string, name string, arg ...string) (string, string, error) {
-	cmd := exec.Command(name, arg...)
+	return runCommandSplit(context.Background(), env, name, arg...)
+}
+
+// RunCommandSplitContext is like RunCommandSplit, killing the command if the context is cancelled
+// before it completes.
+func RunCommandSplitContext(ctx context.Context, env []string, name string, arg ...string) (string, string, error) {
+	return runCommandSplit(ctx, env, name, arg...)
+}
+
+// RunCommandHook, if set, is called before running a command through the RunCommand functions. The
+// function it returns is called with the result of the command once it completes. It's used by the
+// daemon to trace subprocess invocations.
+var RunCommandHook func(ctx context.Context, name string, arg []string) func(err error)
+
+func runCommandSplit(ctx context.Context, env []string, name string, arg ...string) (stdoutStr string, stderrStr string, err error) {
+	if RunCommandHook != nil {
+		done := RunCommandHook(ctx, name, arg)
+		defer func() { done(err) }()
+	}
+
+	cmd := exec.CommandContext(ctx, name, arg...)
 
 	if env != nil {
 		cmd.Env = env
@@ -867,15 +888,15 @@ func RunCommandSplit(env []string, name string, arg ...string) (string, string,
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	err := cmd.Run()
+	err = cmd.Run()
 	if err != nil {
-		err := RunError{
+		runErr := RunError{
 			msg:    fmt.Sprintf("Failed to run: %s %s: %s", name, strings.Join(arg, " "), strings.TrimSpace(string(stderr.Bytes()))),
 			Stdout: string(stdout.Bytes()),
 			Stderr: string(stderr.Bytes()),
 			Err:    err,
 		}
-		return string(stdout.Bytes()), string(stderr.Bytes()), err
+		return string(stdout.Bytes()), string(stderr.Bytes()), runErr
 	}
 
 	return string(stdout.Bytes()), string(stderr.Bytes()), nil
@@ -888,6 +909,13 @@ func RunCommand(name string, arg ...string) (string, error) {
 	return stdout, err
 }
 
+// RunCommandContext runs a command with optional arguments and returns stdout. The command is killed
+// if the context is cancelled before it completes.
+func RunCommandContext(ctx context.Context, name string, arg ...string) (string, error) {
+	stdout, _, err := runCommandSplit(ctx, nil, name, arg...)
+	return stdout, err
+}
+
 // RunCommandCLocale runs a command with a LANG=C.UTF-8 environment set with optional arguments and
 // returns stdout. If the command fails to start or returns a non-zero exit code then an error is
 // returned containing the output of stderr.
@@ -897,7 +925,18 @@ func RunCommandCLocale(name string, arg ...string) (string, error) {
 }
 
 func RunCommandWithFds(stdin io.Reader, stdout io.Writer, name string, arg ...string) error {
-	cmd := exec.Command(name, arg...)
+	return RunCommandWithFdsContext(context.Background(), stdin, stdout, name, arg...)
+}
+
+// RunCommandWithFdsContext is like RunCommandWithFds, but the command is killed if the context is
+// cancelled before it completes.
+func RunCommandWithFdsContext(ctx context.Context, stdin io.Reader, stdout io.Writer, name string, arg ...string) (err error) {
+	if RunCommandHook != nil {
+		done := RunCommandHook(ctx, name, arg)
+		defer func() { done(err) }()
+	}
+
+	cmd := exec.CommandContext(ctx, name, arg...)
 
 	if stdin != nil {
 		cmd.Stdin = stdin
@@ -910,15 +949,15 @@ func RunCommandWithFds(stdin io.Reader, stdout io.Writer, name string, arg ...st
 	var buffer bytes.Buffer
 	cmd.Stderr = &buffer
 
-	err := cmd.Run()
+	err = cmd.Run()
 	if err != nil {
-		err := RunError{
+		runErr := RunError{
 			msg: fmt.Sprintf("Failed to run: %s %s: %s", name, strings.Join(arg, " "),
 				strings.TrimSpace(buffer.String())),
 			Err: err,
 		}
 
-		return err
+		return runErr
 	}
 
 	return nil
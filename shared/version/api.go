@@ -209,6 +209,83 @@ var APIExtensions = []string{
 	"container_nic_ipvlan_host_table",
 	"container_nic_ipvlan_mode",
 	"resources_system",
+	"instance_history",
+	"warnings",
+	"maintenance_mode",
+	"projects_default_profiles",
+	"profiles_conflicts",
+	"profiles_device_templates",
+	"instance_config_references",
+	"instance_snapshot_diff",
+	"instance_snapshot_files",
+	"instance_snapshot_mount",
+	"snapshots_before_changes",
+	"instance_project_move",
+	"projects_parent",
+	"projects_restricted_networks_pools",
+	"instance_idle",
+	"proxy_activation",
+	"container_core_scheduling",
+	"container_hardening",
+	"device_plugins",
+	"storage_driver_plugins",
+	"authorization_webhook",
+	"instances_admission_scriptlet",
+	"container_provisioning",
+	"migration_max_downtime",
+	"network_address_reservations",
+	"network_peers",
+	"network_bgp",
+	"network_multicast",
+	"instance_state_nic_details",
+	"nic_host_name_template",
+	"container_shared_netns",
+	"container_shared_namespaces",
+	"container_capabilities",
+	"container_dev_mode",
+	"disk_fstype",
+	"container_rootfs_modes",
+	"container_reset_rootfs",
+	"instance_clones",
+	"api_openapi",
+	"exec_terminal_framing",
+	"dashboard",
+	"image_alias_channels",
+	"instance_console_screenshot",
+	"console_log_persistence",
+	"container_last_exit",
+	"exec_control_numeric_args",
+	"container_oom_events",
+	"instance_state_pressure",
+	"exec_environment_inherit",
+	"instance_autoscale",
+	"container_network_quota",
+	"operations_history",
+	"instance_rebuild",
+	"otlp_tracing",
+	"debug_metrics",
+	"instance_maintenance_windows",
+	"instance_bulk_state_change",
+	"instance_host_timezone",
+	"server_tools",
+	"instance_stop_signal",
+	"instance_warm_pool",
+	"operation_not_before",
+	"instance_schedules",
+	"snapshots_retention",
+	"instance_groups",
+	"cluster_groups",
+	"clustering_member_roles",
+	"snapshot_restore_paths",
+	"instance_replications",
+	"instance_export_streaming",
+	"backup_incremental",
+	"snapshot_hooks",
+	"storage_pool_trim",
+	"storage_online_resize",
+	"limits_disk_priority_unified",
+	"orphans_report_only",
+	"network_priority_uplinks",
 }
 
 // APIExtensionsCount returns the number of available API extensions.